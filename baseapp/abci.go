@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -220,7 +221,18 @@ func (app *BaseApp) CheckTx(ctx context.Context, req *abci.RequestCheckTx) (*abc
 	}
 
 	sdkCtx := app.getContextForTx(mode, req.Tx)
+	var recorder *executionHintsRecorder
+	if app.checkTxExecutionHints != nil && mode == runTxModeCheck {
+		sdkCtx, recorder = newExecutionHintsRecorder(sdkCtx)
+	}
 	gInfo, result, _, priority, err := app.runTx(sdkCtx, mode, req.Tx)
+	if recorder != nil {
+		// the ante handler's writes persist to the check state even if the msgs fail, so always flush
+		recorder.flush()
+		if err == nil {
+			app.checkTxExecutionHints.record(req.Tx, recorder.hints(gInfo.GasUsed))
+		}
+	}
 	if err != nil {
 		res := sdkerrors.ResponseCheckTx(err, gInfo.GasWanted, gInfo.GasUsed, app.trace)
 		return &res, err
@@ -233,22 +245,127 @@ func (app *BaseApp) CheckTx(ctx context.Context, req *abci.RequestCheckTx) (*abc
 	}, nil
 }
 
-// DeliverTxBatch executes multiple txs
-func (app *BaseApp) DeliverTxBatch(ctx sdk.Context, req sdk.DeliverTxBatchRequest) (res sdk.DeliverTxBatchResponse) {
-	scheduler := tasks.NewScheduler(app.concurrencyWorkers, app.TracingInfo, app.DeliverTx)
-	// This will basically no-op the actual prefill if the metadata for the txs is empty
+// BuildDeliverTxBatchRequest builds a DeliverTxBatch request from raw txs, attaching execution hints if an
+// ExecutionHintsProvider has them, or else estimated writesets if an EstimatedWritesetsFn is set. A tx whose writesets
+// can't be estimated is still included, just without estimates.
+func (app *BaseApp) BuildDeliverTxBatchRequest(ctx sdk.Context, txs [][]byte) sdk.DeliverTxBatchRequest {
+	entries := make([]*sdk.DeliverTxEntry, 0, len(txs))
+	for txIndex, txBytes := range txs {
+		entry := &sdk.DeliverTxEntry{
+			Request: abci.RequestDeliverTx{Tx: txBytes},
+		}
+		if app.executionHintsProvider != nil {
+			entry.EstimatedWritesets, entry.EstimatedReadsets, entry.EstimatedGas = app.executionHintsProvider.ProvideExecutionHints(txBytes)
+		}
+		if entry.EstimatedWritesets == nil {
+			entry.EstimatedWritesets = app.estimateWritesets(ctx, txIndex, txBytes)
+		}
+		entries = append(entries, entry)
+	}
+	return sdk.DeliverTxBatchRequest{TxEntries: entries}
+}
+
+// estimateWritesets returns the writesets of a tx estimated by the EstimatedWritesetsFn, or nil if there is none or it
+// fails
+func (app *BaseApp) estimateWritesets(ctx sdk.Context, txIndex int, txBytes []byte) sdk.MappedWritesets {
+	if app.estimatedWritesetsFn == nil {
+		return nil
+	}
+	writesets, err := app.estimatedWritesetsFn(ctx, txIndex, txBytes)
+	if err != nil {
+		app.logger.Debug("failed to estimate writesets", "txIndex", txIndex, "err", err)
+		return nil
+	}
+	return writesets
+}
 
+// DeliverTxBatch executes multiple txs with the OCC scheduler. Like DeliverTxs, the scheduler processes the batch
+// against a branch of ctx, which is only written if it succeeds: otherwise the batch is executed sequentially against
+// ctx instead, and the response has no writeset hash, unless the error is one the node halts on (see handleOCCError).
+func (app *BaseApp) DeliverTxBatch(ctx sdk.Context, req sdk.DeliverTxBatchRequest) (res sdk.DeliverTxBatchResponse) {
 	// process all txs, this will also initializes the MVS if prefill estimates was disabled
-	txRes, err := scheduler.ProcessAll(ctx, req.TxEntries)
+	txRes, writesetHash, err := app.deliverTxsOCC(ctx, req.TxEntries)
 	if err != nil {
-		// TODO: handle error
+		app.handleOCCError(ctx, err)
+		txRes = app.deliverTxsSequential(ctx, req.TxEntries)
 	}
 
 	responses := make([]*sdk.DeliverTxResult, 0, len(req.TxEntries))
 	for _, tx := range txRes {
 		responses = append(responses, &sdk.DeliverTxResult{Response: tx})
 	}
-	return sdk.DeliverTxBatchResponse{Results: responses}
+	return sdk.DeliverTxBatchResponse{Results: responses, WritesetHash: writesetHash}
+}
+
+// DeliverTxs executes the txs of a block in order, with the OCC scheduler if OCC is enabled (see FlagOccEnabled and
+// FlagOccWorkers) and sequentially otherwise, so that apps don't have to assemble the scheduler themselves. The
+// scheduler processes the block against a branch of ctx, which is only written if it succeeds: otherwise the block is
+// executed sequentially against ctx instead, unless the error is one the node halts on (see handleOCCError).
+func (app *BaseApp) DeliverTxs(ctx sdk.Context, entries []*sdk.DeliverTxEntry) []abci.ResponseDeliverTx {
+	if app.occEnabled {
+		res, _, err := app.deliverTxsOCC(ctx, entries)
+		if err == nil {
+			return res
+		}
+		app.handleOCCError(ctx, err)
+	}
+	return app.deliverTxsSequential(ctx, entries)
+}
+
+// deliverTxsOCC executes the txs of a block with the OCC scheduler against a branch of ctx, and writes the branch to
+// ctx if the scheduler succeeds, so that a failed block leaves ctx untouched (even if the scheduler committed part of
+// it to its parent stores already). It returns the writeset hash of the block, if the scheduler hashes writesets.
+func (app *BaseApp) deliverTxsOCC(ctx sdk.Context, entries []*sdk.DeliverTxEntry) ([]abci.ResponseDeliverTx, []byte, error) {
+	scheduler := app.newOCCScheduler(ctx)
+	branch := ctx.MultiStore().CacheMultiStore()
+	res, err := scheduler.ProcessAll(ctx.WithMultiStore(branch), entries)
+	if err != nil {
+		return nil, nil, err
+	}
+	branch.Write()
+	return res, scheduler.WritesetHash(), nil
+}
+
+// deliverTxsSequential executes the txs of a block one after the other against ctx
+func (app *BaseApp) deliverTxsSequential(ctx sdk.Context, entries []*sdk.DeliverTxEntry) []abci.ResponseDeliverTx {
+	if app.TracingEnabled {
+		spanCtx, span := app.TracingInfo.StartWithContext("DeliverTxsSequential", ctx.TraceSpanContext())
+		defer span.End()
+		ctx = ctx.WithTraceSpanContext(spanCtx)
+	}
+	responses := make([]abci.ResponseDeliverTx, 0, len(entries))
+	for i, entry := range entries {
+		responses = append(responses, app.DeliverTx(ctx.WithTxIndex(i), entry.Request))
+	}
+	return responses
+}
+
+// newOCCScheduler returns a scheduler for a block, with the workers, tracing and options of the app, and those set by
+// the consensus parameters of ctx
+func (app *BaseApp) newOCCScheduler(ctx sdk.Context) tasks.Scheduler {
+	opts := app.occOptions()
+	if app.GetOCCParams(ctx.WithGasMeter(sdk.NewInfiniteGasMeter())).StrictWritesets {
+		opts = append(opts[:len(opts):len(opts)], tasks.WithStrictWritesets())
+	}
+	if app.occPrefixStats != nil {
+		opts = append(opts[:len(opts):len(opts)], tasks.WithPrefixStats(app.occPrefixStats))
+	}
+	if app.occWorkerTuner != nil {
+		opts = append(opts[:len(opts):len(opts)], tasks.WithWorkerTuner(app.occWorkerTuner))
+	}
+	if app.occWorkerPool != nil {
+		opts = append(opts[:len(opts):len(opts)], tasks.WithWorkerPool(app.occWorkerPool))
+	}
+	if app.occEstimateCache != nil {
+		opts = append(opts[:len(opts):len(opts)], tasks.WithEstimateCarryover(app.occEstimateCache))
+	}
+	if app.occInvariantChecks != nil {
+		opts = append(opts[:len(opts):len(opts)], tasks.WithInvariantChecks(app.occInvariantChecks))
+	}
+	if app.occInspector != nil {
+		opts = append(opts[:len(opts):len(opts)], tasks.WithInspector(app.occInspector))
+	}
+	return tasks.NewScheduler(app.concurrencyWorkers, app.TracingInfo, app.DeliverTx, opts...)
 }
 
 // DeliverTx implements the ABCI interface and executes a tx in DeliverTx mode.
@@ -272,12 +389,28 @@ func (app *BaseApp) DeliverTx(ctx sdk.Context, req abci.RequestDeliverTx) (res a
 	resultStr := "successful"
 
 	defer func() {
-		telemetry.IncrCounter(1, "tx", "count")
-		telemetry.IncrCounter(1, "tx", resultStr)
-		telemetry.SetGauge(float32(gInfo.GasUsed), "tx", "gas", "used")
-		telemetry.SetGauge(float32(gInfo.GasWanted), "tx", "gas", "wanted")
+		// under OCC, only the metrics of the final execution of the tx are emitted
+		ctx.Telemetry().IncrCounter(1, "tx", "count")
+		ctx.Telemetry().IncrCounter(1, "tx", resultStr)
+		ctx.Telemetry().SetGauge(float32(gInfo.GasUsed), "tx", "gas", "used")
+		ctx.Telemetry().SetGauge(float32(gInfo.GasWanted), "tx", "gas", "wanted")
 	}()
 
+	// in strict mode the writes of the tx are recorded, and only flushed if they kept to its declared writesets
+	if declared := app.declaredWritesets(ctx, req.Tx); declared != nil {
+		var recorder *executionHintsRecorder
+		ctx, recorder = newExecutionHintsRecorder(ctx)
+		defer func() {
+			if undeclared := recorder.undeclaredWrite(declared); undeclared != nil {
+				resultStr = "failed"
+				err := sdkerrors.Wrap(sdkerrors.ErrOCCUndeclaredWrite, undeclared.Error())
+				res = sdkerrors.ResponseDeliverTx(err, gInfo.GasWanted, gInfo.GasUsed, app.trace)
+				return
+			}
+			recorder.flush()
+		}()
+	}
+
 	gInfo, result, anteEvents, _, err := app.runTx(ctx.WithTxBytes(req.Tx).WithVoteInfos(app.voteInfos), runTxModeDeliver, req.Tx)
 	if err != nil {
 		resultStr = "failed"
@@ -844,6 +977,9 @@ func handleQueryApp(app *BaseApp, path []string, req abci.RequestQuery) abci.Res
 				Value:     responseValue,
 			}
 
+		case "occ-prefix-suggestions":
+			return handleQueryOCCPrefixSuggestions(app, path, req)
+
 		default:
 			return sdkerrors.QueryResultWithDebug(sdkerrors.Wrapf(sdkerrors.ErrUnknownRequest, "unknown query: %s", path), app.trace)
 		}
@@ -856,6 +992,35 @@ func handleQueryApp(app *BaseApp, path []string, req abci.RequestQuery) abci.Res
 		), app.trace)
 }
 
+// handleQueryOCCPrefixSuggestions returns the key prefixes that are conflict exemption or deferred write candidates
+// given the collected OCC prefix statistics, as JSON. The optional third path element is the minimum number of blocks
+// a prefix must have been accessed in to be suggested, which defaults to 1.
+func handleQueryOCCPrefixSuggestions(app *BaseApp, path []string, req abci.RequestQuery) abci.ResponseQuery {
+	if app.occPrefixStats == nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(sdkerrors.ErrInvalidRequest, "occ prefix stats are not enabled"))
+	}
+
+	minBlocks := 1
+	if len(path) >= 3 {
+		n, err := strconv.Atoi(path[2])
+		if err != nil || n < 1 {
+			return sdkerrors.QueryResult(sdkerrors.Wrapf(sdkerrors.ErrInvalidRequest, "invalid minimum blocks %q", path[2]))
+		}
+		minBlocks = n
+	}
+
+	bz, err := json.Marshal(app.occPrefixStats.Suggestions(minBlocks))
+	if err != nil {
+		return sdkerrors.QueryResult(sdkerrors.Wrap(err, "failed to marshal occ prefix suggestions"))
+	}
+
+	return abci.ResponseQuery{
+		Codespace: sdkerrors.RootCodespace,
+		Height:    req.Height,
+		Value:     bz,
+	}
+}
+
 func handleQueryStore(app *BaseApp, path []string, req abci.RequestQuery) abci.ResponseQuery {
 	// "/store" prefix for store queries
 	queryable, ok := app.cms.(sdk.Queryable)
@@ -1048,6 +1213,10 @@ func (app *BaseApp) ProcessProposal(ctx context.Context, req *abci.RequestProces
 		}
 	}()
 
+	if !app.screenProposal(app.processProposalState.ctx, req.Txs) {
+		return &abci.ResponseProcessProposal{Status: abci.ResponseProcessProposal_REJECT}, nil
+	}
+
 	if app.processProposalHandler != nil {
 		resp, err = app.processProposalHandler(app.processProposalState.ctx, req)
 		if err != nil {
@@ -1109,6 +1278,9 @@ func (app *BaseApp) FinalizeBlock(ctx context.Context, req *abci.RequestFinalize
 
 	app.prepareDeliverState(req.Hash)
 
+	// the OCC config only changes between blocks
+	app.applyPendingOCCConfig()
+
 	// we also set block gas meter to checkState in case the application needs to
 	// verify gas consumption during (Re)CheckTx
 	if app.checkState != nil {
@@ -30,6 +30,7 @@ import (
 	servertypes "github.com/cosmos/cosmos-sdk/server/types"
 	"github.com/cosmos/cosmos-sdk/snapshots"
 	"github.com/cosmos/cosmos-sdk/store"
+	"github.com/cosmos/cosmos-sdk/tasks"
 	"github.com/cosmos/cosmos-sdk/telemetry"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	acltypes "github.com/cosmos/cosmos-sdk/types/accesscontrol"
@@ -61,6 +62,7 @@ const (
 	FlagChainID            = "chain-id"
 	FlagConcurrencyWorkers = "concurrency-workers"
 	FlagOccEnabled         = "occ-enabled"
+	FlagOccWorkers         = "occ-workers"
 )
 
 var (
@@ -168,9 +170,36 @@ type BaseApp struct { //nolint: maligned
 	TracingInfo    *tracing.Info
 	TracingEnabled bool
 
-	concurrencyWorkers int
-	occEnabled         bool
-}
+	concurrencyWorkers   int
+	occEnabled           bool
+	occSchedulerOptions  []tasks.SchedulerOption
+	occPrefixStats       *tasks.PrefixStats
+	occWorkerTuner       *tasks.WorkerTuner
+	occWorkerPool        *tasks.WorkerPool
+	occEstimateCache     *tasks.EstimateCache
+	occInvariantChecks   *tasks.InvariantChecks
+	occInspector         *tasks.Inspector
+	estimatedWritesetsFn EstimatedWritesetsFn
+
+	// the OCC configuration from app.toml, see UpdateOCCConfig, of which the enable and workers settings are kept in
+	// occEnabled and concurrencyWorkers
+	occConfig        config.OCCConfig
+	occConfigOptions []tasks.SchedulerOption
+	occConfigLock    sync.Mutex
+	pendingOCCConfig *config.OCCConfig
+
+	// executionHintsProvider provides hints for DeliverTxBatch requests, and checkTxExecutionHints (if set) records
+	// hints from CheckTx
+	executionHintsProvider sdk.ExecutionHintsProvider
+	checkTxExecutionHints  *CheckTxExecutionHints
+
+	// conflictScreen (if set) screens proposals for conflict graphs that would force near-serial execution
+	conflictScreen *ConflictScreen
+}
+
+// EstimatedWritesetsFn estimates the writesets of a tx from its bytes (eg. using ante and message dependencies) so
+// that the OCC scheduler can prefill estimates before execution. Estimates are only hints, so errors are non-fatal.
+type EstimatedWritesetsFn func(ctx sdk.Context, txIndex int, txBytes []byte) (sdk.MappedWritesets, error)
 
 type appStore struct {
 	db          dbm.DB               // common DB backend
@@ -295,15 +324,9 @@ func NewBaseApp(
 	}
 	app.startCompactionRoutine(db)
 
-	// if no option overrode already, initialize to the flags value
+	// if no option overrode already, initialize to the flags value, or else the default value
 	// this avoids forcing every implementation to pass an option, but allows it
-	if app.concurrencyWorkers == 0 {
-		app.concurrencyWorkers = cast.ToInt(appOpts.Get(FlagConcurrencyWorkers))
-	}
-	// safely default this to the default value if 0
-	if app.concurrencyWorkers == 0 {
-		app.concurrencyWorkers = config.DefaultConcurrencyWorkers
-	}
+	app.initOCCConfig(appOpts)
 
 	return app
 }
@@ -700,6 +723,27 @@ func (app *BaseApp) GetConsensusParams(ctx sdk.Context) *tmproto.ConsensusParams
 	return cp
 }
 
+// GetOCCParams returns the OCC consensus parameters from the baseapp's param store, which are all disabled if they
+// weren't set.
+func (app *BaseApp) GetOCCParams(ctx sdk.Context) OCCParams {
+	var params OCCParams
+	if app.paramStore == nil || !app.paramStore.Has(ctx, ParamStoreKeyOCCParams) {
+		return params
+	}
+
+	app.paramStore.Get(ctx, ParamStoreKeyOCCParams, &params)
+	return params
+}
+
+// StoreOCCParams sets the OCC consensus parameters to the baseapp's param store.
+func (app *BaseApp) StoreOCCParams(ctx sdk.Context, params OCCParams) {
+	if app.paramStore == nil {
+		panic("cannot store occ params with no params store set")
+	}
+
+	app.paramStore.Set(ctx, ParamStoreKeyOCCParams, params)
+}
+
 // AddRunTxRecoveryHandler adds custom app.runTx method panic handlers.
 func (app *BaseApp) AddRunTxRecoveryHandler(handlers ...RecoveryHandler) {
 	for _, h := range handlers {
@@ -867,6 +911,8 @@ func (app *BaseApp) runTx(ctx sdk.Context, mode runTxMode, txBytes []byte) (gInf
 			acltypes.SendAllSignalsForTx(ctx.TxCompletionChannels())
 			recoveryMW := newOutOfGasRecoveryMiddleware(gasWanted, ctx, app.runTxRecoveryMiddleware)
 			recoveryMW = newOCCAbortRecoveryMiddleware(recoveryMW) // TODO: do we have to wrap with occ enabled check?
+			recoveryMW = newOCCLimitRecoveryMiddleware(recoveryMW)
+			recoveryMW = newOCCMemoryLimitRecoveryMiddleware(recoveryMW)
 			err, result = processRecovery(r, recoveryMW), nil
 			if mode != runTxModeDeliver {
 				ctx.MultiStore().ResetEvents()
@@ -1144,6 +1190,9 @@ func (app *BaseApp) Close() error {
 	if err := app.cms.Close(); err != nil {
 		return err
 	}
+	if app.occWorkerPool != nil {
+		app.occWorkerPool.Close()
+	}
 	return app.snapshotManager.Close()
 }
 
@@ -12,7 +12,9 @@ import (
 	dbm "github.com/tendermint/tm-db"
 
 	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/cosmos/cosmos-sdk/server/config"
 	store "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/tasks"
 	"github.com/cosmos/cosmos-sdk/testutil"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/x/auth/legacy/legacytx"
@@ -145,6 +147,80 @@ func TestSetOccEnabled(t *testing.T) {
 	require.True(t, app.OccEnabled())
 }
 
+func TestEnableOCC(t *testing.T) {
+	app := newBaseApp(t.Name(), EnableOCC(7))
+	require.True(t, app.OccEnabled())
+	require.Equal(t, 7, app.ConcurrencyWorkers())
+	require.Empty(t, app.occSchedulerOptions)
+}
+
+func TestUpdateOCCConfig(t *testing.T) {
+	app := newBaseApp(t.Name(), EnableOCC(7))
+	app.SetFinalizeBlocker(func(ctx sdk.Context, req *abci.RequestFinalizeBlock) (*abci.ResponseFinalizeBlock, error) {
+		return &abci.ResponseFinalizeBlock{}, nil
+	})
+	require.Equal(t, config.OCCConfig{Enable: true, Workers: 7}, app.OCCConfig())
+
+	require.Error(t, app.UpdateOCCConfig(config.OCCConfig{Enable: true, Workers: 7, FallbackPolicy: "sequential"}))
+	cfg := config.OCCConfig{
+		Enable:          false,
+		Workers:         3,
+		MaxIncarnations: 2,
+		FallbackPolicy:  config.OCCFallbackPolicyRerun,
+		Telemetry:       config.OCCTelemetryBatched,
+	}
+	require.NoError(t, app.UpdateOCCConfig(cfg))
+
+	// the config is applied at the next block
+	require.True(t, app.OccEnabled())
+	require.Equal(t, 7, app.ConcurrencyWorkers())
+	_, err := app.FinalizeBlock(context.Background(), &abci.RequestFinalizeBlock{Height: 1})
+	require.NoError(t, err)
+	require.False(t, app.OccEnabled())
+	require.Equal(t, 3, app.ConcurrencyWorkers())
+	require.Equal(t, cfg, app.OCCConfig())
+	require.Len(t, app.occConfigOptions, 3)
+}
+
+func TestOCCConfigFromAppOpts(t *testing.T) {
+	appOpts := occAppOpts{
+		"chain-id":             "test-chain",
+		FlagOccEnabled:         false,
+		FlagOCCEnable:          true,
+		"occ.workers":          4,
+		"occ.fallback-policy":  config.OCCFallbackPolicyWait,
+		"occ.max-incarnations": 9,
+	}
+	app := NewBaseApp(t.Name(), defaultLogger(), dbm.NewMemDB(), nil, nil, appOpts)
+	require.True(t, app.OccEnabled())
+	require.Equal(t, 4, app.ConcurrencyWorkers())
+	require.Len(t, app.occConfigOptions, 2)
+
+	// options take precedence over the workers setting, as they did over the flag it replaces
+	app = NewBaseApp(t.Name(), defaultLogger(), dbm.NewMemDB(), nil, nil, appOpts, SetConcurrencyWorkers(6))
+	require.Equal(t, 6, app.ConcurrencyWorkers())
+}
+
+type occAppOpts map[string]interface{}
+
+func (opts occAppOpts) Get(key string) interface{} {
+	return opts[key]
+}
+
+func TestQueryOCCPrefixSuggestions(t *testing.T) {
+	app := newBaseApp(t.Name())
+	res, _ := app.Query(context.Background(), &abci.RequestQuery{Path: "/app/occ-prefix-suggestions"})
+	require.False(t, res.IsOK())
+
+	app = newBaseApp(t.Name(), SetOCCPrefixStats(tasks.NewPrefixStats(1)))
+	res, _ = app.Query(context.Background(), &abci.RequestQuery{Path: "/app/occ-prefix-suggestions/3"})
+	require.True(t, res.IsOK())
+	require.Equal(t, "[]", string(res.Value))
+
+	res, _ = app.Query(context.Background(), &abci.RequestQuery{Path: "/app/occ-prefix-suggestions/zero"})
+	require.False(t, res.IsOK())
+}
+
 // func TestGetMaximumBlockGas(t *testing.T) {
 // 	app := setupBaseApp(t)
 // 	app.InitChain(context.Background(), &abci.RequestInitChain{})
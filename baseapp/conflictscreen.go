@@ -0,0 +1,75 @@
+package baseapp
+
+import (
+	"fmt"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/tasks"
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ConflictScreen screens proposals in ProcessProposal for conflict graphs that would force near-serial OCC execution,
+// protecting validators from blocks crafted to defeat concurrent execution. The conflict graph is analyzed from the
+// writesets estimated by the EstimatedWritesetsFn (eg. see SetWritesetEstimators), without executing the txs. Execution
+// hints aren't used, even if DeliverTxBatch requests are built with them, since they're recorded from the CheckTx
+// history of each node: validators would disagree on whether a proposal is valid.
+type ConflictScreen struct {
+	// MaxSerialFraction is the serial fraction (see tasks.ConflictAnalysis) above which a proposal is screened out
+	MaxSerialFraction float64
+	// MinTxs is the number of txs below which proposals aren't screened, since small blocks execute quickly either way
+	MinTxs int
+	// FlagOnly has screened out proposals logged and counted rather than rejected, eg. to tune MaxSerialFraction
+	// against live traffic before enforcing it
+	FlagOnly bool
+}
+
+// ValidateBasic returns an error if the screen would reject every proposal it screens
+func (s ConflictScreen) ValidateBasic() error {
+	if s.MaxSerialFraction <= 0 || s.MaxSerialFraction > 1 {
+		return fmt.Errorf("conflict screen max serial fraction must be in (0, 1], got %v", s.MaxSerialFraction)
+	}
+	return nil
+}
+
+// screenProposal analyzes the conflict graph of the txs of a proposal, returning false if the proposal should be
+// rejected
+func (app *BaseApp) screenProposal(ctx sdk.Context, txs [][]byte) bool {
+	screen := app.conflictScreen
+	if screen == nil || len(txs) < screen.MinTxs {
+		return true
+	}
+	analysis := tasks.AnalyzeConflicts(app.screenEntries(ctx, txs))
+	telemetry.SetGauge(float32(analysis.SerialFraction()), "abci", "process_proposal", "serial_fraction")
+	if analysis.SerialFraction() <= screen.MaxSerialFraction {
+		return true
+	}
+	app.logger.Info(
+		"proposal screened out for conflicts",
+		"height", ctx.BlockHeight(),
+		"txs", analysis.Txs,
+		"depth", analysis.Depth,
+		"dependent", analysis.Dependent,
+		"flag-only", screen.FlagOnly,
+	)
+	if screen.FlagOnly {
+		telemetry.IncrCounter(1, "abci", "process_proposal", "conflict_screen", "flagged")
+		return true
+	}
+	telemetry.IncrCounter(1, "abci", "process_proposal", "conflict_screen", "rejected")
+	return false
+}
+
+// screenEntries returns the entries the conflict graph of a proposal is analyzed from, which only hold the estimated
+// writesets of the txs, since those depend on the txs and the state alone
+func (app *BaseApp) screenEntries(ctx sdk.Context, txs [][]byte) []*sdk.DeliverTxEntry {
+	entries := make([]*sdk.DeliverTxEntry, 0, len(txs))
+	for txIndex, txBytes := range txs {
+		entries = append(entries, &sdk.DeliverTxEntry{
+			Request:            abci.RequestDeliverTx{Tx: txBytes},
+			EstimatedWritesets: app.estimateWritesets(ctx, txIndex, txBytes),
+		})
+	}
+	return entries
+}
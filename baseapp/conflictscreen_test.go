@@ -0,0 +1,60 @@
+package baseapp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessProposalConflictScreen(t *testing.T) {
+	// every tx writes the same hot key, unless it's prefixed with "cold"
+	estimateOpt := SetEstimatedWritesetsFn(func(ctx sdk.Context, txIndex int, txBytes []byte) (sdk.MappedWritesets, error) {
+		key := "hot"
+		if string(txBytes[:4]) == "cold" {
+			key = string(txBytes)
+		}
+		return sdk.MappedWritesets{capKey1: {key: nil}}, nil
+	})
+	handlerOpt := func(app *BaseApp) {
+		app.SetProcessProposalHandler(func(sdk.Context, *abci.RequestProcessProposal) (*abci.ResponseProcessProposal, error) {
+			return &abci.ResponseProcessProposal{Status: abci.ResponseProcessProposal_ACCEPT}, nil
+		})
+	}
+	// execution hints recorded locally (eg. from CheckTx) would have every tx write the hot key, and are ignored, since
+	// they differ between validators
+	hintsOpt := SetExecutionHintsProvider(sdk.ExecutionHintsProviderFunc(func([]byte) (sdk.MappedWritesets, sdk.MappedReadsets, uint64) {
+		return sdk.MappedWritesets{capKey1: {"hot": nil}}, nil, 0
+	}))
+	hot := [][]byte{[]byte("hot0"), []byte("hot1"), []byte("hot2"), []byte("hot3")}
+	cold := [][]byte{[]byte("cold0"), []byte("cold1"), []byte("cold2"), []byte("cold3")}
+
+	for _, tc := range []struct {
+		name   string
+		screen ConflictScreen
+		txs    [][]byte
+		status abci.ResponseProcessProposal_ProposalStatus
+	}{
+		{"serial block is rejected", ConflictScreen{MaxSerialFraction: 0.5}, hot, abci.ResponseProcessProposal_REJECT},
+		{"parallel block is accepted", ConflictScreen{MaxSerialFraction: 0.5}, cold, abci.ResponseProcessProposal_ACCEPT},
+		{"small block isn't screened", ConflictScreen{MaxSerialFraction: 0.5, MinTxs: 5}, hot, abci.ResponseProcessProposal_ACCEPT},
+		{"flagged block is accepted", ConflictScreen{MaxSerialFraction: 0.5, FlagOnly: true}, hot, abci.ResponseProcessProposal_ACCEPT},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			app := setupBaseApp(t, estimateOpt, hintsOpt, handlerOpt, SetConflictScreen(tc.screen))
+			app.InitChain(context.Background(), &abci.RequestInitChain{})
+
+			resp, err := app.ProcessProposal(context.Background(), &abci.RequestProcessProposal{Height: 1, Txs: tc.txs})
+			require.NoError(t, err)
+			require.Equal(t, tc.status, resp.Status)
+		})
+	}
+}
+
+func TestSetConflictScreenInvalid(t *testing.T) {
+	require.Panics(t, func() { setupBaseApp(t, SetConflictScreen(ConflictScreen{})) })
+	require.Panics(t, func() { setupBaseApp(t, SetConflictScreen(ConflictScreen{MaxSerialFraction: 1.5})) })
+}
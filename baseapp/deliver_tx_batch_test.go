@@ -3,13 +3,20 @@ package baseapp
 import (
 	"context"
 	"fmt"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
 	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
 
 	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/cosmos/cosmos-sdk/store/cachemulti"
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/tasks"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 )
@@ -142,3 +149,323 @@ func TestDeliverTxBatch(t *testing.T) {
 		app.Commit(context.Background())
 	}
 }
+
+func TestDeliverTxBatchWorkerPool(t *testing.T) {
+	routerOpt := func(bapp *BaseApp) {
+		bapp.Router().AddRoute(sdk.NewRoute(routeMsgCounter, handlerKVStore(capKey1)))
+	}
+	pool := tasks.NewWorkerPool()
+	defer pool.Close()
+	app := setupBaseApp(t, routerOpt, SetOCCWorkerPool(pool))
+	app.InitChain(context.Background(), &abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+
+	// blocks above the small block threshold, which skips the workers
+	const txs = 10
+	for blockN := int64(0); blockN < 2; blockN++ {
+		header := tmproto.Header{Height: blockN + 1}
+		app.setDeliverState(header)
+		app.BeginBlock(app.deliverState.ctx, abci.RequestBeginBlock{Header: header})
+
+		var requests []*sdk.DeliverTxEntry
+		for i := int64(0); i < txs; i++ {
+			txBytes, err := codec.Marshal(newTxCounter(blockN*txs+i, i))
+			require.NoError(t, err)
+			requests = append(requests, &sdk.DeliverTxEntry{Request: abci.RequestDeliverTx{Tx: txBytes}})
+		}
+		responses := app.DeliverTxBatch(app.deliverState.ctx, sdk.DeliverTxBatchRequest{TxEntries: requests})
+		require.Len(t, responses.Results, txs)
+		for _, res := range responses.Results {
+			require.Equal(t, abci.CodeTypeOK, res.Response.Code)
+		}
+		// the execution workers and a validation worker per tx, started for the first block and reused by the second
+		require.Equal(t, app.concurrencyWorkers+txs, pool.Workers())
+
+		app.EndBlock(app.deliverState.ctx, abci.RequestEndBlock{})
+		app.SetDeliverStateToCommit()
+		app.Commit(context.Background())
+	}
+
+}
+
+func TestBuildDeliverTxBatchRequest(t *testing.T) {
+	estimateOpt := SetEstimatedWritesetsFn(func(ctx sdk.Context, txIndex int, txBytes []byte) (sdk.MappedWritesets, error) {
+		if txIndex == 1 {
+			return nil, fmt.Errorf("cannot estimate")
+		}
+		return sdk.MappedWritesets{
+			capKey1: {string(txBytes): nil},
+		}, nil
+	})
+	app := setupBaseApp(t, estimateOpt)
+	app.InitChain(context.Background(), &abci.RequestInitChain{})
+
+	txs := [][]byte{[]byte("tx0"), []byte("tx1"), []byte("tx2")}
+	req := app.BuildDeliverTxBatchRequest(app.deliverState.ctx, txs)
+	require.Len(t, req.TxEntries, len(txs))
+	for idx, entry := range req.TxEntries {
+		require.Equal(t, txs[idx], entry.Request.Tx)
+		if idx == 1 {
+			// txs that can't be estimated are included without estimates
+			require.Nil(t, entry.EstimatedWritesets)
+		} else {
+			require.Contains(t, entry.EstimatedWritesets[capKey1], string(txs[idx]))
+		}
+	}
+}
+
+func TestSetWritesetEstimators(t *testing.T) {
+	registry := sdk.NewWritesetEstimatorRegistry()
+	registry.Register(msgCounter{}, sdk.WritesetEstimatorFunc(func(ctx sdk.Context, msg sdk.Msg) (sdk.MappedWritesets, error) {
+		return sdk.MappedWritesets{
+			capKey1: {fmt.Sprintf("counter-%d", msg.(*msgCounter).Counter): nil},
+		}, nil
+	}))
+	app := setupBaseApp(t, SetWritesetEstimators(registry))
+	app.InitChain(context.Background(), &abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+	txBytes, err := codec.Marshal(newTxCounter(0, 1, 2))
+	require.NoError(t, err)
+
+	req := app.BuildDeliverTxBatchRequest(app.deliverState.ctx, [][]byte{txBytes, []byte("invalid")})
+	require.Len(t, req.TxEntries, 2)
+	require.Equal(t, sdk.MappedWritesets{
+		capKey1: {"counter-1": nil, "counter-2": nil},
+	}, req.TxEntries[0].EstimatedWritesets)
+	// txs that can't be decoded are included without estimates
+	require.Nil(t, req.TxEntries[1].EstimatedWritesets)
+}
+
+func TestCheckTxExecutionHints(t *testing.T) {
+	anteKey := []byte("ante-key")
+	anteOpt := func(bapp *BaseApp) {
+		bapp.SetAnteHandler(anteHandler(capKey1, anteKey))
+	}
+	routerOpt := func(bapp *BaseApp) {
+		bapp.Router().AddRoute(sdk.NewRoute(routeMsgCounter, handlerKVStore(capKey1)))
+	}
+	estimateOpt := SetEstimatedWritesetsFn(func(ctx sdk.Context, txIndex int, txBytes []byte) (sdk.MappedWritesets, error) {
+		return sdk.MappedWritesets{capKey2: {"estimated": nil}}, nil
+	})
+	hints := NewCheckTxExecutionHints(10)
+	app := setupBaseApp(t, anteOpt, routerOpt, estimateOpt, SetCheckTxExecutionHints(hints))
+	app.InitChain(context.Background(), &abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+	var txs [][]byte
+	for i := int64(0); i < 3; i++ {
+		txBytes, err := codec.Marshal(newTxCounter(i, i))
+		require.NoError(t, err)
+		txs = append(txs, txBytes)
+	}
+	// only the first two txs are checked
+	for _, txBytes := range txs[:2] {
+		res, err := app.CheckTx(context.Background(), &abci.RequestCheckTx{Tx: txBytes})
+		require.NoError(t, err)
+		require.True(t, res.IsOK(), fmt.Sprintf("%v", res))
+	}
+	require.Equal(t, 2, hints.Len())
+
+	// writes are still flushed to the check state
+	require.Equal(t, int64(2), getIntFromStore(app.checkState.ctx.KVStore(capKey1), anteKey))
+
+	writesets, readsets, gas := hints.ProvideExecutionHints(txs[1])
+	require.Contains(t, writesets[capKey1], string(anteKey))
+	require.Contains(t, readsets[capKey1], string(anteKey))
+	require.NotZero(t, gas)
+
+	req := app.BuildDeliverTxBatchRequest(app.deliverState.ctx, txs)
+	require.Len(t, req.TxEntries, len(txs))
+	for _, entry := range req.TxEntries[:2] {
+		require.Contains(t, entry.EstimatedWritesets[capKey1], string(anteKey))
+		require.NotEmpty(t, entry.EstimatedReadsets)
+		require.NotZero(t, entry.EstimatedGas)
+	}
+	// txs without hints fall back to the estimated writesets fn
+	require.Equal(t, sdk.MappedWritesets{capKey2: {"estimated": nil}}, req.TxEntries[2].EstimatedWritesets)
+	require.Nil(t, req.TxEntries[2].EstimatedReadsets)
+}
+
+func TestCheckTxExecutionHintsEviction(t *testing.T) {
+	hints := NewCheckTxExecutionHints(2)
+	for i := 0; i < 3; i++ {
+		hints.record([]byte(fmt.Sprintf("tx%d", i)), executionHints{
+			writesets: sdk.MappedWritesets{capKey1: {"key": nil}},
+			gas:       uint64(i + 1),
+		})
+	}
+	require.Equal(t, 2, hints.Len())
+
+	// the oldest tx is evicted first
+	writesets, _, _ := hints.ProvideExecutionHints([]byte("tx0"))
+	require.Nil(t, writesets)
+	_, _, gas := hints.ProvideExecutionHints([]byte("tx2"))
+	require.Equal(t, uint64(3), gas)
+
+	// recording a tx again replaces its hints
+	hints.record([]byte("tx1"), executionHints{gas: 10})
+	require.Equal(t, 2, hints.Len())
+	_, _, gas = hints.ProvideExecutionHints([]byte("tx1"))
+	require.Equal(t, uint64(10), gas)
+}
+
+// objectStore is a store that isn't a KV store, which counts how often its branches are written
+type objectStore struct {
+	writes *int
+}
+
+func (s objectStore) Write()                                             { *s.writes++ }
+func (s objectStore) GetEvents() []abci.Event                            { return nil }
+func (s objectStore) ResetEvents()                                       {}
+func (s objectStore) CacheWrap(storetypes.StoreKey) storetypes.CacheWrap { return s }
+func (s objectStore) CacheWrapWithTrace(storetypes.StoreKey, io.Writer, storetypes.TraceContext) storetypes.CacheWrap {
+	return s
+}
+func (s objectStore) CacheWrapWithListeners(storetypes.StoreKey, []storetypes.WriteListener) storetypes.CacheWrap {
+	return s
+}
+
+func TestExecutionHintsRecorderMixedMultiStore(t *testing.T) {
+	kvKey, objectKey := sdk.NewKVStoreKey("kv"), sdk.NewKVStoreKey("object")
+	db := dbm.NewMemDB()
+	writes := 0
+	ms := cachemulti.NewStore(db, map[storetypes.StoreKey]storetypes.CacheWrapper{
+		kvKey:     dbadapter.Store{DB: db},
+		objectKey: dbadapter.Store{DB: dbm.NewMemDB()},
+	}, map[string]storetypes.StoreKey{kvKey.Name(): kvKey, objectKey.Name(): objectKey}, nil, nil, nil)
+	ms.SetKVStores(func(k storetypes.StoreKey, _ storetypes.KVStore) storetypes.CacheWrap {
+		if k == objectKey {
+			return objectStore{writes: &writes}
+		}
+		return nil
+	})
+
+	ctx, recorder := newExecutionHintsRecorder(sdk.NewContext(ms, tmproto.Header{}, true, log.NewNopLogger()))
+	ctx.KVStore(kvKey).Set([]byte("key"), []byte("value"))
+	recorder.flush()
+
+	// the KV store is recorded, and the store that isn't is branched and written as usual
+	require.Equal(t, []byte("value"), ms.GetKVStore(kvKey).Get([]byte("key")))
+	require.Positive(t, writes)
+	hints := recorder.hints(0)
+	require.Equal(t, sdk.MappedWritesets{kvKey: {"key": []byte("value")}}, hints.writesets)
+}
+
+func TestDeliverTxBatchWritesetHash(t *testing.T) {
+	routerOpt := func(bapp *BaseApp) {
+		bapp.Router().AddRoute(sdk.NewRoute(routeMsgCounter, handlerKVStore(capKey1)))
+	}
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+	var requests []*sdk.DeliverTxEntry
+	for i := int64(0); i < 5; i++ {
+		txBytes, err := codec.Marshal(newTxCounter(i, i))
+		require.NoError(t, err)
+		requests = append(requests, &sdk.DeliverTxEntry{Request: abci.RequestDeliverTx{Tx: txBytes}})
+	}
+
+	deliverBatch := func(opts ...func(*BaseApp)) sdk.DeliverTxBatchResponse {
+		app := setupBaseApp(t, append(opts, routerOpt)...)
+		app.InitChain(context.Background(), &abci.RequestInitChain{})
+		header := tmproto.Header{Height: 1}
+		app.setDeliverState(header)
+		app.BeginBlock(app.deliverState.ctx, abci.RequestBeginBlock{Header: header})
+		return app.DeliverTxBatch(app.deliverState.ctx, sdk.DeliverTxBatchRequest{TxEntries: requests})
+	}
+
+	hashOpt := func(bapp *BaseApp) {
+		bapp.SetOCCSchedulerOptions(tasks.WithWritesetHashing())
+	}
+	require.Nil(t, deliverBatch().WritesetHash)
+	hash := deliverBatch(hashOpt).WritesetHash
+	require.Len(t, hash, 32)
+	require.Equal(t, hash, deliverBatch(hashOpt).WritesetHash)
+}
+
+func TestDeliverTxBatchFallback(t *testing.T) {
+	app := setupBaseApp(t, func(bapp *BaseApp) {
+		bapp.Router().AddRoute(sdk.NewRoute(routeMsgCounter, handlerKVStore(capKey1)))
+		bapp.SetOCCSchedulerOptions(tasks.WithWritesetHashing(), tasks.WithIncrementalCommit())
+	})
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+	const txs = 10
+	var requests []*sdk.DeliverTxEntry
+	for i := int64(0); i < txs; i++ {
+		txBytes, err := codec.Marshal(newTxCounter(i, i))
+		require.NoError(t, err)
+		requests = append(requests, &sdk.DeliverTxEntry{Request: abci.RequestDeliverTx{Tx: txBytes}})
+	}
+	app.InitChain(context.Background(), &abci.RequestInitChain{})
+	header := tmproto.Header{Height: 1}
+	app.setDeliverState(header)
+	app.BeginBlock(app.deliverState.ctx, abci.RequestBeginBlock{Header: header})
+
+	// the scheduler fails on a block whose context is done, which is executed sequentially instead
+	goCtx, cancel := context.WithCancel(app.deliverState.ctx.Context())
+	cancel()
+	res := app.DeliverTxBatch(app.deliverState.ctx.WithContext(goCtx), sdk.DeliverTxBatchRequest{TxEntries: requests})
+	require.Len(t, res.Results, txs)
+	for idx, result := range res.Results {
+		require.Equal(t, abci.CodeTypeOK, result.Response.Code)
+		requireAttribute(t, result.Response.Events, "shared-val", fmt.Sprintf("%d", idx+1))
+	}
+	require.Nil(t, res.WritesetHash)
+	// every tx is applied once
+	require.Equal(t, int64(txs), getIntFromStore(app.deliverState.ctx.KVStore(capKey1), []byte("shared")))
+}
+
+func TestDeliverTxs(t *testing.T) {
+	routerOpt := func(bapp *BaseApp) {
+		bapp.Router().AddRoute(sdk.NewRoute(routeMsgCounter, handlerKVStore(capKey1)))
+	}
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+	const txs = 10
+	var requests []*sdk.DeliverTxEntry
+	for i := int64(0); i < txs; i++ {
+		txBytes, err := codec.Marshal(newTxCounter(i, i))
+		require.NoError(t, err)
+		requests = append(requests, &sdk.DeliverTxEntry{Request: abci.RequestDeliverTx{Tx: txBytes}})
+	}
+
+	for _, tc := range []struct {
+		name        string
+		occEnabled  bool
+		interrupted bool
+	}{
+		{name: "sequential"},
+		{name: "occ", occEnabled: true},
+		// the scheduler fails on a block whose context is done, which is executed sequentially instead
+		{name: "fallback", occEnabled: true, interrupted: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			app := setupBaseApp(t, routerOpt, SetOccEnabled(tc.occEnabled))
+			app.InitChain(context.Background(), &abci.RequestInitChain{})
+			header := tmproto.Header{Height: 1}
+			app.setDeliverState(header)
+			app.BeginBlock(app.deliverState.ctx, abci.RequestBeginBlock{Header: header})
+
+			ctx := app.deliverState.ctx
+			if tc.interrupted {
+				goCtx, cancel := context.WithCancel(ctx.Context())
+				cancel()
+				ctx = ctx.WithContext(goCtx)
+			}
+			responses := app.DeliverTxs(ctx, requests)
+			require.Len(t, responses, txs)
+			for idx, res := range responses {
+				require.Equal(t, abci.CodeTypeOK, res.Code)
+				requireAttribute(t, res.Events, "tx-id", fmt.Sprintf("%d", idx))
+				requireAttribute(t, res.Events, "shared-val", fmt.Sprintf("%d", idx+1))
+			}
+			// every tx is applied once
+			require.Equal(t, int64(txs), getIntFromStore(app.deliverState.ctx.KVStore(capKey1), []byte("shared")))
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package baseapp
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// CheckTxExecutionHints records the writesets, readsets and gas used of txs as they are simulated by CheckTx, and
+// provides them as execution hints once the txs are delivered in a batch, so that chains get OCC hints without
+// writing estimators. It keeps the hints of at most capacity txs, evicting the oldest first.
+type CheckTxExecutionHints struct {
+	mtx      sync.Mutex
+	capacity int
+	hints    map[[sha256.Size]byte]executionHints
+	order    [][sha256.Size]byte
+}
+
+type executionHints struct {
+	writesets sdk.MappedWritesets
+	readsets  sdk.MappedReadsets
+	gas       uint64
+}
+
+var _ sdk.ExecutionHintsProvider = (*CheckTxExecutionHints)(nil)
+
+// NewCheckTxExecutionHints returns CheckTxExecutionHints that keep the hints of at most capacity txs
+func NewCheckTxExecutionHints(capacity int) *CheckTxExecutionHints {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &CheckTxExecutionHints{
+		capacity: capacity,
+		hints:    make(map[[sha256.Size]byte]executionHints, capacity),
+	}
+}
+
+// ProvideExecutionHints implements sdk.ExecutionHintsProvider
+func (h *CheckTxExecutionHints) ProvideExecutionHints(tx []byte) (sdk.MappedWritesets, sdk.MappedReadsets, uint64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	hints, ok := h.hints[sha256.Sum256(tx)]
+	if !ok {
+		return nil, nil, 0
+	}
+	return hints.writesets, hints.readsets, hints.gas
+}
+
+// Len returns the number of txs with recorded hints
+func (h *CheckTxExecutionHints) Len() int {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return len(h.hints)
+}
+
+// record sets the hints of a tx, replacing any previous hints without refreshing its age
+func (h *CheckTxExecutionHints) record(tx []byte, hints executionHints) {
+	hash := sha256.Sum256(tx)
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if _, ok := h.hints[hash]; !ok {
+		if len(h.order) >= h.capacity {
+			delete(h.hints, h.order[0])
+			h.order = h.order[1:]
+		}
+		h.order = append(h.order, hash)
+	}
+	h.hints[hash] = hints
+}
+
+// executionHintsRecorder wraps the KV stores of the context of a tx in version indexed stores, so that the keys the tx
+// reads and writes can be recorded (eg. as the execution hints of CheckTx, or to enforce strict writesets in
+// DeliverTx), and then flushes the writes to the original stores. Stores that aren't KV stores are branched as usual.
+type executionHintsRecorder struct {
+	ms      sdk.CacheMultiStore
+	parents map[sdk.StoreKey]sdk.CacheWrap
+	stores  map[sdk.StoreKey]*multiversion.Store
+	vis     map[sdk.StoreKey]*multiversion.VersionIndexedStore
+}
+
+// newExecutionHintsRecorder returns a context whose stores are recorded by the returned recorder
+func newExecutionHintsRecorder(ctx sdk.Context) (sdk.Context, *executionHintsRecorder) {
+	r := &executionHintsRecorder{
+		parents: make(map[sdk.StoreKey]sdk.CacheWrap),
+		stores:  make(map[sdk.StoreKey]*multiversion.Store),
+		vis:     make(map[sdk.StoreKey]*multiversion.VersionIndexedStore),
+	}
+	// a single tx never conflicts with itself, so nothing is ever sent on the abort channel
+	abortCh := make(chan occ.Abort, 1)
+	ms := ctx.MultiStore().CacheMultiStore()
+	ms.SetKVStores(func(k sdk.StoreKey, kvs sdk.KVStore) sdk.CacheWrap {
+		if kvs == nil {
+			return nil
+		}
+		r.parents[k] = kvs.(sdk.CacheWrap)
+		r.stores[k] = multiversion.NewMultiVersionStore(kvs)
+		r.vis[k] = r.stores[k].VersionedIndexedStore(0, 0, abortCh)
+		return r.vis[k]
+	})
+	r.ms = ms
+	return ctx.WithMultiStore(ms), r
+}
+
+// flush writes the writes recorded by the tx through to the stores of the original context
+func (r *executionHintsRecorder) flush() {
+	for k, vis := range r.vis {
+		vis.WriteToMultiVersionStore()
+		r.stores[k].WriteLatestToStore()
+		r.parents[k].Write()
+	}
+	// writes the branches of the stores that aren't KV stores, since the version indexed stores don't write anything
+	r.ms.Write()
+}
+
+// hints returns the writesets and readsets recorded by the tx, leaving out stores it didn't touch
+func (r *executionHintsRecorder) hints(gas uint64) executionHints {
+	hints := executionHints{
+		writesets: make(sdk.MappedWritesets),
+		readsets:  make(sdk.MappedReadsets),
+		gas:       gas,
+	}
+	for k, vis := range r.vis {
+		if writeset := vis.GetWriteset(); len(writeset) > 0 {
+			hints.writesets[k] = writeset
+		}
+		if readset := vis.GetReadset(); len(readset) > 0 {
+			hints.readsets[k] = readset
+		}
+	}
+	return hints
+}
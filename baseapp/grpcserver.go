@@ -12,6 +12,7 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
+	"github.com/cosmos/cosmos-sdk/tasks"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 	grpctypes "github.com/cosmos/cosmos-sdk/types/grpc"
@@ -95,4 +96,10 @@ func (app *BaseApp) RegisterGRPCServer(server gogogrpc.Server) {
 
 		server.RegisterService(newDesc, data.handler)
 	}
+
+	// the OCC inspector serves the live state of the block being processed rather than queries against committed
+	// state, so it bypasses the query interceptor
+	if app.occInspector != nil {
+		tasks.RegisterInspectorService(server, app.occInspector)
+	}
 }
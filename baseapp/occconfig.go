@@ -0,0 +1,93 @@
+package baseapp
+
+import (
+	"github.com/cosmos/cosmos-sdk/server/config"
+	"github.com/cosmos/cosmos-sdk/tasks"
+)
+
+// FlagOCCEnable enables OCC from the [occ] section of app.toml, taking precedence over FlagOccEnabled and the
+// SetOccEnabled option
+const FlagOCCEnable = "occ.enable"
+
+// OCCConfig returns the OCC configuration the app executes blocks with
+func (app *BaseApp) OCCConfig() config.OCCConfig {
+	cfg := app.occConfig
+	cfg.Enable = app.occEnabled
+	cfg.Workers = app.concurrencyWorkers
+	return cfg
+}
+
+// UpdateOCCConfig sets an OCC configuration, eg. from a changed [occ] section of app.toml, that is applied at the
+// start of the next block, so that no block is executed with a mix of configurations. It's safe to call concurrently
+// with block execution, and only the last configuration set before a block is applied.
+func (app *BaseApp) UpdateOCCConfig(cfg config.OCCConfig) error {
+	if err := cfg.ValidateBasic(); err != nil {
+		return err
+	}
+	app.occConfigLock.Lock()
+	defer app.occConfigLock.Unlock()
+	app.pendingOCCConfig = &cfg
+	return nil
+}
+
+// initOCCConfig sets the OCC configuration from the app options. The [occ] section of the options only overrides
+// settings that options of the app didn't set, like the flags it replaces, except for FlagOCCEnable.
+func (app *BaseApp) initOCCConfig(appOpts interface{ Get(string) interface{} }) {
+	cfg := config.GetOCCConfig(appOpts)
+	if app.concurrencyWorkers != 0 {
+		cfg.Workers = app.concurrencyWorkers
+	}
+	if appOpts.Get(FlagOCCEnable) == nil {
+		cfg.Enable = app.occEnabled
+	}
+	if err := cfg.ValidateBasic(); err != nil {
+		panic(err)
+	}
+	app.applyOCCConfig(cfg)
+}
+
+// applyPendingOCCConfig applies the configuration set by UpdateOCCConfig since the last block, if any
+func (app *BaseApp) applyPendingOCCConfig() {
+	app.occConfigLock.Lock()
+	cfg := app.pendingOCCConfig
+	app.pendingOCCConfig = nil
+	app.occConfigLock.Unlock()
+	if cfg == nil {
+		return
+	}
+	app.applyOCCConfig(*cfg)
+	app.logger.Info("applied OCC config", "enable", cfg.Enable, "workers", cfg.Workers,
+		"max-incarnations", cfg.MaxIncarnations, "fallback-policy", cfg.FallbackPolicy, "telemetry", cfg.Telemetry)
+}
+
+func (app *BaseApp) applyOCCConfig(cfg config.OCCConfig) {
+	app.occConfig = cfg
+	app.occEnabled = cfg.Enable
+	app.concurrencyWorkers = cfg.Workers
+	app.occConfigOptions = occConfigSchedulerOptions(cfg)
+}
+
+// occConfigSchedulerOptions returns the scheduler options of an OCC configuration, which are applied after the
+// options set with SetOCCSchedulerOptions, so that operators can override them
+func occConfigSchedulerOptions(cfg config.OCCConfig) []tasks.SchedulerOption {
+	var opts []tasks.SchedulerOption
+	if cfg.MaxIncarnations > 0 {
+		opts = append(opts, tasks.WithMaxIterations(cfg.MaxIncarnations))
+	}
+	switch cfg.FallbackPolicy {
+	case config.OCCFallbackPolicyWait:
+		opts = append(opts, tasks.WithConflictPolicy(tasks.WaitForDependenciesPolicy{}))
+	case config.OCCFallbackPolicyRerun:
+		opts = append(opts, tasks.WithConflictPolicy(tasks.RerunImmediatelyPolicy{}))
+	}
+	if cfg.Telemetry == config.OCCTelemetryBatched {
+		opts = append(opts, tasks.WithBatchedTelemetry())
+	}
+	return opts
+}
+
+// occOptions returns the scheduler options of the app followed by those of its OCC configuration
+func (app *BaseApp) occOptions() []tasks.SchedulerOption {
+	opts := app.occSchedulerOptions
+	return append(opts[:len(opts):len(opts)], app.occConfigOptions...)
+}
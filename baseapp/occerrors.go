@@ -0,0 +1,56 @@
+package baseapp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/armon/go-metrics"
+
+	"github.com/cosmos/cosmos-sdk/tasks"
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// occErrorClasses names the classes of scheduler errors in logs and metrics
+var occErrorClasses = []struct {
+	class error
+	name  string
+}{
+	{tasks.ErrEstimateAtCommit, "estimate_at_commit"},
+	{tasks.ErrMissingResponse, "missing_response"},
+	{tasks.ErrValidationLivelock, "validation_livelock"},
+	{tasks.ErrStoreInconsistency, "store_inconsistency"},
+}
+
+// occErrorClass returns the name of the class of an error returned by the scheduler, or "other" for errors that aren't
+// a tasks.SchedulerError, eg. an interrupted block
+func occErrorClass(err error) string {
+	for _, c := range occErrorClasses {
+		if errors.Is(err, c.class) {
+			return c.name
+		}
+	}
+	return "other"
+}
+
+// handleOCCError decides what to do with a block the OCC scheduler failed to process, by the class of the error. The
+// scheduler processes blocks against a branch, which is discarded when it fails, so the block can be executed
+// sequentially instead whenever the failure is the scheduler's own: an interrupted block, or an inconsistency of the
+// scheduler such as tasks.ErrEstimateAtCommit, tasks.ErrMissingResponse or tasks.ErrValidationLivelock. A
+// tasks.ErrStoreInconsistency is a failure of the stores themselves (or of their write listeners) while writing the
+// final state of the block, which sequential execution writes through too, so the node halts rather than committing the
+// block over stores in an unknown state.
+func (app *BaseApp) handleOCCError(ctx sdk.Context, err error) {
+	class := occErrorClass(err)
+	if errors.Is(err, tasks.ErrStoreInconsistency) {
+		app.logger.Error("occ scheduler found the stores inconsistent, halting", "height", ctx.BlockHeight(), "err", err)
+		panic(fmt.Errorf("occ scheduler failed at height %d: %w", ctx.BlockHeight(), err))
+	}
+	logArgs := []interface{}{"height", ctx.BlockHeight(), "class", class, "err", err}
+	var schedErr *tasks.SchedulerError
+	if errors.As(err, &schedErr) {
+		logArgs = append(logArgs, "tx", schedErr.TxIndex, "store", schedErr.StoreKey)
+	}
+	app.logger.Error("occ scheduler failed, executing block sequentially", logArgs...)
+	telemetry.IncrCounterWithLabels([]string{"baseapp", "occ_sequential_fallbacks"}, 1, []metrics.Label{telemetry.NewLabel("class", class)})
+}
@@ -0,0 +1,115 @@
+package baseapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/tasks"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// panickingWriteListener panics on the first write streamed to it
+type panickingWriteListener struct{}
+
+func (panickingWriteListener) OnWrite(storetypes.StoreKey, []byte, []byte, bool) error {
+	panic("listener failed")
+}
+
+func TestHandleOCCError(t *testing.T) {
+	app := setupBaseApp(t)
+	ctx := app.NewUncachedContext(false, tmproto.Header{Height: 3})
+
+	for _, tc := range []struct {
+		err   error
+		class string
+		halts bool
+	}{
+		{context.Canceled, "other", false},
+		{&tasks.SchedulerError{Class: tasks.ErrEstimateAtCommit, TxIndex: 1, StoreKey: "bank"}, "estimate_at_commit", false},
+		{&tasks.SchedulerError{Class: tasks.ErrMissingResponse, TxIndex: 2}, "missing_response", false},
+		{&tasks.SchedulerError{Class: tasks.ErrValidationLivelock, TxIndex: 3}, "validation_livelock", false},
+		{fmt.Errorf("wrapped: %w", &tasks.SchedulerError{Class: tasks.ErrStoreInconsistency, TxIndex: -1}), "store_inconsistency", true},
+	} {
+		require.Equal(t, tc.class, occErrorClass(tc.err))
+		if !tc.halts {
+			require.NotPanics(t, func() { app.handleOCCError(ctx, tc.err) }, tc.class)
+			continue
+		}
+		func() {
+			defer func() {
+				r := recover()
+				require.NotNil(t, r)
+				require.ErrorIs(t, r.(error), tasks.ErrStoreInconsistency)
+			}()
+			app.handleOCCError(ctx, tc.err)
+		}()
+	}
+}
+
+func TestDeliverTxsOCCErrors(t *testing.T) {
+	routerOpt := func(bapp *BaseApp) {
+		bapp.Router().AddRoute(sdk.NewRoute(routeMsgCounter, handlerKVStore(capKey1)))
+	}
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+	const txs = 5
+	var requests []*sdk.DeliverTxEntry
+	for i := int64(0); i < txs; i++ {
+		txBytes, err := codec.Marshal(newTxCounter(i, i))
+		require.NoError(t, err)
+		requests = append(requests, &sdk.DeliverTxEntry{Request: abci.RequestDeliverTx{Tx: txBytes}})
+	}
+	deliverers := map[string]func(app *BaseApp, ctx sdk.Context) int{
+		"DeliverTxs": func(app *BaseApp, ctx sdk.Context) int {
+			return len(app.DeliverTxs(ctx, requests))
+		},
+		"DeliverTxBatch": func(app *BaseApp, ctx sdk.Context) int {
+			return len(app.DeliverTxBatch(ctx, sdk.DeliverTxBatchRequest{TxEntries: requests}).Results)
+		},
+	}
+
+	for name, deliver := range deliverers {
+		t.Run(name, func(t *testing.T) {
+			setup := func(opts ...func(*BaseApp)) *BaseApp {
+				app := setupBaseApp(t, append(opts, routerOpt, SetOccEnabled(true))...)
+				app.InitChain(context.Background(), &abci.RequestInitChain{})
+				header := tmproto.Header{Height: 1}
+				app.setDeliverState(header)
+				app.BeginBlock(app.deliverState.ctx, abci.RequestBeginBlock{Header: header})
+				return app
+			}
+
+			// an interrupted block is executed sequentially
+			app := setup()
+			goCtx, cancel := context.WithCancel(app.deliverState.ctx.Context())
+			cancel()
+			require.Equal(t, txs, deliver(app, app.deliverState.ctx.WithContext(goCtx)))
+			require.Equal(t, int64(txs), getIntFromStore(app.deliverState.ctx.KVStore(capKey1), []byte("shared")))
+
+			// stores that fail to write the final state of the block halt the node
+			app = setup(func(bapp *BaseApp) {
+				bapp.SetOCCSchedulerOptions(tasks.WithWriteListeners(map[sdk.StoreKey][]storetypes.WriteListener{
+					capKey1: {panickingWriteListener{}},
+				}))
+			})
+			func() {
+				defer func() {
+					r := recover()
+					require.NotNil(t, r)
+					err, ok := r.(error)
+					require.True(t, ok)
+					require.True(t, errors.Is(err, tasks.ErrStoreInconsistency))
+				}()
+				deliver(app, app.deliverState.ctx)
+			}()
+		})
+	}
+}
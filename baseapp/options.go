@@ -9,6 +9,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/codec/types"
 	"github.com/cosmos/cosmos-sdk/snapshots"
 	"github.com/cosmos/cosmos-sdk/store"
+	"github.com/cosmos/cosmos-sdk/tasks"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 )
 
@@ -90,6 +91,82 @@ func SetOccEnabled(occEnabled bool) func(*BaseApp) {
 	return func(app *BaseApp) { app.SetOccEnabled(occEnabled) }
 }
 
+// EnableOCC returns an option that enables OCC for DeliverTxBatch with the given number of workers, and
+// optionally configures the scheduler that processes each batch.
+func EnableOCC(workers int, opts ...tasks.SchedulerOption) func(*BaseApp) {
+	return func(app *BaseApp) {
+		app.SetOccEnabled(true)
+		app.SetConcurrencyWorkers(workers)
+		app.SetOCCSchedulerOptions(opts...)
+	}
+}
+
+// SetWritesetEstimators returns an option that estimates tx writesets for DeliverTxBatch requests from the
+// registered estimators of their msgs.
+func SetWritesetEstimators(registry *sdk.WritesetEstimatorRegistry) func(*BaseApp) {
+	return func(app *BaseApp) { app.SetWritesetEstimators(registry) }
+}
+
+// SetOCCPrefixStats returns an option that collects key prefix statistics from every DeliverTxBatch, which are
+// surfaced as exemption and deferred write suggestions through the app/occ-prefix-suggestions query.
+func SetOCCPrefixStats(stats *tasks.PrefixStats) func(*BaseApp) {
+	return func(app *BaseApp) { app.SetOCCPrefixStats(stats) }
+}
+
+// SetOCCWorkerTuner returns an option that has every DeliverTxBatch take its number of workers from the tuner instead
+// of the configured concurrency workers. The tuner's limits can be changed at runtime to reconfigure it.
+func SetOCCWorkerTuner(tuner *tasks.WorkerTuner) func(*BaseApp) {
+	return func(app *BaseApp) { app.SetOCCWorkerTuner(tuner) }
+}
+
+// SetOCCWorkerPool returns an option that has every DeliverTxBatch borrow its workers from a long-lived pool, rather
+// than starting goroutines for each block. The pool is closed along with the app.
+func SetOCCWorkerPool(pool *tasks.WorkerPool) func(*BaseApp) {
+	return func(app *BaseApp) { app.SetOCCWorkerPool(pool) }
+}
+
+// SetOCCEstimateCache returns an option that carries the writesets txs were learned to write in every DeliverTxBatch
+// over as estimates to later batches re-proposing them, eg. after their block failed to be finalized.
+func SetOCCEstimateCache(cache *tasks.EstimateCache) func(*BaseApp) {
+	return func(app *BaseApp) { app.SetOCCEstimateCache(cache) }
+}
+
+// SetOCCInvariantChecks returns an option that asserts module invariants once the writes of every DeliverTxBatch are
+// flushed, rather than mid-block where the state seen by txs isn't final.
+func SetOCCInvariantChecks(checks *tasks.InvariantChecks) func(*BaseApp) {
+	return func(app *BaseApp) { app.SetOCCInvariantChecks(checks) }
+}
+
+// SetOCCInspector returns an option that exposes the multiversion state of the DeliverTxBatch being processed through
+// the inspector, which is also served by the app's gRPC server for operators to inspect a stuck or slow block.
+func SetOCCInspector(inspector *tasks.Inspector) func(*BaseApp) {
+	return func(app *BaseApp) { app.SetOCCInspector(inspector) }
+}
+
+// SetConflictScreen returns an option that screens proposals in ProcessProposal for conflict graphs that would force
+// near-serial OCC execution, see ConflictScreen.
+func SetConflictScreen(screen ConflictScreen) func(*BaseApp) {
+	return func(app *BaseApp) { app.SetConflictScreen(screen) }
+}
+
+// SetExecutionHintsProvider returns an option that sets the provider of execution hints used when building
+// DeliverTxBatch requests from raw txs.
+func SetExecutionHintsProvider(provider sdk.ExecutionHintsProvider) func(*BaseApp) {
+	return func(app *BaseApp) { app.SetExecutionHintsProvider(provider) }
+}
+
+// SetCheckTxExecutionHints returns an option that records execution hints from CheckTx and provides them to
+// DeliverTxBatch requests.
+func SetCheckTxExecutionHints(hints *CheckTxExecutionHints) func(*BaseApp) {
+	return func(app *BaseApp) { app.SetCheckTxExecutionHints(hints) }
+}
+
+// SetEstimatedWritesetsFn returns an option that sets the function used to estimate tx writesets when building
+// DeliverTxBatch requests from raw txs.
+func SetEstimatedWritesetsFn(fn EstimatedWritesetsFn) func(*BaseApp) {
+	return func(app *BaseApp) { app.SetEstimatedWritesetsFn(fn) }
+}
+
 // SetSnapshotKeepRecent sets the recent snapshots to keep.
 func SetSnapshotKeepRecent(keepRecent uint32) func(*BaseApp) {
 	return func(app *BaseApp) { app.SetSnapshotKeepRecent(keepRecent) }
@@ -312,6 +389,106 @@ func (app *BaseApp) SetOccEnabled(occEnabled bool) {
 	app.occEnabled = occEnabled
 }
 
+func (app *BaseApp) SetOCCSchedulerOptions(opts ...tasks.SchedulerOption) {
+	if app.sealed {
+		panic("SetOCCSchedulerOptions() on sealed BaseApp")
+	}
+	app.occSchedulerOptions = opts
+}
+
+func (app *BaseApp) SetOCCPrefixStats(stats *tasks.PrefixStats) {
+	if app.sealed {
+		panic("SetOCCPrefixStats() on sealed BaseApp")
+	}
+	app.occPrefixStats = stats
+}
+
+func (app *BaseApp) SetOCCWorkerTuner(tuner *tasks.WorkerTuner) {
+	if app.sealed {
+		panic("SetOCCWorkerTuner() on sealed BaseApp")
+	}
+	app.occWorkerTuner = tuner
+}
+
+func (app *BaseApp) SetOCCWorkerPool(pool *tasks.WorkerPool) {
+	if app.sealed {
+		panic("SetOCCWorkerPool() on sealed BaseApp")
+	}
+	app.occWorkerPool = pool
+}
+
+func (app *BaseApp) SetOCCEstimateCache(cache *tasks.EstimateCache) {
+	if app.sealed {
+		panic("SetOCCEstimateCache() on sealed BaseApp")
+	}
+	app.occEstimateCache = cache
+}
+
+// SetOCCInvariantChecks sets the module invariants asserted once the writes of every DeliverTxBatch are flushed
+func (app *BaseApp) SetOCCInvariantChecks(checks *tasks.InvariantChecks) {
+	if app.sealed {
+		panic("SetOCCInvariantChecks() on sealed BaseApp")
+	}
+	app.occInvariantChecks = checks
+}
+
+// SetOCCInspector sets the inspector of the multiversion state of the DeliverTxBatch being processed
+func (app *BaseApp) SetOCCInspector(inspector *tasks.Inspector) {
+	if app.sealed {
+		panic("SetOCCInspector() on sealed BaseApp")
+	}
+	app.occInspector = inspector
+}
+
+// SetConflictScreen sets the screen proposals are checked against in ProcessProposal before the process proposal
+// handler runs. It panics if the screen is invalid.
+func (app *BaseApp) SetConflictScreen(screen ConflictScreen) {
+	if app.sealed {
+		panic("SetConflictScreen() on sealed BaseApp")
+	}
+	if err := screen.ValidateBasic(); err != nil {
+		panic(err)
+	}
+	app.conflictScreen = &screen
+}
+
+// SetWritesetEstimators sets the EstimatedWritesetsFn to decode each tx and merge the estimated writesets of its msgs
+func (app *BaseApp) SetWritesetEstimators(registry *sdk.WritesetEstimatorRegistry) {
+	app.SetEstimatedWritesetsFn(func(ctx sdk.Context, _ int, txBytes []byte) (sdk.MappedWritesets, error) {
+		tx, err := app.txDecoder(txBytes)
+		if err != nil {
+			return nil, err
+		}
+		return registry.EstimateTxWritesets(ctx, tx)
+	})
+}
+
+func (app *BaseApp) SetEstimatedWritesetsFn(fn EstimatedWritesetsFn) {
+	if app.sealed {
+		panic("SetEstimatedWritesetsFn() on sealed BaseApp")
+	}
+	app.estimatedWritesetsFn = fn
+}
+
+// SetExecutionHintsProvider sets the provider of execution hints (eg. a simulating mempool) used when building
+// DeliverTxBatch requests from raw txs. Txs it has no hints for fall back to the EstimatedWritesetsFn.
+func (app *BaseApp) SetExecutionHintsProvider(provider sdk.ExecutionHintsProvider) {
+	if app.sealed {
+		panic("SetExecutionHintsProvider() on sealed BaseApp")
+	}
+	app.executionHintsProvider = provider
+}
+
+// SetCheckTxExecutionHints records the writesets, readsets and gas of new txs in CheckTx into hints, and uses them as
+// the execution hints provider.
+func (app *BaseApp) SetCheckTxExecutionHints(hints *CheckTxExecutionHints) {
+	if app.sealed {
+		panic("SetCheckTxExecutionHints() on sealed BaseApp")
+	}
+	app.checkTxExecutionHints = hints
+	app.executionHintsProvider = hints
+}
+
 // SetSnapshotKeepRecent sets the number of recent snapshots to keep.
 func (app *BaseApp) SetSnapshotKeepRecent(snapshotKeepRecent uint32) {
 	if app.sealed {
@@ -22,8 +22,17 @@ var (
 	ParamStoreKeySynchronyParams = []byte("SynchronyParams")
 	ParamStoreKeyTimeoutParams   = []byte("TimeoutParams")
 	ParamStoreKeyABCIParams      = []byte("ABCIParams")
+	ParamStoreKeyOCCParams       = []byte("OCCParams")
 )
 
+// OCCParams are the consensus parameters of the execution of txs that bear on their results, so unlike the options of
+// the OCC scheduler they're enforced by DeliverTx, whether a block is executed by the scheduler or sequentially.
+type OCCParams struct {
+	// StrictWritesets fails a tx writing a key outside of the writesets declared for it by the EstimatedWritesetsFn
+	// with ErrOCCUndeclaredWrite, discarding all of its writes. Txs without declared writesets aren't restricted.
+	StrictWritesets bool `json:"strict_writesets"`
+}
+
 // ParamStore defines the interface the parameter store used by the BaseApp must
 // fulfill.
 type ParamStore interface {
@@ -157,6 +166,16 @@ func ValidateABCIParams(i interface{}) error {
 	return nil
 }
 
+// ValidateOCCParams defines a stateless validation on OCCParams. This function is called whenever the parameters are
+// updated or stored.
+func ValidateOCCParams(i interface{}) error {
+	if _, ok := i.(OCCParams); !ok {
+		return fmt.Errorf("invalid parameter type: %T", i)
+	}
+
+	return nil
+}
+
 func validateDurationPointer(i *time.Duration, name string) error {
 	if i == nil || *i < 0 {
 		return fmt.Errorf("invalid %s", name)
@@ -67,15 +67,15 @@ func newOutOfGasRecoveryMiddleware(gasWanted uint64, ctx sdk.Context, next recov
 // newOCCAbortRecoveryMiddleware creates a standard OCC Abort recovery middleware for app.runTx method.
 func newOCCAbortRecoveryMiddleware(next recoveryMiddleware) recoveryMiddleware {
 	handler := func(recoveryObj interface{}) error {
-		abort, ok := recoveryObj.(scheduler.Abort)
+		abort, ok := scheduler.RecoveredAbort(recoveryObj)
 		if !ok {
 			return nil
 		}
 
 		return sdkerrors.Wrap(
 			sdkerrors.ErrOCCAbort, fmt.Sprintf(
-				"occ abort occurred with dependent index %d and error: %v",
-				abort.DependentTxIdx, abort.Err,
+				"occ abort occurred with dependent index %d and error: %v (reason: %s, store: %s, key: %X)",
+				abort.DependentTxIdx, abort.Err, abort.Reason, abort.StoreKey, abort.Key,
 			),
 		)
 	}
@@ -83,6 +83,34 @@ func newOCCAbortRecoveryMiddleware(next recoveryMiddleware) recoveryMiddleware {
 	return newRecoveryMiddleware(handler, next)
 }
 
+// newOCCLimitRecoveryMiddleware creates a standard OCC resource limit recovery middleware for app.runTx method.
+func newOCCLimitRecoveryMiddleware(next recoveryMiddleware) recoveryMiddleware {
+	handler := func(recoveryObj interface{}) error {
+		limit, ok := recoveryObj.(scheduler.LimitExceeded)
+		if !ok {
+			return nil
+		}
+
+		return sdkerrors.Wrap(sdkerrors.ErrOCCLimitExceeded, limit.Error())
+	}
+
+	return newRecoveryMiddleware(handler, next)
+}
+
+// newOCCMemoryLimitRecoveryMiddleware creates a standard OCC memory limit recovery middleware for app.runTx method.
+func newOCCMemoryLimitRecoveryMiddleware(next recoveryMiddleware) recoveryMiddleware {
+	handler := func(recoveryObj interface{}) error {
+		limit, ok := recoveryObj.(scheduler.MemoryLimitExceeded)
+		if !ok {
+			return nil
+		}
+
+		return sdkerrors.Wrap(sdkerrors.ErrOCCMemoryLimitExceeded, limit.Error())
+	}
+
+	return newRecoveryMiddleware(handler, next)
+}
+
 // newDefaultRecoveryMiddleware creates a default (last in chain) recovery middleware for app.runTx method.
 func newDefaultRecoveryMiddleware() recoveryMiddleware {
 	handler := func(recoveryObj interface{}) error {
@@ -5,6 +5,9 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
 )
 
 // Test that recovery chain produces expected error at specific middleware layer
@@ -62,3 +65,45 @@ func TestRecoveryChain(t *testing.T) {
 		require.Nil(t, receivedErr)
 	}
 }
+
+func TestOCCLimitRecoveryMiddleware(t *testing.T) {
+	mw := newOCCLimitRecoveryMiddleware(newDefaultRecoveryMiddleware())
+
+	err := processRecovery(scheduler.LimitExceeded{Descriptor: "store operations", Limit: 10}, mw)
+	require.True(t, sdkerrors.ErrOCCLimitExceeded.Is(err))
+	require.Contains(t, err.Error(), "store operations limit of 10 exceeded")
+
+	// anything else is passed down the chain
+	err = processRecovery("other", mw)
+	require.True(t, sdkerrors.ErrPanic.Is(err))
+}
+
+func TestOCCMemoryLimitRecoveryMiddleware(t *testing.T) {
+	mw := newOCCMemoryLimitRecoveryMiddleware(newDefaultRecoveryMiddleware())
+
+	err := processRecovery(scheduler.MemoryLimitExceeded{Limit: 1024, Used: 1030}, mw)
+	require.True(t, sdkerrors.ErrOCCMemoryLimitExceeded.Is(err))
+	require.Contains(t, err.Error(), "memory limit of 1024 bytes exceeded with 1030 bytes")
+
+	// anything else is passed down the chain
+	err = processRecovery("other", mw)
+	require.True(t, sdkerrors.ErrPanic.Is(err))
+}
+
+func TestOCCAbortRecoveryMiddleware(t *testing.T) {
+	mw := newOCCAbortRecoveryMiddleware(newDefaultRecoveryMiddleware())
+
+	abort := scheduler.NewEstimateAbort(3, "bank", []byte{0xab})
+	err := processRecovery(abort, mw)
+	require.True(t, sdkerrors.ErrOCCAbort.Is(err))
+	require.True(t, scheduler.IsOCCAbort(err))
+
+	// aborts wrapped in errors by a recovering handler are recovered as aborts too
+	err = processRecovery(fmt.Errorf("handler failed: %w", abort), mw)
+	require.True(t, sdkerrors.ErrOCCAbort.Is(err))
+
+	// anything else is passed down the chain
+	err = processRecovery("other", mw)
+	require.True(t, sdkerrors.ErrPanic.Is(err))
+	require.False(t, scheduler.IsOCCAbort(err))
+}
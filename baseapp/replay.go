@@ -0,0 +1,121 @@
+package baseapp
+
+import (
+	"errors"
+	"fmt"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/tasks"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// BlockLoader loads committed blocks by height, eg. from a node's block store
+type BlockLoader interface {
+	LoadBlock(height int64) *tmtypes.Block
+}
+
+// ReplayResult is the outcome of replaying the txs of a single height under OCC
+type ReplayResult struct {
+	Height int64
+	// Metrics are the scheduler statistics of the OCC execution
+	Metrics tasks.SchedulerMetrics
+	// WritesetHash is the hash of the final writesets under OCC, and SequentialWritesetHash under sequential execution
+	WritesetHash           []byte
+	SequentialWritesetHash []byte
+	// Err is a tasks.ErrWritesetHashMismatch error if OCC diverged from sequential execution, or the error of the OCC
+	// execution
+	Err error
+}
+
+// ReplayOCC re-executes the txs of the blocks in [fromHeight, toHeight] through the OCC scheduler, each against a
+// read-only branch of the state at the end of the previous height, reporting the conflict statistics of every height.
+// Since the app hash can't be recomputed from a read-only branch, every height is also executed sequentially and the
+// final writesets are compared, which catches OCC-induced divergence from the state the app hash commits to. Begin and
+// end blockers aren't run, so it's meant for validating OCC (eg. from a node debug command) before enabling it, not
+// for reproducing the chain's state. Nothing is ever written to the app's state.
+func (app *BaseApp) ReplayOCC(blocks BlockLoader, fromHeight, toHeight int64, opts ...tasks.SchedulerOption) ([]ReplayResult, error) {
+	if len(app.abciListeners) > 0 {
+		return nil, errors.New("cannot replay blocks with ABCI listeners registered")
+	}
+	results := make([]ReplayResult, 0, toHeight-fromHeight+1)
+	for height := fromHeight; height <= toHeight; height++ {
+		block := blocks.LoadBlock(height)
+		if block == nil {
+			return results, fmt.Errorf("block at height %d not found", height)
+		}
+		result, err := app.replayBlock(block, opts)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// replayBlock replays the txs of a block under OCC and sequentially, returning an error if either couldn't run
+func (app *BaseApp) replayBlock(block *tmtypes.Block, opts []tasks.SchedulerOption) (ReplayResult, error) {
+	result := ReplayResult{Height: block.Height}
+	txs := make([][]byte, 0, len(block.Txs))
+	for _, tx := range block.Txs {
+		txs = append(txs, tx)
+	}
+
+	ctx, err := app.replayContext(block)
+	if err != nil {
+		return result, err
+	}
+	req := app.BuildDeliverTxBatchRequest(ctx, txs)
+	schedulerOpts := append(append(app.occOptions(), opts...), tasks.WithWritesetHashing())
+	scheduler := tasks.NewScheduler(app.concurrencyWorkers, app.TracingInfo, app.DeliverTx, schedulerOpts...)
+	_, result.Err = scheduler.ProcessAll(ctx, req.TxEntries)
+	result.Metrics = scheduler.Metrics()
+	result.WritesetHash = scheduler.WritesetHash()
+
+	ctx, err = app.replayContext(block)
+	if err != nil {
+		return result, err
+	}
+	result.SequentialWritesetHash = app.sequentialWritesetHash(ctx, txs)
+	if result.Err == nil {
+		result.Err = tasks.CheckWritesetHash(block.Height, result.SequentialWritesetHash, result.WritesetHash)
+	}
+	return result, nil
+}
+
+// replayContext returns a context for delivering the txs of a block on a read-only branch of the state at the end of
+// the previous height
+func (app *BaseApp) replayContext(block *tmtypes.Block) (sdk.Context, error) {
+	ms, err := app.cms.CacheMultiStoreWithVersion(block.Height - 1)
+	if err != nil {
+		return sdk.Context{}, fmt.Errorf("failed to load state at height %d: %w", block.Height-1, err)
+	}
+	ctx := sdk.NewContext(ms, *block.Header.ToProto(), false, app.logger)
+	return ctx.WithConsensusParams(app.GetConsensusParams(ctx)), nil
+}
+
+// sequentialWritesetHash delivers the txs one at a time in order, each through version indexed stores at its own
+// index so that its writes are visible to the next tx, and returns the hash of the final writesets
+func (app *BaseApp) sequentialWritesetHash(ctx sdk.Context, txs [][]byte) []byte {
+	stores := make(map[sdk.StoreKey]multiversion.MultiVersionStore)
+	for _, key := range ctx.MultiStore().StoreKeys() {
+		stores[key] = multiversion.NewMultiVersionStore(ctx.MultiStore().GetKVStore(key))
+	}
+	// sequential txs never read estimates, so nothing is ever sent on the abort channel
+	abortCh := make(chan occ.Abort, len(stores))
+	for txIndex, tx := range txs {
+		vis := make(map[sdk.StoreKey]*multiversion.VersionIndexedStore, len(stores))
+		ms := ctx.MultiStore().CacheMultiStore().SetKVStores(func(k sdk.StoreKey, _ sdk.KVStore) sdk.CacheWrap {
+			vis[k] = stores[k].(*multiversion.Store).VersionedIndexedStore(txIndex, 0, abortCh)
+			return vis[k]
+		})
+		app.DeliverTx(ctx.WithMultiStore(ms).WithTxIndex(txIndex), abci.RequestDeliverTx{Tx: tx})
+		for _, store := range vis {
+			store.WriteToMultiVersionStore()
+		}
+	}
+	return tasks.HashWritesets(stores)
+}
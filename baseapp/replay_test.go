@@ -0,0 +1,80 @@
+package baseapp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+type testBlockLoader map[int64]*tmtypes.Block
+
+func (l testBlockLoader) LoadBlock(height int64) *tmtypes.Block {
+	return l[height]
+}
+
+func TestReplayOCC(t *testing.T) {
+	anteKey := []byte("ante-key")
+	anteOpt := func(bapp *BaseApp) {
+		bapp.SetAnteHandler(anteHandler(capKey1, anteKey))
+	}
+	routerOpt := func(bapp *BaseApp) {
+		bapp.Router().AddRoute(sdk.NewRoute(routeMsgCounter, handlerKVStore(capKey1)))
+	}
+	app := setupBaseApp(t, anteOpt, routerOpt)
+	app.InitChain(context.Background(), &abci.RequestInitChain{})
+
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+	blocks := testBlockLoader{}
+	counter := int64(0)
+	for height := int64(1); height <= 3; height++ {
+		block := &tmtypes.Block{Header: tmtypes.Header{Height: height}}
+		var entries []*sdk.DeliverTxEntry
+		for i := int64(0); i < height*5; i++ {
+			txBytes, err := codec.Marshal(newTxCounter(counter, counter))
+			require.NoError(t, err)
+			counter++
+			block.Txs = append(block.Txs, txBytes)
+			entries = append(entries, &sdk.DeliverTxEntry{Request: abci.RequestDeliverTx{Tx: txBytes}})
+		}
+		blocks[height] = block
+
+		header := tmproto.Header{Height: height}
+		app.setDeliverState(header)
+		app.BeginBlock(app.deliverState.ctx, abci.RequestBeginBlock{Header: header})
+		app.DeliverTxBatch(app.deliverState.ctx, sdk.DeliverTxBatchRequest{TxEntries: entries})
+		app.EndBlock(app.deliverState.ctx, abci.RequestEndBlock{})
+		app.SetDeliverStateToCommit()
+		app.Commit(context.Background())
+	}
+	lastCommitID := app.cms.LastCommitID()
+
+	results, err := app.ReplayOCC(blocks, 2, 3)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	for i, result := range results {
+		height := int64(i) + 2
+		require.Equal(t, height, result.Height)
+		require.NoError(t, result.Err)
+		require.Equal(t, int(height*5), result.Metrics.Txs)
+		require.Len(t, result.WritesetHash, 32)
+		require.Equal(t, result.SequentialWritesetHash, result.WritesetHash)
+	}
+	// the heights wrote different state
+	require.NotEqual(t, results[0].WritesetHash, results[1].WritesetHash)
+	// replaying never writes to the app's state
+	require.Equal(t, lastCommitID, app.cms.LastCommitID())
+	require.Equal(t, int64(30), getIntFromStore(app.cms.GetKVStore(capKey1), anteKey))
+
+	// replay stops at missing blocks
+	results, err = app.ReplayOCC(blocks, 3, 4)
+	require.ErrorContains(t, err, "height 4")
+	require.Len(t, results, 1)
+}
@@ -0,0 +1,30 @@
+package baseapp
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// declaredWritesets returns the writesets a tx is restricted to by the StrictWritesets consensus parameter, which are
+// the ones estimated by the EstimatedWritesetsFn, or nil if the parameter is disabled or the tx declares none. The
+// parameter is read with a gas meter of its own, since the context of the block may be shared by concurrent txs.
+func (app *BaseApp) declaredWritesets(ctx sdk.Context, txBytes []byte) sdk.MappedWritesets {
+	ctx = ctx.WithGasMeter(sdk.NewInfiniteGasMeter())
+	if !app.GetOCCParams(ctx).StrictWritesets {
+		return nil
+	}
+	return app.estimateWritesets(ctx, ctx.TxIndex(), txBytes)
+}
+
+// undeclaredWrite returns the first key the tx wrote outside of its declared writesets, or nil if it kept to them.
+// Stores the tx didn't declare writes to mustn't be written at all.
+func (r *executionHintsRecorder) undeclaredWrite(declared sdk.MappedWritesets) *occ.UndeclaredWrite {
+	for k, vis := range r.vis {
+		for key := range vis.GetWriteset() {
+			if _, ok := declared[k][key]; !ok {
+				return &occ.UndeclaredWrite{StoreKey: k.Name(), Key: []byte(key)}
+			}
+		}
+	}
+	return nil
+}
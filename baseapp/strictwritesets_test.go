@@ -0,0 +1,88 @@
+package baseapp
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+func TestDeliverTxsStrictWritesets(t *testing.T) {
+	const txs = 6
+	const rogueTx = 2
+	const undeclaredTx = 4
+	routerOpt := func(bapp *BaseApp) {
+		bapp.Router().AddRoute(sdk.NewRoute(routeMsgCounter, handlerKVStore(capKey1)))
+	}
+	// every tx writes its own key and the shared key, but the rogue tx only declares the shared key, and the
+	// undeclared tx declares nothing
+	estimateOpt := SetEstimatedWritesetsFn(func(ctx sdk.Context, txIndex int, txBytes []byte) (sdk.MappedWritesets, error) {
+		switch txIndex {
+		case rogueTx:
+			return sdk.MappedWritesets{capKey1: {"shared": nil}}, nil
+		case undeclaredTx:
+			return nil, fmt.Errorf("cannot estimate")
+		}
+		return sdk.MappedWritesets{capKey1: {"shared": nil, fmt.Sprintf("tx-%d", txIndex): nil}}, nil
+	})
+	codec := codec.NewLegacyAmino()
+	registerTestCodec(codec)
+	var requests []*sdk.DeliverTxEntry
+	for i := int64(0); i < txs; i++ {
+		txBytes, err := codec.Marshal(newTxCounter(i, i))
+		require.NoError(t, err)
+		requests = append(requests, &sdk.DeliverTxEntry{Request: abci.RequestDeliverTx{Tx: txBytes}})
+	}
+
+	for _, tc := range []struct {
+		name       string
+		occEnabled bool
+		strict     bool
+	}{
+		{name: "sequential"},
+		{name: "occ", occEnabled: true},
+		{name: "strict sequential", strict: true},
+		{name: "strict occ", occEnabled: true, strict: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			app := setupBaseApp(t, routerOpt, estimateOpt, SetOccEnabled(tc.occEnabled))
+			app.InitChain(context.Background(), &abci.RequestInitChain{})
+			header := tmproto.Header{Height: 1}
+			app.setDeliverState(header)
+			app.BeginBlock(app.deliverState.ctx, abci.RequestBeginBlock{Header: header})
+			app.StoreOCCParams(app.deliverState.ctx, OCCParams{StrictWritesets: tc.strict})
+			require.Equal(t, tc.strict, app.GetOCCParams(app.deliverState.ctx).StrictWritesets)
+
+			// the rogue tx fails in strict mode whether the block is executed by the scheduler or sequentially, with
+			// none of its writes kept, while the undeclared tx isn't restricted
+			responses := app.DeliverTxs(app.deliverState.ctx, requests)
+			require.Len(t, responses, txs)
+			shared := 0
+			for idx, res := range responses {
+				if tc.strict && idx == rogueTx {
+					require.Equal(t, sdkerrors.ErrOCCUndeclaredWrite.ABCICode(), res.Code)
+					require.Positive(t, res.GasUsed)
+					require.Zero(t, getIntFromStore(app.deliverState.ctx.KVStore(capKey1), []byte(fmt.Sprintf("tx-%d", idx))))
+					continue
+				}
+				shared++
+				require.Equal(t, abci.CodeTypeOK, res.Code)
+				requireAttribute(t, res.Events, "shared-val", fmt.Sprintf("%d", shared))
+				require.Equal(t, int64(1), getIntFromStore(app.deliverState.ctx.KVStore(capKey1), []byte(fmt.Sprintf("tx-%d", idx))))
+			}
+			require.Equal(t, int64(shared), getIntFromStore(app.deliverState.ctx.KVStore(capKey1), []byte("shared")))
+		})
+	}
+}
+
+func TestValidateOCCParams(t *testing.T) {
+	require.NoError(t, ValidateOCCParams(OCCParams{StrictWritesets: true}))
+	require.Error(t, ValidateOCCParams(&OCCParams{}))
+}
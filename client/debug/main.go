@@ -10,6 +10,7 @@ import (
 
 	"github.com/cosmos/cosmos-sdk/client"
 	cryptotypes "github.com/cosmos/cosmos-sdk/crypto/types"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/version"
 )
@@ -25,6 +26,7 @@ func Cmd() *cobra.Command {
 	cmd.AddCommand(PubkeyCmd())
 	cmd.AddCommand(AddrCmd())
 	cmd.AddCommand(RawBytesCmd())
+	cmd.AddCommand(OccConflictsCmd())
 
 	return cmd
 }
@@ -129,3 +131,41 @@ $ %s debug raw-bytes [72 101 108 108 111 44 32 112 108 97 121 103 114 111 117 11
 		},
 	}
 }
+
+const flagStores = "stores"
+
+func OccConflictsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "occ-conflicts [earlier-tx-json] [later-tx-json]",
+		Short: "Check whether a later tx conflicts with an earlier tx under OCC validation rules",
+		Long: fmt.Sprintf(`Check whether a later tx conflicts with an earlier tx given their readsets and writesets.
+Values are base64 encoded, and a null value in a writeset represents a delete. With --stores, the readsets and
+writesets of each tx are keyed by store name, and conflicts are reported by store qualified key.
+
+Example:
+$ %[1]s debug occ-conflicts '{"writeset":{"key1":"dmFsdWUx"}}' '{"readset":{"key1":["c3RhbGU="]}}'
+$ %[1]s debug occ-conflicts --stores '{"bank":{"writeset":{"key1":"dmFsdWUx"}}}' '{"bank":{"readset":{"key1":["c3RhbGU="]}}}'
+			`, version.AppName),
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			check := multiversion.CheckSerializedConflicts
+			if stores, _ := cmd.Flags().GetBool(flagStores); stores {
+				check = multiversion.CheckSerializedStoreConflicts
+			}
+			conflicts, err := check([]byte(args[0]), []byte(args[1]))
+			if err != nil {
+				return err
+			}
+			if len(conflicts) == 0 {
+				cmd.Println("No conflicts")
+				return nil
+			}
+			for _, conflict := range conflicts {
+				cmd.Println("Conflict:", conflict)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Bool(flagStores, false, "Access sets are keyed by store name")
+	return cmd
+}
@@ -0,0 +1,38 @@
+//go:build !occfailpoints
+// +build !occfailpoints
+
+package failpoint
+
+// Enabled is whether failpoints are compiled in
+const Enabled = false
+
+// Enable returns ErrDisabled, since failpoints aren't compiled in
+func Enable(...Failpoint) error {
+	return ErrDisabled
+}
+
+// Disable does nothing, since failpoints aren't compiled in
+func Disable() {}
+
+// Hits returns zero, since failpoints aren't compiled in
+func Hits(string) int {
+	return 0
+}
+
+// DelayExecution does nothing, since failpoints aren't compiled in
+func DelayExecution(int, int) {}
+
+// ForceAbort returns false, since failpoints aren't compiled in
+func ForceAbort(int, int) bool {
+	return false
+}
+
+// EstimateRead returns false, since failpoints aren't compiled in
+func EstimateRead(string, int, int, []byte) bool {
+	return false
+}
+
+// FlapValidation returns false, since failpoints aren't compiled in
+func FlapValidation(int, int) bool {
+	return false
+}
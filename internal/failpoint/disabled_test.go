@@ -0,0 +1,19 @@
+//go:build !occfailpoints
+// +build !occfailpoints
+
+package failpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisabled(t *testing.T) {
+	require.False(t, Enabled)
+	require.ErrorIs(t, Enable(Failpoint{Kind: KindAbort}), ErrDisabled)
+	require.False(t, ForceAbort(1, 0))
+	require.False(t, EstimateRead("bank", 1, 0, []byte("balance")))
+	require.False(t, FlapValidation(1, 0))
+	require.Zero(t, Hits("abort"))
+}
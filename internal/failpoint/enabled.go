@@ -0,0 +1,122 @@
+//go:build occfailpoints
+// +build occfailpoints
+
+package failpoint
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Enabled is whether failpoints are compiled in
+const Enabled = true
+
+var (
+	mx         sync.Mutex
+	failpoints []Failpoint
+	hits       map[string]int
+	// active is whether any failpoint is enabled, so that hooks skip the lock otherwise (only accessed atomically)
+	active int32
+)
+
+func init() {
+	spec := os.Getenv(EnvVar)
+	if spec == "" {
+		return
+	}
+	fps, err := Parse([]byte(spec))
+	if err != nil {
+		panic(fmt.Errorf("%s: %w", EnvVar, err))
+	}
+	if err := Enable(fps...); err != nil {
+		panic(fmt.Errorf("%s: %w", EnvVar, err))
+	}
+}
+
+// Enable replaces the enabled failpoints with the given ones, and resets their hits
+func Enable(fps ...Failpoint) error {
+	for _, fp := range fps {
+		if err := fp.Validate(); err != nil {
+			return err
+		}
+	}
+	mx.Lock()
+	defer mx.Unlock()
+	failpoints = append([]Failpoint(nil), fps...)
+	hits = make(map[string]int)
+	if len(failpoints) > 0 {
+		atomic.StoreInt32(&active, 1)
+	} else {
+		atomic.StoreInt32(&active, 0)
+	}
+	return nil
+}
+
+// Disable disables every failpoint
+func Disable() {
+	_ = Enable()
+}
+
+// Hits returns how often the failpoints of the given name fired since they were enabled
+func Hits(name string) int {
+	mx.Lock()
+	defer mx.Unlock()
+	return hits[name]
+}
+
+// fire returns the first enabled failpoint of the given kind that matches and may still fire, counting its hit
+func fire(kind Kind, index int, incarnation int, match func(fp Failpoint) bool) (Failpoint, bool) {
+	if atomic.LoadInt32(&active) == 0 {
+		return Failpoint{}, false
+	}
+	mx.Lock()
+	defer mx.Unlock()
+	for _, fp := range failpoints {
+		if !fp.matches(kind, index, incarnation) || (match != nil && !match(fp)) {
+			continue
+		}
+		if fp.Times > 0 && hits[fp.name()] >= fp.Times {
+			continue
+		}
+		hits[fp.name()]++
+		return fp, true
+	}
+	return Failpoint{}, false
+}
+
+// DelayExecution sleeps before an execution of the tx at index, if a KindDelay failpoint fires for it
+func DelayExecution(index int, incarnation int) {
+	if fp, ok := fire(KindDelay, index, incarnation, nil); ok {
+		time.Sleep(fp.Delay)
+	}
+}
+
+// ForceAbort returns whether a KindAbort failpoint fires for the execution of the tx at index, which then aborts with a
+// dependency on the tx before it
+func ForceAbort(index int, incarnation int) bool {
+	if index == 0 {
+		return false
+	}
+	_, ok := fire(KindAbort, index, incarnation, nil)
+	return ok
+}
+
+// EstimateRead returns whether a KindEstimateRead failpoint fires for the read of key from the store key of the given
+// name by the tx at index, which then reads an estimate of the tx before it
+func EstimateRead(store string, index int, incarnation int, key []byte) bool {
+	if index == 0 {
+		return false
+	}
+	_, ok := fire(KindEstimateRead, index, incarnation, func(fp Failpoint) bool { return fp.matchesRead(store, key) })
+	return ok
+}
+
+// FlapValidation returns whether a KindValidationFlap failpoint fires for a validation of the tx at index, which then
+// fails even though its reads are valid
+func FlapValidation(index int, incarnation int) bool {
+	_, ok := fire(KindValidationFlap, index, incarnation, nil)
+	return ok
+}
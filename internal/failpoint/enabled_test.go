@@ -0,0 +1,54 @@
+//go:build occfailpoints
+// +build occfailpoints
+
+package failpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnabled(t *testing.T) {
+	require.True(t, Enabled)
+	t.Cleanup(Disable)
+
+	require.Error(t, Enable(Failpoint{Kind: KindDelay}))
+	require.NoError(t, Enable(
+		Failpoint{Name: "abort-3", Kind: KindAbort, Txs: []int{3}, Times: 2},
+		Failpoint{Kind: KindAbort, Txs: []int{0, 5}, Incarnations: []int{0}},
+		Failpoint{Kind: KindEstimateRead, Key: []byte("balance")},
+		Failpoint{Kind: KindValidationFlap, Txs: []int{0}, Times: 1},
+		Failpoint{Kind: KindDelay, Txs: []int{1}, Delay: 10 * time.Millisecond},
+	))
+
+	// failpoints fire for matching txs until they ran out of times
+	require.True(t, ForceAbort(3, 0))
+	require.True(t, ForceAbort(3, 1))
+	require.False(t, ForceAbort(3, 2))
+	require.Equal(t, 2, Hits("abort-3"))
+	require.True(t, ForceAbort(5, 0))
+	require.False(t, ForceAbort(5, 1))
+	require.False(t, ForceAbort(4, 0))
+
+	// aborts and estimate reads depend on the tx before, so the first tx is exempt
+	require.False(t, ForceAbort(0, 0))
+	require.False(t, EstimateRead("bank", 0, 0, []byte("balance")))
+	require.True(t, EstimateRead("bank", 2, 0, []byte("balance")))
+	require.False(t, EstimateRead("bank", 2, 0, []byte("supply")))
+
+	require.True(t, FlapValidation(0, 0))
+	require.False(t, FlapValidation(0, 0))
+
+	start := time.Now()
+	DelayExecution(1, 0)
+	require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+	// enabling failpoints replaces the previous ones and resets their hits
+	require.NoError(t, Enable(Failpoint{Name: "abort-3", Kind: KindAbort, Txs: []int{3}}))
+	require.Zero(t, Hits("abort-3"))
+	require.False(t, EstimateRead("bank", 2, 0, []byte("balance")))
+	Disable()
+	require.False(t, ForceAbort(3, 0))
+}
@@ -0,0 +1,222 @@
+// Package failpoint injects faults into OCC execution at specific txs and keys: delayed executions, forced aborts,
+// spurious estimate reads and flapping validations, so that the retry, waiting and fallback logic of the scheduler can
+// be exercised deterministically in tests and soak runs. Failpoints are only compiled in with the occfailpoints build
+// tag. Without it, Enable returns ErrDisabled and every hook is a no-op, so regular builds pay nothing for them.
+//
+// With the build tag, failpoints can also be enabled for a whole process by setting the OCC_FAILPOINTS environment
+// variable to a JSON array of failpoints, eg. for soak runs:
+//
+//	OCC_FAILPOINTS='[{"kind":"abort","txs":[3],"incarnations":[0]},{"kind":"delay","delay":"5ms"}]'
+package failpoint
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EnvVar is the environment variable failpoints are read from when the process starts, with the build tag
+const EnvVar = "OCC_FAILPOINTS"
+
+var (
+	// ErrDisabled is returned by Enable in builds without the occfailpoints build tag
+	ErrDisabled = errors.New("failpoints are disabled, build with the occfailpoints build tag to enable them")
+	// ErrInjected is the error of the aborts forced by KindAbort failpoints
+	ErrInjected = errors.New("abort injected by failpoint")
+)
+
+// Kind is the fault a failpoint injects
+type Kind int
+
+const (
+	// KindDelay sleeps before an execution of a tx starts, eg. to widen the window in which other txs conflict with it
+	KindDelay Kind = iota + 1
+	// KindAbort aborts an execution of a tx once it's done, as if it read an estimate of the tx before it
+	KindAbort
+	// KindEstimateRead has a read of a key by an execution of a tx hit an estimate of the tx before it, which aborts the
+	// execution mid-way
+	KindEstimateRead
+	// KindValidationFlap fails a validation of a tx whose reads are valid, so that it's re-executed
+	KindValidationFlap
+)
+
+var kindNames = map[Kind]string{
+	KindDelay:          "delay",
+	KindAbort:          "abort",
+	KindEstimateRead:   "estimate_read",
+	KindValidationFlap: "validation_flap",
+}
+
+// String returns the name of the kind, as used in JSON
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k Kind) MarshalText() ([]byte, error) {
+	if _, ok := kindNames[k]; !ok {
+		return nil, fmt.Errorf("unknown failpoint kind %d", int(k))
+	}
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (k *Kind) UnmarshalText(text []byte) error {
+	for kind, name := range kindNames {
+		if name == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown failpoint kind %q", text)
+}
+
+// Failpoint is a fault injected into the executions or validations of txs that match it. Aborts and estimate reads
+// depend on the tx before the one they're injected into, which the scheduler waits for like for a real conflict, so
+// they're never injected into the first tx of a block. A failpoint that keeps firing for every incarnation of a tx keeps
+// it from ever validating, which is how the livelock detection and fallbacks of the scheduler are exercised; bound it
+// with Incarnations or Times for a block to converge.
+type Failpoint struct {
+	// Name identifies the failpoint in Hits, and defaults to the name of its kind
+	Name string
+	Kind Kind
+	// Txs and Incarnations restrict the failpoint to the executions or validations of the given tx indices and
+	// incarnations. Empty matches any.
+	Txs          []int
+	Incarnations []int
+	// Store and Key restrict KindEstimateRead failpoints to the reads of a key of the store key of that name. Empty matches
+	// any.
+	Store string
+	Key   []byte
+	// Delay is how long KindDelay failpoints sleep for
+	Delay time.Duration
+	// Times bounds how often the failpoint fires. Zero is unbounded.
+	Times int
+}
+
+// failpointJSON is the JSON form of a failpoint, with the delay as a duration string and the key as a plain string
+type failpointJSON struct {
+	Name         string `json:"name,omitempty"`
+	Kind         Kind   `json:"kind"`
+	Txs          []int  `json:"txs,omitempty"`
+	Incarnations []int  `json:"incarnations,omitempty"`
+	Store        string `json:"store,omitempty"`
+	Key          string `json:"key,omitempty"`
+	Delay        string `json:"delay,omitempty"`
+	Times        int    `json:"times,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reading the delay as a duration string, eg. "5ms", and the key as a plain
+// string
+func (fp *Failpoint) UnmarshalJSON(bz []byte) error {
+	var raw failpointJSON
+	if err := json.Unmarshal(bz, &raw); err != nil {
+		return err
+	}
+	*fp = Failpoint{
+		Name:         raw.Name,
+		Kind:         raw.Kind,
+		Txs:          raw.Txs,
+		Incarnations: raw.Incarnations,
+		Store:        raw.Store,
+		Times:        raw.Times,
+	}
+	if raw.Key != "" {
+		fp.Key = []byte(raw.Key)
+	}
+	if raw.Delay != "" {
+		delay, err := time.ParseDuration(raw.Delay)
+		if err != nil {
+			return fmt.Errorf("failpoint delay: %w", err)
+		}
+		fp.Delay = delay
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, in the form UnmarshalJSON reads
+func (fp Failpoint) MarshalJSON() ([]byte, error) {
+	raw := failpointJSON{
+		Name:         fp.Name,
+		Kind:         fp.Kind,
+		Txs:          fp.Txs,
+		Incarnations: fp.Incarnations,
+		Store:        fp.Store,
+		Key:          string(fp.Key),
+		Times:        fp.Times,
+	}
+	if fp.Delay != 0 {
+		raw.Delay = fp.Delay.String()
+	}
+	return json.Marshal(raw)
+}
+
+// Parse parses a JSON array of failpoints, as read from EnvVar, and validates them
+func Parse(bz []byte) ([]Failpoint, error) {
+	var fps []Failpoint
+	if err := json.Unmarshal(bz, &fps); err != nil {
+		return nil, fmt.Errorf("invalid failpoints: %w", err)
+	}
+	for _, fp := range fps {
+		if err := fp.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return fps, nil
+}
+
+// Validate returns an error if the failpoint can't be injected
+func (fp Failpoint) Validate() error {
+	if _, ok := kindNames[fp.Kind]; !ok {
+		return fmt.Errorf("failpoint %q: unknown kind %d", fp.name(), int(fp.Kind))
+	}
+	if fp.Kind == KindDelay && fp.Delay <= 0 {
+		return fmt.Errorf("failpoint %q: delay must be positive", fp.name())
+	}
+	if fp.Kind != KindDelay && fp.Delay != 0 {
+		return fmt.Errorf("failpoint %q: only delay failpoints have a delay", fp.name())
+	}
+	if fp.Kind != KindEstimateRead && (fp.Store != "" || fp.Key != nil) {
+		return fmt.Errorf("failpoint %q: only estimate_read failpoints match stores and keys", fp.name())
+	}
+	if fp.Times < 0 {
+		return fmt.Errorf("failpoint %q: times must be non-negative", fp.name())
+	}
+	return nil
+}
+
+// name returns the name the hits of the failpoint are counted under
+func (fp Failpoint) name() string {
+	if fp.Name != "" {
+		return fp.Name
+	}
+	return fp.Kind.String()
+}
+
+// matches returns whether the failpoint applies to an execution or validation of the tx at index
+func (fp Failpoint) matches(kind Kind, index int, incarnation int) bool {
+	return fp.Kind == kind && contains(fp.Txs, index) && contains(fp.Incarnations, incarnation)
+}
+
+// matchesRead returns whether the failpoint applies to a read of key from the store key of the given name
+func (fp Failpoint) matchesRead(store string, key []byte) bool {
+	return (fp.Store == "" || fp.Store == store) && (fp.Key == nil || bytes.Equal(fp.Key, key))
+}
+
+// contains returns whether values contains value, or is empty
+func contains(values []int, value int) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
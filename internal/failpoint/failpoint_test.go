@@ -0,0 +1,62 @@
+package failpoint
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	fps, err := Parse([]byte(`[
+		{"kind":"abort","txs":[3],"incarnations":[0]},
+		{"name":"slow","kind":"delay","delay":"5ms","times":2},
+		{"kind":"estimate_read","store":"bank","key":"balance"},
+		{"kind":"validation_flap"}
+	]`))
+	require.NoError(t, err)
+	require.Equal(t, []Failpoint{
+		{Kind: KindAbort, Txs: []int{3}, Incarnations: []int{0}},
+		{Name: "slow", Kind: KindDelay, Delay: 5 * time.Millisecond, Times: 2},
+		{Kind: KindEstimateRead, Store: "bank", Key: []byte("balance")},
+		{Kind: KindValidationFlap},
+	}, fps)
+
+	// failpoints round trip through JSON
+	bz, err := json.Marshal(fps)
+	require.NoError(t, err)
+	parsed, err := Parse(bz)
+	require.NoError(t, err)
+	require.Equal(t, fps, parsed)
+
+	for _, spec := range []string{
+		`{"kind":"abort"}`,
+		`[{"kind":"crash"}]`,
+		`[{"kind":"delay","delay":"soon"}]`,
+		`[{"kind":"delay"}]`,
+		`[{"kind":"abort","delay":"5ms"}]`,
+		`[{"kind":"validation_flap","key":"balance"}]`,
+		`[{"kind":"abort","times":-1}]`,
+	} {
+		_, err := Parse([]byte(spec))
+		require.Error(t, err, spec)
+	}
+}
+
+func TestFailpointMatches(t *testing.T) {
+	fp := Failpoint{Kind: KindEstimateRead, Txs: []int{2, 4}, Incarnations: []int{1}, Store: "bank", Key: []byte("balance")}
+	require.True(t, fp.matches(KindEstimateRead, 4, 1))
+	require.False(t, fp.matches(KindAbort, 4, 1))
+	require.False(t, fp.matches(KindEstimateRead, 3, 1))
+	require.False(t, fp.matches(KindEstimateRead, 4, 0))
+	require.True(t, fp.matchesRead("bank", []byte("balance")))
+	require.False(t, fp.matchesRead("staking", []byte("balance")))
+	require.False(t, fp.matchesRead("bank", []byte("supply")))
+
+	// empty restrictions match anything
+	fp = Failpoint{Kind: KindEstimateRead}
+	require.True(t, fp.matches(KindEstimateRead, 7, 3))
+	require.True(t, fp.matchesRead("staking", []byte("supply")))
+	require.Equal(t, "estimate_read", fp.name())
+}
@@ -0,0 +1,128 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+	dbm "github.com/tendermint/tm-db"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cosmos/cosmos-sdk/occ/api"
+	"github.com/cosmos/cosmos-sdk/store/cachekv"
+	"github.com/cosmos/cosmos-sdk/store/cachemulti"
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/tasks"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+	"github.com/cosmos/cosmos-sdk/utils/tracing"
+)
+
+func TestMultiVersionStore(t *testing.T) {
+	parent := dbadapter.Store{DB: dbm.NewMemDB()}
+	parent.Set([]byte("parent"), []byte("value"))
+	mvs := api.NewMultiVersionStore(parent)
+
+	require.Nil(t, mvs.GetLatest([]byte("key")))
+	mvs.SetWriteset(1, 0, api.WriteSet{"key": []byte("value1"), "deleted": nil})
+	mvs.SetEstimatedWriteset(3, 0, api.WriteSet{"key": nil})
+
+	latest := mvs.GetLatest([]byte("key"))
+	require.True(t, latest.IsEstimate())
+	require.Equal(t, 3, latest.Index())
+	before := mvs.GetLatestBeforeIndex(3, []byte("key"))
+	require.Equal(t, []byte("value1"), before.Value())
+	require.Equal(t, 0, before.Incarnation())
+	require.True(t, mvs.GetLatestBeforeIndex(2, []byte("deleted")).IsDeleted())
+	require.Nil(t, mvs.GetLatestBeforeIndex(1, []byte("key")))
+	require.True(t, mvs.Has(2, []byte("key")))
+
+	// reads of values that changed since are invalid, and reads of estimates conflict with their writer
+	mvs.SetReadset(2, api.ReadSet{"key": {[]byte("value1")}})
+	mvs.SetReadset(4, api.ReadSet{"key": {[]byte("value1")}})
+	require.Equal(t, api.ReadSet{"key": {[]byte("value1")}}, mvs.GetReadset(2))
+	valid, conflicts := mvs.ValidateTransactionState(2)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+	valid, conflicts = mvs.ValidateTransactionState(4)
+	require.True(t, valid)
+	require.Equal(t, []int{3}, conflicts)
+	mvs.SetWriteset(3, 1, api.WriteSet{"key": []byte("value3")})
+	valid, _ = mvs.ValidateTransactionState(4)
+	require.False(t, valid)
+
+	mvs.InvalidateWriteset(3, 1)
+	require.True(t, mvs.GetLatest([]byte("key")).IsEstimate())
+	mvs.SetWriteset(3, 2, api.WriteSet{"key": []byte("value3")})
+	mvs.WriteLatestToStore()
+	require.Equal(t, []byte("value3"), parent.Get([]byte("key")))
+	require.False(t, parent.Has([]byte("deleted")))
+	require.Equal(t, []byte("value"), parent.Get([]byte("parent")))
+}
+
+func TestScheduler(t *testing.T) {
+	tp := trace.NewNoopTracerProvider()
+	tr := tp.Tracer("api-test")
+	ti := &tracing.Info{
+		Tracer: &tr,
+	}
+	storeKey := sdk.NewKVStoreKey("mock")
+	db := dbm.NewMemDB()
+	stores := map[sdk.StoreKey]sdk.CacheWrapper{storeKey: cachekv.NewStore(dbadapter.Store{DB: db}, storeKey, 1000)}
+	ms := cachemulti.NewStore(db, stores, map[string]sdk.StoreKey{storeKey.Name(): storeKey}, nil, nil, nil)
+	ctx := sdk.Context{}.WithContext(context.Background()).WithMultiStore(&ms).WithLogger(log.NewNopLogger())
+
+	// every tx appends its index to the same key
+	deliverTx := func(ctx sdk.Context, req abci.RequestDeliverTx) (res abci.ResponseDeliverTx) {
+		defer func() {
+			// aborted executions are re-executed
+			if r := recover(); r != nil {
+				if _, ok := r.(occ.Abort); !ok {
+					panic(r)
+				}
+			}
+		}()
+		kv := ctx.MultiStore().GetKVStore(storeKey)
+		val := string(kv.Get([]byte("key"))) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set([]byte("key"), []byte(val))
+		return abci.ResponseDeliverTx{Info: val}
+	}
+
+	const txs = 10
+	reqs := make([]*api.DeliverTxRequest, txs)
+	for i := range reqs {
+		reqs[i] = &api.DeliverTxRequest{
+			Request:            abci.RequestDeliverTx{Tx: []byte(fmt.Sprintf("%d", i))},
+			EstimatedWritesets: map[sdk.StoreKey]api.WriteSet{storeKey: {"key": nil}},
+			EstimateConfidence: api.EstimateConfidenceGuaranteed,
+		}
+	}
+	s := api.NewScheduler(4, ti, deliverTx, api.WithMaxIterations(5), api.WithTaskTimeout(time.Minute))
+	res, err := s.ProcessBlock(ctx, reqs)
+	require.NoError(t, err)
+	expected := ""
+	for i, r := range res {
+		expected += fmt.Sprintf("%d,", i)
+		require.Equal(t, expected, r.Info)
+	}
+	require.Equal(t, []byte(expected), ctx.MultiStore().GetKVStore(storeKey).Get([]byte("key")))
+	require.Equal(t, txs, s.Metrics().Txs)
+
+	// the deprecated entries of the types package are still processed, through ProcessBlock
+	entries := make([]*sdk.DeliverTxEntry, txs)
+	for i := range entries {
+		entries[i] = &sdk.DeliverTxEntry{Request: abci.RequestDeliverTx{Tx: []byte(fmt.Sprintf("%d", i))}}
+	}
+	s = api.NewScheduler(4, ti, deliverTx, api.WithUnstableOptions(tasks.WithMaxIterations(5)))
+	res, err = s.ProcessAll(ctx, entries)
+	require.NoError(t, err)
+	for i, r := range res {
+		expected += fmt.Sprintf("%d,", i)
+		require.Equal(t, expected, r.Info)
+	}
+	require.Equal(t, txs, s.Metrics().Txs)
+}
@@ -0,0 +1,23 @@
+/*
+Package api is the stable, versioned public interface of the OCC engine, for chains embedding it.
+
+The internals of the engine, the scheduler of the tasks package and the multiversion stores of the store/multiversion
+package, keep being redesigned, eg. to pipeline the scheduler or shard the stores, and their exported APIs change
+along with them. This package freezes a v1 subset of them behind adapters over the internal implementations, so that
+code written against it keeps building across those redesigns:
+
+  - the interfaces, types and functions of the package aren't changed or removed in incompatible ways within major
+    version 1 (see Version), only added to, eg. with new options or with new interfaces extending the existing ones
+  - anything superseded is marked as deprecated, and kept working through a shim over its replacement until the next
+    major version
+  - the interfaces are only meant to be implemented by the adapters of this package, so methods may be added to them
+
+The types of the internal packages aren't part of v1 either: txs are passed to a Scheduler as DeliverTxRequests, and
+configured with the SchedulerOptions of this package, which are converted to those of the internal scheduler. The
+deprecated Scheduler.ProcessAll and WithUnstableOptions, which took the internal types, are kept as shims until the
+next major version.
+*/
+package api
+
+// Version is the semantic version of the API of the package
+const Version = "1.1.0"
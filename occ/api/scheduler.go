@@ -0,0 +1,218 @@
+package api
+
+import (
+	"time"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/tasks"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/utils/tracing"
+)
+
+// ErrInterrupted is returned by Scheduler.ProcessAll when the block is interrupted, once the context of the block is
+// done
+var ErrInterrupted = tasks.ErrInterrupted
+
+// DeliverTxFunc executes a single tx of a block against the multistore of ctx
+type DeliverTxFunc func(ctx sdk.Context, req abci.RequestDeliverTx) abci.ResponseDeliverTx
+
+// DeliverTxRequest is a tx of a block to be processed by a Scheduler, along with the hints the scheduler may use to
+// execute it. The hints only bear on how fast the block is processed, never on its outcome.
+type DeliverTxRequest struct {
+	Request abci.RequestDeliverTx
+	// EstimatedWritesets holds the keys the tx is expected to write by store
+	EstimatedWritesets map[sdk.StoreKey]WriteSet
+	// EstimateConfidence is how far EstimatedWritesets can be trusted
+	EstimateConfidence EstimateConfidence
+	// EstimatedReadsets and EstimatedGas are hints from a pre-simulation of the tx (eg. in CheckTx), if any
+	EstimatedReadsets map[sdk.StoreKey]ReadSet
+	EstimatedGas      uint64
+	// NoWritesExpected flags a tx that isn't expected to write any state
+	NoWritesExpected bool
+}
+
+// EstimateConfidence describes how far the estimated writesets of a DeliverTxRequest can be trusted
+type EstimateConfidence int
+
+const (
+	// EstimateConfidenceHint means the estimated writesets are only conflict hints, and may be incomplete
+	EstimateConfidenceHint EstimateConfidence = iota
+	// EstimateConfidenceGuaranteed means the estimated writesets contain every key the tx may write
+	EstimateConfidenceGuaranteed
+)
+
+// Scheduler executes the txs of blocks concurrently with optimistic concurrency control, with the same outcome as
+// executing them sequentially
+type Scheduler interface {
+	// ProcessBlock processes all txs of a block, writing their final state to the multistore of ctx and returning
+	// their responses in tx order. A scheduler may be reused for consecutive blocks, but not concurrently.
+	ProcessBlock(ctx sdk.Context, reqs []*DeliverTxRequest) ([]abci.ResponseDeliverTx, error)
+	// ProcessAll is ProcessBlock for the internal tx entries of the types package.
+	//
+	// Deprecated: use ProcessBlock, which doesn't depend on the internals of the engine. ProcessAll is kept until
+	// the next major version.
+	ProcessAll(ctx sdk.Context, reqs []*sdk.DeliverTxEntry) ([]abci.ResponseDeliverTx, error)
+	// Metrics returns the statistics of the most recently processed block
+	Metrics() Metrics
+}
+
+// Metrics are the statistics of a block processed by a Scheduler
+type Metrics struct {
+	// Txs is the number of txs in the block
+	Txs int
+	// Iterations is the number of execute and validate rounds
+	Iterations int
+	// Retries is the number of executions beyond the first execution of every tx
+	Retries int
+	// Aborts is the number of executions aborted for reading the estimated writes of another tx
+	Aborts int
+	// Synchronous is whether the scheduler fell back to executing the rest of the block sequentially
+	Synchronous bool
+	// Duration is the time taken to process the block
+	Duration time.Duration
+}
+
+// SchedulerOption configures a Scheduler created by NewScheduler
+type SchedulerOption func(cfg *schedulerConfig)
+
+// schedulerConfig collects the options of a Scheduler as the options of the internal scheduler they stand for, in
+// the order they're given
+type schedulerConfig struct {
+	internalOptions []tasks.SchedulerOption
+}
+
+// withInternalOptions returns a SchedulerOption passing options of the internal scheduler on
+func withInternalOptions(opts ...tasks.SchedulerOption) SchedulerOption {
+	return func(cfg *schedulerConfig) { cfg.internalOptions = append(cfg.internalOptions, opts...) }
+}
+
+// WithMaxIterations sets the number of execute and validate rounds after which the rest of a block is executed
+// sequentially
+func WithMaxIterations(maxIterations int) SchedulerOption {
+	return withInternalOptions(tasks.WithMaxIterations(maxIterations))
+}
+
+// WithTaskTimeout bounds how long a single execution of a tx may take. A block with a tx that times out is executed
+// sequentially, without a timeout, so the timeout never changes the outcome of a block.
+func WithTaskTimeout(timeout time.Duration) SchedulerOption {
+	return withInternalOptions(tasks.WithTaskTimeout(timeout))
+}
+
+// WithStrictWritesets declares that the estimated writesets of the requests are enforced by the DeliverTxFunc, which
+// fails any tx writing a key outside of them, so that blocks whose estimated writesets are disjoint skip validation
+func WithStrictWritesets() SchedulerOption {
+	return withInternalOptions(tasks.WithStrictWritesets())
+}
+
+// WithUnstableOptions passes options of the internal scheduler through, which aren't covered by the guarantees of
+// the package and may change or go away in any release.
+//
+// Deprecated: the options of the internal scheduler aren't part of v1, so code passing them may break with any
+// redesign of the engine. Use the options of this package instead. WithUnstableOptions is kept until the next major
+// version.
+func WithUnstableOptions(opts ...tasks.SchedulerOption) SchedulerOption {
+	return withInternalOptions(opts...)
+}
+
+// NewScheduler creates a scheduler executing txs with deliverTx on the given number of workers
+func NewScheduler(workers int, tracingInfo *tracing.Info, deliverTx DeliverTxFunc, opts ...SchedulerOption) Scheduler {
+	var cfg schedulerConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return AdaptScheduler(tasks.NewScheduler(workers, tracingInfo, deliverTx, cfg.internalOptions...))
+}
+
+// AdaptScheduler adapts a scheduler of the tasks package to the v1 interface
+func AdaptScheduler(s tasks.Scheduler) Scheduler {
+	return schedulerAdapter{s: s}
+}
+
+type schedulerAdapter struct {
+	s tasks.Scheduler
+}
+
+var _ Scheduler = schedulerAdapter{}
+
+// ProcessBlock implements Scheduler.
+func (a schedulerAdapter) ProcessBlock(ctx sdk.Context, reqs []*DeliverTxRequest) ([]abci.ResponseDeliverTx, error) {
+	entries := make([]*sdk.DeliverTxEntry, len(reqs))
+	for i, req := range reqs {
+		entries[i] = req.toEntry()
+	}
+	return a.s.ProcessAll(ctx, entries)
+}
+
+// ProcessAll implements Scheduler.
+func (a schedulerAdapter) ProcessAll(ctx sdk.Context, reqs []*sdk.DeliverTxEntry) ([]abci.ResponseDeliverTx, error) {
+	requests := make([]*DeliverTxRequest, len(reqs))
+	for i, entry := range reqs {
+		requests[i] = requestOf(entry)
+	}
+	return a.ProcessBlock(ctx, requests)
+}
+
+// Metrics implements Scheduler.
+func (a schedulerAdapter) Metrics() Metrics {
+	m := a.s.Metrics()
+	return Metrics{
+		Txs:         m.Txs,
+		Iterations:  m.Iterations,
+		Retries:     m.Retries,
+		Aborts:      m.Aborts,
+		Synchronous: m.Synchronous,
+		Duration:    m.Duration,
+	}
+}
+
+// toEntry converts the request to the tx entry of the internal scheduler
+func (req *DeliverTxRequest) toEntry() *sdk.DeliverTxEntry {
+	entry := &sdk.DeliverTxEntry{
+		Request:          req.Request,
+		EstimatedGas:     req.EstimatedGas,
+		NoWritesExpected: req.NoWritesExpected,
+	}
+	if req.EstimateConfidence == EstimateConfidenceGuaranteed {
+		entry.EstimateConfidence = sdk.EstimateConfidenceGuaranteed
+	}
+	if req.EstimatedWritesets != nil {
+		entry.EstimatedWritesets = make(sdk.MappedWritesets, len(req.EstimatedWritesets))
+		for storeKey, writeset := range req.EstimatedWritesets {
+			entry.EstimatedWritesets[storeKey] = multiversion.WriteSet(writeset)
+		}
+	}
+	if req.EstimatedReadsets != nil {
+		entry.EstimatedReadsets = make(sdk.MappedReadsets, len(req.EstimatedReadsets))
+		for storeKey, readset := range req.EstimatedReadsets {
+			entry.EstimatedReadsets[storeKey] = multiversion.ReadSet(readset)
+		}
+	}
+	return entry
+}
+
+// requestOf converts a tx entry of the internal scheduler to a request, the inverse of toEntry
+func requestOf(entry *sdk.DeliverTxEntry) *DeliverTxRequest {
+	req := &DeliverTxRequest{
+		Request:          entry.Request,
+		EstimatedGas:     entry.EstimatedGas,
+		NoWritesExpected: entry.NoWritesExpected,
+	}
+	if entry.EstimateConfidence == sdk.EstimateConfidenceGuaranteed {
+		req.EstimateConfidence = EstimateConfidenceGuaranteed
+	}
+	if entry.EstimatedWritesets != nil {
+		req.EstimatedWritesets = make(map[sdk.StoreKey]WriteSet, len(entry.EstimatedWritesets))
+		for storeKey, writeset := range entry.EstimatedWritesets {
+			req.EstimatedWritesets[storeKey] = WriteSet(writeset)
+		}
+	}
+	if entry.EstimatedReadsets != nil {
+		req.EstimatedReadsets = make(map[sdk.StoreKey]ReadSet, len(entry.EstimatedReadsets))
+		for storeKey, readset := range entry.EstimatedReadsets {
+			req.EstimatedReadsets[storeKey] = ReadSet(readset)
+		}
+	}
+	return req
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// WriteSet holds the values a tx wrote by key, where a nil value is a delete
+type WriteSet map[string][]byte
+
+// ReadSet holds the distinct values a tx read by key, in the order they were first read
+type ReadSet map[string][][]byte
+
+// Value is a version of a key in a MultiVersionStore, written by the tx at Index
+type Value interface {
+	Index() int
+	Incarnation() int
+	// Value is the value written, or nil for deletes and estimates
+	Value() []byte
+	IsDeleted() bool
+	// IsEstimate is whether the value is only an estimate of a write of the tx, which txs reading it wait for
+	IsEstimate() bool
+}
+
+// MultiVersionStore holds the versions of the keys of a store written by the txs of a block, so that every tx reads
+// the latest writes of the txs before it, and the reads of a tx can be validated once the txs before it are final
+type MultiVersionStore interface {
+	// GetLatest returns the version of key written by the highest tx index, or nil if there's none
+	GetLatest(key []byte) Value
+	// GetLatestBeforeIndex returns the version of key written by the highest tx index below index, or nil if there's
+	// none
+	GetLatestBeforeIndex(index int, key []byte) Value
+	// Has returns whether there's a version of key written by a tx below index
+	Has(index int, key []byte) bool
+	// SetWriteset replaces the writes of the tx at index with those of the given incarnation
+	SetWriteset(index int, incarnation int, writeset WriteSet)
+	// SetEstimatedWriteset sets estimates for the keys the tx at index is expected to write
+	SetEstimatedWriteset(index int, incarnation int, writeset WriteSet)
+	// InvalidateWriteset turns the writes of the given incarnation of the tx at index into estimates
+	InvalidateWriteset(index int, incarnation int)
+	// SetReadset sets the reads of the tx at index to validate
+	SetReadset(index int, readset ReadSet)
+	// GetReadset returns the reads of the tx at index, or nil if there are none
+	GetReadset(index int) ReadSet
+	// ValidateTransactionState validates the reads of the tx at index against the writes of the txs before it,
+	// returning whether they're valid, and the indices of the txs they conflict with
+	ValidateTransactionState(index int) (bool, []int)
+	// WriteLatestToStore writes the latest version of every key to the parent store
+	WriteLatestToStore()
+}
+
+// NewMultiVersionStore creates a multiversion store over the parent store
+func NewMultiVersionStore(parent storetypes.KVStore) MultiVersionStore {
+	return AdaptMultiVersionStore(multiversion.NewMultiVersionStore(parent))
+}
+
+// AdaptMultiVersionStore adapts a multiversion store of the store/multiversion package to the v1 interface
+func AdaptMultiVersionStore(mvs multiversion.MultiVersionStore) MultiVersionStore {
+	return multiVersionStoreAdapter{mvs: mvs}
+}
+
+type multiVersionStoreAdapter struct {
+	mvs multiversion.MultiVersionStore
+}
+
+var _ MultiVersionStore = multiVersionStoreAdapter{}
+
+// valueOf returns the version as a Value, keeping nil versions untyped
+func valueOf(item multiversion.MultiVersionValueItem) Value {
+	if item == nil {
+		return nil
+	}
+	return item
+}
+
+// GetLatest implements MultiVersionStore.
+func (a multiVersionStoreAdapter) GetLatest(key []byte) Value {
+	return valueOf(a.mvs.GetLatest(key))
+}
+
+// GetLatestBeforeIndex implements MultiVersionStore.
+func (a multiVersionStoreAdapter) GetLatestBeforeIndex(index int, key []byte) Value {
+	return valueOf(a.mvs.GetLatestBeforeIndex(index, key))
+}
+
+// Has implements MultiVersionStore.
+func (a multiVersionStoreAdapter) Has(index int, key []byte) bool {
+	return a.mvs.Has(index, key)
+}
+
+// SetWriteset implements MultiVersionStore.
+func (a multiVersionStoreAdapter) SetWriteset(index int, incarnation int, writeset WriteSet) {
+	a.mvs.SetWriteset(index, incarnation, multiversion.WriteSet(writeset))
+}
+
+// SetEstimatedWriteset implements MultiVersionStore.
+func (a multiVersionStoreAdapter) SetEstimatedWriteset(index int, incarnation int, writeset WriteSet) {
+	a.mvs.SetEstimatedWriteset(index, incarnation, multiversion.WriteSet(writeset))
+}
+
+// InvalidateWriteset implements MultiVersionStore.
+func (a multiVersionStoreAdapter) InvalidateWriteset(index int, incarnation int) {
+	a.mvs.InvalidateWriteset(index, incarnation)
+}
+
+// SetReadset implements MultiVersionStore.
+func (a multiVersionStoreAdapter) SetReadset(index int, readset ReadSet) {
+	a.mvs.SetReadset(index, multiversion.ReadSet(readset))
+}
+
+// GetReadset implements MultiVersionStore.
+func (a multiVersionStoreAdapter) GetReadset(index int) ReadSet {
+	return ReadSet(a.mvs.GetReadset(index))
+}
+
+// ValidateTransactionState implements MultiVersionStore.
+func (a multiVersionStoreAdapter) ValidateTransactionState(index int) (bool, []int) {
+	return a.mvs.ValidateTransactionState(index)
+}
+
+// WriteLatestToStore implements MultiVersionStore.
+func (a multiVersionStoreAdapter) WriteLatestToStore() {
+	a.mvs.WriteLatestToStore()
+}
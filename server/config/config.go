@@ -9,6 +9,7 @@ import (
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 	"github.com/sei-protocol/sei-db/config"
+	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 	tmcfg "github.com/tendermint/tendermint/config"
 )
@@ -27,6 +28,17 @@ const (
 
 	// DefaultOccEanbled defines whether to use OCC for tx processing
 	DefaultOccEnabled = false
+
+	// OCCFallbackPolicyWait makes txs that fail OCC validation wait for the txs they conflict with to be validated
+	// before they are re-executed
+	OCCFallbackPolicyWait = "wait"
+	// OCCFallbackPolicyRerun makes txs that fail OCC validation be re-executed right away
+	OCCFallbackPolicyRerun = "rerun"
+
+	// OCCTelemetryFull emits OCC telemetry as it happens
+	OCCTelemetryFull = "full"
+	// OCCTelemetryBatched aggregates OCC telemetry and emits it once per block
+	OCCTelemetryBatched = "batched"
 )
 
 // BaseConfig defines the server's basic configuration
@@ -97,8 +109,12 @@ type BaseConfig struct {
 
 	// ConcurrencyWorkers defines the number of workers to use for concurrent
 	// transaction execution. A value of -1 means unlimited workers.  Default value is 10.
+	//
+	// Deprecated: use OCCConfig.Workers, which takes precedence if set.
 	ConcurrencyWorkers int `mapstructure:"concurrency-workers"`
 	// Whether to enable optimistic concurrency control for tx execution, default is true
+	//
+	// Deprecated: use OCCConfig.Enable, which takes precedence if set.
 	OccEnabled bool `mapstructure:"occ-enabled"`
 }
 
@@ -192,6 +208,53 @@ type StateSyncConfig struct {
 	SnapshotDirectory string `mapstructure:"snapshot-directory"`
 }
 
+// OCCConfig defines the configuration of optimistic concurrency control (OCC)
+// for tx execution. Apps that support it apply changes to it at the next block
+// boundary, without a restart.
+type OCCConfig struct {
+	// Enable defines whether OCC is used for tx execution.
+	Enable bool `mapstructure:"enable"`
+
+	// Workers defines the number of workers executing txs concurrently. A
+	// value of -1 means unlimited workers.
+	Workers int `mapstructure:"workers"`
+
+	// MaxIncarnations defines the number of optimistic execute/validate
+	// rounds after which the rest of a block is executed sequentially. 0
+	// uses the scheduler's default.
+	MaxIncarnations int `mapstructure:"max-incarnations"`
+
+	// FallbackPolicy defines what happens to txs that fail validation, either
+	// "wait" for the txs they conflict with, or "rerun" right away. Empty uses
+	// the scheduler's default.
+	FallbackPolicy string `mapstructure:"fallback-policy"`
+
+	// Telemetry defines the verbosity of OCC telemetry, either "full" or
+	// "batched" to emit it once per block. Empty uses "full".
+	Telemetry string `mapstructure:"telemetry"`
+}
+
+// ValidateBasic returns an error if the OCC configuration is invalid.
+func (c OCCConfig) ValidateBasic() error {
+	if c.Workers < -1 {
+		return sdkerrors.ErrAppConfig.Wrapf("invalid occ workers %d", c.Workers)
+	}
+	if c.MaxIncarnations < 0 {
+		return sdkerrors.ErrAppConfig.Wrapf("invalid occ max-incarnations %d", c.MaxIncarnations)
+	}
+	switch c.FallbackPolicy {
+	case "", OCCFallbackPolicyWait, OCCFallbackPolicyRerun:
+	default:
+		return sdkerrors.ErrAppConfig.Wrapf("invalid occ fallback-policy %q", c.FallbackPolicy)
+	}
+	switch c.Telemetry {
+	case "", OCCTelemetryFull, OCCTelemetryBatched:
+	default:
+		return sdkerrors.ErrAppConfig.Wrapf("invalid occ telemetry %q", c.Telemetry)
+	}
+	return nil
+}
+
 // Config defines the server's top level configuration
 type Config struct {
 	BaseConfig `mapstructure:",squash"`
@@ -205,6 +268,7 @@ type Config struct {
 	StateSync   StateSyncConfig          `mapstructure:"state-sync"`
 	StateCommit config.StateCommitConfig `mapstructure:"state-commit"`
 	StateStore  config.StateStoreConfig  `mapstructure:"state-store"`
+	OCC         OCCConfig                `mapstructure:"occ"`
 }
 
 // SetMinGasPrices sets the validator's minimum gas prices.
@@ -288,6 +352,12 @@ func DefaultConfig() *Config {
 		},
 		StateCommit: config.DefaultStateCommitConfig(),
 		StateStore:  config.DefaultStateStoreConfig(),
+		OCC: OCCConfig{
+			Enable:         DefaultOccEnabled,
+			Workers:        DefaultConcurrencyWorkers,
+			FallbackPolicy: OCCFallbackPolicyWait,
+			Telemetry:      OCCTelemetryFull,
+		},
 	}
 }
 
@@ -391,9 +461,38 @@ func GetConfig(v *viper.Viper) (Config, error) {
 			PruneIntervalSeconds: v.GetInt("state-store.prune-interval-seconds"),
 			ImportNumWorkers:     v.GetInt("state-store.import-num-workers"),
 		},
+		OCC: GetOCCConfig(v),
 	}, nil
 }
 
+// GetOCCConfig returns the OCC configuration from the [occ] section of the
+// given options, falling back to the occ-enabled and occ-workers flags and the
+// deprecated concurrency-workers setting for the settings the section doesn't
+// set, so that app.toml files from before the section keep working.
+func GetOCCConfig(opts interface{ Get(string) interface{} }) OCCConfig {
+	enable := opts.Get("occ.enable")
+	if enable == nil {
+		enable = opts.Get("occ-enabled")
+	}
+	workers := cast.ToInt(opts.Get("occ.workers"))
+	if workers == 0 {
+		workers = cast.ToInt(opts.Get("occ-workers"))
+	}
+	if workers == 0 {
+		workers = cast.ToInt(opts.Get("concurrency-workers"))
+	}
+	if workers == 0 {
+		workers = DefaultConcurrencyWorkers
+	}
+	return OCCConfig{
+		Enable:          cast.ToBool(enable),
+		Workers:         workers,
+		MaxIncarnations: cast.ToInt(opts.Get("occ.max-incarnations")),
+		FallbackPolicy:  cast.ToString(opts.Get("occ.fallback-policy")),
+		Telemetry:       cast.ToString(opts.Get("occ.telemetry")),
+	}
+}
+
 // ValidateBasic returns an error if min-gas-prices field is empty in BaseConfig. Otherwise, it returns nil.
 func (c Config) ValidateBasic(tendermintConfig *tmcfg.Config) error {
 	if c.BaseConfig.MinGasPrices == "" {
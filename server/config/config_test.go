@@ -1,8 +1,10 @@
 package config
 
 import (
+	"path/filepath"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
@@ -36,3 +38,57 @@ func TestOCCEnabled(t *testing.T) {
 	cfg.BaseConfig.OccEnabled = true
 	require.True(t, cfg.OccEnabled)
 }
+
+func TestOCCConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	require.Equal(t, DefaultOccEnabled, cfg.OCC.Enable)
+	require.Equal(t, DefaultConcurrencyWorkers, cfg.OCC.Workers)
+	require.NoError(t, cfg.OCC.ValidateBasic())
+
+	// the deprecated settings are used if the [occ] section doesn't set them
+	v := viper.New()
+	v.Set("occ-enabled", true)
+	v.Set("concurrency-workers", 5)
+	require.Equal(t, OCCConfig{Enable: true, Workers: 5}, GetOCCConfig(v))
+	v.Set("occ-workers", 6)
+	require.Equal(t, OCCConfig{Enable: true, Workers: 6}, GetOCCConfig(v))
+
+	v.Set("occ.enable", false)
+	v.Set("occ.workers", 8)
+	v.Set("occ.max-incarnations", 3)
+	v.Set("occ.fallback-policy", OCCFallbackPolicyRerun)
+	v.Set("occ.telemetry", OCCTelemetryBatched)
+	require.Equal(t, OCCConfig{
+		Enable:          false,
+		Workers:         8,
+		MaxIncarnations: 3,
+		FallbackPolicy:  OCCFallbackPolicyRerun,
+		Telemetry:       OCCTelemetryBatched,
+	}, GetOCCConfig(v))
+
+	require.Equal(t, DefaultConcurrencyWorkers, GetOCCConfig(viper.New()).Workers)
+}
+
+func TestOCCConfigTemplate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.OCC = OCCConfig{Enable: true, Workers: 12, MaxIncarnations: 4, FallbackPolicy: OCCFallbackPolicyRerun, Telemetry: OCCTelemetryBatched}
+	path := filepath.Join(t.TempDir(), "app.toml")
+	WriteConfigFile(path, cfg)
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	require.NoError(t, v.ReadInConfig())
+	require.Equal(t, cfg.OCC, GetOCCConfig(v))
+}
+
+func TestOCCConfigValidateBasic(t *testing.T) {
+	for _, cfg := range []OCCConfig{
+		{Workers: -2},
+		{MaxIncarnations: -1},
+		{FallbackPolicy: "sequential"},
+		{Telemetry: "verbose"},
+	} {
+		require.Error(t, cfg.ValidateBasic(), "%+v", cfg)
+	}
+	require.NoError(t, OCCConfig{Workers: -1}.ValidateBasic())
+}
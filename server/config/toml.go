@@ -104,11 +104,6 @@ num-orphan-per-file = {{ .BaseConfig.NumOrphanPerFile }}
 # if separate-orphan-storage is true, where to store orphan data
 orphan-dir = "{{ .BaseConfig.OrphanDirectory }}"
 
-# concurrency-workers defines how many workers to run for concurrent transaction execution
-# concurrency-workers = {{ .BaseConfig.ConcurrencyWorkers }}
-
-# occ-enabled defines whether OCC is enabled or not for transaction execution
-occ-enabled = {{ .BaseConfig.OccEnabled }}
 
 ###############################################################################
 ###                         Telemetry Configuration                         ###
@@ -246,6 +241,35 @@ snapshot-keep-recent = {{ .StateSync.SnapshotKeepRecent }}
 # default is emtpy which will then store under the app home directory same as before.
 snapshot-directory = "{{ .StateSync.SnapshotDirectory }}"
 
+###############################################################################
+###                            OCC Configuration                            ###
+###############################################################################
+
+# Optimistic concurrency control (OCC) for transaction execution. Changes to
+# this section are applied at the next block without restarting the node. The
+# section takes precedence over the deprecated occ-enabled and
+# concurrency-workers settings.
+[occ]
+
+# enable defines whether OCC is enabled or not for transaction execution
+enable = {{ .OCC.Enable }}
+
+# workers defines how many workers to run for concurrent transaction execution
+# (-1 for unlimited)
+workers = {{ .OCC.Workers }}
+
+# max-incarnations defines the number of optimistic execute/validate rounds
+# after which the rest of a block is executed sequentially (0 for the default)
+max-incarnations = {{ .OCC.MaxIncarnations }}
+
+# fallback-policy defines what happens to transactions that fail validation:
+# "wait" for the transactions they conflict with, or "rerun" right away
+fallback-policy = "{{ .OCC.FallbackPolicy }}"
+
+# telemetry defines the verbosity of OCC telemetry: "full", or "batched" to
+# emit it once per block
+telemetry = "{{ .OCC.Telemetry }}"
+
 ` + config.DefaultConfigTemplate
 
 var configTemplate *template.Template
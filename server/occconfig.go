@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/spf13/viper"
+	tmlog "github.com/tendermint/tendermint/libs/log"
+
+	"github.com/cosmos/cosmos-sdk/server/config"
+	"github.com/cosmos/cosmos-sdk/server/types"
+)
+
+// occConfigPollInterval is how often the node checks app.toml for changes to the [occ] section
+const occConfigPollInterval = 5 * time.Second
+
+// WatchOCCConfig watches the [occ] section of the app.toml file at path until ctx is done, passing it to the updater
+// every time it changes. The file is polled rather than watched through filesystem events, so that files replaced
+// by editors or configuration management are followed too. Configurations that fail to be read or applied are logged
+// and skipped, leaving the app with the last one that was applied.
+func WatchOCCConfig(ctx context.Context, logger tmlog.Logger, path string, interval time.Duration, updater types.OCCConfigUpdater) {
+	lastModTime, _ := modTime(path)
+	last, _ := readOCCConfig(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		modified, err := modTime(path)
+		if err != nil || modified.Equal(lastModTime) {
+			continue
+		}
+		lastModTime = modified
+		cfg, err := readOCCConfig(path)
+		if err != nil {
+			logger.Error("failed to read OCC config", "path", path, "err", err)
+			continue
+		}
+		if cfg == last {
+			continue
+		}
+		if err := updater.UpdateOCCConfig(cfg); err != nil {
+			logger.Error("failed to update OCC config", "path", path, "err", err)
+			continue
+		}
+		last = cfg
+		logger.Info("OCC config changed, applying it from the next block", "path", path)
+	}
+}
+
+func modTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// readOCCConfig reads the OCC config from an app.toml file
+func readOCCConfig(path string) (config.OCCConfig, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return config.OCCConfig{}, err
+	}
+	return config.GetOCCConfig(v), nil
+}
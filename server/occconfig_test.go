@@ -0,0 +1,68 @@
+package server_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+
+	"github.com/cosmos/cosmos-sdk/server"
+	"github.com/cosmos/cosmos-sdk/server/config"
+)
+
+type recordingOCCConfigUpdater struct {
+	mtx     sync.Mutex
+	updates []config.OCCConfig
+}
+
+func (u *recordingOCCConfigUpdater) UpdateOCCConfig(cfg config.OCCConfig) error {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	u.updates = append(u.updates, cfg)
+	return nil
+}
+
+func (u *recordingOCCConfigUpdater) get() []config.OCCConfig {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	return append([]config.OCCConfig{}, u.updates...)
+}
+
+func TestWatchOCCConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.toml")
+	cfg := config.DefaultConfig()
+	config.WriteConfigFile(path, cfg)
+
+	updater := &recordingOCCConfigUpdater{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.WatchOCCConfig(ctx, log.NewNopLogger(), path, 10*time.Millisecond, updater)
+
+	// changes outside of the [occ] section aren't passed on
+	time.Sleep(50 * time.Millisecond)
+	cfg.MinGasPrices = "1usei"
+	config.WriteConfigFile(path, cfg)
+	bumpModTime(t, path, time.Second)
+	time.Sleep(50 * time.Millisecond)
+	require.Empty(t, updater.get())
+
+	cfg.OCC.Enable = true
+	cfg.OCC.Workers = 4
+	cfg.OCC.FallbackPolicy = config.OCCFallbackPolicyRerun
+	config.WriteConfigFile(path, cfg)
+	bumpModTime(t, path, 2*time.Second)
+	require.Eventually(t, func() bool { return len(updater.get()) == 1 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, cfg.OCC, updater.get()[0])
+}
+
+// bumpModTime moves the modification time of a file forward, since writes in quick succession may not change it on
+// filesystems with coarse timestamps
+func bumpModTime(t *testing.T, path string, by time.Duration) {
+	now := time.Now().Add(by)
+	require.NoError(t, os.Chtimes(path, now, now))
+}
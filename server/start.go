@@ -71,6 +71,8 @@ const (
 	FlagNumOrphanPerFile             = "num-orphan-per-file"
 	FlagOrphanDirectory              = "orphan-dir"
 	FlagConcurrencyWorkers           = "concurrency-workers"
+	FlagOccEnabled                   = "occ-enabled"
+	FlagOccWorkers                   = "occ-workers"
 
 	// state sync-related flags
 	FlagStateSyncSnapshotInterval   = "state-sync.snapshot-interval"
@@ -254,6 +256,8 @@ is performed. Note, when enabled, gRPC will also be automatically enabled.
 	cmd.Flags().Int(FlagNumOrphanPerFile, 100000, "Number of orphans to store on each file if storing orphans separately")
 	cmd.Flags().String(FlagOrphanDirectory, path.Join(defaultNodeHome, "orphans"), "Directory to store orphan files if storing orphans separately")
 	cmd.Flags().Int(FlagConcurrencyWorkers, config.DefaultConcurrencyWorkers, "Number of workers to process concurrent transactions")
+	cmd.Flags().Bool(FlagOccEnabled, config.DefaultOccEnabled, "Whether to execute the transactions of blocks with optimistic concurrency control (OCC)")
+	cmd.Flags().Int(FlagOccWorkers, 0, "Number of workers to execute transactions with under OCC (0 for concurrency-workers)")
 
 	cmd.Flags().Bool(flagGRPCOnly, false, "Start the node in gRPC query only mode (no Tendermint process is started)")
 	cmd.Flags().Bool(flagGRPCEnable, true, "Define if the gRPC server should be enabled")
@@ -371,7 +375,14 @@ func startInProcess(
 			"This defaults to 0 in the current version, but will error in the next version " +
 			"(SDK v0.45). Please explicitly put the desired minimum-gas-prices in your app.toml.")
 	}
+	if err := config.OCC.ValidateBasic(); err != nil {
+		return err
+	}
 	app := appCreator(ctx.Logger, db, traceWriter, ctx.Config, ctx.Viper)
+	if updater, ok := app.(types.OCCConfigUpdater); ok {
+		appCfgFilePath := path.Join(home, "config", "app.toml")
+		go WatchOCCConfig(goCtx, ctx.Logger, appCfgFilePath, occConfigPollInterval, updater)
+	}
 
 	var (
 		tmNode    service.Service
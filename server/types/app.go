@@ -62,6 +62,13 @@ type (
 		Close() error
 	}
 
+	// OCCConfigUpdater is implemented by applications that apply changes to
+	// the [occ] section of app.toml without a restart. The server passes them
+	// the section whenever the file changes.
+	OCCConfigUpdater interface {
+		UpdateOCCConfig(config.OCCConfig) error
+	}
+
 	// AppCreator is a function that allows us to lazily initialize an
 	// application using various configurations.
 	AppCreator func(log.Logger, dbm.DB, io.Writer, *tmcfg.Config, AppOptions) Application
@@ -55,6 +55,11 @@ func NewFromKVStore(
 	}
 
 	for key, store := range stores {
+		if _, ok := store.(types.KVStore); !ok {
+			// stores that aren't KV stores branch themselves, without tracing or listening
+			cms.stores[key] = store.CacheWrap(key)
+			continue
+		}
 		if cms.TracingEnabled() {
 			store = tracekv.NewStore(store.(types.KVStore), cms.traceWriter, cms.traceContext)
 		}
@@ -83,7 +88,7 @@ func newCacheMultiStoreFromCMS(cms Store) Store {
 		stores[k] = v
 	}
 
-	return NewFromKVStore(cms.db, stores, nil, cms.traceWriter, cms.traceContext, nil)
+	return NewFromKVStore(cms.db, stores, cms.keys, cms.traceWriter, cms.traceContext, nil)
 }
 
 // SetTracer sets the tracer for the MultiStore that the underlying
@@ -218,10 +223,14 @@ func (cms Store) StoreKeys() []types.StoreKey {
 	return keys
 }
 
-// SetKVStores sets the underlying KVStores via a handler for each key
+// SetKVStores sets the underlying KVStores via a handler for each key. Stores that aren't KV stores are passed to the
+// handler as nil, and stores the handler returns nil for are kept as they are.
 func (cms Store) SetKVStores(handler func(sk types.StoreKey, s types.KVStore) types.CacheWrap) types.MultiStore {
 	for k, s := range cms.stores {
-		cms.stores[k] = handler(k, s.(types.KVStore))
+		kvs, _ := s.(types.KVStore)
+		if cw := handler(k, kvs); cw != nil {
+			cms.stores[k] = cw
+		}
 	}
 	return cms
 }
@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
 	"github.com/cosmos/cosmos-sdk/store/types"
 	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
 )
 
 func TestStoreGetKVStore(t *testing.T) {
@@ -21,3 +23,47 @@ func TestStoreGetKVStore(t *testing.T) {
 	require.PanicsWithValue(errMsg,
 		func() { s.GetKVStore(key) })
 }
+
+func TestStoreBranchKeepsStoreKeys(t *testing.T) {
+	key := types.NewKVStoreKey("abc")
+	db := dbm.NewMemDB()
+	s := NewStore(db, map[types.StoreKey]types.CacheWrapper{key: dbadapter.Store{DB: db}}, map[string]types.StoreKey{key.Name(): key}, nil, nil, nil)
+	require.Equal(t, []types.StoreKey{key}, s.StoreKeys())
+	require.Equal(t, []types.StoreKey{key}, s.CacheMultiStore().StoreKeys())
+}
+
+type cacheWrap interface {
+	types.CacheWrap
+}
+
+// objectStore is a branched store that isn't a KV store
+type objectStore struct {
+	cacheWrap
+}
+
+func TestStoreSetKVStoresPassesNilForNonKVStores(t *testing.T) {
+	kvKey, objectKey := types.NewKVStoreKey("kv"), types.NewKVStoreKey("object")
+	db := dbm.NewMemDB()
+	s := NewStore(db, map[types.StoreKey]types.CacheWrapper{kvKey: dbadapter.Store{DB: db}}, map[string]types.StoreKey{kvKey.Name(): kvKey}, nil, nil, nil)
+	object := objectStore{}
+	s.stores[objectKey] = object
+
+	handled := make(map[types.StoreKey]types.KVStore)
+	s.SetKVStores(func(key types.StoreKey, kvs types.KVStore) types.CacheWrap {
+		handled[key] = kvs
+		if kvs == nil {
+			return object
+		}
+		return kvs.(types.CacheWrap)
+	})
+	require.NotNil(t, handled[kvKey])
+	require.Contains(t, handled, objectKey)
+	require.Nil(t, handled[objectKey])
+	require.Equal(t, object, s.stores[objectKey])
+
+	// stores the handler returns nil for are kept
+	kv := s.stores[kvKey]
+	s.SetKVStores(func(types.StoreKey, types.KVStore) types.CacheWrap { return nil })
+	require.Equal(t, kv, s.stores[kvKey])
+	require.Equal(t, object, s.stores[objectKey])
+}
@@ -0,0 +1,147 @@
+package multiversion
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// AccessOp is a kind of access to a version indexed store recorded in an AccessLog
+type AccessOp int
+
+const (
+	AccessGet AccessOp = iota
+	AccessHas
+	AccessSet
+	AccessDelete
+	AccessIterate
+	AccessReverseIterate
+)
+
+func (op AccessOp) String() string {
+	switch op {
+	case AccessGet:
+		return "get"
+	case AccessHas:
+		return "has"
+	case AccessSet:
+		return "set"
+	case AccessDelete:
+		return "delete"
+	case AccessIterate:
+		return "iterate"
+	case AccessReverseIterate:
+		return "reverse_iterate"
+	default:
+		return fmt.Sprintf("AccessOp(%d)", int(op))
+	}
+}
+
+// AccessRecord is a single access of a tx execution to a version indexed store
+type AccessRecord struct {
+	Store       string
+	Index       int
+	Incarnation int
+	Op          AccessOp
+	// Key is the key accessed, or the start of the range iterated over
+	Key []byte
+	// End is the end of the range iterated over, if Op is an iteration
+	End []byte
+}
+
+// AccessLog retains the most recent accesses of the version indexed stores of the multiversion stores it's set on,
+// up to its capacity, eg. to find out which incarnations of which txs wrote a key when diagnosing a block. It locks on
+// every access of every store, so it's intended for debug builds and incident investigations rather than production
+// throughput.
+type AccessLog struct {
+	mtx     sync.Mutex
+	records []AccessRecord
+	next    int
+	full    bool
+	dropped int
+}
+
+func NewAccessLog(capacity int) *AccessLog {
+	if capacity < 1 {
+		panic("access log capacity must be positive")
+	}
+	return &AccessLog{
+		records: make([]AccessRecord, capacity),
+	}
+}
+
+// WithAccessLog has the version indexed stores of the store append their accesses to the log, which may be shared by
+// the stores of a block
+func WithAccessLog(log *AccessLog) StoreOption {
+	return func(s *Store) {
+		s.accessLog = log
+	}
+}
+
+// append appends a record, overwriting the oldest record if the log is full
+func (l *AccessLog) append(record AccessRecord) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.full {
+		l.dropped++
+	}
+	l.records[l.next] = record
+	l.next = (l.next + 1) % len(l.records)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Records returns the retained access records, oldest first
+func (l *AccessLog) Records() []AccessRecord {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if !l.full {
+		return append([]AccessRecord{}, l.records[:l.next]...)
+	}
+	return append(append([]AccessRecord{}, l.records[l.next:]...), l.records[:l.next]...)
+}
+
+// Writers returns the retained sets and deletes of a key of a store, oldest first
+func (l *AccessLog) Writers(storeName string, key []byte) []AccessRecord {
+	var writers []AccessRecord
+	for _, record := range l.Records() {
+		if record.Store == storeName && (record.Op == AccessSet || record.Op == AccessDelete) && string(record.Key) == string(key) {
+			writers = append(writers, record)
+		}
+	}
+	return writers
+}
+
+// Dropped returns the number of records overwritten because the log was full
+func (l *AccessLog) Dropped() int {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.dropped
+}
+
+// Reset clears the log, eg. before the next block
+func (l *AccessLog) Reset() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.records = make([]AccessRecord, len(l.records))
+	l.next = 0
+	l.full = false
+	l.dropped = 0
+}
+
+// logAccess appends an access of the store to its access log, if any
+func (store *VersionIndexedStore) logAccess(op AccessOp, key []byte, end []byte) {
+	if store.accessLog == nil {
+		return
+	}
+	store.accessLog.append(AccessRecord{
+		Store:       store.storeName,
+		Index:       store.transactionIndex,
+		Incarnation: store.incarnation,
+		Op:          op,
+		Key:         types.CopyBytes(key),
+		End:         types.CopyBytes(end),
+	})
+}
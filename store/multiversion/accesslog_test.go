@@ -0,0 +1,56 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+	dbm "github.com/tendermint/tm-db"
+)
+
+func TestAccessLog(t *testing.T) {
+	log := multiversion.NewAccessLog(3)
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithStoreName("bank"), multiversion.WithAccessLog(log))
+
+	vis := mvs.VersionedIndexedStore(1, 2, make(chan occ.Abort, 1))
+	key := []byte("key")
+	vis.Set(key, []byte("value"))
+	// the log keeps a copy of the key
+	key[0] = 'K'
+	vis.Get([]byte("key"))
+	vis.Delete([]byte("key"))
+	require.Equal(t, []multiversion.AccessRecord{
+		{Store: "bank", Index: 1, Incarnation: 2, Op: multiversion.AccessSet, Key: []byte("key")},
+		{Store: "bank", Index: 1, Incarnation: 2, Op: multiversion.AccessGet, Key: []byte("key")},
+		{Store: "bank", Index: 1, Incarnation: 2, Op: multiversion.AccessDelete, Key: []byte("key")},
+	}, log.Records())
+	require.Zero(t, log.Dropped())
+
+	// the oldest records are dropped once the log is full
+	vis = mvs.VersionedIndexedStore(3, 0, make(chan occ.Abort, 1))
+	vis.Has([]byte("other"))
+	vis.ReverseIterator([]byte("a"), []byte("z")).Close()
+	records := log.Records()
+	require.Len(t, records, 3)
+	require.Equal(t, multiversion.AccessDelete, records[0].Op)
+	require.Equal(t, multiversion.AccessRecord{Store: "bank", Index: 3, Op: multiversion.AccessHas, Key: []byte("other")}, records[1])
+	require.Equal(t, multiversion.AccessRecord{
+		Store: "bank", Index: 3, Op: multiversion.AccessReverseIterate, Key: []byte("a"), End: []byte("z"),
+	}, records[2])
+	require.Equal(t, 2, log.Dropped())
+	require.Equal(t, []multiversion.AccessRecord{records[0]}, log.Writers("bank", []byte("key")))
+	require.Empty(t, log.Writers("staking", []byte("key")))
+	require.Equal(t, "reverse_iterate", multiversion.AccessReverseIterate.String())
+
+	log.Reset()
+	require.Empty(t, log.Records())
+	require.Zero(t, log.Dropped())
+
+	require.Panics(t, func() {
+		multiversion.NewAccessLog(0)
+	})
+}
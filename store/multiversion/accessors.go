@@ -0,0 +1,61 @@
+package multiversion
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// RangeWritesetKeys calls fn with the writeset keys of every tx, in tx index order, until fn returns false. The keys
+// are copies, so that tooling can inspect the store while txs are being processed without racing with its writes.
+// Writesets set or removed during the iteration may or may not be observed.
+func (s *Store) RangeWritesetKeys(fn func(index int, keys []string) bool) {
+	for _, index := range sortedIndices(s.txWritesetKeys) {
+		keys := s.GetWritesetKeys(index)
+		if keys == nil {
+			continue
+		}
+		if !fn(index, keys) {
+			return
+		}
+	}
+}
+
+// RangeReadsets calls fn with the readset of every tx, in tx index order, until fn returns false. Like the readsets
+// returned by GetReadset, they are deep copies.
+func (s *Store) RangeReadsets(fn func(index int, readset ReadSet) bool) {
+	for _, index := range sortedIndices(s.txReadSets) {
+		readset := s.GetReadset(index)
+		if readset == nil {
+			continue
+		}
+		if !fn(index, readset) {
+			return
+		}
+	}
+}
+
+// sortedIndices returns the tx indices of a map keyed by tx index, sorted
+func sortedIndices(m *sync.Map) []int {
+	indices := []int{}
+	m.Range(func(key, value interface{}) bool {
+		indices = append(indices, key.(int))
+		return true
+	})
+	sort.Ints(indices)
+	return indices
+}
+
+// copyReadset returns a deep copy of a readset
+func copyReadset(readset ReadSet) ReadSet {
+	copied := make(ReadSet, len(readset))
+	for key, values := range readset {
+		copiedValues := make([][]byte, len(values))
+		for i, value := range values {
+			copiedValues[i] = types.CopyBytes(value)
+		}
+		copied[key] = copiedValues
+	}
+	return copied
+}
@@ -0,0 +1,132 @@
+package multiversion_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+func TestMultiVersionStoreRangeAccessors(t *testing.T) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	for _, index := range []int{3, 1, 2} {
+		key := fmt.Sprintf("key%d", index)
+		mvs.SetWriteset(index, 0, multiversion.WriteSet{key: []byte("value"), "shared": nil})
+		mvs.SetReadset(index, multiversion.ReadSet{"shared": {[]byte("read")}})
+	}
+
+	// txs are visited in index order, until the callback returns false
+	var indices []int
+	mvs.RangeWritesetKeys(func(index int, keys []string) bool {
+		indices = append(indices, index)
+		require.Equal(t, []string{fmt.Sprintf("key%d", index), "shared"}, keys)
+		return index < 2
+	})
+	require.Equal(t, []int{1, 2}, indices)
+	indices = nil
+	mvs.RangeReadsets(func(index int, readset multiversion.ReadSet) bool {
+		indices = append(indices, index)
+		return true
+	})
+	require.Equal(t, []int{1, 2, 3}, indices)
+
+	// modifying what is returned doesn't modify the store
+	mvs.GetWritesetKeys(1)[0] = "modified"
+	mvs.GetAllWritesetKeys()[2][0] = "modified"
+	readset := mvs.GetReadset(1)
+	readset["shared"][0][0] = 'x'
+	readset["other"] = [][]byte{nil}
+	mvs.RangeReadsets(func(index int, readset multiversion.ReadSet) bool {
+		readset["shared"] = nil
+		return true
+	})
+	require.Equal(t, []string{"key1", "shared"}, mvs.GetWritesetKeys(1))
+	require.Equal(t, []string{"key2", "shared"}, mvs.GetWritesetKeys(2))
+	require.Equal(t, multiversion.ReadSet{"shared": {[]byte("read")}}, mvs.GetReadset(1))
+}
+
+func TestMultiVersionStoreRangeAccessorsConcurrently(t *testing.T) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+
+	// writesets and readsets are replaced while being inspected, which must not race (see -race)
+	var wg sync.WaitGroup
+	for index := 0; index < 4; index++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			for incarnation := 0; incarnation < 100; incarnation++ {
+				mvs.SetWriteset(index, incarnation, multiversion.WriteSet{fmt.Sprintf("key%d", incarnation%3): []byte("value")})
+				mvs.SetReadset(index, multiversion.ReadSet{"read": {[]byte("value")}})
+			}
+		}(index)
+	}
+	for i := 0; i < 100; i++ {
+		mvs.RangeWritesetKeys(func(index int, keys []string) bool {
+			require.Len(t, keys, 1)
+			keys[0] = "modified"
+			return true
+		})
+		mvs.RangeReadsets(func(index int, readset multiversion.ReadSet) bool {
+			readset["read"][0][0] = 'x'
+			return true
+		})
+	}
+	wg.Wait()
+
+	for index, keys := range mvs.GetAllWritesetKeys() {
+		require.Equal(t, []string{"key0"}, keys, index)
+		require.Equal(t, multiversion.ReadSet{"read": {[]byte("value")}}, mvs.GetReadset(index))
+	}
+}
+
+func TestMultiVersionStoreWritesetKeysInRange(t *testing.T) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"a": []byte("a"), "b": nil, "c": []byte("c"), "e": []byte("e")})
+	mvs.SetEstimatedWriteset(2, 0, multiversion.WriteSet{"b": nil, "d": nil})
+
+	require.Equal(t, []string{"a", "b", "c", "e"}, mvs.WritesetKeysInRange(1, nil, nil))
+	require.Equal(t, []string{"b", "c"}, mvs.WritesetKeysInRange(1, []byte("b"), []byte("d")))
+	require.Equal(t, []string{"c", "e"}, mvs.WritesetKeysInRange(1, []byte("bb"), nil))
+	require.Equal(t, []string{"a"}, mvs.WritesetKeysInRange(1, nil, []byte("b")))
+	require.Equal(t, []string{"b", "d"}, mvs.WritesetKeysInRange(2, []byte("b"), []byte("e")))
+	require.Nil(t, mvs.WritesetKeysInRange(1, []byte("f"), nil))
+	require.Nil(t, mvs.WritesetKeysInRange(1, []byte("c"), []byte("b")))
+	require.Nil(t, mvs.WritesetKeysInRange(3, nil, nil))
+
+	// the keys returned are copies
+	keys := mvs.WritesetKeysInRange(1, nil, nil)
+	keys[0] = "z"
+	require.Equal(t, []string{"a", "b", "c", "e"}, mvs.GetWritesetKeys(1))
+
+	// the range follows changes to the writeset
+	mvs.SetWriteset(1, 1, multiversion.WriteSet{"c": []byte("c")})
+	require.Equal(t, []string{"c"}, mvs.WritesetKeysInRange(1, []byte("b"), []byte("d")))
+	mvs.RemoveEstimatesForIndex(2)
+	require.Nil(t, mvs.WritesetKeysInRange(2, nil, nil))
+}
+
+func TestMultiVersionStoreLatestEstimate(t *testing.T) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	_, _, found := mvs.LatestEstimate()
+	require.False(t, found)
+
+	mvs.SetEstimatedWriteset(1, 0, multiversion.WriteSet{"b": nil, "c": nil})
+	mvs.SetEstimatedWriteset(2, 0, multiversion.WriteSet{"d": nil})
+	// estimates superseded by a later write aren't latest
+	mvs.SetWriteset(3, 0, multiversion.WriteSet{"b": []byte("value")})
+	key, index, found := mvs.LatestEstimate()
+	require.True(t, found)
+	require.Equal(t, []byte("c"), key)
+	require.Equal(t, 1, index)
+
+	mvs.RemoveEstimatesForIndex(1)
+	key, index, found = mvs.LatestEstimate()
+	require.True(t, found)
+	require.Equal(t, []byte("d"), key)
+	require.Equal(t, 2, index)
+}
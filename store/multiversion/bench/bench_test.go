@@ -0,0 +1,102 @@
+package bench_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion/bench"
+)
+
+// Run these with eg. -bench . -benchmem -cpuprofile cpu.out, or profile a whole block with cmd/mvsbench. Setup is
+// excluded from the timings, so every benchmark measures a single phase of processing a block.
+
+const benchWorkers = 8
+
+func benchmarkWorkloads(b *testing.B, phase func(b *testing.B, block *bench.Block)) {
+	for _, w := range bench.Workloads {
+		w := w
+		b.Run(w.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				block := bench.NewBlock(w, int64(i))
+				b.StartTimer()
+				phase(b, block)
+			}
+		})
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	benchmarkWorkloads(b, func(b *testing.B, block *bench.Block) {
+		for index, tx := range block.Txs {
+			block.Store.SetWriteset(index, 0, tx.Writes)
+		}
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	benchmarkWorkloads(b, func(b *testing.B, block *bench.Block) {
+		b.StopTimer()
+		for index, tx := range block.Txs {
+			block.Store.SetWriteset(index, 0, tx.Writes)
+		}
+		b.StartTimer()
+		for index, tx := range block.Txs {
+			for _, key := range tx.Reads {
+				block.Store.GetLatestBeforeIndex(index, key)
+			}
+		}
+	})
+}
+
+func BenchmarkExecute(b *testing.B) {
+	benchmarkWorkloads(b, func(b *testing.B, block *bench.Block) {
+		block.ExecuteAll(benchWorkers)
+	})
+}
+
+func BenchmarkValidate(b *testing.B) {
+	benchmarkWorkloads(b, func(b *testing.B, block *bench.Block) {
+		b.StopTimer()
+		block.ExecuteAll(benchWorkers)
+		b.StartTimer()
+		block.ValidateAll()
+	})
+}
+
+func BenchmarkWriteLatest(b *testing.B) {
+	benchmarkWorkloads(b, func(b *testing.B, block *bench.Block) {
+		b.StopTimer()
+		block.ExecuteAll(benchWorkers)
+		b.StartTimer()
+		block.WriteLatest()
+	})
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	for _, w := range bench.Workloads {
+		require.Equal(t, w.Generate(1), w.Generate(1), w.Name)
+		require.NotEqual(t, w.Generate(1), w.Generate(2), w.Name)
+	}
+}
+
+func TestRunMatchesSequential(t *testing.T) {
+	w := bench.ZipfianHotKeys
+	w.Txs, w.Keys = 200, 1000
+
+	parallel := bench.NewBlock(w, 1)
+	result := parallel.Run(benchWorkers)
+	require.Equal(t, w.Name, result.Workload)
+	require.GreaterOrEqual(t, result.Rounds, 1)
+
+	sequential := bench.NewBlock(w, 1)
+	sequential.ExecuteAll(1)
+	require.Empty(t, sequential.ValidateAll())
+	sequential.WriteLatest()
+
+	for i := 0; i < w.Keys; i++ {
+		require.Equal(t, sequential.Parent.Get(bench.Key(i)), parallel.Parent.Get(bench.Key(i)))
+	}
+}
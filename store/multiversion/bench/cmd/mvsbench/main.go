@@ -0,0 +1,97 @@
+// mvsbench runs the workloads of the multiversion store benchmark suite, optionally writing CPU and heap profiles, eg.
+//
+//	go run ./store/multiversion/bench/cmd/mvsbench -workload zipfian -iterations 20 -cpuprofile cpu.out
+//	go tool pprof -http :8080 cpu.out
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion/bench"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var names []string
+	for _, w := range bench.Workloads {
+		names = append(names, w.Name)
+	}
+	workload := flag.String("workload", "all", "workload to run, all or one of "+strings.Join(names, ", "))
+	txs := flag.Int("txs", 0, "number of txs per block, overriding the workload's")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of workers executing the txs of a block")
+	iterations := flag.Int("iterations", 10, "number of blocks to run per workload")
+	seed := flag.Int64("seed", 1, "seed of the first block, incremented for every block")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile of the runs to this file")
+	memProfile := flag.String("memprofile", "", "write a heap profile to this file after the runs")
+	flag.Parse()
+
+	if *iterations < 1 {
+		return fmt.Errorf("invalid -iterations %d, expected at least 1", *iterations)
+	}
+	workloads := bench.Workloads
+	if *workload != "all" {
+		w, ok := bench.WorkloadByName(*workload)
+		if !ok {
+			return fmt.Errorf("unknown workload %q, expected all or one of %s", *workload, strings.Join(names, ", "))
+		}
+		workloads = []bench.Workload{w}
+	}
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return err
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	for _, w := range workloads {
+		if *txs > 0 {
+			w.Txs = *txs
+		}
+		var total bench.Result
+		for i := 0; i < *iterations; i++ {
+			// generating the block isn't profiled apart from the runs, but it's excluded from the timings
+			result := bench.NewBlock(w, *seed+int64(i)).Run(*workers)
+			total.Execute += result.Execute
+			total.Validate += result.Validate
+			total.Reexecute += result.Reexecute
+			total.WriteLatest += result.WriteLatest
+			total.Reexecutions += result.Reexecutions
+			total.Rounds += result.Rounds
+		}
+		n := time.Duration(*iterations)
+		fmt.Printf("%-14s execute %12v  validate %12v  reexecute %12v  write latest %12v  reexecutions %8.1f  rounds %5.1f\n",
+			w.Name, total.Execute/n, total.Validate/n, total.Reexecute/n, total.WriteLatest/n,
+			float64(total.Reexecutions)/float64(*iterations), float64(total.Rounds)/float64(*iterations))
+	}
+
+	if *memProfile != "" {
+		f, err := os.Create(*memProfile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
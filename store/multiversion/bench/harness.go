@@ -0,0 +1,137 @@
+package bench
+
+import (
+	"sync"
+	"time"
+
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// Block is the txs of a workload against a multiversion store over a parent store populated with its keyspace
+type Block struct {
+	Workload Workload
+	Txs      []Tx
+	Parent   dbadapter.Store
+	Store    *multiversion.Store
+}
+
+// NewBlock generates the txs of the workload from seed, and populates a parent store with its keyspace
+func NewBlock(w Workload, seed int64, opts ...multiversion.StoreOption) *Block {
+	parent := dbadapter.Store{DB: dbm.NewMemDB()}
+	value := make([]byte, w.ValueSize)
+	for i := 0; i < w.Keys; i++ {
+		parent.Set(Key(i), value)
+	}
+	return &Block{
+		Workload: w,
+		Txs:      w.Generate(seed),
+		Parent:   parent,
+		Store:    multiversion.NewMultiVersionStore(parent, opts...),
+	}
+}
+
+// Execute executes the tx at index against a version indexed store, reading its keys and writing its writes, and
+// writes its readset and writeset to the multiversion store
+func (b *Block) Execute(index int, incarnation int) {
+	tx := b.Txs[index]
+	vis := b.Store.VersionedIndexedStore(index, incarnation, make(chan occ.Abort, 1))
+	for _, key := range tx.Reads {
+		vis.Get(key)
+	}
+	for key, value := range tx.Writes {
+		if value == nil {
+			vis.Delete([]byte(key))
+			continue
+		}
+		vis.Set([]byte(key), value)
+	}
+	vis.WriteToMultiVersionStore()
+}
+
+// ExecuteAll executes every tx on the given number of workers, so that txs may observe stale versions and fail
+// validation, like in the first iteration of the scheduler
+func (b *Block) ExecuteAll(workers int) {
+	indexes := make(chan int, len(b.Txs))
+	for i := range b.Txs {
+		indexes <- i
+	}
+	close(indexes)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				b.Execute(i, 0)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// ValidateAll validates every tx, returning the indexes of the invalid ones
+func (b *Block) ValidateAll() []int {
+	var invalid []int
+	for i := range b.Txs {
+		if valid, _ := b.Store.ValidateTransactionState(i); !valid {
+			invalid = append(invalid, i)
+		}
+	}
+	return invalid
+}
+
+// WriteLatest writes the latest versions of the multiversion store to the parent store
+func (b *Block) WriteLatest() {
+	b.Store.WriteLatestToStore()
+}
+
+// Result is the duration of every phase of a run of a block, summed over its rounds of validation
+type Result struct {
+	Workload string
+	Execute  time.Duration
+	Validate time.Duration
+	// Reexecute is the duration of the sequential re-executions of the txs that failed validation
+	Reexecute   time.Duration
+	WriteLatest time.Duration
+	// Reexecutions is the number of txs re-executed, and Rounds the number of rounds of validation
+	Reexecutions int
+	Rounds       int
+}
+
+// Run executes the block on the given number of workers, then validates it and re-executes the invalid txs in order
+// until every tx is valid, and writes it to the parent store, timing every phase
+func (b *Block) Run(workers int) Result {
+	result := Result{Workload: b.Workload.Name}
+
+	start := time.Now()
+	b.ExecuteAll(workers)
+	result.Execute = time.Since(start)
+
+	for {
+		start = time.Now()
+		invalid := b.ValidateAll()
+		result.Validate += time.Since(start)
+		result.Rounds++
+		if len(invalid) == 0 {
+			break
+		}
+
+		// the lowest invalid tx observes the final writes of the earlier ones once re-executed, so every round
+		// makes progress
+		start = time.Now()
+		for _, i := range invalid {
+			b.Execute(i, result.Rounds)
+		}
+		result.Reexecute += time.Since(start)
+		result.Reexecutions += len(invalid)
+	}
+
+	start = time.Now()
+	b.WriteLatest()
+	result.WriteLatest = time.Since(start)
+	return result
+}
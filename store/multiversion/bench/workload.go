@@ -0,0 +1,134 @@
+// Package bench provides synthetic workloads for benchmarking and profiling the multiversion store in the OCC path:
+// the blocks of txs they generate are executed against version indexed stores, validated and written to the parent
+// store, like the scheduler does, so that regressions of any of these phases show up in benchmarks and profiles.
+package bench
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+// Distribution is how the keys accessed by the txs of a workload are drawn from its keyspace
+type Distribution int
+
+const (
+	// DistributionUniform draws every key with the same probability
+	DistributionUniform Distribution = iota
+	// DistributionZipfian draws a few hot keys most of the time, like the pools and oracles of a busy chain
+	DistributionZipfian
+)
+
+func (d Distribution) String() string {
+	switch d {
+	case DistributionUniform:
+		return "uniform"
+	case DistributionZipfian:
+		return "zipfian"
+	default:
+		return fmt.Sprintf("distribution(%d)", int(d))
+	}
+}
+
+// Workload describes a block of txs accessing a keyspace
+type Workload struct {
+	Name string
+	// Txs is the number of txs of the block
+	Txs int
+	// Keys is the size of the keyspace, which is populated in the parent store before the block
+	Keys int
+	// ReadsPerTx and WritesPerTx are the number of keys read and written by every tx
+	ReadsPerTx  int
+	WritesPerTx int
+	// ValueSize is the size of the values written, and of the values in the parent store
+	ValueSize int
+	// DeleteRatio is the share of the writes that are deletes
+	DeleteRatio  float64
+	Distribution Distribution
+	// ZipfS is the skew of the zipfian distribution, which must be greater than 1
+	ZipfS float64
+}
+
+// Tx is the keys read by a tx and the keys it writes, with nil values for deletes
+type Tx struct {
+	Reads  [][]byte
+	Writes multiversion.WriteSet
+}
+
+var (
+	// Uniform is a block of small txs accessing a large keyspace uniformly, with few conflicts
+	Uniform = Workload{Name: "uniform", Txs: 1000, Keys: 100000, ReadsPerTx: 10, WritesPerTx: 5, ValueSize: 32}
+	// ZipfianHotKeys is a block whose txs mostly access a few hot keys, with many conflicts
+	ZipfianHotKeys = Workload{Name: "zipfian", Txs: 1000, Keys: 100000, ReadsPerTx: 10, WritesPerTx: 5, ValueSize: 32, Distribution: DistributionZipfian, ZipfS: 1.1}
+	// HeavyDeletes is a block whose txs mostly delete the keys they write, eg. pruning expired entries
+	HeavyDeletes = Workload{Name: "deletes", Txs: 1000, Keys: 100000, ReadsPerTx: 10, WritesPerTx: 10, ValueSize: 32, DeleteRatio: 0.8}
+	// LargeValues is a block of txs writing large values, eg. contract code and state blobs
+	LargeValues = Workload{Name: "large-values", Txs: 200, Keys: 10000, ReadsPerTx: 5, WritesPerTx: 2, ValueSize: 64 << 10}
+
+	// Workloads are the standard workloads
+	Workloads = []Workload{Uniform, ZipfianHotKeys, HeavyDeletes, LargeValues}
+)
+
+// WorkloadByName returns the standard workload with the given name
+func WorkloadByName(name string) (Workload, bool) {
+	for _, w := range Workloads {
+		if w.Name == name {
+			return w, true
+		}
+	}
+	return Workload{}, false
+}
+
+// Key returns the i-th key of a keyspace
+func Key(i int) []byte {
+	return []byte(fmt.Sprintf("key-%010d", i))
+}
+
+// Generate deterministically generates the txs of the workload from seed
+func (w Workload) Generate(seed int64) []Tx {
+	r := rand.New(rand.NewSource(seed))
+	draw := func() int { return r.Intn(w.Keys) }
+	if w.Distribution == DistributionZipfian {
+		zipf := rand.NewZipf(r, w.ZipfS, 1, uint64(w.Keys-1))
+		draw = func() int { return int(zipf.Uint64()) }
+	}
+
+	txs := make([]Tx, w.Txs)
+	for i := range txs {
+		reads := make(map[int]struct{}, w.ReadsPerTx)
+		for len(reads) < w.ReadsPerTx && len(reads) < w.Keys {
+			reads[draw()] = struct{}{}
+		}
+		txs[i].Reads = make([][]byte, 0, len(reads))
+		for _, k := range sortedKeys(reads) {
+			txs[i].Reads = append(txs[i].Reads, Key(k))
+		}
+
+		txs[i].Writes = make(multiversion.WriteSet, w.WritesPerTx)
+		for len(txs[i].Writes) < w.WritesPerTx && len(txs[i].Writes) < w.Keys {
+			key := string(Key(draw()))
+			if _, ok := txs[i].Writes[key]; ok {
+				continue
+			}
+			if r.Float64() < w.DeleteRatio {
+				txs[i].Writes[key] = nil
+				continue
+			}
+			value := make([]byte, w.ValueSize)
+			r.Read(value)
+			txs[i].Writes[key] = value
+		}
+	}
+	return txs
+}
+
+func sortedKeys(set map[int]struct{}) []int {
+	keys := make([]int, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
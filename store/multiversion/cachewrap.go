@@ -0,0 +1,214 @@
+package multiversion
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/listenkv"
+	"github.com/cosmos/cosmos-sdk/store/tracekv"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// cacheWrapStore branches a version indexed store (or another branch of one), eg. for a CacheContext within a tx.
+// Writes are buffered until Write applies them to the parent in key order, and are dropped if the branch is
+// discarded. Reads that aren't served from the buffered writes go through the parent, so the version indexed store
+// still tracks them in the tx's readset and iterateset whether or not the branch is written. It plays the role of a
+// cachekv.Store, which can't be used here since it depends on this package.
+type cacheWrapStore struct {
+	mtx    sync.Mutex
+	parent types.KVStore
+	// buffered writes by key, with nil values for deletes
+	writes map[string][]byte
+}
+
+var _ types.CacheKVStore = (*cacheWrapStore)(nil)
+
+func newCacheWrapStore(parent types.KVStore) *cacheWrapStore {
+	return &cacheWrapStore{
+		parent: parent,
+		writes: make(map[string][]byte),
+	}
+}
+
+// GetStoreType implements types.KVStore.
+func (c *cacheWrapStore) GetStoreType() types.StoreType {
+	return c.parent.GetStoreType()
+}
+
+// Get implements types.KVStore.
+func (c *cacheWrapStore) Get(key []byte) []byte {
+	types.AssertValidKey(key)
+	c.mtx.Lock()
+	value, ok := c.writes[string(key)]
+	c.mtx.Unlock()
+	if ok {
+		return types.CopyBytes(value)
+	}
+	return c.parent.Get(key)
+}
+
+// Has implements types.KVStore.
+func (c *cacheWrapStore) Has(key []byte) bool {
+	return c.Get(key) != nil
+}
+
+// Set implements types.KVStore.
+func (c *cacheWrapStore) Set(key []byte, value []byte) {
+	types.AssertValidKey(key)
+	types.AssertValidValue(value)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.writes[string(key)] = types.CopyBytes(value)
+}
+
+// Delete implements types.KVStore.
+func (c *cacheWrapStore) Delete(key []byte) {
+	types.AssertValidKey(key)
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.writes[string(key)] = nil
+}
+
+// Iterator implements types.KVStore.
+func (c *cacheWrapStore) Iterator(start []byte, end []byte) types.Iterator {
+	return c.iterator(start, end, true)
+}
+
+// ReverseIterator implements types.KVStore.
+func (c *cacheWrapStore) ReverseIterator(start []byte, end []byte) types.Iterator {
+	return c.iterator(start, end, false)
+}
+
+// iterator merges the parent's items with the buffered writes in the domain, as of the iterator's creation
+func (c *cacheWrapStore) iterator(start []byte, end []byte, ascending bool) types.Iterator {
+	var parent types.Iterator
+	if ascending {
+		parent = c.parent.Iterator(start, end)
+	} else {
+		parent = c.parent.ReverseIterator(start, end)
+	}
+	c.mtx.Lock()
+	writes := newWritesIterator(start, end, c.writes, ascending)
+	c.mtx.Unlock()
+	// the parent tracks its own reads
+	return NewMVSMergeIterator(parent, writes, ascending, NoOpHandler{})
+}
+
+// Write implements types.CacheWrap. It applies the buffered writes to the parent in key order.
+func (c *cacheWrapStore) Write() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	keys := make([]string, 0, len(c.writes))
+	for key := range c.writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value := c.writes[key]; value == nil {
+			c.parent.Delete([]byte(key))
+		} else {
+			c.parent.Set([]byte(key), value)
+		}
+	}
+	c.writes = make(map[string][]byte)
+}
+
+// GetEvents implements types.CacheWrap. The store doesn't record events.
+func (c *cacheWrapStore) GetEvents() []abci.Event {
+	return nil
+}
+
+// ResetEvents implements types.CacheWrap.
+func (c *cacheWrapStore) ResetEvents() {}
+
+// CacheWrap implements types.KVStore.
+func (c *cacheWrapStore) CacheWrap(_ types.StoreKey) types.CacheWrap {
+	return newCacheWrapStore(c)
+}
+
+// CacheWrapWithTrace implements types.KVStore.
+func (c *cacheWrapStore) CacheWrapWithTrace(_ types.StoreKey, w io.Writer, tc types.TraceContext) types.CacheWrap {
+	return newCacheWrapStore(tracekv.NewStore(c, w, tc))
+}
+
+// CacheWrapWithListeners implements types.KVStore.
+func (c *cacheWrapStore) CacheWrapWithListeners(storeKey types.StoreKey, listeners []types.WriteListener) types.CacheWrap {
+	return newCacheWrapStore(listenkv.NewStore(c, storeKey, listeners))
+}
+
+// GetWorkingHash implements types.KVStore.
+func (c *cacheWrapStore) GetWorkingHash() ([]byte, error) {
+	panic("should never attempt to get working hash from a branch of a version indexed store")
+}
+
+// writesIterator iterates over a sorted copy of buffered writes within a domain, with nil values for deletes
+type writesIterator struct {
+	start, end []byte
+	keys       []string
+	values     [][]byte
+	pos        int
+}
+
+var _ types.Iterator = (*writesIterator)(nil)
+
+func newWritesIterator(start, end []byte, writes map[string][]byte, ascending bool) *writesIterator {
+	keys := make([]string, 0, len(writes))
+	for key := range writes {
+		if start != nil && bytes.Compare([]byte(key), start) < 0 {
+			continue
+		}
+		if end != nil && bytes.Compare([]byte(key), end) >= 0 {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if ascending {
+		sort.Strings(keys)
+	} else {
+		sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	}
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = types.CopyBytes(writes[key])
+	}
+	return &writesIterator{start: start, end: end, keys: keys, values: values}
+}
+
+// Domain implements types.Iterator.
+func (wi *writesIterator) Domain() (start []byte, end []byte) {
+	return wi.start, wi.end
+}
+
+// Valid implements types.Iterator.
+func (wi *writesIterator) Valid() bool {
+	return wi.pos < len(wi.keys)
+}
+
+// Next implements types.Iterator.
+func (wi *writesIterator) Next() {
+	wi.pos++
+}
+
+// Key implements types.Iterator.
+func (wi *writesIterator) Key() []byte {
+	return []byte(wi.keys[wi.pos])
+}
+
+// Value implements types.Iterator.
+func (wi *writesIterator) Value() []byte {
+	return wi.values[wi.pos]
+}
+
+// Error implements types.Iterator.
+func (wi *writesIterator) Error() error {
+	return nil
+}
+
+// Close implements types.Iterator.
+func (wi *writesIterator) Close() error {
+	return nil
+}
@@ -0,0 +1,118 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// iterateAll returns the keys and values of an iterator, closing it
+func iterateAll(t *testing.T, iter types.Iterator) ([]string, []string) {
+	var keys, values []string
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+		values = append(values, string(iter.Value()))
+	}
+	require.NoError(t, iter.Close())
+	return keys, values
+}
+
+func TestVersionIndexedStoreCacheWrap(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("key1"), []byte("parent1"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key2": []byte("value2")})
+	vis := mvs.VersionedIndexedStore(2, 0, make(chan scheduler.Abort, 1))
+	vis.Set([]byte("key3"), []byte("value3"))
+
+	// reads fall through to the tx's store, which tracks them, while writes are buffered
+	branch := vis.CacheWrap(types.NewKVStoreKey("mock")).(types.CacheKVStore)
+	require.Equal(t, []byte("parent1"), branch.Get([]byte("key1")))
+	require.Equal(t, []byte("value3"), branch.Get([]byte("key3")))
+	branch.Set([]byte("key1"), []byte("branch1"))
+	branch.Delete([]byte("key2"))
+	branch.Set([]byte("key4"), []byte("branch4"))
+	require.Equal(t, []byte("branch1"), branch.Get([]byte("key1")))
+	require.False(t, branch.Has([]byte("key2")))
+	require.Equal(t, []byte("parent1"), vis.Get([]byte("key1")))
+	require.Equal(t, map[string][]byte{"key3": []byte("value3")}, vis.GetWriteset())
+
+	// iterators merge the buffered writes with the tx's store in both directions
+	keys, values := iterateAll(t, branch.Iterator(nil, nil))
+	require.Equal(t, []string{"key1", "key3", "key4"}, keys)
+	require.Equal(t, []string{"branch1", "value3", "branch4"}, values)
+	keys, _ = iterateAll(t, branch.ReverseIterator([]byte("key2"), []byte("key4")))
+	require.Equal(t, []string{"key3"}, keys)
+
+	// a nested branch is written into its parent branch only
+	nested := branch.CacheWrap(types.NewKVStoreKey("mock")).(types.CacheKVStore)
+	nested.Set([]byte("key5"), []byte("nested5"))
+	nested.Write()
+	require.Equal(t, []byte("nested5"), branch.Get([]byte("key5")))
+	require.Nil(t, vis.Get([]byte("key5")))
+
+	// writing the branch applies its writes to the tx's writeset
+	branch.Write()
+	require.Equal(t, map[string][]byte{
+		"key1": []byte("branch1"),
+		"key2": nil,
+		"key3": []byte("value3"),
+		"key4": []byte("branch4"),
+		"key5": []byte("nested5"),
+	}, vis.GetWriteset())
+	require.Equal(t, multiversion.ReadSet{
+		"key1": {[]byte("parent1")},
+		"key2": {[]byte("value2")},
+		"key5": {nil},
+	}, multiversion.ReadSet(vis.GetReadset()))
+}
+
+func TestVersionIndexedStoreCacheWrapDiscard(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": []byte("value1"), "key2": []byte("value2")})
+	vis := mvs.VersionedIndexedStore(3, 0, make(chan scheduler.Abort, 1))
+
+	// a failed sub-message reads a key and iterates over a range, and its branch is discarded
+	branch := vis.CacheWrap(types.NewKVStoreKey("mock"))
+	kv := branch.(types.KVStore)
+	require.Equal(t, []byte("value1"), kv.Get([]byte("key1")))
+	kv.Set([]byte("key1"), []byte("failed"))
+	keys, _ := iterateAll(t, kv.Iterator([]byte("key2"), []byte("key3")))
+	require.Equal(t, []string{"key2"}, keys)
+
+	// its writes are dropped, but what it observed still has to be validated
+	vis.WriteToMultiVersionStore()
+	require.Empty(t, mvs.GetWritesetKeys(3))
+	valid, _ := mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+	mvs.SetWriteset(2, 0, multiversion.WriteSet{"key2b": []byte("added")})
+	valid, _ = mvs.ValidateTransactionState(3)
+	require.False(t, valid)
+	mvs.SetWriteset(2, 0, multiversion.WriteSet{})
+	mvs.SetWriteset(1, 1, multiversion.WriteSet{"key1": []byte("changed"), "key2": []byte("value2")})
+	valid, conflicts := mvs.ValidateTransactionState(3)
+	require.False(t, valid)
+	require.Equal(t, []int{1}, conflicts)
+}
+
+func TestVersionIndexedStoreCacheWrapAbort(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	mvs.SetEstimatedWriteset(1, 0, multiversion.WriteSet{"key1": nil})
+	abortChannel := make(chan scheduler.Abort, 1)
+	vis := mvs.VersionedIndexedStore(2, 0, abortChannel)
+
+	// reading an estimate through a branch aborts the tx like reading it directly
+	branch := vis.CacheWrap(types.NewKVStoreKey("mock")).(types.KVStore)
+	require.Panics(t, func() { branch.Get([]byte("key1")) })
+	abort := <-abortChannel
+	require.Equal(t, 1, abort.DependentTxIdx)
+	require.Equal(t, []byte("key1"), abort.Key)
+}
@@ -0,0 +1,22 @@
+package multiversion
+
+import (
+	"github.com/cosmos/iavl"
+)
+
+// ChangeSet returns the final writes of the block to the store, sorted by key, as WriteLatestToStore writes them to
+// the parent store. It lets a commit pipeline apply the block's writes to a commitment store, eg. to compute the
+// working hash in the background while the next block executes, without traversing the parent store. The values are
+// shared with the store and must not be modified, and the changeset must be taken before the store is reset.
+func (s *Store) ChangeSet() iavl.ChangeSet {
+	var changeSet iavl.ChangeSet
+	s.forEachLatest(func(key string, mvValue MultiVersionValueItem) {
+		switch {
+		case mvValue.IsDeleted():
+			changeSet.Pairs = append(changeSet.Pairs, &iavl.KVPair{Key: []byte(key), Delete: true})
+		case mvValue.Value() != nil:
+			changeSet.Pairs = append(changeSet.Pairs, &iavl.KVPair{Key: []byte(key), Value: mvValue.Value()})
+		}
+	})
+	return changeSet
+}
@@ -0,0 +1,35 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/cosmos/iavl"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+func TestMultiVersionStoreChangeSet(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("key1"), []byte("value0"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	require.Empty(t, mvs.ChangeSet().Pairs)
+
+	mvs.SetWriteset(0, 1, map[string][]byte{"key3": []byte("value3"), "key1": []byte("value1")})
+	mvs.SetWriteset(1, 1, map[string][]byte{"key1": nil, "key2": []byte("value2")})
+	// estimates left without a final write aren't part of the changeset
+	mvs.SetEstimatedWriteset(2, 1, map[string][]byte{"key4": nil})
+	mvs.RemoveEstimatesForIndex(2)
+
+	// the changeset holds the latest write of every key, in key order, like the writes to the parent store
+	require.Equal(t, iavl.ChangeSet{Pairs: []*iavl.KVPair{
+		{Key: []byte("key1"), Delete: true},
+		{Key: []byte("key2"), Value: []byte("value2")},
+		{Key: []byte("key3"), Value: []byte("value3")},
+	}}, mvs.ChangeSet())
+	mvs.WriteLatestToStore()
+	require.False(t, parentKVStore.Has([]byte("key1")))
+	require.Equal(t, []byte("value2"), parentKVStore.Get([]byte("key2")))
+}
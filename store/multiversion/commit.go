@@ -0,0 +1,87 @@
+package multiversion
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// CommitBefore writes the final writes of the txs before index to the parent store in key order, like
+// WriteLatestToStore does for the whole block, and removes their versions from the store, which bounds the versions
+// held by a long block to the txs that aren't final yet. Txs from index onward read the committed writes from the
+// parent store instead, which holds the same values. Keys stay in the store once their versions are removed, so that
+// reads of them from the parent store aren't mistaken for mutations of it, and estimates are kept, so that they're
+// still found when the block is committed.
+//
+// The txs before index must be final, and none of them may be executed or validated again, since the versions written
+// before them are gone. For the same reason, committing can't be combined with GetSnapshotBeforeIndex below the
+// committed index or with WriteLatestToStoreWithListeners, and LatestWritesetHash and ChangeSet only cover the writes
+// that weren't committed. It must not be called while txs are executed or validated against the store. Committing an
+// index at or below the committed index has no effect. It returns the number of keys written to the parent store.
+func (s *Store) CommitBefore(index int) int {
+	from := s.CommittedIndex()
+	if index <= from {
+		return 0
+	}
+	keySet := make(map[string]struct{})
+	for i := from; i < index; i++ {
+		for _, key := range s.writesetKeys(i) {
+			keySet[key] = struct{}{}
+		}
+	}
+	keys := make([]string, 0, len(keySet))
+	for key := range keySet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	type commit struct {
+		key     string
+		item    MultiVersionValue
+		latest  MultiVersionValueItem
+		indices []int
+	}
+	commits := make([]commit, 0, len(keys))
+	for _, key := range keys {
+		val, ok := s.multiVersionMap.Load(key)
+		if !ok {
+			continue
+		}
+		c := commit{key: key, item: val.(MultiVersionValue)}
+		for _, version := range c.item.Versions() {
+			if version.Index() >= index {
+				break
+			}
+			if version.IsEstimate() {
+				continue
+			}
+			c.latest = version
+			c.indices = append(c.indices, version.Index())
+		}
+		if c.latest != nil {
+			commits = append(commits, c)
+		}
+	}
+
+	// write every key before removing any version, so that the versions are still there if the parent store panics
+	s.writeToParent(func(fn func(key string, value MultiVersionValueItem)) {
+		for _, c := range commits {
+			fn(c.key, c.latest)
+		}
+	})
+	for _, c := range commits {
+		for _, i := range c.indices {
+			c.item.Remove(i)
+		}
+	}
+	if s.readCache != nil {
+		s.readCache.evict(keys)
+	}
+	atomic.StoreInt64(&s.committedIndex, int64(index))
+	return len(commits)
+}
+
+// CommittedIndex returns the index below which the writes of the block were committed to the parent store, see
+// CommitBefore
+func (s *Store) CommittedIndex() int {
+	return int(atomic.LoadInt64(&s.committedIndex))
+}
@@ -0,0 +1,62 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestMultiVersionStoreCommitBefore(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("b"), []byte("parentB"))
+	parentKVStore.Set([]byte("c"), []byte("parentC"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+
+	mvs.SetWriteset(0, 0, multiversion.WriteSet{"a": []byte("a0"), "c": nil})
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"a": []byte("a1"), "b": []byte("b1")})
+	mvs.SetWriteset(2, 0, multiversion.WriteSet{"a": []byte("a2")})
+	mvs.SetEstimatedWriteset(3, 0, multiversion.WriteSet{"d": nil})
+	// tx 4 read the writes of txs 1 and 2, and tx 5 read the parent value tx 1 overwrote
+	mvs.SetReadset(4, multiversion.ReadSet{"a": [][]byte{[]byte("a2")}, "b": [][]byte{[]byte("b1")}})
+	mvs.SetReadset(5, multiversion.ReadSet{"b": [][]byte{[]byte("parentB")}})
+
+	require.Equal(t, 3, mvs.CommitBefore(2))
+	require.Equal(t, 2, mvs.CommittedIndex())
+	require.Equal(t, []byte("a1"), parentKVStore.Get([]byte("a")))
+	require.Equal(t, []byte("b1"), parentKVStore.Get([]byte("b")))
+	require.False(t, parentKVStore.Has([]byte("c")))
+
+	// the committed versions are gone, while later ones and estimates are kept
+	require.Nil(t, mvs.GetLatestBeforeIndex(2, []byte("a")))
+	require.Nil(t, mvs.GetLatestBeforeIndex(2, []byte("b")))
+	require.Equal(t, []byte("a2"), mvs.GetLatestBeforeIndex(3, []byte("a")).Value())
+	require.True(t, mvs.GetLatestBeforeIndex(4, []byte("d")).IsEstimate())
+
+	// reads of committed writes stay valid through the parent store, without being taken for mutations of it
+	valid, _ := mvs.ValidateTransactionState(4)
+	require.True(t, valid)
+	valid, _ = mvs.ValidateTransactionState(5)
+	require.False(t, valid)
+	require.NoError(t, mvs.ParentStateMutation())
+
+	// committing is idempotent and never goes backwards
+	require.Zero(t, mvs.CommitBefore(2))
+	require.Zero(t, mvs.CommitBefore(1))
+	require.Equal(t, 2, mvs.CommittedIndex())
+
+	// writesets can't be streamed once committed, but the remaining writes are still written
+	require.Panics(t, func() {
+		_ = mvs.WriteLatestToStoreWithListeners(types.NewKVStoreKey("test"), nil)
+	})
+	mvs.WriteLatestToStore()
+	require.Equal(t, []byte("a2"), parentKVStore.Get([]byte("a")))
+	require.Equal(t, []byte("b1"), parentKVStore.Get([]byte("b")))
+
+	mvs.Reset(parentKVStore)
+	require.Zero(t, mvs.CommittedIndex())
+}
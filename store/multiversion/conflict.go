@@ -0,0 +1,111 @@
+package multiversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ConflictReason describes why a later transaction conflicts with an earlier one
+type ConflictReason string
+
+const (
+	// ConflictStaleRead means the later tx read a value for a key that differs from what the earlier tx wrote
+	ConflictStaleRead ConflictReason = "stale-read"
+	// ConflictMultipleReads means the later tx read more than one value for a key, which always fails validation
+	ConflictMultipleReads ConflictReason = "multiple-read-values"
+)
+
+// TxAccessSet is the readset and writeset of a single transaction
+type TxAccessSet struct {
+	Readset  ReadSet  `json:"readset"`
+	Writeset WriteSet `json:"writeset"`
+}
+
+// Conflict is a single key that causes a later transaction to fail validation. Store is the name of the store the key
+// belongs to, and is empty for conflicts checked against a single store.
+type Conflict struct {
+	Store  string         `json:"store,omitempty"`
+	Key    string         `json:"key"`
+	Reason ConflictReason `json:"reason"`
+}
+
+// QualifiedKey returns the store qualified key of the conflict
+func (c Conflict) QualifiedKey() QualifiedKey {
+	return QualifiedKey{Store: c.Store, Key: c.Key}
+}
+
+func (c Conflict) String() string {
+	if c.Store != "" {
+		return fmt.Sprintf("%s: %s", c.QualifiedKey(), c.Reason)
+	}
+	return fmt.Sprintf("%X: %s", c.Key, c.Reason)
+}
+
+// CheckConflicts reports the keys for which the later transaction would fail validation if it were executed after the
+// earlier transaction, using the same rules as the multiversion store validation: a later read must match the value
+// written by the earlier tx (deletes are read as nil), and a key may only have been read with a single value. Writes by
+// both txs to the same key don't conflict since the later write wins. Conflicts are sorted by key.
+func CheckConflicts(earlier, later TxAccessSet) []Conflict {
+	var conflicts []Conflict
+	for key, values := range later.Readset {
+		if len(values) != 1 {
+			conflicts = append(conflicts, Conflict{Key: key, Reason: ConflictMultipleReads})
+			continue
+		}
+		written, ok := earlier.Writeset[key]
+		if !ok {
+			continue
+		}
+		if !bytes.Equal(written, values[0]) || (written == nil) != (values[0] == nil) {
+			conflicts = append(conflicts, Conflict{Key: key, Reason: ConflictStaleRead})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].Key < conflicts[j].Key
+	})
+	return conflicts
+}
+
+// CheckStoreConflicts is CheckConflicts across multiple stores, given the access sets of both txs by store name. Keys
+// are only compared within the same store, and conflicts are sorted by store and key.
+func CheckStoreConflicts(earlier, later map[string]TxAccessSet) []Conflict {
+	var conflicts []Conflict
+	for store, laterSet := range later {
+		for _, conflict := range CheckConflicts(earlier[store], laterSet) {
+			conflict.Store = store
+			conflicts = append(conflicts, conflict)
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		return conflicts[i].QualifiedKey().Less(conflicts[j].QualifiedKey())
+	})
+	return conflicts
+}
+
+// CheckSerializedConflicts is a thin wrapper around CheckConflicts for JSON serialized TxAccessSets, eg. for use from
+// debug commands. Values are base64 encoded as per the encoding/json []byte encoding.
+func CheckSerializedConflicts(earlier, later []byte) ([]Conflict, error) {
+	var earlierSet, laterSet TxAccessSet
+	if err := json.Unmarshal(earlier, &earlierSet); err != nil {
+		return nil, fmt.Errorf("failed to decode earlier tx access set: %w", err)
+	}
+	if err := json.Unmarshal(later, &laterSet); err != nil {
+		return nil, fmt.Errorf("failed to decode later tx access set: %w", err)
+	}
+	return CheckConflicts(earlierSet, laterSet), nil
+}
+
+// CheckSerializedStoreConflicts is a thin wrapper around CheckStoreConflicts for JSON serialized TxAccessSets keyed by
+// store name.
+func CheckSerializedStoreConflicts(earlier, later []byte) ([]Conflict, error) {
+	var earlierSets, laterSets map[string]TxAccessSet
+	if err := json.Unmarshal(earlier, &earlierSets); err != nil {
+		return nil, fmt.Errorf("failed to decode earlier tx access sets: %w", err)
+	}
+	if err := json.Unmarshal(later, &laterSets); err != nil {
+		return nil, fmt.Errorf("failed to decode later tx access sets: %w", err)
+	}
+	return CheckStoreConflicts(earlierSets, laterSets), nil
+}
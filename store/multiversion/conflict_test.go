@@ -0,0 +1,81 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+func TestCheckConflicts(t *testing.T) {
+	earlier := multiversion.TxAccessSet{
+		Writeset: multiversion.WriteSet{
+			"key1": []byte("value1"),
+			"key2": nil,
+			"key3": []byte("value3"),
+			"key4": []byte("value4"),
+		},
+	}
+	later := multiversion.TxAccessSet{
+		Readset: multiversion.ReadSet{
+			"key1": {[]byte("value1")},                   // matches the written value
+			"key2": {[]byte("value2")},                   // earlier tx deleted the key
+			"key3": {[]byte("stale")},                    // earlier tx wrote a different value
+			"key5": {[]byte("value5")},                   // not written by the earlier tx
+			"key6": {[]byte("value6"), []byte("value7")}, // read multiple values
+		},
+		Writeset: multiversion.WriteSet{
+			"key4": []byte("other"), // write-write doesn't conflict
+		},
+	}
+
+	require.Equal(t, []multiversion.Conflict{
+		{Key: "key2", Reason: multiversion.ConflictStaleRead},
+		{Key: "key3", Reason: multiversion.ConflictStaleRead},
+		{Key: "key6", Reason: multiversion.ConflictMultipleReads},
+	}, multiversion.CheckConflicts(earlier, later))
+
+	// reading a deleted key as nil is valid, but reading an empty value is not
+	earlier = multiversion.TxAccessSet{Writeset: multiversion.WriteSet{"key1": nil}}
+	require.Empty(t, multiversion.CheckConflicts(earlier, multiversion.TxAccessSet{Readset: multiversion.ReadSet{"key1": {nil}}}))
+	require.Len(t, multiversion.CheckConflicts(earlier, multiversion.TxAccessSet{Readset: multiversion.ReadSet{"key1": {[]byte{}}}}), 1)
+}
+
+func TestCheckSerializedConflicts(t *testing.T) {
+	conflicts, err := multiversion.CheckSerializedConflicts(
+		[]byte(`{"writeset":{"key1":"dmFsdWUx"}}`),
+		[]byte(`{"readset":{"key1":["c3RhbGU="]}}`),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []multiversion.Conflict{{Key: "key1", Reason: multiversion.ConflictStaleRead}}, conflicts)
+
+	_, err = multiversion.CheckSerializedConflicts([]byte(`{`), []byte(`{}`))
+	require.Error(t, err)
+}
+
+func TestCheckStoreConflicts(t *testing.T) {
+	// the same key is written in one store and read in another, which doesn't conflict
+	earlier := map[string]multiversion.TxAccessSet{
+		"bank":    {Writeset: multiversion.WriteSet{"key1": []byte("value1")}},
+		"staking": {Writeset: multiversion.WriteSet{"key2": []byte("value2")}},
+	}
+	later := map[string]multiversion.TxAccessSet{
+		"bank":    {Readset: multiversion.ReadSet{"key1": {[]byte("stale")}, "key2": {[]byte("stale")}}},
+		"staking": {Readset: multiversion.ReadSet{"key1": {[]byte("stale")}, "key2": {[]byte("stale")}}},
+	}
+
+	conflicts := multiversion.CheckStoreConflicts(earlier, later)
+	require.Equal(t, []multiversion.Conflict{
+		{Store: "bank", Key: "key1", Reason: multiversion.ConflictStaleRead},
+		{Store: "staking", Key: "key2", Reason: multiversion.ConflictStaleRead},
+	}, conflicts)
+	require.Equal(t, "bank/6B657931: stale-read", conflicts[0].String())
+
+	serialized, err := multiversion.CheckSerializedStoreConflicts(
+		[]byte(`{"bank":{"writeset":{"key1":"dmFsdWUx"}}}`),
+		[]byte(`{"bank":{"readset":{"key1":["c3RhbGU="]}},"staking":{"readset":{"key1":["c3RhbGU="]}}}`),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []multiversion.Conflict{{Store: "bank", Key: "key1", Reason: multiversion.ConflictStaleRead}}, serialized)
+}
@@ -0,0 +1,52 @@
+package multiversion
+
+import (
+	"sort"
+	"sync"
+)
+
+// conflictBuffer collects the writers a validation conflicts with. Buffers are pooled, so that validations that don't
+// conflict, which are the vast majority in steady state, don't allocate, and the ones that do only allocate the
+// indices they return.
+type conflictBuffer struct {
+	writers []int
+}
+
+var conflictBufferPool = sync.Pool{
+	New: func() interface{} { return &conflictBuffer{} },
+}
+
+func getConflictBuffer() *conflictBuffer {
+	return conflictBufferPool.Get().(*conflictBuffer)
+}
+
+func putConflictBuffer(buf *conflictBuffer) {
+	buf.writers = buf.writers[:0]
+	conflictBufferPool.Put(buf)
+}
+
+// add records a conflict with the writer at index
+func (buf *conflictBuffer) add(index int) {
+	buf.writers = append(buf.writers, index)
+}
+
+// empty returns whether no conflicts were recorded
+func (buf *conflictBuffer) empty() bool {
+	return len(buf.writers) == 0
+}
+
+// indices returns the sorted distinct writers of the buffer in a new slice, which is empty (and not allocated) if there
+// aren't any
+func (buf *conflictBuffer) indices() []int {
+	if len(buf.writers) == 0 {
+		return []int{}
+	}
+	sort.Ints(buf.writers)
+	indices := make([]int, 0, len(buf.writers))
+	for i, writer := range buf.writers {
+		if i == 0 || writer != buf.writers[i-1] {
+			indices = append(indices, writer)
+		}
+	}
+	return indices
+}
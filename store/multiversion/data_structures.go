@@ -2,8 +2,8 @@ package multiversion
 
 import (
 	"sync"
+	"sync/atomic"
 
-	"github.com/cosmos/cosmos-sdk/store/types"
 	"github.com/google/btree"
 )
 
@@ -11,61 +11,101 @@ const (
 	multiVersionBTreeDegree = 2
 )
 
-type MultiVersionValue interface {
-	GetLatest() (value MultiVersionValueItem, found bool)
-	GetLatestNonEstimate() (value MultiVersionValueItem, found bool)
-	GetLatestBeforeIndex(index int) (value MultiVersionValueItem, found bool)
-	Set(index int, incarnation int, value []byte)
+// VersionedValue holds the versions of a value written by the txs of a block, indexed by tx, from which each tx reads
+// the latest version written before it. Versions are values, deletions, or estimates of values a tx is expected to
+// write. It's generic over the type of the values, so that stores of values other than bytes, eg. objects, share the
+// versioning of the multiversion store, see NewVersionedValue and CheckVersion.
+type VersionedValue[V any] interface {
+	GetLatest() (value VersionedValueItem[V], found bool)
+	GetLatestNonEstimate() (value VersionedValueItem[V], found bool)
+	GetLatestBeforeIndex(index int) (value VersionedValueItem[V], found bool)
+	Set(index int, incarnation int, value V)
 	SetEstimate(index int, incarnation int)
 	Delete(index int, incarnation int)
 	Remove(index int)
+	RemoveEstimate(index int) bool
+	GetLatestBeforeIndexWithGeneration(index int) (value VersionedValueItem[V], found bool, generation uint64)
+	Generation() uint64
+	ChangedSince(generation uint64) <-chan struct{}
+	Prune(index int) int
+	Versions() []VersionedValueItem[V]
 }
 
-type MultiVersionValueItem interface {
+// VersionedValueItem is a version of a VersionedValue
+type VersionedValueItem[V any] interface {
 	IsDeleted() bool
 	IsEstimate() bool
-	Value() []byte
+	Value() V
 	Incarnation() int
 	Index() int
 }
 
-type multiVersionItem struct {
-	valueTree *btree.BTree // contains versions values written to this key
-	mtx       sync.RWMutex // manages read + write accesses
+// MultiVersionValue and MultiVersionValueItem are the versioned values of the multiversion store, which are bytes
+type (
+	MultiVersionValue     = VersionedValue[[]byte]
+	MultiVersionValueItem = VersionedValueItem[[]byte]
+)
+
+// versionedItem implements VersionedValue with a btree of versions ordered by tx index. The btree is copy-on-write:
+// every change clones the latest tree, which shares its nodes until they're changed, and publishes the changed clone
+// as the latest versions. Reads load the latest tree without locking, so that reads of a key never contend with each
+// other or with the writes of the key, and only writes are serialized.
+type versionedItem[V any] struct {
+	latest   atomic.Value    // *versionTree, the latest versions of the item
+	mtx      sync.Mutex      // serializes changes to the versions
+	changed  chan struct{}   // closed by the next change to the versions if anyone waits for it, see ChangedSince
+	versions *versionPool[V] // allocates the versions of the item
 }
 
+// versionTree is a snapshot of the versions of an item, which is never changed once published
+type versionTree struct {
+	tree       *btree.BTree // contains versions values written to this key
+	generation uint64       // incremented by every change to the versions, see Generation
+}
+
+// multiVersionItem is the versioned value of a key of the multiversion store
+type multiVersionItem = versionedItem[[]byte]
+
 var _ MultiVersionValue = (*multiVersionItem)(nil)
 
 func NewMultiVersionItem() *multiVersionItem {
-	return &multiVersionItem{
-		valueTree: btree.New(multiVersionBTreeDegree),
-	}
+	return newVersionedItem(btree.New(multiVersionBTreeDegree), valueItemPool)
 }
 
-// GetLatest returns the latest written value to the btree, and returns a boolean indicating whether it was found.
-func (item *multiVersionItem) GetLatest() (MultiVersionValueItem, bool) {
-	item.mtx.RLock()
-	defer item.mtx.RUnlock()
+// NewVersionedValue returns an empty VersionedValue of values of type V
+func NewVersionedValue[V any]() VersionedValue[V] {
+	return newVersionedItem(btree.New(multiVersionBTreeDegree), newVersionPool[V]())
+}
 
-	bTreeItem := item.valueTree.Max()
+func newVersionedItem[V any](tree *btree.BTree, versions *versionPool[V]) *versionedItem[V] {
+	item := &versionedItem[V]{versions: versions}
+	item.latest.Store(&versionTree{tree: tree})
+	return item
+}
+
+// load returns the latest versions of the item
+func (item *versionedItem[V]) load() *versionTree {
+	return item.latest.Load().(*versionTree)
+}
+
+// GetLatest returns the latest written value to the btree, and returns a boolean indicating whether it was found.
+func (item *versionedItem[V]) GetLatest() (VersionedValueItem[V], bool) {
+	bTreeItem := item.load().tree.Max()
 	if bTreeItem == nil {
 		return nil, false
 	}
-	valueItem := bTreeItem.(*valueItem)
+	valueItem := bTreeItem.(*versionedValueItem[V])
 	return valueItem, true
 }
 
 // GetLatestNonEstimate returns the latest written value that isn't an ESTIMATE and returns a boolean indicating whether it was found.
 // This can be used when we want to write finalized values, since ESTIMATEs can be considered to be irrelevant at that point
-func (item *multiVersionItem) GetLatestNonEstimate() (MultiVersionValueItem, bool) {
-	item.mtx.RLock()
-	defer item.mtx.RUnlock()
-
-	var vItem *valueItem
+func (item *versionedItem[V]) GetLatestNonEstimate() (VersionedValueItem[V], bool) {
+	var vItem *versionedValueItem[V]
 	var found bool
-	item.valueTree.Descend(func(bTreeItem btree.Item) bool {
+	item.load().tree.Descend(func(bTreeItem btree.Item) bool {
 		// only return if non-estimate
-		item := bTreeItem.(*valueItem)
+		item := bTreeItem.(*versionedValueItem[V])
 		if item.IsEstimate() {
 			// if estimate, continue
 			return true
@@ -81,119 +121,235 @@ func (item *multiVersionItem) GetLatestNonEstimate() (MultiVersionValueItem, boo
 // GetLatest returns the latest written value to the btree prior to the index passed in, and returns a boolean indicating whether it was found.
 //
 // A `nil` value along with `found=true` indicates a deletion that has occurred and the underlying parent store doesn't need to be hit.
-func (item *multiVersionItem) GetLatestBeforeIndex(index int) (MultiVersionValueItem, bool) {
-	item.mtx.RLock()
-	defer item.mtx.RUnlock()
+func (item *versionedItem[V]) GetLatestBeforeIndex(index int) (VersionedValueItem[V], bool) {
+	return item.latestBeforeIndex(item.load().tree, index)
+}
 
-	// we want to find the value at the index that is LESS than the current index
-	pivot := &valueItem{index: index - 1}
+// GetLatestBeforeIndexWithGeneration behaves like GetLatestBeforeIndex, also returning the generation of the item as
+// of the read
+func (item *versionedItem[V]) GetLatestBeforeIndexWithGeneration(index int) (VersionedValueItem[V], bool, uint64) {
+	latest := item.load()
+	vItem, found := item.latestBeforeIndex(latest.tree, index)
+	return vItem, found, latest.generation
+}
 
-	var vItem *valueItem
-	var found bool
-	// start from pivot which contains our current index, and return on first item we hit.
-	// This will ensure we get the latest indexed value relative to our current index
-	item.valueTree.DescendLessOrEqual(pivot, func(bTreeItem btree.Item) bool {
-		vItem = bTreeItem.(*valueItem)
-		found = true
-		return false
+// latestBeforeIndex returns the latest version of tree written before index
+func (item *versionedItem[V]) latestBeforeIndex(tree *btree.BTree, index int) (VersionedValueItem[V], bool) {
+	// the search descends from the pivot just below index, and the first version it hits is the latest before index
+	vItem := item.versions.latestBeforeIndex(tree, index)
+	if vItem == nil {
+		return nil, false
+	}
+	return vItem, true
+}
+
+// Generation returns a counter that's incremented by every change to the item, so that a read of the item is known to
+// still be current as long as its generation is unchanged. It's never reset, including when the item is recycled.
+func (item *versionedItem[V]) Generation() uint64 {
+	return item.load().generation
+}
+
+// ChangedSince returns a channel that's closed once the item changes from the given generation, which is already
+// closed if it did. Together with the generation of a read, it works as a condition variable that can be waited on
+// with a timeout.
+func (item *versionedItem[V]) ChangedSince(generation uint64) <-chan struct{} {
+	item.mtx.Lock()
+	defer item.mtx.Unlock()
+	if item.load().generation != generation {
+		changed := make(chan struct{})
+		close(changed)
+		return changed
+	}
+	if item.changed == nil {
+		item.changed = make(chan struct{})
+	}
+	return item.changed
+}
+
+// changeLocked applies change to a clone of the latest versions and publishes it, incrementing the generation of the
+// item. The item must be locked.
+func (item *versionedItem[V]) changeLocked(change func(tree *btree.BTree)) {
+	latest := item.load()
+	tree := latest.tree.Clone()
+	change(tree)
+	item.publishLocked(tree, latest.generation+1)
+}
+
+// publishLocked makes tree the latest versions of the item, waking up the waiters for its change if the generation
+// changed. The item must be locked.
+func (item *versionedItem[V]) publishLocked(tree *btree.BTree, generation uint64) {
+	changed := generation != item.load().generation
+	item.latest.Store(&versionTree{tree: tree, generation: generation})
+	if changed && item.changed != nil {
+		close(item.changed)
+		item.changed = nil
+	}
+}
+
+func (item *versionedItem[V]) Set(index int, incarnation int, value V) {
+	item.mtx.Lock()
+	defer item.mtx.Unlock()
+
+	item.changeLocked(func(tree *btree.BTree) {
+		tree.ReplaceOrInsert(item.versions.get(index, incarnation, value, false, false))
+	})
+}
+
+func (item *versionedItem[V]) Delete(index int, incarnation int) {
+	item.mtx.Lock()
+	defer item.mtx.Unlock()
+
+	var deleted V
+	item.changeLocked(func(tree *btree.BTree) {
+		tree.ReplaceOrInsert(item.versions.get(index, incarnation, deleted, true, false))
 	})
-	return vItem, found
 }
 
-func (item *multiVersionItem) Set(index int, incarnation int, value []byte) {
-	types.AssertValidValue(value)
+func (item *versionedItem[V]) Remove(index int) {
 	item.mtx.Lock()
 	defer item.mtx.Unlock()
 
-	valueItem := NewValueItem(index, incarnation, value)
-	item.valueTree.ReplaceOrInsert(valueItem)
+	item.changeLocked(func(tree *btree.BTree) {
+		tree.Delete(&versionedValueItem[V]{index: index})
+	})
 }
 
-func (item *multiVersionItem) Delete(index int, incarnation int) {
+// RemoveEstimate removes the item at index if it's an estimate, and reports whether it did
+func (item *versionedItem[V]) RemoveEstimate(index int) bool {
 	item.mtx.Lock()
 	defer item.mtx.Unlock()
 
-	deletedItem := NewDeletedItem(index, incarnation)
-	item.valueTree.ReplaceOrInsert(deletedItem)
+	existing := item.load().tree.Get(&versionedValueItem[V]{index: index})
+	if existing == nil || !existing.(*versionedValueItem[V]).IsEstimate() {
+		return false
+	}
+	item.changeLocked(func(tree *btree.BTree) {
+		tree.Delete(existing)
+	})
+	return true
 }
 
-func (item *multiVersionItem) Remove(index int) {
+// Prune removes the values written before index that are superseded by a later value also written before index, and
+// returns how many it removed. Estimates are kept, so reads that hit them still abort. Reads from index onward are
+// unaffected, so the generation isn't incremented, but reads before index may no longer see the values they would have.
+func (item *versionedItem[V]) Prune(index int) int {
 	item.mtx.Lock()
 	defer item.mtx.Unlock()
 
-	item.valueTree.Delete(&valueItem{index: index})
+	latest := item.load()
+	var superseded []btree.Item
+	kept := false
+	latest.tree.DescendLessOrEqual(&versionedValueItem[V]{index: index - 1}, func(bTreeItem btree.Item) bool {
+		if bTreeItem.(*versionedValueItem[V]).IsEstimate() {
+			return true
+		}
+		if kept {
+			superseded = append(superseded, bTreeItem)
+		}
+		kept = true
+		return true
+	})
+	if len(superseded) == 0 {
+		return 0
+	}
+	tree := latest.tree.Clone()
+	for _, bTreeItem := range superseded {
+		tree.Delete(bTreeItem)
+	}
+	item.publishLocked(tree, latest.generation)
+	return len(superseded)
+}
+
+// Versions returns every version of the key, in index order
+func (item *versionedItem[V]) Versions() []VersionedValueItem[V] {
+	tree := item.load().tree
+	versions := make([]VersionedValueItem[V], 0, tree.Len())
+	tree.Ascend(func(bTreeItem btree.Item) bool {
+		versions = append(versions, bTreeItem.(*versionedValueItem[V]))
+		return true
+	})
+	return versions
 }
 
-func (item *multiVersionItem) SetEstimate(index int, incarnation int) {
+func (item *versionedItem[V]) SetEstimate(index int, incarnation int) {
 	item.mtx.Lock()
 	defer item.mtx.Unlock()
 
-	estimateItem := NewEstimateItem(index, incarnation)
-	item.valueTree.ReplaceOrInsert(estimateItem)
+	var estimate V
+	item.changeLocked(func(tree *btree.BTree) {
+		tree.ReplaceOrInsert(item.versions.get(index, incarnation, estimate, false, true))
+	})
 }
 
-type valueItem struct {
+// versionedValueItem implements VersionedValueItem
+type versionedValueItem[V any] struct {
 	index       int
 	incarnation int
-	value       []byte
+	value       V
+	deleted     bool
 	estimate    bool
 }
 
+// valueItem is a version of a key of the multiversion store
+type valueItem = versionedValueItem[[]byte]
+
 var _ MultiVersionValueItem = (*valueItem)(nil)
 
-// Index implements MultiVersionValueItem.
-func (v *valueItem) Index() int {
+// Index implements VersionedValueItem.
+func (v *versionedValueItem[V]) Index() int {
 	return v.index
 }
 
-// Incarnation implements MultiVersionValueItem.
-func (v *valueItem) Incarnation() int {
+// Incarnation implements VersionedValueItem.
+func (v *versionedValueItem[V]) Incarnation() int {
 	return v.incarnation
 }
 
-// IsDeleted implements MultiVersionValueItem.
-func (v *valueItem) IsDeleted() bool {
-	return v.value == nil && !v.estimate
+// IsDeleted implements VersionedValueItem.
+func (v *versionedValueItem[V]) IsDeleted() bool {
+	return v.deleted
 }
 
-// IsEstimate implements MultiVersionValueItem.
-func (v *valueItem) IsEstimate() bool {
+// IsEstimate implements VersionedValueItem.
+func (v *versionedValueItem[V]) IsEstimate() bool {
 	return v.estimate
 }
 
-// Value implements MultiVersionValueItem.
-func (v *valueItem) Value() []byte {
+// Value implements VersionedValueItem.
+func (v *versionedValueItem[V]) Value() V {
 	return v.value
 }
 
-// implement Less for btree.Item for valueItem
-func (i *valueItem) Less(other btree.Item) bool {
-	return i.index < other.(*valueItem).index
+// implement Less for btree.Item for versionedValueItem
+func (i *versionedValueItem[V]) Less(other btree.Item) bool {
+	return i.index < other.(*versionedValueItem[V]).index
 }
 
 func NewValueItem(index int, incarnation int, value []byte) *valueItem {
-	return &valueItem{
-		index:       index,
-		incarnation: incarnation,
-		value:       value,
-		estimate:    false,
-	}
+	return valueItemPool.get(index, incarnation, value, false, false)
 }
 
 func NewEstimateItem(index int, incarnation int) *valueItem {
-	return &valueItem{
-		index:       index,
-		incarnation: incarnation,
-		value:       nil,
-		estimate:    true,
-	}
+	return valueItemPool.get(index, incarnation, nil, false, true)
 }
 
 func NewDeletedItem(index int, incarnation int) *valueItem {
-	return &valueItem{
-		index:       index,
-		incarnation: incarnation,
-		value:       nil,
-		estimate:    false,
+	return valueItemPool.get(index, incarnation, nil, true, false)
+}
+
+// CheckVersion checks a read of a versioned value by a tx against latest, the latest version written before the tx,
+// where observedDeleted is whether the tx observed the value as deleted, and matches reports whether a value equals
+// the one it observed otherwise. It returns whether the read is still valid, and whether the writer of latest
+// conflicts with the tx, ie. the tx must wait for it: estimates conflict without invalidating the read, since the
+// estimated tx may end up writing the value read.
+func CheckVersion[V any](latest VersionedValueItem[V], observedDeleted bool, matches func(current V) bool) (valid bool, conflict bool) {
+	switch {
+	case latest.IsEstimate():
+		return true, true
+	case latest.IsDeleted():
+		return observedDeleted, !observedDeleted
+	default:
+		valid = matches(latest.Value())
+		return valid, !valid
 	}
 }
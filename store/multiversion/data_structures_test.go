@@ -1,6 +1,8 @@
 package multiversion_test
 
 import (
+	"strconv"
+	"sync"
 	"testing"
 
 	mv "github.com/cosmos/cosmos-sdk/store/multiversion"
@@ -226,3 +228,130 @@ func TestMultiversionItemGetLatestNonEstimate(t *testing.T) {
 	require.Equal(t, []byte("one"), value.Value())
 
 }
+
+func TestMultiversionItemChangedSince(t *testing.T) {
+	mvItem := mv.NewMultiVersionItem()
+	generation := mvItem.Generation()
+	changed := mvItem.ChangedSince(generation)
+	select {
+	case <-changed:
+		t.Fatal("item didn't change")
+	default:
+	}
+
+	// every waiter for the generation is woken up by the next change
+	other := mvItem.ChangedSince(generation)
+	mvItem.SetEstimate(1, 0)
+	<-changed
+	<-other
+
+	// waiting for a stale generation returns right away
+	<-mvItem.ChangedSince(generation)
+	select {
+	case <-mvItem.ChangedSince(mvItem.Generation()):
+		t.Fatal("item didn't change")
+	default:
+	}
+}
+
+func TestVersionedValueObjects(t *testing.T) {
+	type object struct{ balance int }
+	item := mv.NewVersionedValue[*object]()
+
+	// objects are versioned like bytes, with deletions distinct from values
+	item.Set(1, 0, &object{balance: 1})
+	item.Delete(2, 0)
+	item.SetEstimate(3, 0)
+	item.Set(4, 1, &object{balance: 4})
+
+	value, found := item.GetLatestBeforeIndex(2)
+	require.True(t, found)
+	require.Equal(t, &object{balance: 1}, value.Value())
+	value, found = item.GetLatestBeforeIndex(3)
+	require.True(t, found)
+	require.True(t, value.IsDeleted())
+	require.Nil(t, value.Value())
+	value, found = item.GetLatestBeforeIndex(4)
+	require.True(t, found)
+	require.True(t, value.IsEstimate())
+	require.False(t, value.IsDeleted())
+	value, found = item.GetLatestNonEstimate()
+	require.True(t, found)
+	require.Equal(t, 1, value.Incarnation())
+	require.Len(t, item.Versions(), 4)
+
+	// a zero value isn't a deletion
+	counters := mv.NewVersionedValue[int]()
+	counters.Set(0, 0, 0)
+	counter, found := counters.GetLatest()
+	require.True(t, found)
+	require.False(t, counter.IsDeleted())
+
+	// reads are checked against the latest version before the reader like for bytes
+	matches := func(observed int) func(int) bool { return func(current int) bool { return current == observed } }
+	counters.Set(1, 0, 5)
+	latest, _ := counters.GetLatestBeforeIndex(2)
+	valid, conflict := mv.CheckVersion(latest, false, matches(5))
+	require.True(t, valid)
+	require.False(t, conflict)
+	valid, conflict = mv.CheckVersion(latest, false, matches(4))
+	require.False(t, valid)
+	require.True(t, conflict)
+	valid, _ = mv.CheckVersion(latest, true, matches(0))
+	require.False(t, valid)
+
+	counters.Delete(1, 1)
+	latest, _ = counters.GetLatestBeforeIndex(2)
+	valid, conflict = mv.CheckVersion(latest, true, matches(0))
+	require.True(t, valid)
+	require.False(t, conflict)
+
+	// estimates conflict without invalidating the read
+	counters.SetEstimate(1, 2)
+	latest, _ = counters.GetLatestBeforeIndex(2)
+	valid, conflict = mv.CheckVersion(latest, false, matches(5))
+	require.True(t, valid)
+	require.True(t, conflict)
+}
+
+func TestMultiversionItemConcurrentReadsAndWrites(t *testing.T) {
+	mvItem := mv.NewMultiVersionItem()
+	const writes = 1000
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// every read sees a consistent version, written before the index it reads at, whose generation is
+				// at least the one of the version
+				value, found, generation := mvItem.GetLatestBeforeIndexWithGeneration(writes)
+				if !found {
+					continue
+				}
+				require.Less(t, value.Index(), writes)
+				require.Equal(t, []byte(strconv.Itoa(value.Index())), value.Value())
+				require.GreaterOrEqual(t, generation, uint64(value.Index()+1))
+				// versions are listed from a single snapshot, holding every version written so far
+				versions := mvItem.Versions()
+				require.Equal(t, len(versions)-1, versions[len(versions)-1].Index())
+			}
+		}()
+	}
+	for i := 0; i < writes; i++ {
+		mvItem.Set(i, 0, []byte(strconv.Itoa(i)))
+	}
+	close(done)
+	wg.Wait()
+
+	value, found := mvItem.GetLatest()
+	require.True(t, found)
+	require.Equal(t, writes-1, value.Index())
+	require.Equal(t, uint64(writes), mvItem.Generation())
+}
@@ -0,0 +1,89 @@
+package multiversion
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// WithReadsetDigests cuts the memory held by readsets of read heavy txs by keeping a sha256 digest of every read value
+// of at least minSize bytes in place of the value itself, while shorter values are kept as they are. Only values longer
+// than their 32 byte digest take less memory, so minSize is normally larger than that. Validation compares the digest
+// of the value a key holds against the recorded one, so it reaches the same results as with full values, barring a
+// sha256 collision.
+//
+// Readsets returned by GetReadset hold the digests rather than the values that were read. The version indexed stores
+// keep full values while their tx executes, so reads within a tx are unaffected.
+func WithReadsetDigests(minSize int) StoreOption {
+	if minSize < 1 {
+		// empty and missing values are never digested, so that they stay distinguishable
+		minSize = 1
+	}
+	return func(s *Store) {
+		s.readsetDigestMinSize = minSize
+	}
+}
+
+// digestedReads holds which values of a readset were replaced with their digests, by key and position. Keys none of
+// whose values were digested are left out. Whether a recorded value is a digest is kept apart from the value, since a
+// digest can't be told apart from a value that happens to be as long as one.
+type digestedReads map[string][]bool
+
+// isDigest returns whether the value at position i of the reads of key is a digest
+func (d digestedReads) isDigest(key string, i int) bool {
+	positions, ok := d[key]
+	return ok && positions[i]
+}
+
+// digestReadset returns the readset with the values that are digested replaced with their digests, and which of them
+// were, or nil if none were. The readset is left as it is, since it's still owned by the tx's version indexed store.
+func (s *Store) digestReadset(readset ReadSet) (ReadSet, digestedReads) {
+	if !s.digestsEnabled() {
+		return readset, nil
+	}
+	digested := make(ReadSet, len(readset))
+	var positions digestedReads
+	for key, values := range readset {
+		digestedValues := make([][]byte, len(values))
+		for i, value := range values {
+			if len(value) < s.readsetDigestMinSize {
+				digestedValues[i] = value
+				continue
+			}
+			digest := sha256.Sum256(value)
+			digestedValues[i] = digest[:]
+			if positions == nil {
+				positions = make(digestedReads)
+			}
+			if positions[key] == nil {
+				positions[key] = make([]bool, len(values))
+			}
+			positions[key][i] = true
+		}
+		digested[key] = digestedValues
+	}
+	return digested, positions
+}
+
+// digestedReads returns which values of the readset of the tx at index are digests, or nil if none are
+func (s *Store) digestedReads(index int) digestedReads {
+	digested, ok := s.txDigestedReads.Load(index)
+	if !ok {
+		return nil
+	}
+	return digested.(digestedReads)
+}
+
+// readMatches returns whether a value recorded in a readset, which is a digest if isDigest is set, matches the value
+// the key currently holds
+func readMatches(recorded []byte, isDigest bool, current []byte) bool {
+	if !isDigest {
+		return bytes.Equal(recorded, current)
+	}
+	digest := sha256.Sum256(current)
+	return bytes.Equal(recorded, digest[:])
+}
+
+// digestsEnabled returns whether read values are digested, which they aren't if readsets are hashed instead
+func (s *Store) digestsEnabled() bool {
+	return s.readsetDigestMinSize > 0 && s.readKeys == nil
+}
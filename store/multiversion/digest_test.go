@@ -0,0 +1,76 @@
+package multiversion_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestMultiVersionStoreReadsetDigests(t *testing.T) {
+	long := bytes.Repeat([]byte("a"), 100)
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("parent"), long)
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithReadsetDigests(64))
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{
+		"long":  long,
+		"short": []byte("value"),
+	})
+
+	vis := mvs.VersionedIndexedStore(2, 0, make(chan occ.Abort, 1))
+	require.Equal(t, long, vis.Get([]byte("long")))
+	require.Equal(t, []byte("value"), vis.Get([]byte("short")))
+	require.Equal(t, long, vis.Get([]byte("parent")))
+	require.Nil(t, vis.Get([]byte("missing")))
+	// repeated reads within the tx still see the full value
+	require.Equal(t, long, vis.Get([]byte("long")))
+	vis.WriteToMultiVersionStore()
+
+	digest := sha256.Sum256(long)
+	readset := mvs.GetReadset(2)
+	require.Equal(t, [][]byte{digest[:]}, readset["long"])
+	require.Equal(t, [][]byte{digest[:]}, readset["parent"])
+	require.Equal(t, [][]byte{[]byte("value")}, readset["short"])
+	require.Equal(t, [][]byte{nil}, readset["missing"])
+	// the version indexed store keeps full values
+	require.Equal(t, [][]byte{long}, vis.GetReadset()["long"])
+
+	valid, conflicts := mvs.ValidateTransactionState(2)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+
+	// changing a long value to another long value, to a short one, or deleting it is a conflict
+	for _, value := range [][]byte{bytes.Repeat([]byte("b"), 100), []byte("value"), nil} {
+		mvs.SetWriteset(1, 1, multiversion.WriteSet{"long": value, "short": []byte("value")})
+		valid, conflicts = mvs.ValidateTransactionState(2)
+		require.False(t, valid)
+		require.Equal(t, []int{1}, conflicts)
+	}
+	// as is a short value that becomes long
+	mvs.SetWriteset(1, 2, multiversion.WriteSet{"long": long, "short": long})
+	valid, _ = mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+	// and a long value that becomes its own digest, which is short enough not to be digested itself
+	mvs.SetWriteset(1, 2, multiversion.WriteSet{"long": digest[:], "short": []byte("value")})
+	valid, _ = mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+
+	// reads from the parent store are checked against digests too
+	mvs.SetWriteset(1, 3, multiversion.WriteSet{"long": long, "short": []byte("value")})
+	valid, _ = mvs.ValidateTransactionState(2)
+	require.True(t, valid)
+	parentKVStore.Set([]byte("parent"), bytes.Repeat([]byte("c"), 100))
+	valid, _ = mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+
+	// digests are an option of the block, cleared on reset
+	mvs.Reset(parentKVStore)
+	mvs.SetReadset(2, multiversion.ReadSet{"long": {long}})
+	require.Equal(t, [][]byte{long}, mvs.GetReadset(2)["long"])
+}
@@ -0,0 +1,104 @@
+package multiversion
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+)
+
+// EstimateStats counts the estimates of a store over a block. The writes of a tx become estimates when they're
+// prefilled or invalidated, and are resolved once the tx writes a concrete writeset or its estimates are removed. Reads
+// that hit an estimate abort their tx, so prefilled estimates only help a workload if they're resolved quickly and
+// spare more conflicting executions than the aborts they cause.
+type EstimateStats struct {
+	// Set is the number of times the writes of a tx became estimates while it had none
+	Set int
+	// Resolved is the number of times the estimates of a tx were resolved
+	Resolved int
+	// Reads is the number of reads that hit an estimate
+	Reads int
+}
+
+// Unresolved returns the number of txs whose estimates weren't resolved
+func (s EstimateStats) Unresolved() int {
+	return s.Set - s.Resolved
+}
+
+// estimateStats tracks the estimates of a store, and the time the estimates of every tx have been unresolved for
+type estimateStats struct {
+	// since is the time the estimates of every tx with unresolved estimates were set, by index
+	since     sync.Map
+	set       int64
+	resolved  int64
+	reads     int64
+	lifetimes latencyHistogram
+}
+
+// setEstimates records that the writes of the tx at index became estimates, unless they already were
+func (e *estimateStats) setEstimates(index int) {
+	if _, loaded := e.since.LoadOrStore(index, time.Now()); !loaded {
+		atomic.AddInt64(&e.set, 1)
+	}
+}
+
+// resolveEstimates records that the estimates of the tx at index were resolved, if it had any
+func (e *estimateStats) resolveEstimates(index int) {
+	since, loaded := e.since.LoadAndDelete(index)
+	if !loaded {
+		return
+	}
+	atomic.AddInt64(&e.resolved, 1)
+	atomic.AddInt64(&e.lifetimes[latencyBucket(time.Since(since.(time.Time)))], 1)
+}
+
+// readEstimate records a read that hit an estimate
+func (e *estimateStats) readEstimate() {
+	atomic.AddInt64(&e.reads, 1)
+}
+
+// countEstimateRead counts a read of the store that hit an estimate in the totals of its multiversion store, if any.
+// Unlike its other operations, it's counted right away, since the read aborts the execution.
+func (store *VersionIndexedStore) countEstimateRead() {
+	if store.estimateTotals != nil {
+		store.estimateTotals.readEstimate()
+	}
+}
+
+// EstimateStats returns the estimate counts of the store for the block so far
+func (s *Store) EstimateStats() EstimateStats {
+	return EstimateStats{
+		Set:      int(atomic.LoadInt64(&s.estimates.set)),
+		Resolved: int(atomic.LoadInt64(&s.estimates.resolved)),
+		Reads:    int(atomic.LoadInt64(&s.estimates.reads)),
+	}
+}
+
+// EstimateLifetime returns an upper bound on the q quantile of the time the estimates of txs remained unresolved, over
+// the estimates resolved so far. Lifetimes are kept in power of two buckets, so the bound is at most twice the actual
+// quantile.
+func (s *Store) EstimateLifetime(q float64) time.Duration {
+	return s.estimates.lifetimes.quantile(q)
+}
+
+// emitEstimateStats emits the estimate counts of the block and the quantiles of estimate lifetimes, in milliseconds
+func (s *Store) emitEstimateStats() {
+	stats := s.EstimateStats()
+	if stats.Set == 0 && stats.Reads == 0 {
+		return
+	}
+	telemetry.IncrCounterWithLabels([]string{"store", "mvs", "estimates", "set"}, float32(stats.Set), s.telemetryLabels())
+	telemetry.IncrCounterWithLabels([]string{"store", "mvs", "estimates", "unresolved"}, float32(stats.Unresolved()), s.telemetryLabels())
+	telemetry.IncrCounterWithLabels([]string{"store", "mvs", "estimates", "reads"}, float32(stats.Reads), s.telemetryLabels())
+	if stats.Resolved == 0 {
+		return
+	}
+	for _, quantile := range readLatencyQuantiles {
+		telemetry.SetGaugeWithLabels(
+			[]string{"store", "mvs", "estimate_lifetime", quantile.name},
+			float32(s.EstimateLifetime(quantile.q).Seconds()*1000),
+			s.telemetryLabels(),
+		)
+	}
+}
@@ -0,0 +1,57 @@
+package multiversion_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestMultiVersionStoreEstimateStats(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithStoreName("bank"))
+
+	// prefilled estimates, estimated again before they're resolved, count once
+	mvs.SetEstimatedWriteset(1, 0, multiversion.WriteSet{"key1": nil})
+	mvs.SetEstimatedWriteset(1, 0, multiversion.WriteSet{"key1": nil, "key2": nil})
+	mvs.SetEstimatedWriteset(2, 0, multiversion.WriteSet{"key3": nil})
+	require.Equal(t, multiversion.EstimateStats{Set: 2}, mvs.EstimateStats())
+
+	// a read hitting an estimate aborts, and is counted right away
+	vis := mvs.VersionedIndexedStore(3, 0, make(chan occ.Abort, 1))
+	require.Panics(t, func() { vis.Get([]byte("key1")) })
+	vis = mvs.VersionedIndexedStore(3, 0, make(chan occ.Abort, 1))
+	require.Panics(t, func() { vis.Has([]byte("key3")) })
+	require.Equal(t, 2, mvs.EstimateStats().Reads)
+
+	time.Sleep(2 * time.Millisecond)
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": []byte("value1")})
+	mvs.RemoveEstimatesForIndex(2)
+	require.Equal(t, multiversion.EstimateStats{Set: 2, Resolved: 2, Reads: 2}, mvs.EstimateStats())
+	require.GreaterOrEqual(t, mvs.EstimateLifetime(0.99), 2*time.Millisecond)
+
+	// invalidated writes are estimates again until the next incarnation writes
+	mvs.InvalidateWriteset(1, 0)
+	stats := mvs.EstimateStats()
+	require.Equal(t, 3, stats.Set)
+	require.Equal(t, 1, stats.Unresolved())
+	// writes without estimates don't resolve anything
+	mvs.SetWriteset(4, 0, multiversion.WriteSet{"key4": []byte("value4")})
+	require.Equal(t, 2, mvs.EstimateStats().Resolved)
+
+	sink := newInmemTelemetry(t)
+	mvs.FlushTelemetry()
+	require.True(t, emitted(sink, "store.mvs.estimates.set"))
+	require.True(t, emitted(sink, "store.mvs.estimates.unresolved"))
+	require.True(t, emitted(sink, "store.mvs.estimates.reads"))
+	require.True(t, emitted(sink, "store.mvs.estimate_lifetime.p50"))
+
+	mvs.Reset(parentKVStore)
+	require.Equal(t, multiversion.EstimateStats{}, mvs.EstimateStats())
+	require.Zero(t, mvs.EstimateLifetime(0.5))
+}
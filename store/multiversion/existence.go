@@ -0,0 +1,137 @@
+package multiversion
+
+import (
+	"sort"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// ExistenceSet holds whether each key a tx only checked the existence of (see VersionIndexedStore.Has) existed as of
+// the check. Existence checks don't observe values, so they're validated apart from the readset: a check stays valid
+// as long as the key keeps existing (or not), even if earlier txs change its value, while a key that an earlier tx
+// creates or deletes invalidates the check, which a readset entry would only catch by comparing values.
+type ExistenceSet map[string]bool
+
+// existenceSnapshot is an existence set as stored by the multiversion store, along with its keys in order, so that
+// validations iterate the keys in a deterministic order without sorting them every time
+type existenceSnapshot struct {
+	set  ExistenceSet
+	keys []string
+}
+
+// has checks whether key exists for the tx, recording the check in the existence set unless the key was already read
+// or written by the tx, in which case the value observed is already validated (or isn't read from earlier txs)
+func (store *VersionIndexedStore) has(key []byte) bool {
+	types.AssertValidKey(key)
+	strKey := store.keys.intern(key)
+	if value, ok := store.writeset[strKey]; ok {
+		return value != nil
+	}
+	if readsetVal, ok := store.readset[strKey]; ok {
+		return readsetVal[0] != nil
+	}
+	if exists, ok := store.existenceset[strKey]; ok {
+		return exists
+	}
+
+	start := time.Now()
+	mvsValue := store.multiVersionStore.GetLatestBeforeIndex(store.transactionIndex, key)
+	var exists bool
+	if mvsValue != nil {
+		if mvsValue.IsEstimate() {
+			store.countEstimateRead()
+			abort := scheduler.NewEstimateAbort(mvsValue.Index(), store.storeName, key)
+			sendAbort(store.abortChannel, abort)
+			if store.abortSignal != nil {
+				store.abortSignal.Signal(abort)
+			}
+			panic(abort)
+		}
+		store.recordRead(ReadSourceMultiVersion, start)
+		exists = !mvsValue.IsDeleted()
+	} else {
+		exists = store.hasParent(key, strKey)
+	}
+
+	if !store.trackRead() {
+		store.meterUntrackedRead(strKey, nil)
+		return exists
+	}
+	store.existenceset[strKey] = exists
+	store.meterMemory(len(strKey))
+	return exists
+}
+
+// GetExistenceSet returns the existence set
+func (store *VersionIndexedStore) GetExistenceSet() ExistenceSet {
+	return store.existenceset
+}
+
+// SetExistenceSet sets the existence set of the tx at index, indexing its keys along with those of its readset so that
+// the tx is revalidated when earlier txs change them. It must be set after the readset of the same incarnation, since
+// setting a readset clears the existence set.
+func (s *Store) SetExistenceSet(index int, existenceset ExistenceSet) {
+	if len(existenceset) == 0 {
+		s.txExistenceSets.Delete(index)
+		return
+	}
+	keys := make([]string, 0, len(existenceset))
+	for key := range existenceset {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	s.readIndex.add(index, keys)
+	s.txExistenceSets.Store(index, &existenceSnapshot{set: existenceset, keys: keys})
+}
+
+// GetExistenceSet returns the existence set of the tx at index, or nil if there is none
+func (s *Store) GetExistenceSet(index int) ExistenceSet {
+	snapshot := s.existenceSnapshot(index)
+	if snapshot == nil {
+		return nil
+	}
+	return snapshot.set
+}
+
+// existenceSnapshot returns the stored existence set of the tx at index, or nil if there is none
+func (s *Store) existenceSnapshot(index int) *existenceSnapshot {
+	snapshot, found := s.txExistenceSets.Load(index)
+	if !found {
+		return nil
+	}
+	return snapshot.(*existenceSnapshot)
+}
+
+// checkExistenceAtIndex validates the existence set of the tx at index against the latest values before the tx,
+// adding the writers it conflicts with to conflicts. Like reads, checks of keys with estimates are valid but conflict
+// with the estimated writer, so that the tx waits for it.
+func (s *Store) checkExistenceAtIndex(index int, conflicts *conflictBuffer) bool {
+	snapshot := s.existenceSnapshot(index)
+	if snapshot == nil {
+		return true
+	}
+	existenceset := snapshot.set
+	valid := true
+	for _, key := range snapshot.keys {
+		latestValue := s.GetLatestBeforeIndex(index, []byte(key))
+		if latestValue == nil {
+			if s.parentStore.Has([]byte(key)) != existenceset[key] {
+				s.notifyInvalidation(index, key, -1)
+				valid = false
+			}
+			continue
+		}
+		if latestValue.IsEstimate() {
+			conflicts.add(latestValue.Index())
+			continue
+		}
+		if latestValue.IsDeleted() == existenceset[key] {
+			conflicts.add(latestValue.Index())
+			s.notifyInvalidation(index, key, latestValue.Index())
+			valid = false
+		}
+	}
+	return valid
+}
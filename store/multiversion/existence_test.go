@@ -0,0 +1,87 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestVersionIndexedStoreExistenceSet(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("parent"), []byte("value"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"written": []byte("value"), "deleted": nil})
+
+	vis := mvs.VersionedIndexedStore(5, 0, make(chan scheduler.Abort, 1))
+	require.True(t, vis.Has([]byte("parent")))
+	require.False(t, vis.Has([]byte("absent")))
+	require.True(t, vis.Has([]byte("written")))
+	require.False(t, vis.Has([]byte("deleted")))
+
+	// keys already read or written by the tx aren't checked again
+	vis.Get([]byte("read"))
+	require.False(t, vis.Has([]byte("read")))
+	vis.Set([]byte("own"), []byte("value"))
+	require.True(t, vis.Has([]byte("own")))
+
+	// existence checks don't observe values, so they're kept apart from the readset
+	require.Equal(t, multiversion.ExistenceSet{"parent": true, "absent": false, "written": true, "deleted": false}, vis.GetExistenceSet())
+	require.NotContains(t, vis.GetReadset(), "parent")
+	require.Contains(t, vis.GetReadset(), "read")
+
+	vis.WriteToMultiVersionStore()
+	require.Equal(t, vis.GetExistenceSet(), mvs.GetExistenceSet(5))
+	valid, conflicts := mvs.ValidateTransactionState(5)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+
+	// changing the value of a key that still exists leaves the check valid
+	mvs.SetWriteset(2, 0, multiversion.WriteSet{"parent": []byte("other")})
+	valid, conflicts = mvs.ValidateTransactionState(5)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+
+	// creating a key that was absent invalidates the check, and the tx is revalidated for it
+	require.Equal(t, []int{5}, mvs.GetAffectedReaders(map[string]int{"absent": 3}))
+	mvs.SetWriteset(3, 0, multiversion.WriteSet{"absent": []byte("value")})
+	valid, conflicts = mvs.ValidateTransactionState(5)
+	require.False(t, valid)
+	require.Equal(t, []int{3}, conflicts)
+	mvs.InvalidateWriteset(3, 0)
+	mvs.SetWriteset(3, 1, multiversion.WriteSet{})
+
+	// deleting a key that existed invalidates the check too
+	mvs.SetWriteset(4, 0, multiversion.WriteSet{"written": nil})
+	valid, conflicts = mvs.ValidateTransactionState(5)
+	require.False(t, valid)
+	require.Equal(t, []int{4}, conflicts)
+
+	// estimates are conflicts to wait on, without invalidating the check
+	mvs.SetEstimatedWriteset(4, 1, multiversion.WriteSet{"written": nil})
+	valid, conflicts = mvs.ValidateTransactionState(5)
+	require.True(t, valid)
+	require.Equal(t, []int{4}, conflicts)
+
+	// a new readset replaces the existence set of the previous incarnation
+	mvs.SetReadset(5, multiversion.ReadSet{})
+	require.Nil(t, mvs.GetExistenceSet(5))
+	require.Empty(t, mvs.GetAffectedReaders(map[string]int{"absent": 3}))
+}
+
+func TestVersionIndexedStoreHasEstimateAborts(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	mvs.SetEstimatedWriteset(1, 0, multiversion.WriteSet{"key": nil})
+
+	abortCh := make(chan scheduler.Abort, 1)
+	vis := mvs.VersionedIndexedStore(2, 0, abortCh)
+	require.Panics(t, func() { vis.Has([]byte("key")) })
+	abort := <-abortCh
+	require.Equal(t, 1, abort.DependentTxIdx)
+	require.Empty(t, vis.GetExistenceSet())
+}
@@ -0,0 +1,22 @@
+package multiversion
+
+// WithReadKeyHash hashes readset keys with hash, to force collisions in hashed readsets
+func WithReadKeyHash(hash func(key []byte) uint64) StoreOption {
+	return func(s *Store) {
+		WithHashedReadsets()(s)
+		s.readKeys.keyHash = hash
+	}
+}
+
+// WithPrefilterKeyHash prefilters readsets with digests built from hash, to force digests to intersect
+func WithPrefilterKeyHash(hash func(key []byte) uint64) StoreOption {
+	return func(s *Store) {
+		WithReadsetPrefilter()(s)
+		s.prefilter.keyHash = hash
+	}
+}
+
+// InternedKeys returns the number of keys interned by the store
+func InternedKeys(s *Store) int {
+	return s.keys.len()
+}
@@ -0,0 +1,104 @@
+package multiversion
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+)
+
+// FlushRecord describes a single writeset flush into a multiversion store
+type FlushRecord struct {
+	Store       string     `json:"store"`
+	Index       int        `json:"index"`
+	Incarnation int        `json:"incarnation"`
+	Estimate    bool       `json:"estimate"`
+	KeyHashes   [][32]byte `json:"key_hashes"`
+}
+
+// FlushListener is notified of every writeset flush into a multiversion store, eg. to keep a log of the OCC activity
+// of a block that can be used to reconstruct what happened if the node crashes mid-block
+type FlushListener interface {
+	OnFlush(record FlushRecord)
+}
+
+func newFlushRecord(storeName string, index int, incarnation int, estimate bool, writeset WriteSet) FlushRecord {
+	keys := make([]string, 0, len(writeset))
+	for key := range writeset {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	keyHashes := make([][32]byte, 0, len(keys))
+	for _, key := range keys {
+		keyHashes = append(keyHashes, sha256.Sum256([]byte(key)))
+	}
+	return FlushRecord{
+		Store:       storeName,
+		Index:       index,
+		Incarnation: incarnation,
+		Estimate:    estimate,
+		KeyHashes:   keyHashes,
+	}
+}
+
+// RingFlushLog is an in-memory FlushListener that retains the most recent flush records up to its capacity
+type RingFlushLog struct {
+	mtx     sync.Mutex
+	records []FlushRecord
+	next    int
+	full    bool
+}
+
+var _ FlushListener = (*RingFlushLog)(nil)
+
+func NewRingFlushLog(capacity int) *RingFlushLog {
+	if capacity < 1 {
+		panic("ring flush log capacity must be positive")
+	}
+	return &RingFlushLog{
+		records: make([]FlushRecord, capacity),
+	}
+}
+
+// OnFlush implements FlushListener.
+func (l *RingFlushLog) OnFlush(record FlushRecord) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.records[l.next] = record
+	l.next = (l.next + 1) % len(l.records)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Records returns the retained flush records, oldest first
+func (l *RingFlushLog) Records() []FlushRecord {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if !l.full {
+		return append([]FlushRecord{}, l.records[:l.next]...)
+	}
+	return append(append([]FlushRecord{}, l.records[l.next:]...), l.records[:l.next]...)
+}
+
+// WriterFlushLog is a FlushListener that appends every flush record as a JSON line to a writer, eg. an on-disk WAL file
+type WriterFlushLog struct {
+	mtx     sync.Mutex
+	encoder *json.Encoder
+}
+
+var _ FlushListener = (*WriterFlushLog)(nil)
+
+func NewWriterFlushLog(w io.Writer) *WriterFlushLog {
+	return &WriterFlushLog{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// OnFlush implements FlushListener. Write errors are ignored since the log is a best effort debugging aid.
+func (l *WriterFlushLog) OnFlush(record FlushRecord) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	_ = l.encoder.Encode(record)
+}
@@ -0,0 +1,70 @@
+package multiversion_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestRingFlushLog(t *testing.T) {
+	ring := multiversion.NewRingFlushLog(3)
+	require.Empty(t, ring.Records())
+
+	for i := 0; i < 5; i++ {
+		ring.OnFlush(multiversion.FlushRecord{Index: i})
+	}
+	records := ring.Records()
+	require.Len(t, records, 3)
+	for i, record := range records {
+		require.Equal(t, i+2, record.Index)
+	}
+
+	require.Panics(t, func() {
+		multiversion.NewRingFlushLog(0)
+	})
+}
+
+func TestMultiVersionStoreFlushListener(t *testing.T) {
+	store := multiversion.NewMultiVersionStore(nil)
+	ring := multiversion.NewRingFlushLog(10)
+	var buf bytes.Buffer
+	writer := multiversion.NewWriterFlushLog(&buf)
+
+	store.SetFlushListener("bank", ring)
+	store.SetEstimatedWriteset(1, occ.PrefillIncarnation, map[string][]byte{"key1": nil})
+	store.SetWriteset(1, 0, map[string][]byte{
+		"key2": []byte("value2"),
+		"key1": []byte("value1"),
+	})
+
+	require.Equal(t, []multiversion.FlushRecord{
+		{
+			Store:       "bank",
+			Index:       1,
+			Incarnation: occ.PrefillIncarnation,
+			Estimate:    true,
+			KeyHashes:   [][32]byte{sha256.Sum256([]byte("key1"))},
+		},
+		{
+			Store:       "bank",
+			Index:       1,
+			Incarnation: 0,
+			KeyHashes:   [][32]byte{sha256.Sum256([]byte("key1")), sha256.Sum256([]byte("key2"))},
+		},
+	}, ring.Records())
+
+	// records can be written as JSON lines
+	store.SetFlushListener("bank", writer)
+	store.SetWriteset(2, 3, map[string][]byte{"key3": nil})
+	var record multiversion.FlushRecord
+	require.NoError(t, json.NewDecoder(&buf).Decode(&record))
+	require.Equal(t, 2, record.Index)
+	require.Equal(t, 3, record.Incarnation)
+	require.Equal(t, [][32]byte{sha256.Sum256([]byte("key3"))}, record.KeyHashes)
+}
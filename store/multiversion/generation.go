@@ -0,0 +1,67 @@
+package multiversion
+
+import "sync/atomic"
+
+// Reads are validated by looking up the latest value of every readset key before the tx and comparing it with the
+// value the tx read. Most keys don't change between the execution of a tx and its validation though, so the generation
+// of the multiversion item of each key (see multiVersionItem.Generation) is recorded along with the reads served from
+// it, and a read whose item is still at the same generation is valid without any lookup or comparison: the item is
+// unchanged, so the read would be served the same value again. Like any KVStore, this relies on values not being
+// mutated once they're written. Reads served from the parent store are always compared.
+
+// GetLatestBeforeIndexWithGeneration behaves like GetLatestBeforeIndex, also returning the generation of the key as of
+// the read, to be recorded with SetReadsetWithGenerations
+func (s *Store) GetLatestBeforeIndexWithGeneration(index int, key []byte) (MultiVersionValueItem, uint64) {
+	keyString := s.keys.intern(key)
+	mvVal, found := s.multiVersionMap.Load(keyString)
+	if !found {
+		return nil, 0
+	}
+	val, found, generation := mvVal.(MultiVersionValue).GetLatestBeforeIndexWithGeneration(index)
+	if !found {
+		return nil, generation
+	}
+	return s.handOut(keyString, val), generation
+}
+
+// SetReadsetWithGenerations behaves like SetReadset, also keeping the generations of the keys of the readset as of
+// their reads, so that validation skips the reads of keys whose generation is unchanged. Keys without a generation,
+// eg. read through iterators, are always validated in full.
+func (s *Store) SetReadsetWithGenerations(index int, readset ReadSet, generations map[string]uint64) {
+	s.SetReadset(index, readset)
+	if len(generations) > 0 {
+		s.txReadGenerations.Store(index, generations)
+	}
+}
+
+// generation returns the current generation of a key
+func (s *Store) generation(key string) uint64 {
+	mvVal, found := s.multiVersionMap.Load(key)
+	if !found {
+		return 0
+	}
+	return mvVal.(MultiVersionValue).Generation()
+}
+
+// readGenerations returns the generations recorded for the readset of a tx, if any
+func (s *Store) readGenerations(index int) map[string]uint64 {
+	generations, found := s.txReadGenerations.Load(index)
+	if !found {
+		return nil
+	}
+	return generations.(map[string]uint64)
+}
+
+// readUnchanged returns whether a read of key by a tx that observed a single value is known to still be valid, because
+// the generation of the key is the one recorded with the read
+func (s *Store) readUnchanged(generations map[string]uint64, key string, multiple bool) bool {
+	if multiple {
+		return false
+	}
+	generation, ok := generations[key]
+	if !ok || s.generation(key) != generation {
+		return false
+	}
+	atomic.AddInt64(&s.validationCost.unchangedReads, 1)
+	return true
+}
@@ -0,0 +1,91 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestMultiversionItemGeneration(t *testing.T) {
+	item := multiversion.NewMultiVersionItem()
+	require.Zero(t, item.Generation())
+
+	item.Set(1, 0, []byte("one"))
+	item.SetEstimate(2, 0)
+	item.Delete(3, 0)
+	item.Remove(2)
+	require.Equal(t, uint64(4), item.Generation())
+
+	value, found, generation := item.GetLatestBeforeIndexWithGeneration(2)
+	require.True(t, found)
+	require.Equal(t, []byte("one"), value.Value())
+	require.Equal(t, uint64(4), generation)
+	_, found, generation = item.GetLatestBeforeIndexWithGeneration(1)
+	require.False(t, found)
+	require.Equal(t, uint64(4), generation)
+}
+
+func TestMultiVersionStoreUnchangedReads(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("parent"), []byte("value"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"written": []byte("value")})
+
+	vis := mvs.VersionedIndexedStore(3, 0, make(chan occ.Abort, 1))
+	require.Equal(t, []byte("value"), vis.Get([]byte("written")))
+	require.Equal(t, []byte("value"), vis.Get([]byte("parent")))
+	require.Nil(t, vis.Get([]byte("missing")))
+	vis.WriteToMultiVersionStore()
+
+	// nothing changed, so the read served from the multiversion store isn't looked up again, unlike parent reads
+	valid, conflicts := mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+	require.Equal(t, 1, mvs.ValidationCost().UnchangedReads)
+
+	// writes to other keys don't change the read keys
+	mvs.SetWriteset(2, 0, multiversion.WriteSet{"other": []byte("value")})
+	valid, _ = mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+	require.Equal(t, 2, mvs.ValidationCost().UnchangedReads)
+
+	// rewriting a read key has it compared again, even if the value is the same, as do writes by later txs
+	mvs.SetWriteset(1, 1, multiversion.WriteSet{"written": []byte("value")})
+	valid, _ = mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+	require.Equal(t, 2, mvs.ValidationCost().UnchangedReads)
+	mvs.SetWriteset(4, 0, multiversion.WriteSet{"written": []byte("later")})
+	valid, _ = mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+	require.Equal(t, 2, mvs.ValidationCost().UnchangedReads)
+
+	// and a changed value is a conflict
+	mvs.SetWriteset(2, 1, multiversion.WriteSet{"written": []byte("changed")})
+	valid, conflicts = mvs.ValidateTransactionState(3)
+	require.False(t, valid)
+	require.Equal(t, []int{2}, conflicts)
+
+	// until the tx is executed again, the read is compared, even once the item is back to the value read
+	mvs.SetWriteset(2, 2, multiversion.WriteSet{})
+	valid, _ = mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+	require.Equal(t, 2, mvs.ValidationCost().UnchangedReads)
+
+	// readsets set without generations are compared in full
+	vis = mvs.VersionedIndexedStore(3, 1, make(chan occ.Abort, 1))
+	require.Equal(t, []byte("value"), vis.Get([]byte("written")))
+	mvs.SetReadset(3, vis.GetReadset())
+	valid, _ = mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+	require.Equal(t, 2, mvs.ValidationCost().UnchangedReads)
+	// while those of a re-execution are skipped again
+	vis.WriteToMultiVersionStore()
+	valid, _ = mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+	require.Equal(t, 3, mvs.ValidationCost().UnchangedReads)
+}
@@ -0,0 +1,149 @@
+package multiversion
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"sync"
+)
+
+// WithHashedReadsets cuts the memory held by readsets with huge numbers of keys. Each distinct key read in the block is
+// kept once by the store, in a table of 64 bit key hashes, and readsets only hold the hashes of the keys they read and
+// of the values they observed, in place of the keys and values themselves. It takes precedence over
+// WithReadsetDigests and WithReadsetSpill, whose readsets it replaces.
+//
+// Keys are never confused: a key whose hash collides with another key of the table is kept in full in the readsets
+// that read it. Values are compared by their hashes, seeded randomly by each store, so a read of a value that was since
+// changed is mistaken for a valid one with a probability of about 2^-64 per validated read of a changed value. Even a
+// node validating a billion such reads per block for a billion blocks is unlikely to ever see one (about 5e-2 over its
+// whole lifetime), which is far below the rate of hardware faults.
+//
+// Readsets returned by GetReadset hold the 8 byte big endian hashes of values rather than the values that were read,
+// and nil for nil values. The version indexed stores keep full values while their tx executes, so reads within a tx
+// are unaffected.
+func WithHashedReadsets() StoreOption {
+	return func(s *Store) {
+		s.readKeys = &readKeyTable{seed: maphash.MakeSeed()}
+	}
+}
+
+// readKeyTable maps the hashes of the keys read in a block to the keys
+type readKeyTable struct {
+	seed maphash.Seed
+	keys sync.Map // map of key hash -> key string
+	// replaces the hash of keys, only ever set by tests to force collisions
+	keyHash func(key []byte) uint64
+}
+
+// hashedRead is a read of a key in a hashed readset
+type hashedRead struct {
+	key uint64
+	// the hash of the value that was read, or 0 if it was nil
+	value uint64
+	// whether the tx observed multiple values, which always fails validation
+	multiple bool
+}
+
+// hashedReadset is a readset in the form kept by stores with hashed readsets
+type hashedReadset struct {
+	reads []hashedRead
+	// reads of keys whose hash collides with another key in the table, in full
+	collided ReadSet
+}
+
+func (t *readKeyTable) hash(bz []byte) uint64 {
+	var h maphash.Hash
+	h.SetSeed(t.seed)
+	_, _ = h.Write(bz)
+	return h.Sum64()
+}
+
+// valueHash returns the hash of a value, which is 0 only for nil values
+func (t *readKeyTable) valueHash(value []byte) uint64 {
+	if value == nil {
+		return 0
+	}
+	if h := t.hash(value); h != 0 {
+		return h
+	}
+	return 1
+}
+
+// valueMatches returns whether a value hash recorded for a read matches the value a key currently holds. Like full
+// values, nil and empty values match each other.
+func (t *readKeyTable) valueMatches(recorded uint64, current []byte) bool {
+	if recorded == 0 {
+		return len(current) == 0
+	}
+	if current == nil {
+		current = []byte{}
+	}
+	return recorded == t.valueHash(current)
+}
+
+// intern adds a key to the table, returning its hash and the key as kept by the table, and false if the hash collides
+// with a different key
+func (t *readKeyTable) intern(key string) (uint64, string, bool) {
+	var h uint64
+	if t.keyHash != nil {
+		h = t.keyHash([]byte(key))
+	} else {
+		h = t.hash([]byte(key))
+	}
+	existing, _ := t.keys.LoadOrStore(h, key)
+	return h, existing.(string), existing.(string) == key
+}
+
+// key returns the key with a hash in the table
+func (t *readKeyTable) key(h uint64) string {
+	key, _ := t.keys.Load(h)
+	return key.(string)
+}
+
+// hashReadset converts a readset to its hashed form, along with the keys it reads as kept by the table
+func (t *readKeyTable) hashReadset(readset ReadSet) (*hashedReadset, []string) {
+	hashed := &hashedReadset{reads: make([]hashedRead, 0, len(readset))}
+	keys := make([]string, 0, len(readset))
+	for key, values := range readset {
+		if len(values) == 0 {
+			continue
+		}
+		h, interned, ok := t.intern(key)
+		if !ok {
+			if hashed.collided == nil {
+				hashed.collided = make(ReadSet)
+			}
+			hashed.collided[key] = values
+			keys = append(keys, key)
+			continue
+		}
+		hashed.reads = append(hashed.reads, hashedRead{
+			key:      h,
+			value:    t.valueHash(values[0]),
+			multiple: len(values) > 1,
+		})
+		keys = append(keys, interned)
+	}
+	return hashed, keys
+}
+
+// readset returns the hashed readset as a ReadSet, with the hashes of values in place of the values
+func (t *readKeyTable) readset(hashed *hashedReadset) ReadSet {
+	readset := make(ReadSet, len(hashed.reads)+len(hashed.collided))
+	for _, read := range hashed.reads {
+		var value []byte
+		if read.value != 0 {
+			value = make([]byte, 8)
+			binary.BigEndian.PutUint64(value, read.value)
+		}
+		values := [][]byte{value}
+		if read.multiple {
+			// the other values aren't kept, only that there were some
+			values = append(values, nil)
+		}
+		readset[t.key(read.key)] = values
+	}
+	for key, values := range hashed.collided {
+		readset[key] = values
+	}
+	return readset
+}
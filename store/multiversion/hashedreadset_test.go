@@ -0,0 +1,95 @@
+package multiversion_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestMultiVersionStoreHashedReadsets(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	for i := 0; i < 1000; i++ {
+		parentKVStore.Set([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithHashedReadsets())
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key0": []byte("written"), "deleted": nil})
+
+	vis := mvs.VersionedIndexedStore(2, 0, make(chan occ.Abort, 1))
+	for i := 0; i < 1000; i++ {
+		vis.Get([]byte(fmt.Sprintf("key%d", i)))
+	}
+	require.Nil(t, vis.Get([]byte("deleted")))
+	require.Nil(t, vis.Get([]byte("missing")))
+	vis.WriteToMultiVersionStore()
+
+	// readsets hold the hashes of values, and nil for nil values
+	readset := mvs.GetReadset(2)
+	require.Len(t, readset, 1002)
+	require.Len(t, readset["key0"], 1)
+	require.Len(t, readset["key0"][0], 8)
+	require.NotZero(t, binary.BigEndian.Uint64(readset["key0"][0]))
+	require.Equal(t, [][]byte{nil}, readset["deleted"])
+	require.Equal(t, [][]byte{nil}, readset["missing"])
+
+	valid, conflicts := mvs.ValidateTransactionState(2)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+
+	// changes to read values are conflicts, and are found through the read index
+	mvs.TakeDirtyKeys()
+	mvs.SetWriteset(1, 1, multiversion.WriteSet{"key0": []byte("rewritten"), "deleted": nil})
+	require.Equal(t, []int{2}, mvs.GetAffectedReaders(mvs.TakeDirtyKeys()))
+	valid, conflicts = mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+	require.Equal(t, []int{1}, conflicts)
+
+	mvs.SetWriteset(1, 2, multiversion.WriteSet{"key0": []byte("written"), "deleted": []byte("value")})
+	valid, _ = mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+
+	mvs.SetWriteset(1, 3, multiversion.WriteSet{"key0": []byte("written"), "deleted": nil})
+	valid, _ = mvs.ValidateTransactionState(2)
+	require.True(t, valid)
+	parentKVStore.Set([]byte("key500"), []byte("changed"))
+	valid, _ = mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+}
+
+func TestMultiVersionStoreHashedReadsetsKeyCollisions(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("keyA"), []byte("valueA"))
+	parentKVStore.Set([]byte("keyB"), []byte("valueB"))
+	// every key collides
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithReadKeyHash(func([]byte) uint64 { return 42 }))
+
+	mvs.SetReadset(2, multiversion.ReadSet{"keyA": {[]byte("valueA")}})
+	mvs.SetReadset(3, multiversion.ReadSet{"keyB": {[]byte("valueB")}})
+	// the colliding key is kept in full
+	require.Equal(t, multiversion.ReadSet{"keyB": {[]byte("valueB")}}, mvs.GetReadset(3))
+
+	for _, index := range []int{2, 3} {
+		valid, _ := mvs.ValidateTransactionState(index)
+		require.True(t, valid)
+	}
+
+	// a write to the colliding key only invalidates its reader
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"keyB": []byte("changed")})
+	valid, _ := mvs.ValidateTransactionState(2)
+	require.True(t, valid)
+	valid, conflicts := mvs.ValidateTransactionState(3)
+	require.False(t, valid)
+	require.Equal(t, []int{1}, conflicts)
+
+	mvs.SetWriteset(1, 1, multiversion.WriteSet{"keyA": []byte("changed")})
+	valid, _ = mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+	valid, _ = mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+}
@@ -0,0 +1,50 @@
+package multiversion
+
+import (
+	"sort"
+)
+
+// KeyState is the state of a key in the multiversion store, as inspected while a block is processed
+type KeyState struct {
+	Key []byte `json:"key"`
+	// Writers are the indices of the txs with a version of the key, in index order
+	Writers []int `json:"writers"`
+	// Estimates are the indices of the writers whose versions are estimates, in index order
+	Estimates []int `json:"estimates,omitempty"`
+}
+
+// StoreState is a snapshot of the multiversion store for debugging a block as it's processed
+type StoreState struct {
+	// Keys are the keys with versions in the store, sorted by key
+	Keys []KeyState `json:"keys"`
+	// ReadsetSizes are the number of keys in the readset of every tx with one, by tx index
+	ReadsetSizes map[int]int `json:"readset_sizes"`
+}
+
+// Inspect returns a snapshot of the versions of every key and the size of every readset. It's safe to call while txs
+// are executed and validated against the store, but the snapshot isn't consistent across keys, since they keep
+// changing while it's taken.
+func (s *Store) Inspect() StoreState {
+	state := StoreState{ReadsetSizes: make(map[int]int)}
+	s.multiVersionMap.Range(func(key, value interface{}) bool {
+		versions := value.(MultiVersionValue).Versions()
+		if len(versions) == 0 {
+			return true
+		}
+		keyState := KeyState{Key: []byte(key.(string)), Writers: make([]int, 0, len(versions))}
+		for _, version := range versions {
+			keyState.Writers = append(keyState.Writers, version.Index())
+			if version.IsEstimate() {
+				keyState.Estimates = append(keyState.Estimates, version.Index())
+			}
+		}
+		state.Keys = append(state.Keys, keyState)
+		return true
+	})
+	sort.Slice(state.Keys, func(i, j int) bool { return string(state.Keys[i].Key) < string(state.Keys[j].Key) })
+	s.RangeReadsets(func(index int, readset ReadSet) bool {
+		state.ReadsetSizes[index] = len(readset)
+		return true
+	})
+	return state
+}
@@ -0,0 +1,42 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+func TestMultiVersionStoreInspect(t *testing.T) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	require.Empty(t, mvs.Inspect().Keys)
+
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"a": []byte("1"), "b": nil})
+	mvs.SetEstimatedWriteset(3, 0, multiversion.WriteSet{"a": nil})
+	mvs.SetWriteset(2, 0, multiversion.WriteSet{"a": []byte("2")})
+	mvs.SetReadset(2, multiversion.ReadSet{"a": {[]byte("1")}, "c": {nil}})
+	mvs.SetReadset(4, multiversion.ReadSet{"b": {nil}})
+
+	require.Equal(t, multiversion.StoreState{
+		Keys: []multiversion.KeyState{
+			{Key: []byte("a"), Writers: []int{1, 2, 3}, Estimates: []int{3}},
+			{Key: []byte("b"), Writers: []int{1}},
+		},
+		ReadsetSizes: map[int]int{2: 2, 4: 1},
+	}, mvs.Inspect())
+
+	// invalidated writesets are estimates, and keys left without versions aren't listed
+	mvs.InvalidateWriteset(1, 0)
+	require.Equal(t, []multiversion.KeyState{
+		{Key: []byte("a"), Writers: []int{1, 2, 3}, Estimates: []int{1, 3}},
+		{Key: []byte("b"), Writers: []int{1}, Estimates: []int{1}},
+	}, mvs.Inspect().Keys)
+	mvs.RemoveEstimatesForIndex(1)
+	mvs.RemoveEstimatesForIndex(3)
+	require.Equal(t, []multiversion.KeyState{
+		{Key: []byte("a"), Writers: []int{2}},
+	}, mvs.Inspect().Keys)
+}
@@ -0,0 +1,111 @@
+package multiversion
+
+import (
+	"sync"
+)
+
+// keyTableShards is the number of shards of a keyTable, so that concurrent executions rarely contend on the same lock
+const keyTableShards = 32
+
+// keyTable interns the keys accessed in a block, so that each distinct key is converted to a string once and the same
+// string is shared by the readsets, writesets and multiversion map of the store and its version indexed stores,
+// rather than every one of them allocating a copy of the key. The table is cleared when the store is reset, so it only
+// holds the keys of the current block. A nil table doesn't intern, and converts keys as they are.
+type keyTable struct {
+	shards [keyTableShards]keyTableShard
+}
+
+type keyTableShard struct {
+	mx   sync.RWMutex
+	keys map[string]string
+}
+
+func newKeyTable() *keyTable {
+	t := &keyTable{}
+	for i := range t.shards {
+		t.shards[i].keys = make(map[string]string)
+	}
+	return t
+}
+
+// shard returns the shard of a key, by its FNV-1a hash, and shardString the shard of a key as a string
+func (t *keyTable) shard(key []byte) *keyTableShard {
+	h := uint32(2166136261)
+	for _, b := range key {
+		h = (h ^ uint32(b)) * 16777619
+	}
+	return &t.shards[h%keyTableShards]
+}
+
+func (t *keyTable) shardString(key string) *keyTableShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h = (h ^ uint32(key[i])) * 16777619
+	}
+	return &t.shards[h%keyTableShards]
+}
+
+// intern returns key as a string, only converting it the first time the table sees it
+func (t *keyTable) intern(key []byte) string {
+	if t == nil {
+		return string(key)
+	}
+	shard := t.shard(key)
+	shard.mx.RLock()
+	// looking up a converted byteslice doesn't allocate
+	interned, ok := shard.keys[string(key)]
+	shard.mx.RUnlock()
+	if ok {
+		return interned
+	}
+	shard.mx.Lock()
+	defer shard.mx.Unlock()
+	if interned, ok := shard.keys[string(key)]; ok {
+		return interned
+	}
+	interned = string(key)
+	shard.keys[interned] = interned
+	return interned
+}
+
+// internString returns the string of the table equal to key, adding key to the table if there's none, eg. for the
+// keys of writesets built outside of the store's version indexed stores
+func (t *keyTable) internString(key string) string {
+	if t == nil {
+		return key
+	}
+	shard := t.shardString(key)
+	shard.mx.RLock()
+	interned, ok := shard.keys[key]
+	shard.mx.RUnlock()
+	if ok {
+		return interned
+	}
+	shard.mx.Lock()
+	defer shard.mx.Unlock()
+	if interned, ok := shard.keys[key]; ok {
+		return interned
+	}
+	shard.keys[key] = key
+	return key
+}
+
+// len returns the number of keys in the table
+func (t *keyTable) len() int {
+	n := 0
+	for i := range t.shards {
+		t.shards[i].mx.RLock()
+		n += len(t.shards[i].keys)
+		t.shards[i].mx.RUnlock()
+	}
+	return n
+}
+
+// reset clears the table for the next block
+func (t *keyTable) reset() {
+	for i := range t.shards {
+		t.shards[i].mx.Lock()
+		t.shards[i].keys = make(map[string]string)
+		t.shards[i].mx.Unlock()
+	}
+}
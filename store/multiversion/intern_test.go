@@ -0,0 +1,64 @@
+package multiversion_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestStoreInternsKeys(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+
+	// the keys of writesets built outside of the version indexed stores are interned too
+	mvs.SetWriteset(0, 0, multiversion.WriteSet{"key2": []byte("value2")})
+	mvs.SetEstimatedWriteset(1, 0, multiversion.WriteSet{"key3": []byte("value3")})
+	require.Equal(t, 2, multiversion.InternedKeys(mvs))
+
+	// every distinct key accessed by the version indexed stores is interned once, however many times and by however
+	// many stores it's accessed
+	for index := 2; index < 5; index++ {
+		vis := mvs.VersionedIndexedStore(index, 0, make(chan scheduler.Abort, 1))
+		require.Equal(t, []byte("value1"), vis.Get([]byte("key1")))
+		require.Equal(t, []byte("value2"), vis.Get([]byte("key2")))
+		require.True(t, vis.Has([]byte("key1")))
+		require.False(t, vis.Has([]byte("key4")))
+		vis.Set([]byte("key5"), []byte("value5"))
+		vis.Set([]byte("key5"), []byte("value5"))
+		vis.WriteToMultiVersionStore()
+	}
+	require.Equal(t, 5, multiversion.InternedKeys(mvs))
+	require.Equal(t, []byte("value5"), mvs.GetLatestBeforeIndex(3, []byte("key5")).Value())
+	valid, conflicts := mvs.ValidateTransactionState(4)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+
+	// the table only holds the keys of the current block
+	mvs.Reset(parentKVStore)
+	require.Zero(t, multiversion.InternedKeys(mvs))
+}
+
+func TestStoreInternsKeysConcurrently(t *testing.T) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	var wg sync.WaitGroup
+	for index := 0; index < 8; index++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			vis := mvs.VersionedIndexedStore(index, 0, make(chan scheduler.Abort, 1))
+			for i := 0; i < 100; i++ {
+				vis.Get([]byte(fmt.Sprintf("key%d", i)))
+			}
+		}(index)
+	}
+	wg.Wait()
+	require.Equal(t, 100, multiversion.InternedKeys(mvs))
+}
@@ -0,0 +1,24 @@
+package multiversion
+
+// InvalidationListener is notified of every key that fails the validation of a tx, eg. to find the keys that serialize
+// execution. The writer is the index of the tx whose write the key no longer matches, or -1 if the read no longer
+// matches the parent store, which happens when the write it read was reverted. It's called concurrently by the
+// validations of different txs.
+type InvalidationListener interface {
+	OnInvalidation(storeName string, index int, key string, writer int)
+}
+
+// WithInvalidationListener sets a listener that is notified of the keys failing validation. Iterators that fail
+// validation aren't reported, since they aren't invalidated by a single key.
+func WithInvalidationListener(listener InvalidationListener) StoreOption {
+	return func(s *Store) {
+		s.invalidationListener = listener
+	}
+}
+
+func (s *Store) notifyInvalidation(index int, key string, writer int) {
+	if s.invalidationListener == nil {
+		return
+	}
+	s.invalidationListener.OnInvalidation(s.storeName, index, key, writer)
+}
@@ -0,0 +1,73 @@
+package multiversion_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+type invalidation struct {
+	store  string
+	index  int
+	key    string
+	writer int
+}
+
+type invalidationLog struct {
+	mx            sync.Mutex
+	invalidations []invalidation
+}
+
+func (l *invalidationLog) OnInvalidation(storeName string, index int, key string, writer int) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	l.invalidations = append(l.invalidations, invalidation{store: storeName, index: index, key: key, writer: writer})
+}
+
+func TestInvalidationListener(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("parent"), []byte("value"))
+	log := &invalidationLog{}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithStoreName("bank"), multiversion.WithInvalidationListener(log))
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"written": []byte("value")})
+
+	vis := mvs.VersionedIndexedStore(5, 0, make(chan scheduler.Abort, 1))
+	vis.Get([]byte("written"))
+	vis.Get([]byte("parent"))
+	vis.Has([]byte("absent"))
+	vis.WriteToMultiVersionStore()
+	valid, _ := mvs.ValidateTransactionState(5)
+	require.True(t, valid)
+	require.Empty(t, log.invalidations)
+
+	// keys rewritten by lower-index txs are reported with their writers
+	mvs.SetWriteset(2, 0, multiversion.WriteSet{"written": []byte("other")})
+	mvs.SetWriteset(3, 0, multiversion.WriteSet{"absent": []byte("value")})
+	valid, _ = mvs.ValidateTransactionState(5)
+	require.False(t, valid)
+	require.ElementsMatch(t, []invalidation{
+		{store: "bank", index: 5, key: "written", writer: 2},
+		{store: "bank", index: 5, key: "absent", writer: 3},
+	}, log.invalidations)
+
+	// estimates don't invalidate, so they aren't reported
+	log.invalidations = nil
+	mvs.SetEstimatedWriteset(2, 1, multiversion.WriteSet{"written": nil})
+	mvs.SetWriteset(3, 1, multiversion.WriteSet{})
+	valid, _ = mvs.ValidateTransactionState(5)
+	require.True(t, valid)
+	require.Empty(t, log.invalidations)
+
+	// reads of the parent store that no longer match it have no writer
+	mvs.SetWriteset(2, 2, multiversion.WriteSet{})
+	mvs.SetWriteset(1, 1, multiversion.WriteSet{})
+	valid, _ = mvs.ValidateTransactionState(5)
+	require.False(t, valid)
+	require.Equal(t, []invalidation{{store: "bank", index: 5, key: "written", writer: -1}}, log.invalidations)
+}
@@ -0,0 +1,279 @@
+package multiversion_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// iteratorKeys are the keys of the iterator tests, including keys that are prefixes of each other and keys at the
+// edges of the byte range
+var iteratorKeys = []string{"a", "a\x00", "aa", "ab", "ab\xff", "b", "ba", "b\xff", "c", "\xff", "\xff\xff"}
+
+// iteratorBounds are the start and end bounds of the iterator tests: every key, keys between and around them, and nil
+var iteratorBounds = [][]byte{nil, []byte("\x00"), []byte("a"), []byte("a\x00"), []byte("aa"), []byte("ab"),
+	[]byte("ab\x00"), []byte("ac"), []byte("b"), []byte("b\xff"), []byte("bb"), []byte("c"), []byte("d"),
+	[]byte("\xff"), []byte("\xff\xff"), []byte("\xff\xff\xff")}
+
+// iteratorPrefixes are the prefixes of the prefix iterator tests
+var iteratorPrefixes = [][]byte{nil, []byte("a"), []byte("ab"), []byte("b"), []byte("c"), []byte("d"), []byte("\xff")}
+
+// newIteratorFixture returns a version indexed store of tx 2 layered over the writes of txs 0 and 1 and the parent
+// store, with writes and deletes of its own, along with the key-value pairs it's expected to observe. Every layer
+// overrides and deletes keys of the layers under it, and adds keys of its own.
+func newIteratorFixture(t *testing.T) (*multiversion.Store, *multiversion.VersionIndexedStore, map[string]string, []multiversion.WriteSet) {
+	parent := dbadapter.Store{DB: dbm.NewMemDB()}
+	expected := make(map[string]string)
+	for i, key := range iteratorKeys {
+		if i%3 != 2 {
+			parent.Set([]byte(key), []byte("parent-"+key))
+			expected[key] = "parent-" + key
+		}
+	}
+	mvs := multiversion.NewMultiVersionStore(parent)
+	writesets := make([]multiversion.WriteSet, 0, 2)
+	for index := 0; index < 2; index++ {
+		writeset := make(multiversion.WriteSet)
+		for i, key := range iteratorKeys {
+			switch (i + index) % 4 {
+			case 0:
+				writeset[key] = []byte(fmt.Sprintf("tx%d-%s", index, key))
+				expected[key] = string(writeset[key])
+			case 1:
+				writeset[key] = nil
+				delete(expected, key)
+			}
+		}
+		mvs.SetWriteset(index, 0, writeset)
+		writesets = append(writesets, writeset)
+	}
+
+	vis := mvs.VersionedIndexedStore(2, 0, make(chan scheduler.Abort, 1))
+	for i, key := range iteratorKeys {
+		switch i % 5 {
+		case 0:
+			vis.Set([]byte(key), []byte("tx2-"+key))
+			expected[key] = "tx2-" + key
+		case 1:
+			vis.Delete([]byte(key))
+			delete(expected, key)
+		case 2:
+			// reads populate the readset, which iterators serve values from
+			vis.Get([]byte(key))
+		}
+	}
+	return mvs, vis, expected, writesets
+}
+
+// expectedRange returns the expected key-value pairs within [start, end), in iteration order
+func expectedRange(expected map[string]string, start, end []byte, ascending bool) []string {
+	db := dbm.NewMemDB()
+	for key, value := range expected {
+		require.NoError(nil, db.Set([]byte(key), []byte(value)))
+	}
+	var iter dbm.Iterator
+	var err error
+	if ascending {
+		iter, err = db.Iterator(start, end)
+	} else {
+		iter, err = db.ReverseIterator(start, end)
+	}
+	if err != nil {
+		panic(err)
+	}
+	defer iter.Close()
+	var pairs []string
+	for ; iter.Valid(); iter.Next() {
+		pairs = append(pairs, string(iter.Key())+"="+string(iter.Value()))
+	}
+	return pairs
+}
+
+// newIterator returns an iterator over [start, end) of the store, and newPrefixIterator over the keys with the prefix.
+// Every iterator created is tracked by the store, so only the one iterated must be created.
+func newIterator(vis *multiversion.VersionIndexedStore, start, end []byte, ascending bool) types.Iterator {
+	if ascending {
+		return vis.Iterator(start, end)
+	}
+	return vis.ReverseIterator(start, end)
+}
+
+func newPrefixIterator(vis *multiversion.VersionIndexedStore, prefix []byte, ascending bool) types.Iterator {
+	if ascending {
+		return types.KVStorePrefixIterator(vis, prefix)
+	}
+	return types.KVStoreReversePrefixIterator(vis, prefix)
+}
+
+// collect returns the key-value pairs of an iterator, stopping after limit pairs if limit is positive
+func collect(iter types.Iterator, limit int) []string {
+	defer iter.Close()
+	var pairs []string
+	for ; iter.Valid(); iter.Next() {
+		pairs = append(pairs, string(iter.Key())+"="+string(iter.Value()))
+		if len(pairs) == limit {
+			break
+		}
+	}
+	return pairs
+}
+
+func TestVersionIndexedStoreIteratorBounds(t *testing.T) {
+	for _, start := range iteratorBounds {
+		for _, end := range iteratorBounds {
+			if start != nil && end != nil && string(start) > string(end) {
+				continue
+			}
+			for _, ascending := range []bool{true, false} {
+				name := fmt.Sprintf("%q-%q-ascending=%t", start, end, ascending)
+				mvs, vis, expected, _ := newIteratorFixture(t)
+				iter := newIterator(vis, start, end, ascending)
+				require.Equal(t, expectedRange(expected, start, end, ascending), collect(iter, 0), name)
+
+				// the iteration validates as long as the txs before it don't change
+				vis.WriteToMultiVersionStore()
+				valid, conflicts := mvs.ValidateTransactionState(2)
+				require.True(t, valid, name)
+				require.Empty(t, conflicts, name)
+			}
+		}
+	}
+}
+
+func TestVersionIndexedStorePrefixIterator(t *testing.T) {
+	for _, prefix := range iteratorPrefixes {
+		for _, ascending := range []bool{true, false} {
+			name := fmt.Sprintf("%q-ascending=%t", prefix, ascending)
+			mvs, vis, expected, _ := newIteratorFixture(t)
+			iter := newPrefixIterator(vis, prefix, ascending)
+			require.Equal(t, expectedRange(expected, prefix, types.PrefixEndBytes(prefix), ascending), collect(iter, 0), name)
+
+			vis.WriteToMultiVersionStore()
+			valid, _ := mvs.ValidateTransactionState(2)
+			require.True(t, valid, name)
+		}
+	}
+}
+
+func TestVersionIndexedStoreIteratorEarlyStop(t *testing.T) {
+	for _, prefix := range iteratorPrefixes {
+		for _, ascending := range []bool{true, false} {
+			name := fmt.Sprintf("%q-ascending=%t", prefix, ascending)
+			mvs, vis, expected, writesets := newIteratorFixture(t)
+			all := expectedRange(expected, prefix, types.PrefixEndBytes(prefix), ascending)
+			if len(all) < 2 {
+				continue
+			}
+			iter := newPrefixIterator(vis, prefix, ascending)
+			require.Equal(t, all[:1], collect(iter, 1), name)
+			vis.WriteToMultiVersionStore()
+			valid, _ := mvs.ValidateTransactionState(2)
+			require.True(t, valid, name)
+
+			// a key written by an earlier tx past the early stop doesn't invalidate the iteration, unlike an earlier tx
+			// deleting the key it stopped at, unless the tx wrote that key itself
+			first := all[0][:strings.IndexByte(all[0], '=')]
+			writeset := writesets[1]
+			if ascending {
+				writeset[first+"\x00\x00"] = []byte("late")
+				mvs.SetWriteset(1, 1, writeset)
+				valid, _ = mvs.ValidateTransactionState(2)
+				require.True(t, valid, name)
+			}
+			writeset[first] = nil
+			mvs.SetWriteset(1, 2, writeset)
+			_, ownWrite := vis.GetWriteset()[first]
+			valid, _ = mvs.ValidateTransactionState(2)
+			require.Equal(t, ownWrite, valid, name)
+		}
+	}
+}
+
+func TestCacheWrapPrefixIterator(t *testing.T) {
+	for _, prefix := range iteratorPrefixes {
+		for _, ascending := range []bool{true, false} {
+			name := fmt.Sprintf("%q-ascending=%t", prefix, ascending)
+			mvs, vis, expected, _ := newIteratorFixture(t)
+			// the branch buffers writes and deletes over the store's own
+			branch := vis.CacheWrap(nil).(types.KVStore)
+			for i, key := range iteratorKeys {
+				switch i % 3 {
+				case 0:
+					branch.Set([]byte(key), []byte("branch-"+key))
+					expected[key] = "branch-" + key
+				case 1:
+					branch.Delete([]byte(key))
+					delete(expected, key)
+				}
+			}
+			var iter types.Iterator
+			if ascending {
+				iter = types.KVStorePrefixIterator(branch, prefix)
+			} else {
+				iter = types.KVStoreReversePrefixIterator(branch, prefix)
+			}
+			require.Equal(t, expectedRange(expected, prefix, types.PrefixEndBytes(prefix), ascending), collect(iter, 0), name)
+
+			// the reads through the branch are tracked by the store, whether or not the branch is written
+			vis.WriteToMultiVersionStore()
+			valid, _ := mvs.ValidateTransactionState(2)
+			require.True(t, valid, name)
+		}
+	}
+}
+
+func TestCollectIteratorItemsInRange(t *testing.T) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	// every key is written by the tx of its position, half of them deleted, and tx 3 only leaves estimates
+	for i, key := range iteratorKeys {
+		var value []byte
+		if i%2 == 0 {
+			value = []byte(key)
+		}
+		if i == 3 {
+			mvs.SetEstimatedWriteset(i, 0, multiversion.WriteSet{key: nil})
+			continue
+		}
+		mvs.SetWriteset(i, 0, multiversion.WriteSet{key: value})
+	}
+	// tx 5 rewrites its writeset without its key, which leaves the key in the index without a version
+	mvs.SetWriteset(5, 1, multiversion.WriteSet{})
+
+	collect := func(items *dbm.MemDB) []string {
+		var keys []string
+		iter, err := items.Iterator(nil, nil)
+		require.NoError(t, err)
+		defer iter.Close()
+		for ; iter.Valid(); iter.Next() {
+			keys = append(keys, string(iter.Key()))
+		}
+		return keys
+	}
+	for index := 0; index <= len(iteratorKeys); index++ {
+		for _, start := range iteratorBounds {
+			for _, end := range iteratorBounds {
+				// the keys in the range with a version before the index
+				var expected []string
+				for i, key := range iteratorKeys {
+					if i < index && i != 5 && (start == nil || key >= string(start)) && (end == nil || key < string(end)) {
+						expected = append(expected, key)
+					}
+				}
+				require.Equal(t, expected, collect(mvs.CollectIteratorItemsInRange(index, start, end)), "index %d, range [%q, %q)", index, start, end)
+			}
+		}
+		require.Equal(t, collect(mvs.CollectIteratorItemsInRange(index, nil, nil)), collect(mvs.CollectIteratorItems(index)))
+	}
+
+	// the index doesn't outlive the block
+	mvs.Reset(dbadapter.Store{DB: dbm.NewMemDB()})
+	require.Empty(t, collect(mvs.CollectIteratorItems(len(iteratorKeys))))
+}
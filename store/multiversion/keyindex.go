@@ -0,0 +1,50 @@
+package multiversion
+
+import (
+	"sync"
+
+	"github.com/google/btree"
+)
+
+const keyIndexBTreeDegree = 32
+
+// keyIndex is a sorted index of every key written in the block, so that the keys in the range of an iterator are
+// found with a range scan of the index, rather than by walking the writesets of every tx before the iterating one. Keys
+// are never removed from the index during a block, since their items stay in the store once created.
+type keyIndex struct {
+	mx   sync.RWMutex
+	tree *btree.BTreeG[string]
+}
+
+func newKeyIndex() *keyIndex {
+	return &keyIndex{tree: btree.NewOrderedG[string](keyIndexBTreeDegree)}
+}
+
+// add adds a key written in the block to the index
+func (ki *keyIndex) add(key string) {
+	ki.mx.Lock()
+	defer ki.mx.Unlock()
+	ki.tree.ReplaceOrInsert(key)
+}
+
+// ascendRange calls fn with the keys in [start, end) in order, until it returns false. Nil bounds are unbounded.
+func (ki *keyIndex) ascendRange(start, end []byte, fn func(key string) bool) {
+	ki.mx.RLock()
+	defer ki.mx.RUnlock()
+	switch {
+	case start == nil && end == nil:
+		ki.tree.Ascend(fn)
+	case start == nil:
+		ki.tree.AscendLessThan(string(end), fn)
+	case end == nil:
+		ki.tree.AscendGreaterOrEqual(string(start), fn)
+	default:
+		ki.tree.AscendRange(string(start), string(end), fn)
+	}
+}
+
+func (ki *keyIndex) reset() {
+	ki.mx.Lock()
+	defer ki.mx.Unlock()
+	ki.tree.Clear(false)
+}
@@ -0,0 +1,101 @@
+package multiversion
+
+import (
+	"sync"
+
+	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// Operation is a kind of store operation that counts against a tx's resource limits
+type Operation int
+
+const (
+	// OperationRead is a Get or Has
+	OperationRead Operation = iota
+	// OperationWrite is a Set or Delete
+	OperationWrite
+	// OperationIterator is the creation of an iterator
+	OperationIterator
+	// OperationIteratorStep is a single Next call on an iterator
+	OperationIteratorStep
+)
+
+// Limiter bounds the resources a single tx may consume across its version indexed stores, so that one runaway tx
+// can't tie up a worker. Consume is called before every operation, and if it reports an exceeded limit the execution
+// is stopped by panicking with it. A Limiter is only used by a single execution, so it needn't be
+// thread-safe, unless the execution accesses its stores concurrently (see NewSyncLimiter).
+type Limiter interface {
+	Consume(op Operation) *scheduler.LimitExceeded
+}
+
+type operationLimiter struct {
+	maxOperations    int
+	maxIteratorSteps int
+	operations       int
+	iteratorSteps    int
+}
+
+var _ Limiter = (*operationLimiter)(nil)
+
+// NewOperationLimiter returns a Limiter allowing at most maxOperations reads, writes and iterator creations, and at
+// most maxIteratorSteps iterator steps. Non-positive values disable the respective limit.
+func NewOperationLimiter(maxOperations, maxIteratorSteps int) Limiter {
+	return &operationLimiter{
+		maxOperations:    maxOperations,
+		maxIteratorSteps: maxIteratorSteps,
+	}
+}
+
+// Consume implements Limiter.
+func (l *operationLimiter) Consume(op Operation) *scheduler.LimitExceeded {
+	if op == OperationIteratorStep {
+		l.iteratorSteps++
+		if l.maxIteratorSteps > 0 && l.iteratorSteps > l.maxIteratorSteps {
+			return &scheduler.LimitExceeded{Descriptor: "iterator steps", Limit: l.maxIteratorSteps}
+		}
+		return nil
+	}
+	l.operations++
+	if l.maxOperations > 0 && l.operations > l.maxOperations {
+		return &scheduler.LimitExceeded{Descriptor: "store operations", Limit: l.maxOperations}
+	}
+	return nil
+}
+
+type syncLimiter struct {
+	mtx     sync.Mutex
+	limiter Limiter
+}
+
+// NewSyncLimiter wraps a limiter to be safe to use from multiple goroutines, for executions whose stores have
+// concurrent access enabled
+func NewSyncLimiter(limiter Limiter) Limiter {
+	return &syncLimiter{limiter: limiter}
+}
+
+// Consume implements Limiter.
+func (l *syncLimiter) Consume(op Operation) *scheduler.LimitExceeded {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.limiter.Consume(op)
+}
+
+// SetLimiter sets the limiter that the store's operations count against. The same limiter may be shared by all of a
+// tx's version indexed stores to enforce limits across stores.
+func (store *VersionIndexedStore) SetLimiter(limiter Limiter) *VersionIndexedStore {
+	store.limiter = limiter
+	return store
+}
+
+// consume counts an operation, including against the store's limiter, panicking if a limit is exceeded or the
+// execution already aborted
+func (store *VersionIndexedStore) consume(op Operation) {
+	store.checkAborted()
+	store.countOperation(op)
+	if store.limiter == nil {
+		return
+	}
+	if exceeded := store.limiter.Consume(op); exceeded != nil {
+		panic(*exceeded)
+	}
+}
@@ -0,0 +1,62 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestVersionIndexedStoreOperationLimit(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	abortCh := make(chan scheduler.Abort, 1)
+
+	// the limiter is shared across stores, so operations on both count towards the same limit
+	limiter := multiversion.NewOperationLimiter(3, 0)
+	vis1 := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 1, 0, abortCh).SetLimiter(limiter)
+	vis2 := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 1, 0, abortCh).SetLimiter(limiter)
+
+	vis1.Set([]byte("key1"), []byte("value1"))
+	require.Equal(t, []byte("value1"), vis1.Get([]byte("key1")))
+	require.False(t, vis2.Has([]byte("key2")))
+	require.PanicsWithValue(t, scheduler.LimitExceeded{Descriptor: "store operations", Limit: 3}, func() {
+		vis2.Delete([]byte("key2"))
+	})
+	// the limit exceeded isn't an occ abort
+	require.Empty(t, abortCh)
+}
+
+func TestVersionIndexedStoreIteratorStepLimit(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	for _, key := range []string{"key1", "key2", "key3", "key4"} {
+		parentKVStore.Set([]byte(key), []byte("value"))
+	}
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 1, 0, make(chan scheduler.Abort, 1))
+	vis.SetLimiter(multiversion.NewOperationLimiter(0, 2))
+
+	iter := vis.Iterator(nil, nil)
+	defer iter.Close()
+	iter.Next()
+	iter.Next()
+	require.True(t, iter.Valid())
+	require.Equal(t, []byte("value"), iter.Value())
+	require.PanicsWithValue(t, scheduler.LimitExceeded{Descriptor: "iterator steps", Limit: 2}, func() {
+		iter.Next()
+	})
+}
+
+func TestVersionIndexedStoreNoLimiter(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 1, 0, make(chan scheduler.Abort, 1))
+	for i := 0; i < 100; i++ {
+		vis.Set([]byte("key"), []byte("value"))
+		require.Equal(t, []byte("value"), vis.Get([]byte("key")))
+	}
+}
@@ -46,7 +46,8 @@ func (store *VersionIndexedStore) newMemIterator(
 func (mi *memIterator) Value() []byte {
 	key := mi.Iterator.Key()
 	// TODO: verify that this is correct
-	return mi.mvkv.Get(key)
+	// values served while iterating count as iterator steps rather than reads against the tx's limits
+	return types.CopyBytes(mi.mvkv.get(key))
 }
 
 type validationIterator struct {
@@ -56,6 +57,7 @@ type validationIterator struct {
 	writeset     WriteSet
 	index        int
 	abortChannel chan occtypes.Abort
+	storeName    string
 
 	// this ensure that we serve consistent values throughout the lifecycle of the validationIterator - this should prevent race conditions causing an iterator to become invalid while being used
 	readCache map[string][]byte
@@ -90,6 +92,7 @@ func (store *Store) newMVSValidationIterator(
 		mvStore:      store,
 		index:        index,
 		abortChannel: abortChannel,
+		storeName:    store.storeName,
 		writeset:     writeset,
 		readCache:    make(map[string][]byte),
 	}
@@ -113,7 +116,7 @@ func (vi *validationIterator) Value() []byte {
 
 	// if we have an estimate, write to abort channel
 	if val.IsEstimate() {
-		vi.abortChannel <- occtypes.NewEstimateAbort(val.Index())
+		sendAbort(vi.abortChannel, occtypes.NewIteratorConflictAbort(val.Index(), vi.storeName, key))
 	}
 
 	// if we have a deleted value, return nil
@@ -0,0 +1,204 @@
+package multiversion
+
+import (
+	"sync"
+	"sync/atomic"
+
+	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// MemoryMeter accounts the approximate bytes held by the readsets and writesets of a tx's version indexed stores, as
+// the lengths of the keys and values it read and wrote, and bounds them by a limit so that a single tx can't exhaust
+// the memory of the node. Writes buffered in branches of the stores (see CacheWrap) only
+// count once they're written. The meter is thread-safe, and is meant to be shared by all of a tx's stores.
+type MemoryMeter struct {
+	limit int64
+	// only accessed atomically
+	used int64
+	// bytes held when the limit was first exceeded, or 0 if it wasn't, only accessed atomically
+	exceededAt int64
+}
+
+// NewMemoryMeter returns a MemoryMeter allowing at most limit bytes. A non-positive limit only accounts the bytes.
+func NewMemoryMeter(limit int) *MemoryMeter {
+	return &MemoryMeter{limit: int64(limit)}
+}
+
+// Consume accounts a change in the bytes held, which may be negative (eg. when a write is overwritten by a smaller
+// one). It returns the exceeded limit if the bytes held exceed it, or ever did, since the tx may have recovered the
+// panic of the first excess.
+func (m *MemoryMeter) Consume(bytes int) *scheduler.MemoryLimitExceeded {
+	used := atomic.AddInt64(&m.used, int64(bytes))
+	if m.limit > 0 && used > m.limit {
+		atomic.CompareAndSwapInt64(&m.exceededAt, 0, used)
+	}
+	return m.Exceeded()
+}
+
+// Used returns the bytes currently held
+func (m *MemoryMeter) Used() int {
+	return int(atomic.LoadInt64(&m.used))
+}
+
+// Exceeded returns the first excess of the limit, or nil if the limit was never exceeded
+func (m *MemoryMeter) Exceeded() *scheduler.MemoryLimitExceeded {
+	exceededAt := atomic.LoadInt64(&m.exceededAt)
+	if exceededAt == 0 {
+		return nil
+	}
+	return &scheduler.MemoryLimitExceeded{Limit: int(m.limit), Used: int(exceededAt)}
+}
+
+// SetMemoryMeter sets the meter that the bytes held by the store's readset and writeset count against
+func (store *VersionIndexedStore) SetMemoryMeter(meter *MemoryMeter) *VersionIndexedStore {
+	store.memoryMeter = meter
+	return store
+}
+
+// meterMemory accounts a change in the bytes held by the store, panicking if the memory limit is exceeded
+func (store *VersionIndexedStore) meterMemory(bytes int) {
+	if store.memoryMeter == nil {
+		return
+	}
+	if exceeded := store.memoryMeter.Consume(bytes); exceeded != nil {
+		panic(*exceeded)
+	}
+}
+
+// meterUntrackedRead accounts the first read of a key while reads aren't tracked as if it had been added to the
+// readset, so that the bytes accounted for a tx don't depend on whether it's validated
+func (store *VersionIndexedStore) meterUntrackedRead(key string, value []byte) {
+	if store.memoryMeter == nil {
+		return
+	}
+	if store.untrackedReads == nil {
+		store.untrackedReads = make(map[string]struct{})
+	}
+	if _, ok := store.untrackedReads[key]; ok {
+		return
+	}
+	store.untrackedReads[key] = struct{}{}
+	store.meterMemory(len(key) + len(value))
+}
+
+// MemoryUsage is the approximate bytes held by the block state of a multiversion store, as the lengths of the keys
+// and values of the writesets and readsets of its txs. Superseded versions pruned from the store, versions committed to
+// the parent store and readsets kept as digests or spilled to disk are still counted in full, so it's an upper bound of
+// what the store holds.
+type MemoryUsage struct {
+	// Writesets are the bytes of the latest writesets of the txs, which the versions of the store hold. Estimates
+	// only count their keys.
+	Writesets int64
+	// Readsets are the bytes of the latest readsets of the txs
+	Readsets int64
+}
+
+// Total returns the bytes of the writesets and readsets
+func (u MemoryUsage) Total() int64 {
+	return u.Writesets + u.Readsets
+}
+
+// Add returns the sum of two usages, eg. to total the usages of the stores of a block
+func (u MemoryUsage) Add(other MemoryUsage) MemoryUsage {
+	return MemoryUsage{Writesets: u.Writesets + other.Writesets, Readsets: u.Readsets + other.Readsets}
+}
+
+// blockMemory accounts the bytes held by the writesets and readsets of the txs of a block, replacing those of a tx
+// whenever it sets new ones
+type blockMemory struct {
+	mx        sync.Mutex
+	writesets map[int]int64
+	readsets  map[int]int64
+	usage     MemoryUsage
+}
+
+func (m *blockMemory) setWriteset(index int, bytes int64) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.writesets == nil {
+		m.writesets = make(map[int]int64)
+	}
+	m.usage.Writesets += bytes - m.writesets[index]
+	m.writesets[index] = bytes
+}
+
+// shrinkWriteset removes bytes from the writeset of a tx, eg. its estimates once they're removed
+func (m *blockMemory) shrinkWriteset(index int, bytes int64) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.writesets == nil {
+		return
+	}
+	if bytes > m.writesets[index] {
+		bytes = m.writesets[index]
+	}
+	m.usage.Writesets -= bytes
+	m.writesets[index] -= bytes
+}
+
+func (m *blockMemory) setReadset(index int, bytes int64) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.readsets == nil {
+		m.readsets = make(map[int]int64)
+	}
+	m.usage.Readsets += bytes - m.readsets[index]
+	if bytes == 0 {
+		delete(m.readsets, index)
+		return
+	}
+	m.readsets[index] = bytes
+}
+
+func (m *blockMemory) get() MemoryUsage {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	return m.usage
+}
+
+func (m *blockMemory) reset() {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.writesets = nil
+	m.readsets = nil
+	m.usage = MemoryUsage{}
+}
+
+// MemoryUsage returns the approximate bytes held by the writesets and readsets of the txs of the block. It's safe to
+// call while txs are executed and validated against the store.
+func (s *Store) MemoryUsage() MemoryUsage {
+	return s.memory.get()
+}
+
+// writesetBytes returns the bytes of the keys and values of a writeset, or only of its keys for estimates
+func writesetBytes(writeset WriteSet, estimate bool) int64 {
+	var bytes int64
+	for key, value := range writeset {
+		bytes += int64(len(key))
+		if !estimate {
+			bytes += int64(len(value))
+		}
+	}
+	return bytes
+}
+
+// readsetBytes returns the bytes of the keys and values of a readset
+func readsetBytes(readset ReadSet) int64 {
+	var bytes int64
+	for key, values := range readset {
+		bytes += int64(len(key))
+		for _, value := range values {
+			bytes += int64(len(value))
+		}
+	}
+	return bytes
+}
+
+// keysBytes returns the bytes of keys
+func keysBytes(keys []string) int64 {
+	var bytes int64
+	for _, key := range keys {
+		bytes += int64(len(key))
+	}
+	return bytes
+}
@@ -0,0 +1,109 @@
+package multiversion_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestVersionIndexedStoreMemoryMeter(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("key1"), []byte("parent1"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key2": []byte("value2")})
+	abortCh := make(chan scheduler.Abort, 1)
+
+	// the meter is shared across stores, so the bytes held by both count towards the same limit
+	meter := multiversion.NewMemoryMeter(40)
+	vis1 := mvs.VersionedIndexedStore(2, 0, abortCh).SetMemoryMeter(meter)
+	vis2 := mvs.VersionedIndexedStore(2, 0, abortCh).SetMemoryMeter(meter)
+
+	// reads count their key and value once
+	vis1.Get([]byte("key1"))
+	vis1.Get([]byte("key1"))
+	require.Equal(t, 11, meter.Used())
+	// existence checks only count their key
+	vis2.Has([]byte("key2"))
+	require.Equal(t, 15, meter.Used())
+
+	// writes count their key once, and overwrites only the difference in value
+	vis1.Set([]byte("key3"), []byte("value3"))
+	require.Equal(t, 25, meter.Used())
+	vis1.Set([]byte("key3"), []byte("v3"))
+	require.Equal(t, 21, meter.Used())
+	vis1.Delete([]byte("key3"))
+	require.Equal(t, 19, meter.Used())
+	require.Nil(t, meter.Exceeded())
+
+	require.PanicsWithValue(t, scheduler.MemoryLimitExceeded{Limit: 40, Used: 45}, func() {
+		vis2.Set([]byte("key4"), bytes.Repeat([]byte{1}, 22))
+	})
+	// the limit stays exceeded, even if the tx recovered the panic and frees up bytes
+	require.Panics(t, func() { vis2.Set([]byte("key4"), nil) })
+	require.Equal(t, &scheduler.MemoryLimitExceeded{Limit: 40, Used: 45}, meter.Exceeded())
+	// the limit exceeded isn't an occ abort
+	require.Empty(t, abortCh)
+}
+
+func TestVersionIndexedStoreMemoryMeterUntrackedReads(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	for _, key := range []string{"key1", "key2", "key3"} {
+		parentKVStore.Set([]byte(key), []byte("value"))
+	}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+
+	// the same reads count the same bytes whether or not they're tracked
+	used := func(disableTracking bool) int {
+		meter := multiversion.NewMemoryMeter(0)
+		vis := mvs.VersionedIndexedStore(1, 0, make(chan scheduler.Abort, 1)).SetMemoryMeter(meter)
+		if disableTracking {
+			vis.DisableReadTracking()
+		}
+		vis.Get([]byte("key1"))
+		vis.Get([]byte("key1"))
+		iter := vis.Iterator(nil, nil)
+		for ; iter.Valid(); iter.Next() {
+			iter.Value()
+		}
+		require.NoError(t, iter.Close())
+		vis.Get([]byte("key3"))
+		return meter.Used()
+	}
+	require.Equal(t, 27, used(false))
+	require.Equal(t, used(false), used(true))
+}
+
+func TestMultiVersionStoreMemoryUsage(t *testing.T) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	require.Equal(t, multiversion.MemoryUsage{}, mvs.MemoryUsage())
+
+	// writesets count their keys and values, and replace the previous writeset of the tx
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": []byte("value1"), "key2": nil})
+	require.Equal(t, multiversion.MemoryUsage{Writesets: 14}, mvs.MemoryUsage())
+	mvs.SetWriteset(1, 1, multiversion.WriteSet{"key1": []byte("v1")})
+	require.Equal(t, multiversion.MemoryUsage{Writesets: 6}, mvs.MemoryUsage())
+
+	// estimates only count their keys
+	mvs.InvalidateWriteset(1, 1)
+	require.Equal(t, multiversion.MemoryUsage{Writesets: 4}, mvs.MemoryUsage())
+	mvs.SetEstimatedWriteset(2, 0, multiversion.WriteSet{"key3": nil, "key4": nil})
+	require.Equal(t, multiversion.MemoryUsage{Writesets: 12}, mvs.MemoryUsage())
+	mvs.RemoveEstimatesForIndex(2)
+	require.Equal(t, multiversion.MemoryUsage{Writesets: 4}, mvs.MemoryUsage())
+
+	// readsets count their keys and every value observed, and are released when cleared
+	mvs.SetReadset(3, multiversion.ReadSet{"key1": [][]byte{[]byte("v1"), []byte("value1")}})
+	require.Equal(t, multiversion.MemoryUsage{Writesets: 4, Readsets: 12}, mvs.MemoryUsage())
+	require.Equal(t, int64(16), mvs.MemoryUsage().Total())
+	mvs.ClearReadset(3)
+	require.Equal(t, multiversion.MemoryUsage{Writesets: 4}, mvs.MemoryUsage())
+
+	mvs.Reset(dbadapter.Store{DB: dbm.NewMemDB()})
+	require.Equal(t, multiversion.MemoryUsage{}, mvs.MemoryUsage())
+}
@@ -0,0 +1,68 @@
+package multiversion
+
+import (
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// Store metadata, eg. the latest version of a store or its commit info, isn't read through the KVStore interface, so
+// reads of it by a tx aren't in its readset and aren't validated. Metadata read live while the block executes may
+// differ between incarnations and between nodes, depending on when each execution ran. Txs should instead read
+// metadata from a snapshot captured at the start of the block, see WithMetadataSnapshot and ReadMetadata, and register
+// any key they read around the store, eg. through a reference to its parent, with RegisterRead.
+
+// MetadataReader is implemented by stores that serve reads of store metadata and state read around the store
+// deterministically under OCC
+type MetadataReader interface {
+	// Metadata returns the value of the named metadata as of the start of the block, if it was captured
+	Metadata(name string) ([]byte, bool)
+	// RegisterRead records a read of key in the readset, as if it was read through the store
+	RegisterRead(key []byte)
+}
+
+var _ MetadataReader = (*VersionIndexedStore)(nil)
+
+// WithMetadataSnapshot sets the metadata of the store as of the start of the block, by name, which version indexed
+// stores serve through Metadata for the whole block. The values are copied.
+func WithMetadataSnapshot(metadata map[string][]byte) StoreOption {
+	snapshot := make(map[string][]byte, len(metadata))
+	for name, value := range metadata {
+		snapshot[name] = types.CopyBytes(value)
+	}
+	return func(s *Store) {
+		s.metadata = snapshot
+	}
+}
+
+// Metadata implements MetadataReader. The returned value is a copy that the caller may freely mutate.
+func (store *VersionIndexedStore) Metadata(name string) ([]byte, bool) {
+	store.checkAborted()
+	value, ok := store.metadata[name]
+	return types.CopyBytes(value), ok
+}
+
+// RegisterRead implements MetadataReader. The key is read from the versions of earlier txs like any other read, so
+// the tx aborts on an estimate and is invalidated if the key is rewritten.
+func (store *VersionIndexedStore) RegisterRead(key []byte) {
+	defer store.lock()()
+	store.consume(OperationRead)
+	store.logAccess(AccessGet, key, nil)
+	store.get(key)
+}
+
+// ReadMetadata reads the named metadata of a store from the snapshot captured at the start of the block if the store
+// is a MetadataReader, eg. a version indexed store, and from live otherwise, eg. when the block executes sequentially.
+// Metadata missing from the snapshot can't be read deterministically under OCC, so ReadMetadata panics rather than
+// falling back to live.
+func ReadMetadata(store types.KVStore, name string, live func() []byte) []byte {
+	reader, ok := store.(MetadataReader)
+	if !ok {
+		return live()
+	}
+	value, ok := reader.Metadata(name)
+	if !ok {
+		panic(fmt.Sprintf("store metadata %q wasn't captured at the start of the block", name))
+	}
+	return value
+}
@@ -0,0 +1,66 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestMultiVersionStoreMetadata(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	metadata := map[string][]byte{"version": []byte("10")}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithMetadataSnapshot(metadata))
+	// the snapshot is a copy
+	metadata["version"][0] = '2'
+
+	vis := mvs.VersionedIndexedStore(1, 0, make(chan occ.Abort, 1))
+	value, ok := vis.Metadata("version")
+	require.True(t, ok)
+	require.Equal(t, []byte("10"), value)
+	// so are the values read from it
+	value[0] = '3'
+	value, _ = vis.Metadata("version")
+	require.Equal(t, []byte("10"), value)
+	_, ok = vis.Metadata("commit")
+	require.False(t, ok)
+
+	live := func() []byte { return []byte("11") }
+	require.Equal(t, []byte("10"), multiversion.ReadMetadata(vis, "version", live))
+	// stores that don't read from a snapshot read live
+	require.Equal(t, []byte("11"), multiversion.ReadMetadata(parentKVStore, "version", live))
+	// metadata that wasn't captured can't be read deterministically
+	require.Panics(t, func() { multiversion.ReadMetadata(vis, "commit", live) })
+
+	// the snapshot is cleared along with the block state
+	mvs.Reset(parentKVStore)
+	vis = mvs.VersionedIndexedStore(1, 0, make(chan occ.Abort, 1))
+	_, ok = vis.Metadata("version")
+	require.False(t, ok)
+}
+
+func TestVersionIndexedStoreRegisterRead(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("key"), []byte("value"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+
+	// tx 2 reads the key around the store, and registers the read
+	vis := mvs.VersionedIndexedStore(2, 0, make(chan occ.Abort, 1))
+	vis.RegisterRead([]byte("key"))
+	require.Equal(t, [][]byte{[]byte("value")}, vis.GetReadset()["key"])
+	vis.WriteToMultiVersionStore()
+
+	valid, conflicts := mvs.ValidateTransactionState(2)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+
+	// the registered read is invalidated by an earlier tx writing the key
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key": []byte("other")})
+	valid, conflicts = mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+	require.Equal(t, []int{1}, conflicts)
+}
@@ -4,10 +4,16 @@ import (
 	"bytes"
 	"io"
 	"sort"
+	"sync"
+	"time"
 
 	abci "github.com/tendermint/tendermint/abci/types"
 
+	"github.com/cosmos/cosmos-sdk/internal/failpoint"
+	"github.com/cosmos/cosmos-sdk/store/listenkv"
+	"github.com/cosmos/cosmos-sdk/store/tracekv"
 	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/telemetry"
 	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
 	dbm "github.com/tendermint/tm-db"
 )
@@ -70,16 +76,124 @@ func (item *iterationTracker) SetEarlyStopKey(key []byte) {
 	item.earlyStopKey = key
 }
 
+// containsAny returns whether any of the keys fall within the iteration range
+func (item *iterationTracker) containsAny(keys []string) bool {
+	for _, key := range keys {
+		if item.startKey != nil && key < string(item.startKey) {
+			continue
+		}
+		if item.endKey != nil && key >= string(item.endKey) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// containsAnyBefore returns whether any of the dirty keys dirtied by a tx before index fall within the iteration range
+func (item *iterationTracker) containsAnyBefore(index int, dirty map[string]int) bool {
+	for key, writer := range dirty {
+		if writer < index && item.containsAny([]string{key}) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendAbort performs a non-blocking send of an abort to the abort channel. A single store can emit
+// multiple aborts during one execution (eg. if the tx recovers from the abort panic and keeps reading), but
+// only the first abort is consumed by the scheduler, so any aborts that don't fit in the buffer are dropped
+// rather than blocking the executing goroutine forever.
+func sendAbort(abortChannel chan scheduler.Abort, abort scheduler.Abort) {
+	select {
+	case abortChannel <- abort:
+	default:
+		telemetry.IncrCounter(1, "store", "mvkv", "abort_dropped")
+	}
+}
+
+// Observer is a read-only view of a transaction's in-flight readset and writeset, useful for middleware that wants
+// to inspect what a tx touched without being able to mutate the version indexed store. All returned sets are copies.
+type Observer interface {
+	TransactionIndex() int
+	Incarnation() int
+	Readset() ReadSet
+	Writeset() WriteSet
+	HasRead(key []byte) bool
+	HasWritten(key []byte) bool
+}
+
+type versionIndexedStoreObserver struct {
+	store *VersionIndexedStore
+}
+
+var _ Observer = (*versionIndexedStoreObserver)(nil)
+
+// TransactionIndex implements Observer.
+func (o *versionIndexedStoreObserver) TransactionIndex() int {
+	return o.store.transactionIndex
+}
+
+// Incarnation implements Observer.
+func (o *versionIndexedStoreObserver) Incarnation() int {
+	return o.store.incarnation
+}
+
+// Readset implements Observer.
+func (o *versionIndexedStoreObserver) Readset() ReadSet {
+	defer o.store.lock()()
+	readset := make(ReadSet, len(o.store.readset))
+	for key, values := range o.store.readset {
+		copyValues := make([][]byte, 0, len(values))
+		for _, value := range values {
+			copyValues = append(copyValues, types.CopyBytes(value))
+		}
+		readset[key] = copyValues
+	}
+	return readset
+}
+
+// Writeset implements Observer.
+func (o *versionIndexedStoreObserver) Writeset() WriteSet {
+	defer o.store.lock()()
+	writeset := make(WriteSet, len(o.store.writeset))
+	for key, value := range o.store.writeset {
+		writeset[key] = types.CopyBytes(value)
+	}
+	return writeset
+}
+
+// HasRead implements Observer.
+func (o *versionIndexedStoreObserver) HasRead(key []byte) bool {
+	defer o.store.lock()()
+	_, ok := o.store.readset[string(key)]
+	return ok
+}
+
+// HasWritten implements Observer.
+func (o *versionIndexedStoreObserver) HasWritten(key []byte) bool {
+	defer o.store.lock()()
+	_, ok := o.store.writeset[string(key)]
+	return ok
+}
+
 // Version Indexed Store wraps the multiversion store in a way that implements the KVStore interface, but also stores the index of the transaction, and so store actions are applied to the multiversion store using that index
 type VersionIndexedStore struct {
-	// TODO: this shouldnt NEED a mutex because its used within single transaction execution, therefore no concurrency
-	// mtx sync.Mutex
+	// guards the store if concurrent access was enabled, for txs that access it from multiple goroutines. Stores are
+	// otherwise used by a single tx execution, so they aren't locked.
+	mtx *sync.Mutex
 	// used for tracking reads and writes for eventual validation + persistence into multi-version store
 	// TODO: does this need sync.Map?
 	readset    map[string][][]byte // contains the key -> []value mapping for all keys read from the store (not mvkv, underlying store)
 	writeset   map[string][]byte   // contains the key -> value mapping for all keys written to the store
 	iterateset Iterateset
 	// TODO: need to add iterateset here as well
+	// whether the keys the tx only checked the existence of existed, see ExistenceSet
+	existenceset ExistenceSet
+
+	// generations of the keys read directly (rather than through iterators) as of their first read, see
+	// SetReadsetWithGenerations
+	readGenerations map[string]uint64
 
 	// used for iterators - populated at the time of iterator instantiation
 	// TODO: when we want to perform iteration, we need to move all the dirty keys (writeset and readset) into the sortedTree and then combine with the iterators for the underlying stores
@@ -92,6 +206,37 @@ type VersionIndexedStore struct {
 	incarnation      int
 	// have abort channel here for aborting transactions
 	abortChannel chan scheduler.Abort
+	// if set, the first abort of the execution, shared by all of its stores, see SetAbortSignal
+	abortSignal *scheduler.AbortSignal
+	// name of the multiversion store, to identify the store in aborts
+	storeName string
+	// keys interned by the multiversion store, shared with it, if any
+	keys *keyTable
+	// whether GetUnsafe may return internal slices without copying
+	unsafeGetEnabled bool
+	// optional per-tx resource limits
+	limiter Limiter
+	// optional per-tx memory accounting, and the keys read while reads aren't tracked, see meterUntrackedRead
+	memoryMeter    *MemoryMeter
+	untrackedReads map[string]struct{}
+	// optional per-tx caps on the entries recorded for validation, see SetTrackingMeter
+	trackingMeter *TrackingMeter
+	// whether reads are left out of the readset and iterateset, for txs that are known not to need validation
+	readTrackingDisabled bool
+	// operations counted locally, and the totals of the multiversion store they're flushed to on writes, if any
+	operations      operationCounts
+	operationTotals *operationCounts
+	// read latencies recorded locally, and the histograms of the multiversion store they're flushed to on writes
+	readLatency       [numReadSources]latencyHistogram
+	readLatencyTotals *[numReadSources]latencyHistogram
+	// the estimate counts of the multiversion store, which reads hitting an estimate are counted in, if any
+	estimateTotals *estimateStats
+	// the access log of the multiversion store, if any, see WithAccessLog
+	accessLog *AccessLog
+	// the metadata snapshot of the multiversion store, see WithMetadataSnapshot
+	metadata map[string][]byte
+	// the parent read cache of the multiversion store, see WithParentReadCache
+	readCache *parentReadCache
 }
 
 var _ types.KVStore = (*VersionIndexedStore)(nil)
@@ -101,8 +246,10 @@ var _ IterateSetHandler = (*VersionIndexedStore)(nil)
 func NewVersionIndexedStore(parent types.KVStore, multiVersionStore MultiVersionStore, transactionIndex, incarnation int, abortChannel chan scheduler.Abort) *VersionIndexedStore {
 	return &VersionIndexedStore{
 		readset:           make(map[string][][]byte),
+		readGenerations:   make(map[string]uint64),
 		writeset:          make(map[string][]byte),
 		iterateset:        []*iterationTracker{},
+		existenceset:      make(ExistenceSet),
 		sortedStore:       dbm.NewMemDB(),
 		parent:            parent,
 		multiVersionStore: multiVersionStore,
@@ -112,6 +259,37 @@ func NewVersionIndexedStore(parent types.KVStore, multiVersionStore MultiVersion
 	}
 }
 
+// reset clears the store for another incarnation of its tx, as if it was newly created, keeping its parents and the
+// multiversion store's tables it's wired to. The readset, iterateset and existence set were handed to the multiversion
+// store, which keeps them, so they're replaced rather than cleared. Options set on the store are cleared too.
+func (store *VersionIndexedStore) reset(incarnation int, abortChannel chan scheduler.Abort) {
+	store.mtx = nil
+	store.readset = make(map[string][][]byte)
+	store.readGenerations = make(map[string]uint64)
+	for key := range store.writeset {
+		delete(store.writeset, key)
+	}
+	store.iterateset = []*iterationTracker{}
+	store.existenceset = make(ExistenceSet)
+	store.sortedStore = dbm.NewMemDB()
+	store.incarnation = incarnation
+	store.abortChannel = abortChannel
+	store.abortSignal = nil
+	store.unsafeGetEnabled = false
+	store.limiter = nil
+	store.memoryMeter = nil
+	store.untrackedReads = nil
+	store.trackingMeter = nil
+	store.readTrackingDisabled = false
+	store.operations = operationCounts{}
+	store.readLatency = [numReadSources]latencyHistogram{}
+}
+
+// Observer returns a read-only view of the store's in-flight readset and writeset
+func (store *VersionIndexedStore) Observer() Observer {
+	return &versionIndexedStoreObserver{store: store}
+}
+
 // GetReadset returns the readset
 func (store *VersionIndexedStore) GetReadset() map[string][][]byte {
 	return store.readset
@@ -122,19 +300,102 @@ func (store *VersionIndexedStore) GetWriteset() map[string][]byte {
 	return store.writeset
 }
 
-// Get implements types.KVStore.
+// EnableUnsafeGet allows GetUnsafe to return internal slices without copying. It should only be enabled for
+// hot read paths where the copy in Get shows up in profiles and every GetUnsafe caller is known not to mutate.
+func (store *VersionIndexedStore) EnableUnsafeGet() *VersionIndexedStore {
+	store.unsafeGetEnabled = true
+	return store
+}
+
+// DisableReadTracking stops recording reads in the readset and iterateset, and writing them to the multiversion store.
+// It's only safe for txs that won't be validated, eg. because their writesets are guaranteed to be disjoint from
+// every other tx in the block.
+func (store *VersionIndexedStore) DisableReadTracking() *VersionIndexedStore {
+	store.readTrackingDisabled = true
+	return store
+}
+
+// EnableConcurrentAccess makes the store safe to use from multiple goroutines, for txs whose handlers or hooks read
+// and write state concurrently (eg. from goroutines spawned while executing the tx). Every operation locks the store,
+// including the steps of its iterators, so that the readset, writeset and iterateset stay consistent. Aborts still
+// panic on the goroutine that hit them, which must hand the panic back to the tx. If a limiter is shared with other
+// stores, it must be thread-safe as well, see NewSyncLimiter.
+func (store *VersionIndexedStore) EnableConcurrentAccess() *VersionIndexedStore {
+	store.mtx = &sync.Mutex{}
+	return store
+}
+
+// SetAbortSignal sets the signal that the store's aborts are recorded in. Once any store sharing the signal aborted,
+// every operation on the store panics with that abort, so that a tx that recovered the abort can't carry on.
+func (store *VersionIndexedStore) SetAbortSignal(signal *scheduler.AbortSignal) *VersionIndexedStore {
+	store.abortSignal = signal
+	return store
+}
+
+// checkAborted panics with the abort signaled for the execution, if any. The abort was already sent when it was
+// first hit, so it isn't sent again.
+func (store *VersionIndexedStore) checkAborted() {
+	if store.abortSignal == nil {
+		return
+	}
+	if abort := store.abortSignal.Aborted(); abort != nil {
+		telemetry.IncrCounter(1, "store", "mvkv", "abort_propagated")
+		panic(*abort)
+	}
+}
+
+// lock locks the store if concurrent access was enabled, returning the function unlocking it
+func (store *VersionIndexedStore) lock() func() {
+	if store.mtx == nil {
+		return noopUnlock
+	}
+	store.mtx.Lock()
+	return store.mtx.Unlock
+}
+
+func noopUnlock() {}
+
+// Get implements types.KVStore. The returned value is a copy that the caller may freely mutate.
 func (store *VersionIndexedStore) Get(key []byte) []byte {
+	defer store.lock()()
+	store.consume(OperationRead)
+	store.logAccess(AccessGet, key, nil)
+	return types.CopyBytes(store.get(key))
+}
+
+// GetUnsafe behaves like Get, but if unsafe gets were enabled via EnableUnsafeGet, the returned value is the internal
+// slice held by the writeset, readset, or parent store. Callers MUST NOT mutate the returned value (or retain it
+// across writes to the store), since doing so corrupts the readset used for validation. If unsafe gets aren't
+// enabled, this returns a copy like Get.
+func (store *VersionIndexedStore) GetUnsafe(key []byte) []byte {
+	defer store.lock()()
+	store.consume(OperationRead)
+	store.logAccess(AccessGet, key, nil)
+	if !store.unsafeGetEnabled {
+		return types.CopyBytes(store.get(key))
+	}
+	return store.get(key)
+}
+
+// abortOnEstimate aborts the execution of the store's tx on a read of key that hit an estimate of the dependent tx
+func (store *VersionIndexedStore) abortOnEstimate(dependentTxIdx int, key []byte) {
+	store.countEstimateRead()
+	abort := scheduler.NewEstimateAbort(dependentTxIdx, store.storeName, key)
+	sendAbort(store.abortChannel, abort)
+	if store.abortSignal != nil {
+		store.abortSignal.Signal(abort)
+	}
+	panic(abort)
+}
+
+func (store *VersionIndexedStore) get(key []byte) []byte {
 	// first try to get from writeset cache, if cache miss, then try to get from multiversion store, if that misses, then get from parent store
 	// if the key is in the cache, return it
 
-	// don't have RW mutex because we have to update readset
-	// TODO: remove?
-	// store.mtx.Lock()
-	// defer store.mtx.Unlock()
 	// defer telemetry.MeasureSince(time.Now(), "store", "mvkv", "get")
 
 	types.AssertValidKey(key)
-	strKey := string(key)
+	strKey := store.keys.intern(key)
 	// first check the MVKV writeset, and return that value if present
 	cacheValue, ok := store.writeset[strKey]
 	if ok {
@@ -148,19 +409,23 @@ func (store *VersionIndexedStore) Get(key []byte) []byte {
 	}
 
 	// if we didn't find it, then we want to check the multivalue store + add to readset if applicable
-	mvsValue := store.multiVersionStore.GetLatestBeforeIndex(store.transactionIndex, key)
+	if failpoint.EstimateRead(store.storeName, store.transactionIndex, store.incarnation, key) {
+		store.abortOnEstimate(store.transactionIndex-1, key)
+	}
+	start := time.Now()
+	mvsValue, generation := store.multiVersionStore.GetLatestBeforeIndexWithGeneration(store.transactionIndex, key)
 	if mvsValue != nil {
 		if mvsValue.IsEstimate() {
-			abort := scheduler.NewEstimateAbort(mvsValue.Index())
-			store.abortChannel <- abort
-			panic(abort)
+			store.abortOnEstimate(mvsValue.Index(), key)
 		} else {
+			store.recordRead(ReadSourceMultiVersion, start)
+			store.recordGeneration(strKey, generation)
 			// This handles both detecting readset conflicts and updating readset if applicable
 			return store.parseValueAndUpdateReadset(strKey, mvsValue)
 		}
 	}
 	// if we didn't find it in the multiversion store, then we want to check the parent store + add to readset
-	parentValue := store.parent.Get(key)
+	parentValue := store.readParent(key, strKey)
 	store.UpdateReadSet(key, parentValue)
 	return parentValue
 }
@@ -177,9 +442,7 @@ func (store *VersionIndexedStore) parseValueAndUpdateReadset(strKey string, mvsV
 
 // This function iterates over the readset, validating that the values in the readset are consistent with the values in the multiversion store and underlying parent store, and returns a boolean indicating validity
 func (store *VersionIndexedStore) ValidateReadset() bool {
-	// TODO: remove?
-	// store.mtx.Lock()
-	// defer store.mtx.Unlock()
+	defer store.lock()()
 	// defer telemetry.MeasureSince(time.Now(), "store", "mvkv", "validate_readset")
 
 	// sort the readset keys - this is so we have consistent behavior when theres varying conflicts within the readset (eg. read conflict vs estimate)
@@ -202,7 +465,7 @@ func (store *VersionIndexedStore) ValidateReadset() bool {
 		if mvsValue != nil {
 			if mvsValue.IsEstimate() {
 				// if we see an estimate, that means that we need to abort and rerun
-				store.abortChannel <- scheduler.NewEstimateAbort(mvsValue.Index())
+				sendAbort(store.abortChannel, scheduler.NewEstimateAbort(mvsValue.Index(), store.storeName, key))
 				return false
 			} else {
 				if mvsValue.IsDeleted() {
@@ -232,52 +495,143 @@ func (store *VersionIndexedStore) ValidateReadset() bool {
 
 // Delete implements types.KVStore.
 func (store *VersionIndexedStore) Delete(key []byte) {
-	// TODO: remove?
-	// store.mtx.Lock()
-	// defer store.mtx.Unlock()
+	defer store.lock()()
 	// defer telemetry.MeasureSince(time.Now(), "store", "mvkv", "delete")
 
+	store.delete(key)
+}
+
+func (store *VersionIndexedStore) delete(key []byte) {
 	types.AssertValidKey(key)
+	store.consume(OperationWrite)
+	store.logAccess(AccessDelete, key, nil)
 	store.setValue(key, nil)
 }
 
+// RangeDeleter is implemented by stores that can delete every key in a range as a single batched operation
+type RangeDeleter interface {
+	DeleteRange(start, end []byte)
+	DeleteAll(prefix []byte)
+}
+
+var _ RangeDeleter = (*VersionIndexedStore)(nil)
+
+// DeleteRange deletes every key in [start, end). The range tombstone is recorded in the writeset as a delete of each
+// key in the range visible to the tx (its own writes, earlier txs' writes and the parent store), so it is applied by
+// WriteLatestToStore and validated against later txs' reads like any other delete. The range is tracked as an
+// iteration, so the tx is invalidated if an earlier tx writes a new key into the range.
+func (store *VersionIndexedStore) DeleteRange(start, end []byte) {
+	defer store.lock()()
+	var keys [][]byte
+	iter := store.iterator(start, end, true)
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, iter.Key())
+	}
+	iter.Close()
+	for _, key := range keys {
+		store.delete(key)
+	}
+}
+
+// DeleteAll deletes every key with the given prefix, see DeleteRange
+func (store *VersionIndexedStore) DeleteAll(prefix []byte) {
+	store.DeleteRange(prefix, types.PrefixEndBytes(prefix))
+}
+
 // Has implements types.KVStore.
 func (store *VersionIndexedStore) Has(key []byte) bool {
-	// necessary locking happens within store.Get
-	return store.Get(key) != nil
+	defer store.lock()()
+	store.consume(OperationRead)
+	store.logAccess(AccessHas, key, nil)
+	return store.has(key)
 }
 
 // Set implements types.KVStore.
 func (store *VersionIndexedStore) Set(key []byte, value []byte) {
-	// TODO: remove?
-	// store.mtx.Lock()
-	// defer store.mtx.Unlock()
+	defer store.lock()()
 	// defer telemetry.MeasureSince(time.Now(), "store", "mvkv", "set")
 
 	types.AssertValidKey(key)
+	store.consume(OperationWrite)
+	store.logAccess(AccessSet, key, nil)
 	store.setValue(key, value)
 }
 
 // Iterator implements types.KVStore.
 func (v *VersionIndexedStore) Iterator(start []byte, end []byte) dbm.Iterator {
-	return v.iterator(start, end, true)
+	defer v.lock()()
+	return v.lockIterator(v.iterator(start, end, true))
 }
 
 // ReverseIterator implements types.KVStore.
 func (v *VersionIndexedStore) ReverseIterator(start []byte, end []byte) dbm.Iterator {
-	return v.iterator(start, end, false)
+	defer v.lock()()
+	return v.lockIterator(v.iterator(start, end, false))
+}
+
+// lockIterator wraps an iterator of the store to lock the store around its calls if concurrent access was enabled,
+// since iterating reads through the store and records the iterated keys
+func (v *VersionIndexedStore) lockIterator(iter dbm.Iterator) dbm.Iterator {
+	if v.mtx == nil {
+		return iter
+	}
+	return &lockedIterator{Iterator: iter, store: v}
+}
+
+type lockedIterator struct {
+	dbm.Iterator
+	store *VersionIndexedStore
+}
+
+// Valid implements types.Iterator.
+func (li *lockedIterator) Valid() bool {
+	defer li.store.lock()()
+	return li.Iterator.Valid()
+}
+
+// Next implements types.Iterator.
+func (li *lockedIterator) Next() {
+	defer li.store.lock()()
+	li.Iterator.Next()
+}
+
+// Key implements types.Iterator.
+func (li *lockedIterator) Key() []byte {
+	defer li.store.lock()()
+	return li.Iterator.Key()
+}
+
+// Value implements types.Iterator.
+func (li *lockedIterator) Value() []byte {
+	defer li.store.lock()()
+	return li.Iterator.Value()
+}
+
+// Error implements types.Iterator.
+func (li *lockedIterator) Error() error {
+	defer li.store.lock()()
+	return li.Iterator.Error()
+}
+
+// Close implements types.Iterator.
+func (li *lockedIterator) Close() error {
+	defer li.store.lock()()
+	return li.Iterator.Close()
 }
 
 // Iterator implements types.KVStore.
 func (store *VersionIndexedStore) iterator(start []byte, end []byte, ascending bool) dbm.Iterator {
-	// TODO: remove?
-	// store.mtx.Lock()
-	// defer store.mtx.Unlock()
+	store.consume(OperationIterator)
+	if ascending {
+		store.logAccess(AccessIterate, start, end)
+	} else {
+		store.logAccess(AccessReverseIterate, start, end)
+	}
 
 	// get the sorted keys from MVS
 	// TODO: ideally we take advantage of mvs keys already being sorted
 	// TODO: ideally merge btree and mvs keys into a single sorted btree
-	memDB := store.multiVersionStore.CollectIteratorItems(store.transactionIndex)
+	memDB := store.multiVersionStore.CollectIteratorItemsInRange(store.transactionIndex, start, end)
 
 	// TODO: ideally we persist writeset keys into a sorted btree for later use
 	// make a set of total keys across mvkv and mvs to iterate
@@ -305,6 +659,7 @@ func (store *VersionIndexedStore) iterator(start []byte, end []byte, ascending b
 	iterationTracker := NewIterationTracker(start, end, ascending, store.writeset)
 	store.UpdateIterateSet(&iterationTracker)
 	trackedIterator := NewTrackedIterator(mergeIterator, &iterationTracker)
+	trackedIterator.onStep = func() { store.consume(OperationIteratorStep) }
 
 	// mergeIterator
 	return trackedIterator
@@ -316,19 +671,20 @@ func (v *VersionIndexedStore) GetStoreType() types.StoreType {
 	return v.parent.GetStoreType()
 }
 
-// CacheWrap implements types.KVStore.
-func (*VersionIndexedStore) CacheWrap(storeKey types.StoreKey) types.CacheWrap {
-	panic("CacheWrap not supported for version indexed store")
+// CacheWrap implements types.KVStore. The branch buffers its writes until it's written into the store, while its reads
+// are tracked by the store as reads of the tx, see cacheWrapStore.
+func (store *VersionIndexedStore) CacheWrap(storeKey types.StoreKey) types.CacheWrap {
+	return newCacheWrapStore(store)
 }
 
 // CacheWrapWithListeners implements types.KVStore.
-func (*VersionIndexedStore) CacheWrapWithListeners(storeKey types.StoreKey, listeners []types.WriteListener) types.CacheWrap {
-	panic("CacheWrapWithListeners not supported for version indexed store")
+func (store *VersionIndexedStore) CacheWrapWithListeners(storeKey types.StoreKey, listeners []types.WriteListener) types.CacheWrap {
+	return newCacheWrapStore(listenkv.NewStore(store, storeKey, listeners))
 }
 
 // CacheWrapWithTrace implements types.KVStore.
-func (*VersionIndexedStore) CacheWrapWithTrace(storeKey types.StoreKey, w io.Writer, tc types.TraceContext) types.CacheWrap {
-	panic("CacheWrapWithTrace not supported for version indexed store")
+func (store *VersionIndexedStore) CacheWrapWithTrace(storeKey types.StoreKey, w io.Writer, tc types.TraceContext) types.CacheWrap {
+	return newCacheWrapStore(tracekv.NewStore(store, w, tc))
 }
 
 // GetWorkingHash implements types.KVStore.
@@ -340,37 +696,87 @@ func (v *VersionIndexedStore) GetWorkingHash() ([]byte, error) {
 func (store *VersionIndexedStore) setValue(key, value []byte) {
 	types.AssertValidKey(key)
 
-	keyStr := string(key)
+	keyStr := store.keys.intern(key)
+	size := len(value)
+	if previous, ok := store.writeset[keyStr]; ok {
+		size -= len(previous)
+	} else {
+		size += len(keyStr)
+		store.trackWrite()
+	}
 	store.writeset[keyStr] = value
+	store.meterMemory(size)
+}
+
+// DiscardWrites drops the writeset of the store while keeping its readset and iterateset, so that the reads of a tx
+// whose writes are rolled back (eg. because it panicked) are still validated
+func (store *VersionIndexedStore) DiscardWrites() {
+	store.writeset = make(map[string][]byte)
 }
 
 func (store *VersionIndexedStore) WriteToMultiVersionStore() {
-	// TODO: remove?
-	// store.mtx.Lock()
-	// defer store.mtx.Unlock()
+	defer store.lock()()
 	// defer telemetry.MeasureSince(time.Now(), "store", "mvkv", "write_mvs")
+	store.flushOperations()
+	store.flushReadLatency()
 	store.multiVersionStore.SetWriteset(store.transactionIndex, store.incarnation, store.writeset)
-	store.multiVersionStore.SetReadset(store.transactionIndex, store.readset)
+	if store.readTrackingDisabled {
+		return
+	}
+	store.multiVersionStore.SetReadsetWithGenerations(store.transactionIndex, store.readset, store.readGenerations)
+	store.multiVersionStore.SetExistenceSet(store.transactionIndex, store.existenceset)
+	store.multiVersionStore.SetIterateset(store.transactionIndex, store.iterateset)
+}
+
+// WriteReadsetToMultiVersionStore writes the readset and iterateset of a tx that wrote nothing, leaving its (empty)
+// writeset out of the multiversion store
+func (store *VersionIndexedStore) WriteReadsetToMultiVersionStore() {
+	defer store.lock()()
+	store.flushOperations()
+	store.flushReadLatency()
+	if store.readTrackingDisabled {
+		return
+	}
+	store.multiVersionStore.SetReadsetWithGenerations(store.transactionIndex, store.readset, store.readGenerations)
+	store.multiVersionStore.SetExistenceSet(store.transactionIndex, store.existenceset)
 	store.multiVersionStore.SetIterateset(store.transactionIndex, store.iterateset)
 }
 
 func (store *VersionIndexedStore) WriteEstimatesToMultiVersionStore() {
-	// TODO: remove?
-	// store.mtx.Lock()
-	// defer store.mtx.Unlock()
+	defer store.lock()()
 	// defer telemetry.MeasureSince(time.Now(), "store", "mvkv", "write_mvs")
+	store.flushOperations()
+	store.flushReadLatency()
 	store.multiVersionStore.SetEstimatedWriteset(store.transactionIndex, store.incarnation, store.writeset)
 	// TODO: do we need to write readset and iterateset in this case? I don't think so since if this is called it means we aren't doing validation
 }
 
+// recordGeneration records the generation of a key as of its first read, which is about to be added to the readset
+func (store *VersionIndexedStore) recordGeneration(key string, generation uint64) {
+	if !store.tracksReads() {
+		return
+	}
+	if _, ok := store.readset[key]; !ok {
+		store.readGenerations[key] = generation
+	}
+}
+
+// UpdateReadSet implements ReadsetHandler. It's called while reading through the store (eg. by its iterators), so it
+// doesn't lock the store itself.
 func (store *VersionIndexedStore) UpdateReadSet(key []byte, value []byte) {
-	// TODO: make readset a list of byte slices, and store the value if it's a new value
-	// add to readset
-	keyStr := string(key)
+	keyStr := store.keys.intern(key)
+	_, recorded := store.readset[keyStr]
+	if !store.tracksReads() || !recorded && !store.trackRead() {
+		store.meterUntrackedRead(keyStr, value)
+		return
+	}
+	// add to readset, keeping the distinct values in the order they were observed (see ReadSet)
+	size := len(value)
 	// TODO: maybe only add if not already existing?
-	if _, ok := store.readset[keyStr]; !ok {
+	if !recorded {
 		// if the entry doesnt exist, make a new empty slice
 		store.readset[keyStr] = [][]byte{}
+		size += len(keyStr)
 	}
 	for _, readsetVal := range store.readset[keyStr] {
 		if bytes.Equal(value, readsetVal) {
@@ -379,26 +785,30 @@ func (store *VersionIndexedStore) UpdateReadSet(key []byte, value []byte) {
 		}
 	}
 	// if we get here, that means we have a new readset val, so we append it to the slice
-	store.readset[keyStr] = append(store.readset[keyStr], value)
+	// the value is copied on record, since it may be backed by a slice owned by another tx's writeset (or the parent
+	// store) that could be mutated later, which would otherwise fool validation
+	store.readset[keyStr] = append(store.readset[keyStr], types.CopyBytes(value))
+	store.meterMemory(size)
 }
 
-// Write implements types.CacheWrap so this store can exist on the cache multi store
-func (store *VersionIndexedStore) Write() {
-	panic("not implemented")
-}
+// Write implements types.CacheWrap so this store can exist on the cache multi store. The writes of the tx are kept in
+// its writeset until the scheduler flushes them into the multiversion store, so there is nothing to write.
+func (store *VersionIndexedStore) Write() {}
 
 // GetEvents implements types.CacheWrap so this store can exist on the cache multi store
 func (store *VersionIndexedStore) GetEvents() []abci.Event {
 	panic("not implemented")
 }
 
-// ResetEvents implements types.CacheWrap so this store can exist on the cache multi store
-func (store *VersionIndexedStore) ResetEvents() {
-	panic("not implemented")
-}
+// ResetEvents implements types.CacheWrap so this store can exist on the cache multi store. The store doesn't record
+// events, so there is nothing to reset (runTx resets the events of its multistore outside DeliverTx).
+func (store *VersionIndexedStore) ResetEvents() {}
 
 func (store *VersionIndexedStore) UpdateIterateSet(iterationTracker *iterationTracker) {
 	// TODO: refactor such that the iterateset is added to the store at the time of iterator creation and updated continuously instead of at Close
+	if !store.trackRead() {
+		return
+	}
 	// append to iterateset
 	store.iterateset = append(store.iterateset, iterationTracker)
 }
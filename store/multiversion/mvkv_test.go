@@ -1,7 +1,11 @@
 package multiversion_test
 
 import (
+	"fmt"
+	"io"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cosmos/cosmos-sdk/store/cachekv"
 	"github.com/cosmos/cosmos-sdk/store/dbadapter"
@@ -95,6 +99,169 @@ func TestVersionIndexedStoreGetters(t *testing.T) {
 	require.Nil(t, vis.GetReadset()["key4"])
 }
 
+func TestVersionIndexedStoreMultipleAborts(t *testing.T) {
+	mem := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+
+	mvs.SetEstimatedWriteset(1, 0, map[string][]byte{
+		"key1": nil,
+		"key2": nil,
+	})
+
+	// abort channel is only buffered for a single abort
+	abortChannel := make(chan scheduler.Abort, 1)
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 2, 0, abortChannel)
+
+	// repeatedly read estimates, recovering from the abort panic each time - this must not block
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			require.Panics(t, func() {
+				vis.Get([]byte("key1"))
+			})
+			require.Panics(t, func() {
+				vis.Get([]byte("key2"))
+			})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sending multiple aborts blocked")
+	}
+
+	// only the first abort is retained
+	require.Len(t, abortChannel, 1)
+	abort := <-abortChannel
+	require.Equal(t, 1, abort.DependentTxIdx)
+	require.Equal(t, []byte("key1"), abort.Key)
+}
+
+func TestVersionIndexedStoreAbortDetails(t *testing.T) {
+	mem := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithStoreName("bank"))
+
+	mvs.SetEstimatedWriteset(1, 0, map[string][]byte{"key1": nil})
+
+	abortChannel := make(chan scheduler.Abort, 1)
+	vis := mvs.VersionedIndexedStore(2, 0, abortChannel)
+	require.Panics(t, func() {
+		vis.Get([]byte("key1"))
+	})
+	abort := <-abortChannel
+	require.Equal(t, 1, abort.DependentTxIdx)
+	require.Equal(t, scheduler.ErrReadEstimate, abort.Err)
+	require.Equal(t, scheduler.AbortReasonEstimateRead, abort.Reason)
+	require.Equal(t, "bank", abort.StoreKey)
+	require.Equal(t, []byte("key1"), abort.Key)
+}
+
+// recoverAbort returns the abort that f panics with
+func recoverAbort(t *testing.T, f func()) (abort scheduler.Abort) {
+	t.Helper()
+	defer func() {
+		r := recover()
+		require.IsType(t, scheduler.Abort{}, r)
+		abort = r.(scheduler.Abort)
+	}()
+	f()
+	return
+}
+
+func TestVersionIndexedStoreAbortSignal(t *testing.T) {
+	mem := dbadapter.Store{DB: dbm.NewMemDB()}
+	mem.Set([]byte("key2"), []byte("value2"))
+	mvs := multiversion.NewMultiVersionStore(mem, multiversion.WithStoreName("bank"))
+	mvs.SetEstimatedWriteset(1, 0, map[string][]byte{"key1": nil})
+
+	// the signal is shared by the stores of the execution, so an abort in one stops both
+	abortChannel := make(chan scheduler.Abort, 2)
+	signal := scheduler.NewAbortSignal()
+	vis1 := mvs.VersionedIndexedStore(2, 0, abortChannel).SetAbortSignal(signal)
+	vis2 := mvs.VersionedIndexedStore(2, 0, abortChannel).SetAbortSignal(signal)
+	require.Equal(t, []byte("value2"), vis2.Get([]byte("key2")))
+	vis2.Set([]byte("key4"), []byte("value4"))
+	require.Nil(t, signal.Aborted())
+
+	expected := scheduler.NewEstimateAbort(1, "bank", []byte("key1"))
+	require.Equal(t, expected, recoverAbort(t, func() { vis1.Get([]byte("key1")) }))
+	require.Equal(t, &expected, signal.Aborted())
+
+	// once the abort is recovered, every operation panics with it again, without sending it again
+	require.Equal(t, expected, recoverAbort(t, func() { vis2.Get([]byte("key2")) }))
+	require.Equal(t, expected, recoverAbort(t, func() { vis2.Set([]byte("key3"), []byte("value3")) }))
+	require.Equal(t, expected, recoverAbort(t, func() { vis1.Iterator(nil, nil) }))
+	require.Equal(t, map[string][]byte{"key4": []byte("value4")}, vis2.GetWriteset())
+	require.Len(t, abortChannel, 1)
+
+	// the writes made before the abort are still written as estimates
+	require.NotPanics(t, vis2.WriteEstimatesToMultiVersionStore)
+	require.True(t, mvs.GetLatestBeforeIndex(3, []byte("key4")).IsEstimate())
+}
+
+func TestVersionIndexedStoreObserver(t *testing.T) {
+	mem := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 1, 2, make(chan scheduler.Abort, 1))
+
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+	vis.Get([]byte("key1"))
+	vis.Set([]byte("key2"), []byte("value2"))
+	vis.Delete([]byte("key3"))
+
+	observer := vis.Observer()
+	require.Equal(t, 1, observer.TransactionIndex())
+	require.Equal(t, 2, observer.Incarnation())
+	require.True(t, observer.HasRead([]byte("key1")))
+	require.False(t, observer.HasRead([]byte("key2")))
+	require.True(t, observer.HasWritten([]byte("key2")))
+	require.True(t, observer.HasWritten([]byte("key3")))
+	require.Equal(t, multiversion.ReadSet{"key1": [][]byte{[]byte("value1")}}, observer.Readset())
+	require.Equal(t, multiversion.WriteSet{"key2": []byte("value2"), "key3": nil}, observer.Writeset())
+
+	// mutating the observed sets doesn't affect the store
+	writeset := observer.Writeset()
+	writeset["key2"][0] = 'x'
+	writeset["key4"] = []byte("value4")
+	readset := observer.Readset()
+	readset["key1"][0][0] = 'x'
+	require.Equal(t, []byte("value2"), vis.Get([]byte("key2")))
+	require.Equal(t, []byte("value1"), vis.Get([]byte("key1")))
+	require.False(t, observer.HasWritten([]byte("key4")))
+
+	// the observer reflects later writes
+	vis.Set([]byte("key4"), []byte("value4"))
+	require.True(t, observer.HasWritten([]byte("key4")))
+}
+
+func TestVersionIndexedStoreDisableReadTracking(t *testing.T) {
+	mem := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+	mvs.SetWriteset(0, 0, map[string][]byte{"key2": []byte("value2")})
+
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 1, 0, make(chan scheduler.Abort, 1)).DisableReadTracking()
+	require.Equal(t, []byte("value1"), vis.Get([]byte("key1")))
+	require.Equal(t, []byte("value2"), vis.Get([]byte("key2")))
+	iter := vis.Iterator([]byte("key1"), []byte("key3"))
+	for ; iter.Valid(); iter.Next() {
+	}
+	iter.Close()
+	vis.Set([]byte("key3"), []byte("value3"))
+	require.Empty(t, vis.GetReadset())
+
+	// only the writeset is written to the multiversion store
+	vis.WriteToMultiVersionStore()
+	require.Equal(t, []string{"key3"}, mvs.GetWritesetKeys(1))
+	require.Nil(t, mvs.GetReadset(1))
+	require.Nil(t, mvs.GetIterateset(1))
+}
+
 func TestVersionIndexedStoreSetters(t *testing.T) {
 	mem := dbadapter.Store{DB: dbm.NewMemDB()}
 	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
@@ -127,10 +294,13 @@ func TestVersionIndexedStoreBoilerplateFunctions(t *testing.T) {
 	// initialize a new VersionIndexedStore
 	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 1, 2, make(chan scheduler.Abort, 1))
 
+	// branches are supported, see TestVersionIndexedStoreCacheWrap
+	require.NotNil(t, vis.CacheWrap(types.NewKVStoreKey("mock")))
+	require.NotNil(t, vis.CacheWrapWithListeners(types.NewKVStoreKey("mock"), nil))
+	require.NotNil(t, vis.CacheWrapWithTrace(types.NewKVStoreKey("mock"), io.Discard, nil))
+	require.NotPanics(t, vis.Write)
+
 	// asserts panics where appropriate
-	require.Panics(t, func() { vis.CacheWrap(types.NewKVStoreKey("mock")) })
-	require.Panics(t, func() { vis.CacheWrapWithListeners(types.NewKVStoreKey("mock"), nil) })
-	require.Panics(t, func() { vis.CacheWrapWithTrace(types.NewKVStoreKey("mock"), nil, nil) })
 	require.Panics(t, func() { vis.GetWorkingHash() })
 
 	// assert properly returns store type
@@ -164,6 +334,34 @@ func TestVersionIndexedStoreWrite(t *testing.T) {
 	require.True(t, mvs.GetLatest([]byte("key3")).IsDeleted())
 }
 
+func TestReuseVersionIndexedStore(t *testing.T) {
+	mem := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
+	parentKVStore.Set([]byte("parent"), []byte("value"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	vis := mvs.VersionedIndexedStore(1, 0, make(chan scheduler.Abort, 1))
+
+	require.Equal(t, []byte("value"), vis.Get([]byte("parent")))
+	vis.Set([]byte("key1"), []byte("value1"))
+	vis.WriteToMultiVersionStore()
+
+	// the reused store starts over for the new incarnation, without any of the options set on it
+	abortCh := make(chan scheduler.Abort, 1)
+	reused := mvs.ReuseVersionIndexedStore(vis, 1, abortCh)
+	require.Same(t, vis, reused)
+	require.Empty(t, reused.GetReadset())
+	require.Empty(t, reused.GetWriteset())
+	require.Nil(t, reused.Get([]byte("key1")))
+	reused.Set([]byte("key2"), []byte("value2"))
+
+	// the readset of the previous incarnation was handed to the multiversion store, so it's left alone
+	require.Equal(t, multiversion.ReadSet{"parent": {[]byte("value")}}, mvs.GetReadset(1))
+	reused.WriteToMultiVersionStore()
+
+	require.Equal(t, 1, mvs.GetLatest([]byte("key2")).Incarnation())
+	require.Nil(t, mvs.GetLatest([]byte("key1")))
+}
+
 func TestVersionIndexedStoreWriteEstimates(t *testing.T) {
 	mem := dbadapter.Store{DB: dbm.NewMemDB()}
 	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
@@ -474,3 +672,224 @@ func TestRemoveLastEntry(t *testing.T) {
 	require.False(t, valid)
 	require.Empty(t, conflicts)
 }
+
+func TestVersionIndexedStoreGetUnsafe(t *testing.T) {
+	mem := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 1, 0, make(chan scheduler.Abort, 1))
+
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+	vis.Set([]byte("key2"), []byte("value2"))
+
+	// Get returns copies, so mutating them doesn't affect the readset or writeset
+	val := vis.Get([]byte("key1"))
+	val[0] = 'x'
+	val = vis.Get([]byte("key2"))
+	val[0] = 'x'
+	require.Equal(t, []byte("value1"), vis.Get([]byte("key1")))
+	require.Equal(t, []byte("value2"), vis.Get([]byte("key2")))
+
+	// GetUnsafe copies unless explicitly enabled
+	val = vis.GetUnsafe([]byte("key2"))
+	val[0] = 'x'
+	require.Equal(t, []byte("value2"), vis.Get([]byte("key2")))
+
+	// once enabled, GetUnsafe serves the internal slices
+	vis.EnableUnsafeGet()
+	require.Equal(t, []byte("value1"), vis.GetUnsafe([]byte("key1")))
+	require.Equal(t, []byte("value2"), vis.GetUnsafe([]byte("key2")))
+	require.Same(t, &vis.GetWriteset()["key2"][0], &vis.GetUnsafe([]byte("key2"))[0])
+	require.Nil(t, vis.GetUnsafe([]byte("key3")))
+}
+
+func TestVersionIndexedStoreReadsetCopiesValues(t *testing.T) {
+	mem := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+
+	// the writer's value is owned by its writeset
+	written := []byte("value1")
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": written})
+
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 2, 0, make(chan scheduler.Abort, 1))
+	require.Equal(t, []byte("value1"), vis.Get([]byte("key1")))
+
+	// post-hoc mutation of the shared backing array must not change what was recorded in the readset
+	written[0] = 'x'
+	require.Equal(t, [][]byte{[]byte("value1")}, vis.GetReadset()["key1"])
+	// the readset is set without the generations of its reads, which would skip comparing values as the item of the
+	// key didn't change
+	mvs.SetReadset(2, vis.GetReadset())
+
+	// so validation detects that the value read no longer matches the writer's value
+	valid, conflicts := mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+	require.Equal(t, []int{1}, conflicts)
+}
+
+func TestVersionIndexedStoreDiscardWrites(t *testing.T) {
+	mem := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 1, 0, make(chan scheduler.Abort, 1))
+	require.Equal(t, []byte("value1"), vis.Get([]byte("key1")))
+	vis.Set([]byte("key2"), []byte("value2"))
+	vis.DiscardWrites()
+	vis.WriteToMultiVersionStore()
+
+	// the reads are kept, but none of the writes reach the multiversion store
+	require.Empty(t, vis.GetWriteset())
+	require.Equal(t, [][]byte{[]byte("value1")}, mvs.GetReadset(1)["key1"])
+	require.Nil(t, mvs.GetLatest([]byte("key2")))
+}
+
+func TestVersionIndexedStoreDeleteAll(t *testing.T) {
+	mem := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	parentKVStore.Set([]byte("a1"), []byte("value1"))
+	parentKVStore.Set([]byte("a2"), []byte("value2"))
+	parentKVStore.Set([]byte("b1"), []byte("value3"))
+
+	// an earlier tx writes a key into the range
+	mvs.SetWriteset(0, 1, map[string][]byte{"a3": []byte("value4")})
+	// a later tx reads a key in the range before it is deleted
+	reader := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 2, 1, make(chan scheduler.Abort, 1))
+	require.Equal(t, []byte("value1"), reader.Get([]byte("a1")))
+	reader.WriteToMultiVersionStore()
+	valid, conflicts := mvs.ValidateTransactionState(2)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 1, 1, make(chan scheduler.Abort, 1))
+	vis.Set([]byte("a4"), []byte("value5"))
+	vis.DeleteAll([]byte("a"))
+	require.Equal(t, map[string][]byte{"a1": nil, "a2": nil, "a3": nil, "a4": nil}, vis.GetWriteset())
+	require.Nil(t, vis.Get([]byte("a1")))
+	require.Equal(t, []byte("value3"), vis.Get([]byte("b1")))
+	vis.WriteToMultiVersionStore()
+
+	// the later tx's read of a deleted key is invalidated
+	valid, conflicts = mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+	require.Equal(t, []int{1}, conflicts)
+	valid, _ = mvs.ValidateTransactionState(1)
+	require.True(t, valid)
+
+	// an earlier tx writing a new key into the range invalidates the range delete
+	mvs.SetWriteset(0, 2, map[string][]byte{"a3": []byte("value4"), "a5": []byte("value6")})
+	valid, _ = mvs.ValidateTransactionState(1)
+	require.False(t, valid)
+
+	// re-executing the range delete covers the new key, and the deletes reach the parent store
+	vis = multiversion.NewVersionIndexedStore(parentKVStore, mvs, 1, 2, make(chan scheduler.Abort, 1))
+	vis.DeleteAll([]byte("a"))
+	vis.WriteToMultiVersionStore()
+	valid, _ = mvs.ValidateTransactionState(1)
+	require.True(t, valid)
+	mvs.WriteLatestToStore()
+	iter := parentKVStore.Iterator(nil, nil)
+	defer iter.Close()
+	var keys []string
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	require.Equal(t, []string{"b1"}, keys)
+}
+
+func TestVersionIndexedStoreRepeatedReads(t *testing.T) {
+	mem := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+	parentKVStore.Set([]byte("key2"), []byte("value2"))
+
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 2, 0, make(chan scheduler.Abort, 1))
+	// read, write and read again serves the tx's own write, and only records the first read
+	require.Equal(t, []byte("value1"), vis.Get([]byte("key1")))
+	vis.Set([]byte("key1"), []byte("own"))
+	require.Equal(t, []byte("own"), vis.Get([]byte("key1")))
+	vis.Delete([]byte("key1"))
+	require.Nil(t, vis.Get([]byte("key1")))
+
+	// repeated reads are served from the first observed value, even if an earlier tx writes in between
+	require.Equal(t, []byte("value2"), vis.Get([]byte("key2")))
+	mvs.SetWriteset(1, 0, map[string][]byte{"key2": []byte("changed")})
+	require.Equal(t, []byte("value2"), vis.Get([]byte("key2")))
+	require.Equal(t, multiversion.ReadSet{
+		"key1": {[]byte("value1")},
+		"key2": {[]byte("value2")},
+	}, multiversion.ReadSet(vis.GetReadset()))
+
+	vis.WriteToMultiVersionStore()
+	valid, conflicts := mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+	require.Equal(t, []int{1}, conflicts)
+
+	// an earlier write to the key that was read before the tx's own write still invalidates the first read
+	mvs.SetWriteset(1, 1, map[string][]byte{"key1": []byte("changed")})
+	readset := mvs.GetReadset(2)
+	delete(readset, "key2")
+	mvs.SetReadset(2, readset)
+	valid, conflicts = mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+	require.Equal(t, []int{1}, conflicts)
+}
+
+func TestVersionIndexedStoreConcurrentAccess(t *testing.T) {
+	mem := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore := cachekv.NewStore(mem, types.NewKVStoreKey("mock"), 1000)
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	const goroutines = 8
+	for i := 0; i < goroutines; i++ {
+		parentKVStore.Set([]byte(fmt.Sprintf("parent%d", i)), []byte("value"))
+	}
+	mvs.SetWriteset(0, 1, map[string][]byte{"shared": []byte("value0")})
+
+	limiter := multiversion.NewSyncLimiter(multiversion.NewOperationLimiter(0, 0))
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 1, 1, make(chan scheduler.Abort, 1)).
+		SetLimiter(limiter).
+		EnableConcurrentAccess()
+
+	// goroutines of the same tx reading, writing and iterating at the same time
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Equal(t, []byte("value0"), vis.Get([]byte("shared")))
+			require.True(t, vis.Has([]byte(fmt.Sprintf("parent%d", i))))
+			vis.Set([]byte(fmt.Sprintf("own%d", i)), []byte("value"))
+			vis.Delete([]byte(fmt.Sprintf("deleted%d", i)))
+			iter := vis.Iterator([]byte("parent"), []byte("parentz"))
+			keys := 0
+			for ; iter.Valid(); iter.Next() {
+				require.Equal(t, []byte("value"), iter.Value())
+				keys++
+			}
+			require.NoError(t, iter.Close())
+			require.Equal(t, goroutines, keys)
+			require.True(t, vis.Observer().HasWritten([]byte(fmt.Sprintf("own%d", i))))
+		}()
+	}
+	wg.Wait()
+
+	// every goroutine's reads and writes were recorded
+	writeset := vis.GetWriteset()
+	require.Len(t, writeset, 2*goroutines)
+	for i := 0; i < goroutines; i++ {
+		require.Equal(t, []byte("value"), writeset[fmt.Sprintf("own%d", i)])
+		require.Contains(t, writeset, fmt.Sprintf("deleted%d", i))
+		require.Equal(t, [][]byte{[]byte("value")}, vis.GetReadset()[fmt.Sprintf("parent%d", i)])
+	}
+	require.Equal(t, [][]byte{[]byte("value0")}, vis.GetReadset()["shared"])
+	require.True(t, vis.ValidateReadset())
+	vis.WriteToMultiVersionStore()
+	valid, conflicts := mvs.ValidateTransactionState(1)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+}
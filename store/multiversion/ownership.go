@@ -0,0 +1,137 @@
+package multiversion
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Values written to the store are owned by it from then on, and the values of the items it returns are shared by
+// every reader of the version, across txs and incarnations, without being copied. Callers must treat them as read only,
+// since mutating one corrupts what every other reader of the version observes, including validation. Callers that
+// can't guarantee that, eg. modules handing values to code they don't control, can have the store hand out copies with
+// WithDefensiveCopies, and tests can catch mutations with WithMutationDetection.
+
+// WithDefensiveCopies has GetLatest, GetLatestBeforeIndex and GetLatestBeforeIndexWithGeneration return items holding
+// a private copy of their value, which callers may mutate freely, at the cost of a copy per read
+func WithDefensiveCopies() StoreOption {
+	return func(s *Store) {
+		s.defensiveCopies = true
+	}
+}
+
+// WithMutationDetection checksums every value written to the store, and verifies the checksum of the values the store
+// hands out on every read, panicking with a *ValueMutationError if a value was mutated since it was written. Values
+// that are never read again can be verified with CheckValueMutations. This is intended for tests, since it hashes
+// every value written and read.
+func WithMutationDetection() StoreOption {
+	return func(s *Store) {
+		s.valueChecksums = &sync.Map{}
+	}
+}
+
+// ValueMutationError is the mutation of a value owned by the store, found with WithMutationDetection
+type ValueMutationError struct {
+	StoreName   string
+	Key         []byte
+	Index       int
+	Incarnation int
+}
+
+func (e *ValueMutationError) Error() string {
+	return fmt.Sprintf("value of key %X written by tx %d (incarnation %d) to store %q was mutated after it was written",
+		e.Key, e.Index, e.Incarnation, e.StoreName)
+}
+
+// valueRef identifies a version written to the store
+type valueRef struct {
+	key         string
+	index       int
+	incarnation int
+}
+
+// copiedValueItem is a version of a key holding a private copy of its value, see WithDefensiveCopies
+type copiedValueItem struct {
+	MultiVersionValueItem
+	value []byte
+}
+
+// Value implements VersionedValueItem.
+func (v *copiedValueItem) Value() []byte {
+	return v.value
+}
+
+// recordChecksums records the checksums of the values of a writeset, if mutation detection is enabled
+func (s *Store) recordChecksums(index int, incarnation int, writeset WriteSet) {
+	if s.valueChecksums == nil {
+		return
+	}
+	for key, value := range writeset {
+		if value != nil {
+			s.valueChecksums.Store(valueRef{key: key, index: index, incarnation: incarnation}, sha256.Sum256(value))
+		}
+	}
+}
+
+// handOut returns a version of key read from the store to a caller, verifying or copying its value if enabled
+func (s *Store) handOut(key string, item MultiVersionValueItem) MultiVersionValueItem {
+	if !s.defensiveCopies && s.valueChecksums == nil {
+		return item
+	}
+	if item.IsEstimate() || item.IsDeleted() {
+		return item
+	}
+	if err := s.checkValue(key, item); err != nil {
+		panic(err)
+	}
+	if !s.defensiveCopies {
+		return item
+	}
+	value := make([]byte, len(item.Value()))
+	copy(value, item.Value())
+	return &copiedValueItem{MultiVersionValueItem: item, value: value}
+}
+
+// checkValue returns a *ValueMutationError if the value of a version no longer matches its checksum
+func (s *Store) checkValue(key string, item MultiVersionValueItem) error {
+	if s.valueChecksums == nil {
+		return nil
+	}
+	ref := valueRef{key: key, index: item.Index(), incarnation: item.Incarnation()}
+	checksum, found := s.valueChecksums.Load(ref)
+	if !found || checksum.([sha256.Size]byte) == sha256.Sum256(item.Value()) {
+		return nil
+	}
+	return &ValueMutationError{StoreName: s.storeName, Key: []byte(key), Index: ref.index, Incarnation: ref.incarnation}
+}
+
+// CheckValueMutations verifies the values currently in the store against their checksums, if mutation detection is
+// enabled, returning a *ValueMutationError for the first mutated value in key and index order
+func (s *Store) CheckValueMutations() error {
+	if s.valueChecksums == nil {
+		return nil
+	}
+	var mutations []*ValueMutationError
+	s.multiVersionMap.Range(func(key, value interface{}) bool {
+		for _, item := range value.(MultiVersionValue).Versions() {
+			if item.IsEstimate() || item.IsDeleted() {
+				continue
+			}
+			if err := s.checkValue(key.(string), item); err != nil {
+				mutations = append(mutations, err.(*ValueMutationError))
+			}
+		}
+		return true
+	})
+	if len(mutations) == 0 {
+		return nil
+	}
+	sort.Slice(mutations, func(i, j int) bool {
+		if string(mutations[i].Key) != string(mutations[j].Key) {
+			return string(mutations[i].Key) < string(mutations[j].Key)
+		}
+		return mutations[i].Index < mutations[j].Index
+	})
+	return mutations[0]
+}
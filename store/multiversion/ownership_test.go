@@ -0,0 +1,74 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+func TestMultiVersionStoreDefensiveCopies(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+
+	// without copies, every reader shares the value written
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	mvs.SetWriteset(1, 0, map[string][]byte{"key": []byte("value")})
+	mvs.GetLatestBeforeIndex(2, []byte("key")).Value()[0] = 'V'
+	require.Equal(t, []byte("Value"), mvs.GetLatest([]byte("key")).Value())
+
+	// with copies, mutating a value read doesn't affect other readers
+	mvs = multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithDefensiveCopies())
+	mvs.SetWriteset(1, 0, map[string][]byte{"key": []byte("value")})
+	mvs.SetWriteset(2, 0, map[string][]byte{"key": nil})
+	mvs.SetEstimatedWriteset(3, 0, map[string][]byte{"key": nil})
+	value := mvs.GetLatestBeforeIndex(2, []byte("key"))
+	require.Equal(t, 1, value.Index())
+	value.Value()[0] = 'V'
+	require.Equal(t, []byte("value"), mvs.GetLatestBeforeIndex(2, []byte("key")).Value())
+	value, _ = mvs.GetLatestBeforeIndexWithGeneration(2, []byte("key"))
+	value.Value()[0] = 'V'
+	require.Equal(t, []byte("value"), mvs.GetLatestBeforeIndex(2, []byte("key")).Value())
+
+	// deletions and estimates are handed out as they are
+	require.True(t, mvs.GetLatestBeforeIndex(3, []byte("key")).IsDeleted())
+	require.True(t, mvs.GetLatest([]byte("key")).IsEstimate())
+}
+
+func TestMultiVersionStoreMutationDetection(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithMutationDetection(), multiversion.WithStoreName("bank"))
+	mvs.SetWriteset(1, 0, map[string][]byte{"key": []byte("value"), "other": []byte("other"), "deleted": nil})
+	mvs.SetWriteset(2, 1, map[string][]byte{"key": []byte("value2")})
+	require.NoError(t, mvs.CheckValueMutations())
+
+	// reading an unchanged value is fine, reading a mutated one panics
+	value := mvs.GetLatestBeforeIndex(2, []byte("key")).Value()
+	require.True(t, mvs.GetLatestBeforeIndex(2, []byte("deleted")).IsDeleted())
+	value[0] = 'V'
+	require.PanicsWithError(t, (&multiversion.ValueMutationError{StoreName: "bank", Key: []byte("key"), Index: 1}).Error(), func() {
+		mvs.GetLatestBeforeIndex(2, []byte("key"))
+	})
+	require.Equal(t, []byte("value2"), mvs.GetLatest([]byte("key")).Value())
+
+	// mutations of values that aren't read again are found by checking the store
+	err := mvs.CheckValueMutations()
+	var mutation *multiversion.ValueMutationError
+	require.ErrorAs(t, err, &mutation)
+	require.Equal(t, []byte("key"), mutation.Key)
+	require.Equal(t, 1, mutation.Index)
+	require.Equal(t, 0, mutation.Incarnation)
+
+	// rewriting the version records its new value
+	mvs.SetWriteset(1, 1, map[string][]byte{"key": []byte("value"), "other": []byte("other")})
+	require.NoError(t, mvs.CheckValueMutations())
+
+	// detection is off once the store is reset without it
+	mvs.Reset(parentKVStore)
+	mvs.SetWriteset(1, 0, map[string][]byte{"key": []byte("value")})
+	mvs.GetLatest([]byte("key")).Value()[0] = 'V'
+	require.NotPanics(t, func() { mvs.GetLatest([]byte("key")) })
+	require.NoError(t, mvs.CheckValueMutations())
+}
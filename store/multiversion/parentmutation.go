@@ -0,0 +1,58 @@
+package multiversion
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+)
+
+// ErrParentStateMutation is the error of a block whose parent store changed while it was processed. The parent store
+// of a block is assumed to be immutable until the block's writes are flushed to it, but upgrades, migrations or
+// streaming may still write to it mid-block, which leaves the reads txs made from it stale.
+var ErrParentStateMutation = errors.New("parent store mutated while the block was processed")
+
+// ParentStateMutationError is a key of the parent store that no longer has the value a tx read from it, found while
+// validating the tx
+type ParentStateMutationError struct {
+	StoreName string
+	Key       []byte
+	// Index is the index of the tx that read the key
+	Index int
+}
+
+func (e *ParentStateMutationError) Error() string {
+	return fmt.Sprintf("%s: key %X of store %q read by tx %d", ErrParentStateMutation, e.Key, e.StoreName, e.Index)
+}
+
+func (e *ParentStateMutationError) Unwrap() error {
+	return ErrParentStateMutation
+}
+
+// ParentStateMutation returns the first mutation of the parent store found by the validations of the block, or nil if
+// none was. A read of the parent store is only known to be stale if no tx of the block wrote the key, since a read of
+// a tx's write that was later reverted no longer matches the parent store either.
+func (s *Store) ParentStateMutation() error {
+	s.parentMutationMx.Lock()
+	defer s.parentMutationMx.Unlock()
+	if s.parentMutation == nil {
+		return nil
+	}
+	return s.parentMutation
+}
+
+// recordParentMutation records that the value read by the tx at index from the parent store for key changed, unless a
+// mutation was already found
+func (s *Store) recordParentMutation(index int, key string) {
+	s.parentMutationMx.Lock()
+	defer s.parentMutationMx.Unlock()
+	if s.parentMutation != nil {
+		return
+	}
+	telemetry.IncrCounter(1, "store", "mvs", "parent_mutations")
+	if s.readCache != nil {
+		// the cached values may be stale too
+		s.readCache.bypass()
+	}
+	s.parentMutation = &ParentStateMutationError{StoreName: s.storeName, Key: []byte(key), Index: index}
+}
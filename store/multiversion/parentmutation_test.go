@@ -0,0 +1,50 @@
+package multiversion_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+func TestValidateTransactionStateParentMutation(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+	parentKVStore.Set([]byte("key2"), []byte("value2"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithStoreName("bank"))
+
+	// tx 2 read a value written by tx 1, which was since reverted, so it doesn't match the parent store any more
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key2": []byte("written")})
+	mvs.SetReadset(2, multiversion.ReadSet{"key2": {[]byte("written")}})
+	mvs.SetWriteset(1, 1, multiversion.WriteSet{})
+	valid, conflicts := mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+	require.Empty(t, conflicts)
+	require.NoError(t, mvs.ParentStateMutation())
+
+	// tx 3 read a key no tx wrote, which can only come from the parent store, so the parent store was mutated
+	mvs.SetReadset(3, multiversion.ReadSet{"key1": {[]byte("value1")}})
+	valid, _ = mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+	parentKVStore.Set([]byte("key1"), []byte("mutated"))
+	valid, conflicts = mvs.ValidateTransactionState(3)
+	require.False(t, valid)
+	require.Empty(t, conflicts)
+	err := mvs.ParentStateMutation()
+	require.ErrorIs(t, err, multiversion.ErrParentStateMutation)
+	var mutation *multiversion.ParentStateMutationError
+	require.True(t, errors.As(err, &mutation))
+	require.Equal(t, &multiversion.ParentStateMutationError{StoreName: "bank", Key: []byte("key1"), Index: 3}, mutation)
+
+	// the first mutation is kept until the store is reset
+	mvs.SetReadset(4, multiversion.ReadSet{"key3": {[]byte("value3")}})
+	valid, _ = mvs.ValidateTransactionState(4)
+	require.False(t, valid)
+	require.Equal(t, err, mvs.ParentStateMutation())
+	mvs.Reset(parentKVStore)
+	require.NoError(t, mvs.ParentStateMutation())
+}
@@ -0,0 +1,104 @@
+package multiversion
+
+import (
+	"sync"
+
+	"github.com/google/btree"
+)
+
+// Every block writes a fresh set of keys, so without reuse each block allocates a new multiversion item, btree and
+// value item per written key, only for all of them to become garbage once the block is committed. The pools below let
+// a store that is reset between blocks hand its items back, so that the next block (on any store) can reuse them.
+
+// nodeFreeList is shared by the btrees of all pooled multiversion items, so that btree nodes are reused as well
+var nodeFreeList = btree.NewFreeList(btree.DefaultFreeListSize)
+
+var multiVersionItemPool = sync.Pool{
+	New: func() interface{} {
+		return newVersionedItem(btree.NewWithFreeList(multiVersionBTreeDegree, nodeFreeList), valueItemPool)
+	},
+}
+
+// valueItemPool recycles the versions of the multiversion store
+var valueItemPool = newVersionPool[[]byte]()
+
+// versionPool recycles the versions of versioned values of type V, and the searches for them
+type versionPool[V any] struct {
+	pool     sync.Pool
+	searches sync.Pool
+}
+
+func newVersionPool[V any]() *versionPool[V] {
+	p := &versionPool[V]{}
+	p.pool.New = func() interface{} { return &versionedValueItem[V]{} }
+	p.searches.New = func() interface{} { return newVersionSearch[V]() }
+	return p
+}
+
+// versionSearch finds the latest version of a btree written before an index. The pivot of the search and the iterator
+// bound to it would escape to the heap on every search, so they're pooled instead, which keeps reads and validations
+// from allocating.
+type versionSearch[V any] struct {
+	pivot versionedValueItem[V]
+	found *versionedValueItem[V]
+	visit btree.ItemIterator
+}
+
+func newVersionSearch[V any]() *versionSearch[V] {
+	search := &versionSearch[V]{}
+	search.visit = func(bTreeItem btree.Item) bool {
+		search.found = bTreeItem.(*versionedValueItem[V])
+		return false
+	}
+	return search
+}
+
+// latestBeforeIndex returns the latest version of tree written before index, if any
+func (p *versionPool[V]) latestBeforeIndex(tree *btree.BTree, index int) *versionedValueItem[V] {
+	search := p.searches.Get().(*versionSearch[V])
+	search.pivot.index = index - 1
+	tree.DescendLessOrEqual(&search.pivot, search.visit)
+	found := search.found
+	search.found = nil
+	p.searches.Put(search)
+	return found
+}
+
+// get returns a version from the pool set to the given fields
+func (p *versionPool[V]) get(index int, incarnation int, value V, deleted bool, estimate bool) *versionedValueItem[V] {
+	item := p.pool.Get().(*versionedValueItem[V])
+	item.index = index
+	item.incarnation = incarnation
+	item.value = value
+	item.deleted = deleted
+	item.estimate = estimate
+	return item
+}
+
+// put returns a version to the pool, dropping its reference to the value
+func (p *versionPool[V]) put(item *versionedValueItem[V]) {
+	*item = versionedValueItem[V]{}
+	p.pool.Put(item)
+}
+
+// getMultiVersionItem returns an empty multiversion item from the pool
+func getMultiVersionItem() *multiVersionItem {
+	return multiVersionItemPool.Get().(*multiVersionItem)
+}
+
+// putMultiVersionItem returns a multiversion item and all of its value items to their pools. The item must no longer
+// be reachable from any store, nor be read, since its latest versions are cleared in place so that their btree nodes
+// are reused.
+func putMultiVersionItem(item *multiVersionItem) {
+	item.mtx.Lock()
+	latest := item.load()
+	latest.tree.Ascend(func(bTreeItem btree.Item) bool {
+		item.versions.put(bTreeItem.(*valueItem))
+		return true
+	})
+	latest.tree.Clear(true)
+	// waiters still holding the item see it emptied
+	item.publishLocked(latest.tree, latest.generation+1)
+	item.mtx.Unlock()
+	multiVersionItemPool.Put(item)
+}
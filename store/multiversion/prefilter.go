@@ -0,0 +1,212 @@
+package multiversion
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// prefilterMaxScan is the number of writeset changes from which proving that a readset wasn't written to costs more
+// than validating its reads one by one
+const prefilterMaxScan = 1024
+
+// WithReadsetPrefilter validates readsets that no earlier tx has written to since they were read (or last validated)
+// without looking their reads up. Every readset and every writeset change is summarized as a digest of its keys, a
+// 512-bit bloom filter, and a readset is proven valid as long as its digest doesn't intersect the digests of the
+// changes made by earlier txs since then. Digests that may intersect, and readsets that observed inconsistent values,
+// are validated read by read as usual, so validation reaches the same results.
+//
+// The digests stop proving anything once readsets have hundreds of keys, since their filters saturate, and a readset
+// is validated read by read once more than a thousand writesets changed since it was last validated.
+func WithReadsetPrefilter() StoreOption {
+	return func(s *Store) {
+		s.prefilter = newWriteLog()
+	}
+}
+
+// keyDigest is a bloom filter of a set of keys, with one bit per key
+type keyDigest [8]uint64
+
+// add adds a key to the digest by its hash
+func (d *keyDigest) add(hash uint64) {
+	bit := hash % 512
+	d[bit/64] |= 1 << (bit % 64)
+}
+
+// intersects returns whether the sets of keys the digests summarize may intersect
+func (d *keyDigest) intersects(other *keyDigest) bool {
+	for i := range d {
+		if d[i]&other[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// saturated returns whether the digest has so many bits set that it intersects nearly every other one
+func (d *keyDigest) saturated() bool {
+	set := 0
+	for _, word := range d {
+		set += bits.OnesCount64(word)
+	}
+	return set > 256
+}
+
+// writeLogEntry is a change to the writeset of a tx, summarized by the digest of the keys it changed
+type writeLogEntry struct {
+	index  int
+	digest keyDigest
+}
+
+// prefilterReader is the digest of a readset, and the position in the write log as of which its reads are valid
+type prefilterReader struct {
+	digest  keyDigest
+	validAt int
+}
+
+// writeLog is the log of the writeset changes of a block, against which readsets are prefiltered
+type writeLog struct {
+	seed maphash.Seed
+	// overrides the hash of keys, only ever set by tests
+	keyHash func(key []byte) uint64
+
+	mtx     sync.RWMutex
+	entries []writeLogEntry
+	// tx index -> log position as of the start of its execution
+	started map[int]int
+	// tx index -> digest of its readset, for readsets that can be prefiltered
+	readers map[int]*prefilterReader
+}
+
+func newWriteLog() *writeLog {
+	return &writeLog{
+		seed:    maphash.MakeSeed(),
+		started: make(map[int]int),
+		readers: make(map[int]*prefilterReader),
+	}
+}
+
+// hash returns the hash of a key its digests are built from
+func (l *writeLog) hash(key string) uint64 {
+	if l.keyHash != nil {
+		return l.keyHash([]byte(key))
+	}
+	var h maphash.Hash
+	h.SetSeed(l.seed)
+	_, _ = h.WriteString(key)
+	return h.Sum64()
+}
+
+// start records that the tx at index starts executing, so that its reads reflect every change logged so far
+func (l *writeLog) start(index int) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.started[index] = len(l.entries)
+}
+
+// log records that the tx at index changed the given keys. It must be called once the changes are visible, so that
+// txs starting afterwards observe them.
+func (l *writeLog) log(index int, keys ...[]string) {
+	entry := writeLogEntry{index: index}
+	empty := true
+	for _, group := range keys {
+		for _, key := range group {
+			entry.digest.add(l.hash(key))
+			empty = false
+		}
+	}
+	if empty {
+		return
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+// setReadset records the readset of the tx at index as of the start of its execution. Readsets set without the tx
+// having started, or that observed inconsistent values, are always validated read by read.
+func (l *writeLog) setReadset(index int, readset ReadSet) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	delete(l.readers, index)
+	validAt, ok := l.started[index]
+	delete(l.started, index)
+	if !ok {
+		return
+	}
+	reader := &prefilterReader{validAt: validAt}
+	for key, values := range readset {
+		if len(values) > 1 {
+			return
+		}
+		reader.digest.add(l.hash(key))
+	}
+	if reader.digest.saturated() {
+		return
+	}
+	l.readers[index] = reader
+}
+
+// clear forgets the readset of the tx at index
+func (l *writeLog) clear(index int) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	delete(l.readers, index)
+	delete(l.started, index)
+}
+
+// check returns the current log position and the digest of the readset of the tx at index, if any, along with
+// whether no earlier tx may have written to the readset since its reads were last known to be valid
+func (l *writeLog) check(index int) (int, *prefilterReader, bool) {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	position := len(l.entries)
+	reader, ok := l.readers[index]
+	if !ok || position-reader.validAt > prefilterMaxScan {
+		return position, reader, false
+	}
+	for i := reader.validAt; i < position; i++ {
+		entry := &l.entries[i]
+		if entry.index < index && entry.digest.intersects(&reader.digest) {
+			return position, reader, false
+		}
+	}
+	return position, reader, true
+}
+
+// validated records that the reads of a readset are valid as of the log position. A readset replaced meanwhile isn't
+// affected, since its reader is a new one.
+func (l *writeLog) validated(reader *prefilterReader, position int) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if reader.validAt < position {
+		reader.validAt = position
+	}
+}
+
+// prefilterReadset returns whether the readset of the tx at index is proven valid by the write log. Otherwise, it
+// returns a func to call if the readset is validated read by read.
+func (s *Store) prefilterReadset(index int) (bool, func()) {
+	if s.prefilter == nil {
+		return false, func() {}
+	}
+	position, reader, unchanged := s.prefilter.check(index)
+	if unchanged {
+		// later validations only need to check the changes made from now on
+		s.prefilter.validated(reader, position)
+		atomic.AddInt64(&s.validationCost.prefilteredReadsets, 1)
+		return true, nil
+	}
+	if reader == nil {
+		return false, func() {}
+	}
+	return false, func() { s.prefilter.validated(reader, position) }
+}
+
+// logWrites records the keys changed by a writeset change of the tx at index in the write log, if enabled
+func (s *Store) logWrites(index int, keys ...[]string) {
+	if s.prefilter != nil {
+		s.prefilter.log(index, keys...)
+	}
+}
@@ -0,0 +1,98 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+// prefilterKeyHash gives every key its own digest bit, so that digests only intersect if their keys do
+func prefilterKeyHash(key []byte) uint64 {
+	return uint64(key[len(key)-1])
+}
+
+func TestMultiVersionStoreReadsetPrefilter(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("key2"), []byte("parent2"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithPrefilterKeyHash(prefilterKeyHash))
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": []byte("value1")})
+
+	// the tx at index 3 reads a key written by an earlier tx and one from the parent store
+	vis := mvs.VersionedIndexedStore(3, 0, nil)
+	require.Equal(t, []byte("value1"), vis.Get([]byte("key1")))
+	require.Equal(t, []byte("parent2"), vis.Get([]byte("key2")))
+	vis.WriteToMultiVersionStore()
+	validate := func(expectedValid bool, expectedConflicts []int, prefiltered int) {
+		t.Helper()
+		valid, conflicts := mvs.ValidateTransactionState(3)
+		require.Equal(t, expectedValid, valid)
+		require.Equal(t, expectedConflicts, conflicts)
+		require.Equal(t, prefiltered, mvs.ValidationCost().PrefilteredReadsets)
+	}
+	validate(true, []int{}, 1)
+
+	// writes to other keys, or by later txs, don't affect it
+	mvs.SetWriteset(2, 0, multiversion.WriteSet{"key5": []byte("value5")})
+	mvs.SetWriteset(4, 0, multiversion.WriteSet{"key1": []byte("value4")})
+	validate(true, []int{}, 2)
+
+	// a write to one of its keys has it validated read by read, and prefiltered again from then on
+	mvs.SetWriteset(2, 1, multiversion.WriteSet{"key5": []byte("value5"), "key2": []byte("parent2")})
+	validate(true, []int{}, 2)
+	validate(true, []int{}, 3)
+
+	// removing a key from a writeset is a write to it too
+	mvs.SetWriteset(2, 2, multiversion.WriteSet{"key5": []byte("value5")})
+	validate(true, []int{}, 3)
+
+	// a read conflicting with an estimate is checked until the estimate is replaced
+	mvs.InvalidateWriteset(1, 0)
+	validate(true, []int{1}, 3)
+	validate(true, []int{1}, 3)
+	mvs.SetWriteset(1, 1, multiversion.WriteSet{"key1": []byte("changed")})
+	validate(false, []int{1}, 3)
+	validate(false, []int{1}, 3)
+}
+
+func TestMultiVersionStoreReadsetPrefilterWritesDuringExecution(t *testing.T) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()}, multiversion.WithPrefilterKeyHash(prefilterKeyHash))
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": []byte("value1")})
+
+	// an earlier tx writes the key after it was read, but before the readset is set
+	vis := mvs.VersionedIndexedStore(2, 0, nil)
+	require.Equal(t, []byte("value1"), vis.Get([]byte("key1")))
+	mvs.SetWriteset(1, 1, multiversion.WriteSet{"key1": []byte("changed")})
+	vis.WriteToMultiVersionStore()
+
+	valid, conflicts := mvs.ValidateTransactionState(2)
+	require.False(t, valid)
+	require.Equal(t, []int{1}, conflicts)
+	require.Zero(t, mvs.ValidationCost().PrefilteredReadsets)
+}
+
+func TestMultiVersionStoreReadsetPrefilterFallbacks(t *testing.T) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()}, multiversion.WithReadsetPrefilter())
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": []byte("value1")})
+
+	// readsets set without their tx starting, or with inconsistent reads, are validated read by read
+	mvs.SetReadset(2, multiversion.ReadSet{"key1": {[]byte("value1")}})
+	mvs.SetReadset(3, multiversion.ReadSet{"key1": {[]byte("value1"), []byte("value0")}})
+	valid, _ := mvs.ValidateTransactionState(2)
+	require.True(t, valid)
+	valid, _ = mvs.ValidateTransactionState(3)
+	require.False(t, valid)
+	require.Zero(t, mvs.ValidationCost().PrefilteredReadsets)
+
+	// so are readsets of a cleared tx
+	vis := mvs.VersionedIndexedStore(4, 0, nil)
+	vis.Get([]byte("key1"))
+	mvs.ClearReadset(4)
+	mvs.SetReadset(4, multiversion.ReadSet{"key1": {[]byte("value1")}})
+	valid, _ = mvs.ValidateTransactionState(4)
+	require.True(t, valid)
+	require.Zero(t, mvs.ValidationCost().PrefilteredReadsets)
+}
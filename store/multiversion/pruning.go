@@ -0,0 +1,44 @@
+package multiversion
+
+import "sync/atomic"
+
+// WithVersionPruning bounds the versions kept for keys written by many txs of a block. Once a prune index is set (see
+// SetPruneIndex), every write of a key removes its values written before the prune index, except for the latest of
+// them, which is all that txs from the prune index onward can read. This is only correct as long as no tx before the
+// prune index is executed or validated again, and since the versions of earlier txs are gone, it can't be combined
+// with GetSnapshotBeforeIndex or WriteLatestToStoreWithListeners, which read them.
+func WithVersionPruning() StoreOption {
+	return func(s *Store) {
+		s.versionPruning = true
+	}
+}
+
+// SetPruneIndex raises the index below which superseded versions are pruned, if pruning is enabled. The txs before it
+// must be final. Lowering the prune index has no effect.
+func (s *Store) SetPruneIndex(index int) {
+	for {
+		current := atomic.LoadInt64(&s.pruneIndex)
+		if int64(index) <= current || atomic.CompareAndSwapInt64(&s.pruneIndex, current, int64(index)) {
+			return
+		}
+	}
+}
+
+// PrunedVersions returns the number of superseded versions pruned since the store was created or reset
+func (s *Store) PrunedVersions() int {
+	return int(atomic.LoadInt64(&s.prunedVersions))
+}
+
+// pruneVersions prunes the superseded versions of a key that was just written, if pruning is enabled
+func (s *Store) pruneVersions(item MultiVersionValue) {
+	if !s.versionPruning {
+		return
+	}
+	index := atomic.LoadInt64(&s.pruneIndex)
+	if index <= 0 {
+		return
+	}
+	if pruned := item.Prune(int(index)); pruned > 0 {
+		atomic.AddInt64(&s.prunedVersions, int64(pruned))
+	}
+}
@@ -0,0 +1,87 @@
+package multiversion_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestMultiversionItemPrune(t *testing.T) {
+	mvItem := multiversion.NewMultiVersionItem()
+	mvItem.Set(1, 0, []byte("one"))
+	mvItem.SetEstimate(2, 0)
+	mvItem.Delete(3, 0)
+	mvItem.Set(5, 0, []byte("five"))
+	mvItem.Set(7, 0, []byte("seven"))
+	generation := mvItem.Generation()
+
+	// only the value written at 1 is superseded before 5, by the deletion at 3, and the estimate at 2 is kept
+	require.Equal(t, 1, mvItem.Prune(5))
+	require.Equal(t, generation, mvItem.Generation())
+	_, found := mvItem.GetLatestBeforeIndex(2)
+	require.False(t, found)
+	value, found := mvItem.GetLatestBeforeIndex(3)
+	require.True(t, found)
+	require.True(t, value.IsEstimate())
+	value, found = mvItem.GetLatestBeforeIndex(5)
+	require.True(t, found)
+	require.True(t, value.IsDeleted())
+	value, found = mvItem.GetLatestBeforeIndex(8)
+	require.True(t, found)
+	require.Equal(t, []byte("seven"), value.Value())
+
+	// nothing is left to prune before 5, while the deletion at 3 is superseded before 6
+	require.Zero(t, mvItem.Prune(5))
+	require.Equal(t, 1, mvItem.Prune(6))
+	value, found = mvItem.GetLatestBeforeIndex(6)
+	require.True(t, found)
+	require.Equal(t, []byte("five"), value.Value())
+}
+
+func TestMultiVersionStoreVersionPruning(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithVersionPruning())
+	hotKey := []byte("hot")
+	for i := 0; i < 10; i++ {
+		mvs.SetWriteset(i, 0, multiversion.WriteSet{string(hotKey): []byte(fmt.Sprintf("value%d", i))})
+	}
+	require.Zero(t, mvs.PrunedVersions())
+
+	// versions are pruned lazily, on the next write of the key
+	mvs.SetPruneIndex(5)
+	require.Zero(t, mvs.PrunedVersions())
+	mvs.SetWriteset(9, 1, multiversion.WriteSet{string(hotKey): []byte("value9")})
+	require.Equal(t, 4, mvs.PrunedVersions())
+	for i := 5; i <= 10; i++ {
+		require.Equal(t, []byte(fmt.Sprintf("value%d", i-1)), mvs.GetLatestBeforeIndex(i, hotKey).Value())
+	}
+	// reads before the prune index no longer see the pruned versions
+	require.Nil(t, mvs.GetLatestBeforeIndex(2, hotKey))
+
+	// the prune index never goes backwards
+	mvs.SetPruneIndex(3)
+	mvs.SetWriteset(8, 1, multiversion.WriteSet{string(hotKey): []byte("value8")})
+	require.Equal(t, 4, mvs.PrunedVersions())
+
+	// writesets can't be streamed once their versions are pruned, but the latest values are still written
+	require.Panics(t, func() {
+		_ = mvs.WriteLatestToStoreWithListeners(types.NewKVStoreKey("test"), nil)
+	})
+	mvs.WriteLatestToStore()
+	require.Equal(t, []byte("value9"), parentKVStore.Get(hotKey))
+
+	// nothing is pruned without the option, and reset clears it
+	mvs.Reset(parentKVStore)
+	mvs.SetPruneIndex(5)
+	for i := 0; i < 10; i++ {
+		mvs.SetWriteset(i, 0, multiversion.WriteSet{string(hotKey): []byte(fmt.Sprintf("value%d", i))})
+	}
+	require.Zero(t, mvs.PrunedVersions())
+	require.Equal(t, []byte("value0"), mvs.GetLatestBeforeIndex(1, hotKey).Value())
+}
@@ -0,0 +1,63 @@
+package multiversion
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// QualifiedKey is a key qualified by the name of the store it belongs to. Identical keys in different stores are
+// unrelated, so diagnostics that aggregate keys across stores should key them by QualifiedKey rather than the raw key.
+// It's comparable, so it can be used as a map key.
+type QualifiedKey struct {
+	Store string
+	Key   string
+}
+
+// NewQualifiedKey creates a qualified key from a store name and a raw key
+func NewQualifiedKey(store string, key []byte) QualifiedKey {
+	return QualifiedKey{Store: store, Key: string(key)}
+}
+
+// String returns the canonical form of the key, the store name and the hex encoded key separated by a slash, eg.
+// "bank/0201AB". The empty key is encoded as the store name followed by a slash.
+func (k QualifiedKey) String() string {
+	return k.Store + "/" + strings.ToUpper(hex.EncodeToString([]byte(k.Key)))
+}
+
+// Less orders qualified keys by store name, then by key
+func (k QualifiedKey) Less(other QualifiedKey) bool {
+	if k.Store != other.Store {
+		return k.Store < other.Store
+	}
+	return k.Key < other.Key
+}
+
+// MarshalText implements encoding.TextMarshaler, so that qualified keys can key JSON maps
+func (k QualifiedKey) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (k *QualifiedKey) UnmarshalText(text []byte) error {
+	parsed, err := ParseQualifiedKey(string(text))
+	if err != nil {
+		return err
+	}
+	*k = parsed
+	return nil
+}
+
+// ParseQualifiedKey parses the canonical form of a qualified key. Since hex encoded keys never contain a slash, the
+// store name may.
+func ParseQualifiedKey(s string) (QualifiedKey, error) {
+	idx := strings.LastIndex(s, "/")
+	if idx < 0 {
+		return QualifiedKey{}, fmt.Errorf("invalid qualified key %q: missing store", s)
+	}
+	key, err := hex.DecodeString(s[idx+1:])
+	if err != nil {
+		return QualifiedKey{}, fmt.Errorf("invalid qualified key %q: %w", s, err)
+	}
+	return NewQualifiedKey(s[:idx], key), nil
+}
@@ -0,0 +1,44 @@
+package multiversion_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+func TestQualifiedKey(t *testing.T) {
+	key := multiversion.NewQualifiedKey("bank", []byte{0x02, 0xab})
+	require.Equal(t, "bank/02AB", key.String())
+
+	parsed, err := multiversion.ParseQualifiedKey("bank/02AB")
+	require.NoError(t, err)
+	require.Equal(t, key, parsed)
+
+	// store names may contain slashes, and keys may be empty
+	parsed, err = multiversion.ParseQualifiedKey("wasm/contract/")
+	require.NoError(t, err)
+	require.Equal(t, multiversion.NewQualifiedKey("wasm/contract", nil), parsed)
+
+	_, err = multiversion.ParseQualifiedKey("02AB")
+	require.Error(t, err)
+	_, err = multiversion.ParseQualifiedKey("bank/zz")
+	require.Error(t, err)
+
+	// identical keys in different stores are distinct
+	counts := map[multiversion.QualifiedKey]int{
+		multiversion.NewQualifiedKey("bank", []byte("key")):    1,
+		multiversion.NewQualifiedKey("staking", []byte("key")): 2,
+	}
+	bz, err := json.Marshal(counts)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"bank/6B6579":1,"staking/6B6579":2}`, string(bz))
+	var decoded map[multiversion.QualifiedKey]int
+	require.NoError(t, json.Unmarshal(bz, &decoded))
+	require.Equal(t, counts, decoded)
+
+	require.True(t, multiversion.NewQualifiedKey("bank", []byte("b")).Less(multiversion.NewQualifiedKey("staking", []byte("a"))))
+	require.True(t, multiversion.NewQualifiedKey("bank", []byte("a")).Less(multiversion.NewQualifiedKey("bank", []byte("b"))))
+}
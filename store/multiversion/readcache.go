@@ -0,0 +1,248 @@
+package multiversion
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/telemetry"
+)
+
+// WithParentReadCache has the version indexed stores of the store share the values they read from the parent store,
+// so that a hot key missing from the multiversion store is read from the parent store once per block rather than once
+// per execution. The parent store is immutable until the block's writes are flushed to it, so the first value read
+// for a key stays valid for the whole block, and the cache holds every key read from the parent store until the store
+// is reset, unless bounded with WithParentReadCacheCapacity. Validation still reads the parent store itself, so that
+// mutations of the parent store are found (see ParentStateMutation), after which the cache is bypassed for the rest
+// of the block. Keys committed with CommitBefore are evicted, since their parent value changes.
+func WithParentReadCache() StoreOption {
+	return func(s *Store) {
+		if s.readCache == nil {
+			s.readCache = newParentReadCache(0)
+		}
+	}
+}
+
+// WithParentReadCacheCapacity enables the parent read cache (see WithParentReadCache), bounded to capacity bytes of
+// cached keys and values, evicting the least recently used keys once full. Every store key has a multiversion store
+// of its own, so the capacity is per store key: one module reading huge values can't evict the hot keys of the
+// others. Values larger than the capacity aren't cached. A capacity of 0 or less leaves the cache unbounded.
+func WithParentReadCacheCapacity(capacity int) StoreOption {
+	return func(s *Store) {
+		s.readCache = newParentReadCache(capacity)
+	}
+}
+
+// ParentReadCacheStats counts the reads of the parent store served by the shared read cache, see WithParentReadCache
+type ParentReadCacheStats struct {
+	// Hits is the number of reads served from the cache
+	Hits int
+	// Misses is the number of reads that went to the parent store, and populated the cache
+	Misses int
+	// Evictions is the number of keys evicted to keep the cache within its capacity, see WithParentReadCacheCapacity
+	Evictions int
+}
+
+// HitRate returns the fraction of the reads served from the cache, or 0 if there were none
+func (s ParentReadCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// cachedRead is a value read from the parent store, nil if the key doesn't exist
+type cachedRead struct {
+	key   string
+	value []byte
+}
+
+// size returns the bytes a cached read counts against the capacity of the cache
+func (r *cachedRead) size() int {
+	return len(r.key) + len(r.value)
+}
+
+// parentReadCache holds the values read from the parent store by key, shared by the version indexed stores of a block.
+// If bounded, the least recently used keys are evicted once the cached keys and values exceed the capacity.
+type parentReadCache struct {
+	mtx sync.RWMutex
+	// values holds the element of recency of each cached key, whose value is its *cachedRead
+	values map[string]*list.Element
+	// recency orders the cached keys from the most to the least recently used, only maintained if bounded
+	recency  *list.List
+	capacity int
+	size     int
+
+	hits      int64
+	misses    int64
+	evictions int64
+	// bypassed is whether reads skip the cache, once the parent store was found mutated (only accessed atomically)
+	bypassed int32
+}
+
+func newParentReadCache(capacity int) *parentReadCache {
+	return &parentReadCache{
+		values:   make(map[string]*list.Element),
+		recency:  list.New(),
+		capacity: capacity,
+	}
+}
+
+// bounded returns whether the cache evicts keys to stay within a capacity
+func (c *parentReadCache) bounded() bool {
+	return c.capacity > 0
+}
+
+// load returns the cached read of key, marking it as the most recently used
+func (c *parentReadCache) load(strKey string) (*cachedRead, bool) {
+	if !c.bounded() {
+		// without evictions the order of use doesn't matter, so hits only need the read lock
+		c.mtx.RLock()
+		defer c.mtx.RUnlock()
+		elem, ok := c.values[strKey]
+		if !ok {
+			return nil, false
+		}
+		return elem.Value.(*cachedRead), true
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	elem, ok := c.values[strKey]
+	if !ok {
+		return nil, false
+	}
+	c.recency.MoveToFront(elem)
+	return elem.Value.(*cachedRead), true
+}
+
+// store caches the value read for key, evicting the least recently used keys if the cache is over capacity
+func (c *parentReadCache) store(strKey string, value []byte) {
+	read := &cachedRead{key: strKey, value: value}
+	if c.bounded() && read.size() > c.capacity {
+		return
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if _, ok := c.values[strKey]; ok {
+		// concurrent misses read the same value, since the parent store doesn't change
+		return
+	}
+	c.values[strKey] = c.recency.PushFront(read)
+	c.size += read.size()
+	for c.bounded() && c.size > c.capacity {
+		c.remove(c.recency.Back())
+		atomic.AddInt64(&c.evictions, 1)
+	}
+}
+
+// remove removes a cached read from the cache, the caller holding the write lock
+func (c *parentReadCache) remove(elem *list.Element) {
+	read := c.recency.Remove(elem).(*cachedRead)
+	delete(c.values, read.key)
+	c.size -= read.size()
+}
+
+// get returns the value of key in the parent store, from the cache if it was read before, and whether it was
+func (c *parentReadCache) get(parent types.KVStore, key []byte, strKey string) ([]byte, bool) {
+	if atomic.LoadInt32(&c.bypassed) != 0 {
+		return parent.Get(key), false
+	}
+	if read, ok := c.load(strKey); ok {
+		atomic.AddInt64(&c.hits, 1)
+		return read.value, true
+	}
+	atomic.AddInt64(&c.misses, 1)
+	value := parent.Get(key)
+	c.store(strKey, value)
+	return value, false
+}
+
+// has returns whether key exists in the parent store, from the cache if its value was read before, and whether it was
+func (c *parentReadCache) has(parent types.KVStore, key []byte, strKey string) (bool, bool) {
+	if atomic.LoadInt32(&c.bypassed) == 0 {
+		if read, ok := c.load(strKey); ok {
+			atomic.AddInt64(&c.hits, 1)
+			return read.value != nil, true
+		}
+	}
+	return parent.Has(key), false
+}
+
+// evict removes keys whose parent value changed from the cache
+func (c *parentReadCache) evict(keys []string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for _, key := range keys {
+		if elem, ok := c.values[key]; ok {
+			c.remove(elem)
+		}
+	}
+}
+
+// bypass has reads skip the cache from now on
+func (c *parentReadCache) bypass() {
+	atomic.StoreInt32(&c.bypassed, 1)
+}
+
+// ParentReadCacheStats returns the reads served by the parent read cache of the store over the block so far, or zero
+// stats if it isn't enabled
+func (s *Store) ParentReadCacheStats() ParentReadCacheStats {
+	if s.readCache == nil {
+		return ParentReadCacheStats{}
+	}
+	return ParentReadCacheStats{
+		Hits:      int(atomic.LoadInt64(&s.readCache.hits)),
+		Misses:    int(atomic.LoadInt64(&s.readCache.misses)),
+		Evictions: int(atomic.LoadInt64(&s.readCache.evictions)),
+	}
+}
+
+// emitParentReadCacheStats emits the hits, misses and evictions of the parent read cache, if enabled
+func (s *Store) emitParentReadCacheStats() {
+	stats := s.ParentReadCacheStats()
+	if stats.Hits == 0 && stats.Misses == 0 {
+		return
+	}
+	telemetry.IncrCounterWithLabels([]string{"store", "mvs", "parent_cache", "hits"}, float32(stats.Hits), s.telemetryLabels())
+	telemetry.IncrCounterWithLabels([]string{"store", "mvs", "parent_cache", "misses"}, float32(stats.Misses), s.telemetryLabels())
+	if stats.Evictions > 0 {
+		telemetry.IncrCounterWithLabels([]string{"store", "mvs", "parent_cache", "evictions"}, float32(stats.Evictions), s.telemetryLabels())
+	}
+}
+
+// readParent reads key from the parent store, through the parent read cache if enabled, recording the read
+func (store *VersionIndexedStore) readParent(key []byte, strKey string) []byte {
+	start := time.Now()
+	if store.readCache == nil {
+		value := store.parent.Get(key)
+		store.recordRead(ReadSourceParent, start)
+		return value
+	}
+	value, cached := store.readCache.get(store.parent, key, strKey)
+	store.recordRead(parentReadSource(cached), start)
+	return value
+}
+
+// hasParent returns whether key exists in the parent store, through the parent read cache if enabled, recording the
+// read
+func (store *VersionIndexedStore) hasParent(key []byte, strKey string) bool {
+	start := time.Now()
+	if store.readCache == nil {
+		exists := store.parent.Has(key)
+		store.recordRead(ReadSourceParent, start)
+		return exists
+	}
+	exists, cached := store.readCache.has(store.parent, key, strKey)
+	store.recordRead(parentReadSource(cached), start)
+	return exists
+}
+
+// parentReadSource returns the source of a read of the parent store, given whether it was served from the cache
+func parentReadSource(cached bool) ReadSource {
+	if cached {
+		return ReadSourceParentCache
+	}
+	return ReadSourceParent
+}
@@ -0,0 +1,102 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	occ "github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestParentReadCache(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithParentReadCache())
+
+	// the first read of a key misses, and every later one by any tx hits, including reads of missing keys
+	vis1 := mvs.VersionedIndexedStore(1, 0, make(chan occ.Abort, 1))
+	require.Equal(t, []byte("value1"), vis1.Get([]byte("key1")))
+	require.Nil(t, vis1.Get([]byte("key2")))
+	vis2 := mvs.VersionedIndexedStore(2, 0, make(chan occ.Abort, 1))
+	require.Equal(t, []byte("value1"), vis2.Get([]byte("key1")))
+	vis3 := mvs.VersionedIndexedStore(3, 0, make(chan occ.Abort, 1))
+	require.True(t, vis3.Has([]byte("key1")))
+	require.False(t, vis3.Has([]byte("key2")))
+	require.Equal(t, multiversion.ParentReadCacheStats{Hits: 3, Misses: 2}, mvs.ParentReadCacheStats())
+	require.Equal(t, 0.6, mvs.ParentReadCacheStats().HitRate())
+
+	// writes of other txs still take precedence over the cached parent value
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": []byte("written")})
+	vis3 = mvs.VersionedIndexedStore(3, 0, make(chan occ.Abort, 1))
+	require.Equal(t, []byte("written"), vis3.Get([]byte("key1")))
+
+	// committed keys are evicted, since their parent value changed
+	require.Equal(t, 1, mvs.CommitBefore(2))
+	vis4 := mvs.VersionedIndexedStore(4, 0, make(chan occ.Abort, 1))
+	require.Equal(t, []byte("written"), vis4.Get([]byte("key1")))
+	require.Equal(t, multiversion.ParentReadCacheStats{Hits: 3, Misses: 3}, mvs.ParentReadCacheStats())
+
+	mvs.Reset(parentKVStore)
+	require.Equal(t, multiversion.ParentReadCacheStats{}, mvs.ParentReadCacheStats())
+	require.Zero(t, multiversion.ParentReadCacheStats{}.HitRate())
+}
+
+func TestParentReadCacheBypassedAfterMutation(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithParentReadCache())
+
+	vis1 := mvs.VersionedIndexedStore(1, 0, make(chan occ.Abort, 1))
+	require.Equal(t, []byte("value1"), vis1.Get([]byte("key1")))
+	vis1.WriteToMultiVersionStore()
+
+	// validation reads the parent store itself, so the mutation is found, and the stale value isn't served any more
+	parentKVStore.Set([]byte("key1"), []byte("mutated"))
+	valid, _ := mvs.ValidateTransactionState(1)
+	require.False(t, valid)
+	require.ErrorIs(t, mvs.ParentStateMutation(), multiversion.ErrParentStateMutation)
+	vis2 := mvs.VersionedIndexedStore(2, 0, make(chan occ.Abort, 1))
+	require.Equal(t, []byte("mutated"), vis2.Get([]byte("key1")))
+	require.Equal(t, multiversion.ParentReadCacheStats{Misses: 1}, mvs.ParentReadCacheStats())
+}
+
+func TestParentReadCacheCapacity(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+	parentKVStore.Set([]byte("key2"), []byte("value2"))
+	parentKVStore.Set([]byte("key3"), []byte("value3"))
+	parentKVStore.Set([]byte("huge"), make([]byte, 64))
+	// room for two keys of 4 bytes with values of 6 bytes
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithParentReadCacheCapacity(20))
+
+	vis := func(index int) *multiversion.VersionIndexedStore {
+		return mvs.VersionedIndexedStore(index, 0, make(chan occ.Abort, 1))
+	}
+	require.Equal(t, []byte("value1"), vis(1).Get([]byte("key1")))
+	require.Equal(t, []byte("value2"), vis(2).Get([]byte("key2")))
+	// key1 is used again, so key2 is the least recently used, and evicted by key3
+	require.Equal(t, []byte("value1"), vis(3).Get([]byte("key1")))
+	require.Equal(t, []byte("value3"), vis(4).Get([]byte("key3")))
+	require.Equal(t, multiversion.ParentReadCacheStats{Hits: 1, Misses: 3, Evictions: 1}, mvs.ParentReadCacheStats())
+	require.Equal(t, []byte("value1"), vis(5).Get([]byte("key1")))
+	require.Equal(t, []byte("value3"), vis(6).Get([]byte("key3")))
+	require.Equal(t, []byte("value2"), vis(7).Get([]byte("key2")))
+	require.Equal(t, multiversion.ParentReadCacheStats{Hits: 3, Misses: 4, Evictions: 2}, mvs.ParentReadCacheStats())
+
+	// values larger than the capacity are never cached, so they don't evict the hot keys
+	require.Len(t, vis(8).Get([]byte("huge")), 64)
+	require.Len(t, vis(9).Get([]byte("huge")), 64)
+	require.Equal(t, []byte("value3"), vis(10).Get([]byte("key3")))
+	require.Equal(t, []byte("value2"), vis(11).Get([]byte("key2")))
+	require.Equal(t, multiversion.ParentReadCacheStats{Hits: 5, Misses: 6, Evictions: 2}, mvs.ParentReadCacheStats())
+
+	// committed keys free their capacity
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key2": []byte("written")})
+	require.Equal(t, 1, mvs.CommitBefore(2))
+	require.Equal(t, []byte("value1"), vis(12).Get([]byte("key1")))
+	require.Equal(t, []byte("value3"), vis(13).Get([]byte("key3")))
+	require.Equal(t, multiversion.ParentReadCacheStats{Hits: 6, Misses: 7, Evictions: 2}, mvs.ParentReadCacheStats())
+}
@@ -0,0 +1,172 @@
+package multiversion
+
+import (
+	"sort"
+	"sync"
+)
+
+// readIndex is a reverse index of readset keys to the indices of the txs that read them, along with the keys whose
+// latest value changed since they were last taken. Together they let the scheduler revalidate only the txs that may
+// have been affected by writeset changes, rather than every tx in the block.
+type readIndex struct {
+	mtx        sync.Mutex
+	keyReaders map[string]map[int]struct{}
+	txReadKeys map[int][]string
+
+	dirtyMtx  sync.Mutex
+	dirtyKeys map[string]int
+}
+
+func newReadIndex() *readIndex {
+	return &readIndex{
+		keyReaders: make(map[string]map[int]struct{}),
+		txReadKeys: make(map[int][]string),
+		dirtyKeys:  make(map[string]int),
+	}
+}
+
+// reset clears the index, keeping the allocated maps around for reuse
+func (ri *readIndex) reset() {
+	ri.mtx.Lock()
+	for key := range ri.keyReaders {
+		delete(ri.keyReaders, key)
+	}
+	for index := range ri.txReadKeys {
+		delete(ri.txReadKeys, index)
+	}
+	ri.mtx.Unlock()
+	ri.dirtyMtx.Lock()
+	for key := range ri.dirtyKeys {
+		delete(ri.dirtyKeys, key)
+	}
+	ri.dirtyMtx.Unlock()
+}
+
+// set replaces the indexed readset keys of the tx at index
+func (ri *readIndex) set(index int, readset ReadSet) {
+	ri.mtx.Lock()
+	defer ri.mtx.Unlock()
+	// reuse the slice of the tx's previous readset keys
+	keys := ri.txReadKeys[index][:0]
+	ri.removeLocked(index)
+	for key := range readset {
+		keys = append(keys, key)
+	}
+	ri.addLocked(index, keys)
+}
+
+// setKeys replaces the indexed readset keys of the tx at index with keys, which the index takes ownership of
+func (ri *readIndex) setKeys(index int, keys []string) {
+	ri.mtx.Lock()
+	defer ri.mtx.Unlock()
+	ri.removeLocked(index)
+	ri.addLocked(index, keys)
+}
+
+// add indexes keys as read by the tx at index, in addition to its indexed readset keys
+func (ri *readIndex) add(index int, keys []string) {
+	ri.mtx.Lock()
+	defer ri.mtx.Unlock()
+	previous := ri.txReadKeys[index]
+	ri.addLocked(index, keys)
+	ri.txReadKeys[index] = append(previous, keys...)
+}
+
+func (ri *readIndex) addLocked(index int, keys []string) {
+	for _, key := range keys {
+		readers, ok := ri.keyReaders[key]
+		if !ok {
+			readers = make(map[int]struct{})
+			ri.keyReaders[key] = readers
+		}
+		readers[index] = struct{}{}
+	}
+	ri.txReadKeys[index] = keys
+}
+
+// remove removes the indexed readset keys of the tx at index
+func (ri *readIndex) remove(index int) {
+	ri.mtx.Lock()
+	defer ri.mtx.Unlock()
+	ri.removeLocked(index)
+}
+
+func (ri *readIndex) removeLocked(index int) {
+	for _, key := range ri.txReadKeys[index] {
+		readers := ri.keyReaders[key]
+		delete(readers, index)
+		if len(readers) == 0 {
+			delete(ri.keyReaders, key)
+		}
+	}
+	delete(ri.txReadKeys, index)
+}
+
+// readersAfter adds the indices of txs after index that read key to readers
+func (ri *readIndex) readersAfter(index int, key string, readers map[int]struct{}) {
+	ri.mtx.Lock()
+	defer ri.mtx.Unlock()
+	for reader := range ri.keyReaders[key] {
+		if reader > index {
+			readers[reader] = struct{}{}
+		}
+	}
+}
+
+// markDirty records that the latest values of keys changed for txs after index
+func (ri *readIndex) markDirty(index int, keys []string) {
+	ri.dirtyMtx.Lock()
+	defer ri.dirtyMtx.Unlock()
+	for _, key := range keys {
+		if writer, ok := ri.dirtyKeys[key]; !ok || index < writer {
+			ri.dirtyKeys[key] = index
+		}
+	}
+}
+
+// takeDirty returns and resets the dirty keys
+func (ri *readIndex) takeDirty() map[string]int {
+	ri.dirtyMtx.Lock()
+	defer ri.dirtyMtx.Unlock()
+	dirty := ri.dirtyKeys
+	ri.dirtyKeys = make(map[string]int)
+	return dirty
+}
+
+// TakeDirtyKeys returns the keys whose latest value changed since the last call, mapped to the lowest index of the
+// txs whose writeset changes touched them. Only txs after that index may have observed a stale value.
+func (s *Store) TakeDirtyKeys() map[string]int {
+	return s.readIndex.takeDirty()
+}
+
+// GetAffectedReaders returns the sorted indices of txs whose readset or iterateset touched any of the dirty keys
+// after the index of the tx that dirtied them, ie. the txs that need to be revalidated.
+func (s *Store) GetAffectedReaders(dirty map[string]int) []int {
+	if len(dirty) == 0 {
+		return nil
+	}
+	readers := make(map[int]struct{})
+	for key, writer := range dirty {
+		s.readIndex.readersAfter(writer, key, readers)
+	}
+	s.txIterateSets.Range(func(key, value interface{}) bool {
+		readerIndex := key.(int)
+		if _, ok := readers[readerIndex]; ok {
+			return true
+		}
+		for _, tracker := range value.(Iterateset) {
+			if tracker.containsAnyBefore(readerIndex, dirty) {
+				readers[readerIndex] = struct{}{}
+				break
+			}
+		}
+		return true
+	})
+
+	readerIndices := make([]int, 0, len(readers))
+	for readerIndex := range readers {
+		readerIndices = append(readerIndices, readerIndex)
+	}
+	sort.Ints(readerIndices)
+	return readerIndices
+}
@@ -0,0 +1,153 @@
+package multiversion
+
+import (
+	"math/bits"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+)
+
+// ReadSource is where a read of a version indexed store that isn't served from its own writes or earlier reads is
+// served from
+type ReadSource int
+
+const (
+	// ReadSourceMultiVersion is a read served by the write of an earlier tx in the multiversion store
+	ReadSourceMultiVersion ReadSource = iota
+	// ReadSourceParent is a read that fell through to the parent store
+	ReadSourceParent
+	// ReadSourceParentCache is a read of the parent store served by the shared read cache, see WithParentReadCache
+	ReadSourceParentCache
+
+	numReadSources = int(ReadSourceParentCache) + 1
+)
+
+var readSourceNames = [numReadSources]string{
+	ReadSourceMultiVersion: "mvs",
+	ReadSourceParent:       "parent",
+	ReadSourceParentCache:  "parent_cache",
+}
+
+// String returns the name of the read source, for telemetry keys
+func (source ReadSource) String() string {
+	if source >= 0 && int(source) < numReadSources {
+		return readSourceNames[source]
+	}
+	return "source(" + strconv.Itoa(int(source)) + ")"
+}
+
+// latencyBuckets is the number of buckets of a latencyHistogram, enough for latencies of over a minute
+const latencyBuckets = 40
+
+// latencyHistogram counts latencies in power of two buckets: bucket i counts the latencies of less than 2^i ns that
+// don't fit in an earlier bucket, and the last bucket counts the rest
+type latencyHistogram [latencyBuckets]int64
+
+func (h *latencyHistogram) record(elapsed time.Duration) {
+	h[latencyBucket(elapsed)]++
+}
+
+// latencyBucket returns the bucket of a latencyHistogram counting a latency
+func latencyBucket(elapsed time.Duration) int {
+	bucket := 0
+	if elapsed > 0 {
+		bucket = bits.Len64(uint64(elapsed))
+	}
+	if bucket >= latencyBuckets {
+		bucket = latencyBuckets - 1
+	}
+	return bucket
+}
+
+// count returns the number of latencies in the histogram
+func (h *latencyHistogram) count() int64 {
+	var count int64
+	for bucket := range h {
+		count += atomic.LoadInt64(&h[bucket])
+	}
+	return count
+}
+
+// quantile returns an upper bound on the q quantile of the latencies in the histogram, which is at most twice the
+// actual quantile, or 0 if the histogram is empty
+func (h *latencyHistogram) quantile(q float64) time.Duration {
+	count := h.count()
+	if count == 0 {
+		return 0
+	}
+	rank := int64(q * float64(count))
+	if rank >= count {
+		rank = count - 1
+	}
+	var seen int64
+	for bucket := range h {
+		seen += atomic.LoadInt64(&h[bucket])
+		if seen > rank {
+			return time.Duration(1) << bucket
+		}
+	}
+	return time.Duration(1) << (latencyBuckets - 1)
+}
+
+// readLatencyQuantiles are the quantiles of read latencies emitted by FlushTelemetry
+var readLatencyQuantiles = []struct {
+	name string
+	q    float64
+}{
+	{"p50", 0.5},
+	{"p90", 0.9},
+	{"p99", 0.99},
+}
+
+// ReadCount returns the number of reads of the version indexed stores of the store served from a source, as of their
+// last writes to it
+func (s *Store) ReadCount(source ReadSource) int {
+	return int(s.readLatency[source].count())
+}
+
+// ReadLatency returns an upper bound on the q quantile of the latencies of reads of the version indexed stores of the
+// store served from a source, as of their last writes to it. Latencies are kept in power of two buckets, so the bound
+// is at most twice the actual quantile.
+func (s *Store) ReadLatency(source ReadSource, q float64) time.Duration {
+	return s.readLatency[source].quantile(q)
+}
+
+// emitReadLatency emits the quantiles of read latencies by source, in milliseconds. Stores whose parent reads are
+// slow compared to their multiversion reads are the ones that benefit the most from caching or preloading.
+func (s *Store) emitReadLatency() {
+	for source := ReadSource(0); int(source) < numReadSources; source++ {
+		if s.ReadCount(source) == 0 {
+			continue
+		}
+		for _, quantile := range readLatencyQuantiles {
+			telemetry.SetGaugeWithLabels(
+				[]string{"store", "mvs", "read_latency", source.String(), quantile.name},
+				float32(s.ReadLatency(source, quantile.q).Seconds()*1000),
+				s.telemetryLabels(),
+			)
+		}
+	}
+}
+
+// recordRead records the latency of a read served from a source locally, since stores are used by a single execution
+func (store *VersionIndexedStore) recordRead(source ReadSource, start time.Time) {
+	store.readLatency[source].record(time.Since(start))
+}
+
+// flushReadLatency adds the read latencies recorded by the store to the histograms of its multiversion store, if it
+// has one, along with its operations
+func (store *VersionIndexedStore) flushReadLatency() {
+	if store.readLatencyTotals == nil {
+		return
+	}
+	for source := range store.readLatency {
+		for bucket, count := range store.readLatency[source] {
+			if count > 0 {
+				atomic.AddInt64(&store.readLatencyTotals[source][bucket], count)
+			}
+		}
+	}
+	store.readLatency = [numReadSources]latencyHistogram{}
+}
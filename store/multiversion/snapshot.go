@@ -0,0 +1,136 @@
+package multiversion
+
+import (
+	"io"
+
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// snapshotStore is a read-only view of a multiversion store as of right before a tx index, serving the latest
+// committed write of every key by an earlier tx, and falling through to the parent store otherwise.
+type snapshotStore struct {
+	mvs   *Store
+	index int
+}
+
+var _ types.KVStore = (*snapshotStore)(nil)
+
+// GetSnapshotBeforeIndex returns a read-only KVStore of the state as of right before the tx at index, eg. for
+// simulating or tracing a tx against the state it observed within a block. Writes of earlier txs that are ESTIMATEs
+// are skipped in favor of their latest committed write, so the snapshot never aborts. Since writes may still be
+// re-executed, the view is only consistent once the txs below index are validated. Writes panic, so callers that need
+// a writable view should wrap the snapshot in a cachekv store.
+func (s *Store) GetSnapshotBeforeIndex(index int) types.KVStore {
+	return &snapshotStore{mvs: s, index: index}
+}
+
+// get returns the value of key as of the snapshot, and nil if it doesn't exist or was deleted
+func (s *snapshotStore) get(key []byte) []byte {
+	types.AssertValidKey(key)
+	mvVal, found := s.mvs.multiVersionMap.Load(string(key))
+	if !found {
+		return s.mvs.parentStore.Get(key)
+	}
+	index := s.index
+	for {
+		val, found := mvVal.(MultiVersionValue).GetLatestBeforeIndex(index)
+		if !found {
+			return s.mvs.parentStore.Get(key)
+		}
+		if !val.IsEstimate() {
+			if val.IsDeleted() {
+				return nil
+			}
+			return val.Value()
+		}
+		index = val.Index()
+	}
+}
+
+// Get implements types.KVStore.
+func (s *snapshotStore) Get(key []byte) []byte {
+	return types.CopyBytes(s.get(key))
+}
+
+// Has implements types.KVStore.
+func (s *snapshotStore) Has(key []byte) bool {
+	return s.get(key) != nil
+}
+
+// Set implements types.KVStore.
+func (*snapshotStore) Set(key []byte, value []byte) {
+	panic("Set not supported for multiversion store snapshot")
+}
+
+// Delete implements types.KVStore.
+func (*snapshotStore) Delete(key []byte) {
+	panic("Delete not supported for multiversion store snapshot")
+}
+
+// Iterator implements types.KVStore.
+func (s *snapshotStore) Iterator(start, end []byte) types.Iterator {
+	return s.iterator(start, end, true)
+}
+
+// ReverseIterator implements types.KVStore.
+func (s *snapshotStore) ReverseIterator(start, end []byte) types.Iterator {
+	return s.iterator(start, end, false)
+}
+
+func (s *snapshotStore) iterator(start, end []byte, ascending bool) types.Iterator {
+	items := s.mvs.CollectIteratorItemsInRange(s.index, start, end)
+
+	var parent, cache dbm.Iterator
+	var err error
+	if ascending {
+		parent = s.mvs.parentStore.Iterator(start, end)
+		cache, err = items.Iterator(start, end)
+	} else {
+		parent = s.mvs.parentStore.ReverseIterator(start, end)
+		cache, err = items.ReverseIterator(start, end)
+	}
+	if err != nil {
+		parent.Close()
+		panic(err)
+	}
+	return NewMVSMergeIterator(parent, &snapshotIterator{Iterator: cache, snapshot: s}, ascending, NoOpHandler{})
+}
+
+// snapshotIterator iterates over the keys written before the snapshot index, serving their values from the snapshot.
+// Deleted keys have nil values so that the merge iterator skips them.
+type snapshotIterator struct {
+	types.Iterator
+	snapshot *snapshotStore
+}
+
+// Value implements types.Iterator.
+func (iter *snapshotIterator) Value() []byte {
+	return types.CopyBytes(iter.snapshot.get(iter.Iterator.Key()))
+}
+
+// GetStoreType implements types.KVStore.
+func (s *snapshotStore) GetStoreType() types.StoreType {
+	return s.mvs.parentStore.GetStoreType()
+}
+
+// GetWorkingHash implements types.KVStore.
+func (*snapshotStore) GetWorkingHash() ([]byte, error) {
+	panic("should never attempt to get working hash from multiversion store snapshot")
+}
+
+// CacheWrap implements types.KVStore.
+func (*snapshotStore) CacheWrap(storeKey types.StoreKey) types.CacheWrap {
+	panic("CacheWrap not supported for multiversion store snapshot")
+}
+
+// CacheWrapWithTrace implements types.KVStore.
+func (*snapshotStore) CacheWrapWithTrace(storeKey types.StoreKey, w io.Writer, tc types.TraceContext) types.CacheWrap {
+	panic("CacheWrapWithTrace not supported for multiversion store snapshot")
+}
+
+// CacheWrapWithListeners implements types.KVStore.
+func (*snapshotStore) CacheWrapWithListeners(storeKey types.StoreKey, listeners []types.WriteListener) types.CacheWrap {
+	panic("CacheWrapWithListeners not supported for multiversion store snapshot")
+}
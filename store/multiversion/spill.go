@@ -0,0 +1,98 @@
+package multiversion
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	db "github.com/tendermint/tm-db"
+)
+
+// StoreOption configures optional multiversion store behavior
+type StoreOption func(*Store)
+
+// WithReadsetSpill bounds the memory used by readsets. Once the in-memory readsets exceed the budget (in bytes), further
+// readsets are spilled to the given database, trading speed for memory. This is intended for replay tooling that
+// processes very large blocks on low-memory machines, not for consensus nodes.
+func WithReadsetSpill(budgetBytes int64, spillDB db.DB) StoreOption {
+	return func(s *Store) {
+		s.readsetSpill = &readsetSpill{
+			budget: budgetBytes,
+			db:     spillDB,
+		}
+	}
+}
+
+// spilledReadset marks a readset in txReadSets that lives in the spill database
+type spilledReadset struct{}
+
+type readsetSpill struct {
+	budget int64
+	used   int64
+	db     db.DB
+	sizes  sync.Map // map of tx index -> size of the in-memory readset
+}
+
+func readsetSize(readset ReadSet) int64 {
+	var size int64
+	for key, values := range readset {
+		size += int64(len(key))
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	return size
+}
+
+func spillKey(index int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(index))
+	return key
+}
+
+// reserve accounts for an in-memory readset, returning false if it doesn't fit in the budget
+func (sp *readsetSpill) reserve(index int, readset ReadSet) bool {
+	size := readsetSize(readset)
+	if atomic.AddInt64(&sp.used, size) > sp.budget {
+		atomic.AddInt64(&sp.used, -size)
+		return false
+	}
+	sp.sizes.Store(index, size)
+	return true
+}
+
+// release frees the budget or spilled data held by the readset at the index
+func (sp *readsetSpill) release(index int, spilled bool) {
+	if spilled {
+		if err := sp.db.Delete(spillKey(index)); err != nil {
+			panic(err)
+		}
+		return
+	}
+	if size, ok := sp.sizes.LoadAndDelete(index); ok {
+		atomic.AddInt64(&sp.used, -size.(int64))
+	}
+}
+
+func (sp *readsetSpill) spill(index int, readset ReadSet) {
+	bz, err := json.Marshal(readset)
+	if err != nil {
+		panic(err)
+	}
+	if err := sp.db.Set(spillKey(index), bz); err != nil {
+		panic(err)
+	}
+}
+
+func (sp *readsetSpill) load(index int) ReadSet {
+	bz, err := sp.db.Get(spillKey(index))
+	if err != nil {
+		panic(err)
+	}
+	var readset ReadSet
+	if err := json.Unmarshal(bz, &readset); err != nil {
+		panic(err)
+	}
+	return readset
+}
@@ -0,0 +1,49 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+func TestMultiVersionStoreReadsetSpill(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	spillDB := dbm.NewMemDB()
+	// the budget fits a single readset
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithReadsetSpill(12, spillDB))
+
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key2": []byte("value2")})
+
+	inMemory := multiversion.ReadSet{"key1": {[]byte("value1")}}
+	spilled := multiversion.ReadSet{"key2": {[]byte("stale")}, "key3": {nil}}
+	mvs.SetReadset(2, inMemory)
+	mvs.SetReadset(3, spilled)
+
+	// the spilled readset round trips, including nil values
+	require.Equal(t, inMemory, mvs.GetReadset(2))
+	require.Equal(t, spilled, mvs.GetReadset(3))
+	stats := spillDB.Stats()
+	require.Equal(t, "1", stats["database.size"])
+
+	// validation and reader lookups see spilled readsets
+	valid, conflicts := mvs.ValidateTransactionState(3)
+	require.False(t, valid)
+	require.Equal(t, []int{1}, conflicts)
+	require.Equal(t, []int{3}, mvs.GetDependentReaders(1, []string{"key2"}))
+
+	// clearing the in-memory readset frees budget for the next one
+	mvs.ClearReadset(3)
+	require.Nil(t, mvs.GetReadset(3))
+	require.Equal(t, "0", spillDB.Stats()["database.size"])
+	mvs.ClearReadset(2)
+	mvs.SetReadset(3, multiversion.ReadSet{"key2": {[]byte("value2")}})
+	require.Equal(t, "0", spillDB.Stats()["database.size"])
+	valid, conflicts = mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+}
@@ -2,36 +2,78 @@ package multiversion
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cosmos/cosmos-sdk/store/types"
 	"github.com/cosmos/cosmos-sdk/types/occ"
 	occtypes "github.com/cosmos/cosmos-sdk/types/occ"
+	"github.com/cosmos/iavl"
 	db "github.com/tendermint/tm-db"
 )
 
 type MultiVersionStore interface {
 	GetLatest(key []byte) (value MultiVersionValueItem)
 	GetLatestBeforeIndex(index int, key []byte) (value MultiVersionValueItem)
+	WaitLatestBeforeIndex(index int, key []byte, timeout time.Duration) (value MultiVersionValueItem)
 	Has(index int, key []byte) bool
 	WriteLatestToStore()
+	WriteLatestToStoreWithListeners(storeKey types.StoreKey, listeners []types.WriteListener) error
+	ChangeSet() iavl.ChangeSet
 	SetWriteset(index int, incarnation int, writeset WriteSet)
 	InvalidateWriteset(index int, incarnation int)
 	SetEstimatedWriteset(index int, incarnation int, writeset WriteSet)
+	RemoveEstimatesForIndex(index int)
 	GetAllWritesetKeys() map[int][]string
+	GetWritesetKeys(index int) []string
+	WritesetKeysInRange(index int, start, end []byte) []string
+	RangeWritesetKeys(fn func(index int, keys []string) bool)
+	GetDependentReaders(index int, keys []string) []int
+	TakeDirtyKeys() map[string]int
+	GetAffectedReaders(dirty map[string]int) []int
 	CollectIteratorItems(index int) *db.MemDB
+	CollectIteratorItemsInRange(index int, start, end []byte) *db.MemDB
 	SetReadset(index int, readset ReadSet)
+	SetReadsetWithGenerations(index int, readset ReadSet, generations map[string]uint64)
+	GetLatestBeforeIndexWithGeneration(index int, key []byte) (value MultiVersionValueItem, generation uint64)
 	GetReadset(index int) ReadSet
+	RangeReadsets(fn func(index int, readset ReadSet) bool)
 	ClearReadset(index int)
 	VersionedIndexedStore(index int, incarnation int, abortChannel chan occ.Abort) *VersionIndexedStore
+	ReuseVersionIndexedStore(vis *VersionIndexedStore, incarnation int, abortChannel chan occ.Abort) *VersionIndexedStore
 	SetIterateset(index int, iterateset Iterateset)
 	GetIterateset(index int) Iterateset
+	SetExistenceSet(index int, existenceset ExistenceSet)
+	GetExistenceSet(index int) ExistenceSet
 	ClearIterateset(index int)
 	ValidateTransactionState(index int) (bool, []int)
+	SetFlushListener(storeName string, listener FlushListener)
+	ValidationCost() ValidationCost
+	FlushTelemetry()
+	Reset(parentStore types.KVStore, opts ...StoreOption)
+	LatestWritesetHash() []byte
+	GetSnapshotBeforeIndex(index int) types.KVStore
+	SetPruneIndex(index int)
+	PrunedVersions() int
+	CommitBefore(index int) int
+	CommittedIndex() int
+	ParentReadCacheStats() ParentReadCacheStats
+	Inspect() StoreState
+	ParentStateMutation() error
+	LatestEstimate() (key []byte, index int, found bool)
+	MemoryUsage() MemoryUsage
 }
 
 type WriteSet map[string][]byte
+
+// ReadSet holds the distinct values a tx observed for each key it read from earlier txs or the parent store, in the
+// order they were first observed. Reads are served from the first observed value (and reads of keys the tx has
+// written are served from its writeset without being recorded), so more than one value means the tx observed
+// inconsistent state, eg. through an iterator, and always fails validation.
 type ReadSet map[string][][]byte
 type Iterateset []*iterationTracker
 
@@ -42,31 +84,210 @@ type Store struct {
 	multiVersionMap *sync.Map
 	// TODO: do we need to support iterators as well similar to how cachekv does it - yes
 
-	txWritesetKeys *sync.Map // map of tx index -> writeset keys []string
+	// map of tx index -> writeset keys []string, sorted. They're replaced rather than modified, so a sorted slice is
+	// all it takes for range queries, see WritesetKeysInRange.
+	txWritesetKeys *sync.Map
 	txReadSets     *sync.Map // map of tx index -> readset ReadSet
 	txIterateSets  *sync.Map // map of tx index -> iterateset Iterateset
+	// map of tx index -> generations of the keys of its readset as of their reads, see SetReadsetWithGenerations
+	txReadGenerations *sync.Map
+	txExistenceSets   *sync.Map // map of tx index -> existence set ExistenceSet
+	// map of tx index -> the values of its readset replaced with their digests, see WithReadsetDigests
+	txDigestedReads *sync.Map
 
 	parentStore types.KVStore
+
+	// optional listener for writeset flushes
+	storeName     string
+	flushListener FlushListener
+	// optional listener for the keys failing validation
+	invalidationListener InvalidationListener
+
+	// optional spill of readsets that don't fit in memory
+	readsetSpill *readsetSpill
+	// size from which read values are kept as digests, or 0 to keep full values
+	readsetDigestMinSize int
+	// table of the keys read in the block if readsets are hashed
+	readKeys *readKeyTable
+	// keys of the block, interned to be shared with the version indexed stores
+	keys *keyTable
+	// sorted index of the keys written in the block, for iterators
+	keyIndex *keyIndex
+
+	// cumulative validation cost by phase
+	validationCost validationCost
+
+	// operations of the version indexed stores, and whether telemetry is only emitted by FlushTelemetry
+	operations       operationCounts
+	batchedTelemetry bool
+	// latencies of the reads of the version indexed stores, by source
+	readLatency [numReadSources]latencyHistogram
+	// estimates set, resolved and read over the block, see EstimateStats
+	estimates *estimateStats
+
+	// reverse index of readset keys, and keys changed by writeset updates
+	readIndex *readIndex
+
+	// log of writeset changes readsets are prefiltered against, if enabled
+	prefilter *writeLog
+
+	// whether superseded versions are pruned, the index they're pruned below and the number pruned, see
+	// WithVersionPruning
+	versionPruning bool
+	pruneIndex     int64
+	prunedVersions int64
+
+	// index below which the writes of the block were committed to the parent store, see CommitBefore
+	committedIndex int64
+
+	// first mutation of the parent store found by validation, see ParentStateMutation
+	parentMutationMx sync.Mutex
+	parentMutation   *ParentStateMutationError
+
+	// bytes held by the writesets and readsets of the block, see MemoryUsage
+	memory blockMemory
+
+	// whether values are copied before they're handed out, and the checksums of the values of the block if mutations
+	// are detected, see WithDefensiveCopies and WithMutationDetection
+	defensiveCopies bool
+	valueChecksums  *sync.Map // map of valueRef -> [sha256.Size]byte
+
+	// log the accesses of the version indexed stores are appended to, if any, see WithAccessLog
+	accessLog *AccessLog
+	// metadata of the store as of the start of the block, by name, see WithMetadataSnapshot
+	metadata map[string][]byte
+
+	// values read from the parent store, shared by the version indexed stores of the block, see WithParentReadCache
+	readCache *parentReadCache
+}
+
+func NewMultiVersionStore(parentStore types.KVStore, opts ...StoreOption) *Store {
+	s := &Store{
+		multiVersionMap:   &sync.Map{},
+		txWritesetKeys:    &sync.Map{},
+		txReadSets:        &sync.Map{},
+		txIterateSets:     &sync.Map{},
+		txReadGenerations: &sync.Map{},
+		txExistenceSets:   &sync.Map{},
+		txDigestedReads:   &sync.Map{},
+		parentStore:       parentStore,
+		readIndex:         newReadIndex(),
+		keys:              newKeyTable(),
+		keyIndex:          newKeyIndex(),
+		estimates:         &estimateStats{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Reset clears all block state from the store and points it at a new parent store, so that it can be reused for the
+// next block instead of allocating a new one. The multiversion items of the previous block are returned to a pool
+// shared by all stores, so nothing previously returned by the store may be used after it is reset. Options are
+// cleared and then reapplied from opts, as in NewMultiVersionStore.
+func (s *Store) Reset(parentStore types.KVStore, opts ...StoreOption) {
+	s.multiVersionMap.Range(func(key, value interface{}) bool {
+		putMultiVersionItem(value.(*multiVersionItem))
+		return true
+	})
+	s.multiVersionMap = &sync.Map{}
+	s.txWritesetKeys = &sync.Map{}
+	s.txReadSets = &sync.Map{}
+	s.txIterateSets = &sync.Map{}
+	s.txReadGenerations = &sync.Map{}
+	s.txExistenceSets = &sync.Map{}
+	s.txDigestedReads = &sync.Map{}
+	s.parentStore = parentStore
+	s.parentMutation = nil
+	s.memory.reset()
+	s.storeName = ""
+	s.flushListener = nil
+	s.invalidationListener = nil
+	s.readsetSpill = nil
+	s.readsetDigestMinSize = 0
+	s.readKeys = nil
+	s.prefilter = nil
+	s.versionPruning = false
+	s.pruneIndex = 0
+	s.prunedVersions = 0
+	s.committedIndex = 0
+	s.validationCost = validationCost{}
+	s.operations = operationCounts{}
+	s.readLatency = [numReadSources]latencyHistogram{}
+	s.estimates = &estimateStats{}
+	s.batchedTelemetry = false
+	s.defensiveCopies = false
+	s.valueChecksums = nil
+	s.accessLog = nil
+	s.metadata = nil
+	s.readCache = nil
+	s.readIndex.reset()
+	s.keys.reset()
+	s.keyIndex.reset()
+	for _, opt := range opts {
+		opt(s)
+	}
 }
 
-func NewMultiVersionStore(parentStore types.KVStore) *Store {
-	return &Store{
-		multiVersionMap: &sync.Map{},
-		txWritesetKeys:  &sync.Map{},
-		txReadSets:      &sync.Map{},
-		txIterateSets:   &sync.Map{},
-		parentStore:     parentStore,
+// mustValidateIncarnation panics if an incarnation passed into the store is out of range
+func mustValidateIncarnation(incarnation int) {
+	if err := occ.ValidateIncarnation(incarnation); err != nil {
+		panic(err)
 	}
 }
 
 // VersionedIndexedStore creates a new versioned index store for a given incarnation and transaction index
 func (s *Store) VersionedIndexedStore(index int, incarnation int, abortChannel chan occ.Abort) *VersionIndexedStore {
-	return NewVersionIndexedStore(s.parentStore, s, index, incarnation, abortChannel)
+	mustValidateIncarnation(incarnation)
+	if s.prefilter != nil {
+		s.prefilter.start(index)
+	}
+	vis := NewVersionIndexedStore(s.parentStore, s, index, incarnation, abortChannel)
+	vis.storeName = s.storeName
+	vis.keys = s.keys
+	vis.operationTotals = &s.operations
+	vis.readLatencyTotals = &s.readLatency
+	vis.estimateTotals = s.estimates
+	vis.accessLog = s.accessLog
+	vis.metadata = s.metadata
+	vis.readCache = s.readCache
+	return vis
+}
+
+// ReuseVersionIndexedStore resets a version indexed store created by the store for another incarnation of its tx,
+// instead of creating a new one, so that whatever it's wired into (eg. a cache multistore) can be reused as well. The
+// writeset of the previous incarnation must have been written to the store already, since it's cleared, and no
+// execution may still be using the version indexed store.
+func (s *Store) ReuseVersionIndexedStore(vis *VersionIndexedStore, incarnation int, abortChannel chan occ.Abort) *VersionIndexedStore {
+	mustValidateIncarnation(incarnation)
+	if s.prefilter != nil {
+		s.prefilter.start(vis.transactionIndex)
+	}
+	vis.reset(incarnation, abortChannel)
+	vis.estimateTotals = s.estimates
+	vis.accessLog = s.accessLog
+	vis.metadata = s.metadata
+	vis.readCache = s.readCache
+	return vis
+}
+
+// SetFlushListener sets a listener that is notified of every writeset flush, identifying the store by the given name
+func (s *Store) SetFlushListener(storeName string, listener FlushListener) {
+	s.storeName = storeName
+	s.flushListener = listener
+}
+
+func (s *Store) notifyFlush(index int, incarnation int, estimate bool, writeset WriteSet) {
+	if s.flushListener == nil {
+		return
+	}
+	s.flushListener.OnFlush(newFlushRecord(s.storeName, index, incarnation, estimate, writeset))
 }
 
 // GetLatest implements MultiVersionStore.
 func (s *Store) GetLatest(key []byte) (value MultiVersionValueItem) {
-	keyString := string(key)
+	keyString := s.keys.intern(key)
 	mvVal, found := s.multiVersionMap.Load(keyString)
 	// if the key doesn't exist in the overall map, return nil
 	if !found {
@@ -76,12 +297,12 @@ func (s *Store) GetLatest(key []byte) (value MultiVersionValueItem) {
 	if !found {
 		return nil // this is possible IF there is are writeset that are then removed for that key
 	}
-	return latestVal
+	return s.handOut(keyString, latestVal)
 }
 
 // GetLatestBeforeIndex implements MultiVersionStore.
 func (s *Store) GetLatestBeforeIndex(index int, key []byte) (value MultiVersionValueItem) {
-	keyString := string(key)
+	keyString := s.keys.intern(key)
 	mvVal, found := s.multiVersionMap.Load(keyString)
 	// if the key doesn't exist in the overall map, return nil
 	if !found {
@@ -93,13 +314,45 @@ func (s *Store) GetLatestBeforeIndex(index int, key []byte) (value MultiVersionV
 		return nil
 	}
 	// found a value prior to the passed in index, return that value (could be estimate OR deleted, but it is a definitive value)
-	return val
+	return s.handOut(keyString, val)
+}
+
+// WaitLatestBeforeIndex behaves like GetLatestBeforeIndex, except that if the latest value before index is an
+// estimate, it waits up to timeout for the estimate to be resolved, ie. replaced by the value the estimated tx writes or
+// removed, so that executors can wait briefly for short-lived estimates rather than abort. It returns the latest value
+// as of when it stopped waiting, which is still an estimate if it timed out.
+func (s *Store) WaitLatestBeforeIndex(index int, key []byte, timeout time.Duration) (value MultiVersionValueItem) {
+	mvVal, found := s.multiVersionMap.Load(s.keys.intern(key))
+	if !found {
+		return nil
+	}
+	item := mvVal.(MultiVersionValue)
+	var deadline <-chan time.Time
+	for {
+		val, found, generation := item.GetLatestBeforeIndexWithGeneration(index)
+		if !found {
+			return nil
+		}
+		if !val.IsEstimate() || timeout <= 0 {
+			return val
+		}
+		if deadline == nil {
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			deadline = timer.C
+		}
+		select {
+		case <-item.ChangedSince(generation):
+		case <-deadline:
+			return val
+		}
+	}
 }
 
 // Has implements MultiVersionStore. It checks if the key exists in the multiversion store at or before the specified index.
 func (s *Store) Has(index int, key []byte) bool {
 
-	keyString := string(key)
+	keyString := s.keys.intern(key)
 	mvVal, found := s.multiVersionMap.Load(keyString)
 	// if the key doesn't exist in the overall map, return nil
 	if !found {
@@ -109,12 +362,15 @@ func (s *Store) Has(index int, key []byte) bool {
 	return foundVal
 }
 
-func (s *Store) removeOldWriteset(index int, newWriteSet WriteSet) {
+// removeOldWriteset removes the writeset of the tx at index, except for the keys of the new writeset, and returns the
+// removed keys
+func (s *Store) removeOldWriteset(index int, newWriteSet WriteSet) []string {
 	writeset := make(map[string][]byte)
 	if newWriteSet != nil {
 		// if non-nil writeset passed in, we can use that to optimize removals
 		writeset = newWriteSet
 	}
+	var removed []string
 	// if there is already a writeset existing, we should remove that fully
 	oldKeys, loaded := s.txWritesetKeys.LoadAndDelete(index)
 	if loaded {
@@ -126,6 +382,8 @@ func (s *Store) removeOldWriteset(index int, newWriteSet WriteSet) {
 				// we don't need to remove this key because it will be overwritten anyways - saves the operation of removing + rebalancing underlying btree
 				continue
 			}
+			s.readIndex.markDirty(index, []string{key})
+			removed = append(removed, key)
 			// remove from the appropriate item if present in multiVersionMap
 			mvVal, found := s.multiVersionMap.Load(key)
 			// if the key doesn't exist in the overall map, return nil
@@ -135,20 +393,38 @@ func (s *Store) removeOldWriteset(index int, newWriteSet WriteSet) {
 			mvVal.(MultiVersionValue).Remove(index)
 		}
 	}
+	return removed
 }
 
 // SetWriteset sets a writeset for a transaction index, and also writes all of the multiversion items in the writeset to the multiversion store.
+// loadOrCreateItem returns the multiversion item of a key, initializing it from the pool if necessary
+func (s *Store) loadOrCreateItem(key string) MultiVersionValue {
+	if val, ok := s.multiVersionMap.Load(key); ok {
+		return val.(MultiVersionValue)
+	}
+	item := getMultiVersionItem()
+	val, loaded := s.multiVersionMap.LoadOrStore(key, item)
+	if loaded {
+		// lost the race to initialize the key
+		multiVersionItemPool.Put(item)
+	} else {
+		s.keyIndex.add(key)
+	}
+	return val.(MultiVersionValue)
+}
+
 // TODO: returns a list of NEW keys added
 func (s *Store) SetWriteset(index int, incarnation int, writeset WriteSet) {
+	mustValidateIncarnation(incarnation)
 	// TODO: add telemetry spans
 	// remove old writeset if it exists
-	s.removeOldWriteset(index, writeset)
+	removed := s.removeOldWriteset(index, writeset)
 
 	writeSetKeys := make([]string, 0, len(writeset))
 	for key, value := range writeset {
+		key = s.keys.internString(key)
 		writeSetKeys = append(writeSetKeys, key)
-		loadVal, _ := s.multiVersionMap.LoadOrStore(key, NewMultiVersionItem()) // init if necessary
-		mvVal := loadVal.(MultiVersionValue)
+		mvVal := s.loadOrCreateItem(key)
 		if value == nil {
 			// delete if nil value
 			// TODO: sync map
@@ -156,13 +432,21 @@ func (s *Store) SetWriteset(index int, incarnation int, writeset WriteSet) {
 		} else {
 			mvVal.Set(index, incarnation, value)
 		}
+		s.pruneVersions(mvVal)
 	}
-	sort.Strings(writeSetKeys) // TODO: if we're sorting here anyways, maybe we just put it into a btree instead of a slice
+	sort.Strings(writeSetKeys)
 	s.txWritesetKeys.Store(index, writeSetKeys)
+	s.memory.setWriteset(index, writesetBytes(writeset, false))
+	s.recordChecksums(index, incarnation, writeset)
+	s.estimates.resolveEstimates(index)
+	s.readIndex.markDirty(index, writeSetKeys)
+	s.logWrites(index, removed, writeSetKeys)
+	s.notifyFlush(index, incarnation, false, writeset)
 }
 
 // InvalidateWriteset iterates over the keys for the given index and incarnation writeset and replaces with ESTIMATEs
 func (s *Store) InvalidateWriteset(index int, incarnation int) {
+	mustValidateIncarnation(incarnation)
 	keysAny, found := s.txWritesetKeys.Load(index)
 	if !found {
 		return
@@ -170,55 +454,227 @@ func (s *Store) InvalidateWriteset(index int, incarnation int) {
 	keys := keysAny.([]string)
 	for _, key := range keys {
 		// invalidate all of the writeset items - is this suboptimal? - we could potentially do concurrently if slow because locking is on an item specific level
-		val, _ := s.multiVersionMap.LoadOrStore(key, NewMultiVersionItem())
-		val.(MultiVersionValue).SetEstimate(index, incarnation)
+		s.loadOrCreateItem(key).SetEstimate(index, incarnation)
+	}
+	if len(keys) > 0 {
+		s.estimates.setEstimates(index)
 	}
+	s.memory.setWriteset(index, keysBytes(keys))
+	s.readIndex.markDirty(index, keys)
+	s.logWrites(index, keys)
 	// we leave the writeset in place because we'll need it for key removal later if/when we replace with a new writeset
 }
 
 // SetEstimatedWriteset is used to directly write estimates instead of writing a writeset and later invalidating
 func (s *Store) SetEstimatedWriteset(index int, incarnation int, writeset WriteSet) {
+	mustValidateIncarnation(incarnation)
 	// remove old writeset if it exists
-	s.removeOldWriteset(index, writeset)
+	removed := s.removeOldWriteset(index, writeset)
 
 	writeSetKeys := make([]string, 0, len(writeset))
 	// still need to save the writeset so we can remove the elements later:
 	for key := range writeset {
+		key = s.keys.internString(key)
 		writeSetKeys = append(writeSetKeys, key)
 
-		mvVal, _ := s.multiVersionMap.LoadOrStore(key, NewMultiVersionItem()) // init if necessary
-		mvVal.(MultiVersionValue).SetEstimate(index, incarnation)
+		s.loadOrCreateItem(key).SetEstimate(index, incarnation)
 	}
 	sort.Strings(writeSetKeys)
 	s.txWritesetKeys.Store(index, writeSetKeys)
+	if len(writeSetKeys) > 0 {
+		s.estimates.setEstimates(index)
+	}
+	s.memory.setWriteset(index, writesetBytes(writeset, true))
+	s.readIndex.markDirty(index, writeSetKeys)
+	s.logWrites(index, removed, writeSetKeys)
+	s.notifyFlush(index, incarnation, true, writeset)
 }
 
-// GetAllWritesetKeys implements MultiVersionStore.
+// RemoveEstimatesForIndex removes the estimates left at index, eg. prefilled or left by an invalidated incarnation of a
+// tx that finished without writing its keys again, so that they don't abort later readers or reach the parent store.
+// Values written at index are kept, along with their keys in the writeset of the tx.
+func (s *Store) RemoveEstimatesForIndex(index int) {
+	keysAny, found := s.txWritesetKeys.Load(index)
+	if !found {
+		return
+	}
+	keys := keysAny.([]string)
+	kept := make([]string, 0, len(keys))
+	var removed []string
+	for _, key := range keys {
+		mvVal, found := s.multiVersionMap.Load(key)
+		if found && mvVal.(MultiVersionValue).RemoveEstimate(index) {
+			removed = append(removed, key)
+			continue
+		}
+		kept = append(kept, key)
+	}
+	if len(removed) == 0 {
+		return
+	}
+	if len(kept) == 0 {
+		s.txWritesetKeys.Delete(index)
+	} else {
+		s.txWritesetKeys.Store(index, kept)
+	}
+	s.estimates.resolveEstimates(index)
+	// the removed estimates only counted their keys
+	s.memory.shrinkWriteset(index, keysBytes(removed))
+	s.readIndex.markDirty(index, removed)
+	s.logWrites(index, removed)
+}
+
+// GetAllWritesetKeys implements MultiVersionStore. It returns copies of the writeset keys of every tx, see
+// RangeWritesetKeys.
 func (s *Store) GetAllWritesetKeys() map[int][]string {
 	writesetKeys := make(map[int][]string)
-	// TODO: is this safe?
-	s.txWritesetKeys.Range(func(key, value interface{}) bool {
-		index := key.(int)
-		keys := value.([]string)
+	s.RangeWritesetKeys(func(index int, keys []string) bool {
 		writesetKeys[index] = keys
 		return true
 	})
-
 	return writesetKeys
 }
 
+// GetWritesetKeys returns a copy of the sorted writeset keys for the given tx index, or nil if there is no writeset
+func (s *Store) GetWritesetKeys(index int) []string {
+	keys := s.writesetKeys(index)
+	if keys == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(keys)), keys...)
+}
+
+// WritesetKeysInRange returns a copy of the sorted writeset keys in [start, end) for the given tx index, or nil if
+// there are none. Nil bounds are unbounded.
+func (s *Store) WritesetKeysInRange(index int, start, end []byte) []string {
+	keys := keysInRange(s.writesetKeys(index), start, end)
+	if len(keys) == 0 {
+		return nil
+	}
+	return append(make([]string, 0, len(keys)), keys...)
+}
+
+// keysInRange returns the sub-slice of sorted keys in [start, end), with nil bounds unbounded
+func keysInRange(keys []string, start, end []byte) []string {
+	from, to := 0, len(keys)
+	if start != nil {
+		from = sort.SearchStrings(keys, string(start))
+	}
+	if end != nil {
+		to = sort.SearchStrings(keys, string(end))
+	}
+	if to <= from {
+		return nil
+	}
+	return keys[from:to]
+}
+
+// writesetKeys returns the sorted writeset keys for the given tx index without copying them, or nil if there is no
+// writeset. They are replaced rather than modified when the writeset changes, but must not be modified by callers.
+func (s *Store) writesetKeys(index int) []string {
+	keysAny, found := s.txWritesetKeys.Load(index)
+	if !found {
+		return nil
+	}
+	return keysAny.([]string)
+}
+
+// GetDependentReaders returns the sorted indices of transactions after the given index whose readset or iterateset
+// touched any of the given keys. These transactions may have observed stale values for the keys.
+func (s *Store) GetDependentReaders(index int, keys []string) []int {
+	if len(keys) == 0 {
+		return nil
+	}
+	readers := make(map[int]struct{})
+	for _, k := range keys {
+		s.readIndex.readersAfter(index, k, readers)
+	}
+	s.txIterateSets.Range(func(key, value interface{}) bool {
+		readerIndex := key.(int)
+		if readerIndex <= index {
+			return true
+		}
+		for _, tracker := range value.(Iterateset) {
+			if tracker.containsAny(keys) {
+				readers[readerIndex] = struct{}{}
+				break
+			}
+		}
+		return true
+	})
+
+	readerIndices := make([]int, 0, len(readers))
+	for readerIndex := range readers {
+		readerIndices = append(readerIndices, readerIndex)
+	}
+	sort.Ints(readerIndices)
+	return readerIndices
+}
+
 func (s *Store) SetReadset(index int, readset ReadSet) {
+	s.releaseReadset(index)
+	s.memory.setReadset(index, readsetBytes(readset))
+	s.txReadGenerations.Delete(index)
+	s.txExistenceSets.Delete(index)
+	if s.prefilter != nil {
+		s.prefilter.setReadset(index, readset)
+	}
+	if s.readKeys != nil {
+		hashed, keys := s.readKeys.hashReadset(readset)
+		s.readIndex.setKeys(index, keys)
+		s.txReadSets.Store(index, hashed)
+		return
+	}
+	readset, digested := s.digestReadset(readset)
+	if digested != nil {
+		s.txDigestedReads.Store(index, digested)
+	} else {
+		s.txDigestedReads.Delete(index)
+	}
+	s.readIndex.set(index, readset)
+	if s.readsetSpill != nil && !s.readsetSpill.reserve(index, readset) {
+		s.readsetSpill.spill(index, readset)
+		s.txReadSets.Store(index, spilledReadset{})
+		return
+	}
 	s.txReadSets.Store(index, readset)
 }
 
+// GetReadset returns a deep copy of the readset of the tx at index, or nil if there is none, so that it can be
+// inspected while txs are being processed
 func (s *Store) GetReadset(index int) ReadSet {
 	readsetAny, found := s.txReadSets.Load(index)
 	if !found {
 		return nil
 	}
+	return copyReadset(s.resolveReadset(index, readsetAny))
+}
+
+// resolveReadset returns the readset for a txReadSets value, loading it from the spill database or converting it from
+// its hashed form if necessary
+func (s *Store) resolveReadset(index int, readsetAny interface{}) ReadSet {
+	if _, ok := readsetAny.(spilledReadset); ok {
+		return s.readsetSpill.load(index)
+	}
+	if hashed, ok := readsetAny.(*hashedReadset); ok {
+		return s.readKeys.readset(hashed)
+	}
 	return readsetAny.(ReadSet)
 }
 
+// releaseReadset frees any spill budget or spilled data held by the readset at the index
+func (s *Store) releaseReadset(index int) {
+	if s.readsetSpill == nil {
+		return
+	}
+	readsetAny, found := s.txReadSets.Load(index)
+	if !found {
+		return
+	}
+	_, spilled := readsetAny.(spilledReadset)
+	s.readsetSpill.release(index, spilled)
+}
+
 func (s *Store) SetIterateset(index int, iterateset Iterateset) {
 	s.txIterateSets.Store(index, iterateset)
 }
@@ -232,7 +688,16 @@ func (s *Store) GetIterateset(index int) Iterateset {
 }
 
 func (s *Store) ClearReadset(index int) {
+	s.releaseReadset(index)
+	s.memory.setReadset(index, 0)
+	s.txReadGenerations.Delete(index)
+	s.txExistenceSets.Delete(index)
+	if s.prefilter != nil {
+		s.prefilter.clear(index)
+	}
+	s.readIndex.remove(index)
 	s.txReadSets.Delete(index)
+	s.txDigestedReads.Delete(index)
 }
 
 func (s *Store) ClearIterateset(index int) {
@@ -241,27 +706,26 @@ func (s *Store) ClearIterateset(index int) {
 
 // CollectIteratorItems implements MultiVersionStore. It will return a memDB containing all of the keys present in the multiversion store within the iteration range prior to (exclusive of) the index.
 func (s *Store) CollectIteratorItems(index int) *db.MemDB {
-	sortedItems := db.NewMemDB()
+	return s.CollectIteratorItemsInRange(index, nil, nil)
+}
 
-	// get all writeset keys prior to index
-	for i := 0; i < index; i++ {
-		writesetAny, found := s.txWritesetKeys.Load(i)
-		if !found {
-			continue
-		}
-		indexedWriteset := writesetAny.([]string)
-		// TODO: do we want to exclude keys out of the range or just let the iterator handle it?
-		for _, key := range indexedWriteset {
-			// TODO: inefficient because (logn) for each key + rebalancing? maybe theres a better way to add to a tree to reduce rebalancing overhead
+// CollectIteratorItemsInRange implements MultiVersionStore. It returns a memDB containing the keys in [start, end) with
+// a version prior to (exclusive of) the index, found with a range scan of the sorted index of the keys of the block.
+// Nil bounds are unbounded.
+func (s *Store) CollectIteratorItemsInRange(index int, start, end []byte) *db.MemDB {
+	sortedItems := db.NewMemDB()
+	s.keyIndex.ascendRange(start, end, func(key string) bool {
+		if s.GetLatestBeforeIndex(index, []byte(key)) != nil {
 			sortedItems.Set([]byte(key), []byte{})
 		}
-	}
+		return true
+	})
 	return sortedItems
 }
 
 func (s *Store) validateIterator(index int, tracker iterationTracker) bool {
-	// collect items from multiversion store
-	sortedItems := s.CollectIteratorItems(index)
+	// collect items in the range of the iterator from multiversion store
+	sortedItems := s.CollectIteratorItemsInRange(index, tracker.startKey, tracker.endKey)
 	// add the iterationtracker writeset keys to the sorted items
 	for key := range tracker.writeset {
 		sortedItems.Set([]byte(key), []byte{})
@@ -332,71 +796,130 @@ func (s *Store) checkIteratorAtIndex(index int) bool {
 	return valid
 }
 
-func (s *Store) checkReadsetAtIndex(index int) (bool, []int) {
-	conflictSet := make(map[int]struct{})
+// checkReadsetAtIndex validates the readset of the tx at index against the latest values before the tx, adding the
+// writers it conflicts with to conflicts
+func (s *Store) checkReadsetAtIndex(index int, conflicts *conflictBuffer) bool {
 	valid := true
 
 	readSetAny, found := s.txReadSets.Load(index)
 	if !found {
-		return true, []int{}
+		return true
+	}
+	prefiltered, validated := s.prefilterReadset(index)
+	if prefiltered {
+		return true
+	}
+
+	start := time.Now()
+	var parentElapsed time.Duration
+	defer func() {
+		s.recordValidationPhase(validationPhaseParent, parentElapsed)
+		s.recordValidationPhase(validationPhaseReadset, time.Since(start)-parentElapsed)
+	}()
+	generations := s.readGenerations(index)
+	var readset ReadSet
+	if hashed, ok := readSetAny.(*hashedReadset); ok {
+		for _, read := range hashed.reads {
+			if s.readUnchanged(generations, s.readKeys.key(read.key), read.multiple) {
+				continue
+			}
+			recorded := read.value
+			matches := func(current []byte) bool { return s.readKeys.valueMatches(recorded, current) }
+			readValid := s.checkRead(index, s.readKeys.key(read.key), read.multiple, recorded == 0, matches, conflicts, &parentElapsed)
+			valid = valid && readValid
+		}
+		readset = hashed.collided
+	} else {
+		readset = s.resolveReadset(index, readSetAny)
 	}
-	readset := readSetAny.(ReadSet)
+	digested := s.digestedReads(index)
 	// iterate over readset and check if the value is the same as the latest value relateive to txIndex in the multiversion store
 	for key, valueArr := range readset {
-		if len(valueArr) != 1 {
-			valid = false
+		if len(valueArr) == 0 || s.readUnchanged(generations, key, len(valueArr) > 1) {
 			continue
 		}
-		value := valueArr[0]
-		// get the latest value from the multiversion store
-		latestValue := s.GetLatestBeforeIndex(index, []byte(key))
-		if latestValue == nil {
-			// this is possible if we previously read a value from a transaction write that was later reverted, so this time we read from parent store
-			parentVal := s.parentStore.Get([]byte(key))
-			if !bytes.Equal(parentVal, value) {
-				valid = false
-			}
-		} else {
-			// if estimate, mark as conflict index - but don't invalidate
-			if latestValue.IsEstimate() {
-				conflictSet[latestValue.Index()] = struct{}{}
-			} else if latestValue.IsDeleted() {
-				if value != nil {
-					// conflict
-					// TODO: would we want to return early?
-					conflictSet[latestValue.Index()] = struct{}{}
-					valid = false
-				}
-			} else if !bytes.Equal(latestValue.Value(), value) {
-				conflictSet[latestValue.Index()] = struct{}{}
-				valid = false
-			}
-		}
+		value, isDigest := valueArr[0], digested.isDigest(key, 0)
+		matches := func(current []byte) bool { return readMatches(value, isDigest, current) }
+		readValid := s.checkRead(index, key, len(valueArr) > 1, value == nil, matches, conflicts, &parentElapsed)
+		valid = valid && readValid
 	}
 
-	conflictIndices := make([]int, 0, len(conflictSet))
-	for index := range conflictSet {
-		conflictIndices = append(conflictIndices, index)
+	// the reads only need to be checked against later writeset changes from now on, unless they conflict with an
+	// estimate, which must be checked again
+	if valid && conflicts.empty() {
+		validated()
 	}
+	return valid
+}
 
-	sort.Ints(conflictIndices)
-
-	return valid, conflictIndices
+// checkRead validates a read of key by the tx at index against the latest value before the tx, adding the writers it
+// conflicts with to conflicts. The read is described by whether the tx observed multiple values, whether the value
+// it observed was nil, and whether that value matches a current one, so that it applies to any form of readset.
+func (s *Store) checkRead(index int, key string, multiple bool, recordedNil bool, matches func(current []byte) bool, conflicts *conflictBuffer, parentElapsed *time.Duration) bool {
+	// get the latest value from the multiversion store
+	latestValue := s.GetLatestBeforeIndex(index, []byte(key))
+	if multiple {
+		// the tx observed inconsistent values, so it's invalid regardless, but the latest writer is still a
+		// conflict so that the re-execution can wait for it
+		writer := -1
+		if latestValue != nil {
+			writer = latestValue.Index()
+			conflicts.add(writer)
+		}
+		s.notifyInvalidation(index, key, writer)
+		return false
+	}
+	if latestValue == nil {
+		// this is possible if we previously read a value from a transaction write that was later reverted, so this time we read from parent store
+		parentStart := time.Now()
+		parentVal := s.parentStore.Get([]byte(key))
+		*parentElapsed += time.Since(parentStart)
+		if !matches(parentVal) {
+			// reads of keys the block never versioned can only have come from the parent store
+			if _, versioned := s.multiVersionMap.Load(key); !versioned {
+				s.recordParentMutation(index, key)
+			}
+			s.notifyInvalidation(index, key, -1)
+			return false
+		}
+		return true
+	}
+	// an estimate is a conflict, but doesn't invalidate the read
+	valid, conflict := CheckVersion(latestValue, recordedNil, matches)
+	if conflict {
+		conflicts.add(latestValue.Index())
+	}
+	if !valid {
+		s.notifyInvalidation(index, key, latestValue.Index())
+	}
+	return valid
 }
 
-// TODO: do we want to return bool + []int where bool indicates whether it was valid and then []int indicates only ones for which we need to wait due to estimates? - yes i think so?
+// ValidateTransactionState implements MultiVersionStore. It validates the iterators, readset and existence set of the tx
+// at index, returning whether they're still valid and the sorted writers they conflict with, whose estimates the tx must
+// wait for. Versions are read without locking, and conflicts are collected in a pooled buffer, so that validating a
+// readset or an existence set that doesn't conflict or fall through to the parent store doesn't allocate once telemetry
+// is batched, see WithBatchedTelemetry.
 func (s *Store) ValidateTransactionState(index int) (bool, []int) {
 	// defer telemetry.MeasureSince(time.Now(), "store", "mvs", "validate")
+	atomic.AddInt64(&s.validationCost.validations, 1)
 
 	// TODO: can we parallelize for all iterators?
+	iteratorStart := time.Now()
 	iteratorValid := s.checkIteratorAtIndex(index)
+	s.recordValidationPhase(validationPhaseIterateset, time.Since(iteratorStart))
 
-	readsetValid, conflictIndices := s.checkReadsetAtIndex(index)
+	conflicts := getConflictBuffer()
+	defer putConflictBuffer(conflicts)
+	readsetValid := s.checkReadsetAtIndex(index, conflicts)
+	existenceValid := s.checkExistenceAtIndex(index, conflicts)
 
-	return iteratorValid && readsetValid, conflictIndices
+	return iteratorValid && readsetValid && existenceValid, conflicts.indices()
 }
 
-func (s *Store) WriteLatestToStore() {
+// forEachLatest calls fn with every key and its latest non-estimate value in key order, which is the final state of
+// the block's writes
+func (s *Store) forEachLatest(fn func(key string, value MultiVersionValueItem)) {
 	// sort the keys
 	keys := []string{}
 	s.multiVersionMap.Range(func(key, value interface{}) bool {
@@ -419,6 +942,41 @@ func (s *Store) WriteLatestToStore() {
 		if mvValue.IsEstimate() {
 			panic("should not have any estimate values when writing to parent store")
 		}
+		fn(key, mvValue)
+	}
+}
+
+// LatestEstimate returns the smallest key whose latest version is an estimate, along with the index of the tx that
+// estimated it. Once every tx of a block is validated, there shouldn't be any: WriteLatestToStore skips estimates, so
+// the writes a tx was expected to make to such a key would silently be missing from the parent store.
+func (s *Store) LatestEstimate() (key []byte, index int, found bool) {
+	s.multiVersionMap.Range(func(k, value interface{}) bool {
+		latest, ok := value.(MultiVersionValue).GetLatest()
+		if !ok || !latest.IsEstimate() {
+			return true
+		}
+		if !found || k.(string) < string(key) {
+			key, index, found = []byte(k.(string)), latest.Index(), true
+		}
+		return true
+	})
+	return key, index, found
+}
+
+// WriteLatestToStore writes the final state of the block's writes to the parent store in key order. If the parent
+// store is a BatchKVStore, the writes are applied with a single batch.
+func (s *Store) WriteLatestToStore() {
+	s.writeToParent(s.forEachLatest)
+}
+
+// writeToParent writes the versions visited by forEach to the parent store, as a single batch if the parent store is
+// a BatchKVStore
+func (s *Store) writeToParent(forEach func(fn func(key string, value MultiVersionValueItem))) {
+	if parent, ok := s.parentStore.(types.BatchKVStore); ok {
+		s.writeToBatch(parent, forEach)
+		return
+	}
+	forEach(func(key string, mvValue MultiVersionValueItem) {
 		// if the value is deleted, then delete it from the parent store
 		if mvValue.IsDeleted() {
 			// We use []byte(key) instead of conv.UnsafeStrToBytes because we cannot
@@ -426,10 +984,99 @@ func (s *Store) WriteLatestToStore() {
 			// not. Once we get confirmation that .Delete is guaranteed not to
 			// save the byteslice, then we can assume only a read-only copy is sufficient.
 			s.parentStore.Delete([]byte(key))
-			continue
+			return
 		}
 		if mvValue.Value() != nil {
 			s.parentStore.Set([]byte(key), mvValue.Value())
 		}
+	})
+}
+
+// writeToBatch writes the versions visited by forEach to a batch of the parent store, and writes the batch.
+func (s *Store) writeToBatch(parent types.BatchKVStore, forEach func(fn func(key string, value MultiVersionValueItem))) {
+	batch := parent.NewBatch()
+	defer batch.Close()
+	forEach(func(key string, mvValue MultiVersionValueItem) {
+		var err error
+		switch {
+		case mvValue.IsDeleted():
+			err = batch.Delete([]byte(key))
+		case mvValue.Value() != nil:
+			types.AssertValidKey([]byte(key))
+			err = batch.Set([]byte(key), mvValue.Value())
+		}
+		if err != nil {
+			panic(err)
+		}
+	})
+	if err := batch.Write(); err != nil {
+		panic(err)
+	}
+}
+
+// LatestWritesetHash returns a deterministic hash of the writes WriteLatestToStore would apply to the parent store:
+// every key in order, with whether it's deleted and its value. Nodes that executed the same block to the same final
+// writes get the same hash, regardless of how the writes were spread over txs and incarnations.
+func (s *Store) LatestWritesetHash() []byte {
+	h := sha256.New()
+	var lenBuf [binary.MaxVarintLen64]byte
+	writeBytes := func(bz []byte) {
+		n := binary.PutUvarint(lenBuf[:], uint64(len(bz)))
+		h.Write(lenBuf[:n])
+		h.Write(bz)
+	}
+	s.forEachLatest(func(key string, mvValue MultiVersionValueItem) {
+		switch {
+		case mvValue.IsDeleted():
+			writeBytes([]byte(key))
+			h.Write([]byte{0})
+		case mvValue.Value() != nil:
+			writeBytes([]byte(key))
+			h.Write([]byte{1})
+			writeBytes(mvValue.Value())
+		}
+	})
+	return h.Sum(nil)
+}
+
+// WriteLatestToStoreWithListeners behaves like WriteLatestToStore, and additionally streams the final writeset of every
+// tx to the listeners in tx index order (with keys in lexical order within a tx), which is the order state listening
+// sees writes in when txs are executed sequentially. The parent store shouldn't be listening itself, or writes would
+// be streamed twice.
+func (s *Store) WriteLatestToStoreWithListeners(storeKey types.StoreKey, listeners []types.WriteListener) error {
+	if s.PrunedVersions() > 0 {
+		panic("can't stream writesets once superseded versions were pruned")
 	}
+	if s.CommittedIndex() > 0 {
+		panic("can't stream writesets once writes were committed to the parent store")
+	}
+	for _, index := range sortedIndices(s.txWritesetKeys) {
+		// writeset keys are stored sorted
+		for _, key := range s.writesetKeys(index) {
+			val, ok := s.multiVersionMap.Load(key)
+			if !ok {
+				continue
+			}
+			// the latest value before the next index is the one written by this tx
+			mvValue, found := val.(MultiVersionValue).GetLatestBeforeIndex(index + 1)
+			if !found || mvValue.Index() != index {
+				continue
+			}
+			if mvValue.IsEstimate() {
+				panic("should not have any estimate values when writing to parent store")
+			}
+			value := mvValue.Value()
+			if mvValue.IsDeleted() {
+				value = nil
+			}
+			for _, listener := range listeners {
+				if err := listener.OnWrite(storeKey, []byte(key), value, mvValue.IsDeleted()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	s.WriteLatestToStore()
+	return nil
 }
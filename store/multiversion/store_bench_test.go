@@ -0,0 +1,227 @@
+package multiversion_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+// The multiversion store is backed by concurrent maps with per-key locking, so operations on disjoint keys shouldn't
+// contend with each other, and reads of a key don't lock it, so they don't contend with its writes either. Run these
+// with increasing -cpu values (eg. -cpu 1,4,16) to observe the scaling.
+
+const benchKeysPerTx = 10
+
+func benchWriteset(index int) multiversion.WriteSet {
+	writeset := make(multiversion.WriteSet, benchKeysPerTx)
+	for i := 0; i < benchKeysPerTx; i++ {
+		writeset[fmt.Sprintf("tx-%d-key-%d", index, i)] = []byte("value")
+	}
+	return writeset
+}
+
+func BenchmarkMultiVersionStoreSetWritesetDisjoint(b *testing.B) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	var next int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			index := int(atomic.AddInt64(&next, 1))
+			mvs.SetWriteset(index, 0, benchWriteset(index))
+		}
+	})
+}
+
+func BenchmarkMultiVersionStoreGetLatestBeforeIndexDisjoint(b *testing.B) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	const txs = 1000
+	for index := 0; index < txs; index++ {
+		mvs.SetWriteset(index, 0, benchWriteset(index))
+	}
+	var next int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			index := int(atomic.AddInt64(&next, 1)) % txs
+			mvs.GetLatestBeforeIndex(txs, []byte(fmt.Sprintf("tx-%d-key-%d", index, index%benchKeysPerTx)))
+		}
+	})
+}
+
+func BenchmarkMultiVersionStoreSameKey(b *testing.B) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	const txs = 100
+	key := []byte("key")
+	for index := 0; index < txs; index++ {
+		mvs.SetWriteset(index, 0, multiversion.WriteSet{string(key): []byte("value")})
+	}
+	var next int64
+
+	// one in ten operations rewrites the key, the others read it
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			index := int(atomic.AddInt64(&next, 1))
+			if index%10 == 0 {
+				mvs.SetWriteset(index%txs, index, multiversion.WriteSet{string(key): []byte("value")})
+				continue
+			}
+			mvs.GetLatestBeforeIndex(index%txs, key)
+		}
+	})
+}
+
+func BenchmarkMultiVersionStoreValidateTransactionStateDisjoint(b *testing.B) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	const txs = 1000
+	for index := 0; index < txs; index++ {
+		mvs.SetWriteset(index, 0, benchWriteset(index))
+		// every tx reads the keys written by the previous tx
+		readset := make(multiversion.ReadSet, benchKeysPerTx)
+		for key, value := range benchWriteset(index - 1) {
+			readset[key] = [][]byte{value}
+		}
+		mvs.SetReadset(index, readset)
+	}
+	var next int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			index := 1 + int(atomic.AddInt64(&next, 1))%(txs-1)
+			if valid, _ := mvs.ValidateTransactionState(index); !valid {
+				b.Fatal("expected valid transaction state")
+			}
+		}
+	})
+}
+
+// Validations that don't conflict or fall through to the parent store shouldn't allocate once telemetry is batched,
+// which -benchmem reports as 0 allocs/op
+func BenchmarkMultiVersionStoreValidateTransactionStateAllocs(b *testing.B) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()}, multiversion.WithBatchedTelemetry())
+	mvs.SetWriteset(0, 0, benchWriteset(0))
+	readset := make(multiversion.ReadSet, benchKeysPerTx)
+	existenceset := make(multiversion.ExistenceSet, benchKeysPerTx)
+	for key, value := range benchWriteset(0) {
+		readset[key] = [][]byte{value}
+	}
+	for key := range benchWriteset(0) {
+		existenceset[key] = true
+	}
+	mvs.SetReadset(1, readset)
+	mvs.SetExistenceSet(1, existenceset)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if valid, _ := mvs.ValidateTransactionState(1); !valid {
+			b.Fatal("expected valid transaction state")
+		}
+	}
+}
+
+const benchBlockTxs = 100
+
+// benchBlock writes, reads and flushes a block worth of writesets to the store
+func benchBlock(mvs *multiversion.Store) {
+	for index := 0; index < benchBlockTxs; index++ {
+		mvs.SetWriteset(index, 0, benchWriteset(index))
+	}
+	for index := 0; index < benchBlockTxs; index++ {
+		mvs.GetLatestBeforeIndex(index, []byte(fmt.Sprintf("tx-%d-key-0", index)))
+	}
+	mvs.WriteLatestToStore()
+}
+
+// Compare the allocations of a new store per block against resetting and reusing the same store across blocks
+func BenchmarkMultiVersionStoreBlockNewStore(b *testing.B) {
+	parent := dbadapter.Store{DB: dbm.NewMemDB()}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		benchBlock(multiversion.NewMultiVersionStore(parent))
+	}
+}
+
+func BenchmarkMultiVersionStoreBlockResetStore(b *testing.B) {
+	parent := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parent)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mvs.Reset(parent)
+		benchBlock(mvs)
+	}
+}
+
+// Compare validating a readset read by read against prefiltering it with digests, both while nothing changes and
+// while a writeset of an earlier tx disjoint from the readset changes before every validation
+func BenchmarkMultiVersionStoreValidateReadsetPrefilter(b *testing.B) {
+	const txs = 1000
+	const reader = txs - 1
+	options := map[string][]multiversion.StoreOption{
+		"read by read": nil,
+		"prefiltered":  {multiversion.WithReadsetPrefilter()},
+	}
+	for name, opts := range options {
+		for _, disjointWrites := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%s/disjoint writes %t", name, disjointWrites), func(b *testing.B) {
+				mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()}, opts...)
+				for index := 0; index < txs; index++ {
+					mvs.SetWriteset(index, 0, benchWriteset(index))
+				}
+				// the last tx reads the keys written by the previous tx
+				vis := mvs.VersionedIndexedStore(reader, 0, nil)
+				for key := range benchWriteset(reader - 1) {
+					vis.Get([]byte(key))
+				}
+				vis.WriteReadsetToMultiVersionStore()
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if disjointWrites {
+						b.StopTimer()
+						writer := i % (reader - 1)
+						mvs.SetWriteset(writer, 1, benchWriteset(writer))
+						b.StartTimer()
+					}
+					if valid, _ := mvs.ValidateTransactionState(reader); !valid {
+						b.Fatal("expected valid transaction state")
+					}
+				}
+				b.ReportMetric(float64(mvs.ValidationCost().PrefilteredReadsets)/float64(b.N), "prefiltered/op")
+			})
+		}
+	}
+}
+
+func BenchmarkCollectIteratorItemsInRange(b *testing.B) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	const txs = 1000
+	for index := 0; index < txs; index++ {
+		mvs.SetWriteset(index, 0, benchWriteset(index))
+	}
+	// a narrow range, like the prefix iteration of a single account's keys
+	start, end := []byte("tx-500-"), []byte("tx-500.")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mvs.CollectIteratorItemsInRange(txs, start, end)
+	}
+}
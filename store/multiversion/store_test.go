@@ -3,9 +3,11 @@ package multiversion_test
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/cosmos/cosmos-sdk/store/dbadapter"
 	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/store/types"
 	"github.com/cosmos/cosmos-sdk/types/occ"
 	"github.com/stretchr/testify/require"
 	dbm "github.com/tendermint/tm-db"
@@ -111,6 +113,64 @@ func TestMultiVersionStoreWriteToParent(t *testing.T) {
 	require.False(t, parentKVStore.Has([]byte("key5")))
 }
 
+// unbatchedStore hides the batches of its DB, so that writes to it are done one key at a time
+type unbatchedStore struct {
+	types.KVStore
+}
+
+// countingBatchStore counts the batches written to its DB
+type countingBatchStore struct {
+	dbadapter.Store
+	batches int
+}
+
+func (s *countingBatchStore) NewBatch() dbm.Batch {
+	s.batches++
+	return s.Store.NewBatch()
+}
+
+func TestMultiVersionStoreWriteLatestToBatch(t *testing.T) {
+	batched := &countingBatchStore{Store: dbadapter.Store{DB: dbm.NewMemDB()}}
+	unbatched := dbadapter.Store{DB: dbm.NewMemDB()}
+	for _, parent := range []types.KVStore{batched, unbatched} {
+		parent.Set([]byte("key2"), []byte("value0"))
+		parent.Set([]byte("key4"), []byte("value4"))
+	}
+
+	write := func(parent types.KVStore) {
+		mvs := multiversion.NewMultiVersionStore(parent)
+		mvs.SetWriteset(1, 1, map[string][]byte{
+			"key1": []byte("value1"),
+			"key3": nil,
+			"key4": nil,
+		})
+		mvs.SetWriteset(2, 1, map[string][]byte{
+			"key1": []byte("value2"),
+			"key5": []byte("value5"),
+		})
+		mvs.SetWriteset(3, 1, map[string][]byte{
+			"key2": []byte("value3"),
+		})
+		mvs.WriteLatestToStore()
+	}
+	write(batched)
+	write(unbatchedStore{unbatched})
+	require.Equal(t, 1, batched.batches)
+
+	expected, actual := unbatched.Iterator(nil, nil), batched.Iterator(nil, nil)
+	defer expected.Close()
+	defer actual.Close()
+	for ; expected.Valid(); expected.Next() {
+		require.True(t, actual.Valid())
+		require.Equal(t, expected.Key(), actual.Key())
+		require.Equal(t, expected.Value(), actual.Value())
+		actual.Next()
+	}
+	require.False(t, actual.Valid())
+	require.Equal(t, []byte("value2"), batched.Get([]byte("key1")))
+	require.False(t, batched.Has([]byte("key4")))
+}
+
 func TestMultiVersionStoreWritesetSetAndInvalidate(t *testing.T) {
 	mvs := multiversion.NewMultiVersionStore(nil)
 
@@ -823,3 +883,386 @@ func TestMVSIteratorValidationEarlyStopIncludedInIterateset(t *testing.T) {
 	require.True(t, valid)
 	require.Empty(t, conflicts)
 }
+
+func TestMVSGetDependentReaders(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+
+	mvs.SetWriteset(1, 0, map[string][]byte{
+		"key2": []byte("value2"),
+		"key1": []byte("value1"),
+	})
+	require.Equal(t, []string{"key1", "key2"}, mvs.GetWritesetKeys(1))
+	require.Nil(t, mvs.GetWritesetKeys(2))
+
+	mvs.SetReadset(0, map[string][][]byte{"key3": {nil}})
+	mvs.SetReadset(2, map[string][][]byte{"key1": {[]byte("value1")}})
+	mvs.SetReadset(3, map[string][][]byte{"key3": {nil}})
+	mvs.SetReadset(4, map[string][][]byte{"key4": {nil}})
+
+	// iterator over [key5, key7)
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 5, 0, make(chan occ.Abort, 1))
+	iter := vis.Iterator([]byte("key5"), []byte("key7"))
+	for ; iter.Valid(); iter.Next() {
+	}
+	iter.Close()
+	vis.WriteToMultiVersionStore()
+
+	// readers before or at the index are excluded
+	require.Equal(t, []int{3}, mvs.GetDependentReaders(1, []string{"key3"}))
+	require.Equal(t, []int{2, 3}, mvs.GetDependentReaders(1, []string{"key1", "key3"}))
+	// keys within an iterated range make the iterating tx a reader
+	require.Equal(t, []int{5}, mvs.GetDependentReaders(1, []string{"key6"}))
+	require.Empty(t, mvs.GetDependentReaders(1, []string{"key7"}))
+	require.Empty(t, mvs.GetDependentReaders(1, nil))
+}
+
+func TestMVSGetAffectedReaders(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+
+	mvs.SetWriteset(1, 0, map[string][]byte{"key1": []byte("value1"), "key2": []byte("value2")})
+	require.Equal(t, map[string]int{"key1": 1, "key2": 1}, mvs.TakeDirtyKeys())
+	require.Empty(t, mvs.TakeDirtyKeys())
+
+	mvs.SetReadset(0, map[string][][]byte{"key1": {nil}})
+	mvs.SetReadset(2, map[string][][]byte{"key1": {[]byte("value1")}})
+	mvs.SetReadset(3, map[string][][]byte{"key2": {[]byte("value2")}})
+	mvs.SetReadset(4, map[string][][]byte{"key4": {nil}})
+
+	// iterator over [key5, key7)
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 5, 0, make(chan occ.Abort, 1))
+	iter := vis.Iterator([]byte("key5"), []byte("key7"))
+	for ; iter.Valid(); iter.Next() {
+	}
+	iter.Close()
+	vis.WriteToMultiVersionStore()
+	mvs.TakeDirtyKeys()
+
+	// rewriting the writeset dirties both the removed and the written keys
+	mvs.SetWriteset(1, 1, map[string][]byte{"key1": []byte("other"), "key6": []byte("value6")})
+	dirty := mvs.TakeDirtyKeys()
+	require.Equal(t, map[string]int{"key1": 1, "key2": 1, "key6": 1}, dirty)
+	// readers before the dirtying tx are excluded, and keys within an iterated range affect the iterating tx
+	require.Equal(t, []int{2, 3, 5}, mvs.GetAffectedReaders(dirty))
+
+	// invalidation dirties the writeset keys, and cleared readsets are no longer indexed
+	mvs.ClearReadset(2)
+	mvs.InvalidateWriteset(1, 1)
+	dirty = mvs.TakeDirtyKeys()
+	require.Equal(t, map[string]int{"key1": 1, "key6": 1}, dirty)
+	require.Equal(t, []int{5}, mvs.GetAffectedReaders(dirty))
+	require.Empty(t, mvs.GetAffectedReaders(map[string]int{"key1": 2}))
+	require.Empty(t, mvs.GetAffectedReaders(nil))
+}
+
+func TestMultiVersionStoreInvalidIncarnation(t *testing.T) {
+	store := multiversion.NewMultiVersionStore(nil)
+
+	require.NotPanics(t, func() {
+		store.SetEstimatedWriteset(1, occ.PrefillIncarnation, map[string][]byte{"key1": nil})
+	})
+	require.Panics(t, func() {
+		store.SetWriteset(1, -2, map[string][]byte{"key1": []byte("value1")})
+	})
+	require.Panics(t, func() {
+		store.InvalidateWriteset(1, occ.MaxIncarnation+1)
+	})
+	require.Panics(t, func() {
+		store.VersionedIndexedStore(1, -2, make(chan occ.Abort, 1))
+	})
+}
+
+func TestMVSIteratorValidationWithKeyDeleted(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	vis := multiversion.NewVersionIndexedStore(parentKVStore, mvs, 5, 1, make(chan occ.Abort, 1))
+
+	parentKVStore.Set([]byte("key2"), []byte("value2"))
+	parentKVStore.Set([]byte("key3"), []byte("value3"))
+	parentKVStore.Set([]byte("key4"), []byte("value4"))
+
+	iter := vis.Iterator([]byte("key1"), []byte("key5"))
+	for ; iter.Valid(); iter.Next() {
+		// read value
+		iter.Value()
+	}
+	iter.Close()
+	vis.WriteToMultiVersionStore()
+
+	// should be valid before any earlier tx touches the range
+	valid, conflicts := mvs.ValidateTransactionState(5)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+
+	// deletion of key3 by an earlier tx removes an observed key from the range
+	mvs.SetWriteset(2, 2, multiversion.WriteSet{"key3": nil})
+
+	// should be invalid, and since key3 was also read by the iterator the deleting tx is reported as a conflict
+	valid, conflicts = mvs.ValidateTransactionState(5)
+	require.False(t, valid)
+	require.Equal(t, []int{2}, conflicts)
+
+	// a deletion by a later tx doesn't affect the iteration
+	mvs.SetWriteset(2, 3, multiversion.WriteSet{})
+	mvs.SetWriteset(6, 2, multiversion.WriteSet{"key3": nil})
+	valid, conflicts = mvs.ValidateTransactionState(5)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+}
+
+func TestMultiVersionStoreValidationCost(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithStoreName("bank"))
+	require.Equal(t, multiversion.ValidationCost{}, mvs.ValidationCost())
+
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key2": []byte("value2")})
+	// key1 falls through to the parent store, key2 is served by the multiversion store
+	mvs.SetReadset(2, multiversion.ReadSet{"key1": {[]byte("value1")}, "key2": {[]byte("value2")}})
+
+	valid, conflicts := mvs.ValidateTransactionState(2)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+	valid, _ = mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+
+	cost := mvs.ValidationCost()
+	require.Equal(t, 2, cost.Validations)
+	require.Equal(t, cost.Readset+cost.Iterateset+cost.ParentFallthrough, cost.Total())
+}
+
+type recordedWrite struct {
+	key    string
+	value  string
+	delete bool
+}
+
+type recordingListener struct {
+	writes []recordedWrite
+}
+
+func (l *recordingListener) OnWrite(storeKey types.StoreKey, key []byte, value []byte, delete bool) error {
+	l.writes = append(l.writes, recordedWrite{key: string(key), value: string(value), delete: delete})
+	return nil
+}
+
+func TestMultiVersionStoreWriteLatestToStoreWithListeners(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	parentKVStore.Set([]byte("key3"), []byte("value0"))
+
+	mvs.SetWriteset(2, 0, multiversion.WriteSet{"key1": []byte("value2"), "key3": nil})
+	mvs.SetWriteset(0, 1, multiversion.WriteSet{"key2": []byte("value0"), "key1": []byte("value0")})
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": []byte("value1")})
+
+	listener := &recordingListener{}
+	require.NoError(t, mvs.WriteLatestToStoreWithListeners(types.NewKVStoreKey("mock"), []types.WriteListener{listener}))
+
+	// writes are streamed in tx order, with keys in lexical order within a tx
+	require.Equal(t, []recordedWrite{
+		{key: "key1", value: "value0"},
+		{key: "key2", value: "value0"},
+		{key: "key1", value: "value1"},
+		{key: "key1", value: "value2"},
+		{key: "key3", delete: true},
+	}, listener.writes)
+	// the latest values are written to the parent store
+	require.Equal(t, []byte("value2"), parentKVStore.Get([]byte("key1")))
+	require.Equal(t, []byte("value0"), parentKVStore.Get([]byte("key2")))
+	require.Nil(t, parentKVStore.Get([]byte("key3")))
+}
+
+func TestMultiVersionStoreRemoveEstimatesForIndex(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": []byte("value1")})
+	mvs.SetEstimatedWriteset(2, 0, multiversion.WriteSet{"key1": []byte("value2"), "key2": nil})
+	mvs.SetWriteset(3, 0, multiversion.WriteSet{"key3": []byte("value3")})
+	mvs.InvalidateWriteset(3, 0)
+	mvs.TakeDirtyKeys()
+
+	// values are kept
+	mvs.RemoveEstimatesForIndex(1)
+	require.Equal(t, []string{"key1"}, mvs.GetWritesetKeys(1))
+	require.Empty(t, mvs.TakeDirtyKeys())
+
+	// estimates are removed along with their keys, uncovering earlier values
+	mvs.RemoveEstimatesForIndex(2)
+	require.Empty(t, mvs.GetWritesetKeys(2))
+	require.Equal(t, []byte("value1"), mvs.GetLatestBeforeIndex(3, []byte("key1")).Value())
+	require.Nil(t, mvs.GetLatest([]byte("key2")))
+	require.Equal(t, map[string]int{"key1": 2, "key2": 2}, mvs.TakeDirtyKeys())
+
+	// once every estimate is removed, the latest values can be written to the parent store
+	require.Panics(t, func() { _ = mvs.WriteLatestToStoreWithListeners(types.NewKVStoreKey("mock"), nil) })
+	mvs.RemoveEstimatesForIndex(3)
+	require.Empty(t, mvs.GetAllWritesetKeys()[3])
+	require.NoError(t, mvs.WriteLatestToStoreWithListeners(types.NewKVStoreKey("mock"), nil))
+	require.Equal(t, []byte("value1"), parentKVStore.Get([]byte("key1")))
+	require.False(t, parentKVStore.Has([]byte("key2")))
+	require.False(t, parentKVStore.Has([]byte("key3")))
+}
+
+func TestMultiVersionStoreReset(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithStoreName("bank"))
+
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": []byte("value1"), "key2": nil})
+	mvs.SetEstimatedWriteset(2, 0, multiversion.WriteSet{"key3": nil})
+	mvs.SetReadset(3, multiversion.ReadSet{"key1": {[]byte("value1")}})
+	valid, _ := mvs.ValidateTransactionState(3)
+	require.True(t, valid)
+	require.NotEmpty(t, mvs.TakeDirtyKeys())
+
+	newParentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	newParentKVStore.Set([]byte("key1"), []byte("parent"))
+	mvs.Reset(newParentKVStore)
+
+	// nothing of the previous block survives the reset
+	require.Nil(t, mvs.GetLatest([]byte("key1")))
+	require.Nil(t, mvs.GetLatest([]byte("key3")))
+	require.Empty(t, mvs.GetAllWritesetKeys())
+	require.Nil(t, mvs.GetReadset(3))
+	require.Empty(t, mvs.TakeDirtyKeys())
+	require.Empty(t, mvs.GetDependentReaders(0, []string{"key1"}))
+	require.Equal(t, multiversion.ValidationCost{}, mvs.ValidationCost())
+
+	// and the store is usable against the new parent store
+	mvs.SetWriteset(0, 0, multiversion.WriteSet{"key2": []byte("value2")})
+	mvs.SetReadset(1, multiversion.ReadSet{"key1": {[]byte("parent")}, "key2": {[]byte("value2")}})
+	valid, conflicts := mvs.ValidateTransactionState(1)
+	require.True(t, valid)
+	require.Empty(t, conflicts)
+	mvs.WriteLatestToStore()
+	require.Equal(t, []byte("value2"), newParentKVStore.Get([]byte("key2")))
+	require.Nil(t, parentKVStore.Get([]byte("key2")))
+}
+
+func TestMultiVersionStoreLatestWritesetHash(t *testing.T) {
+	newStore := func() *multiversion.Store {
+		return multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	}
+
+	// the same final writes spread differently over txs and incarnations hash the same
+	mvs1 := newStore()
+	mvs1.SetWriteset(1, 1, map[string][]byte{"key1": []byte("value1"), "key2": nil})
+	mvs1.SetWriteset(2, 1, map[string][]byte{"key3": []byte("value3")})
+
+	mvs2 := newStore()
+	mvs2.SetWriteset(1, 1, map[string][]byte{"key1": []byte("stale"), "key2": []byte("value2")})
+	mvs2.SetWriteset(2, 1, map[string][]byte{"key2": nil, "key3": []byte("value3")})
+	mvs2.SetWriteset(3, 1, map[string][]byte{"key1": []byte("value1")})
+	// estimates and keys whose writes were later dropped don't count
+	mvs2.SetEstimatedWriteset(4, occ.PrefillIncarnation, map[string][]byte{"key4": nil})
+	mvs2.SetWriteset(5, 1, map[string][]byte{"key5": []byte("value5")})
+	mvs2.SetWriteset(5, 2, map[string][]byte{})
+	mvs2.InvalidateWriteset(4, occ.PrefillIncarnation)
+	require.Equal(t, mvs1.LatestWritesetHash(), mvs2.LatestWritesetHash())
+
+	// a different value, or a delete of a key that was never set, changes the hash
+	mvs2.SetWriteset(6, 1, map[string][]byte{"key3": []byte("other")})
+	require.NotEqual(t, mvs1.LatestWritesetHash(), mvs2.LatestWritesetHash())
+	mvs3 := newStore()
+	mvs3.SetWriteset(1, 1, map[string][]byte{"key1": []byte("value1"), "key3": []byte("value3")})
+	require.NotEqual(t, mvs1.LatestWritesetHash(), mvs3.LatestWritesetHash())
+}
+
+func TestMultiVersionStoreGetSnapshotBeforeIndex(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+
+	parentKVStore.Set([]byte("key1"), []byte("parent1"))
+	parentKVStore.Set([]byte("key2"), []byte("parent2"))
+	parentKVStore.Set([]byte("key5"), []byte("parent5"))
+
+	mvs.SetWriteset(1, 1, map[string][]byte{"key1": []byte("value1"), "key3": []byte("value3")})
+	mvs.SetWriteset(2, 1, map[string][]byte{"key2": nil, "key4": []byte("value4")})
+	mvs.SetWriteset(3, 1, map[string][]byte{"key1": []byte("value1b")})
+	// an estimate falls back to the latest committed write below it
+	mvs.SetWriteset(4, 1, map[string][]byte{"key3": []byte("value3b")})
+	mvs.InvalidateWriteset(4, 1)
+
+	snapshot := mvs.GetSnapshotBeforeIndex(0)
+	require.Equal(t, []byte("parent1"), snapshot.Get([]byte("key1")))
+	require.Equal(t, []byte("parent2"), snapshot.Get([]byte("key2")))
+	require.False(t, snapshot.Has([]byte("key3")))
+
+	snapshot = mvs.GetSnapshotBeforeIndex(2)
+	require.Equal(t, []byte("value1"), snapshot.Get([]byte("key1")))
+	require.Equal(t, []byte("parent2"), snapshot.Get([]byte("key2")))
+	require.Nil(t, snapshot.Get([]byte("key4")))
+
+	snapshot = mvs.GetSnapshotBeforeIndex(5)
+	require.Equal(t, []byte("value1b"), snapshot.Get([]byte("key1")))
+	require.False(t, snapshot.Has([]byte("key2")))
+	require.Equal(t, []byte("value3"), snapshot.Get([]byte("key3")))
+	require.Equal(t, []byte("value4"), snapshot.Get([]byte("key4")))
+
+	iter := snapshot.Iterator(nil, nil)
+	var keys, values []string
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+		values = append(values, string(iter.Value()))
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, []string{"key1", "key3", "key4", "key5"}, keys)
+	require.Equal(t, []string{"value1b", "value3", "value4", "parent5"}, values)
+
+	iter = snapshot.ReverseIterator([]byte("key2"), []byte("key5"))
+	keys = nil
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, []string{"key4", "key3"}, keys)
+
+	// the snapshot is read-only, and reading it leaves no trace in the multiversion store
+	require.Panics(t, func() { snapshot.Set([]byte("key1"), []byte("value")) })
+	require.Panics(t, func() { snapshot.Delete([]byte("key1")) })
+	require.Empty(t, mvs.GetReadset(5))
+	require.Equal(t, []byte("parent1"), parentKVStore.Get([]byte("key1")))
+}
+
+func TestMultiVersionStoreWaitLatestBeforeIndex(t *testing.T) {
+	store := multiversion.NewMultiVersionStore(nil)
+	store.SetWriteset(1, 0, map[string][]byte{"key": []byte("value1")})
+
+	// values that aren't estimates are returned right away
+	require.Nil(t, store.WaitLatestBeforeIndex(5, []byte("missing"), time.Hour))
+	require.Nil(t, store.WaitLatestBeforeIndex(1, []byte("key"), time.Hour))
+	require.Equal(t, []byte("value1"), store.WaitLatestBeforeIndex(5, []byte("key"), time.Hour).Value())
+
+	// estimates are waited for until they're resolved into the value the estimated tx writes
+	store.SetEstimatedWriteset(2, 0, map[string][]byte{"key": nil})
+	require.True(t, store.WaitLatestBeforeIndex(5, []byte("key"), 0).IsEstimate())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		store.SetWriteset(2, 1, map[string][]byte{"key": []byte("value2")})
+	}()
+	value := store.WaitLatestBeforeIndex(5, []byte("key"), time.Hour)
+	require.False(t, value.IsEstimate())
+	require.Equal(t, []byte("value2"), value.Value())
+
+	// or removed, leaving the value before them
+	store.InvalidateWriteset(2, 1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		store.RemoveEstimatesForIndex(2)
+	}()
+	require.Equal(t, []byte("value1"), store.WaitLatestBeforeIndex(5, []byte("key"), time.Hour).Value())
+
+	// estimates that aren't resolved in time are returned once the timeout expires, changes that don't resolve them
+	// notwithstanding
+	store.SetEstimatedWriteset(2, 2, map[string][]byte{"key": nil})
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		store.SetWriteset(3, 0, map[string][]byte{"key": []byte("value3")})
+	}()
+	start := time.Now()
+	value = store.WaitLatestBeforeIndex(3, []byte("key"), 50*time.Millisecond)
+	require.True(t, value.IsEstimate())
+	require.Equal(t, 2, value.Index())
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
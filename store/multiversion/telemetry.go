@@ -0,0 +1,100 @@
+package multiversion
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+)
+
+// numOperations is the number of kinds of Operation
+const numOperations = int(OperationIteratorStep) + 1
+
+var operationNames = [numOperations]string{
+	OperationRead:         "read",
+	OperationWrite:        "write",
+	OperationIterator:     "iterator",
+	OperationIteratorStep: "iterator_step",
+}
+
+// String returns the name of the operation, for telemetry labels
+func (op Operation) String() string {
+	if op >= 0 && int(op) < numOperations {
+		return operationNames[op]
+	}
+	return "operation(" + strconv.Itoa(int(op)) + ")"
+}
+
+// operationCounts is the number of store operations of each kind
+type operationCounts [numOperations]int64
+
+// WithBatchedTelemetry keeps telemetry off of the store's hot paths. The timings of validation phases, which are
+// otherwise emitted on every validation, are only aggregated, and are emitted once per block by FlushTelemetry along
+// with the rest of the store's counts.
+func WithBatchedTelemetry() StoreOption {
+	return func(s *Store) {
+		s.batchedTelemetry = true
+	}
+}
+
+// OperationCount returns the number of operations of a kind done by the version indexed stores of the store, as of
+// their last writes to it
+func (s *Store) OperationCount(op Operation) int {
+	return int(atomic.LoadInt64(&s.operations[op]))
+}
+
+// FlushTelemetry emits the telemetry aggregated by the store over the block: the number of validations, the operations
+// done by its version indexed stores and the latencies of their reads, its estimates (see EstimateStats), as well as
+// the time spent in each validation phase if telemetry is batched. It's meant to be called once per block, after the block is processed.
+func (s *Store) FlushTelemetry() {
+	cost := s.ValidationCost()
+	telemetry.IncrCounterWithLabels([]string{"store", "mvs", "validations"}, float32(cost.Validations), s.telemetryLabels())
+	for op := Operation(0); int(op) < numOperations; op++ {
+		telemetry.IncrCounterWithLabels(
+			[]string{"store", "mvs", "operations"},
+			float32(s.OperationCount(op)),
+			append(s.telemetryLabels(), telemetry.NewLabel("operation", op.String())),
+		)
+	}
+	s.emitReadLatency()
+	s.emitEstimateStats()
+	s.emitParentReadCacheStats()
+	if !s.batchedTelemetry {
+		return
+	}
+	now := time.Now()
+	for phase, elapsed := range map[string]time.Duration{
+		validationPhaseReadset:    cost.Readset,
+		validationPhaseIterateset: cost.Iterateset,
+		validationPhaseParent:     cost.ParentFallthrough,
+	} {
+		telemetry.MeasureSinceWithLabels([]string{"store", "mvs", "validate_block", phase}, now.Add(-elapsed), s.telemetryLabels())
+	}
+}
+
+func (s *Store) telemetryLabels() []metrics.Label {
+	return []metrics.Label{telemetry.NewLabel("store", s.storeName)}
+}
+
+// countOperation counts an operation of the store locally, since stores are used by a single execution
+func (store *VersionIndexedStore) countOperation(op Operation) {
+	store.operations[op]++
+}
+
+// flushOperations adds the operations counted by the store to the totals of its multiversion store, if it has one.
+// It's called when the store's writes are written to the multiversion store, so that the totals are only updated
+// once per execution rather than on every operation.
+func (store *VersionIndexedStore) flushOperations() {
+	if store.operationTotals == nil {
+		return
+	}
+	for op, count := range store.operations {
+		if count > 0 {
+			atomic.AddInt64(&store.operationTotals[op], count)
+		}
+	}
+	store.operations = operationCounts{}
+}
@@ -0,0 +1,160 @@
+package multiversion_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// newInmemTelemetry routes telemetry to an in-memory sink for the rest of the test
+func newInmemTelemetry(t *testing.T) *metrics.InmemSink {
+	sink := metrics.NewInmemSink(time.Minute, time.Minute)
+	cfg := metrics.DefaultConfig("test")
+	cfg.EnableHostname = false
+	cfg.EnableRuntimeMetrics = false
+	_, err := metrics.NewGlobal(cfg, sink)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = metrics.NewGlobal(metrics.DefaultConfig("test"), &metrics.BlackholeSink{})
+	})
+	return sink
+}
+
+// emitted returns whether a metric whose name contains name was emitted to the sink
+func emitted(sink *metrics.InmemSink, name string) bool {
+	for _, interval := range sink.Data() {
+		interval.RLock()
+		for key := range interval.Counters {
+			if strings.Contains(key, name) {
+				interval.RUnlock()
+				return true
+			}
+		}
+		for key := range interval.Samples {
+			if strings.Contains(key, name) {
+				interval.RUnlock()
+				return true
+			}
+		}
+		for key := range interval.Gauges {
+			if strings.Contains(key, name) {
+				interval.RUnlock()
+				return true
+			}
+		}
+		interval.RUnlock()
+	}
+	return false
+}
+
+func TestMultiVersionStoreOperationCount(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("key1"), []byte("value1"))
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+
+	vis := mvs.VersionedIndexedStore(1, 0, make(chan occ.Abort, 1))
+	vis.Get([]byte("key1"))
+	vis.Has([]byte("key2"))
+	vis.Set([]byte("key2"), []byte("value2"))
+	vis.Delete([]byte("key1"))
+	iter := vis.Iterator(nil, nil)
+	for ; iter.Valid(); iter.Next() {
+	}
+	iter.Close()
+
+	// operations are only counted towards the multiversion store once the writes are
+	require.Zero(t, mvs.OperationCount(multiversion.OperationRead))
+	vis.WriteToMultiVersionStore()
+	require.Equal(t, 2, mvs.OperationCount(multiversion.OperationRead))
+	require.Equal(t, 2, mvs.OperationCount(multiversion.OperationWrite))
+	require.Equal(t, 1, mvs.OperationCount(multiversion.OperationIterator))
+	require.Equal(t, 1, mvs.OperationCount(multiversion.OperationIteratorStep))
+
+	// aborted executions count as well
+	vis = mvs.VersionedIndexedStore(2, 0, make(chan occ.Abort, 1))
+	vis.Set([]byte("key3"), []byte("value3"))
+	vis.WriteEstimatesToMultiVersionStore()
+	require.Equal(t, 3, mvs.OperationCount(multiversion.OperationWrite))
+
+	mvs.Reset(parentKVStore)
+	require.Zero(t, mvs.OperationCount(multiversion.OperationWrite))
+}
+
+func TestMultiVersionStoreBatchedTelemetry(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	validate := func(mvs *multiversion.Store) {
+		mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": []byte("value1")})
+		mvs.SetReadset(2, multiversion.ReadSet{"key1": {[]byte("value1")}})
+		valid, _ := mvs.ValidateTransactionState(2)
+		require.True(t, valid)
+	}
+
+	sink := newInmemTelemetry(t)
+	validate(multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithStoreName("bank")))
+	require.True(t, emitted(sink, "store.mvs.validate.readset"))
+
+	// batched telemetry is only emitted once flushed
+	sink = newInmemTelemetry(t)
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, multiversion.WithStoreName("bank"), multiversion.WithBatchedTelemetry())
+	validate(mvs)
+	require.False(t, emitted(sink, "store.mvs.validate"))
+
+	mvs.FlushTelemetry()
+	require.False(t, emitted(sink, "store.mvs.validate.readset"))
+	require.True(t, emitted(sink, "store.mvs.validate_block.readset"))
+	require.True(t, emitted(sink, "store.mvs.validations"))
+	require.True(t, emitted(sink, "store.mvs.operations"))
+}
+
+// slowStore is a parent store whose reads take at least delay
+type slowStore struct {
+	types.KVStore
+	delay time.Duration
+}
+
+func (s slowStore) Get(key []byte) []byte {
+	time.Sleep(s.delay)
+	return s.KVStore.Get(key)
+}
+
+func TestMultiVersionStoreReadLatency(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	parentKVStore.Set([]byte("parent"), []byte("value0"))
+	mvs := multiversion.NewMultiVersionStore(slowStore{KVStore: parentKVStore, delay: 2 * time.Millisecond}, multiversion.WithStoreName("bank"))
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"key1": []byte("value1")})
+
+	vis := mvs.VersionedIndexedStore(2, 0, make(chan occ.Abort, 1))
+	vis.Get([]byte("key1"))
+	vis.Get([]byte("parent"))
+	vis.Get([]byte("missing"))
+	// reads served from the readset or writeset aren't timed
+	vis.Get([]byte("parent"))
+	vis.Set([]byte("key2"), []byte("value2"))
+	vis.Get([]byte("key2"))
+
+	// reads are only counted towards the multiversion store once the writes are
+	require.Zero(t, mvs.ReadCount(multiversion.ReadSourceParent))
+	vis.WriteToMultiVersionStore()
+	require.Equal(t, 1, mvs.ReadCount(multiversion.ReadSourceMultiVersion))
+	require.Equal(t, 2, mvs.ReadCount(multiversion.ReadSourceParent))
+	require.GreaterOrEqual(t, mvs.ReadLatency(multiversion.ReadSourceParent, 0.5), 2*time.Millisecond)
+	require.Less(t, mvs.ReadLatency(multiversion.ReadSourceMultiVersion, 0.99), mvs.ReadLatency(multiversion.ReadSourceParent, 0.5))
+
+	sink := newInmemTelemetry(t)
+	mvs.FlushTelemetry()
+	require.True(t, emitted(sink, "store.mvs.read_latency.parent.p99"))
+	require.True(t, emitted(sink, "store.mvs.read_latency.mvs.p50"))
+
+	mvs.Reset(parentKVStore)
+	require.Zero(t, mvs.ReadCount(multiversion.ReadSourceParent))
+	require.Zero(t, mvs.ReadLatency(multiversion.ReadSourceParent, 0.5))
+}
@@ -9,6 +9,8 @@ type trackedIterator struct {
 	types.Iterator
 
 	iterateset *iterationTracker
+	// onStep is called before every call to Next, if set
+	onStep func()
 }
 
 func NewTrackedIterator(iter types.Iterator, iterationTracker *iterationTracker) *trackedIterator {
@@ -48,6 +50,9 @@ func (ti *trackedIterator) Value() []byte {
 }
 
 func (ti *trackedIterator) Next() {
+	if ti.onStep != nil {
+		ti.onStep()
+	}
 	// add current key to the tracker
 	key := ti.Iterator.Key()
 	ti.iterateset.AddKey(key)
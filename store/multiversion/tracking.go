@@ -0,0 +1,90 @@
+package multiversion
+
+import (
+	"sync/atomic"
+)
+
+// TrackingMeter caps the number of entries a tx's version indexed stores record for validation, so that a
+// pathological tx can't grow its readset or writeset without bound. Reads count the distinct keys added to the
+// readsets and existence sets, and the iterators added to the iteratesets, while writes count the distinct keys
+// written. Once either cap is exceeded the meter overflows, and the stores stop recording reads: the reads recorded so
+// far are kept, but the tx can no longer be validated from them, so it must be executed again once every lower-index
+// tx is final. Writes are still recorded, since they're the tx's output. The meter is thread-safe, and is meant to be
+// shared by all of a tx's stores.
+type TrackingMeter struct {
+	maxReads  int64
+	maxWrites int64
+	// only accessed atomically
+	reads      int64
+	writes     int64
+	overflowed int32
+}
+
+// NewTrackingMeter returns a TrackingMeter allowing at most maxReads reads and maxWrites writes. Non-positive values
+// disable the respective cap.
+func NewTrackingMeter(maxReads, maxWrites int) *TrackingMeter {
+	return &TrackingMeter{maxReads: int64(maxReads), maxWrites: int64(maxWrites)}
+}
+
+// Overflowed returns whether a cap was exceeded
+func (m *TrackingMeter) Overflowed() bool {
+	return atomic.LoadInt32(&m.overflowed) == 1
+}
+
+// Reads returns the reads recorded, and Writes the writes
+func (m *TrackingMeter) Reads() int {
+	return int(atomic.LoadInt64(&m.reads))
+}
+
+func (m *TrackingMeter) Writes() int {
+	return int(atomic.LoadInt64(&m.writes))
+}
+
+// consumeRead counts a read about to be recorded, returning false if the meter overflowed, in which case it mustn't
+// be recorded
+func (m *TrackingMeter) consumeRead() bool {
+	if m.Overflowed() {
+		return false
+	}
+	if reads := atomic.AddInt64(&m.reads, 1); m.maxReads > 0 && reads > m.maxReads {
+		atomic.StoreInt32(&m.overflowed, 1)
+		return false
+	}
+	return true
+}
+
+// consumeWrite counts a written key, overflowing the meter if the writes exceed their cap
+func (m *TrackingMeter) consumeWrite() {
+	if writes := atomic.AddInt64(&m.writes, 1); m.maxWrites > 0 && writes > m.maxWrites {
+		atomic.StoreInt32(&m.overflowed, 1)
+	}
+}
+
+// SetTrackingMeter sets the meter that the entries recorded by the store's readset, existence set, iterateset and
+// writeset count against
+func (store *VersionIndexedStore) SetTrackingMeter(meter *TrackingMeter) *VersionIndexedStore {
+	store.trackingMeter = meter
+	return store
+}
+
+// tracksReads returns whether reads are recorded, ie. read tracking isn't disabled and the tracking meter didn't
+// overflow
+func (store *VersionIndexedStore) tracksReads() bool {
+	return !store.readTrackingDisabled && (store.trackingMeter == nil || !store.trackingMeter.Overflowed())
+}
+
+// trackRead counts a new entry about to be added to the readset, existence set or iterateset, returning whether it
+// may be recorded
+func (store *VersionIndexedStore) trackRead() bool {
+	if store.readTrackingDisabled {
+		return false
+	}
+	return store.trackingMeter == nil || store.trackingMeter.consumeRead()
+}
+
+// trackWrite counts a new key added to the writeset
+func (store *VersionIndexedStore) trackWrite() {
+	if store.trackingMeter != nil {
+		store.trackingMeter.consumeWrite()
+	}
+}
@@ -0,0 +1,86 @@
+package multiversion_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	scheduler "github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestVersionIndexedStoreTrackingMeterReads(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	for _, key := range []string{"key1", "key2", "key3"} {
+		parentKVStore.Set([]byte(key), []byte("value"))
+	}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	abortCh := make(chan scheduler.Abort, 1)
+
+	// the meter is shared across stores, so the reads of both count towards the same cap
+	meter := multiversion.NewTrackingMeter(3, 0)
+	vis1 := mvs.VersionedIndexedStore(1, 0, abortCh).SetTrackingMeter(meter)
+	vis2 := mvs.VersionedIndexedStore(1, 0, abortCh).SetTrackingMeter(meter)
+
+	// reads count their key once, existence checks too, and iterators count once each
+	vis1.Get([]byte("key1"))
+	vis1.Get([]byte("key1"))
+	vis2.Has([]byte("key2"))
+	iter := vis1.Iterator(nil, nil)
+	iter.Close()
+	require.Equal(t, 3, meter.Reads())
+	require.False(t, meter.Overflowed())
+
+	// the read exceeding the cap is served, but neither it nor any later read is recorded
+	require.Equal(t, []byte("value"), vis2.Get([]byte("key3")))
+	require.True(t, meter.Overflowed())
+	vis1.Get([]byte("key2"))
+	require.False(t, vis1.Has([]byte("key4")))
+	vis1.Iterator(nil, nil).Close()
+	require.Len(t, vis1.GetReadset(), 1)
+	require.Empty(t, vis2.GetReadset())
+	require.Len(t, vis2.GetExistenceSet(), 1)
+
+	// writes are still recorded, and the reads recorded before the overflow are kept
+	vis1.Set([]byte("key5"), []byte("value5"))
+	vis1.WriteToMultiVersionStore()
+	require.Equal(t, map[string][]byte{"key5": []byte("value5")}, vis1.GetWriteset())
+	require.Len(t, mvs.GetReadset(1), 1)
+	require.Len(t, mvs.GetIterateset(1), 1)
+	require.Empty(t, abortCh)
+}
+
+func TestVersionIndexedStoreTrackingMeterWrites(t *testing.T) {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore)
+	meter := multiversion.NewTrackingMeter(0, 2)
+	vis := mvs.VersionedIndexedStore(1, 0, make(chan scheduler.Abort, 1)).SetTrackingMeter(meter)
+
+	// writes count their key once
+	vis.Set([]byte("key1"), []byte("value1"))
+	vis.Set([]byte("key1"), []byte("value2"))
+	vis.Delete([]byte("key2"))
+	require.Equal(t, 2, meter.Writes())
+	vis.Get([]byte("key3"))
+	require.False(t, meter.Overflowed())
+
+	// exceeding the cap on writes stops recording reads, while the writes are kept
+	vis.Set([]byte("key4"), []byte("value4"))
+	require.True(t, meter.Overflowed())
+	vis.Get([]byte("key5"))
+	require.Len(t, vis.GetReadset(), 1)
+	require.Len(t, vis.GetWriteset(), 3)
+
+	// without caps, the meter only counts
+	meter = multiversion.NewTrackingMeter(0, 0)
+	vis = mvs.VersionedIndexedStore(2, 0, make(chan scheduler.Abort, 1)).SetTrackingMeter(meter)
+	for _, key := range []string{"key1", "key2", "key3"} {
+		vis.Get([]byte(key))
+		vis.Set([]byte(key), []byte("value"))
+	}
+	require.Equal(t, 3, meter.Reads())
+	require.Equal(t, 3, meter.Writes())
+	require.False(t, meter.Overflowed())
+}
@@ -0,0 +1,185 @@
+package multiversion_test
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// The validation vectors pin down the semantics of ValidateTransactionState, so that refactors of validation can
+// prove they're equivalent. Each vector sets up a parent store and the writesets of other txs, executes a tx that
+// reads and iterates, changes the writesets of other txs, and then validates the tx against the expected outcome.
+// Run with -update-vectors to rewrite the expected outcomes from the current implementation, and review the diff.
+
+var updateVectors = flag.Bool("update-vectors", false, "rewrite the expected outcomes of the validation vectors")
+
+const validationVectorsPath = "testdata/validation_vectors.json"
+
+// vectorValue is a value in a vector, where null is a delete or a missing key
+type vectorValue *string
+
+// vectorWriteset is a change to the writeset of a tx. Kind is one of "set", "estimate" or "invalidate", which
+// correspond to SetWriteset, SetEstimatedWriteset and InvalidateWriteset.
+type vectorWriteset struct {
+	Kind        string                 `json:"kind"`
+	Index       int                    `json:"index"`
+	Incarnation int                    `json:"incarnation"`
+	Writeset    map[string]vectorValue `json:"writeset,omitempty"`
+}
+
+// vectorIteration is an iteration by the validated tx, stopped after limit keys if non-zero
+type vectorIteration struct {
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Reverse bool   `json:"reverse,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+type vectorExpected struct {
+	Valid     bool  `json:"valid"`
+	Conflicts []int `json:"conflicts"`
+}
+
+type validationVector struct {
+	Name   string            `json:"name"`
+	Parent map[string]string `json:"parent,omitempty"`
+	// Before are the writeset changes of other txs applied before the validated tx executes
+	Before []vectorWriteset `json:"before,omitempty"`
+	// Index is the index of the validated tx
+	Index int `json:"index"`
+	// Writes are the writes of the validated tx, applied before its reads
+	Writes map[string]vectorValue `json:"writes,omitempty"`
+	// Reads are the keys read by the validated tx, in order
+	Reads []string `json:"reads,omitempty"`
+	// Iterations are the iterations of the validated tx, after its reads
+	Iterations []vectorIteration `json:"iterations,omitempty"`
+	// Readset replaces the recorded readset of the validated tx, to cover readsets execution can't produce
+	Readset map[string][]vectorValue `json:"readset,omitempty"`
+	// After are the writeset changes of other txs applied after the validated tx executed
+	After    []vectorWriteset `json:"after,omitempty"`
+	Expected vectorExpected   `json:"expected"`
+}
+
+func vectorBytes(value vectorValue) []byte {
+	if value == nil {
+		return nil
+	}
+	return []byte(*value)
+}
+
+func applyVectorWriteset(t *testing.T, mvs *multiversion.Store, change vectorWriteset) {
+	writeset := make(multiversion.WriteSet, len(change.Writeset))
+	for key, value := range change.Writeset {
+		writeset[key] = vectorBytes(value)
+	}
+	switch change.Kind {
+	case "set":
+		mvs.SetWriteset(change.Index, change.Incarnation, writeset)
+	case "estimate":
+		mvs.SetEstimatedWriteset(change.Index, change.Incarnation, writeset)
+	case "invalidate":
+		mvs.InvalidateWriteset(change.Index, change.Incarnation)
+	default:
+		t.Fatalf("unknown writeset change kind %q", change.Kind)
+	}
+}
+
+// runValidationVector returns the outcome of validating the vector's tx in a store with the given options
+func runValidationVector(t *testing.T, vector validationVector, opts ...multiversion.StoreOption) vectorExpected {
+	parentKVStore := dbadapter.Store{DB: dbm.NewMemDB()}
+	for key, value := range vector.Parent {
+		parentKVStore.Set([]byte(key), []byte(value))
+	}
+	mvs := multiversion.NewMultiVersionStore(parentKVStore, opts...)
+	for _, change := range vector.Before {
+		applyVectorWriteset(t, mvs, change)
+	}
+
+	vis := mvs.VersionedIndexedStore(vector.Index, 0, make(chan occ.Abort, 1))
+	for key, value := range vector.Writes {
+		if value == nil {
+			vis.Delete([]byte(key))
+		} else {
+			vis.Set([]byte(key), vectorBytes(value))
+		}
+	}
+	for _, key := range vector.Reads {
+		vis.Get([]byte(key))
+	}
+	for _, iteration := range vector.Iterations {
+		iter := vis.Iterator([]byte(iteration.Start), []byte(iteration.End))
+		if iteration.Reverse {
+			iter = vis.ReverseIterator([]byte(iteration.Start), []byte(iteration.End))
+		}
+		for steps := 0; iter.Valid() && (iteration.Limit == 0 || steps < iteration.Limit); iter.Next() {
+			iter.Value()
+			steps++
+		}
+		iter.Close()
+	}
+	vis.WriteToMultiVersionStore()
+	if vector.Readset != nil {
+		readset := make(multiversion.ReadSet, len(vector.Readset))
+		for key, values := range vector.Readset {
+			for _, value := range values {
+				readset[key] = append(readset[key], vectorBytes(value))
+			}
+		}
+		mvs.SetReadset(vector.Index, readset)
+	}
+
+	for _, change := range vector.After {
+		applyVectorWriteset(t, mvs, change)
+	}
+
+	valid, conflicts := mvs.ValidateTransactionState(vector.Index)
+	if conflicts == nil {
+		conflicts = []int{}
+	}
+	return vectorExpected{Valid: valid, Conflicts: conflicts}
+}
+
+func TestValidationVectors(t *testing.T) {
+	bz, err := os.ReadFile(validationVectorsPath)
+	require.NoError(t, err)
+	var vectors []validationVector
+	require.NoError(t, json.Unmarshal(bz, &vectors))
+	require.NotEmpty(t, vectors)
+
+	names := make(map[string]struct{}, len(vectors))
+	for i, vector := range vectors {
+		_, duplicate := names[vector.Name]
+		require.False(t, duplicate, "duplicate vector name %q", vector.Name)
+		names[vector.Name] = struct{}{}
+
+		actual := runValidationVector(t, vector)
+		if *updateVectors {
+			vectors[i].Expected = actual
+			continue
+		}
+		t.Run(vector.Name, func(t *testing.T) {
+			require.Equal(t, vector.Expected, actual)
+			// neither do readset digests or hashed readsets change the outcome of validation, even with every key
+			// colliding
+			require.Equal(t, vector.Expected, runValidationVector(t, vector, multiversion.WithReadsetDigests(1)))
+			require.Equal(t, vector.Expected, runValidationVector(t, vector, multiversion.WithHashedReadsets()))
+			require.Equal(t, vector.Expected, runValidationVector(t, vector, multiversion.WithReadKeyHash(func([]byte) uint64 { return 0 })))
+		})
+	}
+
+	if *updateVectors {
+		bz, err := json.MarshalIndent(vectors, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Dir(validationVectorsPath), 0o755))
+		require.NoError(t, os.WriteFile(validationVectorsPath, append(bz, '\n'), 0o644))
+	}
+}
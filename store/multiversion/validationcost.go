@@ -0,0 +1,90 @@
+package multiversion
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+)
+
+const (
+	// validationPhaseReadset is the readset scan, excluding reads that fall through to the parent store
+	validationPhaseReadset = "readset"
+	// validationPhaseIterateset is the replay of the iterateset
+	validationPhaseIterateset = "iterateset"
+	// validationPhaseParent is readset reads that fall through to the parent store
+	validationPhaseParent = "parent"
+)
+
+// ValidationCost is the cumulative time spent validating txs against a multiversion store, broken down by phase
+type ValidationCost struct {
+	// Validations is the number of ValidateTransactionState calls
+	Validations int
+	// Readset is the time spent scanning readsets, excluding parent store fallthrough
+	Readset time.Duration
+	// Iterateset is the time spent replaying iterators
+	Iterateset time.Duration
+	// ParentFallthrough is the time spent reading readset keys from the parent store
+	ParentFallthrough time.Duration
+	// UnchangedReads is the number of reads that were known to be valid without a lookup, because their key was
+	// unchanged since, see SetReadsetWithGenerations
+	UnchangedReads int
+	// PrefilteredReadsets is the number of readsets that were known to be valid without a lookup, because no earlier
+	// tx wrote to them since, see WithReadsetPrefilter
+	PrefilteredReadsets int
+}
+
+// Total returns the total validation time
+func (c ValidationCost) Total() time.Duration {
+	return c.Readset + c.Iterateset + c.ParentFallthrough
+}
+
+type validationCost struct {
+	validations int64
+	readset     int64
+	iterateset  int64
+	parent      int64
+
+	unchangedReads      int64
+	prefilteredReadsets int64
+}
+
+// WithStoreName names the store in its telemetry labels
+func WithStoreName(storeName string) StoreOption {
+	return func(s *Store) {
+		s.storeName = storeName
+	}
+}
+
+// ValidationCost returns the cumulative validation cost of the store
+func (s *Store) ValidationCost() ValidationCost {
+	return ValidationCost{
+		Validations:         int(atomic.LoadInt64(&s.validationCost.validations)),
+		Readset:             time.Duration(atomic.LoadInt64(&s.validationCost.readset)),
+		Iterateset:          time.Duration(atomic.LoadInt64(&s.validationCost.iterateset)),
+		ParentFallthrough:   time.Duration(atomic.LoadInt64(&s.validationCost.parent)),
+		UnchangedReads:      int(atomic.LoadInt64(&s.validationCost.unchangedReads)),
+		PrefilteredReadsets: int(atomic.LoadInt64(&s.validationCost.prefilteredReadsets)),
+	}
+}
+
+// recordValidationPhase adds the time spent in a validation phase to the store's cost and emits it as telemetry
+// labeled with the store name, unless telemetry is batched
+func (s *Store) recordValidationPhase(phase string, elapsed time.Duration) {
+	switch phase {
+	case validationPhaseReadset:
+		atomic.AddInt64(&s.validationCost.readset, int64(elapsed))
+	case validationPhaseIterateset:
+		atomic.AddInt64(&s.validationCost.iterateset, int64(elapsed))
+	case validationPhaseParent:
+		atomic.AddInt64(&s.validationCost.parent, int64(elapsed))
+	}
+	if s.batchedTelemetry {
+		return
+	}
+	telemetry.MeasureSinceWithLabels(
+		[]string{"store", "mvs", "validate", phase},
+		time.Now().Add(-elapsed),
+		s.telemetryLabels(),
+	)
+}
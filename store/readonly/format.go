@@ -0,0 +1,128 @@
+package readonly
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+// A snapshot is laid out as the magic, followed by the entries in ascending key order, each a uvarint-prefixed key
+// and value, then the index of the entry offsets as little-endian uint64s, and a footer of the index offset, the
+// entry count and the magic again. The index gives random access to the entries without decoding them up front, so
+// opening even a large snapshot is instant.
+var magic = []byte("SEIROKV1")
+
+const footerSize = 8 + 8 + 8
+
+// ErrCorruptSnapshot is returned when opening data that isn't a well-formed snapshot
+var ErrCorruptSnapshot = errors.New("corrupt readonly snapshot")
+
+// Export writes every entry of iter to w as a snapshot, which can then be opened with NewStore or Open. The keys of
+// iter must be ascending, as they are for the iterator of any KVStore. The iterator is closed once exhausted.
+func Export(w io.Writer, iter types.Iterator) error {
+	defer iter.Close()
+
+	bw := bufio.NewWriter(w)
+	var offsets []uint64
+	offset := uint64(0)
+	write := func(b []byte) error {
+		n, err := bw.Write(b)
+		offset += uint64(n)
+		return err
+	}
+	putUvarint := func(v uint64) error {
+		var buf [binary.MaxVarintLen64]byte
+		return write(buf[:binary.PutUvarint(buf[:], v)])
+	}
+
+	if err := write(magic); err != nil {
+		return err
+	}
+	var prev []byte
+	for ; iter.Valid(); iter.Next() {
+		key, value := iter.Key(), iter.Value()
+		if prev != nil && bytes.Compare(prev, key) >= 0 {
+			return fmt.Errorf("keys must be ascending, got %X after %X", key, prev)
+		}
+		prev = append(prev[:0], key...)
+
+		offsets = append(offsets, offset)
+		if err := putUvarint(uint64(len(key))); err != nil {
+			return err
+		}
+		if err := write(key); err != nil {
+			return err
+		}
+		if err := putUvarint(uint64(len(value))); err != nil {
+			return err
+		}
+		if err := write(value); err != nil {
+			return err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	indexOffset := offset
+	var buf [8]byte
+	for _, o := range offsets {
+		binary.LittleEndian.PutUint64(buf[:], o)
+		if err := write(buf[:]); err != nil {
+			return err
+		}
+	}
+	binary.LittleEndian.PutUint64(buf[:], indexOffset)
+	if err := write(buf[:]); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint64(buf[:], uint64(len(offsets)))
+	if err := write(buf[:]); err != nil {
+		return err
+	}
+	if err := write(magic); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// parseFooter checks the framing of a snapshot, returning its index and entry count
+func parseFooter(data []byte) (index []byte, count int, err error) {
+	if len(data) < len(magic)+footerSize || !bytes.Equal(data[:len(magic)], magic) || !bytes.Equal(data[len(data)-len(magic):], magic) {
+		return nil, 0, fmt.Errorf("%w: bad framing", ErrCorruptSnapshot)
+	}
+	footer := data[len(data)-footerSize:]
+	indexOffset := binary.LittleEndian.Uint64(footer[0:8])
+	entries := binary.LittleEndian.Uint64(footer[8:16])
+	indexEnd := uint64(len(data) - footerSize)
+	if indexOffset < uint64(len(magic)) || indexOffset > indexEnd || (indexEnd-indexOffset)/8 != entries || (indexEnd-indexOffset)%8 != 0 {
+		return nil, 0, fmt.Errorf("%w: bad index", ErrCorruptSnapshot)
+	}
+	return data[indexOffset:indexEnd], int(entries), nil
+}
+
+// entry decodes the i-th entry of the snapshot, returning slices of the underlying data
+func (s *Store) entry(i int) (key, value []byte) {
+	offset := binary.LittleEndian.Uint64(s.index[i*8:])
+	if offset >= uint64(len(s.entries)) {
+		panic(fmt.Errorf("%w: entry %d out of range", ErrCorruptSnapshot, i))
+	}
+	buf := s.entries[offset:]
+	key, buf = readBytes(buf, i)
+	value, _ = readBytes(buf, i)
+	return key, value
+}
+
+func readBytes(buf []byte, i int) ([]byte, []byte) {
+	n, read := binary.Uvarint(buf)
+	if read <= 0 || n > uint64(len(buf)-read) {
+		panic(fmt.Errorf("%w: entry %d truncated", ErrCorruptSnapshot, i))
+	}
+	buf = buf[read:]
+	return buf[:n], buf[n:]
+}
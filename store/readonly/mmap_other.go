@@ -0,0 +1,18 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package readonly
+
+import (
+	"os"
+)
+
+// Open reads the snapshot file at path into memory and returns a store over it, since memory mapping isn't supported
+// on this platform.
+func Open(path string) (*Store, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewStore(data)
+}
@@ -0,0 +1,39 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package readonly
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Open memory maps the snapshot file at path read-only and returns a store over it, so that only the pages that are
+// read get loaded, and they're shared with any other process mapping the same file. The store must be closed to
+// unmap the file.
+func Open(path string) (*Store, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return nil, fmt.Errorf("%w: empty file", ErrCorruptSnapshot)
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("mmap %s: %w", path, err)
+	}
+	store, err := newStore(data, func() error { return syscall.Munmap(data) })
+	if err != nil {
+		_ = syscall.Munmap(data)
+		return nil, err
+	}
+	return store, nil
+}
@@ -0,0 +1,238 @@
+// Package readonly provides a KVStore over an immutable snapshot of a store's state, eg. exported from a node or memory
+// mapped from disk, for replaying blocks outside of a consensus node (eg. in analytics pipelines). Unlike an IAVL store
+// it has no mutable tree or versions to maintain, so reads are a binary search over the snapshot. It serves as the
+// parent of the multiversion stores of the OCC scheduler, whose writes go to the cache multistore wrapping it.
+package readonly
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"sync"
+
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/cachekv"
+	"github.com/cosmos/cosmos-sdk/store/cachemulti"
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/listenkv"
+	"github.com/cosmos/cosmos-sdk/store/tracekv"
+	"github.com/cosmos/cosmos-sdk/store/types"
+)
+
+var _ types.KVStore = (*Store)(nil)
+
+// Store is a read-only KVStore over a snapshot written by Export. It's safe for concurrent use. Returned keys and values
+// are copies, so they stay valid once the store is closed.
+type Store struct {
+	entries []byte // the snapshot up to its index, which entry offsets are relative to
+	index   []byte
+	count   int
+
+	closeOnce sync.Once
+	release   func() error
+}
+
+// NewStore returns a store over snapshot data held in memory, which must not be modified while the store is in use
+func NewStore(data []byte) (*Store, error) {
+	return newStore(data, nil)
+}
+
+func newStore(data []byte, release func() error) (*Store, error) {
+	index, count, err := parseFooter(data)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{
+		entries: data[:len(data)-footerSize-len(index)],
+		index:   index,
+		count:   count,
+		release: release,
+	}, nil
+}
+
+// Close releases the snapshot, eg. unmapping it if the store was opened with Open. The store must not be used
+// afterwards. Closing a closed store is a no-op.
+func (s *Store) Close() (err error) {
+	s.closeOnce.Do(func() {
+		if s.release != nil {
+			err = s.release()
+		}
+	})
+	return err
+}
+
+// Len returns the number of entries of the snapshot
+func (s *Store) Len() int {
+	return s.count
+}
+
+// search returns the position of the first entry whose key is at least key, or the entry count if there's none
+func (s *Store) search(key []byte) int {
+	return sort.Search(s.count, func(i int) bool {
+		k, _ := s.entry(i)
+		return bytes.Compare(k, key) >= 0
+	})
+}
+
+func (s *Store) get(key []byte) []byte {
+	types.AssertValidKey(key)
+	i := s.search(key)
+	if i == s.count {
+		return nil
+	}
+	k, v := s.entry(i)
+	if !bytes.Equal(k, key) {
+		return nil
+	}
+	return v
+}
+
+// Get implements types.KVStore.
+func (s *Store) Get(key []byte) []byte {
+	v := s.get(key)
+	if v == nil {
+		return nil
+	}
+	return types.CopyBytes(v)
+}
+
+// Has implements types.KVStore.
+func (s *Store) Has(key []byte) bool {
+	return s.get(key) != nil
+}
+
+// Set implements types.KVStore.
+func (*Store) Set(key, value []byte) {
+	panic("Set not supported for readonly store")
+}
+
+// Delete implements types.KVStore.
+func (*Store) Delete(key []byte) {
+	panic("Delete not supported for readonly store")
+}
+
+// Iterator implements types.KVStore.
+func (s *Store) Iterator(start, end []byte) types.Iterator {
+	return s.iterator(start, end, true)
+}
+
+// ReverseIterator implements types.KVStore.
+func (s *Store) ReverseIterator(start, end []byte) types.Iterator {
+	return s.iterator(start, end, false)
+}
+
+func (s *Store) iterator(start, end []byte, ascending bool) types.Iterator {
+	lo, hi := 0, s.count
+	if start != nil {
+		lo = s.search(start)
+	}
+	if end != nil {
+		hi = s.search(end)
+	}
+	iter := &iterator{store: s, start: start, end: end, lo: lo, hi: hi, ascending: ascending, pos: lo}
+	if !ascending {
+		iter.pos = hi - 1
+	}
+	return iter
+}
+
+// GetStoreType implements types.KVStore.
+func (*Store) GetStoreType() types.StoreType {
+	return types.StoreTypeDB
+}
+
+// GetWorkingHash implements types.KVStore.
+func (*Store) GetWorkingHash() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// CacheWrap implements types.KVStore.
+func (s *Store) CacheWrap(storeKey types.StoreKey) types.CacheWrap {
+	return cachekv.NewStore(s, storeKey, types.DefaultCacheSizeLimit)
+}
+
+// CacheWrapWithTrace implements types.KVStore.
+func (s *Store) CacheWrapWithTrace(storeKey types.StoreKey, w io.Writer, tc types.TraceContext) types.CacheWrap {
+	return cachekv.NewStore(tracekv.NewStore(s, w, tc), storeKey, types.DefaultCacheSizeLimit)
+}
+
+// CacheWrapWithListeners implements types.KVStore.
+func (s *Store) CacheWrapWithListeners(storeKey types.StoreKey, listeners []types.WriteListener) types.CacheWrap {
+	return cachekv.NewStore(listenkv.NewStore(s, storeKey, listeners), storeKey, types.DefaultCacheSizeLimit)
+}
+
+// NewCacheMultiStore returns a cache multistore over read-only stores by store key, to replay blocks against, eg. with
+// the OCC scheduler. Writes of the replayed txs are kept in the cache, and read back out of it with GetKVStore. The
+// multistore must not be written, since the stores under it are read-only.
+func NewCacheMultiStore(stores map[types.StoreKey]*Store) types.CacheMultiStore {
+	wrappers := make(map[types.StoreKey]types.CacheWrapper, len(stores))
+	keys := make(map[string]types.StoreKey, len(stores))
+	for key, store := range stores {
+		wrappers[key] = store
+		keys[key.Name()] = key
+	}
+	return cachemulti.NewFromKVStore(dbadapter.Store{DB: dbm.NewMemDB()}, wrappers, keys, nil, nil, nil)
+}
+
+// iterator iterates over the entries of a snapshot between positions lo (inclusive) and hi (exclusive)
+type iterator struct {
+	store      *Store
+	start, end []byte
+	lo, hi     int
+	pos        int
+	ascending  bool
+}
+
+var _ types.Iterator = (*iterator)(nil)
+
+// Domain implements types.Iterator.
+func (it *iterator) Domain() ([]byte, []byte) {
+	return it.start, it.end
+}
+
+// Valid implements types.Iterator.
+func (it *iterator) Valid() bool {
+	return it.pos >= it.lo && it.pos < it.hi
+}
+
+// Next implements types.Iterator.
+func (it *iterator) Next() {
+	if !it.Valid() {
+		panic("iterator is invalid")
+	}
+	if it.ascending {
+		it.pos++
+	} else {
+		it.pos--
+	}
+}
+
+// Key implements types.Iterator.
+func (it *iterator) Key() []byte {
+	if !it.Valid() {
+		panic("iterator is invalid")
+	}
+	k, _ := it.store.entry(it.pos)
+	return types.CopyBytes(k)
+}
+
+// Value implements types.Iterator.
+func (it *iterator) Value() []byte {
+	if !it.Valid() {
+		panic("iterator is invalid")
+	}
+	_, v := it.store.entry(it.pos)
+	return types.CopyBytes(v)
+}
+
+// Error implements types.Iterator.
+func (*iterator) Error() error {
+	return nil
+}
+
+// Close implements types.Iterator.
+func (it *iterator) Close() error {
+	it.lo, it.hi = 0, 0
+	return nil
+}
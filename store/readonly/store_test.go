@@ -0,0 +1,174 @@
+package readonly_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+	dbm "github.com/tendermint/tm-db"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/readonly"
+	"github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/tasks"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/utils/tracing"
+)
+
+// newSource returns a store with n random entries, along with a snapshot of it
+func newSource(t *testing.T, n int) (types.KVStore, []byte) {
+	rng := rand.New(rand.NewSource(1))
+	source := dbadapter.Store{DB: dbm.NewMemDB()}
+	for i := 0; i < n; i++ {
+		key := make([]byte, 1+rng.Intn(8))
+		rng.Read(key)
+		value := make([]byte, rng.Intn(64))
+		rng.Read(value)
+		source.Set(key, append([]byte("v"), value...))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, readonly.Export(&buf, source.Iterator(nil, nil)))
+	return source, buf.Bytes()
+}
+
+func requireSameIteration(t *testing.T, expected, actual types.Iterator) {
+	defer expected.Close()
+	defer actual.Close()
+	for ; expected.Valid(); expected.Next() {
+		require.True(t, actual.Valid())
+		require.Equal(t, expected.Key(), actual.Key())
+		require.Equal(t, expected.Value(), actual.Value())
+		actual.Next()
+	}
+	require.False(t, actual.Valid())
+	require.NoError(t, actual.Error())
+}
+
+func TestStoreMatchesSource(t *testing.T) {
+	source, data := newSource(t, 500)
+	store, err := readonly.NewStore(data)
+	require.NoError(t, err)
+
+	entries := 0
+	iter := source.Iterator(nil, nil)
+	for ; iter.Valid(); iter.Next() {
+		require.Equal(t, iter.Value(), store.Get(iter.Key()))
+		require.True(t, store.Has(iter.Key()))
+		entries++
+	}
+	iter.Close()
+	require.Equal(t, entries, store.Len())
+	require.Nil(t, store.Get([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}))
+	require.False(t, store.Has([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}))
+
+	// returned values are copies
+	key := source.Iterator(nil, nil).Key()
+	value := store.Get(key)
+	value[0] = 'x'
+	require.Equal(t, source.Get(key), store.Get(key))
+
+	rng := rand.New(rand.NewSource(2))
+	bound := func() []byte {
+		if rng.Intn(5) == 0 {
+			return nil
+		}
+		b := make([]byte, 1+rng.Intn(2))
+		rng.Read(b)
+		return b
+	}
+	for i := 0; i < 200; i++ {
+		start, end := bound(), bound()
+		requireSameIteration(t, source.Iterator(start, end), store.Iterator(start, end))
+		requireSameIteration(t, source.ReverseIterator(start, end), store.ReverseIterator(start, end))
+	}
+
+	require.Panics(t, func() { store.Set([]byte("key"), []byte("value")) })
+	require.Panics(t, func() { store.Delete([]byte("key")) })
+}
+
+func TestOpen(t *testing.T) {
+	source, data := newSource(t, 100)
+	path := filepath.Join(t.TempDir(), "snapshot")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	store, err := readonly.Open(path)
+	require.NoError(t, err)
+	requireSameIteration(t, source.Iterator(nil, nil), store.Iterator(nil, nil))
+
+	// values stay valid once the mapping is gone
+	key := source.Iterator(nil, nil).Key()
+	value := store.Get(key)
+	require.NoError(t, store.Close())
+	require.NoError(t, store.Close())
+	require.Equal(t, source.Get(key), value)
+
+	_, err = readonly.Open(filepath.Join(t.TempDir(), "missing"))
+	require.Error(t, err)
+}
+
+func TestEmptySnapshot(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, readonly.Export(&buf, dbadapter.Store{DB: dbm.NewMemDB()}.Iterator(nil, nil)))
+	store, err := readonly.NewStore(buf.Bytes())
+	require.NoError(t, err)
+	require.Zero(t, store.Len())
+	require.Nil(t, store.Get([]byte("key")))
+	require.False(t, store.Iterator(nil, nil).Valid())
+	require.False(t, store.ReverseIterator(nil, nil).Valid())
+}
+
+func TestCorruptSnapshot(t *testing.T) {
+	_, data := newSource(t, 10)
+	for _, corrupt := range [][]byte{
+		nil,
+		data[:len(data)-1],
+		data[1:],
+		append(append([]byte{}, data[:len(data)-20]...), data[len(data)-19:]...),
+	} {
+		_, err := readonly.NewStore(corrupt)
+		require.ErrorIs(t, err, readonly.ErrCorruptSnapshot)
+	}
+}
+
+func TestReplayWithScheduler(t *testing.T) {
+	key := sdk.NewKVStoreKey("bank")
+	source := dbadapter.Store{DB: dbm.NewMemDB()}
+	source.Set([]byte("total"), []byte{0})
+	var buf bytes.Buffer
+	require.NoError(t, readonly.Export(&buf, source.Iterator(nil, nil)))
+	store, err := readonly.NewStore(buf.Bytes())
+	require.NoError(t, err)
+
+	// every tx bumps the total and records its own key
+	deliverTx := func(ctx sdk.Context, req abci.RequestDeliverTx) abci.ResponseDeliverTx {
+		kv := ctx.MultiStore().GetKVStore(key)
+		total := kv.Get([]byte("total"))
+		kv.Set([]byte("total"), []byte{total[0] + 1})
+		kv.Set([]byte(fmt.Sprintf("tx-%d", ctx.TxIndex())), req.Tx)
+		return abci.ResponseDeliverTx{}
+	}
+	var reqs []*sdk.DeliverTxEntry
+	for i := 0; i < 20; i++ {
+		reqs = append(reqs, &sdk.DeliverTxEntry{Request: abci.RequestDeliverTx{Tx: []byte(fmt.Sprintf("%d", i))}})
+	}
+
+	cms := readonly.NewCacheMultiStore(map[types.StoreKey]*readonly.Store{key: store})
+	ctx := sdk.Context{}.WithContext(context.Background()).WithMultiStore(cms).WithLogger(log.NewNopLogger())
+	tr := trace.NewNoopTracerProvider().Tracer("readonly-test")
+	_, err = tasks.NewScheduler(4, &tracing.Info{Tracer: &tr}, deliverTx).ProcessAll(ctx, reqs)
+	require.NoError(t, err)
+
+	kv := cms.GetKVStore(key)
+	require.Equal(t, []byte{20}, kv.Get([]byte("total")))
+	require.Equal(t, []byte("7"), kv.Get([]byte("tx-7")))
+	// the snapshot is untouched
+	require.Equal(t, []byte{0}, store.Get([]byte("total")))
+}
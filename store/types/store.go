@@ -255,6 +255,15 @@ type KVStore interface {
 	GetWorkingHash() ([]byte, error)
 }
 
+// BatchKVStore is a KVStore that can group writes into a batch, to apply many writes at once more cheaply than one
+// Set or Delete at a time.
+type BatchKVStore interface {
+	KVStore
+
+	// NewBatch returns a batch of writes to the store, applied once written
+	NewBatch() dbm.Batch
+}
+
 // Iterator is an alias db's Iterator for convenience.
 type Iterator = dbm.Iterator
 
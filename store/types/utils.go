@@ -95,3 +95,11 @@ func PrefixEndBytes(prefix []byte) []byte {
 func InclusiveEndBytes(inclusiveBytes []byte) []byte {
 	return append(inclusiveBytes, byte(0x00))
 }
+
+// CopyBytes copies a value while preserving nil (deleted / missing) values
+func CopyBytes(bz []byte) []byte {
+	if bz == nil {
+		return nil
+	}
+	return append([]byte{}, bz...)
+}
@@ -87,3 +87,15 @@ func TestInclusiveEndBytes(t *testing.T) {
 	bs := []byte("test")
 	require.True(t, bytes.Equal(append(bs, byte(0x00)), types.InclusiveEndBytes(bs)))
 }
+
+func TestCopyBytes(t *testing.T) {
+	t.Parallel()
+	require.Nil(t, types.CopyBytes(nil))
+	require.NotNil(t, types.CopyBytes([]byte{}))
+	require.Empty(t, types.CopyBytes([]byte{}))
+	bs := []byte("test")
+	bsCopy := types.CopyBytes(bs)
+	require.Equal(t, bs, bsCopy)
+	bsCopy[0] = 'b'
+	require.Equal(t, []byte("test"), bs)
+}
@@ -0,0 +1,101 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/tendermint/tendermint/abci/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// maxAbortsPerStore is the number of aborts each store of a task can send during an execution. A store normally sends a
+// single abort, since its operations panic with the first abort of the execution from then on, but a tx may recover the
+// abort's panic, and the stores of a tx accessing them concurrently may abort at once. Aborts beyond the buffer are
+// dropped by the stores.
+const maxAbortsPerStore = 4
+
+// newAbortChannel returns the channel the given number of stores of an execution send their aborts to
+func newAbortChannel(stores int) chan occ.Abort {
+	return make(chan occ.Abort, stores*maxAbortsPerStore)
+}
+
+// collectAborts closes the abort channel of an execution and returns the aborts sent to it, keeping the abort with the
+// lowest dependent index of each store. They're ordered by dependent index and then by store key, so that the first
+// one, which the task is aborted with, doesn't depend on the order in which concurrently aborting stores sent them.
+func collectAborts(abortCh chan occ.Abort) []occ.Abort {
+	close(abortCh)
+	byStore := make(map[string]int)
+	var aborts []occ.Abort
+	for abort := range abortCh {
+		if i, ok := byStore[abort.StoreKey]; ok {
+			if abort.DependentTxIdx < aborts[i].DependentTxIdx {
+				aborts[i] = abort
+			}
+			continue
+		}
+		byStore[abort.StoreKey] = len(aborts)
+		aborts = append(aborts, abort)
+	}
+	sort.Slice(aborts, func(i, j int) bool {
+		if aborts[i].DependentTxIdx != aborts[j].DependentTxIdx {
+			return aborts[i].DependentTxIdx < aborts[j].DependentTxIdx
+		}
+		return aborts[i].StoreKey < aborts[j].StoreKey
+	})
+	return aborts
+}
+
+// abortDependencies returns the distinct dependent indices of aborts, in order
+func abortDependencies(aborts []occ.Abort) []int {
+	deps := make([]int, 0, len(aborts))
+	for _, abort := range aborts {
+		if len(deps) == 0 || deps[len(deps)-1] != abort.DependentTxIdx {
+			deps = append(deps, abort.DependentTxIdx)
+		}
+	}
+	return deps
+}
+
+// classifyAbort refines the reason of an abort sent by a version store with how the tx's execution ended. The store
+// only sees the read that triggered the abort, while the response shows whether the tx ran out of gas unwinding from
+// the abort panic, or recovered the panic itself and carried on.
+func classifyAbort(abort occ.Abort, resp types.ResponseDeliverTx) occ.Abort {
+	switch {
+	case abort.Reason == occ.AbortReasonInjected:
+		// the execution itself went through, the abort was forced on it
+		return abort
+	case isResponseError(resp, sdkerrors.ErrOCCAbort):
+		return abort
+	case isResponseError(resp, sdkerrors.ErrOutOfGas):
+		return abort.WithReason(occ.AbortReasonGasExhaustion)
+	default:
+		return abort.WithReason(occ.AbortReasonPanicRecovered)
+	}
+}
+
+func isResponseError(resp types.ResponseDeliverTx, err *sdkerrors.Error) bool {
+	return resp.Codespace == err.Codespace() && resp.Code == err.ABCICode()
+}
+
+// recordAbort surfaces an abort in the block's metrics, telemetry and the execution's trace span, to help track down
+// conflict hot spots
+func (s *scheduler) recordAbort(span trace.Span, abort occ.Abort) {
+	s.metrics.recordAbortReason(abort.Reason)
+	telemetry.IncrCounterWithLabels(
+		[]string{"scheduler", "abort", "reasons"},
+		1,
+		[]metrics.Label{telemetry.NewLabel("reason", abort.Reason.String()), telemetry.NewLabel("store", abort.StoreKey)},
+	)
+	span.SetAttributes(
+		attribute.String("abortReason", abort.Reason.String()),
+		attribute.String("abortStore", abort.StoreKey),
+		attribute.String("abortKey", fmt.Sprintf("%X", abort.Key)),
+		attribute.Int("abortDependentTxIdx", abort.DependentTxIdx),
+	)
+}
@@ -0,0 +1,160 @@
+package tasks
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestClassifyAbort(t *testing.T) {
+	abort := occ.NewEstimateAbort(1, "bank", []byte("key"))
+	for _, tc := range []struct {
+		name     string
+		resp     types.ResponseDeliverTx
+		expected occ.AbortReason
+	}{
+		{"occ abort", sdkerrors.ResponseDeliverTx(sdkerrors.ErrOCCAbort, 0, 0, false), occ.AbortReasonEstimateRead},
+		{"out of gas", sdkerrors.ResponseDeliverTx(sdkerrors.ErrOutOfGas, 0, 0, false), occ.AbortReasonGasExhaustion},
+		{"other error", sdkerrors.ResponseDeliverTx(sdkerrors.ErrInsufficientFunds, 0, 0, false), occ.AbortReasonPanicRecovered},
+		{"success", types.ResponseDeliverTx{}, occ.AbortReasonPanicRecovered},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			classified := classifyAbort(abort, tc.resp)
+			require.Equal(t, tc.expected, classified.Reason)
+			require.Equal(t, "bank", classified.StoreKey)
+			require.Equal(t, []byte("key"), classified.Key)
+		})
+	}
+}
+
+func TestCollectAborts(t *testing.T) {
+	sent := []occ.Abort{
+		occ.NewEstimateAbort(5, "bank", []byte("balance")),
+		occ.NewIteratorConflictAbort(3, "wasm", []byte("state")),
+		occ.NewEstimateAbort(2, "bank", []byte("supply")),
+		occ.NewSynchronizedStoreAbort(3, "evm"),
+		occ.NewEstimateAbort(4, "wasm", []byte("code")),
+	}
+	expected := []occ.Abort{sent[2], sent[3], sent[1]}
+
+	// whatever order the stores sent their aborts in, the lowest dependent index of each store is kept, in order
+	for shift := range sent {
+		abortCh := newAbortChannel(3)
+		for i := range sent {
+			abortCh <- sent[(i+shift)%len(sent)]
+		}
+		aborts := collectAborts(abortCh)
+		require.Equal(t, expected, aborts)
+		require.Equal(t, []int{2, 3}, abortDependencies(aborts))
+	}
+
+	// an execution that didn't abort has no aborts
+	require.Empty(t, collectAborts(newAbortChannel(3)))
+	require.Empty(t, abortDependencies(nil))
+}
+
+func TestProcessAllRecordsAbortReasons(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// tx 0 only writes the key once tx 1 has read its prefilled estimate, so tx 1 is guaranteed to abort once
+	var once sync.Once
+	estimateRead := make(chan struct{})
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(occ.Abort); !ok {
+					panic(r)
+				}
+				once.Do(func() { close(estimateRead) })
+				response = sdkerrors.ResponseDeliverTx(sdkerrors.ErrOCCAbort, 0, 0, false)
+			}
+		}()
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if ctx.TxIndex() == 0 {
+			<-estimateRead
+			kv.Set(itemKey, []byte("value"))
+			return types.ResponseDeliverTx{}
+		}
+		return types.ResponseDeliverTx{Info: string(kv.Get(itemKey))}
+	}
+
+	reqs := requestList(2)
+	reqs[0].EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(itemKey): nil}}
+
+	s := NewScheduler(2, ti, deliverTx, WithSmallBlockThreshold(0))
+	res, err := s.ProcessAll(initTestCtx(true), reqs)
+	require.NoError(t, err)
+	require.Equal(t, "value", res[1].Info)
+
+	m := s.Metrics()
+	require.Equal(t, 1, m.Aborts)
+	require.Equal(t, map[string]int{"estimate_read": 1}, m.AbortReasons)
+}
+
+func TestProcessAllStopsRecoveredAborts(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// tx 1 recovers the abort of reading tx 0's prefilled estimate and carries on writing, which it can't get far with
+	var once sync.Once
+	estimateRead := make(chan struct{})
+	var writesAfterAbort int64
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		// aborts that aren't recovered by the tx fail it like runTx does
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(occ.Abort); !ok {
+					panic(r)
+				}
+				response = sdkerrors.ResponseDeliverTx(sdkerrors.ErrOCCAbort, 0, 0, false)
+			}
+		}()
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if ctx.TxIndex() == 0 {
+			<-estimateRead
+			kv.Set(itemKey, []byte("value"))
+			return types.ResponseDeliverTx{}
+		}
+		var value []byte
+		aborted := false
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					aborted = true
+					once.Do(func() { close(estimateRead) })
+				}
+			}()
+			value = kv.Get(itemKey)
+		}()
+		for i := 0; i < 100; i++ {
+			kv.Set([]byte(fmt.Sprintf("key-%d", i)), value)
+			if aborted {
+				atomic.AddInt64(&writesAfterAbort, 1)
+			}
+		}
+		return types.ResponseDeliverTx{Info: string(value)}
+	}
+
+	reqs := requestList(2)
+	reqs[0].EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(itemKey): nil}}
+
+	s := NewScheduler(2, ti, deliverTx, WithSmallBlockThreshold(0))
+	ctx := initTestCtx(true)
+	res, err := s.ProcessAll(ctx, reqs)
+	require.NoError(t, err)
+	require.Equal(t, "value", res[1].Info)
+	require.Equal(t, []byte("value"), ctx.MultiStore().GetKVStore(testStoreKey).Get([]byte("key-99")))
+
+	// the first write after the abort panicked with it again, so the abort is taken as is
+	require.Zero(t, atomic.LoadInt64(&writesAfterAbort))
+	m := s.Metrics()
+	require.Equal(t, 1, m.Aborts)
+	require.Equal(t, map[string]int{"estimate_read": 1}, m.AbortReasons)
+}
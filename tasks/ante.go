@@ -0,0 +1,88 @@
+package tasks
+
+import (
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// AnteFunc runs the order-dependent pre-checks of a tx against ctx, eg. signature verification and sequence number
+// checks. It returns the response of the pre-checks, and false if the tx failed them, in which case the response is
+// final and the body of the tx isn't executed.
+type AnteFunc func(ctx sdk.Context, req types.RequestDeliverTx) (types.ResponseDeliverTx, bool)
+
+// anteResult is the outcome of the pre-checks of a tx run by the serial ante phase
+type anteResult struct {
+	response types.ResponseDeliverTx
+	passed   bool
+}
+
+// WithSerialAnte splits the processing of a block in two phases: the pre-checks of every tx are run with ante first,
+// serially in tx order against the block's stores, and the bodies of the txs that passed them are then executed in
+// parallel by deliverTx, which must then skip the pre-checks. Pre-checks touch per-account keys, such as sequence
+// numbers, that make otherwise independent txs of the same account conflict, and running them serially keeps those
+// conflicts out of the parallel phase.
+//
+// The bodies see the state written by the pre-checks of every tx of the block, so the pre-checks must only depend on
+// state the bodies don't write for the block to have the same outcome as running every tx's pre-checks right before
+// its body. The response of a tx merges the events, gas used and gas wanted of its pre-checks into those of its body.
+// Txs appended to the block with AppendTasks run their pre-checks as part of their execution instead, since the block's
+// stores can't be written while bodies execute.
+func WithSerialAnte(ante AnteFunc) SchedulerOption {
+	return func(s *scheduler) { s.ante = ante }
+}
+
+// runSerialAnte runs the pre-checks of the tasks of a block in tx order, writing the state of the txs that pass them
+// to the block's stores
+func (s *scheduler) runSerialAnte(ctx sdk.Context, tasks []*deliverTxTask) {
+	if s.ante == nil {
+		return
+	}
+	ctx, span := s.traceSpan(ctx, "SchedulerSerialAnte", nil)
+	defer span.End()
+	for _, task := range tasks {
+		anteCtx, write := ctx.WithTxIndex(task.Index).CacheContext()
+		response, passed := s.ante(anteCtx, task.Request)
+		if passed {
+			write()
+		} else {
+			s.metrics.anteRejections++
+		}
+		task.Ante = &anteResult{response: response, passed: passed}
+	}
+}
+
+// deliverTxBody executes a task, which only runs the body of its tx if its pre-checks were split out with
+// WithSerialAnte
+func (s *scheduler) deliverTxBody(task *deliverTxTask) types.ResponseDeliverTx {
+	if s.ante == nil {
+		return s.deliverTx(task.Ctx, task.Request)
+	}
+	ante := task.Ante
+	if ante == nil {
+		// the pre-checks of appended txs weren't run serially, so they're executed along with the body
+		anteCtx, write := task.Ctx.CacheContext()
+		response, passed := s.ante(anteCtx, task.Request)
+		if passed {
+			write()
+		}
+		ante = &anteResult{response: response, passed: passed}
+	}
+	if !ante.passed {
+		return ante.response
+	}
+	return mergeAnteResponse(ante.response, s.deliverTx(task.Ctx, task.Request))
+}
+
+// mergeAnteResponse merges the response of the pre-checks of a tx into the response of its body: the events of the
+// pre-checks come first, gas used adds up and gas wanted is the larger of both
+func mergeAnteResponse(ante, body types.ResponseDeliverTx) types.ResponseDeliverTx {
+	if len(ante.Events) > 0 {
+		body.Events = append(append([]types.Event{}, ante.Events...), body.Events...)
+	}
+	body.GasUsed += ante.GasUsed
+	if ante.GasWanted > body.GasWanted {
+		body.GasWanted = ante.GasWanted
+	}
+	return body
+}
@@ -0,0 +1,85 @@
+package tasks
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// sequenceKey is the sequence number of the account of a tx, shared by every tx of the account
+func sequenceKey(txIndex int) []byte {
+	return []byte(fmt.Sprintf("sequence-%d", txIndex%2))
+}
+
+// testAnte increments the sequence of the account of a tx, rejecting every fifth tx
+func testAnte(ctx sdk.Context, req types.RequestDeliverTx) (types.ResponseDeliverTx, bool) {
+	if ctx.TxIndex()%5 == 4 {
+		return sdkerrors.ResponseDeliverTx(sdkerrors.ErrUnauthorized, 3, 0, false), false
+	}
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	sequence, _ := strconv.Atoi(string(kv.Get(sequenceKey(ctx.TxIndex()))))
+	kv.Set(sequenceKey(ctx.TxIndex()), []byte(strconv.Itoa(sequence+1)))
+	return types.ResponseDeliverTx{
+		GasUsed:   5,
+		GasWanted: 100,
+		Events:    []types.Event{{Type: "ante"}},
+	}, true
+}
+
+func TestProcessAllSerialAnte(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	var s Scheduler
+	var once sync.Once
+	// the body of every tx writes a key of its own, and tx 3 appends two more txs to the block
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		if ctx.TxIndex() == 3 {
+			once.Do(func() {
+				_, err := s.(TaskAppender).AppendTasks(requestList(12)[10:]...)
+				require.NoError(t, err)
+			})
+		}
+		ctx.MultiStore().GetKVStore(testStoreKey).Set(req.Tx, req.Tx)
+		return types.ResponseDeliverTx{GasUsed: 10, Events: []types.Event{{Type: "body"}}}
+	}
+
+	const txs = 10
+	s = NewScheduler(10, ti, deliverTx, WithSerialAnte(testAnte), WithAppendableTasks())
+	ctx := initTestCtx(true)
+	res, err := s.ProcessAll(ctx, requestList(txs))
+	require.NoError(t, err)
+	require.Len(t, res, txs+2)
+
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	for i, r := range res {
+		key := []byte(fmt.Sprintf("%d", i))
+		if i%5 == 4 {
+			// rejected txs only have the response of their pre-checks, and their body isn't executed
+			require.Equal(t, sdkerrors.ErrUnauthorized.ABCICode(), r.Code)
+			require.Nil(t, kv.Get(key))
+			continue
+		}
+		require.Zero(t, r.Code)
+		require.Equal(t, int64(15), r.GasUsed)
+		require.Equal(t, int64(100), r.GasWanted)
+		require.Equal(t, []types.Event{{Type: "ante"}, {Type: "body"}}, r.Events)
+		require.Equal(t, key, kv.Get(key))
+	}
+	// the sequences of both accounts were incremented by every tx that passed its pre-checks, including the appended
+	// ones, which ran them during their execution
+	require.Equal(t, []byte("5"), kv.Get(sequenceKey(0)))
+	require.Equal(t, []byte("5"), kv.Get(sequenceKey(1)))
+	require.Equal(t, 2, s.Metrics().AnteRejections)
+	require.Zero(t, s.Metrics().Aborts)
+
+	_, err = VerifySequential(initTestCtx(true), requestList(txs), 10, ti, deliverTx, WithSerialAnte(testAnte))
+	require.NoError(t, err)
+}
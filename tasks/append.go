@@ -0,0 +1,89 @@
+package tasks
+
+import (
+	"errors"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+var (
+	// ErrAppendNotEnabled is returned when appending tasks to a scheduler without the capability
+	ErrAppendNotEnabled = errors.New("appending tasks is not enabled for this scheduler")
+	// ErrNoBlockInProgress is returned when appending tasks while no ProcessAll is in progress
+	ErrNoBlockInProgress = errors.New("no block in progress to append tasks to")
+)
+
+// TaskAppender is implemented by schedulers that can append tasks to an in-flight block
+type TaskAppender interface {
+	// AppendTasks appends requests to the block being processed by an in-progress ProcessAll, eg. txs injected by
+	// the proposer, and returns the tx index of the first one. Appended txs are ordered after every existing tx,
+	// and their responses are returned by ProcessAll after those of the original requests.
+	AppendTasks(reqs ...*sdk.DeliverTxEntry) (int, error)
+}
+
+var _ TaskAppender = (*scheduler)(nil)
+
+// WithAppendableTasks enables appending tasks to an in-flight block with AppendTasks. It's an explicit capability
+// since appended txs change the size of the block from under the caller of ProcessAll.
+func WithAppendableTasks() SchedulerOption {
+	return func(s *scheduler) { s.appendEnabled = true }
+}
+
+// AppendTasks implements TaskAppender. The tasks are picked up at the start of the next execute/validate round.
+func (s *scheduler) AppendTasks(reqs ...*sdk.DeliverTxEntry) (int, error) {
+	if !s.appendEnabled {
+		return 0, ErrAppendNotEnabled
+	}
+	s.appendMx.Lock()
+	defer s.appendMx.Unlock()
+	if !s.acceptingAppends {
+		return 0, ErrNoBlockInProgress
+	}
+	startIdx := s.blockTxs + len(s.appendQueue)
+	s.appendQueue = append(s.appendQueue, reqs...)
+	return startIdx, nil
+}
+
+// startAppends starts accepting appended tasks for a block of n txs
+func (s *scheduler) startAppends(n int) {
+	s.appendMx.Lock()
+	defer s.appendMx.Unlock()
+	s.acceptingAppends = s.appendEnabled
+	s.blockTxs = n
+	s.appendQueue = nil
+}
+
+// stopAppends stops accepting appended tasks if none are queued, returning true if the block is complete
+func (s *scheduler) stopAppends() bool {
+	s.appendMx.Lock()
+	defer s.appendMx.Unlock()
+	if len(s.appendQueue) > 0 {
+		return false
+	}
+	s.acceptingAppends = false
+	return true
+}
+
+// takeAppended turns the queued requests into tasks after the existing ones, prefilling their estimates. It must
+// only be called between rounds, while no tasks are executing or validating.
+func (s *scheduler) takeAppended(tasks []*deliverTxTask) []*deliverTxTask {
+	s.appendMx.Lock()
+	reqs := s.appendQueue
+	s.appendQueue = nil
+	s.blockTxs += len(reqs)
+	s.appendMx.Unlock()
+	if len(reqs) == 0 {
+		return nil
+	}
+
+	startIdx := len(tasks)
+	s.prefillEstimatesAt(startIdx, reqs)
+	s.recordAuditHashes(reqs)
+	appended := toTasks(reqs)
+	for i, t := range appended {
+		t.Index = startIdx + i
+	}
+	s.markSequentialOnly(reqs, appended)
+	s.markDuplicates(appended)
+	return appended
+}
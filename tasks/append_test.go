@@ -0,0 +1,63 @@
+package tasks
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestAppendTasks(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	var s Scheduler
+	var once sync.Once
+	var appendedIdx int
+	var appendErr error
+	// every tx reads the shared key, appends its index and writes it back, and tx 3 appends two more txs to the block
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		if ctx.TxIndex() == 3 {
+			once.Do(func() {
+				appendedIdx, appendErr = s.(TaskAppender).AppendTasks(requestList(12)[10:]...)
+			})
+		}
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		newVal := val + fmt.Sprintf("%d", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		kv.Set(req.Tx, []byte(newVal))
+		return types.ResponseDeliverTx{
+			Info: newVal,
+		}
+	}
+
+	s = NewScheduler(5, ti, deliverTx, WithAppendableTasks())
+	ctx := initTestCtx(true)
+	res, err := s.ProcessAll(ctx, requestList(10))
+	require.NoError(t, err)
+	require.NoError(t, appendErr)
+	require.Equal(t, 10, appendedIdx)
+
+	// appended txs are ordered after the original ones
+	require.Len(t, res, 12)
+	expected := ""
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	for idx, response := range res {
+		expected += fmt.Sprintf("%d", idx)
+		require.Equal(t, expected, response.Info)
+		require.Equal(t, []byte(expected), kv.Get([]byte(fmt.Sprintf("%d", idx))))
+	}
+	require.Equal(t, 12, s.Metrics().Txs)
+
+	// appends are only accepted while a block is in progress
+	_, err = s.(TaskAppender).AppendTasks(requestList(1)...)
+	require.ErrorIs(t, err, ErrNoBlockInProgress)
+
+	_, err = NewScheduler(5, ti, deliverTx).(TaskAppender).AppendTasks(requestList(1)...)
+	require.ErrorIs(t, err, ErrAppendNotEnabled)
+}
@@ -0,0 +1,53 @@
+package tasks
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ErrResponseAudit is returned by ProcessAll when the responses don't line up with the requests
+var ErrResponseAudit = errors.New("occ scheduler responses don't line up with requests")
+
+// WithResponseAudit enables a final audit in ProcessAll that the responses line up 1:1 with the requests, by comparing
+// the tx hash of every request to that of the task whose response is returned at its index. It guards against index
+// bookkeeping bugs silently returning responses in the wrong order, at the cost of hashing every tx twice, so it's
+// meant as a debug flag rather than for production nodes.
+func WithResponseAudit() SchedulerOption {
+	return func(s *scheduler) { s.responseAudit = true }
+}
+
+// recordAuditHashes records the tx hashes of requests added to the block, in order, if responses are audited
+func (s *scheduler) recordAuditHashes(reqs []*sdk.DeliverTxEntry) {
+	if !s.responseAudit {
+		return
+	}
+	for _, req := range reqs {
+		s.auditHashes = append(s.auditHashes, sha256.Sum256(req.Request.Tx))
+	}
+}
+
+// auditResponses checks that every task is at its own index, has a response, and was built from the request at that
+// index, if responses are audited
+func (s *scheduler) auditResponses(tasks []*deliverTxTask) error {
+	if !s.responseAudit {
+		return nil
+	}
+	if len(tasks) != len(s.auditHashes) {
+		return fmt.Errorf("%w: %d responses for %d requests", ErrResponseAudit, len(tasks), len(s.auditHashes))
+	}
+	for i, t := range tasks {
+		if t.Index != i {
+			return fmt.Errorf("%w: task at index %d has tx index %d", ErrResponseAudit, i, t.Index)
+		}
+		if t.Response == nil {
+			return fmt.Errorf("%w: task %d has no response", ErrResponseAudit, i)
+		}
+		if sha256.Sum256(t.Request.Tx) != s.auditHashes[i] {
+			return fmt.Errorf("%w: task %d tx hash %X doesn't match request hash %X", ErrResponseAudit, i, sha256.Sum256(t.Request.Tx), s.auditHashes[i])
+		}
+	}
+	return nil
+}
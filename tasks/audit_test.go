@@ -0,0 +1,94 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllResponseAudit(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx reads and writes the shared key, so txs conflict and are re-executed
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		kv.Set(itemKey, append(kv.Get(itemKey), req.Tx...))
+		return types.ResponseDeliverTx{Info: string(req.Tx)}
+	}
+
+	for _, smallBlockThreshold := range []int{0, defaultSmallBlockThreshold} {
+		t.Run(fmt.Sprintf("small block threshold %d", smallBlockThreshold), func(t *testing.T) {
+			s := NewScheduler(10, ti, deliverTx, WithResponseAudit(), WithSmallBlockThreshold(smallBlockThreshold))
+			for _, txs := range []int{0, 1, 5, 50} {
+				res, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+				require.NoError(t, err)
+				require.Len(t, res, txs)
+				for idx, response := range res {
+					require.Equal(t, fmt.Sprintf("%d", idx), response.Info)
+				}
+			}
+		})
+	}
+}
+
+func TestAuditResponses(t *testing.T) {
+	newTasks := func() []*deliverTxTask {
+		tasks := toTasks(requestList(3))
+		for _, task := range tasks {
+			task.Response = &types.ResponseDeliverTx{}
+		}
+		return tasks
+	}
+	s := &scheduler{responseAudit: true}
+	s.recordAuditHashes(requestList(3))
+	require.NoError(t, s.auditResponses(newTasks()))
+
+	for _, tc := range []struct {
+		name  string
+		tasks func() []*deliverTxTask
+	}{
+		{
+			name:  "missing response",
+			tasks: func() []*deliverTxTask { return newTasks()[:2] },
+		},
+		{
+			name: "swapped tasks",
+			tasks: func() []*deliverTxTask {
+				tasks := newTasks()
+				tasks[0], tasks[1] = tasks[1], tasks[0]
+				return tasks
+			},
+		},
+		{
+			name: "wrong request",
+			tasks: func() []*deliverTxTask {
+				tasks := newTasks()
+				tasks[2].Request.Tx = []byte("other")
+				return tasks
+			},
+		},
+		{
+			name: "nil response",
+			tasks: func() []*deliverTxTask {
+				tasks := newTasks()
+				tasks[1].Response = nil
+				return tasks
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.ErrorIs(t, s.auditResponses(tc.tasks()), ErrResponseAudit)
+		})
+	}
+
+	// without the debug flag nothing is audited
+	s = &scheduler{}
+	s.recordAuditHashes(requestList(3))
+	require.Empty(t, s.auditHashes)
+	require.NoError(t, s.auditResponses(newTasks()[:1]))
+}
@@ -0,0 +1,124 @@
+package tasks
+
+import (
+	"fmt"
+
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/utils/tracing"
+)
+
+// BlockHook is a module hook run at the start or end of a block, eg. the BeginBlock or EndBlock of a module, along with
+// the store keys it declares to access
+type BlockHook struct {
+	// Name identifies the hook, eg. by the name of its module
+	Name string
+	// ReadStores and WriteStores are the store keys the hook reads and writes. A hook that declares neither is assumed
+	// to access every store.
+	ReadStores  []sdk.StoreKey
+	WriteStores []sdk.StoreKey
+	// Run runs the hook, emitting its events to the event manager of ctx
+	Run func(ctx sdk.Context)
+}
+
+// declared returns whether the hook declares the store keys it accesses
+func (h BlockHook) declared() bool {
+	return len(h.ReadStores) > 0 || len(h.WriteStores) > 0
+}
+
+// HookScheduler runs the module hooks of a block with the same multiversion stores and validation as the txs of a
+// block, so that hooks accessing disjoint stores run concurrently while the result is the same as running them one
+// after another in order.
+type HookScheduler interface {
+	// RunHooks runs the hooks as if in order, writing their state to the multistore of ctx, and returns the events
+	// they emitted, in hook order. A hook that panics fails on its own, with its writes discarded, and RunHooks returns
+	// an error naming it once the other hooks are done, which callers should treat like the panic of a sequential hook.
+	// Hooks run in a single RunHooks call at a time.
+	RunHooks(ctx sdk.Context, hooks []BlockHook) ([]types.Event, error)
+}
+
+type hookScheduler struct {
+	scheduler *scheduler
+	hooks     []BlockHook
+}
+
+// NewHookScheduler creates a scheduler for module hooks, executing them with the given number of workers and the
+// options of a tx scheduler. The first round is planned from the store keys the hooks declare: a hook runs after the
+// hooks before it that write a store it declares, and a hook without declared store keys is a barrier between the
+// hooks before and after it. The declarations only order the first round, so a hook accessing stores it didn't declare
+// is re-executed on validation like a conflicting tx, which costs time but not correctness.
+func NewHookScheduler(workers int, tracingInfo *tracing.Info, opts ...SchedulerOption) HookScheduler {
+	h := &hookScheduler{}
+	// the hooks of a block are few, so they'd always take the small block path, which runs them one after another
+	opts = append([]SchedulerOption{WithSmallBlockThreshold(0)}, opts...)
+	h.scheduler = NewScheduler(workers, tracingInfo, h.runHook, opts...).(*scheduler)
+	h.scheduler.dependencyPlanning = true
+	h.scheduler.planner = func([]*sdk.DeliverTxEntry) [][]int {
+		return planHookWaves(h.hooks)
+	}
+	return h
+}
+
+// RunHooks implements HookScheduler.
+func (h *hookScheduler) RunHooks(ctx sdk.Context, hooks []BlockHook) ([]types.Event, error) {
+	h.hooks = hooks
+	defer func() { h.hooks = nil }()
+
+	reqs := make([]*sdk.DeliverTxEntry, 0, len(hooks))
+	for _, hook := range hooks {
+		reqs = append(reqs, &sdk.DeliverTxEntry{Request: types.RequestDeliverTx{Tx: []byte(hook.Name)}})
+	}
+	res, err := h.scheduler.ProcessAll(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+	var events []types.Event
+	for i, r := range res {
+		if !r.IsOK() {
+			return nil, fmt.Errorf("block hook %q failed: %s", hooks[i].Name, r.Log)
+		}
+		events = append(events, r.Events...)
+	}
+	return events, nil
+}
+
+// runHook runs the hook at the index of ctx as a tx of the scheduler, responding with its events
+func (h *hookScheduler) runHook(ctx sdk.Context, _ types.RequestDeliverTx) types.ResponseDeliverTx {
+	ctx = ctx.WithEventManager(sdk.NewEventManager())
+	h.hooks[ctx.TxIndex()].Run(ctx)
+	return types.ResponseDeliverTx{Events: ctx.EventManager().ABCIEvents()}
+}
+
+// planHookWaves groups hooks into topological waves of hook indices, like planWaves does for txs with the store keys
+// the hooks declare: a hook runs in a wave after the latest earlier hook writing a store it reads or writes, and after
+// the latest hook without declared store keys, which runs in a wave after every hook before it
+func planHookWaves(hooks []BlockHook) [][]int {
+	lastWriters := make(map[sdk.StoreKey]int)
+	hookWaves := make([]int, len(hooks))
+	barrier := -1
+	var waves [][]int
+	for idx, hook := range hooks {
+		wave := barrier + 1
+		if !hook.declared() {
+			wave = len(waves)
+			barrier = wave
+		}
+		for _, keys := range [][]sdk.StoreKey{hook.ReadStores, hook.WriteStores} {
+			for _, key := range keys {
+				if writer, ok := lastWriters[key]; ok && hookWaves[writer] >= wave {
+					wave = hookWaves[writer] + 1
+				}
+			}
+		}
+		for _, key := range hook.WriteStores {
+			lastWriters[key] = idx
+		}
+		hookWaves[idx] = wave
+		if wave == len(waves) {
+			waves = append(waves, nil)
+		}
+		waves[wave] = append(waves[wave], idx)
+	}
+	return waves
+}
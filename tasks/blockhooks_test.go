@@ -0,0 +1,113 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/log"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/cachekv"
+	"github.com/cosmos/cosmos-sdk/store/cachemulti"
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestPlanHookWaves(t *testing.T) {
+	a, b, c := sdk.NewKVStoreKey("a"), sdk.NewKVStoreKey("b"), sdk.NewKVStoreKey("c")
+	hooks := []BlockHook{
+		{Name: "0", WriteStores: []sdk.StoreKey{a}},
+		{Name: "1", WriteStores: []sdk.StoreKey{b}},
+		// reads what 0 writes
+		{Name: "2", ReadStores: []sdk.StoreKey{a}, WriteStores: []sdk.StoreKey{c}},
+		// only reads what 2 reads, and writes what 1 writes
+		{Name: "3", ReadStores: []sdk.StoreKey{a}, WriteStores: []sdk.StoreKey{b}},
+		// undeclared
+		{Name: "4"},
+		{Name: "5", WriteStores: []sdk.StoreKey{c}},
+		{Name: "6", ReadStores: []sdk.StoreKey{b}},
+	}
+	require.Equal(t, [][]int{{0, 1}, {2, 3}, {4}, {5, 6}}, planHookWaves(hooks))
+	require.Nil(t, planHookWaves(nil))
+}
+
+func TestHookSchedulerRunHooks(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	storeKeys := []sdk.StoreKey{sdk.NewKVStoreKey("a"), sdk.NewKVStoreKey("b"), sdk.NewKVStoreKey("c")}
+	newCtx := func() sdk.Context {
+		keys := make(map[string]sdk.StoreKey)
+		stores := make(map[sdk.StoreKey]sdk.CacheWrapper)
+		for _, key := range storeKeys {
+			stores[key] = cachekv.NewStore(dbadapter.Store{DB: dbm.NewMemDB()}, key, 1000)
+			keys[key.Name()] = key
+		}
+		store := cachemulti.NewStore(dbm.NewMemDB(), stores, keys, nil, nil, nil)
+		return sdk.Context{}.WithContext(context.Background()).WithMultiStore(&store).WithLogger(log.NewNopLogger())
+	}
+	counter := func(ctx sdk.Context, key sdk.StoreKey) int {
+		n, _ := strconv.Atoi(string(ctx.MultiStore().GetKVStore(key).Get(itemKey)))
+		return n
+	}
+	// every hook adds to the counter of the store it writes what it reads from the counters of the stores it reads,
+	// plus one, and emits an event with its name
+	newHooks := func(declare bool) []BlockHook {
+		var hooks []BlockHook
+		add := func(name string, reads []sdk.StoreKey, write sdk.StoreKey) {
+			hook := BlockHook{Name: name, Run: func(ctx sdk.Context) {
+				n := counter(ctx, write) + 1
+				for _, key := range reads {
+					n += counter(ctx, key)
+				}
+				ctx.MultiStore().GetKVStore(write).Set(itemKey, []byte(strconv.Itoa(n)))
+				ctx.EventManager().EmitEvent(sdk.NewEvent("hook", sdk.NewAttribute("name", name)))
+			}}
+			if declare {
+				hook.ReadStores = reads
+				hook.WriteStores = []sdk.StoreKey{write}
+			}
+			hooks = append(hooks, hook)
+		}
+		a, b, c := storeKeys[0], storeKeys[1], storeKeys[2]
+		add("h0", nil, a)
+		add("h1", nil, b)
+		add("h2", []sdk.StoreKey{a}, c)
+		add("h3", []sdk.StoreKey{c, b}, a)
+		add("h4", nil, b)
+		add("h5", []sdk.StoreKey{a, b, c}, c)
+		return hooks
+	}
+	sequential := func() []int {
+		ctx := newCtx()
+		for _, hook := range newHooks(false) {
+			hook.Run(ctx.WithEventManager(sdk.NewEventManager()))
+		}
+		return []int{counter(ctx, storeKeys[0]), counter(ctx, storeKeys[1]), counter(ctx, storeKeys[2])}
+	}()
+
+	for _, declare := range []bool{true, false} {
+		t.Run(fmt.Sprintf("declared %t", declare), func(t *testing.T) {
+			s := NewHookScheduler(4, ti)
+			for i := 0; i < 3; i++ {
+				ctx := newCtx()
+				events, err := s.RunHooks(ctx, newHooks(declare))
+				require.NoError(t, err)
+				require.Equal(t, sequential, []int{counter(ctx, storeKeys[0]), counter(ctx, storeKeys[1]), counter(ctx, storeKeys[2])})
+				require.Len(t, events, 6)
+				for j, event := range events {
+					require.Equal(t, fmt.Sprintf("h%d", j), string(event.Attributes[0].Value))
+				}
+			}
+		})
+	}
+
+	t.Run("failing hook", func(t *testing.T) {
+		hooks := newHooks(true)
+		hooks[2].Run = func(ctx sdk.Context) { panic("hook failed") }
+		_, err := NewHookScheduler(4, ti).RunHooks(newCtx(), hooks)
+		require.ErrorContains(t, err, `block hook "h2" failed`)
+	})
+}
@@ -0,0 +1,88 @@
+package tasks
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllCacheWrappedStores(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx increments a shared counter in a branch of its store, like a sub-message, and every third tx fails
+	// it, discarding the branch after having read the counter
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		branch := ctx.MultiStore().GetKVStore(testStoreKey).CacheWrap(testStoreKey).(sdk.CacheKVStore)
+		count, _ := strconv.Atoi(string(branch.Get(itemKey)))
+		branch.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		if ctx.TxIndex()%3 == 2 {
+			return types.ResponseDeliverTx{Code: 1, Data: []byte(strconv.Itoa(count))}
+		}
+		branch.Write()
+		return types.ResponseDeliverTx{Data: []byte(strconv.Itoa(count))}
+	}
+
+	const txs = 30
+	for _, workers := range []int{1, 10} {
+		ctx := initTestCtx(true)
+		res, err := VerifySequential(ctx, requestList(txs), workers, ti, deliverTx)
+		require.NoError(t, err)
+
+		// every tx observes the increments of the earlier successful txs
+		for idx, response := range res {
+			require.Equal(t, strconv.Itoa(idx-idx/3), string(response.Data))
+		}
+	}
+}
+
+func TestProcessAllNestedCacheContexts(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx dispatches a sub-message in a cache context, which dispatches a nested sub-message incrementing a shared
+	// counter. The nested sub-message only succeeds if the counter was even, and is rolled back otherwise, in which case
+	// the outer sub-message increments the counter itself with the value read by the rolled back one, so that read must
+	// still be validated.
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		subCtx, writeSub := ctx.CacheContext()
+		nestedCtx, writeNested := subCtx.CacheContext()
+		nested := nestedCtx.MultiStore().GetKVStore(testStoreKey)
+		count, _ := strconv.Atoi(string(nested.Get(itemKey)))
+		time.Sleep(100 * time.Microsecond)
+		nested.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		sub := subCtx.MultiStore().GetKVStore(testStoreKey)
+		outcome := "rolled back"
+		if count%2 == 0 {
+			writeNested()
+			outcome = "succeeded"
+		} else {
+			sub.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		}
+		sub.Set(req.Tx, []byte(outcome))
+		writeSub()
+		return types.ResponseDeliverTx{Info: outcome, Data: []byte(strconv.Itoa(count))}
+	}
+
+	const txs = 20
+	for _, workers := range []int{1, 10} {
+		ctx := initTestCtx(true)
+		res, err := VerifySequential(ctx, requestList(txs), workers, ti, deliverTx)
+		require.NoError(t, err)
+
+		// every tx increments the counter once, so the nested sub-messages alternate
+		for idx, response := range res {
+			expected := "succeeded"
+			if idx%2 == 1 {
+				expected = "rolled back"
+			}
+			require.Equal(t, expected, response.Info)
+			require.Equal(t, strconv.Itoa(idx), string(response.Data))
+		}
+	}
+}
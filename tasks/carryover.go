@@ -0,0 +1,133 @@
+package tasks
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// carriedWritesets are the keys a tx wrote in the last block it was processed in, and the height of that block
+type carriedWritesets struct {
+	height    int64
+	writesets map[sdk.StoreKey][]string
+}
+
+// EstimateCache carries the writesets txs were learned to write over to later blocks, keyed by tx hash, so that txs
+// that are re-proposed after their block failed to be finalized (eg. in a later round or at the next height) start
+// out with estimates of their writes rather than having to learn their conflicts again. Since txs of finalized blocks
+// aren't proposed again, entries expire once they're more than maxAge heights old. It's safe for concurrent use, and
+// meant to be shared by the schedulers of consecutive blocks.
+type EstimateCache struct {
+	mx      sync.Mutex
+	maxAge  int64
+	entries map[[sha256.Size]byte]carriedWritesets
+}
+
+// NewEstimateCache creates a cache whose entries expire once they're more than maxAge heights old
+func NewEstimateCache(maxAge int64) *EstimateCache {
+	return &EstimateCache{
+		maxAge:  maxAge,
+		entries: make(map[[sha256.Size]byte]carriedWritesets),
+	}
+}
+
+// WithEstimateCarryover has the scheduler record the final writesets of the txs of every block it processes in the
+// cache, and prefill the writesets recorded for the txs of a block as estimates, unless their requests have estimated
+// writesets of their own. Carried over writesets are only used as estimates, so they don't declare the writes of txs
+// in strict mode nor make a block eligible for the happy path.
+func WithEstimateCarryover(cache *EstimateCache) SchedulerOption {
+	return func(s *scheduler) { s.estimateCache = cache }
+}
+
+// Len returns the number of txs with writesets in the cache
+func (c *EstimateCache) Len() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return len(c.entries)
+}
+
+// expired returns whether an entry recorded at a height is expired at the given height
+func (c *EstimateCache) expired(entry carriedWritesets, height int64) bool {
+	return height-entry.height > c.maxAge
+}
+
+// get returns the writesets recorded for a tx that haven't expired at the given height
+func (c *EstimateCache) get(hash [sha256.Size]byte, height int64) (map[sdk.StoreKey][]string, bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	entry, ok := c.entries[hash]
+	if !ok || c.expired(entry, height) {
+		return nil, false
+	}
+	return entry.writesets, true
+}
+
+// record records the writesets of the txs of a block at the given height, replacing those of earlier blocks, and
+// expires the entries that are too old
+func (c *EstimateCache) record(height int64, writesets map[[sha256.Size]byte]map[sdk.StoreKey][]string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for hash, entry := range c.entries {
+		if c.expired(entry, height) {
+			delete(c.entries, hash)
+		}
+	}
+	for hash, ws := range writesets {
+		c.entries[hash] = carriedWritesets{height: height, writesets: ws}
+	}
+}
+
+// prefillCarriedEstimates prefills the writesets carried over for the txs of the block as estimates, for the requests
+// without estimated writesets of their own, and returns the indices of the txs it prefilled
+func (s *scheduler) prefillCarriedEstimates(ctx sdk.Context, reqs []*sdk.DeliverTxEntry) map[int]struct{} {
+	if s.estimateCache == nil {
+		return nil
+	}
+	carried := make(map[int]struct{})
+	for i, req := range reqs {
+		if len(req.EstimatedWritesets) > 0 {
+			continue
+		}
+		writesets, ok := s.estimateCache.get(sha256.Sum256(req.Request.Tx), ctx.BlockHeight())
+		if !ok {
+			continue
+		}
+		for storeKey, keys := range writesets {
+			mvs, ok := s.multiVersionStores[storeKey]
+			if !ok {
+				continue
+			}
+			estimate := make(multiversion.WriteSet, len(keys))
+			for _, key := range keys {
+				estimate[key] = nil
+			}
+			mvs.SetEstimatedWriteset(i, occ.PrefillIncarnation, estimate)
+		}
+		carried[i] = struct{}{}
+		s.metrics.carriedEstimates++
+	}
+	return carried
+}
+
+// recordCarriedEstimates records the keys written by the final execution of every tx of the block in the cache
+func (s *scheduler) recordCarriedEstimates(ctx sdk.Context, tasks []*deliverTxTask) {
+	if s.estimateCache == nil {
+		return
+	}
+	writesets := make(map[[sha256.Size]byte]map[sdk.StoreKey][]string, len(tasks))
+	for _, t := range tasks {
+		ws := make(map[sdk.StoreKey][]string)
+		for _, mv := range s.orderedStores {
+			if keys := mv.store.GetWritesetKeys(t.Index); len(keys) > 0 {
+				ws[mv.key] = keys
+			}
+		}
+		if len(ws) > 0 {
+			writesets[sha256.Sum256(t.Request.Tx)] = ws
+		}
+	}
+	s.estimateCache.record(ctx.BlockHeight(), writesets)
+}
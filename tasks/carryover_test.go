@@ -0,0 +1,90 @@
+package tasks
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestEstimateCache(t *testing.T) {
+	cache := NewEstimateCache(2)
+	first, second := sha256.Sum256([]byte("first")), sha256.Sum256([]byte("second"))
+	writesets := map[sdk.StoreKey][]string{testStoreKey: {"key"}}
+	cache.record(10, map[[sha256.Size]byte]map[sdk.StoreKey][]string{first: writesets})
+	require.Equal(t, 1, cache.Len())
+
+	// entries are found until they're more than max age heights old
+	for height := int64(10); height <= 12; height++ {
+		found, ok := cache.get(first, height)
+		require.True(t, ok)
+		require.Equal(t, writesets, found)
+	}
+	_, ok := cache.get(first, 13)
+	require.False(t, ok)
+	_, ok = cache.get(second, 10)
+	require.False(t, ok)
+
+	// expired entries are dropped when later blocks are recorded
+	cache.record(13, map[[sha256.Size]byte]map[sdk.StoreKey][]string{second: writesets})
+	require.Equal(t, 1, cache.Len())
+	_, ok = cache.get(second, 13)
+	require.True(t, ok)
+}
+
+func TestProcessAllEstimateCarryover(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the shared key, and odd txs also write a key of their own once they're re-proposed
+	reproposed := false
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		if reproposed && ctx.TxIndex()%2 == 1 {
+			kv.Set(req.Tx, req.Tx)
+		}
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	const txs = 20
+	cache := NewEstimateCache(1)
+	opts := []SchedulerOption{WithEstimateCarryover(cache), WithStrictWritesets()}
+	res, err := VerifySequential(initTestCtx(true).WithBlockHeight(5), requestList(txs), 10, ti, deliverTx, opts...)
+	require.NoError(t, err)
+	require.Len(t, res, txs)
+	require.Equal(t, txs, cache.Len())
+
+	// the txs are re-proposed at the next height, where every tx without estimates of its own starts out with the
+	// writesets it was learned to write. Carried over writesets aren't declared, so the new writes don't fail in strict
+	// mode.
+	reproposed = true
+	reqs := requestList(txs)
+	reqs[0].EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(itemKey): nil}}
+	s := NewScheduler(10, ti, deliverTx, opts...)
+	ctx := initTestCtx(true).WithBlockHeight(6)
+	res, err = s.ProcessAll(ctx, reqs)
+	require.NoError(t, err)
+	require.Equal(t, txs-1, s.Metrics().CarriedEstimates)
+	expected := ""
+	for idx, response := range res {
+		require.Zero(t, response.Code)
+		expected += fmt.Sprintf("%d,", idx)
+		require.Equal(t, expected, response.Info)
+	}
+	require.Equal(t, []byte("1"), ctx.MultiStore().GetKVStore(testStoreKey).Get([]byte("1")))
+
+	// the new writes are carried over in turn, while the writesets of the first block expire
+	found, ok := cache.get(sha256.Sum256([]byte("1")), 7)
+	require.True(t, ok)
+	require.Equal(t, map[sdk.StoreKey][]string{testStoreKey: {"1", string(itemKey)}}, found)
+	s = NewScheduler(10, ti, deliverTx, opts...)
+	_, err = s.ProcessAll(initTestCtx(true).WithBlockHeight(8), requestList(txs))
+	require.NoError(t, err)
+	require.Zero(t, s.Metrics().CarriedEstimates)
+}
@@ -0,0 +1,35 @@
+package tasks
+
+import (
+	"github.com/sei-protocol/sei-db/proto"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ChangeSetExporter receives the final writes of a block to its multiversion stores, as changesets named after their
+// store keys, sorted by name, with the writes of each sorted by key. Stores the block didn't write to are omitted.
+type ChangeSetExporter func(height int64, changeSets []*proto.NamedChangeSet)
+
+// WithChangeSetExporter has the scheduler export the final writes of every block to the exporter once they were
+// written to the block's stores, eg. for a commit pipeline to apply them to the commitment stores and compute the
+// working hash in the background, overlapping state commitment with the execution of the next block. The exporter
+// runs synchronously before ProcessAll returns, so it should hand the changesets off rather than apply them itself.
+// The values are shared with the block's stores and must not be modified. Stores that aren't wrapped in multiversion
+// stores, see WithStoreStrategy, aren't exported, and neither are simulated blocks.
+func WithChangeSetExporter(exporter ChangeSetExporter) SchedulerOption {
+	return func(s *scheduler) { s.changeSetExporter = exporter }
+}
+
+// exportChangeSets exports the final writes of the block to the changeset exporter, if any
+func (s *scheduler) exportChangeSets(ctx sdk.Context) {
+	if s.changeSetExporter == nil || s.simulation != nil {
+		return
+	}
+	var changeSets []*proto.NamedChangeSet
+	for _, mv := range s.orderedStores {
+		if changeSet := mv.store.ChangeSet(); len(changeSet.Pairs) > 0 {
+			changeSets = append(changeSets, &proto.NamedChangeSet{Name: mv.key.Name(), Changeset: changeSet})
+		}
+	}
+	s.changeSetExporter(ctx.BlockHeight(), changeSets)
+}
@@ -0,0 +1,67 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/sei-protocol/sei-db/proto"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllChangeSetExporter(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the shared key and records its own key, and every third tx deletes the key of the
+	// tx before it
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		kv.Set(itemKey, []byte(val+fmt.Sprintf("%d,", ctx.TxIndex())))
+		kv.Set([]byte(fmt.Sprintf("tx-%02d", ctx.TxIndex())), req.Tx)
+		if ctx.TxIndex()%3 == 2 {
+			kv.Delete([]byte(fmt.Sprintf("tx-%02d", ctx.TxIndex()-1)))
+		}
+		return types.ResponseDeliverTx{}
+	}
+
+	var exported [][]*proto.NamedChangeSet
+	var heights []int64
+	exporter := func(height int64, changeSets []*proto.NamedChangeSet) {
+		heights = append(heights, height)
+		exported = append(exported, changeSets)
+	}
+	const txs = 20
+	s := NewScheduler(10, ti, deliverTx, WithChangeSetExporter(exporter))
+	ctx := initTestCtx(true).WithBlockHeight(7)
+	_, err := s.ProcessAll(ctx, requestList(txs))
+	require.NoError(t, err)
+
+	// only the store the block wrote to is exported, with the final write of every key, sorted by key
+	require.Equal(t, []int64{7}, heights)
+	require.Len(t, exported[0], 1)
+	require.Equal(t, testStoreKey.Name(), exported[0][0].Name)
+	pairs := exported[0][0].Changeset.Pairs
+	require.Len(t, pairs, txs+1)
+	require.True(t, sort.SliceIsSorted(pairs, func(i, j int) bool { return string(pairs[i].Key) < string(pairs[j].Key) }))
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	deletes := 0
+	for _, pair := range pairs {
+		if pair.Delete {
+			deletes++
+			require.False(t, kv.Has(pair.Key))
+			continue
+		}
+		require.Equal(t, kv.Get(pair.Key), pair.Value)
+	}
+	require.Equal(t, txs/3, deletes)
+
+	// simulated blocks aren't exported
+	_, err = s.SimulateBlock(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	require.Len(t, exported, 1)
+}
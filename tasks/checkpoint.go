@@ -0,0 +1,93 @@
+package tasks
+
+import (
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// schedulerCheckpoint is a consistent snapshot of the scheduler bookkeeping, taken after a validation round
+type schedulerCheckpoint struct {
+	// validated is the number of leading tasks that were validated
+	validated int
+	// incarnations contains the incarnation of every task
+	incarnations []int
+}
+
+func isKnownStatus(st status) bool {
+	switch st {
+	case statusPending, statusExecuted, statusAborted, statusValidated, statusWaiting:
+		return true
+	}
+	return false
+}
+
+// checkpoint records the current scheduler bookkeeping as the last consistent state
+func (s *scheduler) checkpoint() {
+	validated, _ := s.findFirstNonValidated()
+	if allValidated(s.allTasks) {
+		validated = len(s.allTasks)
+	}
+	incarnations := make([]int, 0, len(s.allTasks))
+	for _, t := range s.allTasks {
+		incarnations = append(incarnations, t.Incarnation)
+	}
+	s.lastCheckpoint = &schedulerCheckpoint{
+		validated:    validated,
+		incarnations: incarnations,
+	}
+}
+
+// checkInvariants returns an error if the scheduler bookkeeping is inconsistent with the last checkpoint
+func (s *scheduler) checkInvariants() error {
+	for i, t := range s.allTasks {
+		st := t.LoadStatus()
+		if !isKnownStatus(st) {
+			return fmt.Errorf("task %d has unknown status %q", i, st)
+		}
+		if st == statusValidated && t.Response == nil {
+			return fmt.Errorf("task %d is validated without a response", i)
+		}
+		// tasks appended since the checkpoint have no checkpointed incarnation
+		if s.lastCheckpoint != nil && i < len(s.lastCheckpoint.incarnations) && t.Incarnation < s.lastCheckpoint.incarnations[i] {
+			return fmt.Errorf("task %d incarnation %d is lower than checkpointed incarnation %d", i, t.Incarnation, s.lastCheckpoint.incarnations[i])
+		}
+	}
+	return nil
+}
+
+// rollback restores the scheduler bookkeeping to the last checkpoint after an invariant violation. Tasks that were
+// validated at the checkpoint (and still have their checkpointed incarnation and response) stay validated, and every
+// later task is invalidated and reset so that it's re-run sequentially. It returns the tasks to execute next.
+func (s *scheduler) rollback(ctx sdk.Context, cause error) []*deliverTxTask {
+	ctx.Logger().Error("occ scheduler invariant violation, rolling back to last checkpoint", "height", ctx.BlockHeight(), "err", cause)
+	telemetry.IncrCounter(1, "scheduler", "rollbacks")
+
+	cp := s.lastCheckpoint
+	if cp == nil {
+		cp = &schedulerCheckpoint{}
+	}
+	restored := 0
+	for i, t := range s.allTasks {
+		if i == restored && i < cp.validated && t.Incarnation == cp.incarnations[i] && t.Response != nil {
+			t.restoreStatus(statusValidated)
+			restored++
+			continue
+		}
+		s.invalidateTask(t)
+		// the status may be the violated invariant, so it's restored rather than transitioned
+		t.restoreStatus(statusPending)
+		if i < len(cp.incarnations) && t.Incarnation < cp.incarnations[i] {
+			t.Incarnation = cp.incarnations[i]
+		}
+		t.Reset()
+		t.Increment()
+		if t.Incarnation > s.maxIncarnation {
+			s.maxIncarnation = t.Incarnation
+		}
+	}
+	s.recordFallback(ctx, FallbackRollback, cause)
+	s.synchronous = true
+	return s.allTasks[restored:]
+}
@@ -0,0 +1,24 @@
+package tasks
+
+import "time"
+
+// Clock is the source of time for all timing decisions in the scheduler (eg. durations and any backoff between
+// retries), so that scheduling behavior can be made reproducible in tests by injecting a fake clock
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type realClock struct{}
+
+var _ Clock = realClock{}
+
+// Now implements Clock.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep implements Clock.
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
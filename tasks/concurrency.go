@@ -0,0 +1,44 @@
+package tasks
+
+import "sync/atomic"
+
+// concurrencyTracker tracks the number of concurrently executing tasks within a block. Every time a task starts
+// executing, the instantaneous concurrency is sampled, which is used to derive the max and average concurrency.
+type concurrencyTracker struct {
+	active  int64
+	max     int64
+	samples int64
+	sum     int64
+}
+
+// start marks a task as executing and samples the concurrency
+func (c *concurrencyTracker) start() {
+	active := atomic.AddInt64(&c.active, 1)
+	atomic.AddInt64(&c.samples, 1)
+	atomic.AddInt64(&c.sum, active)
+	for {
+		max := atomic.LoadInt64(&c.max)
+		if active <= max || atomic.CompareAndSwapInt64(&c.max, max, active) {
+			return
+		}
+	}
+}
+
+// done marks a task as no longer executing
+func (c *concurrencyTracker) done() {
+	atomic.AddInt64(&c.active, -1)
+}
+
+// maxConcurrency returns the highest number of concurrently executing tasks sampled
+func (c *concurrencyTracker) maxConcurrency() int {
+	return int(atomic.LoadInt64(&c.max))
+}
+
+// avgConcurrency returns the average number of concurrently executing tasks sampled
+func (c *concurrencyTracker) avgConcurrency() float64 {
+	samples := atomic.LoadInt64(&c.samples)
+	if samples == 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&c.sum)) / float64(samples)
+}
@@ -0,0 +1,37 @@
+package tasks
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ConflictAnalysis summarizes the conflict graph of a block as estimated before executing it, from the same estimated
+// writesets (and readsets) that are prefilled as estimates and that WithDependencyPlanning plans the first round from
+type ConflictAnalysis struct {
+	// Txs is the number of txs of the block
+	Txs int
+	// Depth is the length of the longest chain of txs estimated to depend on each other, ie. the number of waves the
+	// block is planned in, which bounds how many txs can execute before the rest of the chain has to wait on them
+	Depth int
+	// Dependent is the number of txs estimated to depend on an earlier tx of the block
+	Dependent int
+}
+
+// SerialFraction returns the depth of the block relative to its number of txs, from close to 0 for a block whose txs
+// are independent to 1 for a block whose txs all depend on the tx before them, which would execute sequentially
+func (a ConflictAnalysis) SerialFraction() float64 {
+	if a.Txs == 0 {
+		return 0
+	}
+	return float64(a.Depth) / float64(a.Txs)
+}
+
+// AnalyzeConflicts analyzes the conflict graph of the requests of a block without executing them. Txs without
+// estimates are assumed not to conflict, so the analysis is only as good as the estimates.
+func AnalyzeConflicts(reqs []*sdk.DeliverTxEntry) ConflictAnalysis {
+	waves := planWaves(reqs)
+	analysis := ConflictAnalysis{Txs: len(reqs), Depth: len(waves)}
+	if len(waves) > 0 {
+		analysis.Dependent = len(reqs) - len(waves[0])
+	}
+	return analysis
+}
@@ -0,0 +1,28 @@
+package tasks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestAnalyzeConflicts(t *testing.T) {
+	// every tx writes the key written by the tx before it, so the block is a single chain
+	chain := requestList(4)
+	for _, req := range chain {
+		req.EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {"hot": nil}}
+	}
+	analysis := AnalyzeConflicts(chain)
+	require.Equal(t, ConflictAnalysis{Txs: 4, Depth: 4, Dependent: 3}, analysis)
+	require.Equal(t, 1.0, analysis.SerialFraction())
+
+	// txs without estimates are assumed independent
+	analysis = AnalyzeConflicts(requestList(4))
+	require.Equal(t, ConflictAnalysis{Txs: 4, Depth: 1}, analysis)
+	require.Equal(t, 0.25, analysis.SerialFraction())
+
+	require.Equal(t, ConflictAnalysis{}, AnalyzeConflicts(nil))
+	require.Zero(t, AnalyzeConflicts(nil).SerialFraction())
+}
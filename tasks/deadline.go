@@ -0,0 +1,47 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ErrInterrupted is returned by ProcessAll when the context of the block is done (eg. its deadline passed) before all
+// of its txs were validated, unless the scheduler falls back to sequential execution, see WithSequentialOnInterrupt
+var ErrInterrupted = errors.New("occ scheduler interrupted")
+
+// WithSequentialOnInterrupt makes the scheduler fall back to executing the remaining non-validated txs sequentially
+// once the context of the block is done, rather than failing the block with ErrInterrupted. The sequential execution
+// itself isn't interrupted, so the block always completes.
+func WithSequentialOnInterrupt() SchedulerOption {
+	return func(s *scheduler) { s.sequentialOnInterrupt = true }
+}
+
+// interrupted returns an ErrInterrupted error if the context of the block is done, unless the scheduler already fell
+// back to sequential execution because of it. The context is that of the sdk.Context passed to ProcessAll, so callers
+// bound the block with eg. ctx.WithContext(context.WithDeadline(...)).
+func (s *scheduler) interrupted() error {
+	if s.blockCtx == nil || (s.synchronous && s.sequentialOnInterrupt) {
+		return nil
+	}
+	if err := s.blockCtx.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInterrupted, err)
+	}
+	return nil
+}
+
+// handleInterrupt returns err, unless it's an ErrInterrupted error and the scheduler falls back to sequential execution,
+// in which case the remaining txs are executed synchronously from the next round on. Outstanding work of the
+// interrupted round is dropped, leaving its tasks to be re-executed.
+func (s *scheduler) handleInterrupt(ctx sdk.Context, err error) error {
+	if !s.sequentialOnInterrupt || !errors.Is(err, ErrInterrupted) {
+		return err
+	}
+	ctx.Logger().Info("occ scheduler interrupted, falling back to sequential execution", "height", ctx.BlockHeight(), "err", err)
+	telemetry.IncrCounter(1, "scheduler", "interrupt_fallbacks")
+	s.recordFallback(ctx, FallbackInterrupt, err)
+	s.synchronous = true
+	return nil
+}
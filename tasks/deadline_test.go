@@ -0,0 +1,126 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllInterrupted(t *testing.T) {
+	ti := newTestTracingInfo()
+	const txs = 20
+
+	for _, tc := range []struct {
+		name       string
+		opts       []SchedulerOption
+		happyPath  bool
+		sequential bool
+	}{
+		{name: "returns an error"},
+		{name: "falls back to sequential execution", opts: []SchedulerOption{WithSequentialOnInterrupt()}, sequential: true},
+		{name: "happy path returns an error", happyPath: true},
+		{name: "happy path falls back to sequential execution", opts: []SchedulerOption{WithSequentialOnInterrupt()}, happyPath: true, sequential: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			goroutines := runtime.NumGoroutine()
+			blockCtx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// every tx reads the shared key, appends its index and writes it back, or on the happy path writes a key of
+			// its own, and the block is canceled midway
+			txKey := func(idx int) []byte { return []byte(fmt.Sprintf("key%d", idx)) }
+			var executions int64
+			deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+				defer abortRecoveryFunc(&response)
+				if atomic.AddInt64(&executions, 1) == txs/2 {
+					cancel()
+				}
+				kv := ctx.MultiStore().GetKVStore(testStoreKey)
+				if tc.happyPath {
+					kv.Set(txKey(ctx.TxIndex()), []byte("value"))
+					return types.ResponseDeliverTx{}
+				}
+				newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d", ctx.TxIndex())
+				kv.Set(itemKey, []byte(newVal))
+				return types.ResponseDeliverTx{Info: newVal}
+			}
+
+			s := NewScheduler(4, ti, deliverTx, tc.opts...)
+			ctx := initTestCtx(true)
+			reqs := requestList(txs)
+			if tc.happyPath {
+				for idx, req := range reqs {
+					req.EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(txKey(idx)): nil}}
+					req.EstimateConfidence = sdk.EstimateConfidenceGuaranteed
+				}
+			}
+			res, err := s.ProcessAll(ctx.WithContext(blockCtx), reqs)
+			kv := ctx.MultiStore().GetKVStore(testStoreKey)
+
+			if !tc.sequential {
+				require.True(t, errors.Is(err, ErrInterrupted))
+				require.Nil(t, res)
+				// nothing is written to the parent stores
+				require.Nil(t, kv.Get(itemKey))
+				for idx := 0; idx < txs; idx++ {
+					require.Nil(t, kv.Get(txKey(idx)))
+				}
+			} else {
+				require.NoError(t, err)
+				require.Len(t, res, txs)
+				require.True(t, s.Metrics().Synchronous)
+				require.False(t, s.Metrics().HappyPath)
+				require.Equal(t, FallbackInterrupt, s.Metrics().Postmortem.Reason)
+				require.Contains(t, s.Metrics().Postmortem.Cause, ErrInterrupted.Error())
+				if tc.happyPath {
+					for idx := 0; idx < txs; idx++ {
+						require.Equal(t, []byte("value"), kv.Get(txKey(idx)))
+					}
+				} else {
+					expected := ""
+					for idx, response := range res {
+						expected = expected + fmt.Sprintf("%d", idx)
+						require.Equal(t, expected, response.Info)
+					}
+					require.Equal(t, expected, string(kv.Get(itemKey)))
+				}
+			}
+
+			// the worker pools are stopped once the block is done
+			// (polled inline, since require.Eventually runs the condition on goroutines of its own)
+			for deadline := time.Now().Add(time.Second); runtime.NumGoroutine() > goroutines && time.Now().Before(deadline); {
+				time.Sleep(10 * time.Millisecond)
+			}
+			require.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+		})
+	}
+}
+
+func TestProcessAllPastDeadline(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	var executions int64
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		atomic.AddInt64(&executions, 1)
+		return types.ResponseDeliverTx{}
+	}
+
+	blockCtx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	for _, txs := range []int{2, 20} {
+		s := NewScheduler(4, ti, deliverTx)
+		_, err := s.ProcessAll(initTestCtx(true).WithContext(blockCtx), requestList(txs))
+		require.True(t, errors.Is(err, ErrInterrupted))
+		require.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+	}
+	require.Zero(t, atomic.LoadInt64(&executions))
+}
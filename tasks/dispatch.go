@@ -0,0 +1,136 @@
+package tasks
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Dispatcher hands the work of a scheduler phase (execution or validation) to the workers serving it. A dispatcher is
+// created per phase and block, for a fixed number of workers.
+type Dispatcher interface {
+	// Dispatch queues work to be run by any of the workers. It may block while capacity items are queued.
+	Dispatch(work func(context.Context))
+	// Next blocks until there's work for a worker, returning false once ctx is done
+	Next(ctx context.Context, worker int) (func(context.Context), bool)
+}
+
+// NewDispatcherFunc creates the dispatcher of a phase, which may have up to capacity items queued at once and is served
+// by the given number of workers
+type NewDispatcherFunc func(capacity int, workers int) Dispatcher
+
+// WithDispatcher sets how the scheduler creates the dispatchers of its phases. Defaults to NewChannelDispatcher.
+func WithDispatcher(newDispatcher NewDispatcherFunc) SchedulerOption {
+	return func(s *scheduler) { s.newDispatcher = newDispatcher }
+}
+
+// channelDispatcher queues work in a single buffered channel shared by all workers
+type channelDispatcher struct {
+	ch chan func(context.Context)
+}
+
+// NewChannelDispatcher returns a dispatcher queuing work in a single buffered channel that every worker receives from.
+// Workers pick up work in the order it was dispatched as they become idle, which balances tasks of uneven duration
+// without any bookkeeping. Benchmarks on skewed task durations (see BenchmarkDispatch) show no gain from
+// NewWorkStealingDispatcher over it, so it's the default.
+func NewChannelDispatcher(capacity int, _ int) Dispatcher {
+	return &channelDispatcher{ch: make(chan func(context.Context), capacity)}
+}
+
+func (d *channelDispatcher) Dispatch(work func(context.Context)) {
+	d.ch <- work
+}
+
+func (d *channelDispatcher) Next(ctx context.Context, _ int) (func(context.Context), bool) {
+	select {
+	case <-ctx.Done():
+		return nil, false
+	case work := <-d.ch:
+		return work, true
+	}
+}
+
+// workStealingDispatcher queues work in a deque per worker, see NewWorkStealingDispatcher
+type workStealingDispatcher struct {
+	deques []workDeque
+	// one token per queued item, which a worker must take before taking an item from the deques
+	tokens chan struct{}
+	next   uint64 // only accessed atomically
+}
+
+// NewWorkStealingDispatcher returns a dispatcher queuing work round-robin in a deque per worker. Workers take work
+// from the front of their own deque, in the order it was dispatched, and steal from the back of the others' once it's
+// empty, so that workers stuck on long tasks don't hold back the work queued behind them.
+func NewWorkStealingDispatcher(capacity int, workers int) Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	return &workStealingDispatcher{
+		deques: make([]workDeque, workers),
+		tokens: make(chan struct{}, capacity),
+	}
+}
+
+func (d *workStealingDispatcher) Dispatch(work func(context.Context)) {
+	i := (atomic.AddUint64(&d.next, 1) - 1) % uint64(len(d.deques))
+	d.deques[i].pushBack(work)
+	d.tokens <- struct{}{}
+}
+
+func (d *workStealingDispatcher) Next(ctx context.Context, worker int) (func(context.Context), bool) {
+	select {
+	case <-ctx.Done():
+		return nil, false
+	case <-d.tokens:
+	}
+	own := worker % len(d.deques)
+	// the token guarantees an item is queued, but it may be dispatched to a deque that was already looked at
+	for {
+		if work := d.deques[own].popFront(); work != nil {
+			return work, true
+		}
+		for i := 1; i < len(d.deques); i++ {
+			if work := d.deques[(own+i)%len(d.deques)].popBack(); work != nil {
+				return work, true
+			}
+		}
+		runtime.Gosched()
+	}
+}
+
+// workDeque is a double-ended queue of work, which its worker pops from the front and others steal from the back of
+type workDeque struct {
+	mx    sync.Mutex
+	items []func(context.Context)
+}
+
+func (q *workDeque) pushBack(work func(context.Context)) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	q.items = append(q.items, work)
+}
+
+func (q *workDeque) popFront() func(context.Context) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if len(q.items) == 0 {
+		return nil
+	}
+	work := q.items[0]
+	q.items[0] = nil
+	q.items = q.items[1:]
+	return work
+}
+
+func (q *workDeque) popBack() func(context.Context) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if len(q.items) == 0 {
+		return nil
+	}
+	work := q.items[len(q.items)-1]
+	q.items[len(q.items)-1] = nil
+	q.items = q.items[:len(q.items)-1]
+	return work
+}
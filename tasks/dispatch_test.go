@@ -0,0 +1,146 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+var dispatchers = map[string]NewDispatcherFunc{
+	"channel":       NewChannelDispatcher,
+	"work stealing": NewWorkStealingDispatcher,
+}
+
+// runDispatched runs the given work through a dispatcher served by workers goroutines, returning once it's all done
+func runDispatched(tb testing.TB, newDispatcher NewDispatcherFunc, workers int, work []func()) {
+	pool := NewWorkerPool()
+	defer pool.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	var released sync.WaitGroup
+	defer released.Wait()
+	defer cancel()
+
+	d := newDispatcher(len(work), workers)
+	require.NoError(tb, pool.serve(ctx, d, workers, "test", &released))
+	var wg sync.WaitGroup
+	wg.Add(len(work))
+	for _, w := range work {
+		w := w
+		d.Dispatch(func(context.Context) {
+			defer wg.Done()
+			w()
+		})
+	}
+	wg.Wait()
+}
+
+func TestDispatchers(t *testing.T) {
+	for name, newDispatcher := range dispatchers {
+		t.Run(name, func(t *testing.T) {
+			for _, workers := range []int{1, 3, 16} {
+				runs := make([]int64, 1000)
+				work := make([]func(), len(runs))
+				for i := range work {
+					i := i
+					work[i] = func() { atomic.AddInt64(&runs[i], 1) }
+				}
+				runDispatched(t, newDispatcher, workers, work)
+				for i, r := range runs {
+					require.Equal(t, int64(1), r, "work %d with %d workers", i, workers)
+				}
+			}
+
+			// idle workers stop waiting for work once their context is done
+			d := newDispatcher(1, 2)
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, ok := d.Next(ctx, 1)
+			require.False(t, ok)
+		})
+	}
+}
+
+func TestProcessAllWorkStealingDispatcher(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx increments a shared counter, so that they all conflict
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		count, _ := strconv.Atoi(string(kv.Get(itemKey)))
+		kv.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		return types.ResponseDeliverTx{Data: []byte(strconv.Itoa(count))}
+	}
+
+	const txs = 50
+	s := NewScheduler(8, ti, deliverTx, WithDispatcher(NewWorkStealingDispatcher))
+	ctx := initTestCtx(true)
+	res, err := s.ProcessAll(ctx, requestList(txs))
+	require.NoError(t, err)
+	for idx, response := range res {
+		require.Equal(t, strconv.Itoa(idx), string(response.Data))
+	}
+	require.Equal(t, strconv.Itoa(txs), string(ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey)))
+}
+
+// spin busy-waits for d, like a tx keeping its worker busy
+func spin(d time.Duration) {
+	for start := time.Now(); time.Since(start) < d; {
+	}
+}
+
+// BenchmarkDispatch compares the dispatchers on blocks of 1000 tasks with skewed durations: mostly short tasks with a
+// few 50x longer ones, either all at the head or the tail of the block, or spread randomly.
+func BenchmarkDispatch(b *testing.B) {
+	const tasks = 1000
+	const short = 10 * time.Microsecond
+	const long = 50 * short
+	workloads := map[string]func(i int, rng *rand.Rand) time.Duration{
+		"uniform": func(int, *rand.Rand) time.Duration { return short },
+		"head-heavy": func(i int, _ *rand.Rand) time.Duration {
+			if i < tasks/20 {
+				return long
+			}
+			return short
+		},
+		"tail-heavy": func(i int, _ *rand.Rand) time.Duration {
+			if i >= tasks-tasks/20 {
+				return long
+			}
+			return short
+		},
+		"random-heavy": func(_ int, rng *rand.Rand) time.Duration {
+			if rng.Intn(20) == 0 {
+				return long
+			}
+			return short
+		},
+	}
+	for workload, duration := range workloads {
+		rng := rand.New(rand.NewSource(1))
+		work := make([]func(), tasks)
+		for i := range work {
+			d := duration(i, rng)
+			work[i] = func() { spin(d) }
+		}
+		for _, workers := range []int{4, 16} {
+			for name, newDispatcher := range dispatchers {
+				b.Run(fmt.Sprintf("%s/%d workers/%s", workload, workers, name), func(b *testing.B) {
+					for i := 0; i < b.N; i++ {
+						runDispatched(b, newDispatcher, workers, work)
+					}
+				})
+			}
+		}
+	}
+}
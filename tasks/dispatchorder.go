@@ -0,0 +1,88 @@
+package tasks
+
+import (
+	"math/rand"
+	"sort"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// DispatchOrder is the order the tasks of an execution round are dispatched to the workers in. With more tasks than
+// workers, it decides which tasks execute first, which changes how many executions abort or are invalidated, but never
+// the results of the block: every order is validated into the same state and responses as index order.
+type DispatchOrder int
+
+const (
+	// DispatchIndexOrder dispatches tasks in tx index order. This is the default.
+	DispatchIndexOrder DispatchOrder = iota
+	// DispatchGasDescending dispatches the tasks expected to use the most gas first, so that long executions don't
+	// start last and hold up the end of the round. A task is expected to use the gas its previous execution used, or
+	// the gas estimated for its request before its first execution.
+	DispatchGasDescending
+	// DispatchDependencyAware dispatches tasks by the wave WithDependencyPlanning would plan them in, so that the
+	// estimated writers of keys execute before the txs estimated to depend on them
+	DispatchDependencyAware
+	// DispatchShuffled dispatches tasks in a pseudo-random order seeded by WithDispatchSeed, eg. to check that the
+	// results of a workload don't depend on the dispatch order
+	DispatchShuffled
+)
+
+// WithDispatchOrder sets the order the tasks of an execution round are dispatched in. Sequential rounds always execute
+// in index order, since any other order only aborts.
+func WithDispatchOrder(order DispatchOrder) SchedulerOption {
+	return func(s *scheduler) { s.dispatchOrder = order }
+}
+
+// WithDispatchSeed seeds the pseudo-random order of DispatchShuffled. Every block is shuffled with the same seed, so
+// that a block is dispatched in the same order every time it's processed, as long as it executes in the same rounds.
+func WithDispatchSeed(seed int64) SchedulerOption {
+	return func(s *scheduler) { s.dispatchSeed = seed }
+}
+
+// startDispatchOrder prepares the dispatch order of a block
+func (s *scheduler) startDispatchOrder(reqs []*sdk.DeliverTxEntry) {
+	switch s.dispatchOrder {
+	case DispatchDependencyAware:
+		s.dispatchRanks = make([]int, len(reqs))
+		for wave, indices := range planWaves(reqs) {
+			for _, idx := range indices {
+				s.dispatchRanks[idx] = wave
+			}
+		}
+	case DispatchShuffled:
+		s.dispatchRand = rand.New(rand.NewSource(s.dispatchSeed))
+	}
+}
+
+// orderDispatch returns the tasks of an execution round in the order to dispatch them in, leaving tasks as is
+func (s *scheduler) orderDispatch(tasks []*deliverTxTask) []*deliverTxTask {
+	if s.dispatchOrder == DispatchIndexOrder || s.synchronous || len(tasks) < 2 {
+		return tasks
+	}
+	ordered := make([]*deliverTxTask, len(tasks))
+	copy(ordered, tasks)
+	switch s.dispatchOrder {
+	case DispatchGasDescending:
+		sort.SliceStable(ordered, func(i, j int) bool { return expectedGas(ordered[i]) > expectedGas(ordered[j]) })
+	case DispatchDependencyAware:
+		// tasks appended to the block after it started weren't planned, and are dispatched last
+		rank := func(t *deliverTxTask) int {
+			if t.Index < len(s.dispatchRanks) {
+				return s.dispatchRanks[t.Index]
+			}
+			return len(s.dispatchRanks)
+		}
+		sort.SliceStable(ordered, func(i, j int) bool { return rank(ordered[i]) < rank(ordered[j]) })
+	case DispatchShuffled:
+		s.dispatchRand.Shuffle(len(ordered), func(i, j int) { ordered[i], ordered[j] = ordered[j], ordered[i] })
+	}
+	return ordered
+}
+
+// expectedGas returns the gas the next execution of a task is expected to use
+func expectedGas(task *deliverTxTask) uint64 {
+	if task.Response != nil && task.Response.GasUsed > 0 {
+		return uint64(task.Response.GasUsed)
+	}
+	return task.EstimatedGas
+}
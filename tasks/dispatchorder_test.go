@@ -0,0 +1,113 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestOrderDispatch(t *testing.T) {
+	reqs := requestList(4)
+	for i, gas := range []uint64{10, 30, 0, 20} {
+		reqs[i].EstimatedGas = gas
+	}
+	// tx 2 depends on tx 0, and tx 3 on tx 2
+	reqs[0].EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {"a": nil}}
+	reqs[2].EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {"a": nil, "b": nil}}
+	reqs[3].EstimatedReadsets = sdk.MappedReadsets{testStoreKey: {"b": nil}}
+	tasks := toTasks(reqs)
+
+	indices := func(tasks []*deliverTxTask) []int {
+		res := make([]int, 0, len(tasks))
+		for _, task := range tasks {
+			res = append(res, task.Index)
+		}
+		return res
+	}
+	order := func(opts ...SchedulerOption) []int {
+		s := &scheduler{}
+		for _, opt := range opts {
+			opt(s)
+		}
+		s.startDispatchOrder(reqs)
+		return indices(s.orderDispatch(tasks))
+	}
+
+	require.Equal(t, []int{0, 1, 2, 3}, order())
+	require.Equal(t, []int{1, 3, 0, 2}, order(WithDispatchOrder(DispatchGasDescending)))
+	require.Equal(t, []int{0, 1, 2, 3}, order(WithDispatchOrder(DispatchDependencyAware)))
+	// the same seed shuffles the same way
+	shuffled := order(WithDispatchOrder(DispatchShuffled), WithDispatchSeed(7))
+	require.ElementsMatch(t, []int{0, 1, 2, 3}, shuffled)
+	require.Equal(t, shuffled, order(WithDispatchOrder(DispatchShuffled), WithDispatchSeed(7)))
+	// the tasks of the round are left in index order
+	require.Equal(t, []int{0, 1, 2, 3}, indices(tasks))
+
+	// the gas used by the previous execution takes precedence over the estimate
+	tasks[2].Response = &types.ResponseDeliverTx{GasUsed: 40}
+	require.Equal(t, []int{2, 1, 3, 0}, order(WithDispatchOrder(DispatchGasDescending)))
+
+	// the tx depending on tx 2 goes after the txs of the first wave, even if they're later in the block
+	require.Equal(t, []int{1, 2, 3}, indices((&scheduler{
+		dispatchOrder: DispatchDependencyAware,
+		dispatchRanks: []int{0, 0, 1, 2},
+	}).orderDispatch(tasks[1:])))
+
+	// sequential rounds are dispatched in index order
+	s := &scheduler{dispatchOrder: DispatchGasDescending, synchronous: true}
+	require.Equal(t, []int{0, 1, 2, 3}, indices(s.orderDispatch(tasks)))
+}
+
+func TestProcessAllDispatchOrderDeterminism(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every third tx appends to a shared key that every tx reads, and every tx writes a key of its own and emits an
+	// event with what it read, so that the responses depend on the order the writes are validated in
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		ctx = ctx.WithEventManager(sdk.NewEventManager())
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		if ctx.TxIndex()%3 == 0 {
+			kv.Set(itemKey, []byte(val+fmt.Sprintf("%d,", ctx.TxIndex())))
+		}
+		kv.Set(req.Tx, []byte(val))
+		ctx.EventManager().EmitEvent(sdk.NewEvent("read", sdk.NewAttribute("value", val)))
+		return types.ResponseDeliverTx{Info: val, GasUsed: int64(len(val)), Events: ctx.EventManager().ABCIEvents()}
+	}
+	reqs := func() []*sdk.DeliverTxEntry {
+		reqs := requestList(60)
+		for i, req := range reqs {
+			req.EstimatedGas = uint64(i % 7)
+			if i%3 == 0 {
+				req.EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(itemKey): nil}}
+			}
+		}
+		return reqs
+	}
+
+	var expected []types.ResponseDeliverTx
+	for _, tc := range []struct {
+		name  string
+		order DispatchOrder
+	}{
+		{"index order", DispatchIndexOrder},
+		{"gas descending", DispatchGasDescending},
+		{"dependency aware", DispatchDependencyAware},
+		{"shuffled", DispatchShuffled},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := VerifySequential(initTestCtx(true), reqs(), 4, ti, deliverTx,
+				WithDispatchOrder(tc.order), WithDispatchSeed(42))
+			require.NoError(t, err)
+			if expected == nil {
+				expected = res
+			}
+			require.Equal(t, expected, res)
+		})
+	}
+}
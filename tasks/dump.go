@@ -0,0 +1,195 @@
+package tasks
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// KVRecord is a single key and value in a dumped readset or writeset. A nil value is a delete (or a missing key).
+type KVRecord struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// StoreRecord is the final readset and writeset of a task against a single store, sorted by key
+type StoreRecord struct {
+	Readset  []KVRecord `json:"readset"`
+	Writeset []KVRecord `json:"writeset"`
+}
+
+// TaskRecord is the final state of a task in a block, as dumped in debug mode
+type TaskRecord struct {
+	Index       int `json:"index"`
+	Incarnation int `json:"incarnation"`
+	// Dependencies are the sorted indices of every tx the task ever conflicted with
+	Dependencies []int `json:"dependencies"`
+	// Stores are the task's final readsets and writesets by store key name
+	Stores map[string]StoreRecord `json:"stores"`
+	// Changes are the task's final writesets decoded into typed changes by store key name, if the scheduler has
+	// writeset decoders
+	Changes map[string][]sdk.WritesetChange `json:"changes,omitempty"`
+}
+
+// WithDebugDump enables a debug mode where the final readset, writeset, incarnation and conflict history of every
+// task is written to a JSONL file per block in the given directory, to help track down nondeterminism between
+// parallel and sequential execution. It isn't meant for production nodes.
+func WithDebugDump(dir string) SchedulerOption {
+	return func(s *scheduler) { s.debugDumpDir = dir }
+}
+
+// WithWritesetDecoders has the debug dump decode the final writesets of every task into typed changes of module
+// state with the given decoders, eg. balance changes in the bank store, alongside the raw writesets
+func WithWritesetDecoders(decoders *sdk.WritesetDecoderRegistry) SchedulerOption {
+	return func(s *scheduler) { s.writesetDecoders = decoders }
+}
+
+// blockDumpPath returns the path of the dump file for a block height
+func blockDumpPath(dir string, height int64) string {
+	return filepath.Join(dir, fmt.Sprintf("block-%d.jsonl", height))
+}
+
+// taskRecord builds the dump record for a task's final execution, decoding its writesets if there are decoders
+func taskRecord(task *deliverTxTask, decoders *sdk.WritesetDecoderRegistry) (TaskRecord, error) {
+	record := TaskRecord{
+		Index:        task.Index,
+		Incarnation:  task.Incarnation,
+		Dependencies: make([]int, 0, len(task.Dependencies)),
+		Stores:       make(map[string]StoreRecord, len(task.VersionStores)),
+	}
+	for dep := range task.Dependencies {
+		record.Dependencies = append(record.Dependencies, dep)
+	}
+	sort.Ints(record.Dependencies)
+	for storeKey, vs := range task.VersionStores {
+		var storeRecord StoreRecord
+		for key, values := range vs.GetReadset() {
+			for _, value := range values {
+				storeRecord.Readset = append(storeRecord.Readset, KVRecord{Key: []byte(key), Value: value})
+			}
+		}
+		for key, value := range vs.GetWriteset() {
+			storeRecord.Writeset = append(storeRecord.Writeset, KVRecord{Key: []byte(key), Value: value})
+		}
+		sortKVRecords(storeRecord.Readset)
+		sortKVRecords(storeRecord.Writeset)
+		record.Stores[storeKey.Name()] = storeRecord
+	}
+	if decoders != nil {
+		writesets := make(sdk.MappedWritesets, len(task.VersionStores))
+		for storeKey, vs := range task.VersionStores {
+			writesets[storeKey] = vs.GetWriteset()
+		}
+		changes, err := decoders.DecodeWritesets(writesets)
+		if err != nil {
+			return TaskRecord{}, err
+		}
+		record.Changes = changes
+	}
+	return record, nil
+}
+
+func sortKVRecords(records []KVRecord) {
+	sort.SliceStable(records, func(i, j int) bool {
+		if string(records[i].Key) != string(records[j].Key) {
+			return string(records[i].Key) < string(records[j].Key)
+		}
+		return string(records[i].Value) < string(records[j].Value)
+	})
+}
+
+// dumpBlock writes the records of all tasks in the block to the debug dump directory
+func (s *scheduler) dumpBlock(ctx sdk.Context, tasks []*deliverTxTask) {
+	if s.debugDumpDir == "" {
+		return
+	}
+	path := blockDumpPath(s.debugDumpDir, ctx.BlockHeight())
+	if err := writeBlockDump(path, tasks, s.writesetDecoders); err != nil {
+		ctx.Logger().Error("failed to write occ scheduler debug dump", "path", path, "err", err)
+	}
+}
+
+func writeBlockDump(path string, tasks []*deliverTxTask, decoders *sdk.WritesetDecoderRegistry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, t := range tasks {
+		record, err := taskRecord(t, decoders)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// LoadBlockDump loads the task records of a block dumped in debug mode, ordered by tx index
+func LoadBlockDump(path string) ([]TaskRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []TaskRecord
+	dec := json.NewDecoder(f)
+	for dec.More() {
+		var record TaskRecord
+		if err := dec.Decode(&record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Index < records[j].Index })
+	return records, nil
+}
+
+// NewReplayDeliverTx returns a deliverTx function that replays the recorded reads and then writes of each tx by its
+// index, so that a dumped block can be re-run deterministically through a scheduler, or sequentially, in tests
+// without the original txs. Store key names in the records are resolved with the given store keys.
+func NewReplayDeliverTx(records []TaskRecord, storeKeys map[string]sdk.StoreKey) func(ctx sdk.Context, req types.RequestDeliverTx) types.ResponseDeliverTx {
+	byIndex := make(map[int]TaskRecord, len(records))
+	for _, record := range records {
+		byIndex[record.Index] = record
+	}
+	return func(ctx sdk.Context, req types.RequestDeliverTx) types.ResponseDeliverTx {
+		record, ok := byIndex[ctx.TxIndex()]
+		if !ok {
+			panic(fmt.Sprintf("no recorded task for tx %d", ctx.TxIndex()))
+		}
+		names := make([]string, 0, len(record.Stores))
+		for name := range record.Stores {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			kv := ctx.MultiStore().GetKVStore(storeKeys[name])
+			for _, read := range record.Stores[name].Readset {
+				kv.Get(read.Key)
+			}
+			for _, write := range record.Stores[name].Writeset {
+				if write.Value == nil {
+					kv.Delete(write.Key)
+				} else {
+					kv.Set(write.Key, write.Value)
+				}
+			}
+		}
+		return types.ResponseDeliverTx{}
+	}
+}
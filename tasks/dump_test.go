@@ -0,0 +1,84 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllWithDebugDump(t *testing.T) {
+	ti := newTestTracingInfo()
+	dir := t.TempDir()
+
+	// every tx reads the shared key, appends its index and writes it back, and writes its own key
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		newVal := val + fmt.Sprintf("%d", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		kv.Set(req.Tx, []byte(newVal))
+		return types.ResponseDeliverTx{
+			Info: newVal,
+		}
+	}
+
+	// the shared key is decoded into a typed change, while the keys of the txs are unknown
+	decoders := sdk.NewWritesetDecoderRegistry()
+	decoders.Register(testStoreKey, sdk.WritesetDecoderFunc(func(key, value []byte) (sdk.WritesetChange, error) {
+		if string(key) != string(itemKey) {
+			return sdk.WritesetChange{Kind: sdk.WritesetChangeUnknown}, nil
+		}
+		return sdk.WritesetChange{Kind: "item", Value: string(value)}, nil
+	}))
+
+	s := NewScheduler(10, ti, deliverTx, WithDebugDump(dir), WithWritesetDecoders(decoders))
+	ctx := initTestCtx(true).WithBlockHeight(7)
+	_, err := s.ProcessAll(ctx, requestList(20))
+	require.NoError(t, err)
+
+	records, err := LoadBlockDump(blockDumpPath(dir, 7))
+	require.NoError(t, err)
+	require.Len(t, records, 20)
+	metrics := s.Metrics()
+	for idx, record := range records {
+		require.Equal(t, idx, record.Index)
+		require.Equal(t, metrics.Incarnations[idx], record.Incarnation)
+		for _, dep := range record.Dependencies {
+			require.Less(t, dep, idx)
+		}
+		storeRecord := record.Stores[testStoreKey.Name()]
+		require.Len(t, storeRecord.Readset, 1)
+		require.Equal(t, itemKey, storeRecord.Readset[0].Key)
+		require.Len(t, storeRecord.Writeset, 2)
+		changes := record.Changes[testStoreKey.Name()]
+		require.Len(t, changes, 2)
+		for i, change := range changes {
+			require.Equal(t, storeRecord.Writeset[i].Key, change.Key)
+			if string(change.Key) == string(itemKey) {
+				require.Equal(t, "item", change.Kind)
+				require.Equal(t, string(storeRecord.Writeset[i].Value), change.Value)
+			} else {
+				require.Equal(t, sdk.WritesetChangeUnknown, change.Kind)
+			}
+		}
+	}
+
+	// replaying the dump through a fresh scheduler reproduces the final state
+	replay := NewScheduler(10, ti, NewReplayDeliverTx(records, map[string]sdk.StoreKey{testStoreKey.Name(): testStoreKey}))
+	replayCtx := initTestCtx(true)
+	_, err = replay.ProcessAll(replayCtx, requestList(20))
+	require.NoError(t, err)
+
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	replayKV := replayCtx.MultiStore().GetKVStore(testStoreKey)
+	require.Equal(t, kv.Get(itemKey), replayKV.Get(itemKey))
+	for idx := 0; idx < 20; idx++ {
+		key := []byte(fmt.Sprintf("%d", idx))
+		require.Equal(t, kv.Get(key), replayKV.Get(key))
+	}
+}
@@ -0,0 +1,54 @@
+package tasks
+
+import (
+	"crypto/sha256"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+)
+
+// WithDuplicateTxOrdering orders the txs of a block whose bytes are identical to those of an earlier tx of the block
+// behind the tx they repeat. A duplicate replays the same account sequence as the tx it copies, so it reads everything
+// the original writes and conflicts with it on every execution until the original is final, churning through
+// re-executions. Duplicates still execute like any other tx, so their results are the same as under sequential
+// execution (including when the original fails its pre-checks without bumping the sequence, and the duplicate goes
+// through), but they depend on the original from the start: they wait for the execution of the original in flight
+// before executing, and for the original to validate before re-executing after a conflict.
+func WithDuplicateTxOrdering() SchedulerOption {
+	return func(s *scheduler) { s.orderDuplicates = true }
+}
+
+// markDuplicates flags the tasks whose tx bytes were already seen in the block, with the index of the first tx with
+// the same bytes, which they depend on
+func (s *scheduler) markDuplicates(tasks []*deliverTxTask) {
+	if !s.orderDuplicates {
+		return
+	}
+	if s.seenTxs == nil {
+		s.seenTxs = make(map[[sha256.Size]byte]int, len(tasks))
+	}
+	for _, task := range tasks {
+		hash := sha256.Sum256(task.Request.Tx)
+		if original, ok := s.seenTxs[hash]; ok {
+			task.Duplicate = true
+			task.DuplicateOf = original
+			task.AppendDependencies([]int{original})
+			s.metrics.duplicateTxs++
+			continue
+		}
+		s.seenTxs[hash] = task.Index
+	}
+}
+
+// waitForOriginal waits for the execution in flight of the tx a duplicate repeats, if any, so that the duplicate doesn't
+// execute against its estimates. Duplicates only ever wait on lower indices, so waits can't form a cycle.
+func (s *scheduler) waitForOriginal(task *deliverTxTask) {
+	if !task.Duplicate || s.synchronous {
+		return
+	}
+	done := s.allTasks[task.DuplicateOf].executionDone()
+	if done == nil {
+		return
+	}
+	telemetry.IncrCounter(1, "scheduler", "duplicate_waits")
+	<-done
+}
@@ -0,0 +1,81 @@
+package tasks
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+func TestProcessAllDuplicateTxs(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// each tx bumps the sequence of its account, which is the tx bytes, and fails if it was already bumped. The fund tx
+	// funds every account, and until it runs, txs fail without bumping their sequence.
+	fundKey := []byte("funded")
+	var executions [30]int32
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		atomic.AddInt32(&executions[ctx.TxIndex()], 1)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if string(req.Tx) == "fund" {
+			kv.Set(fundKey, []byte("1"))
+			return types.ResponseDeliverTx{}
+		}
+		if kv.Has(req.Tx) {
+			return sdkerrors.ResponseDeliverTx(sdkerrors.ErrWrongSequence, 0, 0, false)
+		}
+		if !kv.Has(fundKey) {
+			return sdkerrors.ResponseDeliverTx(sdkerrors.ErrInsufficientFunds, 0, 0, false)
+		}
+		kv.Set(req.Tx, []byte("1"))
+		count, _ := strconv.Atoi(string(kv.Get(itemKey)))
+		kv.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		return types.ResponseDeliverTx{Info: strconv.Itoa(count)}
+	}
+
+	// every third tx repeats the tx before it, and the first tx funds the accounts
+	reqs := requestList(len(executions))
+	reqs[0].Request.Tx = []byte("fund")
+	for i := 2; i < len(reqs); i += 3 {
+		reqs[i].Request.Tx = reqs[i-1].Request.Tx
+	}
+
+	for _, workers := range []int{1, 10} {
+		executions = [30]int32{}
+		s := NewScheduler(workers, ti, deliverTx, WithDuplicateTxOrdering())
+		res, err := s.ProcessAll(initTestCtx(true), reqs)
+		require.NoError(t, err)
+
+		// duplicates execute, and fail on their own like under sequential execution
+		for i, r := range res[1:] {
+			i++
+			if i%3 == 2 {
+				require.Equal(t, sdkerrors.ErrWrongSequence.ABCICode(), r.Code, "tx %d", i)
+				require.NotZero(t, atomic.LoadInt32(&executions[i]), "tx %d", i)
+				continue
+			}
+			require.Zero(t, r.Code, "tx %d", i)
+			require.Equal(t, strconv.Itoa(i-1-i/3), r.Info, "tx %d", i)
+		}
+		require.Equal(t, len(reqs)/3, s.Metrics().DuplicateTxs)
+	}
+
+	// the ordering matches sequential execution
+	_, err := VerifySequential(initTestCtx(true), reqs, 10, ti, deliverTx, WithDuplicateTxOrdering())
+	require.NoError(t, err)
+
+	// a duplicate of a tx that failed without bumping its sequence goes through, as it does sequentially
+	reqs = requestList(3)
+	reqs[1].Request.Tx = []byte("fund")
+	reqs[2].Request.Tx = reqs[0].Request.Tx
+	res, err := VerifySequential(initTestCtx(true), reqs, 10, ti, deliverTx, WithDuplicateTxOrdering())
+	require.NoError(t, err)
+	require.Equal(t, sdkerrors.ErrInsufficientFunds.ABCICode(), res[0].Code)
+	require.Zero(t, res[2].Code)
+}
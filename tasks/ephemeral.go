@@ -0,0 +1,51 @@
+package tasks
+
+import (
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// isEphemeralStore returns whether a store key is of a store whose contents aren't committed: transient stores, which
+// are reset at the end of every block, and memory stores, which only live in memory.
+//
+// Txs of a block still observe each other's writes to these stores (eg. accumulators in transient stores), so they're
+// wrapped in multiversion stores and their reads are validated like any other. But since they're small and in memory,
+// they always keep exact in-memory readsets: the options of WithMultiVersionStoreOptions, which trade validation speed
+// for memory on large persistent stores, aren't applied to them.
+func isEphemeralStore(storeKey sdk.StoreKey) bool {
+	switch storeKey.(type) {
+	case *sdk.TransientStoreKey, *sdk.MemoryStoreKey:
+		return true
+	default:
+		return false
+	}
+}
+
+// multiVersionStoreOptions returns the options of the multiversion store of a store key for the next block
+func (s *scheduler) multiVersionStoreOptions(storeKey sdk.StoreKey) []multiversion.StoreOption {
+	opts := []multiversion.StoreOption{multiversion.WithStoreName(storeKey.Name())}
+	if s.batchedTelemetry {
+		opts = append(opts, multiversion.WithBatchedTelemetry())
+	}
+	opts = append(opts, s.versionPruningOptions(storeKey)...)
+	if s.parentReadCache {
+		if s.parentReadCacheCapacity != nil {
+			opts = append(opts, multiversion.WithParentReadCacheCapacity(s.parentReadCacheCapacity(storeKey)))
+		} else {
+			opts = append(opts, multiversion.WithParentReadCache())
+		}
+	}
+	if s.accessLog != nil {
+		opts = append(opts, multiversion.WithAccessLog(s.accessLog))
+	}
+	if s.storeMetadata != nil {
+		opts = append(opts, multiversion.WithMetadataSnapshot(s.storeMetadata(storeKey)))
+	}
+	if s.metrics != nil && s.metrics.hotKeys != nil {
+		opts = append(opts, multiversion.WithInvalidationListener(s.metrics.hotKeys))
+	}
+	if s.mvsOptions != nil && !isEphemeralStore(storeKey) {
+		opts = append(opts, s.mvsOptions(storeKey)...)
+	}
+	return opts
+}
@@ -0,0 +1,83 @@
+package tasks
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/cachekv"
+	"github.com/cosmos/cosmos-sdk/store/cachemulti"
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/mem"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/store/transient"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllEphemeralStores(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	transientKey := sdk.NewTransientStoreKey("transient_mock")
+	memKey := sdk.NewMemoryStoreKeys("mem_mock")["mem_mock"]
+	db := dbm.NewMemDB()
+	keys := map[string]sdk.StoreKey{
+		testStoreKey.Name(): testStoreKey,
+		transientKey.Name(): transientKey,
+		memKey.Name():       memKey,
+	}
+	stores := map[sdk.StoreKey]sdk.CacheWrapper{
+		testStoreKey: cachekv.NewStore(dbadapter.Store{DB: db}, testStoreKey, 1000),
+		transientKey: cachekv.NewStore(transient.NewStore(), transientKey, 1000),
+		memKey:       cachekv.NewStore(mem.NewStore(), memKey, 1000),
+	}
+	cms := cachemulti.NewStore(db, stores, keys, nil, nil, nil)
+	ctx := sdk.Context{}.WithContext(context.Background()).WithMultiStore(&cms).WithLogger(log.NewNopLogger())
+
+	const txs = 20
+	counterKey := []byte("counter")
+	readCounter := func(kv sdk.KVStore) int {
+		count, _ := strconv.Atoi(string(kv.Get(counterKey)))
+		return count
+	}
+	// every tx increments counters in the transient and memory stores, and records what it read in the persistent store
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		tkv := ctx.MultiStore().GetKVStore(transientKey)
+		mkv := ctx.MultiStore().GetKVStore(memKey)
+		transientCount, memCount := readCounter(tkv), readCounter(mkv)
+		tkv.Set(counterKey, []byte(strconv.Itoa(transientCount+1)))
+		mkv.Set(counterKey, []byte(strconv.Itoa(memCount+1)))
+		ctx.MultiStore().GetKVStore(testStoreKey).Set(req.Tx, []byte(strconv.Itoa(transientCount)))
+		return types.ResponseDeliverTx{Data: []byte(strconv.Itoa(memCount))}
+	}
+
+	var mtx sync.Mutex
+	optionKeys := make(map[string]bool)
+	mvsOptions := func(storeKey sdk.StoreKey) []multiversion.StoreOption {
+		mtx.Lock()
+		defer mtx.Unlock()
+		optionKeys[storeKey.Name()] = true
+		return []multiversion.StoreOption{multiversion.WithReadsetDigests(1)}
+	}
+
+	s := NewScheduler(10, ti, deliverTx, WithMultiVersionStoreOptions(mvsOptions))
+	res, err := s.ProcessAll(ctx, requestList(txs))
+	require.NoError(t, err)
+
+	// txs observe each other's writes to the ephemeral stores like under sequential execution
+	for idx, response := range res {
+		require.Equal(t, uint32(0), response.Code)
+		require.Equal(t, strconv.Itoa(idx), string(response.Data))
+		require.Equal(t, strconv.Itoa(idx), string(ctx.MultiStore().GetKVStore(testStoreKey).Get([]byte(strconv.Itoa(idx)))))
+	}
+	require.Equal(t, txs, readCounter(ctx.MultiStore().GetKVStore(transientKey)))
+	require.Equal(t, txs, readCounter(ctx.MultiStore().GetKVStore(memKey)))
+	// only the persistent store got the multiversion store options
+	require.Equal(t, map[string]bool{testStoreKey.Name(): true}, optionKeys)
+}
@@ -0,0 +1,101 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Classes of the internal inconsistencies ProcessAll returns as a *SchedulerError. They don't come from the txs of the
+// block but from the scheduler or the multiversion stores, so the caller decides per class whether to halt or to
+// execute the block sequentially instead, eg. against a fresh branch of the parent stores.
+var (
+	// ErrEstimateAtCommit is an estimate left as the latest version of a key once every tx was validated, which means
+	// the writes a tx was expected to make are missing from the final state of the block
+	ErrEstimateAtCommit = errors.New("occ scheduler found an estimate when committing the block")
+	// ErrMissingResponse is a tx left without a response once every tx was validated
+	ErrMissingResponse = errors.New("occ scheduler has no response for a tx")
+	// ErrValidationLivelock is sequential execution that stopped validating txs, which would otherwise loop forever
+	ErrValidationLivelock = errors.New("occ scheduler validation stopped making progress")
+	// ErrStoreInconsistency is a multiversion store that failed to write the final state of the block to its parent
+	// store, which may have been written partially
+	ErrStoreInconsistency = errors.New("occ scheduler found a multiversion store inconsistent")
+)
+
+// livelockStalls is the number of consecutive sequential rounds that can fail to validate the first tx that isn't
+// validated before the block is considered livelocked. A round may legitimately stall once, eg. if it's interrupted or
+// rolled back, but sequential execution of a tx only depends on validated txs, so it must validate eventually.
+const livelockStalls = 3
+
+// SchedulerError is an internal inconsistency found while processing a block, with the tx and the store it was found
+// at. errors.Is matches it against its class, eg. ErrEstimateAtCommit.
+type SchedulerError struct {
+	// Class is one of ErrEstimateAtCommit, ErrMissingResponse, ErrValidationLivelock or ErrStoreInconsistency
+	Class error
+	// TxIndex is the index of the offending tx, or -1 if the inconsistency isn't tied to a tx
+	TxIndex int
+	// StoreKey is the name of the offending store key, or empty if the inconsistency isn't tied to a store
+	StoreKey string
+	// Key is the offending key of the store, if any
+	Key []byte
+	// Cause is the underlying error, eg. a panic of the store, if any
+	Cause error
+}
+
+func (e *SchedulerError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Class.Error())
+	if e.TxIndex >= 0 {
+		fmt.Fprintf(&b, ": tx %d", e.TxIndex)
+	}
+	if e.StoreKey != "" {
+		fmt.Fprintf(&b, ": store %q", e.StoreKey)
+	}
+	if e.Key != nil {
+		fmt.Fprintf(&b, ": key %X", e.Key)
+	}
+	if e.Cause != nil {
+		fmt.Fprintf(&b, ": %s", e.Cause)
+	}
+	return b.String()
+}
+
+func (e *SchedulerError) Unwrap() error {
+	return e.Class
+}
+
+// checkResponses returns an ErrMissingResponse error for the first task without a response
+func checkResponses(tasks []*deliverTxTask) error {
+	for _, t := range tasks {
+		if t.Response == nil {
+			return &SchedulerError{Class: ErrMissingResponse, TxIndex: t.Index}
+		}
+	}
+	return nil
+}
+
+// checkEstimates returns an ErrEstimateAtCommit error for the first store, in store key order, with an estimate as
+// the latest version of a key
+func (s *scheduler) checkEstimates() error {
+	for _, mv := range s.orderedStores {
+		if key, index, found := mv.store.LatestEstimate(); found {
+			return &SchedulerError{Class: ErrEstimateAtCommit, TxIndex: index, StoreKey: mv.key.Name(), Key: key}
+		}
+	}
+	return nil
+}
+
+// checkLivelock returns an ErrValidationLivelock error if too many consecutive sequential rounds started at the same
+// tx without validating it
+func (s *scheduler) checkLivelock(startIdx int) error {
+	if startIdx > s.syncStart {
+		s.syncStart = startIdx
+		s.syncStalls = 0
+		return nil
+	}
+	s.syncStalls++
+	if s.syncStalls >= livelockStalls {
+		return &SchedulerError{Class: ErrValidationLivelock, TxIndex: startIdx}
+	}
+	return nil
+}
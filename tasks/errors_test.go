@@ -0,0 +1,101 @@
+package tasks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// panickingWriteListener panics on the first write streamed to it
+type panickingWriteListener struct{}
+
+func (panickingWriteListener) OnWrite(storeKey storetypes.StoreKey, key []byte, value []byte, delete bool) error {
+	panic("listener failed")
+}
+
+func TestSchedulerErrorClasses(t *testing.T) {
+	err := error(&SchedulerError{Class: ErrEstimateAtCommit, TxIndex: 3, StoreKey: "bank", Key: []byte{0xab}})
+	require.ErrorIs(t, err, ErrEstimateAtCommit)
+	require.NotErrorIs(t, err, ErrStoreInconsistency)
+	require.EqualError(t, err, `occ scheduler found an estimate when committing the block: tx 3: store "bank": key AB`)
+
+	err = &SchedulerError{Class: ErrStoreInconsistency, TxIndex: -1, Cause: errors.New("boom")}
+	require.EqualError(t, err, "occ scheduler found a multiversion store inconsistent: boom")
+}
+
+func TestProcessAllStoreInconsistency(t *testing.T) {
+	ti := newTestTracingInfo()
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		ctx.MultiStore().GetKVStore(testStoreKey).Set(req.Tx, req.Tx)
+		return types.ResponseDeliverTx{}
+	}
+
+	// a panic while flushing is returned rather than crashing the node
+	s := NewScheduler(4, ti, deliverTx, WithWriteListeners(map[sdk.StoreKey][]storetypes.WriteListener{
+		testStoreKey: {panickingWriteListener{}},
+	}))
+	_, err := s.ProcessAll(initTestCtx(true), requestList(10))
+	require.ErrorIs(t, err, ErrStoreInconsistency)
+	var schedErr *SchedulerError
+	require.True(t, errors.As(err, &schedErr))
+	require.Equal(t, testStoreKey.Name(), schedErr.StoreKey)
+	require.Equal(t, -1, schedErr.TxIndex)
+	require.EqualError(t, schedErr.Cause, "listener failed")
+}
+
+func TestCheckEstimates(t *testing.T) {
+	s := NewScheduler(1, nil, nil).(*scheduler)
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	s.orderedStores = []keyedMultiVersionStore{{key: testStoreKey, store: mvs}}
+	mvs.SetWriteset(1, 0, multiversion.WriteSet{"a": []byte("a")})
+	require.NoError(t, s.checkEstimates())
+
+	// an estimate under a later write doesn't reach the parent store, the latest one does
+	mvs.SetEstimatedWriteset(0, 0, multiversion.WriteSet{"a": nil})
+	require.NoError(t, s.checkEstimates())
+	mvs.SetEstimatedWriteset(2, 0, multiversion.WriteSet{"c": nil, "b": nil})
+	err := s.checkEstimates()
+	require.ErrorIs(t, err, ErrEstimateAtCommit)
+	var schedErr *SchedulerError
+	require.True(t, errors.As(err, &schedErr))
+	require.Equal(t, 2, schedErr.TxIndex)
+	require.Equal(t, testStoreKey.Name(), schedErr.StoreKey)
+	require.Equal(t, []byte("b"), schedErr.Key)
+}
+
+func TestCheckResponses(t *testing.T) {
+	tasks := toTasks(requestList(3))
+	for _, task := range tasks {
+		task.Response = &types.ResponseDeliverTx{}
+	}
+	require.NoError(t, checkResponses(tasks))
+
+	tasks[1].Response = nil
+	err := checkResponses(tasks)
+	require.ErrorIs(t, err, ErrMissingResponse)
+	require.Equal(t, 1, err.(*SchedulerError).TxIndex)
+}
+
+func TestCheckLivelock(t *testing.T) {
+	s := NewScheduler(1, nil, nil).(*scheduler)
+	s.syncStart = -1
+	require.NoError(t, s.checkLivelock(0))
+	// a stalled round is tolerated, as long as a later one makes progress
+	require.NoError(t, s.checkLivelock(0))
+	require.NoError(t, s.checkLivelock(2))
+	for i := 1; i < livelockStalls; i++ {
+		require.NoError(t, s.checkLivelock(2))
+	}
+	err := s.checkLivelock(2)
+	require.ErrorIs(t, err, ErrValidationLivelock)
+	require.Equal(t, 2, err.(*SchedulerError).TxIndex)
+}
@@ -0,0 +1,108 @@
+package tasks
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	store "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// TxEstimate is the outcome of a dry run of a tx with EstimateTxs
+type TxEstimate struct {
+	Response types.ResponseDeliverTx
+	// Writesets are the writes of the tx, by store key, to the stores wrapped in multiversion stores. They can be
+	// cached and used as the EstimatedWritesets of the tx once it lands in a block, see PrefillEstimates.
+	Writesets sdk.MappedWritesets
+}
+
+// EstimateTxs dry runs txs, eg. pending mempool txs, to estimate their gas and the keys they write. Every tx is
+// executed in parallel on the scheduler's workers against ctx's multistore alone, eg. the latest committed state, as
+// if it were the only tx of a block, and its writes are discarded, so that nothing is written to ctx's multistore.
+// Unlike SimulateBlock it doesn't process a block and touches none of the scheduler's block-scoped state, so it may
+// be called concurrently with blocks, eg. from CheckTx.
+func (s *scheduler) EstimateTxs(ctx sdk.Context, reqs []*sdk.DeliverTxEntry) []TxEstimate {
+	estimates := make([]TxEstimate, len(reqs))
+	if len(reqs) == 0 {
+		return estimates
+	}
+	ctx, span := s.traceSpan(ctx, "SchedulerEstimateTxs", nil)
+	defer span.End()
+
+	// the multiversion stores are never written to, so that every tx only observes ctx's multistore
+	stores := make(map[store.StoreKey]multiversion.MultiVersionStore)
+	keys := ctx.MultiStore().StoreKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name() < keys[j].Name() })
+	for _, sk := range keys {
+		if s.storeStrategy(sk, ctx.MultiStore().GetStore(sk).GetStoreType()) == StoreStrategyMultiVersion {
+			stores[sk] = multiversion.NewMultiVersionStore(ctx.MultiStore().GetKVStore(sk), multiversion.WithStoreName(sk.Name()))
+		}
+	}
+
+	workers := s.blockWorkers(len(reqs))
+	if workers > len(reqs) {
+		workers = len(reqs)
+	}
+	indexes := make(chan int, len(reqs))
+	for i := range reqs {
+		indexes <- i
+	}
+	close(indexes)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				estimates[i] = s.estimateTx(ctx, stores, i, reqs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return estimates
+}
+
+// estimateTx dry runs the tx at index against a branch of ctx's multistore, with version indexed stores over stores
+// recording its writes
+func (s *scheduler) estimateTx(ctx sdk.Context, stores map[store.StoreKey]multiversion.MultiVersionStore, index int, req *sdk.DeliverTxEntry) TxEstimate {
+	task := &deliverTxTask{Index: index, Request: req.Request}
+	ctx, span := s.traceSpan(ctx.WithTxIndex(index), "SchedulerEstimateTx", task)
+	defer span.End()
+
+	// no tx is written to the multiversion stores, so the version indexed stores never abort
+	abortCh := newAbortChannel(len(stores))
+	vs := make(map[store.StoreKey]*multiversion.VersionIndexedStore, len(stores))
+	for sk, mvs := range stores {
+		vs[sk] = mvs.VersionedIndexedStore(index, 0, abortCh)
+	}
+	ms := ctx.MultiStore().CacheMultiStore().SetKVStores(func(k store.StoreKey, kvs sdk.KVStore) store.CacheWrap {
+		if vis, ok := vs[k]; ok {
+			return vis
+		}
+		// stores that aren't versioned, including the ones that aren't KV stores, are kept as they are
+		return nil
+	})
+	task.Ctx = ctx.WithMultiStore(ms)
+	task.VersionStores = vs
+	task.AbortCh = abortCh
+
+	estimate := TxEstimate{
+		Response:  s.deliverTxWithRecovery(span, task),
+		Writesets: make(sdk.MappedWritesets, len(vs)),
+	}
+	for sk, vis := range vs {
+		writeset := vis.GetWriteset()
+		if len(writeset) == 0 {
+			continue
+		}
+		copied := make(multiversion.WriteSet, len(writeset))
+		for key, value := range writeset {
+			copied[key] = sdk.CopyBytes(value)
+		}
+		estimate.Writesets[sk] = copied
+	}
+	return estimate
+}
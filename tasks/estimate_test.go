@@ -0,0 +1,67 @@
+package tasks
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+func TestEstimateTxs(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	const txs = 10
+	const panickingTx = 7
+	// every tx increments a shared counter and writes a key of its own
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		count, _ := strconv.Atoi(string(kv.Get(itemKey)))
+		kv.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		kv.Set(req.Tx, req.Tx)
+		if ctx.TxIndex() == panickingTx && ctx.BlockHeight() == 0 {
+			panic("estimate failed")
+		}
+		return types.ResponseDeliverTx{GasUsed: 10, Data: []byte(strconv.Itoa(count))}
+	}
+
+	s := NewScheduler(4, ti, deliverTx)
+	ctx := initTestCtx(true)
+	estimates := s.EstimateTxs(ctx, requestList(txs))
+	require.Len(t, estimates, txs)
+	for i, estimate := range estimates {
+		if i == panickingTx {
+			require.Equal(t, sdkerrors.ErrPanic.ABCICode(), estimate.Response.Code)
+			require.Empty(t, estimate.Writesets)
+			continue
+		}
+		// every tx ran alone against the parent state
+		require.Zero(t, estimate.Response.Code)
+		require.Equal(t, int64(10), estimate.Response.GasUsed)
+		require.Equal(t, []byte("0"), estimate.Response.Data)
+		key := strconv.Itoa(i)
+		require.Equal(t, sdk.MappedWritesets{testStoreKey: {string(itemKey): []byte("1"), key: []byte(key)}}, estimate.Writesets)
+	}
+	// nothing was written to the parent, and the scheduler's block state wasn't touched
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	require.Nil(t, kv.Get(itemKey))
+	require.Nil(t, kv.Get([]byte("0")))
+	require.Nil(t, s.(*scheduler).multiVersionStores)
+
+	// the estimates can be prefilled once the txs land in a block
+	reqs := requestList(txs)
+	for i, req := range reqs {
+		req.EstimatedWritesets = estimates[i].Writesets
+	}
+	ctx = initTestCtx(true).WithBlockHeight(1)
+	res, err := s.ProcessAll(ctx, reqs)
+	require.NoError(t, err)
+	for i, r := range res {
+		require.Equal(t, []byte(strconv.Itoa(i)), r.Data)
+	}
+	require.Equal(t, []byte(strconv.Itoa(txs)), ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey))
+}
@@ -0,0 +1,15 @@
+package tasks
+
+// removeStaleEstimates removes the estimates left at the indices of txs that failed for good, in tx order, before the
+// block is flushed. A failed tx normally replaces its writeset with an empty one, but estimates it never cleared (eg.
+// prefilled from its declared writeset) would otherwise panic when the latest values are written to the parent store.
+func (s *scheduler) removeStaleEstimates(tasks []*deliverTxTask) {
+	for _, t := range tasks {
+		if t.Response.IsOK() {
+			continue
+		}
+		for _, mv := range s.orderedStores {
+			mv.store.RemoveEstimatesForIndex(t.Index)
+		}
+	}
+}
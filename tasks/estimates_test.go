@@ -0,0 +1,59 @@
+package tasks
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllRemovesUnwrittenEstimates(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	const txs = 20
+	const flaggedTx = 3
+	staleKey := []byte("stale")
+	// every tx reads a key that is never written, and all txs but the flagged one increment a shared counter
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if kv.Get(staleKey) != nil {
+			return types.ResponseDeliverTx{Code: 1}
+		}
+		count, _ := strconv.Atoi(string(kv.Get(itemKey)))
+		if ctx.TxIndex() != flaggedTx {
+			kv.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		}
+		return types.ResponseDeliverTx{Data: []byte(strconv.Itoa(count))}
+	}
+
+	for _, workers := range []int{1, 10} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			// the flagged tx declares a write of the key it never writes, prefilling an estimate at its index
+			reqs := requestList(txs)
+			reqs[flaggedTx].NoWritesExpected = true
+			reqs[flaggedTx].EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(staleKey): nil}}
+
+			s := NewScheduler(workers, ti, deliverTx)
+			ctx := initTestCtx(true)
+			res, err := s.ProcessAll(ctx, reqs)
+			require.NoError(t, err)
+
+			for idx, response := range res {
+				require.Equal(t, uint32(0), response.Code)
+				expected := idx
+				if idx > flaggedTx {
+					expected--
+				}
+				require.Equal(t, strconv.Itoa(expected), string(response.Data))
+			}
+			kv := ctx.MultiStore().GetKVStore(testStoreKey)
+			require.Equal(t, []byte(strconv.Itoa(txs-1)), kv.Get(itemKey))
+			require.False(t, kv.Has(staleKey))
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package tasks
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/tendermint/tendermint/abci/types"
+)
+
+const (
+	// EventTypeTxOrder is the type of the event stamped on responses with the index of their tx in the block, see
+	// WithEventOrdering
+	EventTypeTxOrder = "tx_order"
+	// AttributeKeyTxIndex is the attribute of EventTypeTxOrder events holding the index of the tx in the block
+	AttributeKeyTxIndex = "tx_index"
+)
+
+// WithEventOrdering makes the events of responses independent of how txs were executed. Every response is stamped with
+// an EventTypeTxOrder event holding the final index of its tx, and the attributes of every event are sorted by key,
+// so that their order doesn't depend on how handlers built them (eg. by iterating over a map). Attributes with the same
+// key keep their relative order, but aren't interleaved with other keys anymore, so this is only meant for chains
+// whose clients look attributes up by key. Events aren't part of consensus, so this doesn't affect the app hash.
+func WithEventOrdering() SchedulerOption {
+	return func(s *scheduler) { s.eventOrdering = true }
+}
+
+// orderEvents stamps and normalizes the events of the final response of a task, if enabled
+func (s *scheduler) orderEvents(t *deliverTxTask) {
+	if !s.eventOrdering {
+		return
+	}
+	t.Response.Events = normalizeEvents(t.Response.Events)
+	t.Response.Events = append(t.Response.Events, types.Event{
+		Type: EventTypeTxOrder,
+		Attributes: []types.EventAttribute{
+			{Key: []byte(AttributeKeyTxIndex), Value: []byte(strconv.Itoa(t.Index))},
+		},
+	})
+}
+
+// normalizeEvents returns the events with the attributes of every event sorted by key, keeping the relative order of
+// attributes with the same key. The events themselves keep their order, which is the order they were emitted in.
+func normalizeEvents(events []types.Event) []types.Event {
+	normalized := make([]types.Event, len(events))
+	for i, event := range events {
+		attributes := make([]types.EventAttribute, len(event.Attributes))
+		copy(attributes, event.Attributes)
+		sort.SliceStable(attributes, func(i, j int) bool {
+			return string(attributes[i].Key) < string(attributes[j].Key)
+		})
+		normalized[i] = types.Event{Type: event.Type, Attributes: attributes}
+	}
+	return normalized
+}
@@ -0,0 +1,72 @@
+package tasks
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// mapOrderedDeliverTx increments a shared counter, so that txs conflict and get re-executed, and emits an event whose
+// attributes are built by iterating over a map, so that their order differs between executions
+func mapOrderedDeliverTx(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+	defer abortRecoveryFunc(&response)
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	count, _ := strconv.Atoi(string(kv.Get(itemKey)))
+	kv.Set(itemKey, []byte(strconv.Itoa(count+1)))
+	values := map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": strconv.Itoa(count)}
+	event := types.Event{Type: "counter"}
+	for key, value := range values {
+		event.Attributes = append(event.Attributes, types.EventAttribute{Key: []byte(key), Value: []byte(value)})
+	}
+	return types.ResponseDeliverTx{Data: []byte(strconv.Itoa(count)), Events: []types.Event{event}}
+}
+
+func TestNormalizeEvents(t *testing.T) {
+	attribute := func(key, value string) types.EventAttribute {
+		return types.EventAttribute{Key: []byte(key), Value: []byte(value)}
+	}
+	events := []types.Event{
+		{Type: "transfer", Attributes: []types.EventAttribute{
+			attribute("recipient", "r1"), attribute("amount", "a1"), attribute("recipient", "r2"), attribute("amount", "a2"),
+		}},
+		{Type: "message"},
+	}
+	normalized := normalizeEvents(events)
+	require.Equal(t, []types.Event{
+		{Type: "transfer", Attributes: []types.EventAttribute{
+			attribute("amount", "a1"), attribute("amount", "a2"), attribute("recipient", "r1"), attribute("recipient", "r2"),
+		}},
+		{Type: "message", Attributes: []types.EventAttribute{}},
+	}, normalized)
+	// the events normalized aren't modified
+	require.Equal(t, "recipient", string(events[0].Attributes[0].Key))
+}
+
+func TestProcessAllEventOrdering(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	const txs = 30
+	s := NewScheduler(10, ti, mapOrderedDeliverTx, WithEventOrdering())
+	res, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+
+	for idx, response := range res {
+		require.Len(t, response.Events, 2)
+		var keys []string
+		for _, attribute := range response.Events[0].Attributes {
+			keys = append(keys, string(attribute.Key))
+		}
+		require.Equal(t, []string{"a", "b", "c", "d", "e"}, keys)
+		require.Equal(t, strconv.Itoa(idx), string(response.Events[0].Attributes[4].Value))
+		require.Equal(t, types.Event{
+			Type: EventTypeTxOrder,
+			Attributes: []types.EventAttribute{
+				{Key: []byte(AttributeKeyTxIndex), Value: []byte(strconv.Itoa(idx))},
+			},
+		}, response.Events[1])
+	}
+}
@@ -0,0 +1,15 @@
+package tasks
+
+import (
+	"github.com/cosmos/cosmos-sdk/internal/failpoint"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// injectAbort adds the abort a KindAbort failpoint forces on the execution of task to its aborts, if one fires, see
+// internal/failpoint. The forced abort depends on the tx before it, like a read of one of its estimates would.
+func injectAbort(task *deliverTxTask, aborts []occ.Abort) []occ.Abort {
+	if !failpoint.ForceAbort(task.Index, task.Incarnation) {
+		return aborts
+	}
+	return append(aborts, occ.Abort{DependentTxIdx: task.Index - 1, Err: failpoint.ErrInjected, Reason: occ.AbortReasonInjected})
+}
@@ -0,0 +1,98 @@
+//go:build occfailpoints
+// +build occfailpoints
+
+package tasks
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cosmos/cosmos-sdk/internal/failpoint"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+	"github.com/cosmos/cosmos-sdk/utils/tracing"
+)
+
+func TestProcessAllUnderFailpoints(t *testing.T) {
+	ti := newTestTracingInfo()
+	t.Cleanup(failpoint.Disable)
+
+	// every tx appends its index to the same key, and writes its own key
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		kv.Set(req.Tx, req.Tx)
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	tests := []struct {
+		name       string
+		failpoints []failpoint.Failpoint
+		reason     occ.AbortReason
+	}{
+		{
+			name:       "delays",
+			failpoints: []failpoint.Failpoint{{Kind: failpoint.KindDelay, Txs: []int{0, 7, 13}, Delay: 5 * time.Millisecond}},
+		},
+		{
+			name:       "forced aborts",
+			failpoints: []failpoint.Failpoint{{Kind: failpoint.KindAbort, Txs: []int{3, 11, 19}, Incarnations: []int{0, 1}}},
+			reason:     occ.AbortReasonInjected,
+		},
+		{
+			name:       "spurious estimate reads",
+			failpoints: []failpoint.Failpoint{{Kind: failpoint.KindEstimateRead, Key: itemKey, Times: 10}},
+			// the txs recover the abort panics themselves
+			reason: occ.AbortReasonPanicRecovered,
+		},
+		{
+			name:       "validation flaps",
+			failpoints: []failpoint.Failpoint{{Kind: failpoint.KindValidationFlap, Txs: []int{0, 5, 17}, Times: 6}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, failpoint.Enable(tt.failpoints...))
+			s := NewScheduler(10, ti, deliverTx)
+			ctx := initTestCtx(true)
+			res, err := s.ProcessAll(ctx, requestList(20))
+			require.NoError(t, err)
+
+			expected := ""
+			for i, r := range res {
+				expected += fmt.Sprintf("%d,", i)
+				require.Equal(t, expected, r.Info)
+			}
+			require.Positive(t, failpoint.Hits(tt.failpoints[0].Kind.String()))
+			if tt.reason != occ.AbortReasonUnknown {
+				require.Positive(t, s.Metrics().AbortReasons[tt.reason.String()])
+			}
+		})
+	}
+}
+
+func TestProcessAllFailsUnderPermanentValidationFlap(t *testing.T) {
+	ti := newTestTracingInfo()
+	t.Cleanup(failpoint.Disable)
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		ctx.MultiStore().GetKVStore(testStoreKey).Set(req.Tx, req.Tx)
+		return types.ResponseDeliverTx{}
+	}
+
+	// a tx that never validates exhausts the concurrent rounds, and then stalls sequential execution
+	require.NoError(t, failpoint.Enable(failpoint.Failpoint{Kind: failpoint.KindValidationFlap, Txs: []int{4}}))
+	s := NewScheduler(4, ti, deliverTx, WithMaxIterations(3))
+	_, err := s.ProcessAll(initTestCtx(true), requestList(10))
+	require.ErrorIs(t, err, ErrValidationLivelock)
+	var schedErr *SchedulerError
+	require.ErrorAs(t, err, &schedErr)
+	require.Equal(t, 4, schedErr.TxIndex)
+}
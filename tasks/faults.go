@@ -0,0 +1,39 @@
+package tasks
+
+import (
+	"time"
+)
+
+// faultInjector injects faults into the scheduler's pipeline, to exercise how it copes with concurrency edge cases
+// that are otherwise hard to hit. It's only ever set by tests, and every hook is optional.
+type faultInjector struct {
+	// dropAbort drops the abort of an execution of task if it returns true, as if it was never sent
+	dropAbort func(task *deliverTxTask) bool
+	// delayFlush returns how long to hold back the flush of the writeset of an execution of task
+	delayFlush func(task *deliverTxTask) time.Duration
+	// reorderValidation may reorder the tasks of a validation round before they're dispatched
+	reorderValidation func(tasks []*deliverTxTask)
+}
+
+func (f *faultInjector) shouldDropAbort(task *deliverTxTask) bool {
+	return f != nil && f.dropAbort != nil && f.dropAbort(task)
+}
+
+func (f *faultInjector) delayWritesetFlush(task *deliverTxTask) {
+	if f == nil || f.delayFlush == nil {
+		return
+	}
+	if d := f.delayFlush(task); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+func (f *faultInjector) validationOrder(tasks []*deliverTxTask) []*deliverTxTask {
+	if f == nil || f.reorderValidation == nil {
+		return tasks
+	}
+	reordered := make([]*deliverTxTask, len(tasks))
+	copy(reordered, tasks)
+	f.reorderValidation(reordered)
+	return reordered
+}
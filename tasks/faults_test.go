@@ -0,0 +1,179 @@
+package tasks
+
+import (
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+func TestProcessAllConvergesUnderFaults(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// aborts aren't recovered by the txs themselves, so their responses are OCC aborts like under baseapp. Txs take a
+	// while between reading and writing the shared key, so that their executions overlap and conflict.
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		idx := ctx.TxIndex()
+		val := kv.Get(itemKey)
+		time.Sleep(50 * time.Microsecond)
+		kv.Set(itemKey, append(append([]byte{}, val...), req.Tx...))
+		kv.Set([]byte(fmt.Sprintf("tx-%03d", idx)), req.Tx)
+		if prev := kv.Get([]byte(fmt.Sprintf("tx-%03d", idx-1))); prev != nil {
+			kv.Set([]byte(fmt.Sprintf("seed-%02d", idx%10)), prev)
+		}
+		if idx%4 == 0 {
+			kv.Delete([]byte(fmt.Sprintf("seed-%02d", (idx+1)%10)))
+		}
+		return types.ResponseDeliverTx{}
+	}
+	// every tx is estimated to write the shared key, so that reads of it abort until the previous tx has executed
+	reqs := requestList(100)
+	for _, req := range reqs {
+		req.EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(itemKey): nil}}
+	}
+
+	seqCtx, seqStore := initIAVLTestCtx(t)
+	for i, req := range reqs {
+		cms := seqCtx.MultiStore().CacheMultiStore()
+		deliverTx(seqCtx.WithMultiStore(cms).WithTxIndex(i), req.Request)
+		cms.Write()
+	}
+	seqCtx.MultiStore().(storetypes.CacheMultiStore).Write()
+	seqHash := seqStore.Commit(true).Hash
+
+	tests := []struct {
+		name string
+		// returns the faults to inject, and the number of times they fired
+		faults        func() (*faultInjector, *int64)
+		policy        ConflictPolicy
+		expectedFired bool
+	}{
+		{
+			name: "every abort dropped",
+			faults: func() (*faultInjector, *int64) {
+				var fired int64
+				return &faultInjector{
+					dropAbort: func(*deliverTxTask) bool {
+						atomic.AddInt64(&fired, 1)
+						return true
+					},
+				}, &fired
+			},
+			policy:        RerunImmediatelyPolicy{},
+			expectedFired: true,
+		},
+		{
+			name: "aborts of odd txs dropped",
+			faults: func() (*faultInjector, *int64) {
+				var fired int64
+				return &faultInjector{
+					dropAbort: func(task *deliverTxTask) bool {
+						if task.Index%2 == 0 {
+							return false
+						}
+						atomic.AddInt64(&fired, 1)
+						return true
+					},
+				}, &fired
+			},
+			policy:        RerunImmediatelyPolicy{},
+			expectedFired: true,
+		},
+		{
+			name: "writeset flushes of lower txs delayed",
+			faults: func() (*faultInjector, *int64) {
+				var fired int64
+				return &faultInjector{
+					delayFlush: func(task *deliverTxTask) time.Duration {
+						atomic.AddInt64(&fired, 1)
+						return time.Duration(100-task.Index) * 5 * time.Microsecond
+					},
+				}, &fired
+			},
+			expectedFired: true,
+		},
+		{
+			name: "validation reversed",
+			faults: func() (*faultInjector, *int64) {
+				var fired int64
+				return &faultInjector{
+					reorderValidation: func(tasks []*deliverTxTask) {
+						atomic.AddInt64(&fired, 1)
+						for i, j := 0, len(tasks)-1; i < j; i, j = i+1, j-1 {
+							tasks[i], tasks[j] = tasks[j], tasks[i]
+						}
+					},
+				}, &fired
+			},
+			expectedFired: true,
+		},
+		{
+			name: "all faults with immediate reruns",
+			faults: func() (*faultInjector, *int64) {
+				var fired int64
+				return &faultInjector{
+					dropAbort: func(*deliverTxTask) bool {
+						atomic.AddInt64(&fired, 1)
+						return rand.Intn(2) == 0
+					},
+					delayFlush: func(*deliverTxTask) time.Duration {
+						atomic.AddInt64(&fired, 1)
+						return time.Duration(rand.Intn(200)) * time.Microsecond
+					},
+					reorderValidation: func(tasks []*deliverTxTask) {
+						atomic.AddInt64(&fired, 1)
+						rand.Shuffle(len(tasks), func(i, j int) { tasks[i], tasks[j] = tasks[j], tasks[i] })
+					},
+				}, &fired
+			},
+			policy:        RerunImmediatelyPolicy{},
+			expectedFired: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for run := 0; run < 3; run++ {
+				faults, fired := tt.faults()
+				var opts []SchedulerOption
+				if tt.policy != nil {
+					opts = append(opts, WithConflictPolicy(tt.policy))
+				}
+				s := NewScheduler(20, ti, deliverTx, opts...).(*scheduler)
+				s.faults = faults
+
+				occCtx, occStore := initIAVLTestCtx(t)
+				res, err := s.ProcessAll(occCtx, reqs)
+				require.NoError(t, err)
+				require.Len(t, res, len(reqs))
+				for i, r := range res {
+					require.False(t, isResponseError(r, sdkerrors.ErrOCCAbort), "tx %d has an aborted response", i)
+				}
+				occCtx.MultiStore().(storetypes.CacheMultiStore).Write()
+				require.Equal(t, seqHash, occStore.Commit(true).Hash)
+				if tt.expectedFired {
+					require.Positive(t, atomic.LoadInt64(fired))
+				}
+			}
+		})
+	}
+}
+
+func TestFaultInjectorNil(t *testing.T) {
+	var f *faultInjector
+	task := &deliverTxTask{Index: 1}
+	tasks := []*deliverTxTask{{Index: 0}, task}
+
+	require.False(t, f.shouldDropAbort(task))
+	f.delayWritesetFlush(task)
+	require.Equal(t, tasks, f.validationOrder(tasks))
+}
@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"fmt"
+	"sync"
+)
+
+// WithFlushConcurrency bounds the number of multiversion stores written to their parent stores concurrently at the
+// end of a block. By default every store key is flushed on its own goroutine, since the stores are independent, and
+// a concurrency of 1 flushes them one after another. Write listeners of different store keys may be called
+// concurrently unless the concurrency is 1.
+func WithFlushConcurrency(concurrency int) SchedulerOption {
+	return func(s *scheduler) { s.flushConcurrency = concurrency }
+}
+
+// flushStores writes the final state of the block from every multiversion store to its parent store, streaming it to
+// the store's write listeners first, if any. Stores are flushed in parallel, up to the flush concurrency. An error of a
+// store is returned once every store is done, favoring the first store in store key order, so that the outcome doesn't
+// depend on scheduling. A panic of a store is returned as an ErrStoreInconsistency error, since the store may have
+// partially written its parent store by then.
+func (s *scheduler) flushStores() error {
+	concurrency := s.flushConcurrency
+	if concurrency <= 0 || concurrency > len(s.orderedStores) {
+		concurrency = len(s.orderedStores)
+	}
+	if concurrency <= 1 {
+		for _, mv := range s.orderedStores {
+			if err := s.flushStore(mv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	errs := make([]error, len(s.orderedStores))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, mv := range s.orderedStores {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, mv keyedMultiVersionStore) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = s.flushStore(mv)
+		}(i, mv)
+	}
+	wg.Wait()
+	for i := range s.orderedStores {
+		if errs[i] != nil {
+			return errs[i]
+		}
+	}
+	return nil
+}
+
+// flushStore writes the final state of the block from a multiversion store to its parent store
+func (s *scheduler) flushStore(mv keyedMultiVersionStore) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = storeInconsistency(mv, r)
+		}
+	}()
+	if listeners := s.writeListeners[mv.key]; len(listeners) > 0 && s.simulation == nil {
+		return mv.store.WriteLatestToStoreWithListeners(mv.key, listeners)
+	}
+	mv.store.WriteLatestToStore()
+	return nil
+}
+
+// storeInconsistency returns the ErrStoreInconsistency error of a multiversion store that panicked while writing to its
+// parent store
+func storeInconsistency(mv keyedMultiVersionStore, r interface{}) error {
+	cause, ok := r.(error)
+	if !ok {
+		cause = fmt.Errorf("%v", r)
+	}
+	return &SchedulerError{Class: ErrStoreInconsistency, TxIndex: -1, StoreKey: mv.key.Name(), Cause: cause}
+}
@@ -0,0 +1,118 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/cachekv"
+	"github.com/cosmos/cosmos-sdk/store/cachemulti"
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// concurrencyListener tracks how many stores are being flushed at once, holding each flush on its first write
+type concurrencyListener struct {
+	active, max *int64
+	err         error
+	started     bool
+}
+
+func (l *concurrencyListener) OnWrite(storeKey storetypes.StoreKey, key []byte, value []byte, delete bool) error {
+	if l.started {
+		return nil
+	}
+	l.started = true
+	active := atomic.AddInt64(l.active, 1)
+	defer atomic.AddInt64(l.active, -1)
+	for {
+		max := atomic.LoadInt64(l.max)
+		if active <= max || atomic.CompareAndSwapInt64(l.max, max, active) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return l.err
+}
+
+func TestProcessAllFlushConcurrency(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	const numStores = 4
+	storeKeys := make([]sdk.StoreKey, numStores)
+	for i := range storeKeys {
+		storeKeys[i] = sdk.NewKVStoreKey(fmt.Sprintf("store-%d", i))
+	}
+	newCtx := func() sdk.Context {
+		keys := make(map[string]sdk.StoreKey)
+		stores := make(map[sdk.StoreKey]sdk.CacheWrapper)
+		db := dbm.NewMemDB()
+		for _, key := range storeKeys {
+			stores[key] = cachekv.NewStore(dbadapter.Store{DB: dbm.NewMemDB()}, key, 1000)
+			keys[key.Name()] = key
+		}
+		store := cachemulti.NewStore(db, stores, keys, nil, nil, nil)
+		return sdk.Context{}.WithContext(context.Background()).WithMultiStore(&store).WithLogger(log.NewNopLogger())
+	}
+	// every tx writes its own key in every store
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		for _, key := range storeKeys {
+			ctx.MultiStore().GetKVStore(key).Set([]byte(fmt.Sprintf("tx-%d", ctx.TxIndex())), req.Tx)
+		}
+		return types.ResponseDeliverTx{}
+	}
+	// the listeners of the stores from failingFrom on fail
+	run := func(failingFrom int, opts ...SchedulerOption) (sdk.Context, int64, error) {
+		var active, max int64
+		listeners := make(map[sdk.StoreKey][]storetypes.WriteListener)
+		for i, key := range storeKeys {
+			listener := &concurrencyListener{active: &active, max: &max}
+			if i >= failingFrom {
+				listener.err = fmt.Errorf("store %d failed", i)
+			}
+			listeners[key] = []storetypes.WriteListener{listener}
+		}
+		opts = append(opts, WithWriteListeners(listeners))
+		ctx := newCtx()
+		_, err := NewScheduler(10, ti, deliverTx, opts...).ProcessAll(ctx, requestList(20))
+		return ctx, max, err
+	}
+
+	for _, tc := range []struct {
+		concurrency    int
+		maxConcurrency int64
+	}{
+		{0, numStores},
+		{1, 1},
+		{2, 2},
+	} {
+		t.Run(fmt.Sprintf("concurrency %d", tc.concurrency), func(t *testing.T) {
+			ctx, max, err := run(numStores, WithFlushConcurrency(tc.concurrency))
+			require.NoError(t, err)
+			require.LessOrEqual(t, max, tc.maxConcurrency)
+			if tc.maxConcurrency > 1 {
+				require.Greater(t, max, int64(1))
+			}
+			for _, key := range storeKeys {
+				kv := ctx.MultiStore().GetKVStore(key)
+				for i := 0; i < 20; i++ {
+					require.Equal(t, []byte(fmt.Sprintf("%d", i)), kv.Get([]byte(fmt.Sprintf("tx-%d", i))))
+				}
+			}
+		})
+	}
+
+	t.Run("errors are returned in store order", func(t *testing.T) {
+		_, _, err := run(1)
+		require.EqualError(t, err, "store 1 failed")
+	})
+}
@@ -0,0 +1,112 @@
+package tasks
+
+import (
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// guaranteedDisjoint returns true if every request has guaranteed writeset estimates and no two requests may write
+// the same key, in which case the block can run on the happy path. In strict mode, declared writesets are enforced, so
+// they're guaranteed regardless of their confidence. Txs not expected to write need no estimates: should one write
+// anyway, its writes exceed its estimates, so the block falls back to full OCC.
+func guaranteedDisjoint(reqs []*sdk.DeliverTxEntry, strict bool) bool {
+	if len(reqs) == 0 {
+		return false
+	}
+	declared := make(map[sdk.StoreKey]map[string]struct{})
+	for _, req := range reqs {
+		if req.NoWritesExpected {
+			continue
+		}
+		if req.EstimateConfidence != sdk.EstimateConfidenceGuaranteed && !(strict && req.EstimatedWritesets != nil) {
+			return false
+		}
+		for storeKey, writeset := range req.EstimatedWritesets {
+			if _, ok := declared[storeKey]; !ok {
+				declared[storeKey] = make(map[string]struct{})
+			}
+			for key := range writeset {
+				if _, ok := declared[storeKey][key]; ok {
+					return false
+				}
+				declared[storeKey][key] = struct{}{}
+			}
+		}
+	}
+	return true
+}
+
+// executeHappyPath executes a block whose writesets are guaranteed to be disjoint without recording readsets and
+// without validation. Every key a tx may write is prefilled as an estimate, so a read of a lower-index tx's key aborts
+// until that tx has written it, and since that tx only ever executes to completion once, its writes are final. That
+// makes every read match sequential execution as long as the txs kept to their declared writesets, which is verified
+// once every tx has executed. It returns false if the block has to fall back to full OCC.
+func (s *scheduler) executeHappyPath(ctx sdk.Context, reqs []*sdk.DeliverTxEntry, tasks []*deliverTxTask) (bool, error) {
+	s.happyPath = true
+	defer func() { s.happyPath = false }()
+
+	toExecute := tasks
+	for iterations := 0; len(toExecute) > 0; iterations++ {
+		if iterations >= s.maxIterations {
+			return false, nil
+		}
+		if err := s.executeAll(ctx, toExecute); err != nil {
+			return false, err
+		}
+		// an execution exceeding its limits falls back to sequential execution, which full OCC hands over to
+		if s.taskLimitHit() || s.taskMemoryLimitHit() {
+			return false, nil
+		}
+		// the lowest aborted task only depends on executed tasks, so every round makes progress
+		toExecute = nil
+		for _, t := range tasks {
+			if t.IsStatus(statusAborted) {
+				t.Reset()
+				t.Increment()
+				if t.Incarnation > s.maxIncarnation {
+					s.maxIncarnation = t.Incarnation
+				}
+				toExecute = append(toExecute, t)
+			}
+		}
+	}
+
+	for _, t := range tasks {
+		if !writesWithinEstimates(t, reqs[t.Index].EstimatedWritesets) {
+			return false, nil
+		}
+	}
+	for _, t := range tasks {
+		t.SetStatus(statusValidated)
+	}
+	return true, nil
+}
+
+// writesWithinEstimates returns true if every key written by the task is part of its estimated writesets
+func writesWithinEstimates(task *deliverTxTask, estimates sdk.MappedWritesets) bool {
+	for storeKey, vs := range task.VersionStores {
+		for key := range vs.GetWriteset() {
+			if _, ok := estimates[storeKey][key]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// fallBackToFullOCC discards everything the happy path executed, and resets the block to be processed from scratch
+// with readset tracking and validation
+func (s *scheduler) fallBackToFullOCC(ctx sdk.Context, reqs []*sdk.DeliverTxEntry, tasks []*deliverTxTask) {
+	ctx.Logger().Info("occ scheduler happy path writes exceeded estimates, falling back to full occ", "height", ctx.BlockHeight())
+	telemetry.IncrCounter(1, "scheduler", "happy_path", "fallbacks")
+	s.recycleMultiVersionStores()
+	s.initMultiVersionStore(ctx)
+	s.PrefillEstimates(reqs)
+	for _, t := range tasks {
+		t.Reset()
+		t.Increment()
+		if t.Incarnation > s.maxIncarnation {
+			s.maxIncarnation = t.Incarnation
+		}
+	}
+}
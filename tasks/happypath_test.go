@@ -0,0 +1,82 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// guaranteedRequests returns requests that each declare a guaranteed writeset of their own key
+func guaranteedRequests(n int) []*sdk.DeliverTxEntry {
+	reqs := requestList(n)
+	for i, req := range reqs {
+		req.EstimatedWritesets = sdk.MappedWritesets{
+			testStoreKey: multiversion.WriteSet{fmt.Sprintf("%d", i): nil},
+		}
+		req.EstimateConfidence = sdk.EstimateConfidenceGuaranteed
+	}
+	return reqs
+}
+
+func TestGuaranteedDisjoint(t *testing.T) {
+	require.True(t, guaranteedDisjoint(guaranteedRequests(5), false))
+	require.False(t, guaranteedDisjoint(nil, false))
+
+	hinted := guaranteedRequests(5)
+	hinted[2].EstimateConfidence = sdk.EstimateConfidenceHint
+	require.False(t, guaranteedDisjoint(hinted, false))
+
+	overlapping := guaranteedRequests(5)
+	overlapping[3].EstimatedWritesets[testStoreKey]["1"] = nil
+	require.False(t, guaranteedDisjoint(overlapping, false))
+}
+
+func TestProcessAllHappyPath(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the value written by the previous tx, and tx 7 optionally writes an undeclared key
+	newDeliverTx := func(exceedEstimates bool) func(ctx sdk.Context, req types.RequestDeliverTx) types.ResponseDeliverTx {
+		return func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+			defer abortRecoveryFunc(&response)
+			kv := ctx.MultiStore().GetKVStore(testStoreKey)
+			var prev []byte
+			if ctx.TxIndex() > 0 {
+				prev = kv.Get([]byte(fmt.Sprintf("%d", ctx.TxIndex()-1)))
+			}
+			newVal := string(prev) + fmt.Sprintf("%d,", ctx.TxIndex())
+			kv.Set(req.Tx, []byte(newVal))
+			if exceedEstimates && ctx.TxIndex() == 7 {
+				kv.Set(itemKey, []byte("undeclared"))
+			}
+			return types.ResponseDeliverTx{Info: newVal}
+		}
+	}
+
+	for _, exceedEstimates := range []bool{false, true} {
+		t.Run(fmt.Sprintf("exceed estimates %v", exceedEstimates), func(t *testing.T) {
+			s := NewScheduler(10, ti, newDeliverTx(exceedEstimates))
+			ctx := initTestCtx(true)
+			res, err := s.ProcessAll(ctx, guaranteedRequests(20))
+			require.NoError(t, err)
+
+			// the result always matches sequential execution
+			expected := ""
+			kv := ctx.MultiStore().GetKVStore(testStoreKey)
+			for idx, response := range res {
+				expected += fmt.Sprintf("%d,", idx)
+				require.Equal(t, expected, response.Info)
+				require.Equal(t, []byte(expected), kv.Get([]byte(fmt.Sprintf("%d", idx))))
+			}
+			// writes outside the declared writesets fall back to full occ
+			require.Equal(t, !exceedEstimates, s.Metrics().HappyPath)
+			if exceedEstimates {
+				require.Equal(t, []byte("undeclared"), kv.Get(itemKey))
+			}
+		})
+	}
+}
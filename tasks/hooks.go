@@ -0,0 +1,95 @@
+package tasks
+
+import (
+	"sort"
+
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// TaskInfo identifies an execution of a tx
+type TaskInfo struct {
+	// Index is the index of the tx in the block
+	Index int
+	// Incarnation is the incarnation of the execution, which is the number of times the tx was re-executed before it
+	Incarnation int
+	// Request is the request of the tx
+	Request types.RequestDeliverTx
+}
+
+// taskInfo returns the info of the current execution of a task
+func taskInfo(task *deliverTxTask) TaskInfo {
+	return TaskInfo{Index: task.Index, Incarnation: task.Incarnation, Request: task.Request}
+}
+
+// SchedulerHooks are notified of what the scheduler does while processing blocks, so that integrators can plug in
+// custom logging, mempool feedback (eg. penalizing conflict-heavy txs) or analytics without forking the scheduler.
+// Task hooks are called from the worker goroutines executing the txs, so hooks must be safe for concurrent use, and
+// should return quickly since they hold up the block. Embed BaseSchedulerHooks to only implement some of the hooks.
+type SchedulerHooks interface {
+	// OnTaskExecuted is called when an execution of a tx completes without aborting, with its response. The execution
+	// may still fail validation, in which case the tx is executed again.
+	OnTaskExecuted(info TaskInfo, res types.ResponseDeliverTx)
+	// OnTaskAborted is called when an execution of a tx aborts on a conflict with the tx at abort.DependentTxIdx
+	OnTaskAborted(info TaskInfo, abort occ.Abort)
+	// OnValidationRound is called after each validation round of a block, starting at round 0, with the indices of the
+	// txs that failed validation and are executed again, in order
+	OnValidationRound(round int, invalidated []int)
+	// OnBlockComplete is called once a block is processed, with its metrics
+	OnBlockComplete(metrics SchedulerMetrics)
+}
+
+// BaseSchedulerHooks implements SchedulerHooks with hooks that do nothing
+type BaseSchedulerHooks struct{}
+
+var _ SchedulerHooks = BaseSchedulerHooks{}
+
+func (BaseSchedulerHooks) OnTaskExecuted(TaskInfo, types.ResponseDeliverTx) {}
+
+func (BaseSchedulerHooks) OnTaskAborted(TaskInfo, occ.Abort) {}
+
+func (BaseSchedulerHooks) OnValidationRound(int, []int) {}
+
+func (BaseSchedulerHooks) OnBlockComplete(SchedulerMetrics) {}
+
+// WithSchedulerHooks adds hooks to the scheduler, which are called in the order they were added
+func WithSchedulerHooks(hooks ...SchedulerHooks) SchedulerOption {
+	return func(s *scheduler) { s.hooks = append(s.hooks, hooks...) }
+}
+
+func (s *scheduler) onTaskExecuted(task *deliverTxTask) {
+	for _, h := range s.hooks {
+		h.OnTaskExecuted(taskInfo(task), *task.Response)
+	}
+}
+
+func (s *scheduler) onTaskAborted(task *deliverTxTask, abort occ.Abort) {
+	for _, h := range s.hooks {
+		h.OnTaskAborted(taskInfo(task), abort)
+	}
+}
+
+func (s *scheduler) onValidationRound(round int, invalidated []*deliverTxTask) {
+	if len(s.hooks) == 0 {
+		return
+	}
+	indices := make([]int, 0, len(invalidated))
+	for _, t := range invalidated {
+		indices = append(indices, t.Index)
+	}
+	sort.Ints(indices)
+	for _, h := range s.hooks {
+		h.OnValidationRound(round, indices)
+	}
+}
+
+func (s *scheduler) onBlockComplete() {
+	if len(s.hooks) == 0 {
+		return
+	}
+	metrics := s.metrics.snapshot()
+	for _, h := range s.hooks {
+		h.OnBlockComplete(metrics)
+	}
+}
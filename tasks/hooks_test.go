@@ -0,0 +1,106 @@
+package tasks
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// recordingHooks records the calls of every hook
+type recordingHooks struct {
+	mx       sync.Mutex
+	executed map[int][]TaskInfo
+	aborts   int
+	rounds   []int
+	blocks   []SchedulerMetrics
+	lastData map[int]string
+}
+
+func (h *recordingHooks) OnTaskExecuted(info TaskInfo, res types.ResponseDeliverTx) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.executed[info.Index] = append(h.executed[info.Index], info)
+	h.lastData[info.Index] = string(res.Data)
+}
+
+func (h *recordingHooks) OnTaskAborted(info TaskInfo, abort occ.Abort) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if abort.DependentTxIdx >= info.Index {
+		panic("aborted on a later tx")
+	}
+	h.aborts++
+}
+
+func (h *recordingHooks) OnValidationRound(round int, invalidated []int) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.rounds = append(h.rounds, round)
+}
+
+func (h *recordingHooks) OnBlockComplete(metrics SchedulerMetrics) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	h.blocks = append(h.blocks, metrics)
+}
+
+// blockHooks only implements OnBlockComplete, recording the order hooks are called in
+type blockHooks struct {
+	BaseSchedulerHooks
+	recorded *recordingHooks
+	order    []int
+}
+
+func (h *blockHooks) OnBlockComplete(SchedulerMetrics) {
+	h.order = append(h.order, len(h.recorded.blocks))
+}
+
+func TestProcessAllSchedulerHooks(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx increments a shared counter, so that they conflict and get re-executed
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		count, _ := strconv.Atoi(string(kv.Get(itemKey)))
+		kv.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		return types.ResponseDeliverTx{Data: []byte(strconv.Itoa(count))}
+	}
+
+	const txs = 30
+	recorded := &recordingHooks{executed: make(map[int][]TaskInfo), lastData: make(map[int]string)}
+	block := &blockHooks{recorded: recorded}
+	s := NewScheduler(10, ti, deliverTx, WithSchedulerHooks(recorded), WithSchedulerHooks(block))
+	res, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	m := s.Metrics()
+
+	// the last execution of every tx is its final one
+	for idx, response := range res {
+		executions := recorded.executed[idx]
+		require.NotEmpty(t, executions)
+		last := executions[len(executions)-1]
+		require.Equal(t, m.Incarnations[idx], last.Incarnation)
+		require.Equal(t, []byte(strconv.Itoa(idx)), last.Request.Tx)
+		require.Equal(t, string(response.Data), recorded.lastData[idx])
+	}
+	require.Equal(t, m.Aborts, recorded.aborts)
+
+	// rounds are numbered from 0
+	require.NotEmpty(t, recorded.rounds)
+	for i, round := range recorded.rounds {
+		require.Equal(t, i, round)
+	}
+
+	// the block completes once, and hooks are called in the order they were added
+	require.Len(t, recorded.blocks, 1)
+	require.Equal(t, txs, recorded.blocks[0].Txs)
+	require.Equal(t, m.Incarnations, recorded.blocks[0].Incarnations)
+	require.Equal(t, []int{1}, block.order)
+}
@@ -0,0 +1,129 @@
+package tasks
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	metrics "github.com/armon/go-metrics"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// HotKey is a key that invalidated txs of a block, eg. a contract storage slot that serializes execution
+type HotKey struct {
+	Store string `json:"store"`
+	Key   []byte `json:"key"`
+	// Conflicts is the number of validations of txs the key failed, counting every validation round
+	Conflicts int `json:"conflicts"`
+	// Writers are the indices of the txs whose writes of the key invalidated readers, sorted. Readers of the parent
+	// store that no longer matched it, since the write they read was reverted, have no writer.
+	Writers []int `json:"writers"`
+}
+
+// WithHotKeyReport has the scheduler aggregate the keys responsible for the invalidations of every block it
+// processes, and report the topN keys with the most conflicts: they're logged, emitted as telemetry labelled by store
+// and key, and kept in SchedulerMetrics.HotKeys. Only reads and existence checks are attributed to keys, iterators
+// that fail validation aren't.
+func WithHotKeyReport(topN int) SchedulerOption {
+	return func(s *scheduler) { s.hotKeyReport = topN }
+}
+
+// hotKeyStat is the invalidations of a key within a block
+type hotKeyStat struct {
+	conflicts int
+	writers   map[int]struct{}
+}
+
+// hotKeys aggregates the invalidations of a block by key. It's notified concurrently by the validations of the
+// multiversion stores.
+type hotKeys struct {
+	mx   sync.Mutex
+	keys map[multiversion.QualifiedKey]*hotKeyStat
+}
+
+var _ multiversion.InvalidationListener = (*hotKeys)(nil)
+
+// newHotKeys returns the collector of the hot keys of the next block, or nil if they aren't reported
+func (s *scheduler) newHotKeys() *hotKeys {
+	if s.hotKeyReport <= 0 {
+		return nil
+	}
+	return &hotKeys{keys: make(map[multiversion.QualifiedKey]*hotKeyStat)}
+}
+
+// OnInvalidation implements multiversion.InvalidationListener
+func (h *hotKeys) OnInvalidation(storeName string, _ int, key string, writer int) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	qk := multiversion.QualifiedKey{Store: storeName, Key: key}
+	stat, ok := h.keys[qk]
+	if !ok {
+		stat = &hotKeyStat{writers: make(map[int]struct{})}
+		h.keys[qk] = stat
+	}
+	stat.conflicts++
+	if writer >= 0 {
+		stat.writers[writer] = struct{}{}
+	}
+}
+
+// top returns the n keys with the most conflicts, most first, breaking ties by store and key
+func (h *hotKeys) top(n int) []HotKey {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	hot := make([]HotKey, 0, len(h.keys))
+	for qk, stat := range h.keys {
+		writers := make([]int, 0, len(stat.writers))
+		for writer := range stat.writers {
+			writers = append(writers, writer)
+		}
+		sort.Ints(writers)
+		hot = append(hot, HotKey{Store: qk.Store, Key: []byte(qk.Key), Conflicts: stat.conflicts, Writers: writers})
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].Conflicts != hot[j].Conflicts {
+			return hot[i].Conflicts > hot[j].Conflicts
+		}
+		if hot[i].Store != hot[j].Store {
+			return hot[i].Store < hot[j].Store
+		}
+		return bytes.Compare(hot[i].Key, hot[j].Key) < 0
+	})
+	if len(hot) > n {
+		hot = hot[:n]
+	}
+	return hot
+}
+
+// reportHotKeys logs the hot keys of the block and keeps them in its metrics, if they're reported
+func (s *scheduler) reportHotKeys(ctx sdk.Context) {
+	if s.metrics.hotKeys == nil {
+		return
+	}
+	s.metrics.topHotKeys = s.metrics.hotKeys.top(s.hotKeyReport)
+	for rank, hot := range s.metrics.topHotKeys {
+		ctx.Logger().Info("occ scheduler hot key",
+			"height", ctx.BlockHeight(),
+			"rank", rank+1,
+			"store", hot.Store,
+			"key", fmt.Sprintf("%X", hot.Key),
+			"conflicts", hot.Conflicts,
+			"writers", hot.Writers,
+		)
+	}
+}
+
+// emitHotKeys emits the conflicts of the hot keys of the block, labelled by store and key
+func emitHotKeys(hot []HotKey) {
+	for _, h := range hot {
+		telemetry.IncrCounterWithLabels(
+			[]string{"scheduler", "hot_key", "conflicts"},
+			float32(h.Conflicts),
+			[]metrics.Label{telemetry.NewLabel("store", h.Store), telemetry.NewLabel("key", fmt.Sprintf("%X", h.Key))},
+		)
+	}
+}
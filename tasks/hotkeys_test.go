@@ -0,0 +1,72 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestHotKeysTop(t *testing.T) {
+	s := &scheduler{hotKeyReport: 2}
+	h := s.newHotKeys()
+	h.OnInvalidation("bank", 3, "balance", 1)
+	h.OnInvalidation("bank", 4, "balance", 2)
+	h.OnInvalidation("bank", 5, "balance", 1)
+	h.OnInvalidation("wasm", 5, "slot", 2)
+	h.OnInvalidation("acc", 4, "seq", -1)
+	h.OnInvalidation("acc", 6, "seq", 5)
+
+	// keys are ranked by conflicts, ties broken by store and key, and reverted reads have no writer
+	require.Equal(t, []HotKey{
+		{Store: "bank", Key: []byte("balance"), Conflicts: 3, Writers: []int{1, 2}},
+		{Store: "acc", Key: []byte("seq"), Conflicts: 2, Writers: []int{5}},
+	}, h.top(2))
+	require.Len(t, h.top(10), 3)
+
+	// hot keys aren't collected unless they're reported
+	require.Nil(t, (&scheduler{}).newHotKeys())
+}
+
+func TestProcessAllHotKeyReport(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the same key, and writes a key of its own
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		// executions overlap between the read and the write, so that they conflict
+		time.Sleep(time.Millisecond)
+		newVal := val + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		kv.Set(req.Tx, []byte(newVal))
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	s := NewScheduler(10, ti, deliverTx, WithHotKeyReport(3))
+	_, err := s.ProcessAll(initTestCtx(true), requestList(20))
+	require.NoError(t, err)
+	m := s.Metrics()
+	require.NotZero(t, m.Retries)
+	require.Len(t, m.HotKeys, 1)
+	require.Equal(t, testStoreKey.Name(), m.HotKeys[0].Store)
+	require.Equal(t, itemKey, m.HotKeys[0].Key)
+	require.NotZero(t, m.HotKeys[0].Conflicts)
+	require.NotEmpty(t, m.HotKeys[0].Writers)
+
+	// the next block starts from scratch, and blocks without invalidations have no hot keys
+	_, err = s.ProcessAll(initTestCtx(true), requestList(1))
+	require.NoError(t, err)
+	require.Empty(t, s.Metrics().HotKeys)
+
+	// without the report, hot keys aren't collected
+	s = NewScheduler(10, ti, deliverTx)
+	_, err = s.ProcessAll(initTestCtx(true), requestList(20))
+	require.NoError(t, err)
+	require.Empty(t, s.Metrics().HotKeys)
+}
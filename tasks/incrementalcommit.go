@@ -0,0 +1,57 @@
+package tasks
+
+// WithIncrementalCommit has the scheduler write the final writes of the validated prefix of a block to the parent
+// stores as the prefix grows, after every validation round, rather than all at once after the last round. The versions
+// of the committed txs are removed from the multiversion stores (see multiversion.Store.CommitBefore), which bounds the
+// state a long block holds to the txs that aren't final yet, and spreads the writes to the parent stores over the
+// block. The prefix is the one of the last checkpoint, which rollbacks never invalidate, and it stops at the first tx
+// that exceeds the block gas limit, since its writes are discarded once gas is committed.
+//
+// Since the parent stores are written before the block is done, a block that fails leaves them partially written, so
+// blocks should be processed against a branch that's discarded if ProcessAll returns an error, like DeliverTxs does.
+// Committed txs can't be validated again, and the final writesets of the block are no longer held in full once it's
+// done, so stores with write listeners are never committed incrementally, nor is any store if spot checks, writeset
+// hashing or change set exports are enabled, or while a block is simulated.
+func WithIncrementalCommit() SchedulerOption {
+	return func(s *scheduler) { s.incrementalCommit = true }
+}
+
+// commitsIncrementally returns whether the multiversion stores of the block are committed incrementally, apart from
+// those with write listeners
+func (s *scheduler) commitsIncrementally() bool {
+	return s.incrementalCommit && s.spotCheckRate <= 0 && !s.writesetHashing && s.changeSetExporter == nil &&
+		s.simulation == nil
+}
+
+// commitValidated writes the final writes of the validated prefix of the last checkpoint to the parent stores, if
+// committing incrementally. A panic of a store is returned as an ErrStoreInconsistency error, like when flushing.
+func (s *scheduler) commitValidated() error {
+	if !s.commitsIncrementally() || s.lastCheckpoint == nil {
+		return nil
+	}
+	index := s.lastCheckpoint.validated
+	if index <= s.committed {
+		return nil
+	}
+	for _, mv := range s.orderedStores {
+		if len(s.writeListeners[mv.key]) > 0 {
+			continue
+		}
+		if err := s.commitStore(mv, index); err != nil {
+			return err
+		}
+	}
+	s.committed = index
+	return nil
+}
+
+// commitStore writes the final writes of the txs before index from a multiversion store to its parent store
+func (s *scheduler) commitStore(mv keyedMultiVersionStore, index int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = storeInconsistency(mv, r)
+		}
+	}()
+	s.metrics.committedKeys += mv.store.CommitBefore(index)
+	return nil
+}
@@ -0,0 +1,107 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	store "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllIncrementalCommit(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the same hot key, and writes its own key with the number of txs that wrote theirs
+	// before it, found with an iterator
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		written := 0
+		it := kv.Iterator([]byte("tx-"), []byte("tx."))
+		for ; it.Valid(); it.Next() {
+			written++
+		}
+		it.Close()
+		kv.Set([]byte(fmt.Sprintf("tx-%03d", ctx.TxIndex())), []byte(fmt.Sprintf("%d", written)))
+		return types.ResponseDeliverTx{Info: fmt.Sprintf("%s%d", newVal, written)}
+	}
+	state := func(ctx sdk.Context) map[string]string {
+		kvs := make(map[string]string)
+		it := ctx.MultiStore().GetKVStore(testStoreKey).Iterator(nil, nil)
+		defer it.Close()
+		for ; it.Valid(); it.Next() {
+			kvs[string(it.Key())] = string(it.Value())
+		}
+		return kvs
+	}
+
+	for _, opts := range [][]SchedulerOption{
+		{WithIncrementalCommit()},
+		{WithIncrementalCommit(), WithVersionPruning()},
+		{WithIncrementalCommit(), WithLookahead(8)},
+	} {
+		expectedCtx := initTestCtx(true)
+		expected, err := NewSynchronousScheduler(ti, deliverTx).ProcessAll(expectedCtx, requestList(50))
+		require.NoError(t, err)
+
+		ctx := initTestCtx(true)
+		s := NewScheduler(10, ti, deliverTx, opts...)
+		res, err := s.ProcessAll(ctx, requestList(50))
+		require.NoError(t, err)
+		require.Equal(t, expected, res)
+		require.Equal(t, state(expectedCtx), state(ctx))
+		require.Equal(t, 50, s.Metrics().CommittedTxs)
+		require.Positive(t, s.Metrics().CommittedKeys)
+	}
+}
+
+func TestCommitValidated(t *testing.T) {
+	newScheduler := func(opts ...SchedulerOption) (*scheduler, multiversion.MultiVersionStore) {
+		s := NewScheduler(1, nil, nil, append([]SchedulerOption{WithIncrementalCommit()}, opts...)...).(*scheduler)
+		s.initMultiVersionStore(initTestCtx(true))
+		mvs := s.multiVersionStores[testStoreKey]
+		for i := 0; i < 10; i++ {
+			mvs.SetWriteset(i, 0, multiversion.WriteSet{string(itemKey): []byte(fmt.Sprintf("%d", i))})
+		}
+		return s, mvs
+	}
+
+	// nothing is committed before the first checkpoint
+	s, mvs := newScheduler()
+	require.NoError(t, s.commitValidated())
+	require.Zero(t, mvs.CommittedIndex())
+
+	s.lastCheckpoint = &schedulerCheckpoint{validated: 6}
+	require.NoError(t, s.commitValidated())
+	require.Equal(t, 6, mvs.CommittedIndex())
+	require.Equal(t, 6, s.committed)
+	require.Nil(t, mvs.GetLatestBeforeIndex(6, itemKey))
+	require.Equal(t, []byte("6"), mvs.GetLatestBeforeIndex(7, itemKey).Value())
+
+	// stores with write listeners keep every version to stream them
+	listeners := map[sdk.StoreKey][]store.WriteListener{testStoreKey: {nil}}
+	s, mvs = newScheduler(WithWriteListeners(listeners))
+	s.lastCheckpoint = &schedulerCheckpoint{validated: 6}
+	require.NoError(t, s.commitValidated())
+	require.Zero(t, mvs.CommittedIndex())
+	require.Equal(t, []byte("5"), mvs.GetLatestBeforeIndex(6, itemKey).Value())
+}
+
+func TestCommitsIncrementally(t *testing.T) {
+	require.False(t, NewScheduler(1, nil, nil).(*scheduler).commitsIncrementally())
+	require.True(t, NewScheduler(1, nil, nil, WithIncrementalCommit()).(*scheduler).commitsIncrementally())
+
+	// these need the final writesets of the block in full, or re-validate final txs
+	for _, opt := range []SchedulerOption{
+		WithValidationSpotChecks(0.1, nil),
+		WithWritesetHashing(),
+	} {
+		require.False(t, NewScheduler(1, nil, nil, WithIncrementalCommit(), opt).(*scheduler).commitsIncrementally())
+	}
+}
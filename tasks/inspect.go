@@ -0,0 +1,182 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	gogogrpc "github.com/gogo/protobuf/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+// TaskState is the status of a tx of the block being processed, as inspected
+type TaskState struct {
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+}
+
+// BlockState is a snapshot of the multiversion state of the block being processed, for operators to inspect live OCC
+// behavior on a stuck or slow block
+type BlockState struct {
+	Height int64       `json:"height"`
+	Tasks  []TaskState `json:"tasks"`
+	// Stores are the states of the multiversion stores of the block, by store key name
+	Stores map[string]multiversion.StoreState `json:"stores"`
+}
+
+// Inspector exposes the multiversion state of the block a scheduler is processing, see Inspect. It's safe for
+// concurrent use, and meant to be shared by the schedulers of consecutive blocks, so that it always shows the current
+// block. It's only a debugging aid: taking a snapshot of a large block contends with its processing.
+type Inspector struct {
+	mx     sync.RWMutex
+	height int64
+	stores []keyedMultiVersionStore
+	tasks  []*deliverTxTask
+}
+
+// NewInspector creates an inspector that's empty until a scheduler processes a block with it
+func NewInspector() *Inspector {
+	return &Inspector{}
+}
+
+// WithInspector has the scheduler expose the multiversion state of the blocks it processes through the inspector
+func WithInspector(inspector *Inspector) SchedulerOption {
+	return func(s *scheduler) { s.inspector = inspector }
+}
+
+// setStores publishes the multiversion stores of the block being processed
+func (i *Inspector) setStores(height int64, stores []keyedMultiVersionStore) {
+	if i == nil {
+		return
+	}
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	i.height = height
+	i.stores = stores
+}
+
+// setTasks publishes the tasks of the block being processed
+func (i *Inspector) setTasks(tasks []*deliverTxTask) {
+	if i == nil {
+		return
+	}
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	i.tasks = tasks
+}
+
+// clear unpublishes the block, before its multiversion stores are reset, so that they aren't inspected meanwhile
+func (i *Inspector) clear() {
+	if i == nil {
+		return
+	}
+	i.mx.Lock()
+	defer i.mx.Unlock()
+	i.height = 0
+	i.stores = nil
+	i.tasks = nil
+}
+
+// Inspect returns a snapshot of the block being processed, or false if there's none. The snapshot isn't consistent
+// across txs and keys, since the block keeps being processed while it's taken.
+func (i *Inspector) Inspect() (BlockState, bool) {
+	i.mx.RLock()
+	defer i.mx.RUnlock()
+	if i.stores == nil {
+		return BlockState{}, false
+	}
+	state := BlockState{
+		Height: i.height,
+		Tasks:  make([]TaskState, 0, len(i.tasks)),
+		Stores: make(map[string]multiversion.StoreState, len(i.stores)),
+	}
+	for _, t := range i.tasks {
+		state.Tasks = append(state.Tasks, TaskState{Index: t.Index, Status: t.LoadStatus().String()})
+	}
+	for _, mv := range i.stores {
+		state.Stores[mv.key.Name()] = mv.store.Inspect()
+	}
+	return state, true
+}
+
+// ServeHTTP serves the state of the block being processed as JSON, or 503 if there's none
+func (i *Inspector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	state, ok := i.Inspect()
+	if !ok {
+		http.Error(w, "no block is being processed", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(state)
+}
+
+// inspectorServiceName is the name of the gRPC service serving the inspector
+const inspectorServiceName = "cosmos.occ.v1.Inspector"
+
+// inspectorServer is the gRPC service serving the inspector. It's described by hand with well-known protobuf types
+// rather than generated, since the block state is only meant for operators, eg. through grpcurl: the request is empty,
+// and the response is the block state as a struct with the same fields as its JSON encoding.
+type inspectorServer interface {
+	BlockState(ctx context.Context, req *emptypb.Empty) (*structpb.Struct, error)
+}
+
+var _ inspectorServer = (*Inspector)(nil)
+
+// BlockState implements the BlockState method of the inspector gRPC service, failing with Unavailable if no block is
+// being processed
+func (i *Inspector) BlockState(_ context.Context, _ *emptypb.Empty) (*structpb.Struct, error) {
+	state, ok := i.Inspect()
+	if !ok {
+		return nil, grpcstatus.Error(codes.Unavailable, "no block is being processed")
+	}
+	bz, err := json.Marshal(state)
+	if err != nil {
+		return nil, grpcstatus.Error(codes.Internal, err.Error())
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(bz, &fields); err != nil {
+		return nil, grpcstatus.Error(codes.Internal, err.Error())
+	}
+	res, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, grpcstatus.Error(codes.Internal, err.Error())
+	}
+	return res, nil
+}
+
+var inspectorServiceDesc = grpc.ServiceDesc{
+	ServiceName: inspectorServiceName,
+	HandlerType: (*inspectorServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "BlockState",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := new(emptypb.Empty)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				if interceptor == nil {
+					return srv.(inspectorServer).BlockState(ctx, req)
+				}
+				info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + inspectorServiceName + "/BlockState"}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(inspectorServer).BlockState(ctx, req.(*emptypb.Empty))
+				}
+				return interceptor(ctx, req, info, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// RegisterInspectorService registers the gRPC service serving the state of the block being processed with the server
+func RegisterInspectorService(server gogogrpc.Server, inspector *Inspector) {
+	server.RegisterService(&inspectorServiceDesc, inspector)
+}
@@ -0,0 +1,118 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestInspectorServesLiveBlock(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// the inspector is served over gRPC
+	inspector := NewInspector()
+	server := grpc.NewServer()
+	RegisterInspectorService(server, inspector)
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	blockState := func() (*structpb.Struct, error) {
+		res := new(structpb.Struct)
+		err := conn.Invoke(context.Background(), "/"+inspectorServiceName+"/BlockState", &emptypb.Empty{}, res)
+		return res, err
+	}
+
+	// nothing is inspected outside of a block
+	_, ok := inspector.Inspect()
+	require.False(t, ok)
+	_, err = blockState()
+	require.Equal(t, codes.Unavailable, grpcstatus.Code(err))
+	rec := httptest.NewRecorder()
+	inspector.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	// every tx writes a key of its own, while the first execution of tx 5 is stuck until released
+	const txs = 10
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		kv.Get(itemKey)
+		kv.Set(req.Tx, req.Tx)
+		if ctx.TxIndex() == 5 {
+			once.Do(func() {
+				close(entered)
+				<-release
+			})
+		}
+		return types.ResponseDeliverTx{}
+	}
+
+	s := NewScheduler(4, ti, deliverTx, WithInspector(inspector))
+	done := make(chan error)
+	go func() {
+		_, err := s.ProcessAll(initTestCtx(true).WithBlockHeight(9), requestList(txs))
+		done <- err
+	}()
+	<-entered
+
+	state, ok := inspector.Inspect()
+	require.True(t, ok)
+	require.Equal(t, int64(9), state.Height)
+	require.Len(t, state.Tasks, txs)
+	for idx, task := range state.Tasks {
+		require.Equal(t, idx, task.Index)
+	}
+	// the stuck tx hasn't written anything yet
+	require.Equal(t, statusPending.String(), state.Tasks[5].Status)
+	storeState, ok := state.Stores[testStoreKey.Name()]
+	require.True(t, ok)
+	for _, key := range storeState.Keys {
+		require.NotEqual(t, "5", string(key.Key))
+	}
+
+	res, err := blockState()
+	require.NoError(t, err)
+	require.Equal(t, float64(9), res.Fields["height"].GetNumberValue())
+	require.Len(t, res.Fields["tasks"].GetListValue().GetValues(), txs)
+
+	rec = httptest.NewRecorder()
+	inspector.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var served BlockState
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &served))
+	require.Equal(t, int64(9), served.Height)
+	require.Len(t, served.Tasks, txs)
+
+	close(release)
+	require.NoError(t, <-done)
+
+	// the block is no longer inspected once it's done
+	_, ok = inspector.Inspect()
+	require.False(t, ok)
+	_, err = blockState()
+	require.Equal(t, codes.Unavailable, grpcstatus.Code(err))
+}
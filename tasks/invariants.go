@@ -0,0 +1,88 @@
+package tasks
+
+import (
+	"sync"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// BrokenInvariantHandler handles an invariant found broken once the writes of a block were flushed, with the message
+// returned by the invariant, eg. by halting the chain like the crisis module does
+type BrokenInvariantHandler func(ctx sdk.Context, moduleName, route, msg string)
+
+// invariantRoute is an invariant registered with InvariantChecks
+type invariantRoute struct {
+	moduleName string
+	route      string
+	invariant  sdk.Invariant
+}
+
+// InvariantChecks are module invariants asserted by the scheduler once the final writes of a block are flushed from
+// the multiversion stores into the parent stores. Checking invariants mid-block under OCC is unsafe, since the state
+// a tx sees isn't final until the block is validated, and an invariant reading broad swaths of state would conflict
+// with every tx of the block, so they're deferred until the writes of the block are committed to the parent cache,
+// where they see the same state as after sequential execution. It implements sdk.InvariantRegistry, so modules
+// register their invariants with it the same way as with the crisis module. It's safe for concurrent use, and meant
+// to be shared by the schedulers of consecutive blocks.
+type InvariantChecks struct {
+	mx       sync.RWMutex
+	routes   []invariantRoute
+	period   uint
+	onBroken BrokenInvariantHandler
+}
+
+var _ sdk.InvariantRegistry = (*InvariantChecks)(nil)
+
+// NewInvariantChecks creates checks asserted every period blocks, like the crisis module's invariant check period,
+// and never if it's 0, with broken invariants handled by onBroken
+func NewInvariantChecks(period uint, onBroken BrokenInvariantHandler) *InvariantChecks {
+	return &InvariantChecks{period: period, onBroken: onBroken}
+}
+
+// WithInvariantChecks has the scheduler assert the invariants once the writes of every block it processes are
+// flushed, against a branch of the parent stores so that the invariants can't change the state of the block.
+// Simulated blocks aren't checked.
+func WithInvariantChecks(checks *InvariantChecks) SchedulerOption {
+	return func(s *scheduler) { s.invariantChecks = checks }
+}
+
+// RegisterRoute implements sdk.InvariantRegistry.
+func (c *InvariantChecks) RegisterRoute(moduleName, route string, invar sdk.Invariant) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.routes = append(c.routes, invariantRoute{moduleName: moduleName, route: route, invariant: invar})
+}
+
+// Len returns the number of registered invariants
+func (c *InvariantChecks) Len() int {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	return len(c.routes)
+}
+
+// due returns whether the invariants are asserted at the given height
+func (c *InvariantChecks) due(height int64) bool {
+	return c.period > 0 && height%int64(c.period) == 0
+}
+
+// assert asserts every registered invariant in registration order, handing the broken ones to the handler
+func (c *InvariantChecks) assert(ctx sdk.Context) {
+	c.mx.RLock()
+	routes := c.routes
+	c.mx.RUnlock()
+	for _, r := range routes {
+		if msg, broken := r.invariant(ctx); broken {
+			c.onBroken(ctx, r.moduleName, r.route, msg)
+		}
+	}
+}
+
+// assertInvariants asserts the invariant checks, if any are due, once the writes of the block were flushed into the
+// parent stores of ctx
+func (s *scheduler) assertInvariants(ctx sdk.Context) {
+	if s.invariantChecks == nil || s.simulation != nil || !s.invariantChecks.due(ctx.BlockHeight()) {
+		return
+	}
+	cacheCtx, _ := ctx.WithGasMeter(sdk.NewInfiniteGasMeter()).CacheContext()
+	s.invariantChecks.assert(cacheCtx)
+}
@@ -0,0 +1,64 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllInvariantChecks(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the same key
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	const txs = 20
+	expected := ""
+	for i := 0; i < txs; i++ {
+		expected += fmt.Sprintf("%d,", i)
+	}
+
+	var broken []string
+	checks := NewInvariantChecks(2, func(ctx sdk.Context, moduleName, route, msg string) {
+		broken = append(broken, fmt.Sprintf("%s/%s: %s", moduleName, route, msg))
+	})
+	// the invariants see the final writes of the block, and can't change them
+	checked := 0
+	checks.RegisterRoute("test", "final", func(ctx sdk.Context) (string, bool) {
+		checked++
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		value := string(kv.Get(itemKey))
+		kv.Set(itemKey, []byte("overwritten"))
+		return value, value != expected
+	})
+	checks.RegisterRoute("test", "broken", func(ctx sdk.Context) (string, bool) { return "always", true })
+	require.Equal(t, 2, checks.Len())
+
+	s := NewScheduler(10, ti, deliverTx, WithInvariantChecks(checks))
+	ctx := initTestCtx(true).WithBlockHeight(4)
+	_, err := s.ProcessAll(ctx, requestList(txs))
+	require.NoError(t, err)
+	require.Equal(t, 1, checked)
+	require.Equal(t, []string{"test/broken: always"}, broken)
+	require.Equal(t, []byte(expected), ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey))
+
+	// invariants are only checked every period blocks
+	_, err = s.ProcessAll(initTestCtx(true).WithBlockHeight(5), requestList(txs))
+	require.NoError(t, err)
+	require.Equal(t, 1, checked)
+
+	// simulated blocks aren't checked
+	_, err = s.(*scheduler).SimulateBlock(initTestCtx(true).WithBlockHeight(6), requestList(txs))
+	require.NoError(t, err)
+	require.Equal(t, 1, checked)
+}
@@ -0,0 +1,87 @@
+package tasks
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// ErrTaskLimitExceeded is the cause of the fallback of a block with a tx that exceeded the limits of its tx limiter,
+// see WithTxLimiter
+var ErrTaskLimitExceeded = errors.New("occ task exceeded its resource limits")
+
+// WithTxLimiter sets a constructor for the resource limiter of each tx execution. A fresh limiter is shared by all of
+// an execution's version indexed stores, so that limits apply per tx across stores. An execution exceeding its limits
+// is stopped, so that a runaway tx can't tie up a worker with speculative work: it's aborted, and the block falls back
+// to sequential execution, which executes the tx once, without a limiter. Like WithTrackingLimits the tx doesn't fail,
+// so the limits don't bear on the responses of the block and may differ between nodes.
+func WithTxLimiter(newLimiter func() multiversion.Limiter) SchedulerOption {
+	return func(s *scheduler) { s.newLimiter = newLimiter }
+}
+
+// limitRecorder records whether an execution exceeded the limits of its limiter, since the tx may have recovered the
+// panic of its version store (runTx does), so the response can't be relied on
+type limitRecorder struct {
+	limiter  multiversion.Limiter
+	exceeded int32
+}
+
+var _ multiversion.Limiter = (*limitRecorder)(nil)
+
+// Consume implements multiversion.Limiter.
+func (l *limitRecorder) Consume(op multiversion.Operation) *occ.LimitExceeded {
+	exceeded := l.limiter.Consume(op)
+	if exceeded != nil {
+		atomic.StoreInt32(&l.exceeded, 1)
+	}
+	return exceeded
+}
+
+// Exceeded returns whether any operation exceeded the limits
+func (l *limitRecorder) Exceeded() bool {
+	return atomic.LoadInt32(&l.exceeded) != 0
+}
+
+// newTaskLimiter returns the limiter of a new execution, or nil if the execution isn't limited. Sequential execution
+// runs every tx once and to completion, the same way as baseapp does without the scheduler, so it isn't limited.
+func (s *scheduler) newTaskLimiter() *limitRecorder {
+	if s.newLimiter == nil || s.synchronous {
+		return nil
+	}
+	limiter := s.newLimiter()
+	if s.concurrentStoreAccess {
+		limiter = multiversion.NewSyncLimiter(limiter)
+	}
+	return &limitRecorder{limiter: limiter}
+}
+
+// taskLimitExceededBy returns whether the execution of a task exceeded the limits of its limiter and must be executed
+// again sequentially
+func (s *scheduler) taskLimitExceededBy(task *deliverTxTask) bool {
+	return task.Limiter != nil && task.Limiter.Exceeded()
+}
+
+// onTaskLimitExceeded leaves a task that exceeded its limits aborted with its writes marked as estimates, to be
+// re-executed once the block falls back to sequential execution
+func (s *scheduler) onTaskLimitExceeded(task *deliverTxTask) {
+	task.SetStatus(statusAborted)
+	s.writeAbortEstimates(task)
+	atomic.StoreInt32(&s.taskLimitExceeded, 1)
+}
+
+// taskLimitHit returns whether an execution of the block exceeded the limits of its limiter
+func (s *scheduler) taskLimitHit() bool {
+	return atomic.LoadInt32(&s.taskLimitExceeded) != 0
+}
+
+// handleTaskLimits falls back to sequential execution if an execution of the block exceeded the limits of its limiter
+func (s *scheduler) handleTaskLimits(ctx sdk.Context) {
+	if !s.taskLimitHit() {
+		return
+	}
+	s.recordFallback(ctx, FallbackTaskLimit, ErrTaskLimitExceeded)
+	s.synchronous = true
+}
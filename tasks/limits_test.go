@@ -0,0 +1,94 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllTxLimiter(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the same key, while tx 3 also reads a range of keys of its own
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if ctx.TxIndex() == 3 {
+			for i := 0; i < 50; i++ {
+				kv.Get([]byte(fmt.Sprintf("scan/%d", i)))
+			}
+		}
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+	limited := WithTxLimiter(func() multiversion.Limiter {
+		return multiversion.NewOperationLimiter(20, 0)
+	})
+
+	// the tx exceeding its limits doesn't fail, the block falls back to sequential execution instead
+	const txs = 10
+	s := NewScheduler(4, ti, deliverTx, limited)
+	res, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	expected := ""
+	for i, r := range res {
+		expected += fmt.Sprintf("%d,", i)
+		require.Equal(t, uint32(0), r.Code)
+		require.Equal(t, expected, r.Info)
+	}
+
+	metrics := s.Metrics()
+	require.True(t, metrics.Synchronous)
+	require.NotNil(t, metrics.Postmortem)
+	require.Equal(t, FallbackTaskLimit, metrics.Postmortem.Reason)
+	require.Equal(t, ErrTaskLimitExceeded.Error(), metrics.Postmortem.Cause)
+
+	_, err = VerifySequential(initTestCtx(true), requestList(txs), 4, ti, deliverTx, limited)
+	require.NoError(t, err)
+
+	// within the limits nothing falls back
+	s = NewScheduler(4, ti, deliverTx, WithTxLimiter(func() multiversion.Limiter {
+		return multiversion.NewOperationLimiter(100, 0)
+	}))
+	_, err = s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	require.Nil(t, s.Metrics().Postmortem)
+}
+
+func TestProcessAllTxLimiterHappyPath(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx writes its own declared key, while tx 7 also reads a range of keys
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if ctx.TxIndex() == 7 {
+			for i := 0; i < 50; i++ {
+				kv.Get([]byte(fmt.Sprintf("scan/%d", i)))
+			}
+		}
+		kv.Set(req.Tx, []byte(fmt.Sprintf("%d", ctx.TxIndex())))
+		return types.ResponseDeliverTx{Info: fmt.Sprintf("%d", ctx.TxIndex())}
+	}
+
+	s := NewScheduler(10, ti, deliverTx, WithTxLimiter(func() multiversion.Limiter {
+		return multiversion.NewOperationLimiter(20, 0)
+	}))
+	ctx := initTestCtx(true)
+	res, err := s.ProcessAll(ctx, guaranteedRequests(20))
+	require.NoError(t, err)
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	for idx, response := range res {
+		require.Equal(t, uint32(0), response.Code)
+		require.Equal(t, fmt.Sprintf("%d", idx), response.Info)
+		require.Equal(t, []byte(fmt.Sprintf("%d", idx)), kv.Get([]byte(fmt.Sprintf("%d", idx))))
+	}
+	require.False(t, s.Metrics().HappyPath)
+	require.Equal(t, FallbackTaskLimit, s.Metrics().Postmortem.Reason)
+}
@@ -0,0 +1,191 @@
+package tasks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// livelockHotKeys is the number of hot keys kept in a livelock diagnostics bundle
+const livelockHotKeys = 20
+
+// LivelockAction is what the scheduler does once it detects a livelock, see WithLivelockDetection
+type LivelockAction int
+
+const (
+	// LivelockFallback executes the rest of the block sequentially, like a block exceeding the round limit
+	LivelockFallback LivelockAction = iota
+	// LivelockFail has ProcessAll return a *SchedulerError of class ErrValidationLivelock
+	LivelockFail
+)
+
+// WithLivelockDetection has the scheduler detect a livelock once stallRounds consecutive concurrent rounds failed to
+// advance the validated frontier, ie. the first tx that isn't validated, and act on it rather than spin until the
+// round limit of WithMaxIterations. Rounds that validate txs beyond the frontier while the frontier itself keeps
+// being invalidated aren't progress: the frontier tx is the one every later tx ultimately waits on. A non-positive
+// stallRounds disables detection.
+func WithLivelockDetection(stallRounds int, action LivelockAction) SchedulerOption {
+	return func(s *scheduler) {
+		s.livelockRounds = stallRounds
+		s.livelockAction = action
+	}
+}
+
+// WithLivelockDiagnostics has the scheduler write a diagnostics bundle to the given directory whenever it detects a
+// livelock, see LivelockDiagnostics
+func WithLivelockDiagnostics(dir string) SchedulerOption {
+	return func(s *scheduler) { s.livelockDir = dir }
+}
+
+// TaskDiagnostic is the state of a tx as of a detected livelock
+type TaskDiagnostic struct {
+	Index       int    `json:"index"`
+	Status      string `json:"status"`
+	Incarnation int    `json:"incarnation"`
+	// Dependencies are the txs the tx conflicted with, sorted
+	Dependencies []int `json:"dependencies,omitempty"`
+	// Abort is the abort of the latest execution of the tx, if it aborted
+	Abort string `json:"abort,omitempty"`
+}
+
+// LivelockDiagnostics is the bundle captured when the scheduler detects a livelock, for operators to find out what
+// the block was stuck on
+type LivelockDiagnostics struct {
+	Height int64 `json:"height"`
+	// Round is the round the livelock was detected in, and Frontier the first tx that wasn't validated, which it
+	// stalled at for StalledRounds rounds
+	Round         int `json:"round"`
+	Frontier      int `json:"frontier"`
+	StalledRounds int `json:"stalled_rounds"`
+	// Tasks are the states of every tx of the block
+	Tasks []TaskDiagnostic `json:"tasks"`
+	// Conflicts is the conflict graph of the block so far
+	Conflicts []ConflictPair `json:"conflicts"`
+	// HotKeys are the keys with the most conflicts, if hot keys are reported, see WithHotKeyReport
+	HotKeys []HotKey `json:"hot_keys,omitempty"`
+	// Goroutines is a dump of the stacks of every goroutine
+	Goroutines string `json:"goroutines"`
+}
+
+// livelockDiagnosticsPath returns the path of the diagnostics bundle of a block height
+func livelockDiagnosticsPath(dir string, height int64) string {
+	return filepath.Join(dir, fmt.Sprintf("livelock-%d.json", height))
+}
+
+// checkFrontier tracks the validated frontier over the concurrent rounds of a block, returning an
+// ErrValidationLivelock error once it stalled for too many rounds. With LivelockFallback, the error is only returned
+// from the fallback, and the block goes on sequentially.
+func (s *scheduler) checkFrontier(ctx sdk.Context, round int) error {
+	if s.livelockRounds <= 0 || s.synchronous {
+		return nil
+	}
+	frontier, anyLeft := s.findFirstNonValidated()
+	if !anyLeft || frontier > s.frontier {
+		s.frontier = frontier
+		s.frontierStalls = 0
+		return nil
+	}
+	s.frontierStalls++
+	if s.frontierStalls < s.livelockRounds {
+		return nil
+	}
+	err := &SchedulerError{
+		Class:   ErrValidationLivelock,
+		TxIndex: frontier,
+		Cause:   fmt.Errorf("validated frontier stalled for %d rounds", s.frontierStalls),
+	}
+	s.writeLivelockDiagnostics(ctx, round, frontier)
+	if s.livelockAction == LivelockFail {
+		return err
+	}
+	s.recordFallback(ctx, FallbackLivelock, err)
+	s.synchronous = true
+	return nil
+}
+
+// livelockDiagnostics captures the diagnostics bundle of a livelock detected at the frontier
+func (s *scheduler) livelockDiagnostics(ctx sdk.Context, round int, frontier int) LivelockDiagnostics {
+	snapshot := s.metrics.snapshot()
+	diagnostics := LivelockDiagnostics{
+		Height:        ctx.BlockHeight(),
+		Round:         round,
+		Frontier:      frontier,
+		StalledRounds: s.frontierStalls,
+		Tasks:         make([]TaskDiagnostic, 0, len(s.allTasks)),
+		Conflicts:     snapshot.Conflicts,
+	}
+	for _, t := range s.allTasks {
+		diagnostics.Tasks = append(diagnostics.Tasks, taskDiagnostic(t))
+	}
+	if s.metrics.hotKeys != nil {
+		diagnostics.HotKeys = s.metrics.hotKeys.top(livelockHotKeys)
+	}
+	var goroutines bytes.Buffer
+	if profile := pprof.Lookup("goroutine"); profile != nil {
+		_ = profile.WriteTo(&goroutines, 2)
+	}
+	diagnostics.Goroutines = goroutines.String()
+	return diagnostics
+}
+
+func taskDiagnostic(t *deliverTxTask) TaskDiagnostic {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	diagnostic := TaskDiagnostic{
+		Index:        t.Index,
+		Status:       t.LoadStatus().String(),
+		Incarnation:  t.Incarnation,
+		Dependencies: make([]int, 0, len(t.Dependencies)),
+	}
+	for dep := range t.Dependencies {
+		diagnostic.Dependencies = append(diagnostic.Dependencies, dep)
+	}
+	sort.Ints(diagnostic.Dependencies)
+	if t.Abort != nil {
+		diagnostic.Abort = fmt.Sprintf("%+v", *t.Abort)
+	}
+	return diagnostic
+}
+
+// writeLivelockDiagnostics writes the diagnostics bundle of a livelock to the diagnostics directory, if any
+func (s *scheduler) writeLivelockDiagnostics(ctx sdk.Context, round int, frontier int) {
+	ctx.Logger().Error("occ scheduler detected a livelock", "height", ctx.BlockHeight(), "round", round,
+		"frontier", frontier, "stalled_rounds", s.frontierStalls)
+	if s.livelockDir == "" {
+		return
+	}
+	path := livelockDiagnosticsPath(s.livelockDir, ctx.BlockHeight())
+	if err := writeLivelockDiagnostics(path, s.livelockDiagnostics(ctx, round, frontier)); err != nil {
+		ctx.Logger().Error("failed to write occ livelock diagnostics", "path", path, "err", err)
+		return
+	}
+	ctx.Logger().Info("wrote occ livelock diagnostics", "path", path)
+}
+
+func writeLivelockDiagnostics(path string, diagnostics LivelockDiagnostics) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	bz, err := json.MarshalIndent(diagnostics, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, bz, 0o644)
+}
+
+// LoadLivelockDiagnostics loads a diagnostics bundle written on a livelock
+func LoadLivelockDiagnostics(path string) (LivelockDiagnostics, error) {
+	var diagnostics LivelockDiagnostics
+	bz, err := os.ReadFile(path)
+	if err != nil {
+		return diagnostics, err
+	}
+	err = json.Unmarshal(bz, &diagnostics)
+	return diagnostics, err
+}
@@ -0,0 +1,75 @@
+package tasks
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// newLivelockScheduler returns a scheduler whose tx 1 aborts on tx 0 for its first aborting executions, which stalls
+// the validated frontier at tx 1 for as many rounds
+func newLivelockScheduler(aborting int64, opts ...SchedulerOption) *scheduler {
+	ti := newTestTracingInfo()
+
+	var s *scheduler
+	var executions int64
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if ctx.TxIndex() == 1 && atomic.AddInt64(&executions, 1) <= aborting {
+			abort := occ.NewEstimateAbort(0, testStoreKey.Name(), itemKey)
+			s.allTasks[1].AbortCh <- abort
+			panic(abort)
+		}
+		kv.Set(req.Tx, req.Tx)
+		return types.ResponseDeliverTx{}
+	}
+	s = NewScheduler(4, ti, deliverTx, opts...).(*scheduler)
+	return s
+}
+
+func TestProcessAllLivelockFallback(t *testing.T) {
+	dir := t.TempDir()
+	s := newLivelockScheduler(5, WithLivelockDetection(3, LivelockFallback), WithLivelockDiagnostics(dir), WithMaxIterations(100))
+	ctx := initTestCtx(true).WithBlockHeight(7)
+	res, err := s.ProcessAll(ctx, requestList(10))
+	require.NoError(t, err)
+	require.Len(t, res, 10)
+
+	// the block went on sequentially once the frontier stalled
+	metrics := s.Metrics()
+	require.True(t, metrics.Synchronous)
+	require.NotNil(t, metrics.Postmortem)
+	require.Equal(t, FallbackLivelock, metrics.Postmortem.Reason)
+	require.Contains(t, metrics.Postmortem.Cause, "validated frontier stalled for 3 rounds")
+
+	diagnostics, err := LoadLivelockDiagnostics(livelockDiagnosticsPath(dir, 7))
+	require.NoError(t, err)
+	require.Equal(t, int64(7), diagnostics.Height)
+	require.Equal(t, 1, diagnostics.Frontier)
+	require.Equal(t, 3, diagnostics.StalledRounds)
+	require.Len(t, diagnostics.Tasks, 10)
+	require.Equal(t, "validated", diagnostics.Tasks[0].Status)
+	require.Equal(t, []int{0}, diagnostics.Tasks[1].Dependencies)
+	require.Contains(t, diagnostics.Goroutines, "goroutine")
+}
+
+func TestProcessAllLivelockFail(t *testing.T) {
+	s := newLivelockScheduler(1000, WithLivelockDetection(3, LivelockFail), WithMaxIterations(100))
+	_, err := s.ProcessAll(initTestCtx(true), requestList(10))
+	require.ErrorIs(t, err, ErrValidationLivelock)
+	var schedErr *SchedulerError
+	require.ErrorAs(t, err, &schedErr)
+	require.Equal(t, 1, schedErr.TxIndex)
+
+	// without detection, the block spins until the round limit and then livelocks sequentially
+	s = newLivelockScheduler(1000, WithMaxIterations(5))
+	_, err = s.ProcessAll(initTestCtx(true), requestList(10))
+	require.ErrorIs(t, err, ErrValidationLivelock)
+	require.Equal(t, FallbackRoundLimit, s.Metrics().Postmortem.Reason)
+}
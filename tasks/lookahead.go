@@ -0,0 +1,163 @@
+package tasks
+
+import (
+	"sort"
+	"sync"
+)
+
+// WithLookahead bounds speculative execution to a sliding window of txs: a round only executes txs fewer than window
+// txs past the first tx that isn't validated yet, and defers the others to the round in which the window reaches them.
+// On long blocks with many conflicts, txs far ahead of the validated prefix are likely to read state that's still
+// going to change, so executing them early mostly wastes work, and memory for their readsets and writesets. Since
+// validation happens between rounds, a block takes at least as many rounds as it has windows, so rounds in which the
+// window advanced don't count towards the round limit of WithMaxIterations. A non-positive window disables the bound.
+// Blocks executing sequentially aren't bounded, since none of their executions is speculative.
+func WithLookahead(window int) SchedulerOption {
+	return func(s *scheduler) { s.lookahead = window }
+}
+
+// WithLookaheadTuner has the scheduler take the window of every block from the tuner instead, and report the block's
+// metrics back to it once processed, see WithLookahead. Like the WorkerTuner, the tuner is meant to outlive the
+// scheduler.
+func WithLookaheadTuner(tuner *LookaheadTuner) SchedulerOption {
+	return func(s *scheduler) { s.lookaheadTuner = tuner }
+}
+
+// blockLookahead returns the lookahead window of a block, or 0 if it's unbounded
+func (s *scheduler) blockLookahead() int {
+	window := s.lookahead
+	if s.lookaheadTuner != nil {
+		window = s.lookaheadTuner.Window()
+	}
+	if window < 0 {
+		return 0
+	}
+	return window
+}
+
+// limitLookahead returns the tasks of toExecute and of the previously deferred tasks that are within the lookahead
+// window, in index order, deferring the others. It also exempts the round from the round limit if the window advanced.
+func (s *scheduler) limitLookahead(toExecute []*deliverTxTask) []*deliverTxTask {
+	if s.lookaheadWindow == 0 {
+		return toExecute
+	}
+	if s.synchronous {
+		// sequential execution covers every task that isn't validated, deferred or not
+		s.deferred = nil
+		return toExecute
+	}
+	candidates := make([]*deliverTxTask, 0, len(toExecute)+len(s.deferred))
+	seen := make(map[int]struct{}, len(toExecute)+len(s.deferred))
+	for _, t := range toExecute {
+		if _, ok := seen[t.Index]; !ok {
+			seen[t.Index] = struct{}{}
+			candidates = append(candidates, t)
+		}
+	}
+	for idx := range s.deferred {
+		if _, ok := seen[idx]; !ok {
+			seen[idx] = struct{}{}
+			candidates = append(candidates, s.allTasks[idx])
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Index < candidates[j].Index })
+
+	base, _ := s.findFirstNonValidated()
+	limit := base + s.lookaheadWindow
+	ready := candidates[:0]
+	s.deferred = make(map[int]struct{})
+	for _, t := range candidates {
+		if t.Index < limit {
+			ready = append(ready, t)
+		} else {
+			s.deferred[t.Index] = struct{}{}
+		}
+	}
+	s.metrics.lookaheadDeferrals += len(s.deferred)
+	if len(s.deferred) > 0 && base > s.lookaheadBase {
+		s.lookaheadRounds++
+	}
+	s.lookaheadBase = base
+	return ready
+}
+
+// isDeferred returns true if the task is beyond the lookahead window, so that it hasn't executed yet
+func (s *scheduler) isDeferred(task *deliverTxTask) bool {
+	_, ok := s.deferred[task.Index]
+	return ok
+}
+
+const (
+	// lookaheadHighAbortRate is the rate of wasted executions per tx at or above which the tuner halves the window
+	lookaheadHighAbortRate = 0.5
+	// lookaheadLowAbortRate is the rate of wasted executions per tx at or below which the tuner doubles the window
+	lookaheadLowAbortRate = 0.1
+)
+
+// LookaheadTuner adapts the lookahead window to the blocks being processed, see WithLookahead. Executions aborted
+// or re-executed are wasted work, so the tuner halves the window after a block with a high rate of them (or one that
+// fell back to sequential execution), and doubles it again while blocks waste little.
+type LookaheadTuner struct {
+	mx        sync.Mutex
+	minWindow int
+	maxWindow int
+	window    int
+}
+
+// NewLookaheadTuner returns a tuner bounded by the given windows, starting at the maximum
+func NewLookaheadTuner(minWindow int, maxWindow int) *LookaheadTuner {
+	t := &LookaheadTuner{}
+	t.SetLimits(minWindow, maxWindow)
+	t.window = t.maxWindow
+	return t
+}
+
+// SetLimits changes the bounds of the tuner, eg. when the app config is reloaded. The current window is clamped to the
+// new bounds.
+func (t *LookaheadTuner) SetLimits(minWindow int, maxWindow int) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if minWindow < 1 {
+		minWindow = 1
+	}
+	if maxWindow < minWindow {
+		maxWindow = minWindow
+	}
+	t.minWindow = minWindow
+	t.maxWindow = maxWindow
+	t.window = t.clamp(t.window)
+}
+
+// Window returns the lookahead window to use for the next block
+func (t *LookaheadTuner) Window() int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.window
+}
+
+func (t *LookaheadTuner) clamp(window int) int {
+	if window < t.minWindow {
+		return t.minWindow
+	}
+	if window > t.maxWindow {
+		return t.maxWindow
+	}
+	return window
+}
+
+// observe adjusts the window given the metrics of a processed block
+func (t *LookaheadTuner) observe(m SchedulerMetrics) {
+	if m.Txs < tunerMinTxs {
+		return
+	}
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	abortRate := float64(m.Aborts+m.Retries) / float64(m.Txs)
+	switch {
+	case m.Synchronous || abortRate >= lookaheadHighAbortRate:
+		t.window = t.clamp(t.window / 2)
+	case abortRate <= lookaheadLowAbortRate:
+		t.window = t.clamp(t.window * 2)
+	}
+}
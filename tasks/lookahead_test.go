@@ -0,0 +1,109 @@
+package tasks
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllLookahead(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// independent txs, which only execute once the window reaches them
+	var mx sync.Mutex
+	executed := make(map[int]int)
+	var maxAhead int
+	var s Scheduler
+	independent := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		base, _ := s.(*scheduler).findFirstNonValidated()
+		mx.Lock()
+		executed[ctx.TxIndex()]++
+		if ahead := ctx.TxIndex() - base; ahead > maxAhead {
+			maxAhead = ahead
+		}
+		mx.Unlock()
+		ctx.MultiStore().GetKVStore(testStoreKey).Set(req.Tx, req.Tx)
+		return types.ResponseDeliverTx{Info: string(req.Tx)}
+	}
+	const txs = 40
+	s = NewScheduler(10, ti, independent, WithLookahead(5))
+	res, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	for i, r := range res {
+		require.Equal(t, strconv.Itoa(i), r.Info)
+		require.Equal(t, 1, executed[i], "tx %d", i)
+	}
+	require.Less(t, maxAhead, 5)
+	metrics := s.Metrics()
+	require.Equal(t, 5, metrics.LookaheadWindow)
+	require.NotZero(t, metrics.LookaheadDeferrals)
+	require.Zero(t, metrics.Retries)
+	// the block takes more rounds than the round limit, without falling back since the window kept advancing
+	require.GreaterOrEqual(t, metrics.Iterations, txs/5)
+	require.False(t, metrics.Synchronous)
+
+	// conflicting txs converge to their sequential execution
+	conflicting := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		count, _ := strconv.Atoi(string(kv.Get(itemKey)))
+		time.Sleep(100 * time.Microsecond)
+		kv.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		return types.ResponseDeliverTx{Info: strconv.Itoa(count)}
+	}
+	for _, window := range []int{1, 4, 16} {
+		res, err := VerifySequential(initTestCtx(true), requestList(txs), 10, ti, conflicting, WithLookahead(window))
+		require.NoError(t, err, "window %d", window)
+		for i, r := range res {
+			require.Equal(t, strconv.Itoa(i), r.Info)
+		}
+	}
+
+	// a tuner sets the window of every block
+	tuner := NewLookaheadTuner(2, 8)
+	s = NewScheduler(10, ti, independent, WithLookahead(5), WithLookaheadTuner(tuner))
+	executed = make(map[int]int)
+	_, err = s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	require.Equal(t, 8, s.Metrics().LookaheadWindow)
+}
+
+func TestLookaheadTuner(t *testing.T) {
+	tuner := NewLookaheadTuner(4, 64)
+	require.Equal(t, 64, tuner.Window())
+
+	// blocks wasting executions halve the window
+	tuner.observe(SchedulerMetrics{Txs: 10, Aborts: 3, Retries: 2})
+	require.Equal(t, 32, tuner.Window())
+	// as does falling back to sequential execution
+	tuner.observe(SchedulerMetrics{Txs: 10, Synchronous: true})
+	require.Equal(t, 16, tuner.Window())
+	// but never below the minimum
+	tuner.observe(SchedulerMetrics{Txs: 10, Retries: 10})
+	tuner.observe(SchedulerMetrics{Txs: 10, Retries: 10})
+	tuner.observe(SchedulerMetrics{Txs: 10, Retries: 10})
+	require.Equal(t, 4, tuner.Window())
+
+	// blocks wasting little double it again
+	tuner.observe(SchedulerMetrics{Txs: 10, Aborts: 1})
+	require.Equal(t, 8, tuner.Window())
+	// blocks in between leave it alone
+	tuner.observe(SchedulerMetrics{Txs: 10, Retries: 3})
+	require.Equal(t, 8, tuner.Window())
+	// and so do blocks too small to learn from
+	tuner.observe(SchedulerMetrics{Txs: 1, Retries: 1})
+	require.Equal(t, 8, tuner.Window())
+
+	// new limits clamp the current window
+	tuner.SetLimits(1, 6)
+	require.Equal(t, 6, tuner.Window())
+	tuner.SetLimits(0, 0)
+	require.Equal(t, 1, tuner.Window())
+}
@@ -0,0 +1,105 @@
+package tasks
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ErrMemoryBudgetExceeded is the cause of the fallback of a block whose multiversion stores exceeded the memory budget,
+// see WithBlockMemoryBudget
+var ErrMemoryBudgetExceeded = errors.New("occ state of the block exceeded its memory budget")
+
+// ErrTaskMemoryLimitExceeded is the cause of the fallback of a block with a tx whose version stores exceeded the memory
+// limit, see WithTaskMemoryLimit
+var ErrTaskMemoryLimitExceeded = errors.New("occ task exceeded its memory limit")
+
+// WithTaskMemoryLimit bounds the approximate bytes the readsets and writesets of a tx's version stores may hold, as
+// the lengths of the keys and values it read and wrote, protecting the node against txs reading or writing huge
+// amounts of state under OCC. An execution exceeding the limit is stopped: it's aborted, and the block falls back to
+// sequential execution, which executes the tx once, without the limit. Like WithTrackingLimits the tx doesn't fail, so
+// the limit doesn't bear on the responses of the block and may differ between nodes. Non-positive limits disable it.
+func WithTaskMemoryLimit(maxBytes int) SchedulerOption {
+	return func(s *scheduler) { s.taskMemoryLimit = maxBytes }
+}
+
+// newMemoryMeter returns the memory meter of a new execution, or nil if the execution isn't limited. Sequential
+// execution runs every tx once and to completion, the same way as baseapp does without the scheduler, so it isn't
+// limited.
+func (s *scheduler) newMemoryMeter() *multiversion.MemoryMeter {
+	if s.taskMemoryLimit <= 0 || s.synchronous {
+		return nil
+	}
+	return multiversion.NewMemoryMeter(s.taskMemoryLimit)
+}
+
+// taskMemoryExceededBy returns whether the version stores of the execution of a task exceeded the memory limit, and
+// it must be executed again sequentially. The excess panics in the version store, but the tx may have recovered the
+// panic (runTx does), so the response can't be relied on.
+func (s *scheduler) taskMemoryExceededBy(task *deliverTxTask) bool {
+	return task.MemoryMeter != nil && task.MemoryMeter.Exceeded() != nil
+}
+
+// onTaskMemoryExceeded leaves a task that exceeded the memory limit aborted with its writes marked as estimates, to be
+// re-executed once the block falls back to sequential execution
+func (s *scheduler) onTaskMemoryExceeded(task *deliverTxTask) {
+	task.SetStatus(statusAborted)
+	s.writeAbortEstimates(task)
+	atomic.StoreInt32(&s.taskMemoryExceeded, 1)
+}
+
+// taskMemoryLimitHit returns whether an execution of the block exceeded the memory limit
+func (s *scheduler) taskMemoryLimitHit() bool {
+	return atomic.LoadInt32(&s.taskMemoryExceeded) != 0
+}
+
+// handleTaskMemoryLimit falls back to sequential execution if an execution of the block exceeded the memory limit
+func (s *scheduler) handleTaskMemoryLimit(ctx sdk.Context) {
+	if !s.taskMemoryLimitHit() {
+		return
+	}
+	s.recordFallback(ctx, FallbackTaskMemoryLimit, ErrTaskMemoryLimitExceeded)
+	s.synchronous = true
+}
+
+// WithBlockMemoryBudget bounds the approximate bytes held by the writesets and readsets of the multiversion stores of a
+// block (see multiversion.MemoryUsage), protecting the node against huge blocks. Once the budget is exceeded, the block
+// falls back to sequential execution, which executes one tx at a time rather than piling up speculative executions
+// that are bound to be re-executed. No tx fails, so the budget doesn't bear on the responses of the block and may
+// differ between nodes.
+// Non-positive budgets only track the high-water mark of the block, see SchedulerMetrics.MemoryHighWaterMark.
+func WithBlockMemoryBudget(maxBytes int64) SchedulerOption {
+	return func(s *scheduler) { s.blockMemoryBudget = maxBytes }
+}
+
+// sampleMemory totals the bytes held by the multiversion stores of the block, raising the high-water mark of the block
+// and flagging the block if it exceeds the memory budget. It's called as the stores grow, by executions flushing their
+// writesets and readsets.
+func (s *scheduler) sampleMemory() {
+	var usage multiversion.MemoryUsage
+	for _, mv := range s.orderedStores {
+		usage = usage.Add(mv.store.MemoryUsage())
+	}
+	total := usage.Total()
+	for {
+		mark := atomic.LoadInt64(&s.metrics.memoryHighWaterMark)
+		if total <= mark || atomic.CompareAndSwapInt64(&s.metrics.memoryHighWaterMark, mark, total) {
+			break
+		}
+	}
+	if s.blockMemoryBudget > 0 && total > s.blockMemoryBudget {
+		atomic.StoreInt32(&s.memoryBudgetExceeded, 1)
+	}
+}
+
+// handleMemoryBudget falls back to sequential execution if the multiversion stores of the block exceeded the memory
+// budget
+func (s *scheduler) handleMemoryBudget(ctx sdk.Context) {
+	if atomic.LoadInt32(&s.memoryBudgetExceeded) == 0 || s.synchronous {
+		return
+	}
+	s.recordFallback(ctx, FallbackMemoryBudget, ErrMemoryBudgetExceeded)
+	s.synchronous = true
+}
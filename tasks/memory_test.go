@@ -0,0 +1,121 @@
+package tasks
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllTaskMemoryLimit(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	const txs = 20
+	const bombTx = 7
+	bombKey := []byte("bomb")
+	// every tx appends its index to the shared key, and the bomb tx then writes a huge value, recovering the panic of
+	// the excess like runTx would
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		if ctx.TxIndex() == bombTx {
+			func() {
+				defer func() { _ = recover() }()
+				kv.Set(bombKey, bytes.Repeat([]byte{1}, 1024))
+			}()
+		}
+		return types.ResponseDeliverTx{Info: newVal, GasUsed: 10}
+	}
+
+	for _, workers := range []int{1, 10} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			s := NewScheduler(workers, ti, deliverTx, WithTaskMemoryLimit(512))
+			ctx := initTestCtx(true)
+			res, err := s.ProcessAll(ctx, requestList(txs))
+			require.NoError(t, err)
+
+			// the bomb tx doesn't fail, the block falls back to sequential execution, which keeps all of its writes
+			kv := ctx.MultiStore().GetKVStore(testStoreKey)
+			expected := ""
+			for idx, response := range res {
+				expected += fmt.Sprintf("%d,", idx)
+				require.Equal(t, uint32(0), response.Code)
+				require.Equal(t, expected, response.Info)
+			}
+			require.Equal(t, expected, string(kv.Get(itemKey)))
+			require.Equal(t, bytes.Repeat([]byte{1}, 1024), kv.Get(bombKey))
+
+			metrics := s.Metrics()
+			require.True(t, metrics.Synchronous)
+			require.NotNil(t, metrics.Postmortem)
+			require.Equal(t, FallbackTaskMemoryLimit, metrics.Postmortem.Reason)
+			require.Equal(t, ErrTaskMemoryLimitExceeded.Error(), metrics.Postmortem.Cause)
+
+			_, err = VerifySequential(initTestCtx(true), requestList(txs), workers, ti, deliverTx, WithTaskMemoryLimit(512))
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestProcessAllBlockMemoryBudget(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx writes a large value of its own and appends its index to the same key, with executions overlapping
+	// between the read and the write so that they conflict
+	value := bytes.Repeat([]byte("v"), 1024)
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		kv.Set(req.Tx, value)
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		time.Sleep(100 * time.Microsecond)
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	// without a budget, the high-water mark is only tracked
+	const txs = 20
+	s := NewScheduler(4, ti, deliverTx)
+	_, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	require.Greater(t, s.Metrics().MemoryHighWaterMark, int64(txs*len(value)))
+	require.NotEqual(t, FallbackMemoryBudget, fallbackReason(s.Metrics()))
+
+	// beyond the budget, the block falls back to sequential execution, with the same responses
+	s = NewScheduler(4, ti, deliverTx, WithBlockMemoryBudget(2*int64(len(value))))
+	res, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	expected := ""
+	for i, r := range res {
+		expected += fmt.Sprintf("%d,", i)
+		require.Equal(t, expected, r.Info)
+	}
+	metrics := s.Metrics()
+	require.True(t, metrics.Synchronous)
+	require.NotNil(t, metrics.Postmortem)
+	require.Equal(t, FallbackMemoryBudget, metrics.Postmortem.Reason)
+	require.Equal(t, "memory_budget", metrics.Postmortem.Reason.String())
+	require.Equal(t, ErrMemoryBudgetExceeded.Error(), metrics.Postmortem.Cause)
+	require.Equal(t, 2*int64(len(value)), metrics.Postmortem.Config.BlockMemoryBudget)
+
+	// within the budget the block doesn't fall back for it
+	s = NewScheduler(4, ti, deliverTx, WithBlockMemoryBudget(1<<20))
+	_, err = s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	require.NotEqual(t, FallbackMemoryBudget, fallbackReason(s.Metrics()))
+}
+
+// fallbackReason returns the reason the block fell back to sequential execution for, or -1 if it didn't
+func fallbackReason(metrics SchedulerMetrics) FallbackReason {
+	if metrics.Postmortem == nil {
+		return -1
+	}
+	return metrics.Postmortem.Reason
+}
@@ -0,0 +1,375 @@
+package tasks
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// SchedulerMetrics contains OCC statistics for a single block processed by the scheduler
+type SchedulerMetrics struct {
+	// Txs is the number of txs in the block
+	Txs int
+	// Iterations is the number of execute/validate rounds
+	Iterations int
+	// Synchronous is true if the scheduler fell back to sequential execution
+	Synchronous bool
+	// Workers is the number of workers that executed txs
+	Workers int
+	// HappyPath is true if the block ran without readset tracking or validation, since its writesets were
+	// guaranteed to be disjoint
+	HappyPath bool
+	// SmallBlock is true if the block ran on the calling goroutine with inline validation, since it was small
+	SmallBlock bool
+	// PlannedWaves is the number of waves the first round was dispatched in, or zero if it wasn't planned
+	PlannedWaves int
+	// Incarnations is the final incarnation of each tx, by tx index
+	Incarnations []int
+	// MaxIncarnation is the highest incarnation of any tx
+	MaxIncarnation int
+	// Retries is the number of tx attempts beyond the first attempt
+	Retries int
+	// Aborts is the number of executions aborted for reading an estimate
+	Aborts int
+	// AbortReasons is the number of aborted executions by abort reason name
+	AbortReasons map[string]int
+	// SkippedValidations is the number of validations of already validated txs skipped because none of their reads
+	// were affected by writeset changes
+	SkippedValidations int
+	// SkippedWaits is the number of validations of waiting txs skipped because none of their dependencies were
+	// validated since the previous validation round
+	SkippedWaits int
+	// SpotChecks is the number of txs re-validated at the end of the block, and SpotCheckFailures the number of them
+	// that failed, see WithValidationSpotChecks
+	SpotChecks        int
+	SpotCheckFailures int
+	// Conflicts are the distinct pairs of txs that conflicted, sorted by tx index
+	Conflicts []ConflictPair
+	// WastedGas is the gas used by executions whose results were discarded
+	WastedGas int64
+	// ExecuteDuration is the wall-clock time spent in execution phases
+	ExecuteDuration time.Duration
+	// ValidateDuration is the wall-clock time spent in validation phases
+	ValidateDuration time.Duration
+	// InvalidationLatency is the time validation rounds took to find their last invalid task, summed over rounds, see
+	// WithDirtyFirstValidation
+	InvalidationLatency time.Duration
+	// ValidationCosts is the cumulative validation cost of each store, by store key name
+	ValidationCosts map[string]multiversion.ValidationCost
+	// PrunedVersions is the number of superseded versions pruned from the multiversion stores, see WithVersionPruning
+	PrunedVersions int
+	// CommittedTxs is the number of leading txs whose writes were committed to the parent stores before the end of the
+	// block, and CommittedKeys the number of keys written by those commits, see WithIncrementalCommit
+	CommittedTxs  int
+	CommittedKeys int
+	// ParentCacheHits and ParentCacheMisses are the reads of the parent stores served by the shared read cache and the
+	// reads that went to the parent stores, see WithParentReadCache, and ParentCacheEvictions the keys evicted to keep
+	// the caches within their capacity, see WithParentReadCacheCapacity
+	ParentCacheHits      int
+	ParentCacheMisses    int
+	ParentCacheEvictions int
+	// CarriedEstimates is the number of txs whose estimates were carried over from earlier blocks, see
+	// WithEstimateCarryover
+	CarriedEstimates int
+	// LearnedEstimates is the number of txs whose estimates were learned from the txs of the same identifier in earlier
+	// blocks, see WithWritesetCache
+	LearnedEstimates int
+	// SequentialOnlyTxs is the number of txs pinned to sequential execution, see WithSequentialOnly
+	SequentialOnlyTxs int
+	// DuplicateTxs is the number of txs ordered behind an earlier tx of the block they repeat, see WithDuplicateTxOrdering
+	DuplicateTxs int
+	// LookaheadWindow is the lookahead window of the block, or 0 if unbounded, and LookaheadDeferrals is the number of
+	// times an execution was deferred for being beyond it, see WithLookahead
+	LookaheadWindow    int
+	LookaheadDeferrals int
+	// MemoryHighWaterMark is the most bytes the writesets and readsets of the multiversion stores held at once, see
+	// WithBlockMemoryBudget
+	MemoryHighWaterMark int64
+	// TimedOutTasks is the number of executions abandoned for taking too long, see WithTaskTimeout
+	TimedOutTasks int
+	// TrackingOverflows is the number of executions that exceeded the caps on their readsets or writesets, see
+	// WithTrackingLimits
+	TrackingOverflows int
+	// AnteRejections is the number of txs that failed their pre-checks in the serial ante phase, see WithSerialAnte
+	AnteRejections int
+	// HotKeys are the keys responsible for the most invalidations, most first, see WithHotKeyReport
+	HotKeys []HotKey
+	// Postmortem is the diagnostic of the block falling back to sequential execution, or nil if it didn't
+	Postmortem *FallbackPostmortem
+	// Duration is the time taken to process the block
+	Duration time.Duration
+	// MaxConcurrency is the highest number of concurrently executing txs
+	MaxConcurrency int
+	// AvgConcurrency is the average number of concurrently executing txs
+	AvgConcurrency float64
+}
+
+// ConflictPair is a tx that had to be re-executed because of a lower-index tx it depends on
+type ConflictPair struct {
+	Index      int
+	Dependency int
+}
+
+// schedulerMetrics contains metrics for the scheduler
+type schedulerMetrics struct {
+	// txs is the number of txs in the block
+	txs int
+	// iterations is the number of execute/validate rounds
+	iterations int
+	// synchronous is true if the scheduler fell back to sequential execution
+	synchronous bool
+	// workers is the number of workers that executed txs
+	workers int
+	// happyPath is true if the block ran without readset tracking or validation
+	happyPath bool
+	// smallBlock is true if the block ran on the calling goroutine with inline validation
+	smallBlock bool
+	// plannedWaves is the number of waves the first round was dispatched in
+	plannedWaves int
+	// incarnations is the final incarnation of each tx
+	incarnations []int
+	// maxIncarnation is the highest incarnation seen in this set
+	maxIncarnation int
+	// retries is the number of tx attempts beyond the first attempt
+	retries int
+	// aborts is the number of executions aborted for reading an estimate
+	aborts int64
+	// abortReasons is the number of aborted executions by reason
+	abortReasonsMx sync.Mutex
+	abortReasons   map[occ.AbortReason]int
+	// gasUsed is the gas used by all executions, including discarded ones
+	gasUsed int64
+	// finalGasUsed is the gas used by the final execution of every tx
+	finalGasUsed int64
+	// skippedValidations is the number of revalidations skipped by incremental validation
+	skippedValidations int
+	// skippedWaits is the number of waiting txs left alone by validation rounds until woken
+	skippedWaits int
+	// spotChecks and spotCheckFailures are the number of txs spot checked at the end of the block, and that failed
+	spotChecks        int
+	spotCheckFailures int
+	// conflicts is the set of distinct conflicting pairs
+	conflictsMx sync.Mutex
+	conflicts   map[ConflictPair]struct{}
+	// executeDuration and validateDuration are the time spent in each phase
+	executeDuration  time.Duration
+	validateDuration time.Duration
+	// invalidationLatency is the time validation rounds took to find their last invalid task
+	invalidationLatency time.Duration
+	// validationCosts is the validation cost of each store
+	validationCosts map[string]multiversion.ValidationCost
+	// prunedVersions is the number of superseded versions pruned from the multiversion stores
+	prunedVersions int
+	// committedTxs and committedKeys are the leading txs and the keys committed to the parent stores mid-block
+	committedTxs  int
+	committedKeys int
+	// parentCacheHits and parentCacheMisses are the reads of the parent stores served by and missing the read cache,
+	// and parentCacheEvictions the keys evicted from it
+	parentCacheHits      int
+	parentCacheMisses    int
+	parentCacheEvictions int
+	// carriedEstimates is the number of txs prefilled with writesets carried over from earlier blocks
+	carriedEstimates int
+	// learnedEstimates is the number of txs prefilled with writesets learned for their identifier
+	learnedEstimates int
+	// sequentialOnlyTxs is the number of txs pinned to sequential execution
+	sequentialOnlyTxs int
+	// duplicateTxs is the number of txs ordered behind an earlier tx of the block they repeat
+	duplicateTxs int
+	// lookaheadWindow is the lookahead window of the block, and lookaheadDeferrals the executions deferred beyond it
+	lookaheadWindow    int
+	lookaheadDeferrals int
+	// memoryHighWaterMark is the most bytes the multiversion stores held at once, only accessed atomically
+	memoryHighWaterMark int64
+	// timedOutTasks is the number of executions that timed out, only accessed atomically
+	timedOutTasks int64
+	// trackingOverflows is the number of executions that exceeded the tracking limits, only accessed atomically
+	trackingOverflows int64
+	// anteRejections is the number of txs that failed their pre-checks in the serial ante phase
+	anteRejections int
+	// hotKeys aggregates the invalidations by key if they're reported, and topHotKeys are the reported keys
+	hotKeys    *hotKeys
+	topHotKeys []HotKey
+	// postmortem is the diagnostic of the block falling back to sequential execution, if it did
+	postmortem *FallbackPostmortem
+	// duration is the time taken to process the block
+	duration time.Duration
+	// concurrency tracks the number of concurrently executing tasks
+	concurrency concurrencyTracker
+}
+
+// recordExecution records the gas used by an execution, and whether it was aborted
+func (m *schedulerMetrics) recordExecution(gasUsed int64, aborted bool) {
+	atomic.AddInt64(&m.gasUsed, gasUsed)
+	if aborted {
+		atomic.AddInt64(&m.aborts, 1)
+	}
+}
+
+// recordAbortReason records the reason of an aborted execution
+func (m *schedulerMetrics) recordAbortReason(reason occ.AbortReason) {
+	m.abortReasonsMx.Lock()
+	defer m.abortReasonsMx.Unlock()
+	if m.abortReasons == nil {
+		m.abortReasons = make(map[occ.AbortReason]int)
+	}
+	m.abortReasons[reason]++
+}
+
+// recordConflicts records that the tx at index conflicted with each of the given dependencies
+func (m *schedulerMetrics) recordConflicts(index int, dependencies []int) {
+	m.conflictsMx.Lock()
+	defer m.conflictsMx.Unlock()
+	if m.conflicts == nil {
+		m.conflicts = make(map[ConflictPair]struct{})
+	}
+	for _, dep := range dependencies {
+		m.conflicts[ConflictPair{Index: index, Dependency: dep}] = struct{}{}
+	}
+}
+
+// snapshot returns the exported view of the metrics
+func (m *schedulerMetrics) snapshot() SchedulerMetrics {
+	m.conflictsMx.Lock()
+	conflicts := make([]ConflictPair, 0, len(m.conflicts))
+	for pair := range m.conflicts {
+		conflicts = append(conflicts, pair)
+	}
+	m.conflictsMx.Unlock()
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Index != conflicts[j].Index {
+			return conflicts[i].Index < conflicts[j].Index
+		}
+		return conflicts[i].Dependency < conflicts[j].Dependency
+	})
+
+	m.abortReasonsMx.Lock()
+	abortReasons := make(map[string]int, len(m.abortReasons))
+	for reason, count := range m.abortReasons {
+		abortReasons[reason.String()] = count
+	}
+	m.abortReasonsMx.Unlock()
+
+	validationCosts := make(map[string]multiversion.ValidationCost, len(m.validationCosts))
+	for name, cost := range m.validationCosts {
+		validationCosts[name] = cost
+	}
+
+	return SchedulerMetrics{
+		Txs:                  m.txs,
+		Iterations:           m.iterations,
+		Synchronous:          m.synchronous,
+		Workers:              m.workers,
+		HappyPath:            m.happyPath,
+		SmallBlock:           m.smallBlock,
+		PlannedWaves:         m.plannedWaves,
+		Incarnations:         append([]int(nil), m.incarnations...),
+		MaxIncarnation:       m.maxIncarnation,
+		Retries:              m.retries,
+		Aborts:               int(atomic.LoadInt64(&m.aborts)),
+		AbortReasons:         abortReasons,
+		SkippedValidations:   m.skippedValidations,
+		SkippedWaits:         m.skippedWaits,
+		SpotChecks:           m.spotChecks,
+		SpotCheckFailures:    m.spotCheckFailures,
+		Conflicts:            conflicts,
+		WastedGas:            atomic.LoadInt64(&m.gasUsed) - m.finalGasUsed,
+		ExecuteDuration:      m.executeDuration,
+		ValidateDuration:     m.validateDuration,
+		InvalidationLatency:  m.invalidationLatency,
+		ValidationCosts:      validationCosts,
+		PrunedVersions:       m.prunedVersions,
+		CommittedTxs:         m.committedTxs,
+		CommittedKeys:        m.committedKeys,
+		ParentCacheHits:      m.parentCacheHits,
+		ParentCacheMisses:    m.parentCacheMisses,
+		ParentCacheEvictions: m.parentCacheEvictions,
+		CarriedEstimates:     m.carriedEstimates,
+		LearnedEstimates:     m.learnedEstimates,
+		SequentialOnlyTxs:    m.sequentialOnlyTxs,
+		DuplicateTxs:         m.duplicateTxs,
+		LookaheadWindow:      m.lookaheadWindow,
+		LookaheadDeferrals:   m.lookaheadDeferrals,
+		MemoryHighWaterMark:  atomic.LoadInt64(&m.memoryHighWaterMark),
+		TimedOutTasks:        int(atomic.LoadInt64(&m.timedOutTasks)),
+		TrackingOverflows:    int(atomic.LoadInt64(&m.trackingOverflows)),
+		AnteRejections:       m.anteRejections,
+		HotKeys:              append([]HotKey(nil), m.topHotKeys...),
+		Postmortem:           m.postmortem,
+		Duration:             m.duration,
+		MaxConcurrency:       m.concurrency.maxConcurrency(),
+		AvgConcurrency:       m.concurrency.avgConcurrency(),
+	}
+}
+
+// Metrics returns the OCC statistics of the most recently processed block
+func (s *scheduler) Metrics() SchedulerMetrics {
+	return s.metrics.snapshot()
+}
+
+func (s *scheduler) emitMetrics() {
+	m := s.metrics.snapshot()
+	telemetry.IncrCounter(float32(m.Retries), "scheduler", "retries")
+	telemetry.IncrCounter(float32(m.MaxIncarnation), "scheduler", "incarnations")
+	telemetry.IncrCounter(float32(m.Aborts), "scheduler", "aborts")
+	telemetry.IncrCounter(float32(m.WastedGas), "scheduler", "wasted_gas")
+	telemetry.SetGauge(float32(len(m.Conflicts)), "scheduler", "conflicts")
+	telemetry.SetGauge(float32(m.SkippedValidations), "scheduler", "validate", "skipped")
+	telemetry.SetGauge(float32(m.SkippedWaits), "scheduler", "validate", "skipped_waits")
+	telemetry.IncrCounter(float32(m.PrunedVersions), "scheduler", "pruned_versions")
+	telemetry.IncrCounter(float32(m.CommittedKeys), "scheduler", "committed_keys")
+	telemetry.IncrCounter(float32(m.ParentCacheHits), "scheduler", "parent_cache", "hits")
+	telemetry.IncrCounter(float32(m.ParentCacheMisses), "scheduler", "parent_cache", "misses")
+	telemetry.IncrCounter(float32(m.ParentCacheEvictions), "scheduler", "parent_cache", "evictions")
+	telemetry.IncrCounter(float32(m.CarriedEstimates), "scheduler", "carried_estimates")
+	telemetry.IncrCounter(float32(m.LearnedEstimates), "scheduler", "learned_estimates")
+	telemetry.IncrCounter(float32(m.SequentialOnlyTxs), "scheduler", "sequential_only_txs")
+	telemetry.IncrCounter(float32(m.DuplicateTxs), "scheduler", "duplicate_txs")
+	telemetry.SetGauge(float32(m.LookaheadWindow), "scheduler", "lookahead", "window")
+	telemetry.IncrCounter(float32(m.LookaheadDeferrals), "scheduler", "lookahead", "deferrals")
+	telemetry.SetGauge(float32(m.MemoryHighWaterMark), "scheduler", "memory", "high_water_mark")
+	telemetry.IncrCounter(float32(m.TimedOutTasks), "scheduler", "timed_out_tasks")
+	telemetry.IncrCounter(float32(m.TrackingOverflows), "scheduler", "tracking_overflows")
+	telemetry.IncrCounter(float32(m.AnteRejections), "scheduler", "ante_rejections")
+	telemetry.IncrCounter(float32(m.SpotChecks), "scheduler", "spot_check", "checks")
+	telemetry.SetGauge(float32(m.Iterations), "scheduler", "iterations")
+	telemetry.SetGauge(float32(m.Workers), "scheduler", "workers")
+	telemetry.SetGauge(float32(m.PlannedWaves), "scheduler", "planned_waves")
+	telemetry.SetGauge(float32(m.Duration.Milliseconds()), "scheduler", "duration_ms")
+	telemetry.SetGauge(float32(m.ExecuteDuration.Milliseconds()), "scheduler", "execute", "duration_ms")
+	telemetry.SetGauge(float32(m.ValidateDuration.Milliseconds()), "scheduler", "validate", "duration_ms")
+	telemetry.SetGauge(float32(m.InvalidationLatency.Milliseconds()), "scheduler", "validate", "invalidation_latency_ms")
+	telemetry.SetGauge(float32(m.MaxConcurrency), "scheduler", "concurrency", "max")
+	telemetry.SetGauge(float32(m.AvgConcurrency), "scheduler", "concurrency", "avg")
+	emitHotKeys(m.HotKeys)
+	for name, cost := range m.ValidationCosts {
+		for phase, elapsed := range map[string]time.Duration{
+			"readset":    cost.Readset,
+			"iterateset": cost.Iterateset,
+			"parent":     cost.ParentFallthrough,
+		} {
+			telemetry.SetGaugeWithLabels(
+				[]string{"scheduler", "validate", "store", "duration_ms"},
+				float32(elapsed.Milliseconds()),
+				[]metrics.Label{telemetry.NewLabel("store", name), telemetry.NewLabel("phase", phase)},
+			)
+		}
+	}
+}
+
+// flushTxTelemetry emits the metrics buffered by the handlers of the final incarnation of every tx, in tx order, so
+// that metrics of executions that were discarded aren't counted
+func flushTxTelemetry(tasks []*deliverTxTask) {
+	for _, t := range tasks {
+		if t.Telemetry != nil {
+			t.Telemetry.Flush()
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package tasks
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllBuffersTxTelemetry(t *testing.T) {
+	sink := metrics.NewInmemSink(time.Minute, time.Minute)
+	cfg := metrics.DefaultConfig("test")
+	cfg.EnableHostname = false
+	cfg.EnableRuntimeMetrics = false
+	_, err := metrics.NewGlobal(cfg, sink)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = metrics.NewGlobal(metrics.DefaultConfig("test"), &metrics.BlackholeSink{})
+	})
+	handlerCount := func() int {
+		return sink.Data()[0].Counters["test.handler.calls"].Count
+	}
+
+	ti := newTestTracingInfo()
+	// every tx appends to the same key, so txs are re-executed, and counts its calls from its handler
+	var executions int64
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		atomic.AddInt64(&executions, 1)
+		ctx.Telemetry().IncrCounter(1, "handler", "calls")
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := kv.Get(itemKey)
+		time.Sleep(time.Millisecond)
+		kv.Set(itemKey, append(append([]byte{}, val...), req.Tx...))
+		return types.ResponseDeliverTx{}
+	}
+
+	const txs = 50
+	s := NewScheduler(10, ti, deliverTx)
+	_, err = s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	require.Greater(t, atomic.LoadInt64(&executions), int64(txs))
+	// only the final incarnation of every tx is counted
+	require.Equal(t, txs, handlerCount())
+
+	// nothing is emitted for simulated blocks
+	_, err = s.SimulateBlock(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	require.Equal(t, txs, handlerCount())
+}
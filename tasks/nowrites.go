@@ -0,0 +1,29 @@
+package tasks
+
+import (
+	"github.com/tendermint/tendermint/abci/types"
+)
+
+// wroteNothing returns whether the execution of a task left the writesets of all of its version stores empty
+func (s *scheduler) wroteNothing(task *deliverTxTask) bool {
+	for _, mv := range s.orderedStores {
+		if len(task.VersionStores[mv.key].GetWriteset()) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// finishNoWritesTask finishes the execution of a task flagged as not expected to write that indeed wrote nothing,
+// which only needs its reads to be validated: its writeset is empty, so there are no writes to flush into the
+// multiversion stores, and no readers of newly written keys to abort. Estimates left at its index (eg. prefilled from
+// its declared writeset) are removed, since no later incarnation will replace them.
+func (s *scheduler) finishNoWritesTask(task *deliverTxTask, resp types.ResponseDeliverTx) {
+	task.Response = &resp
+	for _, mv := range s.orderedStores {
+		task.VersionStores[mv.key].WriteReadsetToMultiVersionStore()
+		mv.store.RemoveEstimatesForIndex(task.Index)
+	}
+	s.sampleMemory()
+	task.SetStatus(statusExecuted)
+}
@@ -0,0 +1,119 @@
+package tasks
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllNoWritesExpected(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	const txs = 20
+	const rogueTx = 5
+	counterKey := []byte("counter")
+	readCounter := func(kv sdk.KVStore) int {
+		count, _ := strconv.Atoi(string(kv.Get(counterKey)))
+		return count
+	}
+	// even txs increment a counter, and odd txs only read it, except for the rogue tx, which also increments it
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		count := readCounter(kv)
+		if ctx.TxIndex()%2 == 0 || ctx.TxIndex() == rogueTx {
+			kv.Set(counterKey, []byte(strconv.Itoa(count+1)))
+		}
+		return types.ResponseDeliverTx{Data: []byte(strconv.Itoa(count)), GasUsed: 10}
+	}
+
+	for _, workers := range []int{1, 10} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			reqs := requestList(txs)
+			for idx, req := range reqs {
+				req.NoWritesExpected = idx%2 == 1
+			}
+			s := NewScheduler(workers, ti, deliverTx)
+			ctx := initTestCtx(true)
+			res, err := s.ProcessAll(ctx, reqs)
+			require.NoError(t, err)
+
+			// the flag is only a hint, so the rogue tx succeeds with its write kept, like under sequential execution
+			expected := 0
+			for idx, response := range res {
+				require.Equal(t, uint32(0), response.Code)
+				require.Equal(t, strconv.Itoa(expected), string(response.Data))
+				if idx%2 == 0 || idx == rogueTx {
+					expected++
+				}
+			}
+			kv := ctx.MultiStore().GetKVStore(testStoreKey)
+			require.Equal(t, expected, readCounter(kv))
+
+			_, err = VerifySequential(initTestCtx(true), reqs, workers, ti, deliverTx)
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("flagged txs need no estimates for the happy path", func(t *testing.T) {
+		// only the first tx increments the counter, and it declares so
+		deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+			defer abortRecoveryFunc(&response)
+			kv := ctx.MultiStore().GetKVStore(testStoreKey)
+			count := readCounter(kv)
+			if ctx.TxIndex() == 0 {
+				kv.Set(counterKey, []byte(strconv.Itoa(count+1)))
+			}
+			return types.ResponseDeliverTx{Data: []byte(strconv.Itoa(count))}
+		}
+		reqs := requestList(txs)
+		reqs[0].EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(counterKey): nil}}
+		reqs[0].EstimateConfidence = sdk.EstimateConfidenceGuaranteed
+		for _, req := range reqs[1:] {
+			req.NoWritesExpected = true
+		}
+		s := NewScheduler(10, ti, deliverTx)
+		ctx := initTestCtx(true)
+		res, err := s.ProcessAll(ctx, reqs)
+		require.NoError(t, err)
+		require.True(t, s.Metrics().HappyPath)
+		for _, response := range res[1:] {
+			require.Equal(t, uint32(0), response.Code)
+			require.Equal(t, "1", string(response.Data))
+		}
+	})
+
+	t.Run("a flagged tx writing on the happy path falls back to full occ", func(t *testing.T) {
+		// the first tx increments the counter, as it declares, and so does the flagged tx 1, which the others read
+		deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+			defer abortRecoveryFunc(&response)
+			kv := ctx.MultiStore().GetKVStore(testStoreKey)
+			count := readCounter(kv)
+			if ctx.TxIndex() <= 1 {
+				kv.Set(counterKey, []byte(strconv.Itoa(count+1)))
+			}
+			return types.ResponseDeliverTx{Data: []byte(strconv.Itoa(count))}
+		}
+		reqs := requestList(txs)
+		reqs[0].EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(counterKey): nil}}
+		reqs[0].EstimateConfidence = sdk.EstimateConfidenceGuaranteed
+		for _, req := range reqs[1:] {
+			req.NoWritesExpected = true
+		}
+		s := NewScheduler(10, ti, deliverTx)
+		ctx := initTestCtx(true)
+		res, err := s.ProcessAll(ctx, reqs)
+		require.NoError(t, err)
+		require.False(t, s.Metrics().HappyPath)
+		for _, response := range res[2:] {
+			require.Equal(t, uint32(0), response.Code)
+			require.Equal(t, "2", string(response.Data))
+		}
+		require.Equal(t, 2, readCounter(ctx.MultiStore().GetKVStore(testStoreKey)))
+	})
+}
@@ -0,0 +1,110 @@
+package occtest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/tasks"
+	"github.com/cosmos/cosmos-sdk/tasks/occtest"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+var storeKey = sdk.NewKVStoreKey("occtest")
+
+func TestNewWorkloadDeterministic(t *testing.T) {
+	cfg := occtest.DefaultConfig(42)
+	w := occtest.NewWorkload(storeKey, cfg)
+	require.Equal(t, w, occtest.NewWorkload(storeKey, cfg))
+	require.Len(t, w.Txs, cfg.Txs)
+	require.Len(t, w.Requests(), cfg.Txs)
+	require.NotEqual(t, w.Txs, occtest.NewWorkload(storeKey, occtest.DefaultConfig(43)).Txs)
+
+	kinds := make(map[occtest.OpKind]int)
+	for _, tx := range w.Txs {
+		require.Len(t, tx.Ops, cfg.OpsPerTx)
+		for _, op := range tx.Ops {
+			kinds[op.Kind]++
+		}
+	}
+	for _, kind := range []occtest.OpKind{occtest.OpGet, occtest.OpHas, occtest.OpSet, occtest.OpDelete, occtest.OpIterate} {
+		require.NotZero(t, kinds[kind], kind)
+	}
+
+	// without conflicts or iterators, txs only access keys of their own
+	cfg.ConflictRate = 0
+	cfg.IteratorProbability = 0
+	for i, tx := range occtest.NewWorkload(storeKey, cfg).Txs {
+		for _, op := range tx.Ops {
+			require.Contains(t, string(op.Key), "tx/", i)
+		}
+	}
+}
+
+func TestCheckSeeds(t *testing.T) {
+	for seed := int64(0); seed < 10; seed++ {
+		cfg := occtest.DefaultConfig(seed)
+		w := occtest.NewWorkload(storeKey, cfg)
+		ctx := occtest.NewContext(storeKey)
+		w.Init(ctx)
+		res, err := occtest.Check(ctx, w.Requests(), w.DeliverTx, 8)
+		require.NoError(t, err, "seed %d", seed)
+		require.Len(t, res.Responses, cfg.Txs)
+		for _, r := range res.Responses {
+			require.Zero(t, r.Code)
+			require.NotZero(t, r.GasUsed)
+			require.Len(t, r.Events, cfg.OpsPerTx)
+		}
+	}
+
+	// a fully contended block has conflicts, which are resolved
+	cfg := occtest.DefaultConfig(1)
+	cfg.ConflictRate = 1
+	cfg.Keys = 4
+	w := occtest.NewWorkload(storeKey, cfg)
+	ctx := occtest.NewContext(storeKey)
+	w.Init(ctx)
+	res, err := occtest.Check(ctx, w.Requests(), w.DeliverTx, 8, tasks.WithMaxIterations(100))
+	require.NoError(t, err)
+	require.Equal(t, cfg.Txs, res.Metrics.Txs)
+}
+
+func TestCheckMismatch(t *testing.T) {
+	w := occtest.NewWorkload(storeKey, occtest.DefaultConfig(7))
+	ctx := occtest.NewContext(storeKey)
+	w.Init(ctx)
+
+	// a nondeterministic tx, which writes an extra key when the oracle executes it again
+	calls := 0
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) types.ResponseDeliverTx {
+		res := w.DeliverTx(ctx, req)
+		calls++
+		if calls > len(w.Txs) && ctx.TxIndex() == 3 {
+			ctx.MultiStore().GetKVStore(storeKey).Set([]byte("extra"), []byte("value"))
+		}
+		return res
+	}
+	_, err := occtest.Check(ctx, w.Requests(), deliverTx, 1, tasks.WithMaxIterations(0))
+	require.True(t, errors.Is(err, tasks.ErrSequentialMismatch))
+	require.Contains(t, err.Error(), "missing key")
+}
+
+func FuzzCheck(f *testing.F) {
+	for seed := int64(0); seed < 5; seed++ {
+		f.Add(seed, 0.3, 0.1, 0.1)
+	}
+	f.Add(int64(5), 1.0, 0.5, 0.3)
+	f.Fuzz(func(t *testing.T, seed int64, conflictRate, deleteProbability, iteratorProbability float64) {
+		cfg := occtest.DefaultConfig(seed)
+		cfg.ConflictRate = conflictRate
+		cfg.DeleteProbability = deleteProbability
+		cfg.IteratorProbability = iteratorProbability
+		w := occtest.NewWorkload(storeKey, cfg)
+		ctx := occtest.NewContext(storeKey)
+		w.Init(ctx)
+		_, err := occtest.Check(ctx, w.Requests(), w.DeliverTx, 8)
+		require.NoError(t, err)
+	})
+}
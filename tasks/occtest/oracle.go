@@ -0,0 +1,111 @@
+package occtest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
+	dbm "github.com/tendermint/tm-db"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cosmos/cosmos-sdk/store/cachekv"
+	"github.com/cosmos/cosmos-sdk/store/cachemulti"
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/tasks"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/utils/tracing"
+)
+
+// DeliverTxFunc executes a tx of a block, recovering the occ.Abort panics of aborted executions
+type DeliverTxFunc func(ctx sdk.Context, req types.RequestDeliverTx) types.ResponseDeliverTx
+
+// Result is the outcome of a block checked against its sequential execution
+type Result struct {
+	// Responses are the responses of the scheduler, which match the sequential ones
+	Responses []types.ResponseDeliverTx
+	// Metrics are the OCC statistics of the block, eg. to assert that it had conflicts
+	Metrics tasks.SchedulerMetrics
+}
+
+// NewContext returns a context over an in-memory multistore with the given stores, for running workloads on
+func NewContext(storeKeys ...sdk.StoreKey) sdk.Context {
+	db := dbm.NewMemDB()
+	stores := make(map[sdk.StoreKey]sdk.CacheWrapper, len(storeKeys))
+	keys := make(map[string]sdk.StoreKey, len(storeKeys))
+	for _, storeKey := range storeKeys {
+		stores[storeKey] = cachekv.NewStore(dbadapter.Store{DB: dbm.NewMemDB()}, storeKey, 1000)
+		keys[storeKey.Name()] = storeKey
+	}
+	ms := cachemulti.NewStore(db, stores, keys, nil, nil, nil)
+	return sdk.NewContext(&ms, tmproto.Header{}, false, log.NewNopLogger()).WithContext(context.Background())
+}
+
+// Check processes the block with a scheduler created from the arguments, and executes it again sequentially by
+// calling deliverTx for every tx in order, each execution against its own branch of ctx. It returns an error wrapping
+// tasks.ErrSequentialMismatch that describes the first difference in the responses of the txs (including their
+// events and gas) or the final state of the stores. Neither execution is written to ctx.
+func Check(ctx sdk.Context, reqs []*sdk.DeliverTxEntry, deliverTx DeliverTxFunc, workers int, opts ...tasks.SchedulerOption) (Result, error) {
+	tr := trace.NewNoopTracerProvider().Tracer("occtest")
+	s := tasks.NewScheduler(workers, &tracing.Info{Tracer: &tr}, deliverTx, opts...)
+	parallelCtx := ctx.WithMultiStore(ctx.MultiStore().CacheMultiStore())
+	res, err := s.ProcessAll(parallelCtx, reqs)
+	if err != nil {
+		return Result{}, err
+	}
+
+	sequentialCtx := ctx.WithMultiStore(ctx.MultiStore().CacheMultiStore())
+	expected := make([]types.ResponseDeliverTx, 0, len(reqs))
+	for i, req := range reqs {
+		expected = append(expected, deliverTx(sequentialCtx.WithTxIndex(i), req.Request))
+	}
+
+	if len(res) != len(expected) {
+		return Result{}, fmt.Errorf("%w: %d responses, expected %d", tasks.ErrSequentialMismatch, len(res), len(expected))
+	}
+	for i := range res {
+		if err := tasks.CompareResponses(res[i], expected[i]); err != nil {
+			return Result{}, fmt.Errorf("%w: tx %d: %s", tasks.ErrSequentialMismatch, i, err)
+		}
+	}
+	if err := compareState(parallelCtx, sequentialCtx); err != nil {
+		return Result{}, fmt.Errorf("%w: %s", tasks.ErrSequentialMismatch, err)
+	}
+	return Result{Responses: res, Metrics: s.Metrics()}, nil
+}
+
+// compareState returns an error describing the first key whose final value differs between the stores of both
+// contexts, if any does
+func compareState(ctx sdk.Context, expectedCtx sdk.Context) error {
+	for _, storeKey := range expectedCtx.MultiStore().StoreKeys() {
+		it := ctx.MultiStore().GetKVStore(storeKey).Iterator(nil, nil)
+		expectedIt := expectedCtx.MultiStore().GetKVStore(storeKey).Iterator(nil, nil)
+		err := compareIterators(it, expectedIt)
+		it.Close()
+		expectedIt.Close()
+		if err != nil {
+			return fmt.Errorf("store %s: %s", storeKey.Name(), err)
+		}
+	}
+	return nil
+}
+
+func compareIterators(it sdk.Iterator, expected sdk.Iterator) error {
+	for ; expected.Valid(); expected.Next() {
+		switch {
+		case !it.Valid() || bytes.Compare(it.Key(), expected.Key()) > 0:
+			return fmt.Errorf("missing key %X", expected.Key())
+		case bytes.Compare(it.Key(), expected.Key()) < 0:
+			return fmt.Errorf("unexpected key %X", it.Key())
+		case !bytes.Equal(it.Value(), expected.Value()):
+			return fmt.Errorf("key %X is %X, expected %X", it.Key(), it.Value(), expected.Value())
+		}
+		it.Next()
+	}
+	if it.Valid() {
+		return fmt.Errorf("unexpected key %X", it.Key())
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+// Package occtest is a deterministic, seeded test harness for the OCC scheduler. It generates randomized key-value
+// workloads with a configurable key space, conflict rate, delete probability and iterator usage, and checks any block
+// processed by the scheduler against its sequential execution, so that downstream chains can fuzz their own modules
+// under OCC with the same oracle, see Check.
+package occtest
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// OpKind is the kind of a store operation of a generated tx
+type OpKind string
+
+const (
+	OpGet     OpKind = "get"
+	OpHas     OpKind = "has"
+	OpSet     OpKind = "set"
+	OpDelete  OpKind = "delete"
+	OpIterate OpKind = "iterate"
+)
+
+// EventTypeOp is the type of the events emitted by the operations of generated txs
+const EventTypeOp = "occtest_op"
+
+// Config configures the generation of a workload. The same config always generates the same workload.
+type Config struct {
+	Seed int64
+	// Txs is the number of txs of the block, and OpsPerTx the number of store operations of each
+	Txs      int
+	OpsPerTx int
+	// Keys is the size of the key space shared by all txs
+	Keys int
+	// ConflictRate is the probability of an operation accessing the shared key space rather than keys of the tx's own,
+	// which no other tx accesses
+	ConflictRate float64
+	// DeleteProbability is the probability of an operation being a delete, and IteratorProbability of it iterating
+	// over a range of the shared key space. Other operations are evenly split between reads and writes, a third of
+	// the reads being existence checks.
+	DeleteProbability   float64
+	IteratorProbability float64
+}
+
+// DefaultConfig returns a moderately contended config generating a block of 50 txs from the seed
+func DefaultConfig(seed int64) Config {
+	return Config{
+		Seed:                seed,
+		Txs:                 50,
+		OpsPerTx:            8,
+		Keys:                32,
+		ConflictRate:        0.3,
+		DeleteProbability:   0.1,
+		IteratorProbability: 0.1,
+	}
+}
+
+// Op is a store operation of a generated tx. Iterations cover [Key, End), in reverse if Reverse is set, and stop after
+// Limit entries.
+type Op struct {
+	Kind    OpKind `json:"kind"`
+	Key     []byte `json:"key"`
+	End     []byte `json:"end,omitempty"`
+	Reverse bool   `json:"reverse,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+// Tx is a generated tx, executed by Workload.DeliverTx
+type Tx struct {
+	Ops []Op `json:"ops"`
+}
+
+// Workload is a generated block of txs operating on a single store, along with the initial state of the store
+type Workload struct {
+	StoreKey sdk.StoreKey
+	Initial  map[string][]byte
+	Txs      []Tx
+}
+
+// sharedKey returns the key of the shared key space at i, which sort in i order
+func sharedKey(i int) []byte {
+	return []byte(fmt.Sprintf("shared/%06d", i))
+}
+
+// sharedEnd is the end of the shared key space
+var sharedEnd = []byte("shared0")
+
+// NewWorkload generates a workload on the store from the config
+func NewWorkload(storeKey sdk.StoreKey, cfg Config) Workload {
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	w := Workload{
+		StoreKey: storeKey,
+		Initial:  make(map[string][]byte),
+		Txs:      make([]Tx, 0, cfg.Txs),
+	}
+	// about half of the shared keys exist before the block
+	for i := 0; i < cfg.Keys; i++ {
+		if rng.Intn(2) == 0 {
+			w.Initial[string(sharedKey(i))] = []byte(fmt.Sprintf("initial%d", rng.Int63()))
+		}
+	}
+	for i := 0; i < cfg.Txs; i++ {
+		tx := Tx{Ops: make([]Op, 0, cfg.OpsPerTx)}
+		for j := 0; j < cfg.OpsPerTx; j++ {
+			tx.Ops = append(tx.Ops, randomOp(rng, cfg, i))
+		}
+		w.Txs = append(w.Txs, tx)
+	}
+	return w
+}
+
+// randomOp generates an operation of the tx at index
+func randomOp(rng *rand.Rand, cfg Config, index int) Op {
+	key := []byte(fmt.Sprintf("tx/%06d/%02d", index, rng.Intn(4)))
+	if cfg.Keys > 0 && rng.Float64() < cfg.ConflictRate {
+		key = sharedKey(rng.Intn(cfg.Keys))
+	}
+	r := rng.Float64()
+	switch {
+	case cfg.Keys > 0 && r < cfg.IteratorProbability:
+		return Op{
+			Kind:    OpIterate,
+			Key:     sharedKey(rng.Intn(cfg.Keys)),
+			End:     sharedEnd,
+			Reverse: rng.Intn(2) == 0,
+			Limit:   1 + rng.Intn(5),
+		}
+	case r < cfg.IteratorProbability+cfg.DeleteProbability:
+		return Op{Kind: OpDelete, Key: key}
+	}
+	switch rng.Intn(6) {
+	case 0, 1:
+		return Op{Kind: OpGet, Key: key}
+	case 2:
+		return Op{Kind: OpHas, Key: key}
+	default:
+		return Op{Kind: OpSet, Key: key}
+	}
+}
+
+// Init writes the initial state of the workload to the store of ctx
+func (w Workload) Init(ctx sdk.Context) {
+	kv := ctx.MultiStore().GetKVStore(w.StoreKey)
+	for key, value := range w.Initial {
+		kv.Set([]byte(key), value)
+	}
+}
+
+// Requests returns the requests of the block of the workload, the txs of which are executed by DeliverTx
+func (w Workload) Requests() []*sdk.DeliverTxEntry {
+	reqs := make([]*sdk.DeliverTxEntry, 0, len(w.Txs))
+	for _, tx := range w.Txs {
+		bz, err := json.Marshal(tx)
+		if err != nil {
+			panic(err)
+		}
+		reqs = append(reqs, &sdk.DeliverTxEntry{Request: types.RequestDeliverTx{Tx: bz}})
+	}
+	return reqs
+}
+
+// DeliverTx executes a tx of the workload. Every value read is folded into a digest of the execution, which every
+// value written derives from and which is returned as the response data, so that any stale read changes the output.
+// Every operation emits an event with the value it observed or wrote, and the gas of the store operations is used.
+// Executions aborted by the scheduler are recovered, as baseapp does.
+func (w Workload) DeliverTx(ctx sdk.Context, req types.RequestDeliverTx) (res types.ResponseDeliverTx) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := occ.RecoveredAbort(r); !ok {
+				panic(r)
+			}
+			res = types.ResponseDeliverTx{Info: "occ abort"}
+		}
+	}()
+	var tx Tx
+	if err := json.Unmarshal(req.Tx, &tx); err != nil {
+		return types.ResponseDeliverTx{Code: 1, Log: err.Error()}
+	}
+	gasMeter := sdk.NewInfiniteGasMeter()
+	ctx = ctx.WithGasMeter(gasMeter).WithEventManager(sdk.NewEventManager())
+	kv := ctx.KVStore(w.StoreKey)
+
+	digest := sha256.New()
+	fold := func(bz ...[]byte) {
+		for _, b := range bz {
+			var length [8]byte
+			binary.BigEndian.PutUint64(length[:], uint64(len(b)))
+			digest.Write(length[:])
+			digest.Write(b)
+		}
+	}
+	emit := func(op Op, value []byte) {
+		ctx.EventManager().EmitEvent(sdk.NewEvent(EventTypeOp,
+			sdk.NewAttribute("kind", string(op.Kind)),
+			sdk.NewAttribute("key", string(op.Key)),
+			sdk.NewAttribute("value", hex.EncodeToString(value)),
+		))
+	}
+	for i, op := range tx.Ops {
+		switch op.Kind {
+		case OpGet:
+			value := kv.Get(op.Key)
+			fold(op.Key, value)
+			emit(op, value)
+		case OpHas:
+			exists := []byte("false")
+			if kv.Has(op.Key) {
+				exists = []byte("true")
+			}
+			fold(op.Key, exists)
+			emit(op, exists)
+		case OpSet:
+			fold(op.Key, []byte{byte(i)})
+			value := digest.Sum(nil)
+			kv.Set(op.Key, value)
+			emit(op, value)
+		case OpDelete:
+			kv.Delete(op.Key)
+			fold(op.Key)
+			emit(op, nil)
+		case OpIterate:
+			it := kv.Iterator(op.Key, op.End)
+			if op.Reverse {
+				it = kv.ReverseIterator(op.Key, op.End)
+			}
+			var n int
+			for ; it.Valid() && n < op.Limit; it.Next() {
+				fold(it.Key(), it.Value())
+				n++
+			}
+			it.Close()
+			emit(op, []byte{byte(n)})
+		default:
+			return types.ResponseDeliverTx{Code: 1, Log: fmt.Sprintf("unknown op %q", op.Kind)}
+		}
+	}
+	return types.ResponseDeliverTx{
+		Data:    digest.Sum(nil),
+		GasUsed: int64(gasMeter.GasConsumed()),
+		Events:  ctx.EventManager().ABCIEvents(),
+	}
+}
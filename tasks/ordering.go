@@ -0,0 +1,73 @@
+package tasks
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// SuggestOrder suggests an order for the txs of a prospective block that the scheduler executes with fewer conflicts,
+// from the estimated writesets and readsets of the txs, eg. as learned by SimulateBlock. It returns the indices of reqs
+// in the suggested order.
+//
+// Txs conflict if one writes a key the other reads or writes in the same store, and conflicting txs keep their
+// relative order, so the block has the same outcome in the suggested order as in the given one, as long as the
+// estimates are complete. The rest are free to move: the conflict graph is colored in levels, where every tx is at
+// the level after the highest level of the earlier txs it conflicts with, and txs are ordered level by level, in
+// index order within a level. Txs of a level don't conflict with each other, so neither do the txs the scheduler
+// executes concurrently, as long as levels are larger than the number of workers. Txs without estimates are at the
+// first level.
+func SuggestOrder(reqs []*sdk.DeliverTxEntry) []int {
+	// the highest level of the earlier txs that wrote and read every key, by store
+	writeLevels := make(map[sdk.StoreKey]map[string]int)
+	readLevels := make(map[sdk.StoreKey]map[string]int)
+	after := func(levels map[sdk.StoreKey]map[string]int, storeKey sdk.StoreKey, key string, level int) int {
+		if earlier, ok := levels[storeKey][key]; ok && earlier >= level {
+			return earlier + 1
+		}
+		return level
+	}
+	record := func(levels map[sdk.StoreKey]map[string]int, storeKey sdk.StoreKey, key string, level int) {
+		if _, ok := levels[storeKey]; !ok {
+			levels[storeKey] = make(map[string]int)
+		}
+		if earlier, ok := levels[storeKey][key]; !ok || level > earlier {
+			levels[storeKey][key] = level
+		}
+	}
+
+	var levels [][]int
+	for idx, req := range reqs {
+		level := 0
+		// reads conflict with earlier writes, and writes with earlier reads and writes
+		for storeKey, readset := range req.EstimatedReadsets {
+			for key := range readset {
+				level = after(writeLevels, storeKey, key, level)
+			}
+		}
+		for storeKey, writeset := range req.EstimatedWritesets {
+			for key := range writeset {
+				level = after(writeLevels, storeKey, key, level)
+				level = after(readLevels, storeKey, key, level)
+			}
+		}
+		for storeKey, readset := range req.EstimatedReadsets {
+			for key := range readset {
+				record(readLevels, storeKey, key, level)
+			}
+		}
+		for storeKey, writeset := range req.EstimatedWritesets {
+			for key := range writeset {
+				record(writeLevels, storeKey, key, level)
+			}
+		}
+		if level == len(levels) {
+			levels = append(levels, nil)
+		}
+		levels[level] = append(levels[level], idx)
+	}
+
+	order := make([]int, 0, len(reqs))
+	for _, level := range levels {
+		order = append(order, level...)
+	}
+	return order
+}
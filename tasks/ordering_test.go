@@ -0,0 +1,128 @@
+package tasks
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestSuggestOrder(t *testing.T) {
+	access := func(reads []string, writes []string) *sdk.DeliverTxEntry {
+		req := &sdk.DeliverTxEntry{}
+		if len(reads) > 0 {
+			readset := make(multiversion.ReadSet)
+			for _, key := range reads {
+				readset[key] = nil
+			}
+			req.EstimatedReadsets = sdk.MappedReadsets{testStoreKey: readset}
+		}
+		if len(writes) > 0 {
+			writeset := make(multiversion.WriteSet)
+			for _, key := range writes {
+				writeset[key] = nil
+			}
+			req.EstimatedWritesets = sdk.MappedWritesets{testStoreKey: writeset}
+		}
+		return req
+	}
+	reqs := []*sdk.DeliverTxEntry{
+		access(nil, []string{"a"}),
+		// reads what tx 0 writes
+		access([]string{"a"}, nil),
+		access(nil, []string{"b"}),
+		// writes what tx 1 read
+		access(nil, []string{"a"}),
+		// only reads, like tx 1
+		access([]string{"a"}, nil),
+		// no estimates
+		{},
+		// the same key in another store doesn't conflict
+		{EstimatedWritesets: sdk.MappedWritesets{sdk.NewKVStoreKey("other"): {"a": nil}}},
+		access([]string{"b"}, []string{"c"}),
+	}
+	require.Equal(t, []int{0, 2, 5, 6, 1, 7, 3, 4}, SuggestOrder(reqs))
+	require.Equal(t, []int{0, 1, 2}, SuggestOrder(requestList(3)))
+	require.Empty(t, SuggestOrder(nil))
+}
+
+// accessTx encodes a tx that reads and then writes keys, for deliverAccessTx
+func accessTx(id int, reads, writes []string) []byte {
+	return []byte(fmt.Sprintf("%d;%s;%s", id, strings.Join(reads, ","), strings.Join(writes, ",")))
+}
+
+// deliverAccessTx executes a tx encoded by accessTx, writing a digest of its id and the values it read to every key
+// it writes, and responding with the digest
+func deliverAccessTx(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+	defer abortRecoveryFunc(&response)
+	parts := strings.Split(string(req.Tx), ";")
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	h := sha256.New()
+	h.Write([]byte(parts[0]))
+	for _, key := range strings.Split(parts[1], ",") {
+		if key != "" {
+			h.Write(kv.Get([]byte(key)))
+		}
+	}
+	digest := h.Sum(nil)
+	for _, key := range strings.Split(parts[2], ",") {
+		if key != "" {
+			kv.Set([]byte(key), digest)
+		}
+	}
+	return types.ResponseDeliverTx{Info: fmt.Sprintf("%s:%X", parts[0], digest)}
+}
+
+func TestSuggestOrderKeepsOutcome(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	rng := rand.New(rand.NewSource(1))
+	randomKeys := func() []string {
+		var keys []string
+		for i := rng.Intn(3); i > 0; i-- {
+			keys = append(keys, fmt.Sprintf("key%d", rng.Intn(40)))
+		}
+		return keys
+	}
+	reqs := make([]*sdk.DeliverTxEntry, 100)
+	for idx := range reqs {
+		reqs[idx] = &sdk.DeliverTxEntry{Request: types.RequestDeliverTx{Tx: accessTx(idx, randomKeys(), randomKeys())}}
+	}
+
+	s := NewScheduler(10, ti, deliverAccessTx)
+	simulation, err := s.SimulateBlock(initTestCtx(true), reqs)
+	require.NoError(t, err)
+	for idx, req := range reqs {
+		req.EstimatedWritesets = simulation.Writesets[idx]
+		req.EstimatedReadsets = simulation.Readsets[idx]
+	}
+	order := SuggestOrder(reqs)
+	require.Len(t, order, len(reqs))
+	require.NotEqual(t, order, SuggestOrder(requestList(len(reqs))))
+
+	// every tx responds and writes the same in the suggested order as in the original one
+	ctx := initTestCtx(true)
+	res, err := s.ProcessAll(ctx, reqs)
+	require.NoError(t, err)
+	reordered := make([]*sdk.DeliverTxEntry, 0, len(reqs))
+	for _, idx := range order {
+		reordered = append(reordered, reqs[idx])
+	}
+	reorderedCtx := initTestCtx(true)
+	reorderedRes, err := s.ProcessAll(reorderedCtx, reordered)
+	require.NoError(t, err)
+	for i, idx := range order {
+		require.Equal(t, res[idx].Info, reorderedRes[i].Info)
+	}
+	for i := 0; i < 40; i++ {
+		key := []byte(fmt.Sprintf("key%d", i))
+		require.Equal(t, ctx.MultiStore().GetKVStore(testStoreKey).Get(key), reorderedCtx.MultiStore().GetKVStore(testStoreKey).Get(key))
+	}
+}
@@ -0,0 +1,42 @@
+package tasks
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ParentMutationPolicy is how the scheduler handles a block whose parent stores changed while it was processed, see
+// multiversion.ErrParentStateMutation
+type ParentMutationPolicy int
+
+const (
+	// ParentMutationFallback falls back to executing the txs that aren't validated yet sequentially, against the
+	// mutated parent stores. Txs validated before the mutation was found keep their results.
+	ParentMutationFallback ParentMutationPolicy = iota
+	// ParentMutationHalt fails the block with the mutation, a *multiversion.ParentStateMutationError
+	ParentMutationHalt
+)
+
+// WithParentMutationPolicy sets how the scheduler handles a block whose parent stores changed while it was processed.
+// Defaults to ParentMutationFallback.
+func WithParentMutationPolicy(policy ParentMutationPolicy) SchedulerOption {
+	return func(s *scheduler) { s.parentMutationPolicy = policy }
+}
+
+// handleParentMutations applies the parent mutation policy if validation found a mutation of a parent store, returning
+// the mutation if the block must halt
+func (s *scheduler) handleParentMutations(ctx sdk.Context) error {
+	for _, mv := range s.orderedStores {
+		err := mv.store.ParentStateMutation()
+		if err == nil {
+			continue
+		}
+		if s.parentMutationPolicy == ParentMutationHalt {
+			ctx.Logger().Error("occ scheduler parent store mutated, halting block", "height", ctx.BlockHeight(), "err", err)
+			return err
+		}
+		s.recordFallback(ctx, FallbackParentMutation, err)
+		s.synchronous = true
+		return nil
+	}
+	return nil
+}
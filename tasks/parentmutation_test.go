@@ -0,0 +1,67 @@
+package tasks
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllParentMutation(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	const txs = 10
+	const mutatingTx = 3
+	parentKey := []byte("parent")
+	for _, tc := range []struct {
+		name   string
+		policy ParentMutationPolicy
+	}{
+		{"fallback", ParentMutationFallback},
+		{"halt", ParentMutationHalt},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := initTestCtx(true)
+			parent := ctx.MultiStore().GetKVStore(testStoreKey)
+			parent.Set(parentKey, []byte("original"))
+
+			// every tx reads a key only the parent store has, which the first execution of a tx changes in the parent
+			// store behind the scheduler's back, eg. like a migration would
+			mutated := false
+			deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+				defer abortRecoveryFunc(&response)
+				kv := ctx.MultiStore().GetKVStore(testStoreKey)
+				value := kv.Get(parentKey)
+				if ctx.TxIndex() == mutatingTx && !mutated {
+					mutated = true
+					parent.Set(parentKey, []byte("mutated"))
+				}
+				kv.Set(req.Tx, value)
+				return types.ResponseDeliverTx{Info: string(value)}
+			}
+
+			s := NewScheduler(1, ti, deliverTx, WithParentMutationPolicy(tc.policy))
+			res, err := s.ProcessAll(ctx, requestList(txs))
+			if tc.policy == ParentMutationHalt {
+				require.ErrorIs(t, err, multiversion.ErrParentStateMutation)
+				require.Nil(t, res)
+				return
+			}
+			require.NoError(t, err)
+
+			// the txs that read the original value before the mutation are re-executed sequentially against the
+			// mutated parent store
+			postmortem := s.Metrics().Postmortem
+			require.NotNil(t, postmortem)
+			require.Equal(t, FallbackParentMutation, postmortem.Reason)
+			for i, r := range res {
+				require.Equal(t, "mutated", r.Info, "tx %d", i)
+				require.Equal(t, []byte("mutated"), parent.Get([]byte(strconv.Itoa(i))))
+			}
+		})
+	}
+}
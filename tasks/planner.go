@@ -0,0 +1,84 @@
+package tasks
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// WithDependencyPlanning enables planning the first execution round of a block from the estimated writesets (and
+// readsets) of its txs. The estimates form a dependency DAG, where a tx depends on the latest earlier tx estimated to
+// write a key it's estimated to read or write, and the txs are dispatched in topological waves: every tx of a wave
+// finishes executing before the next wave starts, so independent txs run first and dependent txs run after their
+// writers instead of aborting on their estimates. Txs without estimates run in the first wave.
+func WithDependencyPlanning() SchedulerOption {
+	return func(s *scheduler) { s.dependencyPlanning = true }
+}
+
+// planWaves groups the requests of a block into topological waves of tx indices, in index order within each wave
+func planWaves(reqs []*sdk.DeliverTxEntry) [][]int {
+	// the latest estimated writer of every key, by store
+	lastWriters := make(map[sdk.StoreKey]map[string]int)
+	txWaves := make([]int, len(reqs))
+	var waves [][]int
+	for idx, req := range reqs {
+		wave := 0
+		dependOn := func(storeKey sdk.StoreKey, key string) {
+			if writer, ok := lastWriters[storeKey][key]; ok && txWaves[writer] >= wave {
+				wave = txWaves[writer] + 1
+			}
+		}
+		for storeKey, readset := range req.EstimatedReadsets {
+			for key := range readset {
+				dependOn(storeKey, key)
+			}
+		}
+		for storeKey, writeset := range req.EstimatedWritesets {
+			for key := range writeset {
+				dependOn(storeKey, key)
+			}
+		}
+		for storeKey, writeset := range req.EstimatedWritesets {
+			if _, ok := lastWriters[storeKey]; !ok {
+				lastWriters[storeKey] = make(map[string]int)
+			}
+			for key := range writeset {
+				lastWriters[storeKey][key] = idx
+			}
+		}
+		txWaves[idx] = wave
+		if wave == len(waves) {
+			waves = append(waves, nil)
+		}
+		waves[wave] = append(waves[wave], idx)
+	}
+	return waves
+}
+
+// planFirstRound returns the waves to dispatch the first round of a block in, or nil if it shouldn't be planned. It's
+// only worthwhile if every task still has to execute and the estimates actually order some of them.
+func (s *scheduler) planFirstRound(reqs []*sdk.DeliverTxEntry, toExecute []*deliverTxTask) [][]int {
+	if !s.dependencyPlanning || len(toExecute) != len(reqs) {
+		return nil
+	}
+	planner := s.planner
+	if planner == nil {
+		planner = planWaves
+	}
+	if waves := planner(reqs); len(waves) > 1 {
+		return waves
+	}
+	return nil
+}
+
+// executePlanned executes the tasks of a block wave by wave
+func (s *scheduler) executePlanned(ctx sdk.Context, tasks []*deliverTxTask, waves [][]int) error {
+	for _, wave := range waves {
+		waveTasks := make([]*deliverTxTask, 0, len(wave))
+		for _, idx := range wave {
+			waveTasks = append(waveTasks, tasks[idx])
+		}
+		if err := s.executeAll(ctx, waveTasks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestPlanWaves(t *testing.T) {
+	writes := func(keys ...string) sdk.MappedWritesets {
+		writeset := make(multiversion.WriteSet, len(keys))
+		for _, key := range keys {
+			writeset[key] = nil
+		}
+		return sdk.MappedWritesets{testStoreKey: writeset}
+	}
+	reqs := requestList(7)
+	reqs[0].EstimatedWritesets = writes("a")
+	reqs[1].EstimatedWritesets = writes("b")
+	// write-write overlap with tx 0
+	reqs[2].EstimatedWritesets = writes("a")
+	// reads the latest writer of a, which is tx 2
+	reqs[3].EstimatedReadsets = sdk.MappedReadsets{testStoreKey: {"a": nil}}
+	// tx 4 has no estimates
+	reqs[5].EstimatedWritesets = writes("b", "c")
+	// the same key in another store doesn't overlap
+	reqs[6].EstimatedWritesets = sdk.MappedWritesets{sdk.NewKVStoreKey("other"): {"a": nil}}
+
+	require.Equal(t, [][]int{{0, 1, 4, 6}, {2, 5}, {3}}, planWaves(reqs))
+	require.Equal(t, [][]int{{0, 1, 2}}, planWaves(requestList(3)))
+	require.Empty(t, planWaves(nil))
+}
+
+func TestProcessAllDependencyPlanning(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the shared key
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	const txs = 20
+	for _, planning := range []bool{false, true} {
+		t.Run(fmt.Sprintf("planning %t", planning), func(t *testing.T) {
+			reqs := requestList(txs)
+			// the estimates of every tx are accurate, so they form a chain
+			for _, req := range reqs {
+				req.EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(itemKey): nil}}
+			}
+			opts := []SchedulerOption{}
+			if planning {
+				opts = append(opts, WithDependencyPlanning())
+			}
+			s := NewScheduler(10, ti, deliverTx, opts...)
+			ctx := initTestCtx(true)
+			res, err := s.ProcessAll(ctx, reqs)
+			require.NoError(t, err)
+
+			expected := ""
+			for idx, response := range res {
+				expected = expected + fmt.Sprintf("%d,", idx)
+				require.Equal(t, expected, response.Info)
+			}
+			require.Equal(t, expected, string(ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey)))
+
+			m := s.Metrics()
+			if !planning {
+				require.Zero(t, m.PlannedWaves)
+				return
+			}
+			// every tx ran after its writer, so nothing aborted or re-executed
+			require.Equal(t, txs, m.PlannedWaves)
+			require.Zero(t, m.Aborts)
+			require.Zero(t, m.Retries)
+			require.Zero(t, m.MaxIncarnation)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package tasks
+
+// ConflictDecision is what the scheduler does with a task that failed validation
+type ConflictDecision int
+
+const (
+	// DecisionRerun re-executes the task in the next round
+	DecisionRerun ConflictDecision = iota
+	// DecisionWait parks the task until all of its dependencies are validated, and re-executes it after that
+	DecisionWait
+)
+
+// Conflict describes a task that failed validation
+type Conflict struct {
+	// Index is the index of the task
+	Index int
+	// Incarnation is the incarnation that failed validation
+	Incarnation int
+	// Conflicts are the sorted indices of the lower-index txs the task conflicted with
+	Conflicts []int
+	// DependenciesValidated is true if every tx the task has ever depended on is validated
+	DependenciesValidated bool
+}
+
+// ConflictPolicy decides what the scheduler does with a task that failed validation. The task's writes are always
+// invalidated first, so a policy only decides when the task is re-executed.
+type ConflictPolicy interface {
+	Resolve(conflict Conflict) ConflictDecision
+}
+
+// WaitForDependenciesPolicy re-executes a conflicting task right away if its dependencies are validated, and otherwise
+// waits for them, since re-executing before they settle is likely to conflict again. This is the default policy.
+type WaitForDependenciesPolicy struct{}
+
+var _ ConflictPolicy = WaitForDependenciesPolicy{}
+
+// Resolve implements ConflictPolicy.
+func (WaitForDependenciesPolicy) Resolve(conflict Conflict) ConflictDecision {
+	if conflict.DependenciesValidated {
+		return DecisionRerun
+	}
+	return DecisionWait
+}
+
+// RerunImmediatelyPolicy always re-executes a conflicting task in the next round, trading possibly wasted executions
+// for not leaving workers idle while dependencies settle.
+type RerunImmediatelyPolicy struct{}
+
+var _ ConflictPolicy = RerunImmediatelyPolicy{}
+
+// Resolve implements ConflictPolicy.
+func (RerunImmediatelyPolicy) Resolve(Conflict) ConflictDecision {
+	return DecisionRerun
+}
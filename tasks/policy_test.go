@@ -0,0 +1,88 @@
+package tasks
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestConflictPolicies(t *testing.T) {
+	settled := Conflict{Index: 2, Conflicts: []int{1}, DependenciesValidated: true}
+	unsettled := Conflict{Index: 2, Conflicts: []int{1}, DependenciesValidated: false}
+
+	require.Equal(t, DecisionRerun, WaitForDependenciesPolicy{}.Resolve(settled))
+	require.Equal(t, DecisionWait, WaitForDependenciesPolicy{}.Resolve(unsettled))
+	require.Equal(t, DecisionRerun, RerunImmediatelyPolicy{}.Resolve(settled))
+	require.Equal(t, DecisionRerun, RerunImmediatelyPolicy{}.Resolve(unsettled))
+}
+
+// recordingPolicy wraps a policy and records every conflict it resolves
+type recordingPolicy struct {
+	ConflictPolicy
+	mx        sync.Mutex
+	conflicts []Conflict
+}
+
+func (p *recordingPolicy) Resolve(conflict Conflict) ConflictDecision {
+	p.mx.Lock()
+	p.conflicts = append(p.conflicts, conflict)
+	p.mx.Unlock()
+	return p.ConflictPolicy.Resolve(conflict)
+}
+
+func TestProcessAllConflictPoliciesAgree(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx reads the shared key, appends its index and writes it back, and writes its own key
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		newVal := val + fmt.Sprintf("%d", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		kv.Set(req.Tx, []byte(newVal))
+		return types.ResponseDeliverTx{
+			Info: newVal,
+		}
+	}
+
+	run := func(policy ConflictPolicy) ([]types.ResponseDeliverTx, sdk.Context) {
+		s := NewScheduler(10, ti, deliverTx, WithConflictPolicy(policy))
+		ctx := initTestCtx(true)
+		res, err := s.ProcessAll(ctx, requestList(50))
+		require.NoError(t, err)
+		return res, ctx
+	}
+
+	policies := map[string]*recordingPolicy{
+		"wait for dependencies": {ConflictPolicy: WaitForDependenciesPolicy{}},
+		"rerun immediately":     {ConflictPolicy: RerunImmediatelyPolicy{}},
+	}
+	for name, policy := range policies {
+		t.Run(name, func(t *testing.T) {
+			res, ctx := run(policy)
+			kv := ctx.MultiStore().GetKVStore(testStoreKey)
+
+			// every policy produces the sequential result
+			expected := ""
+			for idx, response := range res {
+				expected = expected + fmt.Sprintf("%d", idx)
+				require.Equal(t, expected, response.Info)
+				require.Equal(t, expected, string(kv.Get([]byte(fmt.Sprintf("%d", idx)))))
+			}
+			require.Equal(t, expected, string(kv.Get(itemKey)))
+
+			// the policy is only consulted for conflicts with lower-index txs
+			for _, conflict := range policy.conflicts {
+				for _, c := range conflict.Conflicts {
+					require.Less(t, c, conflict.Index)
+				}
+			}
+		})
+	}
+}
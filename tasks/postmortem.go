@@ -0,0 +1,189 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+
+	metrics "github.com/armon/go-metrics"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// postmortemTopConflicts is the number of most conflicted txs kept in a postmortem
+const postmortemTopConflicts = 5
+
+// FallbackReason is why the scheduler fell back to sequential execution
+type FallbackReason int
+
+const (
+	// FallbackRoundLimit is a block that didn't converge within the maximum number of rounds, see WithMaxIterations
+	FallbackRoundLimit FallbackReason = iota
+	// FallbackInterrupt is a block whose context was done before it converged, see WithSequentialOnInterrupt
+	FallbackInterrupt
+	// FallbackRollback is a block rolled back to its last checkpoint after an invariant violation
+	FallbackRollback
+	// FallbackTaskTimeout is a block with a tx whose execution timed out, see WithTaskTimeout
+	FallbackTaskTimeout
+	// FallbackTrackingLimit is a block with a tx that recorded too many reads or writes, see WithTrackingLimits
+	FallbackTrackingLimit
+	// FallbackParentMutation is a block whose parent stores changed while it was processed, see
+	// WithParentMutationPolicy
+	FallbackParentMutation
+	// FallbackMemoryBudget is a block whose multiversion stores exceeded the memory budget, see WithBlockMemoryBudget
+	FallbackMemoryBudget
+	// FallbackLivelock is a block whose validated frontier stalled, see WithLivelockDetection
+	FallbackLivelock
+	// FallbackTaskLimit is a block with a tx that exceeded the limits of its tx limiter, see WithTxLimiter
+	FallbackTaskLimit
+	// FallbackTaskMemoryLimit is a block with a tx whose version stores exceeded the memory limit, see
+	// WithTaskMemoryLimit
+	FallbackTaskMemoryLimit
+)
+
+func (r FallbackReason) String() string {
+	switch r {
+	case FallbackRoundLimit:
+		return "round_limit"
+	case FallbackInterrupt:
+		return "interrupt"
+	case FallbackRollback:
+		return "rollback"
+	case FallbackTaskTimeout:
+		return "task_timeout"
+	case FallbackTrackingLimit:
+		return "tracking_limit"
+	case FallbackParentMutation:
+		return "parent_mutation"
+	case FallbackMemoryBudget:
+		return "memory_budget"
+	case FallbackLivelock:
+		return "livelock"
+	case FallbackTaskLimit:
+		return "task_limit"
+	case FallbackTaskMemoryLimit:
+		return "task_memory_limit"
+	default:
+		return "unknown"
+	}
+}
+
+// ConflictHotspot is a tx that later txs of its block conflicted with
+type ConflictHotspot struct {
+	// Index is the index of the tx
+	Index int
+	// Conflicts is the number of distinct later txs that conflicted with it
+	Conflicts int
+}
+
+// FallbackConfig is the scheduler configuration that bears on falling back to sequential execution
+type FallbackConfig struct {
+	Workers               int
+	MaxIterations         int
+	SequentialOnInterrupt bool
+	TaskMemoryLimit       int
+	BlockMemoryBudget     int64
+	DependencyPlanning    bool
+	// ConflictPolicy is the type of the conflict policy
+	ConflictPolicy string
+}
+
+// FallbackPostmortem is a compact diagnostic of a block falling back to sequential execution, capturing what the
+// scheduler saw as of the fallback so that it can be acted on rather than being a silent mode switch. Only the first
+// fallback of a block is captured, since the block stays sequential from then on.
+type FallbackPostmortem struct {
+	Height int64
+	Reason FallbackReason
+	// Cause is the error that triggered the fallback, if any
+	Cause string
+	// Txs is the number of txs in the block, and Validated the number of leading txs that were validated
+	Txs       int
+	Validated int
+	// Retries, Aborts and MaxIncarnation are as in SchedulerMetrics, as of the fallback
+	Retries        int
+	Aborts         int
+	MaxIncarnation int
+	AbortReasons   map[string]int
+	// TopConflicts are the txs that most later txs conflicted with, most conflicted first
+	TopConflicts []ConflictHotspot
+	Config       FallbackConfig
+}
+
+// recordFallback captures the postmortem of the block falling back to sequential execution for the given reason, and
+// logs it, unless the block already fell back
+func (s *scheduler) recordFallback(ctx sdk.Context, reason FallbackReason, cause error) {
+	if s.synchronous || s.metrics.postmortem != nil {
+		return
+	}
+	snapshot := s.metrics.snapshot()
+	validated, anyLeft := s.findFirstNonValidated()
+	if !anyLeft {
+		validated = len(s.allTasks)
+	}
+	postmortem := &FallbackPostmortem{
+		Height:         ctx.BlockHeight(),
+		Reason:         reason,
+		Txs:            len(s.allTasks),
+		Validated:      validated,
+		Retries:        s.metrics.retries,
+		Aborts:         snapshot.Aborts,
+		MaxIncarnation: s.maxIncarnation,
+		AbortReasons:   snapshot.AbortReasons,
+		TopConflicts:   topConflicts(snapshot.Conflicts, postmortemTopConflicts),
+		Config: FallbackConfig{
+			Workers:               s.metrics.workers,
+			MaxIterations:         s.maxIterations,
+			SequentialOnInterrupt: s.sequentialOnInterrupt,
+			TaskMemoryLimit:       s.taskMemoryLimit,
+			BlockMemoryBudget:     s.blockMemoryBudget,
+			DependencyPlanning:    s.dependencyPlanning,
+			ConflictPolicy:        fmt.Sprintf("%T", s.conflictPolicy),
+		},
+	}
+	if cause != nil {
+		postmortem.Cause = cause.Error()
+	}
+	s.metrics.postmortem = postmortem
+
+	ctx.Logger().Info("occ scheduler fell back to sequential execution",
+		"height", postmortem.Height,
+		"reason", reason.String(),
+		"cause", postmortem.Cause,
+		"txs", postmortem.Txs,
+		"validated", validated,
+		"retries", postmortem.Retries,
+		"aborts", postmortem.Aborts,
+		"max_incarnation", postmortem.MaxIncarnation,
+		"top_conflicts", postmortem.TopConflicts,
+		"workers", postmortem.Config.Workers,
+		"max_iterations", postmortem.Config.MaxIterations,
+	)
+	telemetry.IncrCounterWithLabels(
+		[]string{"scheduler", "fallbacks"},
+		1,
+		[]metrics.Label{telemetry.NewLabel("reason", reason.String())},
+	)
+}
+
+// topConflicts returns the n txs that the most distinct txs conflicted with, most conflicted first and by index
+// among equals
+func topConflicts(conflicts []ConflictPair, n int) []ConflictHotspot {
+	counts := make(map[int]int)
+	for _, pair := range conflicts {
+		counts[pair.Dependency]++
+	}
+	hotspots := make([]ConflictHotspot, 0, len(counts))
+	for index, count := range counts {
+		hotspots = append(hotspots, ConflictHotspot{Index: index, Conflicts: count})
+	}
+	sort.Slice(hotspots, func(i, j int) bool {
+		if hotspots[i].Conflicts != hotspots[j].Conflicts {
+			return hotspots[i].Conflicts > hotspots[j].Conflicts
+		}
+		return hotspots[i].Index < hotspots[j].Index
+	})
+	if len(hotspots) > n {
+		hotspots = hotspots[:n]
+	}
+	return hotspots
+}
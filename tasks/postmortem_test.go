@@ -0,0 +1,95 @@
+package tasks
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestFallbackReasonString(t *testing.T) {
+	require.Equal(t, "round_limit", FallbackRoundLimit.String())
+	require.Equal(t, "interrupt", FallbackInterrupt.String())
+	require.Equal(t, "rollback", FallbackRollback.String())
+	require.Equal(t, "task_timeout", FallbackTaskTimeout.String())
+	require.Equal(t, "tracking_limit", FallbackTrackingLimit.String())
+	require.Equal(t, "unknown", FallbackReason(42).String())
+}
+
+func TestTopConflicts(t *testing.T) {
+	conflicts := []ConflictPair{
+		{Index: 2, Dependency: 1},
+		{Index: 3, Dependency: 1},
+		{Index: 3, Dependency: 2},
+		{Index: 4, Dependency: 0},
+		{Index: 4, Dependency: 2},
+		{Index: 5, Dependency: 3},
+	}
+	require.Equal(t, []ConflictHotspot{{Index: 1, Conflicts: 2}, {Index: 2, Conflicts: 2}, {Index: 0, Conflicts: 1}}, topConflicts(conflicts, 3))
+	require.Empty(t, topConflicts(nil, 3))
+}
+
+func TestProcessAllFallbackPostmortem(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	var once sync.Once
+	estimateRead := make(chan struct{})
+	// every tx appends its index to the shared key. Tx 0 only writes the key once tx 1 has read its prefilled estimate,
+	// so the first round never validates every tx.
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(occ.Abort); !ok {
+					panic(r)
+				}
+				once.Do(func() { close(estimateRead) })
+				response = sdkerrors.ResponseDeliverTx(sdkerrors.ErrOCCAbort, 0, 0, false)
+			}
+		}()
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if ctx.TxIndex() == 0 {
+			<-estimateRead
+		}
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	reqs := requestList(10)
+	reqs[0].EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(itemKey): nil}}
+	s := NewScheduler(4, ti, deliverTx, WithMaxIterations(1))
+	_, err := s.ProcessAll(initTestCtx(true), reqs)
+	require.NoError(t, err)
+
+	metrics := s.Metrics()
+	require.True(t, metrics.Synchronous)
+	postmortem := metrics.Postmortem
+	require.NotNil(t, postmortem)
+	require.Equal(t, FallbackRoundLimit, postmortem.Reason)
+	require.Empty(t, postmortem.Cause)
+	require.Equal(t, 10, postmortem.Txs)
+	require.Less(t, postmortem.Validated, 10)
+	require.Positive(t, postmortem.Aborts)
+	require.NotEmpty(t, postmortem.TopConflicts)
+	require.Equal(t, FallbackConfig{
+		Workers:        4,
+		MaxIterations:  1,
+		ConflictPolicy: "tasks.WaitForDependenciesPolicy",
+	}, postmortem.Config)
+
+	// blocks that converge have no postmortem, and neither do blocks configured to be sequential from the start
+	_, err = s.ProcessAll(initTestCtx(true), requestList(10))
+	require.NoError(t, err)
+	require.Nil(t, s.Metrics().Postmortem)
+	s = NewScheduler(4, ti, deliverTx, WithMaxIterations(0))
+	_, err = s.ProcessAll(initTestCtx(true), requestList(10))
+	require.NoError(t, err)
+	require.True(t, s.Metrics().Synchronous)
+	require.Nil(t, s.Metrics().Postmortem)
+}
@@ -0,0 +1,39 @@
+package tasks
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllReadsetPrefilter(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx copies the key of the previous tx into its own, and every third tx also increments a shared counter,
+	// so that some readsets are written to by earlier txs and most aren't
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		previous := kv.Get([]byte(strconv.Itoa(ctx.TxIndex() - 1)))
+		kv.Set(req.Tx, append(previous, req.Tx...))
+		if ctx.TxIndex()%3 == 0 {
+			count, _ := strconv.Atoi(string(kv.Get(itemKey)))
+			kv.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		}
+		return types.ResponseDeliverTx{Data: previous}
+	}
+
+	prefilter := WithMultiVersionStoreOptions(func(sdk.StoreKey) []multiversion.StoreOption {
+		return []multiversion.StoreOption{multiversion.WithReadsetPrefilter()}
+	})
+	for i := 0; i < 5; i++ {
+		res, err := VerifySequential(initTestCtx(true), requestList(30), 10, ti, deliverTx, prefilter)
+		require.NoError(t, err)
+		require.Len(t, res, 30)
+	}
+}
@@ -0,0 +1,209 @@
+package tasks
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+// SuggestionKind is the kind of optimization a key prefix is a candidate for
+type SuggestionKind string
+
+const (
+	// SuggestionConflictExempt is a prefix that is read but never written within a block, so reads of it can't
+	// conflict with other txs in the block and may be exempted from validation
+	SuggestionConflictExempt SuggestionKind = "conflict_exempt"
+	// SuggestionDeferredWrite is a prefix that has at most one writer per block and is never read by a later tx in
+	// the same block, so its writes may be deferred to the end of the block
+	SuggestionDeferredWrite SuggestionKind = "deferred_write"
+)
+
+// PrefixStat is the access statistics of a key prefix in a store, across all observed blocks
+type PrefixStat struct {
+	Store  string `json:"store"`
+	Prefix []byte `json:"prefix"`
+	// Reads and Writes are the number of keys read and written under the prefix
+	Reads  uint64 `json:"reads"`
+	Writes uint64 `json:"writes"`
+	// BlocksRead and BlocksWritten are the number of blocks where the prefix was read or written
+	BlocksRead    int `json:"blocks_read"`
+	BlocksWritten int `json:"blocks_written"`
+	// MultiWriterBlocks is the number of blocks where more than one tx wrote the prefix
+	MultiWriterBlocks int `json:"multi_writer_blocks"`
+	// CrossTxReadBlocks is the number of blocks where a tx read the prefix after a lower-index tx wrote it
+	CrossTxReadBlocks int `json:"cross_tx_read_blocks"`
+}
+
+// PrefixSuggestion is a key prefix that is a candidate for an optimization, given the observed blocks
+type PrefixSuggestion struct {
+	Store  string         `json:"store"`
+	Prefix []byte         `json:"prefix"`
+	Kind   SuggestionKind `json:"kind"`
+	// Blocks is the number of blocks the suggestion is based on
+	Blocks int `json:"blocks"`
+}
+
+// PrefixStats collects per-prefix read and write statistics from the final readsets and writesets of every tx across
+// many blocks, to suggest prefixes that are safe conflict exemption or deferred write candidates. It's safe for
+// concurrent use, and meant to be shared by the schedulers of consecutive blocks.
+type PrefixStats struct {
+	mx        sync.Mutex
+	prefixLen int
+	blocks    int
+	prefixes  map[multiversion.QualifiedKey]*PrefixStat
+}
+
+// NewPrefixStats creates a collector that groups keys by their first prefixLen bytes
+func NewPrefixStats(prefixLen int) *PrefixStats {
+	return &PrefixStats{
+		prefixLen: prefixLen,
+		prefixes:  make(map[multiversion.QualifiedKey]*PrefixStat),
+	}
+}
+
+// WithPrefixStats sets a collector for the key prefix statistics of every block processed by the scheduler
+func WithPrefixStats(stats *PrefixStats) SchedulerOption {
+	return func(s *scheduler) { s.prefixStats = stats }
+}
+
+func (p *PrefixStats) prefixOf(key string) string {
+	if len(key) > p.prefixLen {
+		return key[:p.prefixLen]
+	}
+	return key
+}
+
+// blockAccess is the txs that accessed a prefix within a single block
+type blockAccess struct {
+	readers []int
+	writers []int
+}
+
+// recordBlock records the final readsets and writesets of all tasks in a block
+func (p *PrefixStats) recordBlock(tasks []*deliverTxTask) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.blocks++
+
+	block := make(map[multiversion.QualifiedKey]*blockAccess)
+	access := func(pk multiversion.QualifiedKey) *blockAccess {
+		if _, ok := block[pk]; !ok {
+			block[pk] = &blockAccess{}
+		}
+		return block[pk]
+	}
+	for _, task := range tasks {
+		for storeKey, vs := range task.VersionStores {
+			seenRead := make(map[string]struct{})
+			for key := range vs.GetReadset() {
+				pk := multiversion.QualifiedKey{Store: storeKey.Name(), Key: p.prefixOf(key)}
+				p.stat(pk).Reads++
+				if _, ok := seenRead[pk.Key]; !ok {
+					seenRead[pk.Key] = struct{}{}
+					access(pk).readers = append(access(pk).readers, task.Index)
+				}
+			}
+			seenWrite := make(map[string]struct{})
+			for key := range vs.GetWriteset() {
+				pk := multiversion.QualifiedKey{Store: storeKey.Name(), Key: p.prefixOf(key)}
+				p.stat(pk).Writes++
+				if _, ok := seenWrite[pk.Key]; !ok {
+					seenWrite[pk.Key] = struct{}{}
+					access(pk).writers = append(access(pk).writers, task.Index)
+				}
+			}
+		}
+	}
+
+	for pk, a := range block {
+		stat := p.stat(pk)
+		if len(a.readers) > 0 {
+			stat.BlocksRead++
+		}
+		if len(a.writers) > 0 {
+			stat.BlocksWritten++
+		}
+		if len(a.writers) > 1 {
+			stat.MultiWriterBlocks++
+		}
+		if hasCrossTxRead(a) {
+			stat.CrossTxReadBlocks++
+		}
+	}
+}
+
+// hasCrossTxRead returns true if a tx read the prefix after a lower-index tx wrote it
+func hasCrossTxRead(a *blockAccess) bool {
+	if len(a.writers) == 0 || len(a.readers) == 0 {
+		return false
+	}
+	firstWriter := a.writers[0]
+	for _, w := range a.writers {
+		if w < firstWriter {
+			firstWriter = w
+		}
+	}
+	for _, r := range a.readers {
+		if r > firstWriter {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PrefixStats) stat(pk multiversion.QualifiedKey) *PrefixStat {
+	stat, ok := p.prefixes[pk]
+	if !ok {
+		stat = &PrefixStat{Store: pk.Store, Prefix: []byte(pk.Key)}
+		p.prefixes[pk] = stat
+	}
+	return stat
+}
+
+// Blocks returns the number of observed blocks
+func (p *PrefixStats) Blocks() int {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	return p.blocks
+}
+
+// Stats returns the statistics of every observed prefix, sorted by store and prefix
+func (p *PrefixStats) Stats() []PrefixStat {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	stats := make([]PrefixStat, 0, len(p.prefixes))
+	for _, stat := range p.prefixes {
+		s := *stat
+		s.Prefix = append([]byte(nil), stat.Prefix...)
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Store != stats[j].Store {
+			return stats[i].Store < stats[j].Store
+		}
+		return bytes.Compare(stats[i].Prefix, stats[j].Prefix) < 0
+	})
+	return stats
+}
+
+// Suggestions returns the prefixes that are conflict exemption or deferred write candidates, sorted by store and
+// prefix. A prefix is only suggested once it's been accessed in at least minBlocks blocks, since it's only ever
+// evidence that the prefix has been safe so far.
+func (p *PrefixStats) Suggestions(minBlocks int) []PrefixSuggestion {
+	suggestions := []PrefixSuggestion{}
+	for _, stat := range p.Stats() {
+		switch {
+		case stat.Writes == 0 && stat.BlocksRead >= minBlocks:
+			suggestions = append(suggestions, PrefixSuggestion{
+				Store: stat.Store, Prefix: stat.Prefix, Kind: SuggestionConflictExempt, Blocks: stat.BlocksRead,
+			})
+		case stat.BlocksWritten >= minBlocks && stat.MultiWriterBlocks == 0 && stat.CrossTxReadBlocks == 0:
+			suggestions = append(suggestions, PrefixSuggestion{
+				Store: stat.Store, Prefix: stat.Prefix, Kind: SuggestionDeferredWrite, Blocks: stat.BlocksWritten,
+			})
+		}
+	}
+	return suggestions
+}
@@ -0,0 +1,57 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllWithPrefixStats(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx reads a config key under "b", writes its own key under "c" and reads the key of the previous tx,
+	// and only tx 0 writes under "a"
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		kv.Get([]byte("b-config"))
+		if ctx.TxIndex() > 0 {
+			kv.Get([]byte(fmt.Sprintf("c%d", ctx.TxIndex()-1)))
+		}
+		kv.Set([]byte(fmt.Sprintf("c%d", ctx.TxIndex())), []byte("value"))
+		if ctx.TxIndex() == 0 {
+			kv.Set([]byte("a-total"), []byte("value"))
+		}
+		return types.ResponseDeliverTx{}
+	}
+
+	stats := NewPrefixStats(1)
+	for block := 0; block < 3; block++ {
+		s := NewScheduler(5, ti, deliverTx, WithPrefixStats(stats))
+		_, err := s.ProcessAll(initTestCtx(true), requestList(10))
+		require.NoError(t, err)
+	}
+	require.Equal(t, 3, stats.Blocks())
+
+	byPrefix := make(map[string]PrefixStat)
+	for _, stat := range stats.Stats() {
+		require.Equal(t, testStoreKey.Name(), stat.Store)
+		byPrefix[string(stat.Prefix)] = stat
+	}
+	require.Equal(t, PrefixStat{Store: "mock", Prefix: []byte("a"), Writes: 3, BlocksWritten: 3}, byPrefix["a"])
+	require.Equal(t, PrefixStat{Store: "mock", Prefix: []byte("b"), Reads: 30, BlocksRead: 3}, byPrefix["b"])
+	require.Equal(t, uint64(27), byPrefix["c"].Reads)
+	require.Equal(t, uint64(30), byPrefix["c"].Writes)
+	require.Equal(t, 3, byPrefix["c"].MultiWriterBlocks)
+	require.Equal(t, 3, byPrefix["c"].CrossTxReadBlocks)
+
+	require.Equal(t, []PrefixSuggestion{
+		{Store: "mock", Prefix: []byte("a"), Kind: SuggestionDeferredWrite, Blocks: 3},
+		{Store: "mock", Prefix: []byte("b"), Kind: SuggestionConflictExempt, Blocks: 3},
+	}, stats.Suggestions(3))
+	require.Empty(t, stats.Suggestions(4))
+}
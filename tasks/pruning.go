@@ -0,0 +1,39 @@
+package tasks
+
+import (
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// WithVersionPruning bounds the memory held by the multiversion stores for keys written by many txs of a block. After
+// every validation round, the versions written by the leading validated txs are pruned down to the latest one of each
+// key, since they're final and later txs can't read the older ones (see multiversion.WithVersionPruning). Stores with
+// write listeners need every version to stream the writesets of the block, and spot checks re-validate final txs, so
+// pruning is left off for stores with write listeners and for every store if spot checks are enabled.
+func WithVersionPruning() SchedulerOption {
+	return func(s *scheduler) { s.versionPruning = true }
+}
+
+// prunesVersions returns whether the multiversion store of a store key prunes superseded versions
+func (s *scheduler) prunesVersions(storeKey sdk.StoreKey) bool {
+	return s.versionPruning && s.spotCheckRate <= 0 && len(s.writeListeners[storeKey]) == 0
+}
+
+// versionPruningOptions returns the multiversion store options enabling pruning for a store key, if it prunes
+func (s *scheduler) versionPruningOptions(storeKey sdk.StoreKey) []multiversion.StoreOption {
+	if !s.prunesVersions(storeKey) {
+		return nil
+	}
+	return []multiversion.StoreOption{multiversion.WithVersionPruning()}
+}
+
+// pruneVersions raises the prune index of the multiversion stores to the validated prefix of the last checkpoint,
+// which rollbacks never invalidate
+func (s *scheduler) pruneVersions() {
+	if !s.versionPruning || s.lastCheckpoint == nil {
+		return
+	}
+	for _, mv := range s.orderedStores {
+		mv.store.SetPruneIndex(s.lastCheckpoint.validated)
+	}
+}
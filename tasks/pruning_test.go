@@ -0,0 +1,66 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	store "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllVersionPruning(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the same hot key
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	for i := 0; i < 5; i++ {
+		res, err := VerifySequential(initTestCtx(true), requestList(50), 10, ti, deliverTx, WithVersionPruning())
+		require.NoError(t, err)
+		require.Len(t, res, 50)
+	}
+}
+
+func TestPruneVersions(t *testing.T) {
+	s := NewScheduler(1, nil, nil, WithVersionPruning()).(*scheduler)
+	s.initMultiVersionStore(initTestCtx(true))
+	mvs := s.multiVersionStores[testStoreKey]
+	for i := 0; i < 10; i++ {
+		mvs.SetWriteset(i, 0, multiversion.WriteSet{string(itemKey): []byte(fmt.Sprintf("%d", i))})
+	}
+
+	// nothing is pruned before the first checkpoint
+	s.pruneVersions()
+	mvs.SetWriteset(9, 1, multiversion.WriteSet{string(itemKey): []byte("9")})
+	require.Zero(t, mvs.PrunedVersions())
+
+	// the versions of the validated prefix are pruned down to its latest one
+	s.lastCheckpoint = &schedulerCheckpoint{validated: 6}
+	s.pruneVersions()
+	mvs.SetWriteset(9, 2, multiversion.WriteSet{string(itemKey): []byte("9")})
+	require.Equal(t, 5, mvs.PrunedVersions())
+	require.Equal(t, []byte("5"), mvs.GetLatestBeforeIndex(6, itemKey).Value())
+}
+
+func TestPrunesVersions(t *testing.T) {
+	require.False(t, NewScheduler(1, nil, nil).(*scheduler).prunesVersions(testStoreKey))
+	require.True(t, NewScheduler(1, nil, nil, WithVersionPruning()).(*scheduler).prunesVersions(testStoreKey))
+
+	// write listeners need the versions of every tx, and spot checks re-validate final txs
+	listeners := map[sdk.StoreKey][]store.WriteListener{testStoreKey: {nil}}
+	s := NewScheduler(1, nil, nil, WithVersionPruning(), WithWriteListeners(listeners)).(*scheduler)
+	require.False(t, s.prunesVersions(testStoreKey))
+	require.True(t, s.prunesVersions(sdk.NewKVStoreKey("other")))
+	s = NewScheduler(1, nil, nil, WithVersionPruning(), WithValidationSpotChecks(0.1, nil)).(*scheduler)
+	require.False(t, s.prunesVersions(testStoreKey))
+}
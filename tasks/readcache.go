@@ -0,0 +1,24 @@
+package tasks
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// WithParentReadCache has the executions of a block share the values they read from the parent stores, so that keys
+// read by many txs of the block but written by none, eg. params and hot balances, are read from the parent stores
+// once per block (see multiversion.WithParentReadCache). The hits, misses and evictions of the cache are reported in
+// SchedulerMetrics.
+func WithParentReadCache() SchedulerOption {
+	return func(s *scheduler) { s.parentReadCache = true }
+}
+
+// WithParentReadCacheCapacity enables the parent read cache (see WithParentReadCache), bounding the cache of each store
+// key to the bytes of keys and values returned by capacity, past which the least recently used keys are evicted (see
+// multiversion.WithParentReadCacheCapacity). Each store key is bounded on its own, so that a module reading huge values
+// doesn't evict the hot keys of the others. Store keys with a capacity of 0 or less are unbounded.
+func WithParentReadCacheCapacity(capacity func(storeKey sdk.StoreKey) int) SchedulerOption {
+	return func(s *scheduler) {
+		s.parentReadCache = true
+		s.parentReadCacheCapacity = capacity
+	}
+}
@@ -0,0 +1,92 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllParentReadCache(t *testing.T) {
+	ti := newTestTracingInfo()
+	paramKey := []byte("params")
+
+	// every tx reads a key no tx writes, and appends its index to the same hot key
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		params := string(kv.Get(paramKey))
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: params + ":" + newVal}
+	}
+	initCtx := func() sdk.Context {
+		ctx := initTestCtx(true)
+		ctx.MultiStore().GetKVStore(testStoreKey).Set(paramKey, []byte("p"))
+		return ctx
+	}
+
+	expected, err := NewSynchronousScheduler(ti, deliverTx).ProcessAll(initCtx(), requestList(50))
+	require.NoError(t, err)
+
+	s := NewScheduler(10, ti, deliverTx, WithParentReadCache())
+	res, err := s.ProcessAll(initCtx(), requestList(50))
+	require.NoError(t, err)
+	require.Equal(t, expected, res)
+	metrics := s.Metrics()
+	require.Positive(t, metrics.ParentCacheHits)
+	require.Positive(t, metrics.ParentCacheMisses)
+	require.Greater(t, metrics.ParentCacheHits, metrics.ParentCacheMisses)
+
+	// the cache is block-scoped
+	res, err = s.ProcessAll(initCtx(), requestList(50))
+	require.NoError(t, err)
+	require.Equal(t, expected, res)
+	require.Positive(t, s.Metrics().ParentCacheMisses)
+}
+
+func TestProcessAllParentReadCacheCapacity(t *testing.T) {
+	ti := newTestTracingInfo()
+	paramKey := []byte("params")
+
+	// every tx reads a key no tx writes and a key of its own, and appends its index to the same hot key
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		params := string(kv.Get(paramKey))
+		own := string(kv.Get([]byte(fmt.Sprintf("own-%d", ctx.TxIndex()))))
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: params + own + ":" + newVal}
+	}
+	initCtx := func() sdk.Context {
+		ctx := initTestCtx(true)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		kv.Set(paramKey, []byte("p"))
+		for i := 0; i < 50; i++ {
+			kv.Set([]byte(fmt.Sprintf("own-%d", i)), []byte("o"))
+		}
+		return ctx
+	}
+
+	expected, err := NewSynchronousScheduler(ti, deliverTx).ProcessAll(initCtx(), requestList(50))
+	require.NoError(t, err)
+
+	var capacities []string
+	s := NewScheduler(10, ti, deliverTx, WithParentReadCacheCapacity(func(storeKey sdk.StoreKey) int {
+		capacities = append(capacities, storeKey.Name())
+		// room for a handful of keys, so that the keys of each tx evict each other
+		return 64
+	}))
+	res, err := s.ProcessAll(initCtx(), requestList(50))
+	require.NoError(t, err)
+	require.Equal(t, expected, res)
+	require.Contains(t, capacities, testStoreKey.Name())
+	metrics := s.Metrics()
+	require.Positive(t, metrics.ParentCacheHits)
+	require.Positive(t, metrics.ParentCacheMisses)
+	require.Positive(t, metrics.ParentCacheEvictions)
+}
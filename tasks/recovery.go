@@ -0,0 +1,51 @@
+package tasks
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/tendermint/tendermint/abci/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// deliverTxWithRecovery runs deliverTx for a task, converting a panic into a failed response the same way the
+// recovery of sequential execution does, so that a panicking tx fails on its own rather than taking down its worker
+// and the node with it. OCC aborts are handled as usual. Otherwise, like under sequential execution, the writes of the
+// panicking tx are discarded, while its reads are kept so that the failure is still validated. Panics other than
+// exceeded OCC limits record their stack trace on the task's span.
+func (s *scheduler) deliverTxWithRecovery(span trace.Span, task *deliverTxTask) (resp types.ResponseDeliverTx) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		if abort, ok := occ.RecoveredAbort(r); ok {
+			// the version store already sent the abort, so the task is aborted as usual, and its writes become estimates
+			resp = sdkerrors.ResponseDeliverTx(sdkerrors.Wrapf(sdkerrors.ErrOCCAbort, "occ abort occurred with dependent index %d and error: %v", abort.DependentTxIdx, abort.Err), 0, 0, false)
+			return
+		}
+		var err error
+		switch recovered := r.(type) {
+		case occ.LimitExceeded:
+			err = sdkerrors.Wrap(sdkerrors.ErrOCCLimitExceeded, recovered.Error())
+		case occ.MemoryLimitExceeded:
+			err = sdkerrors.Wrap(sdkerrors.ErrOCCMemoryLimitExceeded, recovered.Error())
+		default:
+			stack := string(debug.Stack())
+			span.SetAttributes(
+				attribute.String("panic", fmt.Sprintf("%v", r)),
+				attribute.String("panicStack", stack),
+			)
+			telemetry.IncrCounter(1, "scheduler", "recovered_panics")
+			err = sdkerrors.Wrap(sdkerrors.ErrPanic, fmt.Sprintf("recovered: %v\nstack:\n%v", r, stack))
+		}
+		task.discardWrites()
+		resp = sdkerrors.ResponseDeliverTx(err, 0, 0, false)
+	}()
+	return s.deliverTxBody(task)
+}
@@ -0,0 +1,53 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+func TestProcessAllRecoversPanics(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the shared key, and tx 3 panics after its write
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		if ctx.TxIndex() == 3 {
+			panic("boom")
+		}
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	// on both the small block path and the worker pools
+	for _, txs := range []int{5, 20} {
+		t.Run(fmt.Sprintf("%d txs", txs), func(t *testing.T) {
+			s := NewScheduler(10, ti, deliverTx)
+			ctx := initTestCtx(true)
+			res, err := s.ProcessAll(ctx, requestList(txs))
+			require.NoError(t, err)
+			require.Len(t, res, txs)
+
+			// the panicking tx fails like it would under sequential execution, and its write is discarded
+			require.Equal(t, sdkerrors.ErrPanic.Codespace(), res[3].Codespace)
+			require.Equal(t, sdkerrors.ErrPanic.ABCICode(), res[3].Code)
+			expected := ""
+			for idx, response := range res {
+				if idx == 3 {
+					continue
+				}
+				expected = expected + fmt.Sprintf("%d", idx)
+				require.Equal(t, uint32(0), response.Code)
+				require.Equal(t, expected, response.Info)
+			}
+			require.Equal(t, expected, string(ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey)))
+		})
+	}
+}
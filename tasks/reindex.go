@@ -0,0 +1,49 @@
+package tasks
+
+import (
+	"sort"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// WithEventReindexing rewrites the events and logs of final responses so that they only depend on the final order of
+// txs, rather than on which incarnation of a tx produced them. The attributes of every event are sorted by key, like
+// WithEventOrdering does, and if the log is a JSON array of message logs (as built by the baseapp), the message logs
+// are renumbered by their position and the attributes of their events sorted by key, then the log is encoded again the
+// way the baseapp encodes it. A block processed in parallel then returns the same responses, byte for byte, as the
+// block processed sequentially with the same option. Logs that aren't message logs, eg. errors, are left as is.
+func WithEventReindexing() SchedulerOption {
+	return func(s *scheduler) { s.eventReindexing = true }
+}
+
+// reindexEvents rewrites the events and log of the final response of a task, if enabled
+func (s *scheduler) reindexEvents(t *deliverTxTask) {
+	if !s.eventReindexing {
+		return
+	}
+	t.Response.Events = normalizeEvents(t.Response.Events)
+	t.Response.Log = reindexLog(t.Response.Log)
+}
+
+// reindexLog returns the log with its message logs numbered by position and the attributes of their events sorted by
+// key, keeping the relative order of attributes with the same key, or the log itself if it isn't a JSON array of
+// message logs
+func reindexLog(log string) string {
+	logs, err := sdk.ParseABCILogs(log)
+	if err != nil || logs == nil {
+		return log
+	}
+	for i := range logs {
+		logs[i].MsgIndex = uint32(i)
+		for j, event := range logs[i].Events {
+			attributes := make([]sdk.Attribute, len(event.Attributes))
+			copy(attributes, event.Attributes)
+			sort.SliceStable(attributes, func(i, j int) bool {
+				return attributes[i].Key < attributes[j].Key
+			})
+			logs[i].Events[j] = sdk.StringEvent{Type: event.Type, Attributes: attributes}
+		}
+	}
+	return strings.TrimSpace(logs.String())
+}
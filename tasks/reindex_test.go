@@ -0,0 +1,71 @@
+package tasks
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestReindexLog(t *testing.T) {
+	log := `[{"msg_index":4,"events":[{"type":"transfer","attributes":[{"key":"recipient","value":"r1"},` +
+		`{"key":"amount","value":"a1"},{"key":"recipient","value":"r2"}]}]},{"msg_index":7,"log":"ok","events":[]}]`
+	require.Equal(t, `[{"events":[{"type":"transfer","attributes":[{"key":"amount","value":"a1"},`+
+		`{"key":"recipient","value":"r1"},{"key":"recipient","value":"r2"}]}]},{"msg_index":1,"log":"ok","events":[]}]`,
+		reindexLog(log))
+
+	// logs that aren't message logs are left as is
+	require.Equal(t, "", reindexLog(""))
+	require.Equal(t, "out of gas", reindexLog("out of gas"))
+	require.Equal(t, "null", reindexLog("null"))
+}
+
+func TestProcessAllEventReindexing(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// like mapOrderedDeliverTx, with a log of two messages numbered from the number of executions so far, so that its
+	// numbering differs between incarnations
+	var executions int64
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) types.ResponseDeliverTx {
+		response := mapOrderedDeliverTx(ctx, req)
+		first := uint32(atomic.AddInt64(&executions, 2))
+		event := sdk.Event(response.Events[0])
+		logs := sdk.ABCIMessageLogs{
+			sdk.NewABCIMessageLog(first, "", sdk.Events{event}),
+			sdk.NewABCIMessageLog(first+1, "", sdk.Events{event}),
+		}
+		response.Log = strings.TrimSpace(logs.String())
+		return response
+	}
+
+	const txs = 30
+	for _, opts := range [][]SchedulerOption{
+		{WithEventReindexing()},
+		{WithEventReindexing(), WithEventOrdering()},
+	} {
+		expected, err := NewSynchronousScheduler(ti, deliverTx, opts...).ProcessAll(initTestCtx(true), requestList(txs))
+		require.NoError(t, err)
+		res, err := NewScheduler(10, ti, deliverTx, opts...).ProcessAll(initTestCtx(true), requestList(txs))
+		require.NoError(t, err)
+		require.Len(t, res, txs)
+		for idx, response := range res {
+			// the golden responses are the ones of the sequential execution, byte for byte
+			bz, err := response.Marshal()
+			require.NoError(t, err)
+			expectedBz, err := expected[idx].Marshal()
+			require.NoError(t, err)
+			require.Equal(t, expectedBz, bz, "tx %d", idx)
+
+			logs, err := sdk.ParseABCILogs(response.Log)
+			require.NoError(t, err)
+			require.Len(t, logs, 2)
+			require.Equal(t, uint32(1), logs[1].MsgIndex)
+			require.Equal(t, strconv.Itoa(idx), logs[0].Events[0].Attributes[4].Value)
+		}
+	}
+}
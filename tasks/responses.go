@@ -0,0 +1,25 @@
+package tasks
+
+import (
+	"github.com/tendermint/tendermint/abci/types"
+)
+
+// ResponseProcessor normalizes or annotates the final response of a tx before it's returned by ProcessAll, eg. to
+// adjust gas reporting conventions or to report the OCC retries of the tx. Since the incarnations of txs depend on
+// timing, they must only be reflected in fields that aren't part of consensus (eg. Info or Log), unlike Code, Data,
+// GasWanted and GasUsed, which must stay deterministic.
+type ResponseProcessor func(info TaskInfo, res *types.ResponseDeliverTx)
+
+// WithResponseProcessor has the scheduler run every final response through the processor, in tx order, keeping such
+// post-processing out of deliverTx itself
+func WithResponseProcessor(processor ResponseProcessor) SchedulerOption {
+	return func(s *scheduler) { s.responseProcessor = processor }
+}
+
+// processResponse runs the final response of a task through the response processor, if any
+func (s *scheduler) processResponse(t *deliverTxTask) {
+	if s.responseProcessor == nil {
+		return
+	}
+	s.responseProcessor(taskInfo(t), t.Response)
+}
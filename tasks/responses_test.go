@@ -0,0 +1,50 @@
+package tasks
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllResponseProcessor(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx increments a shared counter, so that they conflict and get re-executed
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		count, _ := strconv.Atoi(string(kv.Get(itemKey)))
+		kv.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		return types.ResponseDeliverTx{Data: []byte(strconv.Itoa(count)), GasWanted: 100, GasUsed: 10}
+	}
+
+	var infos []TaskInfo
+	processor := func(info TaskInfo, res *types.ResponseDeliverTx) {
+		infos = append(infos, info)
+		// report the gas used as the gas wanted, and the retries of the tx
+		res.GasWanted = res.GasUsed
+		res.Info = strconv.Itoa(info.Incarnation)
+	}
+
+	const txs = 30
+	reqs := requestList(txs)
+	s := NewScheduler(10, ti, deliverTx, WithResponseProcessor(processor))
+	res, err := s.ProcessAll(initTestCtx(true), reqs)
+	require.NoError(t, err)
+
+	// every final response was processed once, in tx order
+	require.Len(t, infos, txs)
+	incarnations := s.Metrics().Incarnations
+	for idx, response := range res {
+		require.Equal(t, idx, infos[idx].Index)
+		require.Equal(t, reqs[idx].Request.Tx, infos[idx].Request.Tx)
+		require.Equal(t, incarnations[idx], infos[idx].Incarnation)
+		require.Equal(t, strconv.Itoa(idx), string(response.Data))
+		require.Equal(t, int64(10), response.GasWanted)
+		require.Equal(t, strconv.Itoa(incarnations[idx]), response.Info)
+	}
+}
@@ -0,0 +1,106 @@
+package tasks
+
+import (
+	"time"
+
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// BlockResult is the outcome of a block processed by ProcessAllWithResults
+type BlockResult struct {
+	Responses []types.ResponseDeliverTx
+	// Txs are the execution metadata of the txs of the block, in tx order
+	Txs []TxResult
+}
+
+// TxResult is the execution metadata of a tx of a block, which tells how much it contended with the txs before it,
+// eg. to price conflicts in a fee market or a priority mempool
+type TxResult struct {
+	// Index is the index of the tx in the block
+	Index int
+	// Incarnations is the number of times the tx was executed
+	Incarnations int
+	// ExecutionTime is the time spent executing the tx, summed over all of its incarnations
+	ExecutionTime time.Duration
+	// Aborts are the incarnations of the tx that were aborted or invalidated, in order
+	Aborts []TxAbort
+	// WritesetKeys is the number of keys written by the final incarnation of the tx, by store key name. Stores the
+	// tx didn't write to are omitted.
+	WritesetKeys map[string]int
+}
+
+// TxAbort is an incarnation of a tx that was aborted during its execution, or invalidated once it was executed
+type TxAbort struct {
+	Incarnation int
+	// Dependencies are the lower-index txs the incarnation conflicted with
+	Dependencies []int
+	// Abort is the abort that stopped the incarnation during its execution, or nil if it was invalidated
+	Abort *occ.Abort
+}
+
+// ProcessAllWithResults behaves like ProcessAll, and additionally returns the execution metadata of every tx of the
+// block. Tracking it costs a little bookkeeping per execution, so it's only done by ProcessAllWithResults.
+func (s *scheduler) ProcessAllWithResults(ctx sdk.Context, reqs []*sdk.DeliverTxEntry) (*BlockResult, error) {
+	s.results = &BlockResult{}
+	defer func() { s.results = nil }()
+	responses, err := s.ProcessAll(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+	results := s.results
+	results.Responses = responses
+	return results, nil
+}
+
+// recordResults records the execution metadata of the tasks of a block processed by ProcessAllWithResults
+func (s *scheduler) recordResults(tasks []*deliverTxTask) {
+	s.results.Txs = make([]TxResult, len(tasks))
+	for i, task := range tasks {
+		task.mx.RLock()
+		aborts := append([]TxAbort{}, task.AbortHistory...)
+		executionTime := task.ExecutionTime
+		task.mx.RUnlock()
+		writesetKeys := make(map[string]int, len(task.VersionStores))
+		for storeKey, vs := range task.VersionStores {
+			if writeset := vs.GetWriteset(); len(writeset) > 0 {
+				writesetKeys[storeKey.Name()] = len(writeset)
+			}
+		}
+		s.results.Txs[i] = TxResult{
+			Index:         task.Index,
+			Incarnations:  task.Incarnation + 1,
+			ExecutionTime: executionTime,
+			Aborts:        aborts,
+			WritesetKeys:  writesetKeys,
+		}
+	}
+}
+
+// recordTaskAbort appends an aborted or invalidated incarnation of task to its abort history, if the block's results
+// are recorded
+func (s *scheduler) recordTaskAbort(task *deliverTxTask, dependencies []int, abort *occ.Abort) {
+	if s.results == nil {
+		return
+	}
+	task.mx.Lock()
+	defer task.mx.Unlock()
+	task.AbortHistory = append(task.AbortHistory, TxAbort{
+		Incarnation:  task.Incarnation,
+		Dependencies: append([]int{}, dependencies...),
+		Abort:        abort,
+	})
+}
+
+// recordExecutionTime adds the time spent by an execution of task since start, if the block's results are recorded
+func (s *scheduler) recordExecutionTime(task *deliverTxTask, start time.Time) {
+	if s.results == nil {
+		return
+	}
+	elapsed := s.clock.Now().Sub(start)
+	task.mx.Lock()
+	defer task.mx.Unlock()
+	task.ExecutionTime += elapsed
+}
@@ -0,0 +1,68 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllWithResults(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the shared key, and records its own key, so that the txs conflict
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		time.Sleep(100 * time.Microsecond)
+		kv.Set(itemKey, []byte(val+fmt.Sprintf("%d,", ctx.TxIndex())))
+		kv.Set([]byte(fmt.Sprintf("tx-%d", ctx.TxIndex())), req.Tx)
+		return types.ResponseDeliverTx{Info: val}
+	}
+
+	const txs = 20
+	s := NewScheduler(10, ti, deliverTx)
+	ctx := initTestCtx(true)
+	result, err := s.ProcessAllWithResults(ctx, requestList(txs))
+	require.NoError(t, err)
+	require.Len(t, result.Responses, txs)
+	require.Len(t, result.Txs, txs)
+
+	metrics := s.Metrics()
+	expected := ""
+	aborts := 0
+	for idx, tx := range result.Txs {
+		require.Equal(t, expected, result.Responses[idx].Info)
+		expected += fmt.Sprintf("%d,", idx)
+
+		require.Equal(t, idx, tx.Index)
+		require.Equal(t, metrics.Incarnations[idx]+1, tx.Incarnations)
+		require.GreaterOrEqual(t, tx.ExecutionTime, time.Duration(tx.Incarnations)*100*time.Microsecond)
+		require.Equal(t, map[string]int{testStoreKey.Name(): 2}, tx.WritesetKeys)
+		// every aborted or invalidated incarnation conflicted with lower-index txs, and was executed again
+		for _, abort := range tx.Aborts {
+			require.Less(t, abort.Incarnation, tx.Incarnations-1)
+			require.NotEmpty(t, abort.Dependencies)
+			for _, dependency := range abort.Dependencies {
+				require.Less(t, dependency, idx)
+			}
+			if abort.Abort != nil {
+				require.Equal(t, []int{abort.Abort.DependentTxIdx}, abort.Dependencies)
+			}
+		}
+		aborts += len(tx.Aborts)
+	}
+	require.NotZero(t, aborts)
+	require.Equal(t, expected, string(ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey)))
+
+	// the results are only kept by ProcessAllWithResults
+	res, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	require.Equal(t, result.Responses, res)
+	require.Nil(t, s.(*scheduler).results)
+}
@@ -4,13 +4,19 @@ import (
 	"context"
 	"crypto/sha256"
 	"fmt"
+	"math/rand"
+	"runtime/pprof"
 	"sort"
+	"strconv"
 	"sync"
+	"time"
 
+	"github.com/cosmos/cosmos-sdk/internal/failpoint"
 	"github.com/cosmos/cosmos-sdk/store/multiversion"
 	store "github.com/cosmos/cosmos-sdk/store/types"
 	"github.com/cosmos/cosmos-sdk/telemetry"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
 	"github.com/cosmos/cosmos-sdk/types/occ"
 	"github.com/cosmos/cosmos-sdk/utils/tracing"
 	"github.com/tendermint/tendermint/abci/types"
@@ -18,25 +24,13 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
-type status string
-
 const (
-	// statusPending tasks are ready for execution
-	// all executing tasks are in pending state
-	statusPending status = "pending"
-	// statusExecuted tasks are ready for validation
-	// these tasks did not abort during execution
-	statusExecuted status = "executed"
-	// statusAborted means the task has been aborted
-	// these tasks transition to pending upon next execution
-	statusAborted status = "aborted"
-	// statusValidated means the task has been validated
-	// tasks in this status can be reset if an earlier task fails validation
-	statusValidated status = "validated"
-	// statusWaiting tasks are waiting for another tx to complete
-	statusWaiting status = "waiting"
-	// maximumIterations before we revert to sequential (for high conflict rates)
+	// maximumIterations is the default number of rounds before we revert to sequential (for high conflict rates)
 	maximumIterations = 10
+	// defaultPreAbortThreshold is the default number of new writeset keys a re-executed task needs to produce before
+	// later tasks that read those keys are eagerly aborted instead of waiting for validation to catch them, see
+	// WithPreAbortThreshold
+	defaultPreAbortThreshold = 10
 )
 
 type deliverTxTask struct {
@@ -44,43 +38,117 @@ type deliverTxTask struct {
 	AbortCh chan occ.Abort
 
 	mx            sync.RWMutex
-	Status        status
+	Status        status // only accessed atomically and transitioned via SetStatus and CompareAndSetStatus, see status.go
 	Dependencies  map[int]struct{}
-	Abort         *occ.Abort
+	Abort         *occ.Abort  // the abort of the latest execution with the lowest dependent index, if it aborted
+	Aborts        []occ.Abort // every abort of the latest execution, one per store, see collectAborts
 	Index         int
 	Incarnation   int
 	Request       types.RequestDeliverTx
 	Response      *types.ResponseDeliverTx
 	VersionStores map[sdk.StoreKey]*multiversion.VersionIndexedStore
+
+	// done is non-nil while the task is executing, and is closed once the execution's writes are visible
+	done chan struct{}
+
+	// NoWritesExpected is set for requests flagged as not expected to write, see sdk.DeliverTxEntry
+	NoWritesExpected bool
+	// EstimatedGas is the gas estimated for the request, if any
+	EstimatedGas uint64
+	// SequentialOnly is set for txs pinned to sequential execution, see WithSequentialOnly
+	SequentialOnly bool
+	// Duplicate is set for txs repeating the tx at DuplicateOf, which they're ordered behind, see WithDuplicateTxOrdering
+	Duplicate   bool
+	DuplicateOf int
+	// Telemetry buffers the metrics emitted by the handlers of the current incarnation
+	Telemetry *telemetry.Buffer
+	// MemoryMeter accounts the bytes held by the version stores of the current incarnation, if limited
+	MemoryMeter *multiversion.MemoryMeter
+	// TrackingMeter caps the entries recorded by the version stores of the current incarnation, if limited
+	TrackingMeter *multiversion.TrackingMeter
+	// Limiter bounds the store operations of the current incarnation, if limited, see WithTxLimiter
+	Limiter *limitRecorder
+	// IsolatedStores are the branches of the stores isolated by StoreStrategyPassthroughIsolated of the current
+	// incarnation
+	IsolatedStores map[sdk.StoreKey]store.CacheWrap
+	// Finalized is set once the response of the validated incarnation was finalized, see finalizeResponse
+	Finalized bool
+	// AbortSignal stops the current incarnation at its next store operation once signaled
+	AbortSignal *occ.AbortSignal
+	// ExecutionTime and AbortHistory accumulate over every incarnation of the task, if the block's results are
+	// recorded, see ProcessAllWithResults
+	ExecutionTime time.Duration
+	AbortHistory  []TxAbort
+	// LastExecution is the span of the latest execution of the task, of LastExecutionIncarnation, which the span of
+	// its next execution links to. It's only accessed by the executions of the task, which never overlap.
+	LastExecution            trace.SpanContext
+	LastExecutionIncarnation int
+	// Ante is the outcome of the pre-checks of the tx if they were run by the serial ante phase, see WithSerialAnte
+	Ante *anteResult
+	// stores is the store wiring of the latest incarnation, which the next incarnation reuses, see prepareTask
+	stores *taskStores
 }
 
-// AppendDependencies appends the given indexes to the task's dependencies
-func (dt *deliverTxTask) AppendDependencies(deps []int) {
+// taskStores is the store wiring of an incarnation of a task: its version indexed stores, and the cache multistore
+// they're set in, for the multiversion stores of the block as of epoch
+type taskStores struct {
+	epoch         int
+	multiStore    sdk.MultiStore
+	versionStores map[store.StoreKey]*multiversion.VersionIndexedStore
+}
+
+// startExecution marks the task as executing
+func (dt *deliverTxTask) startExecution() {
 	dt.mx.Lock()
 	defer dt.mx.Unlock()
-	for _, taskIdx := range deps {
-		dt.Dependencies[taskIdx] = struct{}{}
-	}
+	dt.done = make(chan struct{})
 }
 
-func (dt *deliverTxTask) IsStatus(s status) bool {
+// finishExecution marks the task as no longer executing, releasing any tasks waiting on it
+func (dt *deliverTxTask) finishExecution() {
+	dt.mx.Lock()
+	defer dt.mx.Unlock()
+	close(dt.done)
+	dt.done = nil
+}
+
+// executionDone returns a channel that is closed when the current execution finishes, or nil if not executing
+func (dt *deliverTxTask) executionDone() chan struct{} {
 	dt.mx.RLock()
 	defer dt.mx.RUnlock()
-	return dt.Status == s
+	return dt.done
 }
 
-func (dt *deliverTxTask) SetStatus(s status) {
+// AppendDependencies appends the given indexes to the task's dependencies
+func (dt *deliverTxTask) AppendDependencies(deps []int) {
 	dt.mx.Lock()
 	defer dt.mx.Unlock()
-	dt.Status = s
+	for _, taskIdx := range deps {
+		dt.Dependencies[taskIdx] = struct{}{}
+	}
 }
 
 func (dt *deliverTxTask) Reset() {
 	dt.SetStatus(statusPending)
 	dt.Response = nil
 	dt.Abort = nil
+	dt.Aborts = nil
 	dt.AbortCh = nil
 	dt.VersionStores = nil
+	dt.MemoryMeter = nil
+	dt.TrackingMeter = nil
+	dt.Limiter = nil
+	dt.IsolatedStores = nil
+	dt.Finalized = false
+	dt.AbortSignal = nil
+}
+
+// discardWrites drops the writes of the current incarnation, while keeping its reads so that they're still validated
+func (dt *deliverTxTask) discardWrites() {
+	for _, vs := range dt.VersionStores {
+		vs.DiscardWrites()
+	}
+	dt.IsolatedStores = nil
 }
 
 func (dt *deliverTxTask) Increment() {
@@ -89,77 +157,376 @@ func (dt *deliverTxTask) Increment() {
 
 // Scheduler processes tasks concurrently
 type Scheduler interface {
+	// ProcessAll processes all of the requests of a single block. Block-scoped state (multiversion stores,
+	// tasks, work channels) is created at the start of each invocation and released at the end, so none of it
+	// survives across ProcessAll invocations and a scheduler may be reused for back-to-back blocks.
+	// Processing is interrupted once ctx.Context() is done, see ErrInterrupted. Internal inconsistencies are
+	// returned as a *SchedulerError, see ErrEstimateAtCommit, ErrMissingResponse, ErrValidationLivelock and
+	// ErrStoreInconsistency.
 	ProcessAll(ctx sdk.Context, reqs []*sdk.DeliverTxEntry) ([]types.ResponseDeliverTx, error)
+	// Metrics returns the OCC statistics of the most recently processed block
+	Metrics() SchedulerMetrics
+	// SetWorkers changes the number of workers executing txs, from the next block
+	SetWorkers(workers int)
+	// WritesetHash returns the hash of the final writesets of the most recently processed block, if enabled
+	WritesetHash() []byte
+	// SimulateBlock processes a prospective block without writing its state, see BlockSimulation
+	SimulateBlock(ctx sdk.Context, reqs []*sdk.DeliverTxEntry) (*BlockSimulation, error)
+	// ProcessAllStream behaves like ProcessAll, also streaming the final responses of the block as they're known
+	ProcessAllStream(ctx sdk.Context, reqs []*sdk.DeliverTxEntry, out chan<- StreamedResponse) ([]types.ResponseDeliverTx, error)
+	// ProcessAllWithResults behaves like ProcessAll, also returning the execution metadata of every tx, see BlockResult
+	ProcessAllWithResults(ctx sdk.Context, reqs []*sdk.DeliverTxEntry) (*BlockResult, error)
+	// EstimateTxs dry runs txs in isolation against ctx's multistore, see TxEstimate
+	EstimateTxs(ctx sdk.Context, reqs []*sdk.DeliverTxEntry) []TxEstimate
 }
 
 type scheduler struct {
 	deliverTx          func(ctx sdk.Context, req types.RequestDeliverTx) (res types.ResponseDeliverTx)
-	workers            int
+	workers            int64 // only accessed atomically, so that it can be changed with SetWorkers at any time
 	multiVersionStores map[sdk.StoreKey]multiversion.MultiVersionStore
+	orderedStores      []keyedMultiVersionStore // multiVersionStores frozen in store key name order, used for all iteration
+	storesEpoch        int                      // incremented whenever the multiversion stores are initialized, see taskStores
+	unversioned        []unversionedStore       // stores of the block not wrapped in multiversion stores, in store key name order
 	tracingInfo        *tracing.Info
 	allTasks           []*deliverTxTask
-	executeCh          chan func()
-	validateCh         chan func()
+	wakeups            *wakeups // which waiting tasks may be ready to run again
+	executeDispatcher  Dispatcher
+	validateDispatcher Dispatcher
 	metrics            *schedulerMetrics
 	synchronous        bool // true if maxIncarnation exceeds threshold
 	maxIncarnation     int  // current highest incarnation
+	clock              Clock
+	lastCheckpoint     *schedulerCheckpoint
+	flushListener      multiversion.FlushListener
+	accessLog          *multiversion.AccessLog
+	storeMetadata      func(storeKey sdk.StoreKey) map[string][]byte
+	mvsOptions         func(storeKey sdk.StoreKey) []multiversion.StoreOption
+	maxIterations      int // rounds before falling back to sequential execution
+	preAbortThreshold  int // new writeset keys of a re-execution that pre-abort its readers, see WithPreAbortThreshold
+	newLimiter         func() multiversion.Limiter
+	writeListeners     map[sdk.StoreKey][]store.WriteListener
+	conflictPolicy     ConflictPolicy
+	debugDumpDir       string
+	writesetDecoders   *sdk.WritesetDecoderRegistry
+	inspector          *Inspector
+	prefixStats        *PrefixStats
+	hotKeyReport       int // number of hot keys reported per block, or 0 if they aren't
+	happyPath          bool
+	// validation rounds check the tasks most likely to be invalid first
+	dirtyFirstValidation bool
+	workerTuner          *WorkerTuner
+	// the order execution rounds are dispatched in, see WithDispatchOrder, and its state for the block: the planned
+	// wave of every tx, or the source of the shuffles
+	dispatchOrder DispatchOrder
+	dispatchSeed  int64
+	dispatchRanks []int
+	dispatchRand  *rand.Rand
+
+	// blocks with fewer txs run on the small block path
+	smallBlockThreshold int
+
+	// whether the first round is dispatched in waves planned from the estimates, and how the waves are planned if not
+	// with planWaves
+	dependencyPlanning bool
+	planner            func(reqs []*sdk.DeliverTxEntry) [][]int
+
+	// whether the declared writesets of the requests are enforced by deliverTx, see WithStrictWritesets
+	strictWritesets bool
+
+	// bytes the readsets and writesets of a tx's version stores may hold, if positive
+	taskMemoryLimit int
+
+	// how the stores of each type are isolated between txs, and the strategies overriding them by store key name
+	storeStrategies    StoreStrategies
+	storeKeyStrategies map[string]StoreStrategy
+	// guards the writes of the branches of synchronized stores, and the number of txs whose branches were written
+	synchronizedMx      sync.Mutex
+	synchronizedFlushed int
+
+	// whether superseded versions written by final txs are pruned from the multiversion stores
+	versionPruning bool
+	// whether the writes of final txs are committed to the parent stores as the validated prefix grows, and the number
+	// of leading txs of the block that were, see WithIncrementalCommit
+	incrementalCommit bool
+	committed         int
+	// whether the version indexed stores of a block share the values they read from the parent stores
+	parentReadCache bool
+	// the capacity of the parent read cache of each store key, unbounded if nil, see WithParentReadCacheCapacity
+	parentReadCacheCapacity func(storeKey sdk.StoreKey) int
+
+	// how a block whose parent stores changed while it was processed is handled
+	parentMutationPolicy ParentMutationPolicy
+
+	// writesets of txs carried over from earlier blocks, if enabled
+	estimateCache *EstimateCache
+
+	// writesets learned by the identifiers of txs over earlier blocks, if enabled, see WithWritesetCache
+	writesetCache *WritesetCache
+	identifyTx    TxIdentifierFunc
+
+	// which txs are pinned to sequential execution, see WithSequentialOnly, and the indexes of those of the block
+	isSequentialOnly SequentialOnlyFunc
+	sequentialOnly   []int
+	// whether every block executes synchronously from the start, see NewSynchronousScheduler
+	alwaysSynchronous bool
+	// whether txs repeating an earlier tx of the block are ordered behind it, see WithDuplicateTxOrdering, and the hashes
+	// of the txs of the block, with the index of their first occurrence
+	orderDuplicates bool
+	seenTxs         map[[sha256.Size]byte]int
+	// the configured lookahead window and its tuner, see WithLookahead, and for the block: its window, the tasks
+	// deferred beyond it, the first unvalidated index of the previous round, and the rounds exempt from the round limit
+	lookahead       int
+	lookaheadTuner  *LookaheadTuner
+	lookaheadWindow int
+	deferred        map[int]struct{}
+	lookaheadBase   int
+	lookaheadRounds int
+	// the first tx of the latest sequential round, and the consecutive sequential rounds that started there, see
+	// checkLivelock
+	syncStart  int
+	syncStalls int
+	// the rounds the validated frontier may stall for and what's done once it did, see WithLivelockDetection, where
+	// diagnostics are written, and for the block: the frontier and the consecutive concurrent rounds it stalled for
+	livelockRounds int
+	livelockAction LivelockAction
+	livelockDir    string
+	frontier       int
+	frontierStalls int
+
+	// how long an execution may take before it's abandoned, see WithTaskTimeout, whether an execution of the block
+	// timed out, and the abandoned executions of any block that are still running (only accessed atomically)
+	taskTimeout         time.Duration
+	timedOut            int32
+	abandonedExecutions int64
+	// the caps on the entries recorded for validation by the version stores of a tx, see WithTrackingLimits, and
+	// whether an execution of the block exceeded them (only accessed atomically)
+	maxReadset         int
+	maxWriteset        int
+	trackingOverflowed int32
+	// whether an execution of the block exceeded the limits of its limiter, see WithTxLimiter (only accessed
+	// atomically)
+	taskLimitExceeded int32
+	// the bytes the multiversion stores of a block may hold, see WithBlockMemoryBudget, and whether the block exceeded
+	// them (only accessed atomically)
+	blockMemoryBudget    int64
+	memoryBudgetExceeded int32
+	// whether an execution of the block exceeded the memory limit of its version stores, see WithTaskMemoryLimit (only
+	// accessed atomically)
+	taskMemoryExceeded int32
+
+	// module invariants asserted once the writes of the block are flushed, if set
+	invariantChecks *InvariantChecks
+
+	// whether txs may access their version indexed stores from multiple goroutines
+	concurrentStoreAccess bool
+
+	// whether the multiversion stores only emit telemetry once per block
+	batchedTelemetry bool
+
+	// probability of spot checking each validated tx at the end of the block, and where discrepancies are reported
+	spotCheckRate          float64
+	onSpotCheckDiscrepancy func(height int64, discrepancy SpotCheckDiscrepancy)
+
+	// outcome of the block being simulated by SimulateBlock, if any
+	simulation *BlockSimulation
+	// execution metadata of the block being processed by ProcessAllWithResults, if any
+	results *BlockResult
+
+	// long-lived pool the workers of every block are borrowed from, if set
+	workerPool *WorkerPool
+
+	// creates the dispatchers handing the work of each phase to its workers
+	newDispatcher NewDispatcherFunc
+
+	// post-processes the final responses of every block, if set
+	responseProcessor ResponseProcessor
+	// receives the final writes of every block, if set
+	changeSetExporter ChangeSetExporter
+	// pre-checks of the txs run serially before the block executes in parallel, if any, see WithSerialAnte
+	ante AnteFunc
+
+	// notified of what the scheduler does while processing blocks
+	hooks []SchedulerHooks
+
+	// whether the events of final responses are stamped with their tx index and normalized
+	eventOrdering bool
+	// whether the events and logs of final responses are rewritten to only depend on the final order of txs
+	eventReindexing bool
+
+	// span of the current round of the block, see startRoundSpan
+	roundSpan trace.Span
+
+	// context bounding the block being processed, and whether to fall back to sequential execution once it's done
+	blockCtx              context.Context
+	sequentialOnInterrupt bool
+
+	// how many multiversion stores are flushed to their parents concurrently, unbounded if not positive
+	flushConcurrency int
+
+	// hash of the final writesets of the most recent block, if enabled
+	writesetHashing bool
+	writesetHash    []byte
+
+	// multiversion stores of the previous block, reset and kept by store key name for reuse
+	recycledStores map[string]multiversion.MultiVersionStore
+
+	// where the final responses of the block are streamed to, and how many leading ones were, see ProcessAllStream
+	stream   chan<- StreamedResponse
+	streamed int
+
+	// tx hashes of the block's requests in order, if responses are audited
+	responseAudit bool
+	auditHashes   [][sha256.Size]byte
+
+	// tasks appended to the in-flight block, if enabled
+	appendEnabled    bool
+	appendMx         sync.Mutex
+	acceptingAppends bool
+	blockTxs         int
+	appendQueue      []*sdk.DeliverTxEntry
+
+	// faults injected into the pipeline, only ever set by tests
+	faults *faultInjector
+}
+
+// keyedMultiVersionStore is a multiversion store along with the store key it belongs to
+type keyedMultiVersionStore struct {
+	key   sdk.StoreKey
+	store multiversion.MultiVersionStore
+}
+
+// SchedulerOption configures optional scheduler behavior
+type SchedulerOption func(*scheduler)
+
+// WithClock sets the clock used for all timing decisions in the scheduler
+func WithClock(clock Clock) SchedulerOption {
+	return func(s *scheduler) { s.clock = clock }
+}
+
+// WithFlushListener sets a listener that is notified of every writeset flush into the multiversion stores
+func WithFlushListener(listener multiversion.FlushListener) SchedulerOption {
+	return func(s *scheduler) { s.flushListener = listener }
+}
+
+// WithAccessLog has every access of the txs to the multiversion stores appended to the log, which can be inspected
+// once the block is processed, see multiversion.AccessLog. The log isn't cleared between blocks.
+func WithAccessLog(log *multiversion.AccessLog) SchedulerOption {
+	return func(s *scheduler) { s.accessLog = log }
+}
+
+// WithStoreMetadata sets a function returning the metadata of a store (eg. its latest version or commit info), by
+// name, which is called for every store at the start of each block. Txs read the metadata captured for the block with
+// multiversion.ReadMetadata, rather than live, so that every incarnation observes the same metadata.
+func WithStoreMetadata(storeMetadata func(storeKey sdk.StoreKey) map[string][]byte) SchedulerOption {
+	return func(s *scheduler) { s.storeMetadata = storeMetadata }
+}
+
+// WithMultiVersionStoreOptions sets a function returning the options used for each block's multiversion store of a
+// given store key, eg. multiversion.WithReadsetSpill for replay tooling. They aren't applied to transient and memory
+// stores, see isEphemeralStore.
+func WithMultiVersionStoreOptions(mvsOptions func(storeKey sdk.StoreKey) []multiversion.StoreOption) SchedulerOption {
+	return func(s *scheduler) { s.mvsOptions = mvsOptions }
+}
+
+// WithMaxIterations sets the number of optimistic execute/validate rounds after which the scheduler falls back to
+// executing the remaining non-validated txs sequentially, bounding the work spent on highly conflicting blocks.
+// Non-positive values fall back to sequential execution right away.
+func WithMaxIterations(maxIterations int) SchedulerOption {
+	return func(s *scheduler) { s.maxIterations = maxIterations }
+}
+
+// WithPreAbortThreshold sets the number of new writeset keys a re-executed tx needs to produce before the later txs
+// that read those keys are sent back to pending right away, rather than finishing executions that are doomed to fail
+// validation. Defaults to 10; non-positive values disable pre-aborting.
+func WithPreAbortThreshold(newKeys int) SchedulerOption {
+	return func(s *scheduler) { s.preAbortThreshold = newKeys }
+}
+
+// WithConcurrentStoreAccess makes the version indexed stores of every tx safe to use from multiple goroutines, for
+// chains whose handlers or hooks read and write state concurrently while executing a tx. It costs a lock per store
+// operation, so it should only be enabled if needed.
+func WithConcurrentStoreAccess() SchedulerOption {
+	return func(s *scheduler) { s.concurrentStoreAccess = true }
+}
+
+// WithBatchedTelemetry keeps telemetry off of the hot paths of the multiversion stores (eg. validation), which then
+// aggregate it locally and emit it once per block, see multiversion.WithBatchedTelemetry
+func WithBatchedTelemetry() SchedulerOption {
+	return func(s *scheduler) { s.batchedTelemetry = true }
+}
+
+// WithWriteListeners sets listeners that are streamed the final writes of every tx, per store key, in tx index order
+// when the multiversion stores are flushed at the end of a block
+func WithWriteListeners(listeners map[sdk.StoreKey][]store.WriteListener) SchedulerOption {
+	return func(s *scheduler) { s.writeListeners = listeners }
+}
+
+// WithConflictPolicy sets the policy deciding what to do with tasks that fail validation
+func WithConflictPolicy(policy ConflictPolicy) SchedulerOption {
+	return func(s *scheduler) { s.conflictPolicy = policy }
 }
 
 // NewScheduler creates a new scheduler
-func NewScheduler(workers int, tracingInfo *tracing.Info, deliverTxFunc func(ctx sdk.Context, req types.RequestDeliverTx) (res types.ResponseDeliverTx)) Scheduler {
-	return &scheduler{
-		workers:     workers,
-		deliverTx:   deliverTxFunc,
-		tracingInfo: tracingInfo,
-		metrics:     &schedulerMetrics{},
+func NewScheduler(workers int, tracingInfo *tracing.Info, deliverTxFunc func(ctx sdk.Context, req types.RequestDeliverTx) (res types.ResponseDeliverTx), opts ...SchedulerOption) Scheduler {
+	s := &scheduler{
+		workers:           int64(workers),
+		deliverTx:         deliverTxFunc,
+		tracingInfo:       tracingInfo,
+		metrics:           &schedulerMetrics{},
+		clock:             realClock{},
+		maxIterations:     maximumIterations,
+		preAbortThreshold: defaultPreAbortThreshold,
+		conflictPolicy:    WaitForDependenciesPolicy{},
+		newDispatcher:     NewChannelDispatcher,
+
+		smallBlockThreshold: defaultSmallBlockThreshold,
+		storeStrategies:     DefaultStoreStrategies(),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *scheduler) invalidateTask(task *deliverTxTask) {
-	for _, mv := range s.multiVersionStores {
-		mv.InvalidateWriteset(task.Index, task.Incarnation)
-		mv.ClearReadset(task.Index)
-		mv.ClearIterateset(task.Index)
-	}
-}
-
-func start(ctx context.Context, ch chan func(), workers int) {
-	for i := 0; i < workers; i++ {
-		go func() {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case work := <-ch:
-					work()
-				}
-			}
-		}()
+	for _, mv := range s.orderedStores {
+		mv.store.InvalidateWriteset(task.Index, task.Incarnation)
+		mv.store.ClearReadset(task.Index)
+		mv.store.ClearIterateset(task.Index)
 	}
 }
 
-func (s *scheduler) DoValidate(work func()) {
+// withTaskLabels runs fn with pprof labels identifying the scheduler phase and the task being processed,
+// so that profiles attribute time to specific transactions rather than anonymous worker closures
+func withTaskLabels(ctx context.Context, phase string, task *deliverTxTask, fn func()) {
+	labels := pprof.Labels("occ_phase", phase, "tx_index", strconv.Itoa(task.Index), "tx_incarnation", occ.IncarnationString(task.Incarnation))
+	pprof.Do(ctx, labels, func(context.Context) {
+		fn()
+	})
+}
+
+func (s *scheduler) DoValidate(work func(context.Context)) {
 	if s.synchronous {
-		work()
+		work(context.Background())
 		return
 	}
-	s.validateCh <- work
+	s.validateDispatcher.Dispatch(work)
 }
 
-func (s *scheduler) DoExecute(work func()) {
+func (s *scheduler) DoExecute(work func(context.Context)) {
 	if s.synchronous {
-		work()
+		work(context.Background())
 		return
 	}
-	s.executeCh <- work
+	s.executeDispatcher.Dispatch(work)
 }
 
 func (s *scheduler) findConflicts(task *deliverTxTask) (bool, []int) {
 	var conflicts []int
 	uniq := make(map[int]struct{})
 	valid := true
-	for _, mv := range s.multiVersionStores {
-		ok, mvConflicts := mv.ValidateTransactionState(task.Index)
+	for _, mv := range s.orderedStores {
+		ok, mvConflicts := mv.store.ValidateTransactionState(task.Index)
 		for _, c := range mvConflicts {
 			if _, ok := uniq[c]; !ok {
 				conflicts = append(conflicts, c)
@@ -177,10 +544,12 @@ func toTasks(reqs []*sdk.DeliverTxEntry) []*deliverTxTask {
 	res := make([]*deliverTxTask, 0, len(reqs))
 	for idx, r := range reqs {
 		res = append(res, &deliverTxTask{
-			Request:      r.Request,
-			Index:        idx,
-			Dependencies: map[int]struct{}{},
-			Status:       statusPending,
+			Request:          r.Request,
+			Index:            idx,
+			Dependencies:     map[int]struct{}{},
+			Status:           statusPending,
+			NoWritesExpected: r.NoWritesExpected,
+			EstimatedGas:     r.EstimatedGas,
 		})
 	}
 	return res
@@ -194,16 +563,42 @@ func (s *scheduler) collectResponses(tasks []*deliverTxTask) []types.ResponseDel
 	return res
 }
 
-func (s *scheduler) tryInitMultiVersionStore(ctx sdk.Context) {
+func (s *scheduler) initMultiVersionStore(ctx sdk.Context) {
 	if s.multiVersionStores != nil {
-		return
+		panic("multiversion stores must not survive across ProcessAll invocations")
 	}
 	mvs := make(map[sdk.StoreKey]multiversion.MultiVersionStore)
 	keys := ctx.MultiStore().StoreKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name() < keys[j].Name() })
+	ordered := make([]keyedMultiVersionStore, 0, len(keys))
+	var unversioned []unversionedStore
 	for _, sk := range keys {
-		mvs[sk] = multiversion.NewMultiVersionStore(ctx.MultiStore().GetKVStore(sk))
+		if strategy := s.storeStrategy(sk, ctx.MultiStore().GetStore(sk).GetStoreType()); strategy != StoreStrategyMultiVersion {
+			unversioned = append(unversioned, unversionedStore{
+				key:      sk,
+				store:    ctx.MultiStore().GetStore(sk).(store.CacheWrap),
+				strategy: strategy,
+			})
+			continue
+		}
+		opts := s.multiVersionStoreOptions(sk)
+		if recycled, ok := s.recycledStores[sk.Name()]; ok {
+			recycled.Reset(ctx.MultiStore().GetKVStore(sk), opts...)
+			mvs[sk] = recycled
+			delete(s.recycledStores, sk.Name())
+		} else {
+			mvs[sk] = multiversion.NewMultiVersionStore(ctx.MultiStore().GetKVStore(sk), opts...)
+		}
+		if s.flushListener != nil {
+			mvs[sk].SetFlushListener(sk.Name(), s.flushListener)
+		}
+		ordered = append(ordered, keyedMultiVersionStore{key: sk, store: mvs[sk]})
 	}
 	s.multiVersionStores = mvs
+	s.orderedStores = ordered
+	s.unversioned = unversioned
+	s.storesEpoch++
+	s.inspector.setStores(ctx.BlockHeight(), ordered)
 }
 
 func dependenciesValidated(tasks []*deliverTxTask, deps map[int]struct{}) bool {
@@ -235,140 +630,435 @@ func allValidated(tasks []*deliverTxTask) bool {
 }
 
 func (s *scheduler) PrefillEstimates(reqs []*sdk.DeliverTxEntry) {
+	s.prefillEstimatesAt(0, reqs)
+}
+
+// prefillEstimatesAt prefills the estimated writesets of requests whose tx indices start at startIdx
+func (s *scheduler) prefillEstimatesAt(startIdx int, reqs []*sdk.DeliverTxEntry) {
 	// iterate over TXs, update estimated writesets where applicable
 	for i, req := range reqs {
 		mappedWritesets := req.EstimatedWritesets
 		// order shouldnt matter for storeKeys because each storeKey partitioned MVS is independent
 		for storeKey, writeset := range mappedWritesets {
-			// we use `-1` to indicate a prefill incarnation
-			s.multiVersionStores[storeKey].SetEstimatedWriteset(i, -1, writeset)
+			// writes to stores that aren't multiversion stores can't be estimated
+			if mvs, ok := s.multiVersionStores[storeKey]; ok {
+				mvs.SetEstimatedWriteset(startIdx+i, occ.PrefillIncarnation, writeset)
+			}
 		}
 	}
 }
 
-// schedulerMetrics contains metrics for the scheduler
-type schedulerMetrics struct {
-	// maxIncarnation is the highest incarnation seen in this set
-	maxIncarnation int
-	// retries is the number of tx attempts beyond the first attempt
-	retries int
+// recycleMultiVersionStores resets the multiversion stores of the block and keeps them around to be reused by the
+// next initMultiVersionStore, rather than allocating new ones
+func (s *scheduler) recycleMultiVersionStores() {
+	if s.recycledStores == nil {
+		s.recycledStores = make(map[string]multiversion.MultiVersionStore, len(s.orderedStores))
+	}
+	s.inspector.clear()
+	for _, mv := range s.orderedStores {
+		mv.store.Reset(nil)
+		s.recycledStores[mv.key.Name()] = mv.store
+	}
+	s.multiVersionStores = nil
+	s.orderedStores = nil
 }
 
-func (s *scheduler) emitMetrics() {
-	telemetry.IncrCounter(float32(s.metrics.retries), "scheduler", "retries")
-	telemetry.IncrCounter(float32(s.metrics.maxIncarnation), "scheduler", "incarnations")
+// resetBlockState releases all block-scoped state so that nothing leaks into the next ProcessAll invocation.
+// The multiversion stores are reset and recycled for the next block. The metrics and max incarnation are kept around
+// until the next block for inspection.
+func (s *scheduler) resetBlockState() {
+	s.recycleMultiVersionStores()
+	s.unversioned = nil
+	s.synchronizedFlushed = 0
+	s.sequentialOnly = nil
+	s.seenTxs = nil
+	s.lookaheadWindow = 0
+	s.deferred = nil
+	s.lookaheadBase = 0
+	s.lookaheadRounds = 0
+	s.syncStart = 0
+	s.syncStalls = 0
+	s.frontier = 0
+	s.frontierStalls = 0
+	s.committed = 0
+	s.dispatchRanks = nil
+	s.dispatchRand = nil
+	s.allTasks = nil
+	s.wakeups = nil
+	s.executeDispatcher = nil
+	s.validateDispatcher = nil
+	s.synchronous = false
+	s.timedOut = 0
+	s.trackingOverflowed = 0
+	s.taskLimitExceeded = 0
+	s.memoryBudgetExceeded = 0
+	s.taskMemoryExceeded = 0
+	s.lastCheckpoint = nil
+	s.stream = nil
+	s.streamed = 0
+	s.auditHashes = nil
+	s.blockCtx = nil
+	s.appendMx.Lock()
+	s.acceptingAppends = false
+	s.appendQueue = nil
+	s.appendMx.Unlock()
 }
 
 func (s *scheduler) ProcessAll(ctx sdk.Context, reqs []*sdk.DeliverTxEntry) ([]types.ResponseDeliverTx, error) {
 	var iterations int
-	// initialize mutli-version stores if they haven't been initialized yet
-	s.tryInitMultiVersionStore(ctx)
+	startTime := s.clock.Now()
+	// block-scoped state is always released, even if processing fails
+	defer s.resetBlockState()
+	s.metrics = &schedulerMetrics{hotKeys: s.newHotKeys()}
+	s.maxIncarnation = 0
+	s.writesetHash = nil
+	s.blockCtx = ctx.Context()
+	s.synchronous = s.alwaysSynchronous
+	ctx, blockSpan := s.startBlockSpan(ctx, reqs)
+	defer blockSpan.End()
+	defer s.endRoundSpan()
+	// initialize mutli-version stores for this block
+	s.initMultiVersionStore(ctx)
 	// prefill estimates
 	s.PrefillEstimates(reqs)
+	carried := s.prefillCarriedEstimates(ctx, reqs)
+	s.prefillLearnedEstimates(ctx, reqs, carried)
 	tasks := toTasks(reqs)
 	s.allTasks = tasks
-	s.executeCh = make(chan func(), len(tasks))
-	s.validateCh = make(chan func(), len(tasks))
+	s.markSequentialOnly(reqs, tasks)
+	s.markDuplicates(tasks)
+	s.startDispatchOrder(reqs)
+	s.runSerialAnte(ctx, tasks)
+	s.inspector.setTasks(tasks)
+	s.wakeups = newWakeups()
+	s.recordAuditHashes(reqs)
+	s.startAppends(len(tasks))
 	defer s.emitMetrics()
 
-	// default to number of tasks if workers is negative or 0 by this point
-	workers := s.workers
-	if s.workers < 1 {
-		workers = len(tasks)
+	workers := s.blockWorkers(len(tasks))
+	s.metrics.workers = workers
+	s.lookaheadWindow = s.blockLookahead()
+	s.lookaheadBase = -1
+	s.syncStart = -1
+	s.frontier = -1
+	s.metrics.lookaheadWindow = s.lookaheadWindow
+	// validation tasks uses length of tasks to avoid blocking on validation
+	s.executeDispatcher = s.newDispatcher(len(tasks), workers)
+	s.validateDispatcher = s.newDispatcher(len(tasks), len(tasks))
+
+	// without a long-lived pool, the block's workers are started for it and stopped once it's done
+	pool := s.workerPool
+	if pool == nil {
+		pool = NewWorkerPool()
+		defer pool.Close()
 	}
-
-	workerCtx, cancel := context.WithCancel(ctx.Context())
+	// the workers outlive an interruption of the block, so that the work already handed to them can drain, and are
+	// only released once the block is done, by the time ProcessAll returns
+	var released sync.WaitGroup
+	defer released.Wait()
+	workerCtx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// execution tasks are limited by workers
-	start(workerCtx, s.executeCh, workers)
+	toExecute := tasks
+	// small blocks skip the worker pools, unless they fail inline validation
+	if s.useSmallBlockPath(tasks) {
+		phaseStart := s.clock.Now()
+		s.metrics.smallBlock = s.executeSmallBlock(ctx, tasks)
+		s.metrics.executeDuration += s.clock.Now().Sub(phaseStart)
+		toExecute = s.smallBlockLeftovers(tasks)
+	}
+	if (len(toExecute) > 0 || s.appendEnabled) && !s.synchronous {
+		// execution tasks are limited by workers
+		if err := pool.serve(workerCtx, s.executeDispatcher, workers, "execute", &released); err != nil {
+			return nil, err
+		}
 
-	// validation tasks uses length of tasks to avoid blocking on validation
-	start(workerCtx, s.validateCh, len(tasks))
+		if err := pool.serve(workerCtx, s.validateDispatcher, len(tasks), "validate", &released); err != nil {
+			return nil, err
+		}
+	}
 
-	toExecute := tasks
-	for !allValidated(tasks) {
-		// if the max incarnation >= 5, we should revert to synchronous
-		if iterations >= maximumIterations {
+	// blocks with guaranteed disjoint writesets can skip readset tracking and validation entirely
+	if len(toExecute) == len(tasks) && len(s.sequentialOnly) == 0 && !s.synchronous && guaranteedDisjoint(reqs, s.strictWritesets) {
+		phaseStart := s.clock.Now()
+		ok, err := s.executeHappyPath(ctx, reqs, tasks)
+		if err := s.handleInterrupt(ctx, err); err != nil {
+			return nil, err
+		}
+		s.metrics.executeDuration += s.clock.Now().Sub(phaseStart)
+		s.metrics.happyPath = ok
+		// an interrupted happy path is re-executed sequentially from the first tx, which replaces its writes
+		if !ok && !s.synchronous {
+			s.fallBackToFullOCC(ctx, reqs, tasks)
+		}
+	}
+
+	for {
+		// pick up any tasks appended to the block since the last round
+		if appended := s.takeAppended(tasks); len(appended) > 0 {
+			tasks = append(tasks, appended...)
+			s.allTasks = tasks
+			s.inspector.setTasks(tasks)
+			toExecute = append(toExecute, appended...)
+		}
+		if allValidated(tasks) {
+			if s.stopAppends() {
+				break
+			}
+			continue
+		}
+
+		if err := s.handleInterrupt(ctx, s.interrupted()); err != nil {
+			return nil, err
+		}
+		s.handleTimeouts(ctx)
+		s.handleTrackingOverflows(ctx)
+		s.handleTaskLimits(ctx)
+		s.handleTaskMemoryLimit(ctx)
+		s.handleMemoryBudget(ctx)
+
+		// if we've exceeded the allowed number of rounds, we should revert to synchronous
+		if iterations-s.lookaheadRounds >= s.maxIterations || s.synchronous {
 			// process synchronously
+			if s.maxIterations > 0 {
+				s.recordFallback(ctx, FallbackRoundLimit, nil)
+			}
 			s.synchronous = true
 			startIdx, anyLeft := s.findFirstNonValidated()
 			if !anyLeft {
 				break
 			}
+			if err := s.checkLivelock(startIdx); err != nil {
+				return nil, err
+			}
 			toExecute = tasks[startIdx:]
 		}
 
+		toExecute = s.limitLookahead(toExecute)
+		roundCtx := s.startRoundSpan(ctx, iterations)
+
 		// execute sets statuses of tasks to either executed or aborted
-		if err := s.executeAll(ctx, toExecute); err != nil {
-			return nil, err
+		phaseStart := s.clock.Now()
+		var waves [][]int
+		if iterations == 0 {
+			waves = s.planFirstRound(reqs, toExecute)
+		}
+		var err error
+		if len(waves) > 0 {
+			s.metrics.plannedWaves = len(waves)
+			err = s.executePlanned(roundCtx, tasks, waves)
+		} else {
+			err = s.executeAll(roundCtx, toExecute)
+		}
+		s.metrics.executeDuration += s.clock.Now().Sub(phaseStart)
+		if err != nil {
+			if err := s.handleInterrupt(ctx, err); err != nil {
+				return nil, err
+			}
+			// the tasks dropped by the interruption haven't executed, so the round can't be validated
+			iterations++
+			continue
+		}
+		if err := s.checkInvariants(); err != nil {
+			toExecute = s.rollback(ctx, err)
+			iterations++
+			continue
 		}
 
 		// validate returns any that should be re-executed
-		// note this processes ALL tasks, not just those recently executed
-		var err error
-		toExecute, err = s.validateAll(ctx, tasks)
+		// note this processes every non-validated task, and any validated task affected by writeset changes
+		phaseStart = s.clock.Now()
+		toExecute, err = s.validateAll(roundCtx, tasks)
 		if err != nil {
 			return nil, err
 		}
+		if err := s.handleParentMutations(ctx); err != nil {
+			return nil, err
+		}
+		s.metrics.validateDuration += s.clock.Now().Sub(phaseStart)
+		s.onValidationRound(iterations, toExecute)
+		if err := s.checkInvariants(); err != nil {
+			toExecute = s.rollback(ctx, err)
+		} else {
+			s.checkpoint()
+			s.pruneVersions()
+			s.streamValidated()
+			if err := s.commitValidated(); err != nil {
+				return nil, err
+			}
+		}
 		// these are retries which apply to metrics
 		s.metrics.retries += len(toExecute)
+		if err := s.checkFrontier(ctx, iterations); err != nil {
+			return nil, err
+		}
 		iterations++
 	}
+	s.endRoundSpan()
 
-	for _, mv := range s.multiVersionStores {
-		mv.WriteLatestToStore()
+	if err := s.auditResponses(tasks); err != nil {
+		return nil, err
+	}
+	if err := checkResponses(tasks); err != nil {
+		return nil, err
+	}
+	s.spotCheckValidations(ctx, tasks)
+	s.removeStaleEstimates(tasks)
+	if err := s.checkEstimates(); err != nil {
+		return nil, err
+	}
+	if s.results != nil {
+		s.recordResults(tasks)
+	}
+	if s.simulation != nil {
+		s.recordSimulation(tasks)
+	} else {
+		s.dumpBlock(ctx, tasks)
+		flushTxTelemetry(tasks)
+		if s.writesetHashing {
+			s.writesetHash = HashWritesets(s.multiVersionStores)
+		}
+		if s.prefixStats != nil {
+			s.prefixStats.recordBlock(tasks)
+		}
+		s.recordCarriedEstimates(ctx, tasks)
+		s.recordLearnedWritesets(ctx, tasks)
+	}
+
+	if err := s.flushStores(); err != nil {
+		return nil, err
+	}
+	s.flushIsolatedStores(tasks)
+	s.exportChangeSets(ctx)
+	s.assertInvariants(ctx)
+	s.metrics.txs = len(tasks)
+	s.metrics.iterations = iterations
+	s.metrics.synchronous = s.synchronous
+	s.metrics.committedTxs = s.committed
+	s.metrics.incarnations = make([]int, 0, len(tasks))
+	for _, t := range tasks {
+		s.metrics.incarnations = append(s.metrics.incarnations, t.Incarnation)
+		s.metrics.finalGasUsed += t.Response.GasUsed
 	}
+	s.metrics.validationCosts = make(map[string]multiversion.ValidationCost, len(s.orderedStores))
+	for _, mv := range s.orderedStores {
+		s.metrics.validationCosts[mv.key.Name()] = mv.store.ValidationCost()
+		s.metrics.prunedVersions += mv.store.PrunedVersions()
+		cacheStats := mv.store.ParentReadCacheStats()
+		s.metrics.parentCacheHits += cacheStats.Hits
+		s.metrics.parentCacheMisses += cacheStats.Misses
+		s.metrics.parentCacheEvictions += cacheStats.Evictions
+		mv.store.FlushTelemetry()
+	}
+	s.reportHotKeys(ctx)
 	s.metrics.maxIncarnation = s.maxIncarnation
+	s.metrics.duration = s.clock.Now().Sub(startTime)
+	if s.workerTuner != nil {
+		s.workerTuner.observe(s.metrics.snapshot())
+	}
+	if s.lookaheadTuner != nil {
+		s.lookaheadTuner.observe(s.metrics.snapshot())
+	}
+	s.onBlockComplete()
 
-	ctx.Logger().Info("occ scheduler", "height", ctx.BlockHeight(), "txs", len(tasks), "maxIncarnation", s.maxIncarnation, "iterations", iterations, "sync", s.synchronous, "workers", s.workers)
+	ctx.Logger().Info("occ scheduler", "height", ctx.BlockHeight(), "txs", len(tasks), "maxIncarnation", s.maxIncarnation, "iterations", iterations, "sync", s.synchronous, "workers", workers, "maxConcurrency", s.metrics.concurrency.maxConcurrency())
 
+	s.finalizeResponses(tasks)
+	s.streamResponses(tasks)
 	return s.collectResponses(tasks), nil
 }
 
+// validationResult is the outcome of checking the reads of a task against the multiversion stores, which is only done
+// for executed and validated tasks
+type validationResult struct {
+	checked   bool
+	valid     bool
+	conflicts []int
+}
+
+// checkReads checks the reads of a task against the multiversion stores, if its status calls for it. It only reads
+// from the stores, so the tasks of a validation wave can be checked in parallel.
+func (s *scheduler) checkReads(task *deliverTxTask) validationResult {
+	switch task.LoadStatus() {
+	case statusExecuted, statusValidated:
+		valid, conflicts := s.findConflicts(task)
+		if valid && failpoint.FlapValidation(task.Index, task.Incarnation) {
+			valid = false
+		}
+		return validationResult{checked: true, valid: valid, conflicts: conflicts}
+	default:
+		return validationResult{}
+	}
+}
+
 func (s *scheduler) shouldRerun(task *deliverTxTask) bool {
-	switch task.Status {
+	return s.resolveValidation(task, s.checkReads(task))
+}
+
+// resolveValidation applies the outcome of checking the reads of a task, returning whether the task should be re-run.
+// Unlike checking, resolving invalidates writesets and depends on the statuses of other tasks, so the tasks of a
+// validation wave are resolved one at a time, in index order.
+func (s *scheduler) resolveValidation(task *deliverTxTask, result validationResult) bool {
+	switch current := task.LoadStatus(); current {
 
 	case statusAborted, statusPending:
 		return true
 
 	// validated tasks can become unvalidated if an earlier re-run task now conflicts
 	case statusExecuted, statusValidated:
+		if !result.checked {
+			result = s.checkReads(task)
+		}
 		// With the current scheduler, we won't actually get to this step if a previous task has already been determined to be invalid,
 		// since we choose to fail fast and mark the subsequent tasks as invalid as well.
 		// TODO: in a future async scheduler that no longer exhaustively validates in order, we may need to carefully handle the `valid=true` with conflicts case
-		if valid, conflicts := s.findConflicts(task); !valid {
+		if valid, conflicts := result.valid, result.conflicts; !valid {
+			s.metrics.recordConflicts(task.Index, conflicts)
+			s.recordTaskAbort(task, conflicts, nil)
 			s.invalidateTask(task)
 			task.AppendDependencies(conflicts)
 
-			// if the conflicts are now validated, then rerun this task
-			if dependenciesValidated(s.allTasks, task.Dependencies) {
-				return true
-			} else {
-				// otherwise, wait for completion
-				task.SetStatus(statusWaiting)
+			// the conflict policy decides whether to rerun this task now or wait for its dependencies to complete
+			decision := s.conflictPolicy.Resolve(Conflict{
+				Index:                 task.Index,
+				Incarnation:           task.Incarnation,
+				Conflicts:             conflicts,
+				DependenciesValidated: dependenciesValidated(s.allTasks, task.Dependencies),
+			})
+			// a task pre-aborted since its status was loaded is re-run rather than waiting
+			if decision == DecisionWait && task.CompareAndSetStatus(current, statusWaiting) {
+				s.wakeups.wait(s.allTasks, task)
 				return false
 			}
+			return true
 		} else if len(conflicts) == 0 {
 			// mark as validated, which will avoid re-validating unless a lower-index re-validates
-			task.SetStatus(statusValidated)
+			if !task.CompareAndSetStatus(current, statusValidated) {
+				return true
+			}
+			s.wakeups.validated(task.Index)
 			return false
 		}
 		// conflicts and valid, so it'll validate next time
 		return false
 
 	case statusWaiting:
-		// if conflicts are done, then this task is ready to run again
-		return dependenciesValidated(s.allTasks, task.Dependencies)
+		// if conflicts are done, then this task is ready to run again, otherwise it waits to be woken again
+		if dependenciesValidated(s.allTasks, task.Dependencies) {
+			return true
+		}
+		s.wakeups.wait(s.allTasks, task)
+		return false
 	}
-	panic("unexpected status: " + task.Status)
+	panic("unexpected status: " + task.LoadStatus().String())
 }
 
-func (s *scheduler) validateTask(ctx sdk.Context, task *deliverTxTask) bool {
+// validateTask resolves the checked reads of a task, returning whether it's still valid
+func (s *scheduler) validateTask(ctx sdk.Context, task *deliverTxTask, result validationResult) bool {
 	_, span := s.traceSpan(ctx, "SchedulerValidate", task)
 	defer span.End()
 
-	if s.shouldRerun(task) {
+	if s.resolveValidation(task, result) {
 		return false
 	}
 	return true
@@ -376,51 +1066,108 @@ func (s *scheduler) validateTask(ctx sdk.Context, task *deliverTxTask) bool {
 
 func (s *scheduler) findFirstNonValidated() (int, bool) {
 	for i, t := range s.allTasks {
-		if t.Status != statusValidated {
+		if !t.IsStatus(statusValidated) {
 			return i, true
 		}
 	}
 	return 0, false
 }
 
+// affectedReaders returns the indices of the tasks that read keys whose latest values changed since the previous call,
+// along with the changed keys
+func (s *scheduler) affectedReaders() (map[int]struct{}, dirtyKeys) {
+	affected := make(map[int]struct{})
+	dirty := make(dirtyKeys)
+	for _, mv := range s.orderedStores {
+		keys := mv.store.TakeDirtyKeys()
+		if len(keys) > 0 {
+			dirty[mv.key] = keys
+		}
+		for _, idx := range mv.store.GetAffectedReaders(keys) {
+			affected[idx] = struct{}{}
+		}
+	}
+	return affected, dirty
+}
+
 func (s *scheduler) validateAll(ctx sdk.Context, tasks []*deliverTxTask) ([]*deliverTxTask, error) {
 	ctx, span := s.traceSpan(ctx, "SchedulerValidateAll", nil)
 	defer span.End()
 
 	var mx sync.Mutex
-	var res []*deliverTxTask
 
+	// always drain the dirty keys, so that they only cover writeset changes since the previous validation
+	affected, dirty := s.affectedReaders()
+	woken := s.wakeups.take()
 	startIdx, anyLeft := s.findFirstNonValidated()
 
 	if !anyLeft {
 		return nil, nil
 	}
 
+	// the wave is validated in two phases: the reads of its tasks are checked in parallel, which only reads from the
+	// multiversion stores, and the results are then resolved in index order, so that the invalidation of a task can't
+	// race with checking the reads of another, and conflicts are resolved the same way regardless of timing
+	var wave []*deliverTxTask
+	for _, t := range tasks[startIdx:] {
+		// a validated task stays valid unless a lower-index writeset changed a key it read or iterated over
+		if _, ok := affected[t.Index]; !ok && t.IsStatus(statusValidated) {
+			s.metrics.skippedValidations++
+			continue
+		}
+		// a task beyond the lookahead window hasn't executed yet
+		if s.isDeferred(t) {
+			continue
+		}
+		// a waiting task stays blocked until one of its dependencies is validated
+		if _, ok := woken[t.Index]; !ok && t.IsStatus(statusWaiting) {
+			s.metrics.skippedWaits++
+			continue
+		}
+		wave = append(wave, t)
+	}
+
+	results := make(map[*deliverTxTask]validationResult, len(wave))
+	// the time it took to find the last invalid task of the wave
+	var invalidationLatency time.Duration
+	checkStart := s.clock.Now()
 	wg := &sync.WaitGroup{}
-	for i := startIdx; i < len(tasks); i++ {
+	for _, t := range s.faults.validationOrder(s.checkOrder(wave, dirty)) {
+		t := t
 		wg.Add(1)
-		t := tasks[i]
-		s.DoValidate(func() {
+		s.DoValidate(func(labelCtx context.Context) {
 			defer wg.Done()
-			if !s.validateTask(ctx, t) {
+			withTaskLabels(labelCtx, "validate", t, func() {
+				result := s.checkReads(t)
 				mx.Lock()
 				defer mx.Unlock()
-				t.Reset()
-				t.Increment()
-				// update max incarnation for scheduler
-				if t.Incarnation > s.maxIncarnation {
-					s.maxIncarnation = t.Incarnation
+				results[t] = result
+				if result.checked && !result.valid {
+					invalidationLatency = s.clock.Now().Sub(checkStart)
 				}
-				res = append(res, t)
-			}
+			})
 		})
 	}
 	wg.Wait()
+	s.metrics.invalidationLatency += invalidationLatency
+
+	var res []*deliverTxTask
+	for _, t := range wave {
+		if !s.validateTask(ctx, t, results[t]) {
+			t.Reset()
+			t.Increment()
+			// update max incarnation for scheduler
+			if t.Incarnation > s.maxIncarnation {
+				s.maxIncarnation = t.Incarnation
+			}
+			res = append(res, t)
+		}
+	}
 
 	return res, nil
 }
 
-// ExecuteAll executes all tasks concurrently
+// ExecuteAll executes all tasks concurrently, returning an ErrInterrupted error if the block was interrupted meanwhile
 func (s *scheduler) executeAll(ctx sdk.Context, tasks []*deliverTxTask) error {
 	if len(tasks) == 0 {
 		return nil
@@ -434,16 +1181,23 @@ func (s *scheduler) executeAll(ctx sdk.Context, tasks []*deliverTxTask) error {
 	wg := &sync.WaitGroup{}
 	wg.Add(len(tasks))
 
-	for _, task := range tasks {
+	for _, task := range s.orderDispatch(tasks) {
 		t := task
-		s.DoExecute(func() {
-			s.prepareAndRunTask(wg, ctx, t)
+		s.DoExecute(func(labelCtx context.Context) {
+			// once the block is interrupted, queued executions are dropped rather than run
+			if s.interrupted() != nil {
+				wg.Done()
+				return
+			}
+			withTaskLabels(labelCtx, "execute", t, func() {
+				s.prepareAndRunTask(wg, ctx, t)
+			})
 		})
 	}
 
 	wg.Wait()
 
-	return nil
+	return s.interrupted()
 }
 
 func (s *scheduler) prepareAndRunTask(wg *sync.WaitGroup, ctx sdk.Context, task *deliverTxTask) {
@@ -451,16 +1205,45 @@ func (s *scheduler) prepareAndRunTask(wg *sync.WaitGroup, ctx sdk.Context, task
 	defer eSpan.End()
 
 	task.Ctx = eCtx
+	s.metrics.concurrency.start()
+	s.waitForOriginal(task)
 	s.executeTask(task)
+	// rather than blindly re-executing an aborted task in the next round, wait for the tx it depends on to finish
+	// executing and resume it right away. The aborted execution only left estimates behind, which the resumed
+	// execution replaces, so it keeps the same incarnation.
+	for task.IsStatus(statusAborted) && s.waitForDependency(task) {
+		task.Reset()
+		task.Ctx = eCtx
+		s.executeTask(task)
+	}
+	s.metrics.concurrency.done()
 	wg.Done()
 }
 
-func (s *scheduler) traceSpan(ctx sdk.Context, name string, task *deliverTxTask) (sdk.Context, trace.Span) {
-	spanCtx, span := s.tracingInfo.StartWithContext(name, ctx.TraceSpanContext())
+// waitForDependency parks an aborted task in statusWaiting until the lower-index tx that caused the abort finishes
+// its current execution, returning false if that tx isn't executing (in which case the task is left for the next round).
+// Tasks only ever wait on lower indices that are already executing, so waits can't form a cycle.
+func (s *scheduler) waitForDependency(task *deliverTxTask) bool {
+	if s.synchronous || task.Abort == nil {
+		return false
+	}
+	done := s.allTasks[task.Abort.DependentTxIdx].executionDone()
+	if done == nil {
+		return false
+	}
+	telemetry.IncrCounter(1, "scheduler", "dependency_waits")
+	task.SetStatus(statusWaiting)
+	<-done
+	return true
+}
+
+func (s *scheduler) traceSpan(ctx sdk.Context, name string, task *deliverTxTask, opts ...trace.SpanStartOption) (sdk.Context, trace.Span) {
+	spanCtx, span := s.tracingInfo.StartWithContext(name, ctx.TraceSpanContext(), opts...)
 	if task != nil {
 		span.SetAttributes(attribute.String("txHash", fmt.Sprintf("%X", sha256.Sum256(task.Request.Tx))))
 		span.SetAttributes(attribute.Int("txIndex", task.Index))
 		span.SetAttributes(attribute.Int("txIncarnation", task.Incarnation))
+		span.SetAttributes(attribute.String("txIncarnationLabel", occ.IncarnationString(task.Incarnation)))
 	}
 	ctx = ctx.WithTraceSpanContext(spanCtx)
 	return ctx, span
@@ -474,24 +1257,79 @@ func (s *scheduler) prepareTask(task *deliverTxTask) {
 	defer span.End()
 
 	// initialize the context
-	abortCh := make(chan occ.Abort, len(s.multiVersionStores))
+	abortCh := newAbortChannel(len(s.orderedStores) + len(s.unversioned))
+
+	// metrics emitted by handlers are buffered until the block is done, since the incarnation may not be final
+	task.Telemetry = telemetry.NewBuffer()
+	ctx = ctx.WithTelemetry(task.Telemetry)
 
 	// if there are no stores, don't try to wrap, because there's nothing to wrap
-	if len(s.multiVersionStores) > 0 {
-		// non-blocking
-		cms := ctx.MultiStore().CacheMultiStore()
+	if len(s.orderedStores) > 0 || len(s.unversioned) > 0 {
+		// the stores of the previous incarnation are reset rather than created again, along with the multistore
+		// they're set in, as long as they belong to the current multiversion stores
+		reused := task.stores
+		if reused == nil || reused.epoch != s.storesEpoch {
+			reused = nil
+			task.stores = nil
+		}
 
 		// init version stores by store key
-		vs := make(map[store.StoreKey]*multiversion.VersionIndexedStore)
-		for storeKey, mvs := range s.multiVersionStores {
-			vs[storeKey] = mvs.VersionedIndexedStore(task.Index, task.Incarnation, abortCh)
+		task.Limiter = s.newTaskLimiter()
+		task.MemoryMeter = s.newMemoryMeter()
+		task.TrackingMeter = s.newTrackingMeter()
+		// once the execution aborts, it stops at its next store operation, even if it recovered the abort
+		abortSignal := occ.NewAbortSignal()
+		task.AbortSignal = abortSignal
+		vs := make(map[store.StoreKey]*multiversion.VersionIndexedStore, len(s.orderedStores))
+		if reused != nil {
+			vs = reused.versionStores
+		}
+		for _, mv := range s.orderedStores {
+			if reused != nil {
+				mv.store.ReuseVersionIndexedStore(vs[mv.key], task.Incarnation, abortCh)
+			} else {
+				vs[mv.key] = mv.store.VersionedIndexedStore(task.Index, task.Incarnation, abortCh)
+			}
+			if task.Limiter != nil {
+				vs[mv.key].SetLimiter(task.Limiter)
+			} else {
+				vs[mv.key].SetLimiter(nil)
+			}
+			vs[mv.key].SetAbortSignal(abortSignal)
+			if task.MemoryMeter != nil {
+				vs[mv.key].SetMemoryMeter(task.MemoryMeter)
+			}
+			if task.TrackingMeter != nil {
+				vs[mv.key].SetTrackingMeter(task.TrackingMeter)
+			}
+			if s.happyPath {
+				vs[mv.key].DisableReadTracking()
+			}
+			if s.concurrentStoreAccess {
+				vs[mv.key].EnableConcurrentAccess()
+			}
 		}
 
 		// save off version store so we can ask it things later
 		task.VersionStores = vs
-		ms := cms.SetKVStores(func(k store.StoreKey, kvs sdk.KVStore) store.CacheWrap {
-			return vs[k]
-		})
+		unversioned := s.unversionedStores(task, abortCh)
+		var ms sdk.MultiStore
+		if reused != nil {
+			ms = reused.multiStore
+		} else {
+			// non-blocking
+			ms = ctx.MultiStore().CacheMultiStore()
+		}
+		// the branches of the unversioned stores are new for every incarnation, so they're set again
+		if reused == nil || len(unversioned) > 0 {
+			ms = ms.SetKVStores(func(k store.StoreKey, kvs sdk.KVStore) store.CacheWrap {
+				if vis, ok := vs[k]; ok {
+					return vis
+				}
+				return unversioned[k]
+			})
+		}
+		task.stores = &taskStores{epoch: s.storesEpoch, multiStore: ms, versionStores: vs}
 
 		ctx = ctx.WithMultiStore(ms)
 	}
@@ -501,16 +1339,16 @@ func (s *scheduler) prepareTask(task *deliverTxTask) {
 }
 
 func (s *scheduler) executeTask(task *deliverTxTask) {
-	dCtx, dSpan := s.traceSpan(task.Ctx, "SchedulerExecuteTask", task)
+	dCtx, dSpan := s.traceExecution(task.Ctx, task)
 	defer dSpan.End()
 	task.Ctx = dCtx
 
 	// in the synchronous case, we only want to re-execute tasks that need re-executing
 	if s.synchronous {
-		// if already validated, then this does another validation
+		// if already validated, then this does another validation. A conflicting task is invalidated without leaving
+		// statusValidated, so it must be re-executed here rather than skipped with its writes left as estimates.
 		if task.IsStatus(statusValidated) {
-			s.shouldRerun(task)
-			if task.IsStatus(statusValidated) {
+			if !s.shouldRerun(task) && task.IsStatus(statusValidated) {
 				return
 			}
 		}
@@ -525,27 +1363,146 @@ func (s *scheduler) executeTask(task *deliverTxTask) {
 
 	s.prepareTask(task)
 
-	resp := s.deliverTx(task.Ctx, task.Request)
-	// close the abort channel
-	close(task.AbortCh)
-	abort, ok := <-task.AbortCh
+	task.startExecution()
+	defer task.finishExecution()
+	defer s.recordExecutionTime(task, s.clock.Now())
+
+	failpoint.DelayExecution(task.Index, task.Incarnation)
+	resp, timedOut := s.deliverTxPastBarrier(dSpan, task)
+	if timedOut {
+		s.onTaskTimedOut(task)
+		return
+	}
+	aborts := collectAborts(task.AbortCh)
+	if len(aborts) > 0 && s.faults.shouldDropAbort(task) {
+		aborts = nil
+	}
+	aborts = injectAbort(task, aborts)
+	ok := len(aborts) > 0
+	// an OCC abort response without an abort means the abort was lost, so there's no dependency to wait on
+	lostAbort := !ok && isResponseError(resp, sdkerrors.ErrOCCAbort)
+	s.metrics.recordExecution(resp.GasUsed, ok || lostAbort)
 	if ok {
+		abort := classifyAbort(aborts[0], resp)
+		deps := abortDependencies(aborts)
+		s.recordAbort(dSpan, abort)
+		dSpan.SetAttributes(attribute.Int("aborts", len(aborts)))
+		s.metrics.recordConflicts(task.Index, deps)
 		// if there is an abort item that means we need to wait on the dependent tx
 		task.SetStatus(statusAborted)
 		task.Abort = &abort
-		task.AppendDependencies([]int{abort.DependentTxIdx})
-		// write from version store to multiversion stores
-		for _, v := range task.VersionStores {
-			v.WriteEstimatesToMultiVersionStore()
-		}
+		task.Aborts = aborts
+		task.AppendDependencies(deps)
+		s.recordTaskAbort(task, deps, &abort)
+		s.writeAbortEstimates(task)
+		s.onTaskAborted(task, abort)
+		return
+	}
+	if lostAbort {
+		// the task is simply re-executed in the next round, rather than having its aborted response taken as final
+		telemetry.IncrCounter(1, "scheduler", "lost_aborts")
+		task.SetStatus(statusAborted)
+		s.writeAbortEstimates(task)
+		return
+	}
+	if s.trackingOverflowedBy(task) {
+		s.onTrackingOverflow(task)
+		return
+	}
+	if s.taskLimitExceededBy(task) {
+		s.onTaskLimitExceeded(task)
+		return
+	}
+	if s.taskMemoryExceededBy(task) {
+		s.onTaskMemoryExceeded(task)
 		return
 	}
 
-	task.SetStatus(statusExecuted)
+	if task.NoWritesExpected {
+		if s.wroteNothing(task) {
+			s.finishNoWritesTask(task, resp)
+			s.onTaskExecuted(task)
+			return
+		}
+		// the flag is only a hint, so a flagged task that wrote anyway is finished like any other, keeping its writes
+		telemetry.IncrCounter(1, "scheduler", "unexpected_writes")
+	}
+
 	task.Response = &resp
 
+	newKeys := s.newWritesetKeys(task)
+
+	s.faults.delayWritesetFlush(task)
+
 	// write from version store to multiversion stores
-	for _, v := range task.VersionStores {
-		v.WriteToMultiVersionStore()
+	for _, mv := range s.orderedStores {
+		task.VersionStores[mv.key].WriteToMultiVersionStore()
+	}
+
+	s.sampleMemory()
+
+	// only mark as executed once the writes are visible, so that the task can't be pre-aborted mid-write
+	task.SetStatus(statusExecuted)
+
+	s.preAbortReaders(task, newKeys)
+	s.onTaskExecuted(task)
+}
+
+// writeAbortEstimates marks the writes of an aborted execution of task as estimates in the multiversion stores
+func (s *scheduler) writeAbortEstimates(task *deliverTxTask) {
+	// on the happy path the prefilled estimates already cover every key the task may write, and must stay in
+	// place since nothing validates reads of them
+	if s.happyPath {
+		return
+	}
+	// write from version store to multiversion stores
+	for _, mv := range s.orderedStores {
+		task.VersionStores[mv.key].WriteEstimatesToMultiVersionStore()
+	}
+}
+
+// newWritesetKeys returns the keys per store written by a re-executed task that weren't part of its previous writeset
+func (s *scheduler) newWritesetKeys(task *deliverTxTask) map[sdk.StoreKey][]string {
+	if task.Incarnation == 0 {
+		return nil
+	}
+	newKeys := make(map[sdk.StoreKey][]string)
+	for _, mv := range s.orderedStores {
+		prevKeys := make(map[string]struct{})
+		for _, key := range mv.store.GetWritesetKeys(task.Index) {
+			prevKeys[key] = struct{}{}
+		}
+		for key := range task.VersionStores[mv.key].GetWriteset() {
+			if _, ok := prevKeys[key]; !ok {
+				newKeys[mv.key] = append(newKeys[mv.key], key)
+			}
+		}
+	}
+	return newKeys
+}
+
+// preAbortReaders eagerly sends later tasks that read keys newly written by a re-executed task back to pending, if the
+// writeset changed radically (see WithPreAbortThreshold). Those tasks are doomed to fail validation, so this saves them
+// from being validated and has them re-executed in the next round.
+func (s *scheduler) preAbortReaders(task *deliverTxTask, newKeys map[sdk.StoreKey][]string) {
+	var total int
+	for _, keys := range newKeys {
+		total += len(keys)
+	}
+	if s.preAbortThreshold <= 0 || total < s.preAbortThreshold {
+		return
+	}
+	for _, mv := range s.orderedStores {
+		keys, ok := newKeys[mv.key]
+		if !ok {
+			continue
+		}
+		for _, idx := range mv.store.GetDependentReaders(task.Index, keys) {
+			reader := s.allTasks[idx]
+			if reader.TryPreAbort() {
+				s.invalidateTask(reader)
+				telemetry.IncrCounter(1, "scheduler", "pre_aborts")
+			}
+		}
 	}
 }
@@ -8,6 +8,10 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -21,6 +25,9 @@ import (
 	"github.com/cosmos/cosmos-sdk/store/cachekv"
 	"github.com/cosmos/cosmos-sdk/store/cachemulti"
 	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	iavlstore "github.com/cosmos/cosmos-sdk/store/iavl"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/occ"
 	"github.com/cosmos/cosmos-sdk/utils/tracing"
@@ -71,6 +78,12 @@ func initTestCtx(injectStores bool) sdk.Context {
 	return ctx
 }
 
+// newTestTracingInfo returns tracing info with a no-op tracer for the schedulers under test
+func newTestTracingInfo() *tracing.Info {
+	tr := trace.NewNoopTracerProvider().Tracer("scheduler-test")
+	return &tracing.Info{Tracer: &tr}
+}
+
 func TestProcessAll(t *testing.T) {
 	runtime.SetBlockProfileRate(1)
 
@@ -247,6 +260,45 @@ func TestProcessAll(t *testing.T) {
 			assertions:  func(t *testing.T, ctx sdk.Context, res []types.ResponseDeliverTx) {},
 			expectedErr: nil,
 		},
+		{
+			name:      "Test tx emitting multiple aborts does not block",
+			workers:   50,
+			runs:      5,
+			addStores: true,
+			requests:  requestList(500),
+			deliverTxFunc: func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+				defer abortRecoveryFunc(&response)
+				kv := ctx.MultiStore().GetKVStore(testStoreKey)
+				// swallow aborts so that a single execution emits several of them
+				for i := 0; i < 5; i++ {
+					func() {
+						defer func() {
+							if r := recover(); r != nil {
+								if _, ok := r.(occ.Abort); !ok {
+									panic(r)
+								}
+							}
+						}()
+						kv.Get(itemKey)
+					}()
+				}
+				val := string(kv.Get(itemKey))
+				kv.Set(itemKey, req.Tx)
+				return types.ResponseDeliverTx{
+					Info: val,
+				}
+			},
+			assertions: func(t *testing.T, ctx sdk.Context, res []types.ResponseDeliverTx) {
+				for idx, response := range res {
+					if idx == 0 {
+						require.Equal(t, "", response.Info)
+					} else {
+						require.Equal(t, fmt.Sprintf("%d", idx-1), response.Info)
+					}
+				}
+			},
+			expectedErr: nil,
+		},
 		{
 			name:      "Test no stores on context should not panic",
 			workers:   50,
@@ -307,12 +359,8 @@ func TestProcessAll(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			for i := 0; i < tt.runs; i++ {
 				// set a tracer provider
-				tp := trace.NewNoopTracerProvider()
 				otel.SetTracerProvider(trace.NewNoopTracerProvider())
-				tr := tp.Tracer("scheduler-test")
-				ti := &tracing.Info{
-					Tracer: &tr,
-				}
+				ti := newTestTracingInfo()
 
 				s := NewScheduler(tt.workers, ti, tt.deliverTxFunc)
 				ctx := initTestCtx(tt.addStores)
@@ -334,3 +382,806 @@ func TestProcessAll(t *testing.T) {
 		})
 	}
 }
+
+func TestProcessAllMultipleBlocks(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx reads the shared key, appends its index and writes it back
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		newVal := val + fmt.Sprintf("%d", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{
+			Info: newVal,
+		}
+	}
+
+	// the same scheduler is reused for every block
+	s := NewScheduler(10, ti, deliverTx)
+	var recycled multiversion.MultiVersionStore
+	for block := 0; block < 5; block++ {
+		// each block gets fresh parent stores, so any value surviving from a previous block is a leak
+		ctx := initTestCtx(true)
+		res, err := s.ProcessAll(ctx, requestList(20))
+		require.NoError(t, err)
+		require.Len(t, res, 20)
+
+		expected := ""
+		for idx, response := range res {
+			expected = expected + fmt.Sprintf("%d", idx)
+			require.Equal(t, expected, response.Info)
+		}
+		require.Equal(t, expected, string(ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey)))
+
+		// no block-scoped state survives the invocation
+		sch := s.(*scheduler)
+		require.Nil(t, sch.multiVersionStores)
+		require.Nil(t, sch.orderedStores)
+		require.Nil(t, sch.allTasks)
+		require.Nil(t, sch.executeDispatcher)
+		require.Nil(t, sch.validateDispatcher)
+		require.False(t, sch.synchronous)
+
+		// the reset multiversion stores are reused for the next block
+		require.NotNil(t, sch.recycledStores[testStoreKey.Name()])
+		if recycled != nil {
+			require.Same(t, recycled, sch.recycledStores[testStoreKey.Name()])
+		}
+		recycled = sch.recycledStores[testStoreKey.Name()]
+	}
+}
+
+func TestInitMultiVersionStoreOrdered(t *testing.T) {
+	db := dbm.NewMemDB()
+	keys := make(map[string]sdk.StoreKey)
+	stores := make(map[sdk.StoreKey]sdk.CacheWrapper)
+	for _, name := range []string{"staking", "bank", "evm", "acc"} {
+		key := sdk.NewKVStoreKey(name)
+		keys[name] = key
+		stores[key] = cachekv.NewStore(dbadapter.Store{DB: db}, key, 1000)
+	}
+	store := cachemulti.NewStore(db, stores, keys, nil, nil, nil)
+	ctx := sdk.Context{}.WithContext(context.Background()).WithMultiStore(&store)
+
+	s := &scheduler{}
+	s.initMultiVersionStore(ctx)
+	var names []string
+	for _, mv := range s.orderedStores {
+		require.Equal(t, s.multiVersionStores[mv.key], mv.store)
+		names = append(names, mv.key.Name())
+	}
+	require.Equal(t, []string{"acc", "bank", "evm", "staking"}, names)
+}
+
+func TestProcessAllWritesetGrowth(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx reads the shared key, and writes a number of keys that depends on what it read, so re-executions
+	// of a tx can produce writesets with many new keys that later txs read
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		for i := 0; i < len(val); i++ {
+			kv.Set([]byte(fmt.Sprintf("growth-%d", i)), []byte(req.Tx))
+		}
+		var seen int
+		for i := 0; i < 2*defaultPreAbortThreshold; i++ {
+			if kv.Get([]byte(fmt.Sprintf("growth-%d", i))) != nil {
+				seen++
+			}
+		}
+		kv.Set(itemKey, []byte(val+"x"))
+		return types.ResponseDeliverTx{
+			Info: fmt.Sprintf("%d", seen),
+		}
+	}
+
+	// pre-aborting readers doesn't change the results, whatever the threshold
+	for _, threshold := range []int{0, 1, defaultPreAbortThreshold} {
+		for run := 0; run < 5; run++ {
+			s := NewScheduler(10, ti, deliverTx, WithPreAbortThreshold(threshold))
+			ctx := initTestCtx(true)
+			n := 2 * defaultPreAbortThreshold
+			res, err := s.ProcessAll(ctx, requestList(n))
+			require.NoError(t, err)
+			require.Len(t, res, n)
+			for idx, response := range res {
+				require.Equal(t, fmt.Sprintf("%d", idx), response.Info)
+			}
+			require.Equal(t, strings.Repeat("x", n), string(ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey)))
+		}
+	}
+}
+
+func TestProcessAllWithMaxIterations(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx reads the shared key, appends its index and writes it back
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		newVal := val + fmt.Sprintf("%d", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{
+			Info: newVal,
+		}
+	}
+
+	for _, maxIterations := range []int{0, 1, 3} {
+		s := NewScheduler(10, ti, deliverTx, WithMaxIterations(maxIterations))
+		ctx := initTestCtx(true)
+		res, err := s.ProcessAll(ctx, requestList(50))
+		require.NoError(t, err)
+
+		expected := ""
+		for idx, response := range res {
+			expected = expected + fmt.Sprintf("%d", idx)
+			require.Equal(t, expected, response.Info)
+		}
+		require.Equal(t, expected, string(ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey)))
+		if maxIterations == 0 {
+			// sequential from the start, so nothing is ever retried
+			require.Equal(t, 0, s.(*scheduler).maxIncarnation)
+			require.Equal(t, 0, s.(*scheduler).metrics.retries)
+		}
+	}
+}
+
+func initIAVLTestCtx(t *testing.T) (sdk.Context, storetypes.CommitKVStore) {
+	db := dbm.NewMemDB()
+	iavlStore, err := iavlstore.LoadStore(db, log.NewNopLogger(), testStoreKey, storetypes.CommitID{}, false, 1000, false, nil)
+	require.NoError(t, err)
+	// seed some committed state so that txs overwrite and delete existing keys
+	for i := 0; i < 20; i++ {
+		iavlStore.Set([]byte(fmt.Sprintf("seed-%02d", i)), []byte(fmt.Sprintf("%d", i)))
+	}
+	iavlStore.Commit(true)
+
+	stores := map[sdk.StoreKey]sdk.CacheWrapper{
+		testStoreKey: cachekv.NewStore(iavlStore, testStoreKey, 1000),
+	}
+	keys := map[string]sdk.StoreKey{testStoreKey.Name(): testStoreKey}
+	store := cachemulti.NewStore(db, stores, keys, nil, nil, nil)
+	ctx := sdk.Context{}.WithContext(context.Background()).WithMultiStore(&store).WithLogger(log.NewNopLogger())
+	return ctx, iavlStore
+}
+
+func TestProcessAllMatchesSequentialCommitHash(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// txs overwrite, delete, and re-create keys, including keys that conflict across txs
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		idx := ctx.TxIndex()
+		val := kv.Get(itemKey)
+		kv.Set(itemKey, append(append([]byte{}, val...), req.Tx...))
+		kv.Set([]byte(fmt.Sprintf("tx-%03d", idx)), req.Tx)
+		kv.Delete([]byte(fmt.Sprintf("seed-%02d", idx%20)))
+		if idx%3 == 0 {
+			kv.Set([]byte(fmt.Sprintf("seed-%02d", (idx+1)%20)), req.Tx)
+		}
+		if idx%5 == 0 {
+			kv.Delete([]byte(fmt.Sprintf("tx-%03d", idx/2)))
+		}
+		return types.ResponseDeliverTx{}
+	}
+	reqs := requestList(100)
+
+	// sequential execution, with each tx committed to the block cache in order
+	seqCtx, seqStore := initIAVLTestCtx(t)
+	for i, req := range reqs {
+		cms := seqCtx.MultiStore().CacheMultiStore()
+		deliverTx(seqCtx.WithMultiStore(cms).WithTxIndex(i), req.Request)
+		cms.Write()
+	}
+	seqCtx.MultiStore().(storetypes.CacheMultiStore).Write()
+	seqHash := seqStore.Commit(true).Hash
+
+	for run := 0; run < 5; run++ {
+		occCtx, occStore := initIAVLTestCtx(t)
+		_, err := NewScheduler(20, ti, deliverTx).ProcessAll(occCtx, reqs)
+		require.NoError(t, err)
+		occCtx.MultiStore().(storetypes.CacheMultiStore).Write()
+		require.Equal(t, seqHash, occStore.Commit(true).Hash)
+	}
+}
+
+type fakeClock struct {
+	mx  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestProcessAllWithFakeClock(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	// txs don't conflict, so each executes exactly once and advances the clock by a millisecond
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		clock.Sleep(time.Millisecond)
+		ctx.MultiStore().GetKVStore(testStoreKey).Set(req.Tx, req.Tx)
+		return types.ResponseDeliverTx{}
+	}
+
+	s := NewScheduler(10, ti, deliverTx, WithClock(clock))
+	_, err := s.ProcessAll(initTestCtx(true), requestList(50))
+	require.NoError(t, err)
+	require.Equal(t, 50*time.Millisecond, s.(*scheduler).metrics.duration)
+}
+
+func TestProcessAllConcurrencyMetrics(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// with a single worker, tasks never execute concurrently
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		ctx.MultiStore().GetKVStore(testStoreKey).Set(req.Tx, req.Tx)
+		return types.ResponseDeliverTx{}
+	}
+	s := NewScheduler(1, ti, deliverTx)
+	_, err := s.ProcessAll(initTestCtx(true), requestList(20))
+	require.NoError(t, err)
+	require.Equal(t, 1, s.(*scheduler).metrics.concurrency.maxConcurrency())
+	require.Equal(t, float64(1), s.(*scheduler).metrics.concurrency.avgConcurrency())
+
+	// every tx waits for all the others to start, so all workers are utilized at once
+	const workers = 5
+	var barrier sync.WaitGroup
+	barrier.Add(workers)
+	deliverTx = func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		barrier.Done()
+		barrier.Wait()
+		ctx.MultiStore().GetKVStore(testStoreKey).Set(req.Tx, req.Tx)
+		return types.ResponseDeliverTx{}
+	}
+	// the block is small, so it needs to opt out of the small block path to use the worker pool
+	s = NewScheduler(workers, ti, deliverTx, WithSmallBlockThreshold(0))
+	_, err = s.ProcessAll(initTestCtx(true), requestList(workers))
+	require.NoError(t, err)
+	require.Equal(t, workers, s.(*scheduler).metrics.concurrency.maxConcurrency())
+	require.Greater(t, s.(*scheduler).metrics.concurrency.avgConcurrency(), float64(1))
+}
+
+func TestProcessAllSchedulerMetrics(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx reads the shared key, appends its index and writes it back, using a fixed amount of gas
+	var executions int64
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		atomic.AddInt64(&executions, 1)
+		response.GasUsed = 10
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		newVal := val + fmt.Sprintf("%d", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{
+			Info:    newVal,
+			GasUsed: 10,
+		}
+	}
+
+	s := NewScheduler(10, ti, deliverTx)
+	_, err := s.ProcessAll(initTestCtx(true), requestList(30))
+	require.NoError(t, err)
+
+	m := s.Metrics()
+	require.Equal(t, 30, m.Txs)
+	require.Len(t, m.Incarnations, 30)
+	require.Equal(t, s.(*scheduler).maxIncarnation, m.MaxIncarnation)
+	require.GreaterOrEqual(t, m.Iterations, 1)
+	require.Equal(t, 10*(atomic.LoadInt64(&executions)-30), m.WastedGas)
+	for _, pair := range m.Conflicts {
+		require.Less(t, pair.Dependency, pair.Index)
+	}
+	require.LessOrEqual(t, m.ExecuteDuration+m.ValidateDuration, m.Duration)
+	require.Len(t, m.ValidationCosts, 1)
+	require.GreaterOrEqual(t, m.ValidationCosts[testStoreKey.Name()].Validations, 30)
+
+	// metrics are kept for inspection until the next block
+	_, err = s.ProcessAll(initTestCtx(true), requestList(1))
+	require.NoError(t, err)
+	m = s.Metrics()
+	require.Equal(t, 1, m.Txs)
+	require.Equal(t, []int{0}, m.Incarnations)
+	require.Empty(t, m.Conflicts)
+	require.Equal(t, int64(0), m.WastedGas)
+}
+
+func TestProcessAllSkipsUnaffectedValidations(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// tx 5 reads a key written by tx 0, and tx 0 holds off its first write until tx 5 has read it, so that tx 5 is
+	// always re-executed. Every other tx only touches its own key.
+	readByTx5 := make(chan struct{})
+	var once sync.Once
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		switch ctx.TxIndex() {
+		case 0:
+			<-readByTx5
+			kv.Set(itemKey, []byte("written"))
+		case 5:
+			val := kv.Get(itemKey)
+			once.Do(func() { close(readByTx5) })
+			kv.Set(req.Tx, val)
+		default:
+			kv.Get(req.Tx)
+			kv.Set(req.Tx, req.Tx)
+		}
+		return types.ResponseDeliverTx{}
+	}
+
+	s := NewScheduler(20, ti, deliverTx)
+	ctx := initTestCtx(true)
+	_, err := s.ProcessAll(ctx, requestList(20))
+	require.NoError(t, err)
+
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	require.Equal(t, []byte("written"), kv.Get([]byte("5")))
+	m := s.Metrics()
+	require.Greater(t, m.Iterations, 1)
+	// txs after tx 5 were validated in the first round, and nothing they read changed since
+	require.GreaterOrEqual(t, m.SkippedValidations, 14)
+}
+
+func TestProcessAllRollbackOnInvariantViolation(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	var s Scheduler
+	var corrupted bool
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		// with a single worker tasks execute in order, so the first task is done by the time the last one runs
+		if ctx.TxIndex() == 19 && !corrupted {
+			corrupted = true
+			s.(*scheduler).allTasks[0].restoreStatus(status(-1))
+		}
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		newVal := val + fmt.Sprintf("%d", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{
+			Info: newVal,
+		}
+	}
+
+	s = NewScheduler(1, ti, deliverTx)
+	ctx := initTestCtx(true)
+	res, err := s.ProcessAll(ctx, requestList(20))
+	require.NoError(t, err)
+	require.True(t, corrupted)
+
+	expected := ""
+	for idx, response := range res {
+		expected = expected + fmt.Sprintf("%d", idx)
+		require.Equal(t, expected, response.Info)
+	}
+	require.Equal(t, expected, string(ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey)))
+}
+
+func TestCheckpointRollback(t *testing.T) {
+	s := &scheduler{multiVersionStores: map[sdk.StoreKey]multiversion.MultiVersionStore{}, metrics: &schedulerMetrics{}}
+	s.allTasks = toTasks(requestList(4))
+	for _, task := range s.allTasks {
+		task.Response = &types.ResponseDeliverTx{}
+	}
+	s.allTasks[0].restoreStatus(statusValidated)
+	s.allTasks[1].restoreStatus(statusValidated)
+	s.allTasks[2].restoreStatus(statusExecuted)
+	s.allTasks[2].Incarnation = 1
+	s.checkpoint()
+	require.Equal(t, 2, s.lastCheckpoint.validated)
+	require.NoError(t, s.checkInvariants())
+
+	// going back in incarnation violates the invariants
+	s.allTasks[2].Incarnation = 0
+	require.Error(t, s.checkInvariants())
+
+	toExecute := s.rollback(initTestCtx(false), errors.New("test"))
+	require.Len(t, toExecute, 2)
+	require.True(t, s.synchronous)
+	require.Equal(t, FallbackRollback, s.metrics.postmortem.Reason)
+	require.Equal(t, "test", s.metrics.postmortem.Cause)
+	require.Equal(t, 2, s.metrics.postmortem.Validated)
+	require.True(t, s.allTasks[0].IsStatus(statusValidated))
+	require.True(t, s.allTasks[1].IsStatus(statusValidated))
+	require.True(t, s.allTasks[2].IsStatus(statusPending))
+	require.Equal(t, 2, s.allTasks[2].Incarnation)
+	require.True(t, s.allTasks[3].IsStatus(statusPending))
+	require.Equal(t, 1, s.allTasks[3].Incarnation)
+	require.NoError(t, s.checkInvariants())
+}
+
+func TestProcessAllWithFlushListener(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		ctx.MultiStore().GetKVStore(testStoreKey).Set(req.Tx, req.Tx)
+		return types.ResponseDeliverTx{}
+	}
+
+	ring := multiversion.NewRingFlushLog(100)
+	s := NewScheduler(5, ti, deliverTx, WithFlushListener(ring))
+	_, err := s.ProcessAll(initTestCtx(true), requestList(10))
+	require.NoError(t, err)
+
+	// every tx flushed its writeset to the mock store
+	flushed := make(map[int]bool)
+	for _, record := range ring.Records() {
+		require.Equal(t, testStoreKey.Name(), record.Store)
+		require.Len(t, record.KeyHashes, 1)
+		flushed[record.Index] = true
+	}
+	require.Len(t, flushed, 10)
+}
+
+func TestProcessAllWithAccessLog(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		kv.Get(itemKey)
+		kv.Set(itemKey, req.Tx)
+		return types.ResponseDeliverTx{}
+	}
+
+	log := multiversion.NewAccessLog(1000)
+	s := NewScheduler(5, ti, deliverTx, WithAccessLog(log))
+	_, err := s.ProcessAll(initTestCtx(true), requestList(10))
+	require.NoError(t, err)
+
+	// the incarnation of every tx that was validated wrote the key
+	writers := log.Writers(testStoreKey.Name(), itemKey)
+	written := make(map[int]bool)
+	for _, record := range writers {
+		written[record.Index] = true
+	}
+	require.Len(t, written, 10)
+	for _, task := range s.(*scheduler).allTasks {
+		require.Contains(t, writers, multiversion.AccessRecord{
+			Store:       testStoreKey.Name(),
+			Index:       task.Index,
+			Incarnation: task.Incarnation,
+			Op:          multiversion.AccessSet,
+			Key:         itemKey,
+		})
+	}
+}
+
+func TestProcessAllWithStoreMetadata(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// the live version of the store moves on while the block executes
+	var version int64
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		atomic.AddInt64(&version, 1)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		value := multiversion.ReadMetadata(kv, "version", func() []byte {
+			return []byte(fmt.Sprint(atomic.LoadInt64(&version)))
+		})
+		kv.Set(req.Tx, value)
+		return types.ResponseDeliverTx{Info: string(value)}
+	}
+
+	var captured int
+	s := NewScheduler(5, ti, deliverTx, WithStoreMetadata(func(storeKey sdk.StoreKey) map[string][]byte {
+		captured++
+		return map[string][]byte{"version": []byte(fmt.Sprint(atomic.LoadInt64(&version)))}
+	}))
+	for block := 0; block < 2; block++ {
+		atomic.StoreInt64(&version, int64(block*100))
+		res, err := s.ProcessAll(initTestCtx(true), requestList(10))
+		require.NoError(t, err)
+		// every tx reads the version as of the start of the block
+		for _, r := range res {
+			require.Equal(t, fmt.Sprint(block*100), r.Info)
+		}
+		require.Equal(t, block+1, captured)
+	}
+}
+
+func TestProcessAllWaitsOnExecutingDependency(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	var s Scheduler
+	var mx sync.Mutex
+	executions := make(map[int]int)
+	estimated := make(chan struct{})
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		mx.Lock()
+		executions[ctx.TxIndex()]++
+		mx.Unlock()
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		switch ctx.TxIndex() {
+		case 0:
+			// simulate an estimate left behind by a previous incarnation, and keep executing until tx 1 is parked on it
+			s.(*scheduler).multiVersionStores[testStoreKey].SetEstimatedWriteset(0, 0, multiversion.WriteSet{string(itemKey): nil})
+			close(estimated)
+			require.Eventually(t, func() bool {
+				return s.(*scheduler).allTasks[1].IsStatus(statusWaiting)
+			}, time.Second, time.Millisecond)
+		case 1:
+			<-estimated
+		}
+		val := string(kv.Get(itemKey))
+		newVal := val + fmt.Sprintf("%d", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{
+			Info: newVal,
+		}
+	}
+
+	s = NewScheduler(2, ti, deliverTx, WithSmallBlockThreshold(0))
+	res, err := s.ProcessAll(initTestCtx(true), requestList(2))
+	require.NoError(t, err)
+	require.Equal(t, "0", res[0].Info)
+	require.Equal(t, "01", res[1].Info)
+	// tx 1 re-executed as soon as tx 0 finished, without waiting for another round
+	require.Equal(t, 1, executions[0])
+	require.Equal(t, 2, executions[1])
+}
+
+type recordingWriteListener struct {
+	values []string
+}
+
+func (l *recordingWriteListener) OnWrite(storeKey storetypes.StoreKey, key []byte, value []byte, delete bool) error {
+	l.values = append(l.values, string(value))
+	return nil
+}
+
+func TestProcessAllWithWriteListeners(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx reads the shared key, appends its index and writes it back
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		kv.Set(itemKey, []byte(val+fmt.Sprintf("%d", ctx.TxIndex())))
+		return types.ResponseDeliverTx{}
+	}
+
+	listener := &recordingWriteListener{}
+	s := NewScheduler(10, ti, deliverTx, WithWriteListeners(map[sdk.StoreKey][]storetypes.WriteListener{
+		testStoreKey: {listener},
+	}))
+	ctx := initTestCtx(true)
+	_, err := s.ProcessAll(ctx, requestList(20))
+	require.NoError(t, err)
+
+	// the final write of every tx is streamed in tx order, regardless of the order txs executed in
+	expected := ""
+	require.Len(t, listener.values, 20)
+	for idx, value := range listener.values {
+		expected = expected + fmt.Sprintf("%d", idx)
+		require.Equal(t, expected, value)
+	}
+	require.Equal(t, expected, string(ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey)))
+}
+
+func TestStatusString(t *testing.T) {
+	require.Equal(t, "pending", statusPending.String())
+	require.Equal(t, "executed", statusExecuted.String())
+	require.Equal(t, "aborted", statusAborted.String())
+	require.Equal(t, "validated", statusValidated.String())
+	require.Equal(t, "waiting", statusWaiting.String())
+	require.Equal(t, "status(-1)", status(-1).String())
+	require.False(t, isKnownStatus(status(-1)))
+}
+
+func TestTaskTryPreAbort(t *testing.T) {
+	task := &deliverTxTask{}
+	require.True(t, task.IsStatus(statusPending))
+
+	// only executed and validated tasks can be pre-aborted
+	for _, st := range []status{statusPending, statusAborted, statusWaiting} {
+		task.restoreStatus(st)
+		require.False(t, task.TryPreAbort())
+		require.True(t, task.IsStatus(st))
+	}
+
+	// concurrent pre-aborts transition the task exactly once
+	for _, st := range []status{statusExecuted, statusValidated} {
+		task.restoreStatus(st)
+		var wg sync.WaitGroup
+		var transitions int64
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if task.TryPreAbort() {
+					atomic.AddInt64(&transitions, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, int64(1), transitions)
+		require.Equal(t, statusPending, task.LoadStatus())
+	}
+}
+
+func TestProcessAllWithConcurrentStoreAccess(t *testing.T) {
+	ti := newTestTracingInfo()
+	const goroutines = 4
+
+	// every tx reads the shared key from goroutines of its own, each writing what it read to a key of its own, and then
+	// appends its index to the shared key. Aborts hit by the goroutines are handed back to the tx.
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		var wg sync.WaitGroup
+		panics := make(chan interface{}, goroutines)
+		for g := 0; g < goroutines; g++ {
+			g := g
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() {
+					if r := recover(); r != nil {
+						panics <- r
+					}
+				}()
+				kv.Set([]byte(fmt.Sprintf("%d-%d", ctx.TxIndex(), g)), kv.Get(itemKey))
+			}()
+		}
+		wg.Wait()
+		close(panics)
+		if r, ok := <-panics; ok {
+			panic(r)
+		}
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	s := NewScheduler(8, ti, deliverTx, WithConcurrentStoreAccess(), WithTxLimiter(func() multiversion.Limiter {
+		return multiversion.NewOperationLimiter(0, 0)
+	}))
+	ctx := initTestCtx(true)
+	res, err := s.ProcessAll(ctx, requestList(30))
+	require.NoError(t, err)
+
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	expected := ""
+	for idx, response := range res {
+		for g := 0; g < goroutines; g++ {
+			require.Equal(t, expected, string(kv.Get([]byte(fmt.Sprintf("%d-%d", idx, g)))))
+		}
+		expected = expected + fmt.Sprintf("%d", idx)
+		require.Equal(t, expected, response.Info)
+	}
+	require.Equal(t, expected, string(kv.Get(itemKey)))
+}
+
+// resolveRecorder records the conflicts it resolves, re-running every conflicting task right away
+type resolveRecorder struct {
+	mx       sync.Mutex
+	resolved []int
+}
+
+func (r *resolveRecorder) Resolve(conflict Conflict) ConflictDecision {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.resolved = append(r.resolved, conflict.Index)
+	return DecisionRerun
+}
+
+func TestValidateAllResolvesInIndexOrder(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	const txs = 8
+	policy := &resolveRecorder{}
+	s := NewScheduler(4, ti, nil, WithConflictPolicy(policy)).(*scheduler)
+	s.metrics = &schedulerMetrics{}
+	s.initMultiVersionStore(initTestCtx(true))
+	tasks := toTasks(requestList(txs))
+	s.allTasks = tasks
+	s.wakeups = newWakeups()
+
+	pool := NewWorkerPool()
+	defer pool.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	var released sync.WaitGroup
+	defer released.Wait()
+	defer cancel()
+	s.validateDispatcher = s.newDispatcher(txs, txs)
+	require.NoError(t, pool.serve(ctx, s.validateDispatcher, txs, "validate", &released))
+
+	// every tx wrote a key of its own after reading a stale value of the key of the tx before it, so all but the first
+	// are invalid, while the checks of their reads are dispatched in reverse
+	mvs := s.multiVersionStores[testStoreKey]
+	for i, task := range tasks {
+		key := fmt.Sprintf("key%d", i)
+		mvs.SetWriteset(i, 0, multiversion.WriteSet{key: []byte("new")})
+		if i > 0 {
+			mvs.SetReadset(i, multiversion.ReadSet{fmt.Sprintf("key%d", i-1): {[]byte("stale")}})
+		}
+		task.SetStatus(statusExecuted)
+	}
+	s.faults = &faultInjector{reorderValidation: func(tasks []*deliverTxTask) {
+		for i, j := 0, len(tasks)-1; i < j; i, j = i+1, j-1 {
+			tasks[i], tasks[j] = tasks[j], tasks[i]
+		}
+	}}
+
+	rerun, err := s.validateAll(initTestCtx(true), tasks)
+	require.NoError(t, err)
+	expected := make([]int, 0, txs-1)
+	for i := 1; i < txs; i++ {
+		expected = append(expected, i)
+	}
+	require.Equal(t, expected, policy.resolved)
+	indices := make([]int, 0, len(rerun))
+	for _, task := range rerun {
+		indices = append(indices, task.Index)
+		require.Equal(t, 1, task.Incarnation)
+	}
+	require.Equal(t, expected, indices)
+	require.True(t, tasks[0].IsStatus(statusValidated))
+	require.Equal(t, 1, s.maxIncarnation)
+}
+
+func TestProcessAllReusesTaskStores(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	const txs = 30
+	var mx sync.Mutex
+	stores := make(map[int]map[sdk.KVStore]struct{})
+	executions := make(map[int]int)
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		mx.Lock()
+		if stores[ctx.TxIndex()] == nil {
+			stores[ctx.TxIndex()] = make(map[sdk.KVStore]struct{})
+		}
+		stores[ctx.TxIndex()][kv] = struct{}{}
+		executions[ctx.TxIndex()]++
+		mx.Unlock()
+		count, _ := strconv.Atoi(string(kv.Get(itemKey)))
+		time.Sleep(50 * time.Microsecond)
+		kv.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		return types.ResponseDeliverTx{Info: strconv.Itoa(count)}
+	}
+
+	res, err := VerifySequential(initTestCtx(true), requestList(txs), 10, ti, deliverTx)
+	require.NoError(t, err)
+	for i, r := range res {
+		require.Equal(t, strconv.Itoa(i), r.Info)
+	}
+
+	// every incarnation of a tx ran against the same version indexed store, reset in between
+	reexecuted := 0
+	for i := 0; i < txs; i++ {
+		require.Len(t, stores[i], 2, "tx %d", i) // the parallel and sequential blocks
+		if executions[i] > 2 {
+			reexecuted++
+		}
+	}
+	require.NotZero(t, reexecuted)
+}
@@ -0,0 +1,80 @@
+package tasks
+
+import (
+	"sort"
+
+	"github.com/tendermint/tendermint/abci/types"
+	"go.opentelemetry.io/otel/trace"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// SequentialOnlyFunc returns true if a tx must never execute in parallel with other txs, eg. governance param changes,
+// upgrades or oracle aggregation
+type SequentialOnlyFunc func(req *sdk.DeliverTxEntry) bool
+
+// WithSequentialOnly pins the txs matching isSequentialOnly to sequential execution: each of them is a barrier that
+// only executes once every tx before it is validated, which makes them final, and the txs after it only execute once
+// it's validated in turn, so that they observe its final writes. Executions held back by a barrier are aborted with a
+// dependency on the tx they wait for, before running. Blocks with sequential-only txs don't run on the happy path,
+// since it doesn't validate reads.
+func WithSequentialOnly(isSequentialOnly SequentialOnlyFunc) SchedulerOption {
+	return func(s *scheduler) { s.isSequentialOnly = isSequentialOnly }
+}
+
+// markSequentialOnly flags the tasks of the requests matching the sequential-only predicate, in index order
+func (s *scheduler) markSequentialOnly(reqs []*sdk.DeliverTxEntry, tasks []*deliverTxTask) {
+	if s.isSequentialOnly == nil {
+		return
+	}
+	for i, req := range reqs {
+		if s.isSequentialOnly(req) {
+			tasks[i].SequentialOnly = true
+			s.sequentialOnly = append(s.sequentialOnly, tasks[i].Index)
+			s.metrics.sequentialOnlyTxs++
+		}
+	}
+}
+
+// heldAtBarrier returns the tx the execution of task has to wait for, if any: the first tx before it that isn't
+// validated if it's sequential-only, or the closest sequential-only tx before it if that one isn't validated. Under
+// synchronous execution, txs already execute one at a time in index order, so nothing is held back.
+func (s *scheduler) heldAtBarrier(task *deliverTxTask) (int, bool) {
+	if len(s.sequentialOnly) == 0 || s.synchronous {
+		return 0, false
+	}
+	if task.SequentialOnly {
+		for _, t := range s.allTasks[:task.Index] {
+			if !t.IsStatus(statusValidated) {
+				return t.Index, true
+			}
+		}
+		return 0, false
+	}
+	i := sort.SearchInts(s.sequentialOnly, task.Index)
+	if i == 0 {
+		return 0, false
+	}
+	barrier := s.sequentialOnly[i-1]
+	if !s.allTasks[barrier].IsStatus(statusValidated) {
+		return barrier, true
+	}
+	return 0, false
+}
+
+// deliverTxPastBarrier runs deliverTx for the task, unless it's held back by a sequential-only tx, in which case the
+// execution is aborted without running, with a dependency on the tx it waits for
+func (s *scheduler) deliverTxPastBarrier(span trace.Span, task *deliverTxTask) (types.ResponseDeliverTx, bool) {
+	dependency, held := s.heldAtBarrier(task)
+	if !held {
+		return s.deliverTxWithTimeout(span, task)
+	}
+	abort := occ.NewSequentialBarrierAbort(dependency)
+	select {
+	case task.AbortCh <- abort:
+	default:
+	}
+	return sdkerrors.ResponseDeliverTx(sdkerrors.Wrap(sdkerrors.ErrOCCAbort, abort.Error()), 0, 0, false), false
+}
@@ -0,0 +1,106 @@
+package tasks
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestProcessAllSequentialOnly(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every fifth tx is pinned to sequential execution, like a param change every other tx reads
+	isSequentialOnly := func(req *sdk.DeliverTxEntry) bool {
+		idx, _ := strconv.Atoi(string(req.Request.Tx))
+		return idx%5 == 2
+	}
+	var running, overlaps int32
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		concurrent := atomic.AddInt32(&running, 1) > 1
+		defer atomic.AddInt32(&running, -1)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if !isSequentialOnly(&sdk.DeliverTxEntry{Request: req}) {
+			kv.Set(req.Tx, kv.Get(itemKey))
+			return types.ResponseDeliverTx{Info: string(kv.Get(itemKey))}
+		}
+		time.Sleep(time.Millisecond)
+		if concurrent || atomic.LoadInt32(&running) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		kv.Set(itemKey, req.Tx)
+		return types.ResponseDeliverTx{Info: string(req.Tx)}
+	}
+
+	const txs = 20
+	ctx := initTestCtx(true)
+	s := NewScheduler(10, ti, deliverTx, WithSequentialOnly(isSequentialOnly))
+	res, err := s.ProcessAll(ctx, requestList(txs))
+	require.NoError(t, err)
+
+	// every tx observes the param of the last sequential-only tx before it, and none of them overlapped another tx
+	for i, r := range res {
+		expected := ""
+		if i >= 2 {
+			expected = strconv.Itoa(i - (i+3)%5)
+		}
+		require.Equal(t, expected, r.Info, "tx %d", i)
+	}
+	require.Zero(t, atomic.LoadInt32(&overlaps))
+	metrics := s.Metrics()
+	require.Equal(t, 4, metrics.SequentialOnlyTxs)
+	require.False(t, metrics.Synchronous)
+	require.NotZero(t, metrics.AbortReasons[occ.AbortReasonSequentialBarrier.String()])
+
+	// the block matches its sequential execution, on the small block path too
+	_, err = VerifySequential(initTestCtx(true), requestList(txs), 10, ti, deliverTx, WithSequentialOnly(isSequentialOnly))
+	require.NoError(t, err)
+	_, err = VerifySequential(initTestCtx(true), requestList(txs), 10, ti, deliverTx,
+		WithSequentialOnly(isSequentialOnly), WithSmallBlockThreshold(txs+1))
+	require.NoError(t, err)
+}
+
+func TestHeldAtBarrier(t *testing.T) {
+	s := NewScheduler(1, nil, nil, WithSequentialOnly(func(req *sdk.DeliverTxEntry) bool {
+		return string(req.Request.Tx) == "2"
+	})).(*scheduler)
+	s.metrics = &schedulerMetrics{}
+	reqs := requestList(5)
+	s.allTasks = toTasks(reqs)
+	s.markSequentialOnly(reqs, s.allTasks)
+	require.Equal(t, []int{2}, s.sequentialOnly)
+
+	// txs before the barrier are never held back
+	_, held := s.heldAtBarrier(s.allTasks[1])
+	require.False(t, held)
+
+	// the barrier waits for the first tx before it that isn't validated
+	s.allTasks[0].restoreStatus(statusValidated)
+	dependency, held := s.heldAtBarrier(s.allTasks[2])
+	require.True(t, held)
+	require.Equal(t, 1, dependency)
+	s.allTasks[1].restoreStatus(statusValidated)
+	_, held = s.heldAtBarrier(s.allTasks[2])
+	require.False(t, held)
+
+	// txs after the barrier wait for it to be validated
+	dependency, held = s.heldAtBarrier(s.allTasks[4])
+	require.True(t, held)
+	require.Equal(t, 2, dependency)
+	s.allTasks[2].restoreStatus(statusValidated)
+	_, held = s.heldAtBarrier(s.allTasks[4])
+	require.False(t, held)
+
+	// synchronous execution holds nothing back
+	s.allTasks[2].restoreStatus(statusExecuted)
+	s.synchronous = true
+	_, held = s.heldAtBarrier(s.allTasks[4])
+	require.False(t, held)
+}
@@ -0,0 +1,65 @@
+package tasks
+
+import (
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// BlockSimulation is the outcome of simulating a prospective block with SimulateBlock
+type BlockSimulation struct {
+	Responses []types.ResponseDeliverTx
+	// Writesets and Readsets are the final writesets and readsets of each tx, by store key, which can be used as the
+	// EstimatedWritesets and EstimatedReadsets of the txs when the block is processed, or to order them so that they
+	// conflict less. Readsets are empty if the block ran on the happy path, which doesn't track reads.
+	Writesets []sdk.MappedWritesets
+	Readsets  []sdk.MappedReadsets
+}
+
+// SimulateBlock processes a prospective block like ProcessAll, against a branch of ctx's multistore that is discarded
+// once it's done, so that nothing is written to ctx's multistore, to write listeners or to debug dumps. It lets a
+// proposer learn the responses and access sets of the txs of a block it's building, eg. in PrepareProposal, to order
+// them. Like ProcessAll, it must not be called concurrently with other blocks of the scheduler, and Metrics reports
+// the simulation until the next block.
+func (s *scheduler) SimulateBlock(ctx sdk.Context, reqs []*sdk.DeliverTxEntry) (*BlockSimulation, error) {
+	s.simulation = &BlockSimulation{}
+	defer func() { s.simulation = nil }()
+	responses, err := s.ProcessAll(ctx.WithMultiStore(ctx.MultiStore().CacheMultiStore()), reqs)
+	if err != nil {
+		return nil, err
+	}
+	simulation := s.simulation
+	simulation.Responses = responses
+	return simulation, nil
+}
+
+// recordSimulation records the final access sets of the tasks of a simulated block
+func (s *scheduler) recordSimulation(tasks []*deliverTxTask) {
+	s.simulation.Writesets = make([]sdk.MappedWritesets, len(tasks))
+	s.simulation.Readsets = make([]sdk.MappedReadsets, len(tasks))
+	for i, task := range tasks {
+		writesets := make(sdk.MappedWritesets, len(task.VersionStores))
+		readsets := make(sdk.MappedReadsets, len(task.VersionStores))
+		for storeKey, vs := range task.VersionStores {
+			if writeset := vs.GetWriteset(); len(writeset) > 0 {
+				copied := make(multiversion.WriteSet, len(writeset))
+				for key, value := range writeset {
+					copied[key] = sdk.CopyBytes(value)
+				}
+				writesets[storeKey] = copied
+			}
+			if readset := vs.GetReadset(); len(readset) > 0 {
+				copied := make(multiversion.ReadSet, len(readset))
+				for key, values := range readset {
+					for _, value := range values {
+						copied[key] = append(copied[key], sdk.CopyBytes(value))
+					}
+				}
+				readsets[storeKey] = copied
+			}
+		}
+		s.simulation.Writesets[i] = writesets
+		s.simulation.Readsets[i] = readsets
+	}
+}
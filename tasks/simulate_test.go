@@ -0,0 +1,63 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestSimulateBlock(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the shared key, and records its own key
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		kv.Set(itemKey, []byte(val+fmt.Sprintf("%d,", ctx.TxIndex())))
+		kv.Set([]byte(fmt.Sprintf("tx-%d", ctx.TxIndex())), req.Tx)
+		return types.ResponseDeliverTx{Info: val}
+	}
+
+	listener := &recordingWriteListener{}
+	s := NewScheduler(10, ti, deliverTx, WithWriteListeners(map[sdk.StoreKey][]storetypes.WriteListener{
+		testStoreKey: {listener},
+	}))
+	ctx := initTestCtx(true)
+	simulation, err := s.SimulateBlock(ctx, requestList(20))
+	require.NoError(t, err)
+
+	// nothing is written or streamed
+	require.Nil(t, ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey))
+	require.Empty(t, listener.values)
+
+	require.Len(t, simulation.Responses, 20)
+	require.Len(t, simulation.Writesets, 20)
+	require.Len(t, simulation.Readsets, 20)
+	expected := ""
+	for idx := range simulation.Responses {
+		require.Equal(t, expected, simulation.Responses[idx].Info)
+		expected += fmt.Sprintf("%d,", idx)
+		writeset := simulation.Writesets[idx][testStoreKey]
+		require.Equal(t, []byte(expected), writeset[string(itemKey)])
+		require.Equal(t, []byte(fmt.Sprintf("%d", idx)), writeset[fmt.Sprintf("tx-%d", idx)])
+		require.Len(t, simulation.Readsets[idx][testStoreKey][string(itemKey)], 1)
+	}
+
+	// the learned writesets can be used as estimates of the block, which then processes to the simulated results
+	reqs := requestList(20)
+	for idx, req := range reqs {
+		req.EstimatedWritesets = simulation.Writesets[idx]
+	}
+	res, err := s.ProcessAll(ctx, reqs)
+	require.NoError(t, err)
+	require.Equal(t, simulation.Responses, res)
+	// both keys of every tx are streamed
+	require.Len(t, listener.values, 40)
+	require.Equal(t, expected, string(ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey)))
+}
@@ -0,0 +1,67 @@
+package tasks
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// defaultSmallBlockThreshold is the default number of txs below which a block runs on the small block path
+const defaultSmallBlockThreshold = 8
+
+// WithSmallBlockThreshold sets the number of txs below which a block skips the worker pools and executes on the
+// calling goroutine, validating each tx inline. A threshold of 0 disables the small block path.
+func WithSmallBlockThreshold(threshold int) SchedulerOption {
+	return func(s *scheduler) { s.smallBlockThreshold = threshold }
+}
+
+// useSmallBlockPath returns true if a block of the given tasks should run on the small block path. Blocks that accept
+// appended tasks always use the worker pools, since they may grow beyond the threshold.
+func (s *scheduler) useSmallBlockPath(tasks []*deliverTxTask) bool {
+	return len(tasks) > 0 && len(tasks) < s.smallBlockThreshold && !s.appendEnabled
+}
+
+// executeSmallBlock executes the tasks of a small block on the calling goroutine in index order, validating each task
+// right after it executes. For blocks this small the cost of handing txs to worker goroutines outweighs what little
+// parallelism there is, so this gives sequential-like latency while still going through the version indexed stores,
+// which keeps the flush to the parent stores, gas accounting and metrics the same as under OCC. Every lower-index tx
+// is final by the time a tx executes, so it can only fail validation if it isn't deterministic. It returns false if a
+// task aborted or failed validation, or if the block was interrupted, in which case that task and every task after it
+// are left for the full OCC path.
+func (s *scheduler) executeSmallBlock(ctx sdk.Context, tasks []*deliverTxTask) bool {
+	for _, task := range tasks {
+		if s.interrupted() != nil {
+			return false
+		}
+		eCtx, eSpan := s.traceSpan(ctx, "SchedulerExecuteSmallBlock", task)
+		task.Ctx = eCtx
+		s.metrics.concurrency.start()
+		s.executeTask(task)
+		s.metrics.concurrency.done()
+		eSpan.End()
+		if !task.IsStatus(statusExecuted) {
+			return false
+		}
+		if valid, conflicts := s.findConflicts(task); !valid || len(conflicts) > 0 {
+			s.metrics.recordConflicts(task.Index, conflicts)
+			s.recordTaskAbort(task, conflicts, nil)
+			return false
+		}
+		task.SetStatus(statusValidated)
+	}
+	return true
+}
+
+// smallBlockLeftovers returns the tasks the small block path didn't validate, reset for the next execution
+func (s *scheduler) smallBlockLeftovers(tasks []*deliverTxTask) []*deliverTxTask {
+	leftovers := filterTasks(tasks, func(t *deliverTxTask) bool { return !t.IsStatus(statusValidated) })
+	for _, t := range leftovers {
+		if t.IsStatus(statusPending) {
+			continue
+		}
+		t.Reset()
+		t.Increment()
+		if t.Incarnation > s.maxIncarnation {
+			s.maxIncarnation = t.Incarnation
+		}
+	}
+	return leftovers
+}
@@ -0,0 +1,87 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllSmallBlock(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx reads the shared key, appends its index and writes it back
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	for _, tc := range []struct {
+		txs        int
+		smallBlock bool
+	}{
+		{txs: 1, smallBlock: true},
+		{txs: defaultSmallBlockThreshold - 1, smallBlock: true},
+		{txs: defaultSmallBlockThreshold, smallBlock: false},
+	} {
+		t.Run(fmt.Sprintf("%d txs", tc.txs), func(t *testing.T) {
+			s := NewScheduler(10, ti, deliverTx)
+			ctx := initTestCtx(true)
+			res, err := s.ProcessAll(ctx, requestList(tc.txs))
+			require.NoError(t, err)
+
+			expected := ""
+			for idx, response := range res {
+				expected = expected + fmt.Sprintf("%d", idx)
+				require.Equal(t, expected, response.Info)
+			}
+			require.Equal(t, expected, string(ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey)))
+
+			m := s.Metrics()
+			require.Equal(t, tc.smallBlock, m.SmallBlock)
+			if tc.smallBlock {
+				// txs ran one at a time, in order, without conflicts
+				require.Equal(t, 1, m.MaxConcurrency)
+				require.Zero(t, m.Retries)
+				require.Zero(t, m.MaxIncarnation)
+				require.Empty(t, m.Conflicts)
+			}
+		})
+	}
+}
+
+func TestProcessAllSmallBlockFallsBackOnInvalidation(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	ctx := initTestCtx(true)
+	parent := ctx.MultiStore().GetKVStore(testStoreKey)
+	// tx 1 changes the parent store behind the scheduler's back on its first execution, so it fails inline validation
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		if ctx.TxIndex() == 1 && val == "" {
+			parent.Set(itemKey, []byte("changed"))
+		}
+		kv.Set([]byte(fmt.Sprintf("key-%d", ctx.TxIndex())), []byte(val))
+		return types.ResponseDeliverTx{Info: val}
+	}
+
+	s := NewScheduler(10, ti, deliverTx)
+	res, err := s.ProcessAll(ctx, requestList(4))
+	require.NoError(t, err)
+	require.Equal(t, "", res[0].Info)
+	for _, response := range res[1:] {
+		require.Equal(t, "changed", response.Info)
+	}
+
+	m := s.Metrics()
+	require.False(t, m.SmallBlock)
+	require.Equal(t, []int{0, 1, 0, 0}, m.Incarnations)
+}
@@ -0,0 +1,54 @@
+package tasks
+
+import (
+	"math/rand"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// SpotCheckDiscrepancy is a validated tx that failed its spot check against the final state of its block
+type SpotCheckDiscrepancy struct {
+	// Index and Incarnation identify the tx and its final execution
+	Index       int
+	Incarnation int
+	// Conflicts are the lower-index txs whose final writes conflict with the tx's reads
+	Conflicts []int
+}
+
+// WithValidationSpotChecks makes the scheduler re-validate a random sample of the block's txs once they're all
+// validated, against the final state of the multiversion stores, to catch rare race bugs in validation on canary
+// nodes at a fraction of the cost of shadow sequential execution. Each tx is sampled with probability sampleRate.
+// A tx failing its spot check means the block may have diverged from sequential execution, so it's logged as an
+// error, counted in telemetry and the block's metrics, and reported to onDiscrepancy if set. The results of the block
+// are left as they are, so nodes with spot checks enabled stay in consensus with the ones without.
+func WithValidationSpotChecks(sampleRate float64, onDiscrepancy func(height int64, discrepancy SpotCheckDiscrepancy)) SchedulerOption {
+	return func(s *scheduler) {
+		s.spotCheckRate = sampleRate
+		s.onSpotCheckDiscrepancy = onDiscrepancy
+	}
+}
+
+// spotCheckValidations re-validates a sample of the validated tasks, if spot checks are enabled. Blocks that ran on
+// the happy path don't track reads, so there's nothing to check.
+func (s *scheduler) spotCheckValidations(ctx sdk.Context, tasks []*deliverTxTask) {
+	if s.spotCheckRate <= 0 || s.metrics.happyPath {
+		return
+	}
+	for _, t := range tasks {
+		if !t.IsStatus(statusValidated) || rand.Float64() >= s.spotCheckRate {
+			continue
+		}
+		s.metrics.spotChecks++
+		valid, conflicts := s.findConflicts(t)
+		if valid {
+			continue
+		}
+		s.metrics.spotCheckFailures++
+		telemetry.IncrCounter(1, "scheduler", "spot_check", "failures")
+		ctx.Logger().Error("occ validation spot check failed", "height", ctx.BlockHeight(), "index", t.Index, "incarnation", t.Incarnation, "conflicts", conflicts)
+		if s.onSpotCheckDiscrepancy != nil {
+			s.onSpotCheckDiscrepancy(ctx.BlockHeight(), SpotCheckDiscrepancy{Index: t.Index, Incarnation: t.Incarnation, Conflicts: conflicts})
+		}
+	}
+}
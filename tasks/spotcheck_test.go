@@ -0,0 +1,69 @@
+package tasks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllValidationSpotChecks(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		kv.Set(itemKey, append(kv.Get(itemKey), req.Tx...))
+		return types.ResponseDeliverTx{Info: string(req.Tx)}
+	}
+
+	var discrepancies []SpotCheckDiscrepancy
+	onDiscrepancy := func(_ int64, d SpotCheckDiscrepancy) { discrepancies = append(discrepancies, d) }
+	s := NewScheduler(10, ti, deliverTx, WithValidationSpotChecks(1, onDiscrepancy)).(*scheduler)
+	_, err := s.ProcessAll(initTestCtx(true), requestList(50))
+	require.NoError(t, err)
+	require.Equal(t, 50, s.Metrics().SpotChecks)
+	require.Zero(t, s.Metrics().SpotCheckFailures)
+	require.Empty(t, discrepancies)
+
+	// nothing is checked with a zero sample rate
+	s = NewScheduler(10, ti, deliverTx, WithValidationSpotChecks(0, onDiscrepancy)).(*scheduler)
+	_, err = s.ProcessAll(initTestCtx(true), requestList(50))
+	require.NoError(t, err)
+	require.Zero(t, s.Metrics().SpotChecks)
+}
+
+func TestSpotCheckValidationsDetectsDiscrepancy(t *testing.T) {
+	var discrepancies []SpotCheckDiscrepancy
+	s := NewScheduler(1, nil, nil, WithValidationSpotChecks(1, func(_ int64, d SpotCheckDiscrepancy) {
+		discrepancies = append(discrepancies, d)
+	})).(*scheduler)
+	s.metrics = &schedulerMetrics{}
+	ctx := initTestCtx(true)
+	s.initMultiVersionStore(ctx)
+
+	// tx 1 was validated against a value of tx 0 that isn't its final one, as a racy validation could
+	mvs := s.multiVersionStores[testStoreKey]
+	mvs.SetWriteset(0, 1, multiversion.WriteSet{string(itemKey): []byte("final")})
+	mvs.SetReadset(1, multiversion.ReadSet{string(itemKey): {[]byte("stale")}})
+	mvs.SetWriteset(2, 0, multiversion.WriteSet{string(itemKey): []byte("other")})
+	mvs.SetReadset(2, multiversion.ReadSet{string(itemKey): {[]byte("final")}})
+	tasks := toTasks(requestList(3))
+	for _, task := range tasks {
+		task.restoreStatus(statusValidated)
+	}
+	tasks[1].Incarnation = 2
+
+	s.spotCheckValidations(ctx, tasks)
+	require.Equal(t, 3, s.metrics.spotChecks)
+	require.Equal(t, 1, s.metrics.spotCheckFailures)
+	require.Equal(t, []SpotCheckDiscrepancy{{Index: 1, Incarnation: 2, Conflicts: []int{0}}}, discrepancies)
+
+	// blocks on the happy path have no reads to check
+	s.metrics = &schedulerMetrics{happyPath: true}
+	s.spotCheckValidations(ctx, tasks)
+	require.Zero(t, s.metrics.spotChecks)
+}
@@ -0,0 +1,135 @@
+package tasks
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+)
+
+// status is the state of a task. It's an int32 so that it can be read and transitioned atomically, without taking the
+// task's lock or comparing strings in the scheduler's hot loops.
+type status int32
+
+const (
+	// statusPending tasks are ready for execution
+	// all executing tasks are in pending state
+	statusPending status = iota
+	// statusExecuted tasks are ready for validation
+	// these tasks did not abort during execution
+	statusExecuted
+	// statusAborted means the task has been aborted
+	// these tasks transition to pending upon next execution
+	statusAborted
+	// statusValidated means the task has been validated
+	// tasks in this status can be reset if an earlier task fails validation
+	statusValidated
+	// statusWaiting tasks are waiting for another tx to complete
+	statusWaiting
+)
+
+var statusNames = [...]string{
+	statusPending:   "pending",
+	statusExecuted:  "executed",
+	statusAborted:   "aborted",
+	statusValidated: "validated",
+	statusWaiting:   "waiting",
+}
+
+// String returns the name of the status, for logs
+func (st status) String() string {
+	if st >= 0 && int(st) < len(statusNames) {
+		return statusNames[st]
+	}
+	return "status(" + strconv.Itoa(int(st)) + ")"
+}
+
+// statusTransitions are the statuses each status can transition to, as bitsets. Every task can be reset to pending to
+// be re-executed, an execution either finishes executed or aborted, and only executed tasks are validated. A
+// validated task is revalidated, pre-aborted or invalidated, but never goes back to executed without being re-executed.
+var statusTransitions = [...]uint8{
+	statusPending:   statusSet(statusPending, statusExecuted, statusAborted),
+	statusExecuted:  statusSet(statusPending, statusValidated, statusAborted, statusWaiting),
+	statusAborted:   statusSet(statusPending, statusWaiting),
+	statusValidated: statusSet(statusPending, statusValidated, statusAborted, statusWaiting),
+	statusWaiting:   statusSet(statusPending),
+}
+
+func statusSet(statuses ...status) uint8 {
+	var set uint8
+	for _, st := range statuses {
+		set |= 1 << st
+	}
+	return set
+}
+
+// canTransitionTo returns true if a task can transition from st to next
+func (st status) canTransitionTo(next status) bool {
+	return isKnownStatus(st) && isKnownStatus(next) && statusTransitions[st]&(1<<next) != 0
+}
+
+// illegalTransition is the panic value of an illegal status transition of a task, which is always a scheduler bug
+type illegalTransition struct {
+	index    int
+	from, to status
+}
+
+func (e illegalTransition) Error() string {
+	return fmt.Sprintf("illegal status transition of task %d from %s to %s", e.index, e.from, e.to)
+}
+
+// LoadStatus returns the task's current status
+func (dt *deliverTxTask) LoadStatus() status {
+	return status(atomic.LoadInt32((*int32)(&dt.Status)))
+}
+
+func (dt *deliverTxTask) IsStatus(s status) bool {
+	return dt.LoadStatus() == s
+}
+
+// SetStatus transitions the task from its current status to s, panicking if the transition is illegal
+func (dt *deliverTxTask) SetStatus(s status) {
+	for {
+		current := dt.LoadStatus()
+		if !current.canTransitionTo(s) {
+			dt.illegalTransition(current, s)
+		}
+		if atomic.CompareAndSwapInt32((*int32)(&dt.Status), int32(current), int32(s)) {
+			return
+		}
+	}
+}
+
+// CompareAndSetStatus transitions the task from status from to status to if it's still in status from, returning
+// whether the transition happened. It panics if the transition is illegal, whatever the task's current status.
+func (dt *deliverTxTask) CompareAndSetStatus(from, to status) bool {
+	if !from.canTransitionTo(to) {
+		dt.illegalTransition(from, to)
+	}
+	return atomic.CompareAndSwapInt32((*int32)(&dt.Status), int32(from), int32(to))
+}
+
+// TryPreAbort transitions an executed or validated task back to pending, returning whether the transition happened
+func (dt *deliverTxTask) TryPreAbort() bool {
+	for {
+		current := dt.LoadStatus()
+		if current != statusExecuted && current != statusValidated {
+			return false
+		}
+		if dt.CompareAndSetStatus(current, statusPending) {
+			return true
+		}
+	}
+}
+
+// restoreStatus sets the task's status without checking the transition, to restore the bookkeeping of the scheduler
+// to a checkpoint
+func (dt *deliverTxTask) restoreStatus(s status) {
+	atomic.StoreInt32((*int32)(&dt.Status), int32(s))
+}
+
+func (dt *deliverTxTask) illegalTransition(from, to status) {
+	telemetry.IncrCounter(1, "scheduler", "illegal_status_transitions")
+	panic(illegalTransition{index: dt.Index, from: from, to: to})
+}
@@ -0,0 +1,84 @@
+package tasks
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusTransitions(t *testing.T) {
+	legal := map[status][]status{
+		statusPending:   {statusPending, statusExecuted, statusAborted},
+		statusExecuted:  {statusPending, statusValidated, statusAborted, statusWaiting},
+		statusAborted:   {statusPending, statusWaiting},
+		statusValidated: {statusPending, statusValidated, statusAborted, statusWaiting},
+		statusWaiting:   {statusPending},
+	}
+	all := []status{statusPending, statusExecuted, statusAborted, statusValidated, statusWaiting}
+	for _, from := range all {
+		for _, to := range all {
+			expected := false
+			for _, st := range legal[from] {
+				expected = expected || st == to
+			}
+			require.Equal(t, expected, from.canTransitionTo(to), "%s to %s", from, to)
+
+			task := &deliverTxTask{Index: 3}
+			task.restoreStatus(from)
+			if expected {
+				task.SetStatus(to)
+				require.Equal(t, to, task.LoadStatus())
+				continue
+			}
+			// illegal transitions panic, leaving the status untouched
+			require.PanicsWithValue(t, illegalTransition{index: 3, from: from, to: to}, func() { task.SetStatus(to) })
+			require.PanicsWithValue(t, illegalTransition{index: 3, from: from, to: to}, func() { task.CompareAndSetStatus(from, to) })
+			require.Equal(t, from, task.LoadStatus())
+		}
+	}
+
+	// unknown statuses can neither be transitioned from nor to
+	require.False(t, status(-1).canTransitionTo(statusPending))
+	require.False(t, statusPending.canTransitionTo(status(len(statusNames))))
+	require.EqualError(t, illegalTransition{index: 1, from: statusValidated, to: statusExecuted},
+		"illegal status transition of task 1 from validated to executed")
+}
+
+func TestCompareAndSetStatus(t *testing.T) {
+	task := &deliverTxTask{}
+	task.SetStatus(statusExecuted)
+
+	// the transition only happens from the expected status
+	require.False(t, task.CompareAndSetStatus(statusAborted, statusWaiting))
+	require.Equal(t, statusExecuted, task.LoadStatus())
+	require.True(t, task.CompareAndSetStatus(statusExecuted, statusValidated))
+	require.Equal(t, statusValidated, task.LoadStatus())
+
+	// of concurrent validations and pre-aborts, exactly one transitions the validated task
+	for i := 0; i < 100; i++ {
+		task.SetStatus(statusPending)
+		task.SetStatus(statusExecuted)
+		var wg sync.WaitGroup
+		var transitions int64
+		for j := 0; j < 4; j++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				if task.CompareAndSetStatus(statusExecuted, statusValidated) {
+					atomic.AddInt64(&transitions, 1)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				if task.CompareAndSetStatus(statusExecuted, statusAborted) {
+					atomic.AddInt64(&transitions, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, int64(1), transitions)
+		require.Contains(t, []status{statusValidated, statusAborted}, task.LoadStatus())
+	}
+}
@@ -0,0 +1,139 @@
+package tasks
+
+import (
+	"fmt"
+
+	store "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// StoreStrategy is how the scheduler isolates the txs of a block from each other in a store
+type StoreStrategy int
+
+const (
+	// StoreStrategyMultiVersion wraps the store in a multiversion store, so that every tx observes the writes of the
+	// txs before it and its reads are validated, as if the block was executed sequentially. The store must be a KV
+	// store.
+	StoreStrategyMultiVersion StoreStrategy = iota
+	// StoreStrategyPassthroughIsolated gives every execution its own branch of the store as of the start of the block,
+	// and writes the branches of the final incarnations to the store in tx order once the block is done. Txs don't
+	// observe each other's writes and their reads aren't validated, so it's only meant for stores whose txs don't
+	// depend on each other's writes, eg. caches or per-tx scratch space.
+	StoreStrategyPassthroughIsolated
+	// StoreStrategySkip leaves the store alone: txs access the block's store directly, so the writes of every
+	// incarnation are applied right away, in whatever order the txs run. It's only meant for stores that txs don't
+	// write, or whose writes are idempotent.
+	StoreStrategySkip
+	// StoreStrategySynchronized gives every execution its own branch of the store, and orders the accesses of txs to
+	// the store globally: the first access of an execution waits for every lower-index tx to be validated, and the
+	// branches of those txs are written to the store in tx order before it goes ahead, so that every tx observes the
+	// writes of the txs before it, as if the block was executed sequentially. Txs accessing the store are serialized,
+	// so it's meant for stores with known hazards under OCC that few txs access, see WithStoreKeyStrategy.
+	StoreStrategySynchronized
+)
+
+// String returns the name of the strategy
+func (s StoreStrategy) String() string {
+	switch s {
+	case StoreStrategyMultiVersion:
+		return "multiversion"
+	case StoreStrategyPassthroughIsolated:
+		return "passthrough_isolated"
+	case StoreStrategySkip:
+		return "skip"
+	case StoreStrategySynchronized:
+		return "synchronized"
+	default:
+		return fmt.Sprintf("StoreStrategy(%d)", int(s))
+	}
+}
+
+// StoreStrategies maps store types to the strategy used for stores of that type
+type StoreStrategies map[store.StoreType]StoreStrategy
+
+// DefaultStoreStrategies returns the strategies of the SDK's store types. Persistent, transient and memory stores are
+// wrapped in multiversion stores, while multistores, which aren't KV stores, are skipped.
+func DefaultStoreStrategies() StoreStrategies {
+	return StoreStrategies{
+		store.StoreTypeMulti:     StoreStrategySkip,
+		store.StoreTypeDB:        StoreStrategyMultiVersion,
+		store.StoreTypeIAVL:      StoreStrategyMultiVersion,
+		store.StoreTypeTransient: StoreStrategyMultiVersion,
+		store.StoreTypeMemory:    StoreStrategyMultiVersion,
+	}
+}
+
+// WithStoreStrategy sets the strategy of the stores of a type, overriding its default. The type may be one of the
+// app's own, eg. for object stores that aren't KV stores. Stores of types without a strategy are wrapped in
+// multiversion stores.
+func WithStoreStrategy(storeType store.StoreType, strategy StoreStrategy) SchedulerOption {
+	return func(s *scheduler) { s.storeStrategies[storeType] = strategy }
+}
+
+// WithStoreKeyStrategy sets the strategy of a single store, overriding the strategy of its type, eg. to opt a store
+// out of OCC with StoreStrategySynchronized while the rest of the stores remain parallel.
+func WithStoreKeyStrategy(storeKey sdk.StoreKey, strategy StoreStrategy) SchedulerOption {
+	return func(s *scheduler) {
+		if s.storeKeyStrategies == nil {
+			s.storeKeyStrategies = make(map[string]StoreStrategy)
+		}
+		s.storeKeyStrategies[storeKey.Name()] = strategy
+	}
+}
+
+// storeStrategy returns the strategy of a store of a type
+func (s *scheduler) storeStrategy(storeKey sdk.StoreKey, storeType store.StoreType) StoreStrategy {
+	if strategy, ok := s.storeKeyStrategies[storeKey.Name()]; ok {
+		return strategy
+	}
+	if strategy, ok := s.storeStrategies[storeType]; ok {
+		return strategy
+	}
+	return StoreStrategyMultiVersion
+}
+
+// unversionedStore is a store of the block that isn't wrapped in a multiversion store
+type unversionedStore struct {
+	key      sdk.StoreKey
+	store    store.CacheWrap
+	strategy StoreStrategy
+}
+
+// unversionedStores returns the stores an execution of task accesses in place of the unversioned stores of the
+// block, recording the branches of isolated and synchronized stores on the task. Accesses to synchronized stores
+// abort the execution through abortCh until they can go ahead.
+func (s *scheduler) unversionedStores(task *deliverTxTask, abortCh chan occ.Abort) map[sdk.StoreKey]store.CacheWrap {
+	task.IsolatedStores = nil
+	stores := make(map[sdk.StoreKey]store.CacheWrap, len(s.unversioned))
+	for _, us := range s.unversioned {
+		if us.strategy == StoreStrategySkip {
+			stores[us.key] = us.store
+			continue
+		}
+		if task.IsolatedStores == nil {
+			task.IsolatedStores = make(map[sdk.StoreKey]store.CacheWrap)
+		}
+		task.IsolatedStores[us.key] = us.store.CacheWrap(us.key)
+		stores[us.key] = task.IsolatedStores[us.key]
+		if us.strategy == StoreStrategySynchronized {
+			stores[us.key] = s.newSynchronizedStore(task, us.key, task.IsolatedStores[us.key].(store.CacheKVStore), abortCh)
+		}
+	}
+	return stores
+}
+
+// flushIsolatedStores writes the branches of the isolated and synchronized stores of the final incarnation of every
+// task to the block's stores, in tx order, except for the synchronized branches that were already written
+func (s *scheduler) flushIsolatedStores(tasks []*deliverTxTask) {
+	for _, task := range tasks {
+		for _, us := range s.unversioned {
+			if us.strategy == StoreStrategySynchronized && task.Index < s.synchronizedFlushed {
+				continue
+			}
+			if branch, ok := task.IsolatedStores[us.key]; ok {
+				branch.Write()
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/cachekv"
+	"github.com/cosmos/cosmos-sdk/store/cachemulti"
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	store "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+const (
+	isolatedStoreType store.StoreType = 100 + iota
+	skippedStoreType
+)
+
+var (
+	isolatedStoreKey = sdk.NewKVStoreKey("isolated")
+	skippedStoreKey  = sdk.NewKVStoreKey("skipped")
+)
+
+// typedStore is a store of a custom store type
+type typedStore struct {
+	dbadapter.Store
+	storeType store.StoreType
+}
+
+func (s typedStore) GetStoreType() store.StoreType {
+	return s.storeType
+}
+
+// initStrategiesTestCtx returns a context with the test store, and stores of the isolated and skipped custom types
+func initStrategiesTestCtx() sdk.Context {
+	ctx := sdk.Context{}.WithContext(context.Background())
+	db := dbm.NewMemDB()
+	stores := map[sdk.StoreKey]sdk.CacheWrapper{
+		testStoreKey:     cachekv.NewStore(dbadapter.Store{DB: dbm.NewMemDB()}, testStoreKey, 1000),
+		isolatedStoreKey: cachekv.NewStore(typedStore{Store: dbadapter.Store{DB: dbm.NewMemDB()}, storeType: isolatedStoreType}, isolatedStoreKey, 1000),
+		skippedStoreKey:  cachekv.NewStore(typedStore{Store: dbadapter.Store{DB: dbm.NewMemDB()}, storeType: skippedStoreType}, skippedStoreKey, 1000),
+	}
+	keys := make(map[string]sdk.StoreKey)
+	for key := range stores {
+		keys[key.Name()] = key
+	}
+	ms := cachemulti.NewStore(db, stores, keys, nil, nil, nil)
+	return ctx.WithMultiStore(&ms).WithLogger(log.NewNopLogger())
+}
+
+func TestStoreStrategyString(t *testing.T) {
+	require.Equal(t, "multiversion", StoreStrategyMultiVersion.String())
+	require.Equal(t, "passthrough_isolated", StoreStrategyPassthroughIsolated.String())
+	require.Equal(t, "skip", StoreStrategySkip.String())
+	require.Equal(t, "StoreStrategy(42)", StoreStrategy(42).String())
+}
+
+func TestProcessAllStoreStrategies(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	const txs = 20
+	const failingTx = 5
+	var mx sync.Mutex
+	executions := make(map[int]int)
+	var once sync.Once
+	estimateRead := make(chan struct{})
+	// every tx records each of its executions in the isolated store, as well as itself in the skipped store, and then
+	// appends its index to the shared key of the test store. Tx 0 only writes the key once tx 1 has read its prefilled
+	// estimate, so tx 1 is guaranteed to be re-executed.
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(occ.Abort); !ok {
+					panic(r)
+				}
+				once.Do(func() { close(estimateRead) })
+				response = sdkerrors.ResponseDeliverTx(sdkerrors.ErrOCCAbort, 0, 0, false)
+			}
+		}()
+		mx.Lock()
+		executions[ctx.TxIndex()]++
+		execution := executions[ctx.TxIndex()]
+		mx.Unlock()
+
+		isolated := ctx.MultiStore().GetKVStore(isolatedStoreKey)
+		skipped := ctx.MultiStore().GetKVStore(skippedStoreKey)
+		if _, ok := isolated.(*multiversion.VersionIndexedStore); ok {
+			panic("isolated store is a version indexed store")
+		}
+		// txs don't observe each other's writes to the isolated store
+		if shared := isolated.Get([]byte("shared")); shared != nil {
+			panic(fmt.Sprintf("tx %d observed %s", ctx.TxIndex(), shared))
+		}
+		isolated.Set([]byte("shared"), req.Tx)
+		isolated.Set([]byte(fmt.Sprintf("execution-%d-%d", ctx.TxIndex(), execution)), req.Tx)
+		skipped.Set([]byte(fmt.Sprintf("tx-%d", ctx.TxIndex())), req.Tx)
+
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if ctx.TxIndex() == 0 {
+			<-estimateRead
+		}
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		if ctx.TxIndex() == failingTx {
+			panic("failing tx")
+		}
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	reqs := requestList(txs)
+	// estimated writes to stores that aren't multiversion stores are ignored
+	reqs[0].EstimatedWritesets = sdk.MappedWritesets{
+		testStoreKey:     {string(itemKey): nil},
+		isolatedStoreKey: {"shared": nil},
+	}
+
+	s := NewScheduler(10, ti, deliverTx,
+		WithStoreStrategy(isolatedStoreType, StoreStrategyPassthroughIsolated),
+		WithStoreStrategy(skippedStoreType, StoreStrategySkip),
+	)
+	ctx := initStrategiesTestCtx()
+	res, err := s.ProcessAll(ctx, reqs)
+	require.NoError(t, err)
+
+	expected := ""
+	for idx, response := range res {
+		if idx == failingTx {
+			require.NotEqual(t, uint32(0), response.Code)
+			continue
+		}
+		expected += fmt.Sprintf("%d,", idx)
+		require.Equal(t, expected, response.Info)
+	}
+
+	// only the writes of the final incarnation of each tx that didn't fail are written to the isolated store, in tx
+	// order, while the skipped store has every write
+	isolated := ctx.MultiStore().GetKVStore(isolatedStoreKey)
+	skipped := ctx.MultiStore().GetKVStore(skippedStoreKey)
+	require.Equal(t, []byte(fmt.Sprintf("%d", txs-1)), isolated.Get([]byte("shared")))
+	for idx := 0; idx < txs; idx++ {
+		for execution := 1; execution <= executions[idx]; execution++ {
+			key := []byte(fmt.Sprintf("execution-%d-%d", idx, execution))
+			if idx != failingTx && execution == executions[idx] {
+				require.NotNil(t, isolated.Get(key))
+			} else {
+				require.Nil(t, isolated.Get(key))
+			}
+		}
+		require.NotNil(t, skipped.Get([]byte(fmt.Sprintf("tx-%d", idx))))
+	}
+	require.Greater(t, executions[1], 1)
+	require.Contains(t, s.Metrics().ValidationCosts, testStoreKey.Name())
+	require.NotContains(t, s.Metrics().ValidationCosts, isolatedStoreKey.Name())
+}
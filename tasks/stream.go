@@ -0,0 +1,68 @@
+package tasks
+
+import (
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// StreamedResponse is the final response of a tx, streamed by ProcessAllStream
+type StreamedResponse struct {
+	// Index is the index of the tx in the block
+	Index    int
+	Response types.ResponseDeliverTx
+}
+
+// ProcessAllStream behaves like ProcessAll, and additionally sends the final response of every tx to out, in tx order,
+// as soon as it's known: once a validation round leaves a prefix of the block validated, its responses can't change
+// anymore, so they're streamed right away rather than once the whole block is done, eg. to start indexing events
+// early. The responses that are streamed are the same as the ones returned, but they're only final if ProcessAllStream
+// returns without an error, like the state written by the block. Sending blocks the scheduler, so out should be
+// buffered or drained concurrently. out is closed once ProcessAllStream returns.
+func (s *scheduler) ProcessAllStream(ctx sdk.Context, reqs []*sdk.DeliverTxEntry, out chan<- StreamedResponse) ([]types.ResponseDeliverTx, error) {
+	defer close(out)
+	s.stream = out
+	return s.ProcessAll(ctx, reqs)
+}
+
+// streamValidated streams the responses of the validated prefix of the last checkpoint, which rollbacks never
+// invalidate.
+func (s *scheduler) streamValidated() {
+	if s.stream == nil || s.lastCheckpoint == nil || s.lastCheckpoint.validated <= s.streamed {
+		return
+	}
+	tasks := s.allTasks[:s.lastCheckpoint.validated]
+	s.finalizeResponses(tasks)
+	s.streamResponses(tasks)
+}
+
+// finalizeResponses finalizes the responses of the given tasks that weren't yet
+func (s *scheduler) finalizeResponses(tasks []*deliverTxTask) {
+	for _, t := range tasks {
+		s.finalizeResponse(t)
+	}
+}
+
+// finalizeResponse reindexes and orders the events of the final response of a task and runs it through the response
+// processor, once
+func (s *scheduler) finalizeResponse(t *deliverTxTask) {
+	if t.Finalized {
+		return
+	}
+	t.Finalized = true
+	s.reindexEvents(t)
+	s.orderEvents(t)
+	s.processResponse(t)
+}
+
+// streamResponses sends the finalized responses of the given leading tasks of the block that weren't sent yet, if
+// streaming
+func (s *scheduler) streamResponses(tasks []*deliverTxTask) {
+	if s.stream == nil {
+		return
+	}
+	for _, t := range tasks[s.streamed:] {
+		s.stream <- StreamedResponse{Index: t.Index, Response: *t.Response}
+	}
+	s.streamed = len(tasks)
+}
@@ -0,0 +1,97 @@
+package tasks
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// streamedBeforeCompletion records how many responses were streamed by the time the block completed
+type streamedBeforeCompletion struct {
+	BaseSchedulerHooks
+	out      chan StreamedResponse
+	streamed int
+}
+
+func (h *streamedBeforeCompletion) OnBlockComplete(SchedulerMetrics) {
+	h.streamed = len(h.out)
+}
+
+func TestProcessAllStream(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	const txs = 20
+	var once sync.Once
+	estimateRead := make(chan struct{})
+	// every tx appends its index to the shared key, using a fixed amount of gas. Tx 0 only writes the key once tx 1
+	// has read its prefilled estimate, so the block takes more than one round.
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(occ.Abort); !ok {
+					panic(r)
+				}
+				once.Do(func() { close(estimateRead) })
+				response = sdkerrors.ResponseDeliverTx(sdkerrors.ErrOCCAbort, 0, 0, false)
+			}
+		}()
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if ctx.TxIndex() == 0 {
+			<-estimateRead
+		}
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{
+			Info:      newVal,
+			GasWanted: 20,
+			GasUsed:   10,
+			Events:    []types.Event{{Type: "append"}},
+		}
+	}
+
+	var mx sync.Mutex
+	processed := make(map[int]int)
+	processor := func(info TaskInfo, res *types.ResponseDeliverTx) {
+		mx.Lock()
+		defer mx.Unlock()
+		processed[info.Index]++
+		res.Log = fmt.Sprintf("processed %d", info.Index)
+	}
+	out := make(chan StreamedResponse, txs)
+	hooks := &streamedBeforeCompletion{out: out}
+
+	reqs := requestList(txs)
+	reqs[0].EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(itemKey): nil}}
+	s := NewScheduler(10, ti, deliverTx, WithEventOrdering(), WithResponseProcessor(processor), WithSchedulerHooks(hooks))
+	res, err := s.ProcessAllStream(initTestCtx(true), reqs, out)
+	require.NoError(t, err)
+	require.Len(t, res, txs)
+
+	// the validated prefix of the first round is streamed before the block completes, and every response is
+	// streamed in tx order, finalized once
+	require.Positive(t, hooks.streamed)
+	var streamed []StreamedResponse
+	for response := range out {
+		streamed = append(streamed, response)
+	}
+	require.Len(t, streamed, txs)
+	for idx, response := range streamed {
+		require.Equal(t, idx, response.Index)
+		require.Equal(t, res[idx], response.Response)
+		require.Equal(t, 1, processed[idx])
+		require.Equal(t, fmt.Sprintf("processed %d", idx), response.Response.Log)
+		require.Equal(t, EventTypeTxOrder, response.Response.Events[len(response.Response.Events)-1].Type)
+		require.Zero(t, response.Response.Code)
+	}
+
+	// the stream only lasts for the block, so later blocks don't send to the closed channel
+	_, err = s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+}
@@ -0,0 +1,10 @@
+package tasks
+
+// WithStrictWritesets declares that the estimated writesets of the requests are enforced by the deliverTx of the
+// scheduler, for workloads with contractual access lists (eg. EVM txs with mandatory access lists, see
+// baseapp.OCCParams): a tx writing a key outside of them fails without any of its writes. Blocks whose declared
+// writesets are disjoint then run on the no-validation happy path whatever their estimate confidence. The scheduler
+// doesn't fail txs itself, so should a tx write outside of its estimates anyway, the happy path falls back to full OCC.
+func WithStrictWritesets() SchedulerOption {
+	return func(s *scheduler) { s.strictWritesets = true }
+}
@@ -0,0 +1,83 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllStrictWritesets(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	const txs = 20
+	const rogueTx = 3
+	txKey := func(idx int) []byte { return []byte(fmt.Sprintf("tx-%d", idx)) }
+	rogueKey := []byte("rogue")
+	// every tx writes its own key, and the rogue tx optionally also writes a key it didn't declare
+	newDeliverTx := func(rogue bool) func(ctx sdk.Context, req types.RequestDeliverTx) types.ResponseDeliverTx {
+		return func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+			defer abortRecoveryFunc(&response)
+			kv := ctx.MultiStore().GetKVStore(testStoreKey)
+			kv.Set(txKey(ctx.TxIndex()), req.Tx)
+			if rogue && ctx.TxIndex() == rogueTx {
+				kv.Set(rogueKey, req.Tx)
+			}
+			return types.ResponseDeliverTx{GasUsed: 10}
+		}
+	}
+	newRequests := func() []*sdk.DeliverTxEntry {
+		reqs := requestList(txs)
+		for idx, req := range reqs {
+			req.EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {string(txKey(idx)): nil}}
+		}
+		return reqs
+	}
+
+	for _, tc := range []struct {
+		strict bool
+		rogue  bool
+	}{{false, false}, {true, false}, {true, true}} {
+		t.Run(fmt.Sprintf("strict %t rogue %t", tc.strict, tc.rogue), func(t *testing.T) {
+			var opts []SchedulerOption
+			if tc.strict {
+				opts = append(opts, WithStrictWritesets())
+			}
+			s := NewScheduler(10, ti, newDeliverTx(tc.rogue), opts...)
+			ctx := initTestCtx(true)
+			res, err := s.ProcessAll(ctx, newRequests())
+			require.NoError(t, err)
+
+			// the scheduler never fails txs for their writes, which enforcing the declared writesets is left to
+			kv := ctx.MultiStore().GetKVStore(testStoreKey)
+			for idx, response := range res {
+				require.Equal(t, uint32(0), response.Code)
+				require.Equal(t, []byte(fmt.Sprintf("%d", idx)), kv.Get(txKey(idx)))
+			}
+			require.Equal(t, tc.rogue, kv.Get(rogueKey) != nil)
+			// the declared writesets are disjoint, which is only a guarantee in strict mode, and a write outside of
+			// them falls back to full occ
+			require.Equal(t, tc.strict && !tc.rogue, s.Metrics().HappyPath)
+
+			_, err = VerifySequential(initTestCtx(true), newRequests(), 10, ti, newDeliverTx(tc.rogue), opts...)
+			require.NoError(t, err)
+		})
+	}
+
+	t.Run("undeclared txs keep the block off the happy path", func(t *testing.T) {
+		reqs := newRequests()
+		reqs[rogueTx].EstimatedWritesets = nil
+		s := NewScheduler(10, ti, newDeliverTx(true), WithStrictWritesets())
+		ctx := initTestCtx(true)
+		res, err := s.ProcessAll(ctx, reqs)
+		require.NoError(t, err)
+		for _, response := range res {
+			require.Equal(t, uint32(0), response.Code)
+		}
+		require.NotNil(t, ctx.MultiStore().GetKVStore(testStoreKey).Get(rogueKey))
+		require.False(t, s.Metrics().HappyPath)
+	})
+}
@@ -0,0 +1,140 @@
+package tasks
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/cosmos/cosmos-sdk/store/cachekv"
+	"github.com/cosmos/cosmos-sdk/store/listenkv"
+	"github.com/cosmos/cosmos-sdk/store/tracekv"
+	store "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// synchronizedStore is the branch of a store under StoreStrategySynchronized accessed by an execution. Its first
+// operation goes ahead once every lower-index tx is validated, see enter.
+type synchronizedStore struct {
+	store.CacheKVStore
+	s       *scheduler
+	task    *deliverTxTask
+	key     sdk.StoreKey
+	abortCh chan occ.Abort
+	signal  *occ.AbortSignal
+	// entered is set once the execution may access the store, only accessed atomically
+	entered int32
+}
+
+var _ store.CacheKVStore = (*synchronizedStore)(nil)
+
+func (s *scheduler) newSynchronizedStore(task *deliverTxTask, key sdk.StoreKey, branch store.CacheKVStore, abortCh chan occ.Abort) *synchronizedStore {
+	return &synchronizedStore{
+		CacheKVStore: branch,
+		s:            s,
+		task:         task,
+		key:          key,
+		abortCh:      abortCh,
+		signal:       task.AbortSignal,
+	}
+}
+
+// enter lets the execution access the store once every lower-index tx is validated, which makes them final, and
+// their branches were written to the block's store in tx order. Until then, the execution is aborted with a dependency
+// on the first lower-index tx that isn't validated, so that it's executed again once it is.
+func (st *synchronizedStore) enter() {
+	if st.signal != nil {
+		if abort := st.signal.Aborted(); abort != nil {
+			panic(*abort)
+		}
+	}
+	if atomic.LoadInt32(&st.entered) == 1 {
+		return
+	}
+	if dependency, ok := st.s.flushSynchronized(st.task.Index); !ok {
+		abort := occ.NewSynchronizedStoreAbort(dependency, st.key.Name())
+		select {
+		case st.abortCh <- abort:
+		default:
+		}
+		if st.signal != nil {
+			st.signal.Signal(abort)
+		}
+		panic(abort)
+	}
+	atomic.StoreInt32(&st.entered, 1)
+}
+
+// flushSynchronized writes the branches of the synchronized stores of the txs below index that weren't yet written
+// to the block's stores in tx order, if all of them are validated. Otherwise it returns the first one that isn't.
+func (s *scheduler) flushSynchronized(index int) (int, bool) {
+	s.synchronizedMx.Lock()
+	defer s.synchronizedMx.Unlock()
+	tasks := s.allTasks[s.synchronizedFlushed:index]
+	for _, t := range tasks {
+		if !t.IsStatus(statusValidated) {
+			return t.Index, false
+		}
+	}
+	for _, t := range tasks {
+		for _, us := range s.unversioned {
+			if branch, ok := t.IsolatedStores[us.key]; ok && us.strategy == StoreStrategySynchronized {
+				branch.Write()
+			}
+		}
+	}
+	if index > s.synchronizedFlushed {
+		s.synchronizedFlushed = index
+	}
+	return 0, true
+}
+
+// Get implements store.KVStore
+func (st *synchronizedStore) Get(key []byte) []byte {
+	st.enter()
+	return st.CacheKVStore.Get(key)
+}
+
+// Has implements store.KVStore
+func (st *synchronizedStore) Has(key []byte) bool {
+	st.enter()
+	return st.CacheKVStore.Has(key)
+}
+
+// Set implements store.KVStore
+func (st *synchronizedStore) Set(key, value []byte) {
+	st.enter()
+	st.CacheKVStore.Set(key, value)
+}
+
+// Delete implements store.KVStore
+func (st *synchronizedStore) Delete(key []byte) {
+	st.enter()
+	st.CacheKVStore.Delete(key)
+}
+
+// Iterator implements store.KVStore
+func (st *synchronizedStore) Iterator(start, end []byte) store.Iterator {
+	st.enter()
+	return st.CacheKVStore.Iterator(start, end)
+}
+
+// ReverseIterator implements store.KVStore
+func (st *synchronizedStore) ReverseIterator(start, end []byte) store.Iterator {
+	st.enter()
+	return st.CacheKVStore.ReverseIterator(start, end)
+}
+
+// CacheWrap implements store.CacheWrapper, branching the synchronized store rather than the branch it wraps
+func (st *synchronizedStore) CacheWrap(storeKey store.StoreKey) store.CacheWrap {
+	return cachekv.NewStore(st, storeKey, store.DefaultCacheSizeLimit)
+}
+
+// CacheWrapWithTrace implements store.CacheWrapper
+func (st *synchronizedStore) CacheWrapWithTrace(storeKey store.StoreKey, w io.Writer, tc store.TraceContext) store.CacheWrap {
+	return cachekv.NewStore(tracekv.NewStore(st, w, tc), storeKey, store.DefaultCacheSizeLimit)
+}
+
+// CacheWrapWithListeners implements store.CacheWrapper
+func (st *synchronizedStore) CacheWrapWithListeners(storeKey store.StoreKey, listeners []store.WriteListener) store.CacheWrap {
+	return cachekv.NewStore(listenkv.NewStore(st, storeKey, listeners), storeKey, store.DefaultCacheSizeLimit)
+}
@@ -0,0 +1,71 @@
+package tasks
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	store "github.com/cosmos/cosmos-sdk/store/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestStoreKeyStrategy(t *testing.T) {
+	require.Equal(t, "synchronized", StoreStrategySynchronized.String())
+	require.Equal(t, "synchronized_store", occ.AbortReasonSynchronizedStore.String())
+
+	// store key strategies override the strategies of store types
+	s := NewScheduler(1, nil, nil,
+		WithStoreStrategy(isolatedStoreType, StoreStrategyPassthroughIsolated),
+		WithStoreKeyStrategy(isolatedStoreKey, StoreStrategySynchronized),
+	).(*scheduler)
+	require.Equal(t, StoreStrategySynchronized, s.storeStrategy(isolatedStoreKey, isolatedStoreType))
+	require.Equal(t, StoreStrategyPassthroughIsolated, s.storeStrategy(sdk.NewKVStoreKey("other"), isolatedStoreType))
+	require.Equal(t, StoreStrategyMultiVersion, s.storeStrategy(testStoreKey, store.StoreTypeIAVL))
+}
+
+func TestProcessAllSynchronizedStore(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx increments a counter of the synchronized store, some through a branch of their context, while writing
+	// keys of their own to the parallel store
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		ctx.MultiStore().GetKVStore(testStoreKey).Set(req.Tx, req.Tx)
+		kv := ctx.MultiStore().GetKVStore(isolatedStoreKey)
+		cacheCtx, write := ctx, func() {}
+		if ctx.TxIndex()%2 == 0 {
+			cacheCtx, write = ctx.CacheContext()
+			kv = cacheCtx.MultiStore().GetKVStore(isolatedStoreKey)
+		}
+		counter, _ := strconv.Atoi(string(kv.Get(itemKey)))
+		// executions overlap between the read and the write, so that they would conflict without synchronization
+		time.Sleep(time.Millisecond)
+		kv.Set(itemKey, []byte(strconv.Itoa(counter+1)))
+		write()
+		return types.ResponseDeliverTx{Info: strconv.Itoa(counter + 1)}
+	}
+
+	const txs = 20
+	ctx := initStrategiesTestCtx()
+	s := NewScheduler(10, ti, deliverTx, WithStoreKeyStrategy(isolatedStoreKey, StoreStrategySynchronized))
+	res, err := s.ProcessAll(ctx, requestList(txs))
+	require.NoError(t, err)
+	for i, r := range res {
+		require.Equal(t, strconv.Itoa(i+1), r.Info)
+		require.Equal(t, []byte(fmt.Sprintf("%d", i)), ctx.MultiStore().GetKVStore(testStoreKey).Get([]byte(fmt.Sprintf("%d", i))))
+	}
+	require.Equal(t, []byte(strconv.Itoa(txs)), ctx.MultiStore().GetKVStore(isolatedStoreKey).Get(itemKey))
+	require.NotZero(t, s.Metrics().Aborts)
+
+	// the block matches its sequential execution, on the small block path too
+	_, err = VerifySequential(initStrategiesTestCtx(), requestList(txs), 10, ti, deliverTx, WithStoreKeyStrategy(isolatedStoreKey, StoreStrategySynchronized))
+	require.NoError(t, err)
+	_, err = VerifySequential(initStrategiesTestCtx(), requestList(txs), 10, ti, deliverTx,
+		WithStoreKeyStrategy(isolatedStoreKey, StoreStrategySynchronized), WithSmallBlockThreshold(txs+1))
+	require.NoError(t, err)
+}
@@ -0,0 +1,26 @@
+package tasks
+
+import (
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/utils/tracing"
+)
+
+// NewSynchronousScheduler creates a scheduler that executes the txs of every block one at a time, in index order, on
+// the goroutine calling it. Txs still go through the multiversion stores, with their reads validated and their writes
+// flushed like under OCC, so its responses, events and final state are those of parallel execution without its
+// nondeterministic interleavings. This makes it a drop-in replacement for NewScheduler in module tests, and a
+// reference to compare parallel execution against, see VerifySequential. Options apply like for NewScheduler, except
+// that the number of workers and rounds have no effect, and every block reports Synchronous in its metrics without
+// recording a fallback.
+func NewSynchronousScheduler(
+	tracingInfo *tracing.Info,
+	deliverTxFunc func(ctx sdk.Context, req types.RequestDeliverTx) (res types.ResponseDeliverTx),
+	opts ...SchedulerOption,
+) Scheduler {
+	s := NewScheduler(1, tracingInfo, deliverTxFunc, opts...).(*scheduler)
+	s.alwaysSynchronous = true
+	s.maxIterations = 0
+	return s
+}
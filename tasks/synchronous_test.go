@@ -0,0 +1,66 @@
+package tasks
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestNewSynchronousScheduler(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	var (
+		mx       sync.Mutex
+		order    []int
+		running  int32
+		overlaps int32
+	)
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.AddInt32(&overlaps, 1)
+		}
+		defer atomic.AddInt32(&running, -1)
+		idx, _ := strconv.Atoi(string(req.Tx))
+		mx.Lock()
+		order = append(order, idx)
+		mx.Unlock()
+
+		// every tx increments a shared counter, so that parallel execution conflicts
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		count, _ := strconv.Atoi(string(kv.Get(itemKey)))
+		kv.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		return types.ResponseDeliverTx{Info: strconv.Itoa(count)}
+	}
+
+	for _, txs := range []int{3, 50} {
+		order = nil
+		s := NewSynchronousScheduler(ti, deliverTx, WithMaxIterations(5))
+		res, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+		require.NoError(t, err)
+
+		// every tx executed exactly once, in index order, one at a time
+		expected := make([]int, txs)
+		for i := range expected {
+			expected[i] = i
+			require.Equal(t, strconv.Itoa(i), res[i].Info)
+		}
+		require.Equal(t, expected, order)
+		require.Zero(t, atomic.LoadInt32(&overlaps))
+		metrics := s.Metrics()
+		require.True(t, metrics.Synchronous)
+		require.False(t, metrics.HappyPath)
+		require.Zero(t, metrics.Retries)
+		require.Nil(t, metrics.Postmortem)
+
+		// parallel execution of the same block converges to the same output
+		_, err = VerifySequential(initTestCtx(true), requestList(txs), 10, ti, deliverTx)
+		require.NoError(t, err)
+	}
+}
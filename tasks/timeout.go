@@ -0,0 +1,107 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/tendermint/tendermint/abci/types"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cosmos/cosmos-sdk/telemetry"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// ErrTaskTimedOut is the cause of the abort signaled to an execution that timed out, see WithTaskTimeout
+var ErrTaskTimedOut = errors.New("occ task execution timed out")
+
+// WithTaskTimeout bounds how long a single execution of a tx may take, so that a stuck tx (eg. a contract looping
+// without end) can't hang its block. An execution that doesn't finish within the timeout is abandoned and cancelled:
+// its abort is signaled, so that it stops at its next store operation, and the Go context of its sdk.Context is
+// cancelled, for code that doesn't access its stores to return early. Its writes are never flushed into the
+// multiversion stores. The block then falls back to sequential execution, in which the tx is executed again with its
+// own gas limit and without a timeout, the same way as baseapp executes it without the scheduler, so whether an
+// execution timed out doesn't bear on the responses of the block.
+func WithTaskTimeout(timeout time.Duration) SchedulerOption {
+	return func(s *scheduler) { s.taskTimeout = timeout }
+}
+
+const (
+	executionRunning int32 = iota
+	executionFinished
+	executionAbandoned
+)
+
+// deliverTxWithTimeout runs deliverTxWithRecovery for a task, returning whether its execution timed out, in which
+// case the execution is abandoned and its response is meaningless. Sequential executions aren't timed.
+func (s *scheduler) deliverTxWithTimeout(span trace.Span, task *deliverTxTask) (types.ResponseDeliverTx, bool) {
+	if s.taskTimeout <= 0 || s.synchronous {
+		return s.deliverTxWithRecovery(span, task), false
+	}
+	// the execution runs on a detached task, so that it never touches the task again once abandoned
+	goCtx, cancel := context.WithCancel(task.Ctx.Context())
+	execution := &deliverTxTask{
+		Ctx:            task.Ctx.WithContext(goCtx),
+		Index:          task.Index,
+		Incarnation:    task.Incarnation,
+		Request:        task.Request,
+		VersionStores:  task.VersionStores,
+		IsolatedStores: task.IsolatedStores,
+	}
+	state := executionRunning
+	done := make(chan types.ResponseDeliverTx, 1)
+	go func() {
+		defer cancel()
+		done <- s.deliverTxWithRecovery(span, execution)
+		if !atomic.CompareAndSwapInt32(&state, executionRunning, executionFinished) {
+			s.onAbandonedExecutionReturned()
+		}
+	}()
+
+	timer := time.NewTimer(s.taskTimeout)
+	defer timer.Stop()
+	select {
+	case resp := <-done:
+		task.IsolatedStores = execution.IsolatedStores
+		return resp, false
+	case <-timer.C:
+		if !atomic.CompareAndSwapInt32(&state, executionRunning, executionAbandoned) {
+			// the execution finished as it timed out
+			task.IsolatedStores = execution.IsolatedStores
+			return <-done, false
+		}
+		task.AbortSignal.Signal(occ.Abort{DependentTxIdx: task.Index, Err: ErrTaskTimedOut})
+		cancel()
+		telemetry.SetGauge(float32(atomic.AddInt64(&s.abandonedExecutions, 1)), "scheduler", "abandoned_executions")
+		return types.ResponseDeliverTx{}, true
+	}
+}
+
+// onAbandonedExecutionReturned accounts an abandoned execution that returned, after it was cancelled
+func (s *scheduler) onAbandonedExecutionReturned() {
+	telemetry.SetGauge(float32(atomic.AddInt64(&s.abandonedExecutions, -1)), "scheduler", "abandoned_executions")
+}
+
+// onTaskTimedOut leaves a task whose execution timed out aborted, to be re-executed once the block falls back to
+// sequential execution. The stores of the abandoned execution are dropped without being flushed or marked as
+// estimates, since it may still be using them until it observes its cancellation, and its abort channel is left open
+// for the same reason.
+func (s *scheduler) onTaskTimedOut(task *deliverTxTask) {
+	atomic.AddInt64(&s.metrics.timedOutTasks, 1)
+	task.VersionStores = nil
+	task.stores = nil
+	task.IsolatedStores = nil
+	task.SetStatus(statusAborted)
+	atomic.StoreInt32(&s.timedOut, 1)
+}
+
+// handleTimeouts falls back to sequential execution if an execution of the block timed out
+func (s *scheduler) handleTimeouts(ctx sdk.Context) {
+	if atomic.LoadInt32(&s.timedOut) == 0 {
+		return
+	}
+	s.recordFallback(ctx, FallbackTaskTimeout, ErrTaskTimedOut)
+	s.synchronous = true
+}
@@ -0,0 +1,63 @@
+package tasks
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllTaskTimeout(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the same key, while the first execution of tx 3 is stuck until it's cancelled
+	var stuck int32
+	cancelled := make(chan struct{})
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if ctx.TxIndex() == 3 && atomic.CompareAndSwapInt32(&stuck, 0, 1) {
+			<-ctx.Context().Done()
+			close(cancelled)
+		}
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	const txs = 10
+	s := NewScheduler(4, ti, deliverTx, WithTaskTimeout(50*time.Millisecond))
+	res, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+
+	// the abandoned execution observed its cancellation
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the abandoned execution wasn't cancelled")
+	}
+
+	expected := ""
+	for i, r := range res {
+		expected += fmt.Sprintf("%d,", i)
+		require.Equal(t, expected, r.Info)
+	}
+
+	metrics := s.Metrics()
+	require.Equal(t, 1, metrics.TimedOutTasks)
+	require.True(t, metrics.Synchronous)
+	require.NotNil(t, metrics.Postmortem)
+	require.Equal(t, FallbackTaskTimeout, metrics.Postmortem.Reason)
+	require.Equal(t, ErrTaskTimedOut.Error(), metrics.Postmortem.Cause)
+
+	// nothing times out by default
+	s = NewScheduler(4, ti, deliverTx)
+	_, err = s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	require.Zero(t, s.Metrics().TimedOutTasks)
+}
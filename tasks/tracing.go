@@ -0,0 +1,52 @@
+package tasks
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// The spans of a block form a hierarchy following its OCC structure: a SchedulerProcessAll span covers the whole
+// block, with a SchedulerRound span per execute and validate round, under which the executions and validations of the
+// round are traced. The span of every execution of a tx is linked to the span of its previous execution, whether it
+// was aborted or invalidated, so that the retries of a tx can be followed across rounds.
+
+// startBlockSpan starts the span of a block, under which all of its spans are started
+func (s *scheduler) startBlockSpan(ctx sdk.Context, reqs []*sdk.DeliverTxEntry) (sdk.Context, trace.Span) {
+	ctx, span := s.traceSpan(ctx, "SchedulerProcessAll", nil)
+	span.SetAttributes(attribute.Int64("height", ctx.BlockHeight()), attribute.Int("txs", len(reqs)))
+	return ctx, span
+}
+
+// startRoundSpan ends the span of the previous round, if any, and starts the span of the given round under the span
+// of the block
+func (s *scheduler) startRoundSpan(ctx sdk.Context, round int) sdk.Context {
+	s.endRoundSpan()
+	ctx, s.roundSpan = s.traceSpan(ctx, "SchedulerRound", nil)
+	s.roundSpan.SetAttributes(attribute.Int("round", round), attribute.Bool("synchronous", s.synchronous))
+	return ctx
+}
+
+// endRoundSpan ends the span of the current round, if any
+func (s *scheduler) endRoundSpan() {
+	if s.roundSpan != nil {
+		s.roundSpan.End()
+		s.roundSpan = nil
+	}
+}
+
+// traceExecution starts the span of an execution of task, linked to the span of its previous execution, if any
+func (s *scheduler) traceExecution(ctx sdk.Context, task *deliverTxTask) (sdk.Context, trace.Span) {
+	var opts []trace.SpanStartOption
+	if task.LastExecution.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{
+			SpanContext: task.LastExecution,
+			Attributes:  []attribute.KeyValue{attribute.Int("previousIncarnation", task.LastExecutionIncarnation)},
+		}))
+	}
+	ctx, span := s.traceSpan(ctx, "SchedulerExecuteTask", task, opts...)
+	task.LastExecution = span.SpanContext()
+	task.LastExecutionIncarnation = task.Incarnation
+	return ctx, span
+}
@@ -0,0 +1,118 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/utils/tracing"
+)
+
+func TestProcessAllSpanHierarchy(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tr := tp.Tracer("scheduler-test")
+	ti := &tracing.Info{
+		Tracer: &tr,
+	}
+
+	// every tx appends its index to the shared key, so that the txs conflict and are retried
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		time.Sleep(100 * time.Microsecond)
+		kv.Set(itemKey, []byte(val+fmt.Sprintf("%d,", ctx.TxIndex())))
+		return types.ResponseDeliverTx{Info: val}
+	}
+
+	const txs = 20
+	s := NewScheduler(10, ti, deliverTx)
+	_, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	byID := make(map[trace.SpanID]sdktrace.ReadOnlySpan, len(spans))
+	var blocks, rounds []sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		byID[span.SpanContext().SpanID()] = span
+		switch span.Name() {
+		case "SchedulerProcessAll":
+			blocks = append(blocks, span)
+		case "SchedulerRound":
+			rounds = append(rounds, span)
+		}
+	}
+	require.Len(t, blocks, 1)
+	block := blocks[0]
+	require.False(t, block.Parent().IsValid())
+	require.NotEmpty(t, rounds)
+	for _, round := range rounds {
+		require.Equal(t, block.SpanContext().SpanID(), round.Parent().SpanID())
+	}
+
+	// ancestor returns the closest ancestor of a span with the given name
+	ancestor := func(span sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+		for span.Parent().IsValid() {
+			span = byID[span.Parent().SpanID()]
+			require.NotNil(t, span)
+			if span.Name() == name {
+				return span
+			}
+		}
+		return nil
+	}
+
+	// every execution is traced under a round, and linked to the previous execution of its tx
+	executions := make(map[int][]sdktrace.ReadOnlySpan)
+	for _, span := range spans {
+		switch span.Name() {
+		case "SchedulerExecuteTask":
+			require.NotNil(t, ancestor(span, "SchedulerRound"))
+			index := -1
+			for _, attr := range span.Attributes() {
+				if attr.Key == "txIndex" {
+					index = int(attr.Value.AsInt64())
+				}
+			}
+			require.GreaterOrEqual(t, index, 0)
+			executions[index] = append(executions[index], span)
+		case "SchedulerValidate":
+			require.NotNil(t, ancestor(span, "SchedulerRound"))
+		}
+	}
+	require.Len(t, executions, txs)
+	retried := 0
+	for _, spans := range executions {
+		linked := make(map[trace.SpanID]bool)
+		roots := 0
+		for _, span := range spans {
+			switch links := span.Links(); len(links) {
+			case 0:
+				roots++
+			case 1:
+				linked[links[0].SpanContext.SpanID()] = true
+			default:
+				t.Fatalf("execution linked to %d executions", len(links))
+			}
+		}
+		// the executions of a tx form a single chain from its first execution
+		require.Equal(t, 1, roots)
+		require.Len(t, linked, len(spans)-1)
+		for _, span := range spans {
+			if _, ok := linked[span.SpanContext().SpanID()]; ok {
+				delete(linked, span.SpanContext().SpanID())
+			}
+		}
+		require.Empty(t, linked)
+		retried += len(spans) - 1
+	}
+	require.NotZero(t, retried)
+}
@@ -0,0 +1,61 @@
+package tasks
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ErrTrackingLimitExceeded is the cause of the fallback of a block with a tx that exceeded the tracking limits, see
+// WithTrackingLimits
+var ErrTrackingLimitExceeded = errors.New("occ task exceeded its readset or writeset limit")
+
+// WithTrackingLimits caps the entries the version stores of a tx record for validation: maxReadset bounds the distinct
+// keys read plus the iterators created, and maxWriteset the distinct keys written, across all of the tx's stores (see
+// multiversion.TrackingMeter). Pathological txs can otherwise grow their readsets without bound and exhaust the memory
+// of the node. An execution exceeding a cap stops recording its reads, so it can't be validated: it's aborted, and the
+// block falls back to sequential execution, in which the tx is executed again once every lower-index tx is final, and
+// doesn't need its reads to be validated. The tx doesn't fail, so the caps don't bear on the responses of the block
+// and may differ between nodes. Non-positive values disable the respective cap.
+func WithTrackingLimits(maxReadset, maxWriteset int) SchedulerOption {
+	return func(s *scheduler) {
+		s.maxReadset = maxReadset
+		s.maxWriteset = maxWriteset
+	}
+}
+
+// newTrackingMeter returns the tracking meter of a new execution, or nil if the tracking limits are disabled
+func (s *scheduler) newTrackingMeter() *multiversion.TrackingMeter {
+	if s.maxReadset <= 0 && s.maxWriteset <= 0 {
+		return nil
+	}
+	return multiversion.NewTrackingMeter(s.maxReadset, s.maxWriteset)
+}
+
+// trackingOverflowedBy returns whether the execution of a task exceeded the tracking limits and must be executed
+// again sequentially. Executions under sequential execution don't need to be validated, and neither do those on the
+// happy path, which don't record their reads in the first place.
+func (s *scheduler) trackingOverflowedBy(task *deliverTxTask) bool {
+	return task.TrackingMeter != nil && task.TrackingMeter.Overflowed() && !s.synchronous && !s.happyPath
+}
+
+// onTrackingOverflow leaves a task that exceeded the tracking limits aborted with its writes marked as estimates, to be
+// re-executed once the block falls back to sequential execution
+func (s *scheduler) onTrackingOverflow(task *deliverTxTask) {
+	atomic.AddInt64(&s.metrics.trackingOverflows, 1)
+	task.SetStatus(statusAborted)
+	s.writeAbortEstimates(task)
+	atomic.StoreInt32(&s.trackingOverflowed, 1)
+}
+
+// handleTrackingOverflows falls back to sequential execution if an execution of the block exceeded the tracking
+// limits
+func (s *scheduler) handleTrackingOverflows(ctx sdk.Context) {
+	if atomic.LoadInt32(&s.trackingOverflowed) == 0 {
+		return
+	}
+	s.recordFallback(ctx, FallbackTrackingLimit, ErrTrackingLimitExceeded)
+	s.synchronous = true
+}
@@ -0,0 +1,64 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllTrackingLimits(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the same key, while tx 3 also reads a range of keys of its own and writes another
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		if ctx.TxIndex() == 3 {
+			for i := 0; i < 50; i++ {
+				kv.Get([]byte(fmt.Sprintf("scan/%d", i)))
+			}
+			kv.Set([]byte("scan"), []byte("done"))
+		}
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	const txs = 10
+	s := NewScheduler(4, ti, deliverTx, WithTrackingLimits(20, 0))
+	res, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	expected := ""
+	for i, r := range res {
+		expected += fmt.Sprintf("%d,", i)
+		require.Equal(t, expected, r.Info)
+	}
+
+	metrics := s.Metrics()
+	require.NotZero(t, metrics.TrackingOverflows)
+	require.True(t, metrics.Synchronous)
+	require.NotNil(t, metrics.Postmortem)
+	require.Equal(t, FallbackTrackingLimit, metrics.Postmortem.Reason)
+	require.Equal(t, ErrTrackingLimitExceeded.Error(), metrics.Postmortem.Cause)
+
+	// a cap on writes degrades the same way, and the block matches its sequential execution
+	s = NewScheduler(4, ti, deliverTx, WithTrackingLimits(0, 1))
+	_, err = s.ProcessAll(initTestCtx(true), requestList(txs))
+	require.NoError(t, err)
+	require.NotZero(t, s.Metrics().TrackingOverflows)
+	_, err = VerifySequential(initTestCtx(true), requestList(txs), 4, ti, deliverTx, WithTrackingLimits(0, 1))
+	require.NoError(t, err)
+
+	// within the caps, or without them, nothing overflows
+	for _, opts := range [][]SchedulerOption{{WithTrackingLimits(100, 100)}, nil} {
+		s = NewScheduler(4, ti, deliverTx, opts...)
+		_, err = s.ProcessAll(initTestCtx(true), requestList(txs))
+		require.NoError(t, err)
+		require.Zero(t, s.Metrics().TrackingOverflows)
+		require.Nil(t, s.Metrics().Postmortem)
+	}
+}
@@ -0,0 +1,123 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+	dbm "github.com/tendermint/tm-db"
+
+	"github.com/cosmos/cosmos-sdk/store/dbadapter"
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestCheckOrderDirtyFirst(t *testing.T) {
+	mvs := multiversion.NewMultiVersionStore(dbadapter.Store{DB: dbm.NewMemDB()})
+	// every task reads the keys of its index, and task i reads i dirty keys written by tx 0
+	tasks := toTasks(requestList(5))
+	for _, task := range tasks {
+		vs := mvs.VersionedIndexedStore(task.Index, 0, make(chan occ.Abort, 1))
+		for k := 0; k < task.Index; k++ {
+			vs.Get([]byte(fmt.Sprintf("dirty%d", k)))
+		}
+		vs.Get([]byte(fmt.Sprintf("clean%d", task.Index)))
+		task.VersionStores = map[sdk.StoreKey]*multiversion.VersionIndexedStore{testStoreKey: vs}
+	}
+	dirty := dirtyKeys{testStoreKey: {"dirty0": 0, "dirty1": 0, "dirty2": 0, "dirty3": 2}}
+
+	indices := func(tasks []*deliverTxTask) []int {
+		res := make([]int, 0, len(tasks))
+		for _, task := range tasks {
+			res = append(res, task.Index)
+		}
+		return res
+	}
+
+	// checks are dispatched in index order by default
+	s := &scheduler{}
+	require.Equal(t, []int{0, 1, 2, 3, 4}, indices(s.checkOrder(tasks, dirty)))
+
+	// tasks reading more keys rewritten by lower-index txs go first, so task 4's read of the key rewritten by tx 2
+	// counts while task 2's wouldn't, and tasks hitting no dirty keys stay in index order at the end
+	s.dirtyFirstValidation = true
+	require.Equal(t, []int{4, 3, 2, 1, 0}, indices(s.checkOrder(tasks, dirty)))
+	require.Equal(t, []int{0, 1, 2, 3, 4}, indices(tasks))
+	require.Equal(t, 4, dirtyReads(tasks[4], dirty))
+	require.Equal(t, 3, dirtyReads(tasks[3], dirty))
+	require.Zero(t, dirtyReads(tasks[0], dirty))
+
+	// without dirty keys, there's nothing to order by
+	require.Equal(t, []int{0, 1, 2, 3, 4}, indices(s.checkOrder(tasks, nil)))
+}
+
+func TestProcessAllDirtyFirstValidation(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the same key, so most of them are re-executed
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d,", ctx.TxIndex())
+		kv.Set(itemKey, []byte(newVal))
+		return types.ResponseDeliverTx{Info: newVal}
+	}
+
+	res, err := VerifySequential(initTestCtx(true), requestList(50), 10, ti, deliverTx, WithDirtyFirstValidation())
+	require.NoError(t, err)
+	expected := ""
+	for i, r := range res {
+		expected += fmt.Sprintf("%d,", i)
+		require.Equal(t, expected, r.Info)
+	}
+}
+
+// BenchmarkDirtyFirstValidation compares how long validation rounds take to find the tasks to re-execute on a
+// conflict-heavy block, where every tenth tx rewrites a key read by the rest
+func BenchmarkDirtyFirstValidation(b *testing.B) {
+	ti := newTestTracingInfo()
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		// reads of keys of their own make clean tasks as costly to check as dirty ones
+		for k := 0; k < 20; k++ {
+			kv.Get([]byte(fmt.Sprintf("%s-%d", req.Tx, k)))
+		}
+		val := kv.Get(itemKey)
+		// executions overlap between the read and the write, so that they conflict
+		time.Sleep(10 * time.Microsecond)
+		if ctx.TxIndex()%10 == 0 {
+			kv.Set(itemKey, append(val, req.Tx...))
+		}
+		kv.Set(req.Tx, val)
+		return types.ResponseDeliverTx{}
+	}
+
+	for _, dirtyFirst := range []bool{false, true} {
+		name := "index order"
+		var opts []SchedulerOption
+		if dirtyFirst {
+			name = "dirty first"
+			opts = append(opts, WithDirtyFirstValidation())
+		}
+		b.Run(name, func(b *testing.B) {
+			reqs := requestList(200)
+			var latency int64
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				ctx := initTestCtx(true)
+				b.StartTimer()
+				s := NewScheduler(8, ti, deliverTx, opts...)
+				if _, err := s.ProcessAll(ctx, reqs); err != nil {
+					b.Fatal(err)
+				}
+				latency += int64(s.Metrics().InvalidationLatency)
+			}
+			b.ReportMetric(float64(latency)/float64(b.N), "invalidation-ns/op")
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package tasks
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/utils/tracing"
+)
+
+// ErrSequentialMismatch is returned by VerifySequential when a block processed by a scheduler differs from its
+// sequential execution
+var ErrSequentialMismatch = errors.New("occ scheduler output differs from sequential execution")
+
+// VerifySequential cross-checks the output of a scheduler against sequential execution, eg. in tests. The block is
+// processed by a scheduler created from the arguments, and again by one that executes its txs sequentially (see
+// NewSynchronousScheduler), each against its own branch of ctx, and ErrSequentialMismatch is returned if any of the
+// responses (including their events) or the final writesets differ. The sequential scheduler gets the same options,
+// so that options affecting responses (eg. WithEventOrdering) apply to both. It returns the responses of the scheduler
+// under test.
+func VerifySequential(
+	ctx sdk.Context,
+	reqs []*sdk.DeliverTxEntry,
+	workers int,
+	tracingInfo *tracing.Info,
+	deliverTxFunc func(ctx sdk.Context, req types.RequestDeliverTx) (res types.ResponseDeliverTx),
+	opts ...SchedulerOption,
+) ([]types.ResponseDeliverTx, error) {
+	parallel := NewScheduler(workers, tracingInfo, deliverTxFunc, append(opts[:len(opts):len(opts)], WithWritesetHashing())...)
+	res, err := parallel.ProcessAll(ctx.WithMultiStore(ctx.MultiStore().CacheMultiStore()), reqs)
+	if err != nil {
+		return nil, err
+	}
+	sequential := NewSynchronousScheduler(tracingInfo, deliverTxFunc, append(opts[:len(opts):len(opts)], WithWritesetHashing())...)
+	expected, err := sequential.ProcessAll(ctx.WithMultiStore(ctx.MultiStore().CacheMultiStore()), reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res) != len(expected) {
+		return nil, fmt.Errorf("%w: %d responses, expected %d", ErrSequentialMismatch, len(res), len(expected))
+	}
+	for i := range res {
+		if err := CompareResponses(res[i], expected[i]); err != nil {
+			return nil, fmt.Errorf("%w: response %d: %s", ErrSequentialMismatch, i, err)
+		}
+	}
+	if !bytes.Equal(parallel.WritesetHash(), sequential.WritesetHash()) {
+		return nil, fmt.Errorf("%w: writeset hash %X, expected %X", ErrSequentialMismatch, parallel.WritesetHash(), sequential.WritesetHash())
+	}
+	return res, nil
+}
+
+// CompareResponses returns an error describing how a response differs from the expected one, if it does: its code,
+// data, gas or first differing event, or else the whole response
+func CompareResponses(res types.ResponseDeliverTx, expected types.ResponseDeliverTx) error {
+	switch {
+	case res.Code != expected.Code || res.Codespace != expected.Codespace:
+		return fmt.Errorf("code %s/%d, expected %s/%d", res.Codespace, res.Code, expected.Codespace, expected.Code)
+	case !bytes.Equal(res.Data, expected.Data):
+		return fmt.Errorf("data %X, expected %X", res.Data, expected.Data)
+	case res.GasWanted != expected.GasWanted || res.GasUsed != expected.GasUsed:
+		return fmt.Errorf("gas %d/%d, expected %d/%d", res.GasUsed, res.GasWanted, expected.GasUsed, expected.GasWanted)
+	case len(res.Events) != len(expected.Events):
+		return fmt.Errorf("%d events, expected %d", len(res.Events), len(expected.Events))
+	}
+	for i := range res.Events {
+		if !bytes.Equal(mustMarshal(&res.Events[i]), mustMarshal(&expected.Events[i])) {
+			return fmt.Errorf("event %d %s, expected %s", i, res.Events[i].String(), expected.Events[i].String())
+		}
+	}
+	if !bytes.Equal(mustMarshal(&res), mustMarshal(&expected)) {
+		return fmt.Errorf("%s, expected %s", res.String(), expected.String())
+	}
+	return nil
+}
+
+func mustMarshal(msg interface{ Marshal() ([]byte, error) }) []byte {
+	bz, err := msg.Marshal()
+	if err != nil {
+		panic(err)
+	}
+	return bz
+}
@@ -0,0 +1,78 @@
+package tasks
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestVerifySequential(t *testing.T) {
+	ti := newTestTracingInfo()
+	const txs = 30
+
+	t.Run("matching output", func(t *testing.T) {
+		ctx := initTestCtx(true)
+		res, err := VerifySequential(ctx, requestList(txs), 10, ti, mapOrderedDeliverTx, WithEventOrdering())
+		require.NoError(t, err)
+		require.Len(t, res, txs)
+		for idx, response := range res {
+			require.Equal(t, strconv.Itoa(idx), string(response.Data))
+		}
+		// neither run writes to the context's store
+		require.Nil(t, ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey))
+	})
+
+	t.Run("events without ordering", func(t *testing.T) {
+		// without event ordering, attributes built from a map differ between the runs
+		_, err := VerifySequential(initTestCtx(true), requestList(txs), 10, ti, mapOrderedDeliverTx)
+		require.ErrorIs(t, err, ErrSequentialMismatch)
+	})
+
+	t.Run("non-deterministic writes", func(t *testing.T) {
+		// every execution writes a different value
+		var executions int64
+		deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+			defer abortRecoveryFunc(&response)
+			ctx.MultiStore().GetKVStore(testStoreKey).Set(req.Tx, []byte(strconv.FormatInt(atomic.AddInt64(&executions, 1), 10)))
+			return types.ResponseDeliverTx{}
+		}
+		_, err := VerifySequential(initTestCtx(true), requestList(txs), 10, ti, deliverTx)
+		require.ErrorIs(t, err, ErrSequentialMismatch)
+		require.Contains(t, err.Error(), "writeset hash")
+	})
+}
+
+func TestCompareResponses(t *testing.T) {
+	expected := types.ResponseDeliverTx{
+		Code:      0,
+		Data:      []byte("data"),
+		GasWanted: 10,
+		GasUsed:   5,
+		Events:    []types.Event{{Type: "transfer"}},
+		Info:      "info",
+	}
+	require.NoError(t, CompareResponses(expected, expected))
+
+	for _, tc := range []struct {
+		mutate func(res *types.ResponseDeliverTx)
+		err    string
+	}{
+		{func(res *types.ResponseDeliverTx) { res.Code = 5; res.Codespace = "sdk" }, "code sdk/5, expected /0"},
+		{func(res *types.ResponseDeliverTx) { res.Data = []byte("other") }, "data 6F74686572, expected 64617461"},
+		{func(res *types.ResponseDeliverTx) { res.GasUsed = 6 }, "gas 6/10, expected 5/10"},
+		{func(res *types.ResponseDeliverTx) { res.Events = nil }, "0 events, expected 1"},
+		{func(res *types.ResponseDeliverTx) { res.Events = []types.Event{{Type: "burn"}} }, "event 0 "},
+		{func(res *types.ResponseDeliverTx) { res.Info = "other" }, "expected "},
+	} {
+		res := expected
+		tc.mutate(&res)
+		err := CompareResponses(res, expected)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), tc.err)
+	}
+}
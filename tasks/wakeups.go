@@ -0,0 +1,65 @@
+package tasks
+
+import "sync"
+
+// wakeups tracks the conditions waiting tasks are blocked on, so that validation rounds only revisit the ones whose
+// condition may have been met since they started waiting, rather than polling every waiting task each round. A task
+// waits (see DecisionWait) until all of its dependencies are validated, and is woken every time one of them is.
+// Aborted tasks don't need it, since they already block on the execution of the tx they depend on, see
+// waitForDependency.
+type wakeups struct {
+	mx sync.Mutex
+	// dependency tx index -> indices of the tasks waiting for it to be validated
+	waiters map[int]map[int]struct{}
+	// indices of the tasks woken since the previous validation round
+	woken map[int]struct{}
+}
+
+func newWakeups() *wakeups {
+	return &wakeups{
+		waiters: make(map[int]map[int]struct{}),
+		woken:   make(map[int]struct{}),
+	}
+}
+
+// wait registers a waiting task to be woken once any of its dependencies that aren't validated yet is. It's woken
+// right away if they all are, eg. because one of them was validated concurrently.
+func (w *wakeups) wait(tasks []*deliverTxTask, task *deliverTxTask) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	delete(w.woken, task.Index)
+	pending := false
+	for dep := range task.Dependencies {
+		if tasks[dep].IsStatus(statusValidated) {
+			continue
+		}
+		if w.waiters[dep] == nil {
+			w.waiters[dep] = make(map[int]struct{})
+		}
+		w.waiters[dep][task.Index] = struct{}{}
+		pending = true
+	}
+	if !pending {
+		w.woken[task.Index] = struct{}{}
+	}
+}
+
+// validated wakes the tasks waiting for the task at index, which must already have its validated status so that
+// tasks registering concurrently observe it
+func (w *wakeups) validated(index int) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	for waiter := range w.waiters[index] {
+		w.woken[waiter] = struct{}{}
+	}
+	delete(w.waiters, index)
+}
+
+// take returns the tasks woken since the previous call
+func (w *wakeups) take() map[int]struct{} {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	woken := w.woken
+	w.woken = make(map[int]struct{})
+	return woken
+}
@@ -0,0 +1,73 @@
+package tasks
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestWakeups(t *testing.T) {
+	tasks := toTasks(requestList(4))
+	tasks[0].restoreStatus(statusValidated)
+	tasks[3].AppendDependencies([]int{0, 1, 2})
+	w := newWakeups()
+
+	// the task only waits for its dependencies that aren't validated yet
+	w.wait(tasks, tasks[3])
+	require.Empty(t, w.take())
+	w.validated(0)
+	require.Empty(t, w.take())
+
+	// it's woken by each of them, once
+	tasks[1].restoreStatus(statusValidated)
+	w.validated(1)
+	require.Equal(t, map[int]struct{}{3: {}}, w.take())
+	require.Empty(t, w.take())
+	tasks[2].restoreStatus(statusValidated)
+	w.validated(2)
+	require.Equal(t, map[int]struct{}{3: {}}, w.take())
+
+	// waiting again once they're all validated wakes it right away
+	w.wait(tasks, tasks[3])
+	require.Equal(t, map[int]struct{}{3: {}}, w.take())
+}
+
+func TestProcessAllSkipsWaitingTasks(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx increments a shared counter, so that they conflict and wait for each other
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		count, _ := strconv.Atoi(string(kv.Get(itemKey)))
+		kv.Set(itemKey, []byte(strconv.Itoa(count+1)))
+		return types.ResponseDeliverTx{Data: []byte(strconv.Itoa(count))}
+	}
+
+	const txs = 30
+	for _, policy := range []ConflictPolicy{WaitForDependenciesPolicy{}, waitPolicy{}} {
+		t.Run(fmt.Sprintf("%T", policy), func(t *testing.T) {
+			s := NewScheduler(10, ti, deliverTx, WithConflictPolicy(policy))
+			ctx := initTestCtx(true)
+			res, err := s.ProcessAll(ctx, requestList(txs))
+			require.NoError(t, err)
+
+			// waiting tasks are woken and re-executed until the block completes like under sequential execution
+			for idx, response := range res {
+				require.Equal(t, strconv.Itoa(idx), string(response.Data))
+			}
+			kv := ctx.MultiStore().GetKVStore(testStoreKey)
+			require.Equal(t, []byte(strconv.Itoa(txs)), kv.Get(itemKey))
+		})
+	}
+}
+
+// waitPolicy always waits, even for dependencies that are already validated
+type waitPolicy struct{}
+
+func (waitPolicy) Resolve(Conflict) ConflictDecision { return DecisionWait }
@@ -0,0 +1,117 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrWorkerPoolClosed is returned by ProcessAll when the scheduler's worker pool was closed
+var ErrWorkerPoolClosed = errors.New("occ scheduler worker pool closed")
+
+// WorkerPool keeps the worker goroutines of schedulers alive across blocks, so that they aren't spun up and torn
+// down for every block. Each block borrows as many goroutines as it has workers, which serve its work until the
+// block is done and then go back to the pool. The pool only starts goroutines when none are idle, so it grows to
+// the most workers in use at once, and keeps them parked between blocks until it's closed. A pool may be shared by
+// schedulers (eg. one created per block), including concurrent ones.
+type WorkerPool struct {
+	mx      sync.RWMutex
+	closed  bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	jobs    chan func()
+	workers int64 // only accessed atomically
+}
+
+// WithWorkerPool has the scheduler borrow its workers from a long-lived pool, rather than starting goroutines for
+// each block. The pool is meant to outlive the scheduler, and isn't closed by it.
+func WithWorkerPool(pool *WorkerPool) SchedulerOption {
+	return func(s *scheduler) { s.workerPool = pool }
+}
+
+// NewWorkerPool returns an empty worker pool, which starts goroutines as blocks need them
+func NewWorkerPool() *WorkerPool {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WorkerPool{
+		ctx:    ctx,
+		cancel: cancel,
+		jobs:   make(chan func()),
+	}
+}
+
+// serve has workers goroutines of the pool run the work of dispatcher until ctx is done, marking released done as each
+// goes back to the pool. It returns ErrWorkerPoolClosed if the pool was closed. Each worker carries pprof labels
+// naming its pool and id, and the labeled context is handed to the work so that per-task labels can be layered on top
+// of them.
+func (p *WorkerPool) serve(ctx context.Context, dispatcher Dispatcher, workers int, pool string, released *sync.WaitGroup) error {
+	p.mx.RLock()
+	defer p.mx.RUnlock()
+	if p.closed {
+		return ErrWorkerPoolClosed
+	}
+	for i := 0; i < workers; i++ {
+		worker := i
+		labels := pprof.Labels("occ_pool", pool, "occ_worker", strconv.Itoa(worker))
+		released.Add(1)
+		job := func() {
+			defer released.Done()
+			pprof.Do(ctx, labels, func(ctx context.Context) {
+				for {
+					work, ok := dispatcher.Next(ctx, worker)
+					if !ok {
+						return
+					}
+					work(ctx)
+				}
+			})
+		}
+		// hand the job to an idle goroutine, or start a new one if there's none
+		select {
+		case p.jobs <- job:
+		default:
+			p.spawn(job)
+		}
+	}
+	return nil
+}
+
+// spawn starts a pool goroutine running job, which then waits for more jobs until the pool is closed
+func (p *WorkerPool) spawn(job func()) {
+	atomic.AddInt64(&p.workers, 1)
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer atomic.AddInt64(&p.workers, -1)
+		for {
+			job()
+			select {
+			case <-p.ctx.Done():
+				return
+			case job = <-p.jobs:
+			}
+		}
+	}()
+}
+
+// Workers returns the number of goroutines of the pool, both idle and serving blocks
+func (p *WorkerPool) Workers() int {
+	return int(atomic.LoadInt64(&p.workers))
+}
+
+// Close stops the goroutines of the pool, waiting for the ones serving blocks to finish them. Blocks processed
+// afterwards fail with ErrWorkerPoolClosed. Closing a closed pool is a no-op.
+func (p *WorkerPool) Close() {
+	p.mx.Lock()
+	if p.closed {
+		p.mx.Unlock()
+		return
+	}
+	p.closed = true
+	p.cancel()
+	p.mx.Unlock()
+	p.wg.Wait()
+}
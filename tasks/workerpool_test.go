@@ -0,0 +1,127 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// appendIndexDeliverTx reads the shared key, appends the tx's index and writes it back
+func appendIndexDeliverTx(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+	defer abortRecoveryFunc(&response)
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	newVal := string(kv.Get(itemKey)) + fmt.Sprintf("%d", ctx.TxIndex())
+	kv.Set(itemKey, []byte(newVal))
+	return types.ResponseDeliverTx{Info: newVal}
+}
+
+func TestProcessAllWorkerPool(t *testing.T) {
+	ti := newTestTracingInfo()
+	const (
+		workers = 4
+		txs     = 20
+	)
+
+	requireSequential := func(t *testing.T, ctx sdk.Context, res []types.ResponseDeliverTx) {
+		expected := ""
+		for idx, response := range res {
+			expected = expected + fmt.Sprintf("%d", idx)
+			require.Equal(t, expected, response.Info)
+		}
+		require.Equal(t, expected, string(ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey)))
+	}
+
+	t.Run("reuses workers across blocks", func(t *testing.T) {
+		pool := NewWorkerPool()
+		defer pool.Close()
+		for block := 0; block < 5; block++ {
+			// a scheduler per block, like DeliverTxBatch
+			s := NewScheduler(workers, ti, appendIndexDeliverTx, WithWorkerPool(pool))
+			ctx := initTestCtx(true)
+			res, err := s.ProcessAll(ctx, requestList(txs))
+			require.NoError(t, err)
+			requireSequential(t, ctx, res)
+			// the execution workers and a validation worker per tx, started for the first block only
+			require.Equal(t, workers+txs, pool.Workers())
+		}
+	})
+
+	t.Run("shared by concurrent blocks", func(t *testing.T) {
+		pool := NewWorkerPool()
+		defer pool.Close()
+		var wg sync.WaitGroup
+		for block := 0; block < 3; block++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s := NewScheduler(workers, ti, appendIndexDeliverTx, WithWorkerPool(pool))
+				ctx := initTestCtx(true)
+				res, err := s.ProcessAll(ctx, requestList(txs))
+				require.NoError(t, err)
+				requireSequential(t, ctx, res)
+			}()
+		}
+		wg.Wait()
+		require.LessOrEqual(t, pool.Workers(), 3*(workers+txs))
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		pool := NewWorkerPool()
+		s := NewScheduler(workers, ti, appendIndexDeliverTx, WithWorkerPool(pool))
+		_, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+		require.NoError(t, err)
+		require.NotZero(t, pool.Workers())
+
+		pool.Close()
+		require.Zero(t, pool.Workers())
+		pool.Close()
+
+		ctx := initTestCtx(true)
+		_, err = s.ProcessAll(ctx, requestList(txs))
+		require.True(t, errors.Is(err, ErrWorkerPoolClosed))
+		require.Nil(t, ctx.MultiStore().GetKVStore(testStoreKey).Get(itemKey))
+	})
+}
+
+func BenchmarkProcessAllWorkerPool(b *testing.B) {
+	ti := newTestTracingInfo()
+	// txs writing keys of their own, so that blocks are cheap and the cost of starting workers stands out
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		ctx.MultiStore().GetKVStore(testStoreKey).Set(req.Tx, req.Tx)
+		return types.ResponseDeliverTx{}
+	}
+
+	for _, shared := range []bool{false, true} {
+		name := "per block"
+		if shared {
+			name = "shared pool"
+		}
+		b.Run(name, func(b *testing.B) {
+			var opts []SchedulerOption
+			if shared {
+				pool := NewWorkerPool()
+				defer pool.Close()
+				opts = append(opts, WithWorkerPool(pool))
+			}
+			reqs := requestList(100)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				ctx := initTestCtx(true)
+				b.StartTimer()
+				s := NewScheduler(8, ti, deliverTx, opts...)
+				if _, err := s.ProcessAll(ctx, reqs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
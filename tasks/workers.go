@@ -0,0 +1,128 @@
+package tasks
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// tunerHighConflictRate is the rate of retries per tx at or above which the tuner halves the workers
+	tunerHighConflictRate = 0.5
+	// tunerLowConflictRate is the rate of retries per tx at or below which the tuner grows saturated workers
+	tunerLowConflictRate = 0.1
+	// tunerMinTxs is the number of txs a block needs for the tuner to learn from it
+	tunerMinTxs = 2
+)
+
+// SetWorkers changes the number of workers executing txs. It's safe to call while a block is being processed, and
+// takes effect from the next block. A value below 1 uses a worker per tx. If the scheduler has a worker tuner, the
+// tuner decides the number of workers instead.
+func (s *scheduler) SetWorkers(workers int) {
+	atomic.StoreInt64(&s.workers, int64(workers))
+}
+
+// WithWorkerTuner has the scheduler take the number of workers of every block from the tuner, and report the
+// block's metrics back to it once processed. The tuner is meant to outlive the scheduler, so that it keeps learning
+// across blocks even when a scheduler is created per block.
+func WithWorkerTuner(tuner *WorkerTuner) SchedulerOption {
+	return func(s *scheduler) { s.workerTuner = tuner }
+}
+
+// blockWorkers returns the number of execution workers for a block of n tasks
+func (s *scheduler) blockWorkers(n int) int {
+	workers := int(atomic.LoadInt64(&s.workers))
+	if s.workerTuner != nil {
+		workers = s.workerTuner.Workers()
+	}
+	// default to number of tasks if workers is negative or 0 by this point
+	if workers < 1 {
+		workers = n
+	}
+	return workers
+}
+
+// WorkerTuner adapts the number of workers to the blocks being processed. Conflicting txs only waste work when run
+// in parallel, so the tuner halves the workers after a block with a high conflict rate (or one that fell back to
+// sequential execution), and grows them again while blocks have few conflicts and keep every worker busy. A growth
+// step that made execution slower per tx is undone.
+type WorkerTuner struct {
+	mx         sync.Mutex
+	minWorkers int
+	maxWorkers int
+	workers    int
+
+	// the workers and execution time per tx of the last observed block
+	lastWorkers   int
+	lastExecPerTx time.Duration
+}
+
+// NewWorkerTuner returns a tuner bounded by the given numbers of workers, starting at the maximum
+func NewWorkerTuner(minWorkers int, maxWorkers int) *WorkerTuner {
+	t := &WorkerTuner{}
+	t.SetLimits(minWorkers, maxWorkers)
+	t.workers = t.maxWorkers
+	return t
+}
+
+// SetLimits changes the bounds of the tuner, eg. when the app config is reloaded. The current number of workers is
+// clamped to the new bounds.
+func (t *WorkerTuner) SetLimits(minWorkers int, maxWorkers int) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if minWorkers < 1 {
+		minWorkers = 1
+	}
+	if maxWorkers < minWorkers {
+		maxWorkers = minWorkers
+	}
+	t.minWorkers = minWorkers
+	t.maxWorkers = maxWorkers
+	t.workers = t.clamp(t.workers)
+}
+
+// Workers returns the number of workers to use for the next block
+func (t *WorkerTuner) Workers() int {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.workers
+}
+
+func (t *WorkerTuner) clamp(workers int) int {
+	if workers < t.minWorkers {
+		return t.minWorkers
+	}
+	if workers > t.maxWorkers {
+		return t.maxWorkers
+	}
+	return workers
+}
+
+// observe adjusts the number of workers given the metrics of a processed block
+func (t *WorkerTuner) observe(m SchedulerMetrics) {
+	if m.Txs < tunerMinTxs {
+		return
+	}
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	conflictRate := float64(m.Retries) / float64(m.Txs)
+	execPerTx := m.ExecuteDuration / time.Duration(m.Txs)
+	workers := t.workers
+	switch {
+	case m.Synchronous || conflictRate >= tunerHighConflictRate:
+		workers = m.Workers / 2
+	case m.Workers > t.lastWorkers && t.lastWorkers > 0 && execPerTx > t.lastExecPerTx:
+		// the extra workers only made execution slower
+		workers = t.lastWorkers
+	case conflictRate <= tunerLowConflictRate && m.MaxConcurrency >= m.Workers:
+		step := m.Workers / 4
+		if step < 1 {
+			step = 1
+		}
+		workers = m.Workers + step
+	}
+	t.workers = t.clamp(workers)
+	t.lastWorkers = m.Workers
+	t.lastExecPerTx = execPerTx
+}
@@ -0,0 +1,101 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestWorkerTuner(t *testing.T) {
+	tuner := NewWorkerTuner(2, 16)
+	require.Equal(t, 16, tuner.Workers())
+
+	// a highly conflicting block halves the workers
+	tuner.observe(SchedulerMetrics{Txs: 10, Retries: 8, Workers: 16, MaxConcurrency: 16, ExecuteDuration: 10 * time.Millisecond})
+	require.Equal(t, 8, tuner.Workers())
+	// as does falling back to sequential execution
+	tuner.observe(SchedulerMetrics{Txs: 10, Synchronous: true, Workers: 8, MaxConcurrency: 8, ExecuteDuration: 10 * time.Millisecond})
+	require.Equal(t, 4, tuner.Workers())
+	// but never below the minimum
+	tuner.observe(SchedulerMetrics{Txs: 10, Retries: 10, Workers: 4, MaxConcurrency: 4, ExecuteDuration: 10 * time.Millisecond})
+	tuner.observe(SchedulerMetrics{Txs: 10, Retries: 10, Workers: 2, MaxConcurrency: 2, ExecuteDuration: 10 * time.Millisecond})
+	require.Equal(t, 2, tuner.Workers())
+
+	// blocks with few conflicts that keep every worker busy grow the workers
+	tuner.observe(SchedulerMetrics{Txs: 10, Workers: 2, MaxConcurrency: 2, ExecuteDuration: 10 * time.Millisecond})
+	require.Equal(t, 3, tuner.Workers())
+	tuner.observe(SchedulerMetrics{Txs: 10, Workers: 3, MaxConcurrency: 3, ExecuteDuration: 8 * time.Millisecond})
+	require.Equal(t, 4, tuner.Workers())
+	// unless the extra workers made execution slower
+	tuner.observe(SchedulerMetrics{Txs: 10, Workers: 4, MaxConcurrency: 4, ExecuteDuration: 9 * time.Millisecond})
+	require.Equal(t, 3, tuner.Workers())
+	// workers that aren't all busy are left alone
+	tuner.observe(SchedulerMetrics{Txs: 10, Workers: 3, MaxConcurrency: 2, ExecuteDuration: 20 * time.Millisecond})
+	require.Equal(t, 3, tuner.Workers())
+	// and so are blocks too small to learn from
+	tuner.observe(SchedulerMetrics{Txs: 1, Retries: 1, Workers: 3, MaxConcurrency: 1})
+	require.Equal(t, 3, tuner.Workers())
+
+	// new limits clamp the current workers
+	tuner.SetLimits(4, 8)
+	require.Equal(t, 4, tuner.Workers())
+	tuner.SetLimits(0, 1)
+	require.Equal(t, 1, tuner.Workers())
+}
+
+func TestSchedulerSetWorkers(t *testing.T) {
+	ti := newTestTracingInfo()
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		kv.Set([]byte(fmt.Sprintf("key-%d", ctx.TxIndex())), []byte("value"))
+		return types.ResponseDeliverTx{}
+	}
+
+	s := NewScheduler(4, ti, deliverTx)
+	_, err := s.ProcessAll(initTestCtx(true), requestList(10))
+	require.NoError(t, err)
+	require.Equal(t, 4, s.Metrics().Workers)
+
+	s.SetWorkers(2)
+	_, err = s.ProcessAll(initTestCtx(true), requestList(10))
+	require.NoError(t, err)
+	require.Equal(t, 2, s.Metrics().Workers)
+
+	// a worker per tx
+	s.SetWorkers(0)
+	_, err = s.ProcessAll(initTestCtx(true), requestList(10))
+	require.NoError(t, err)
+	require.Equal(t, 10, s.Metrics().Workers)
+}
+
+func TestSchedulerWorkerTuner(t *testing.T) {
+	ti := newTestTracingInfo()
+	// every tx reads and writes the same key, so the block conflicts heavily
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		val := string(kv.Get(itemKey))
+		kv.Set(itemKey, []byte(val+fmt.Sprintf("%d", ctx.TxIndex())))
+		return types.ResponseDeliverTx{}
+	}
+
+	tuner := NewWorkerTuner(1, 20)
+	// the tuner outlives the scheduler of each block, and overrides the configured workers
+	for block := 0; block < 3; block++ {
+		s := NewScheduler(50, ti, deliverTx, WithWorkerTuner(tuner))
+		workers := tuner.Workers()
+		_, err := s.ProcessAll(initTestCtx(true), requestList(20))
+		require.NoError(t, err)
+		m := s.Metrics()
+		require.Equal(t, workers, m.Workers)
+		if m.Synchronous || float64(m.Retries)/float64(m.Txs) >= tunerHighConflictRate {
+			require.Less(t, tuner.Workers(), workers)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package tasks
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+// TxIdentifierFunc returns the identifier of what a tx calls that determines the keys it writes, eg. the address of
+// the contract called by its message, and false if the tx has none
+type TxIdentifierFunc func(tx []byte) (string, bool)
+
+// learnedWritesets are the keys written by the txs of an identifier, with the height each key was last written at
+type learnedWritesets struct {
+	id        string
+	writesets map[sdk.StoreKey]map[string]int64
+}
+
+// WritesetCache learns the keys written by the txs of recurring contracts or messages, keyed by their identifier, so
+// that txs calling them in later blocks start out with estimates of their writes, eg. the txs of an oracle or of an
+// AMM pool that touch the same keys every block. Keys expire once they haven't been written for more than maxAge
+// heights, and the least recently used identifiers are evicted beyond capacity. It's safe for concurrent use, and meant
+// to be shared by the schedulers of consecutive blocks.
+type WritesetCache struct {
+	mx       sync.Mutex
+	capacity int
+	maxAge   int64
+	lru      *list.List // of *learnedWritesets, most recently used first
+	entries  map[string]*list.Element
+}
+
+// NewWritesetCache creates a cache of the writesets of up to capacity identifiers, whose keys expire once they haven't
+// been written for more than maxAge heights
+func NewWritesetCache(capacity int, maxAge int64) *WritesetCache {
+	return &WritesetCache{
+		capacity: capacity,
+		maxAge:   maxAge,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// WithWritesetCache has the scheduler learn the keys written by the txs of every block it processes in the cache, by
+// the identifier of each tx, and prefill the keys learned for the identifiers of the txs of a block as estimates,
+// unless their requests have estimated writesets of their own or carried over from earlier blocks. Like carried over
+// writesets, learned writesets are only used as estimates.
+func WithWritesetCache(cache *WritesetCache, identify TxIdentifierFunc) SchedulerOption {
+	return func(s *scheduler) {
+		s.writesetCache = cache
+		s.identifyTx = identify
+	}
+}
+
+// Len returns the number of identifiers with writesets in the cache
+func (c *WritesetCache) Len() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return len(c.entries)
+}
+
+// get returns the sorted keys learned for an identifier that haven't expired at the given height
+func (c *WritesetCache) get(id string, height int64) (map[sdk.StoreKey][]string, bool) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	elem, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	writesets := make(map[sdk.StoreKey][]string)
+	for storeKey, keys := range elem.Value.(*learnedWritesets).writesets {
+		for key, written := range keys {
+			if height-written <= c.maxAge {
+				writesets[storeKey] = append(writesets[storeKey], key)
+			}
+		}
+		sort.Strings(writesets[storeKey])
+	}
+	return writesets, len(writesets) > 0
+}
+
+// record learns the keys written by the txs of a block at the given height by identifier, expiring the keys that are
+// too old and evicting the least recently used identifiers beyond capacity
+func (c *WritesetCache) record(height int64, writesets map[string]map[sdk.StoreKey][]string) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	for id, ws := range writesets {
+		elem, ok := c.entries[id]
+		if !ok {
+			elem = c.lru.PushFront(&learnedWritesets{id: id, writesets: make(map[sdk.StoreKey]map[string]int64)})
+			c.entries[id] = elem
+		}
+		c.lru.MoveToFront(elem)
+		learned := elem.Value.(*learnedWritesets)
+		for storeKey, keys := range ws {
+			if learned.writesets[storeKey] == nil {
+				learned.writesets[storeKey] = make(map[string]int64, len(keys))
+			}
+			for _, key := range keys {
+				learned.writesets[storeKey][key] = height
+			}
+		}
+	}
+	// least recently used identifiers are evicted first
+	for elem := c.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		learned := elem.Value.(*learnedWritesets)
+		for storeKey, keys := range learned.writesets {
+			for key, written := range keys {
+				if height-written > c.maxAge {
+					delete(keys, key)
+				}
+			}
+			if len(keys) == 0 {
+				delete(learned.writesets, storeKey)
+			}
+		}
+		if len(learned.writesets) == 0 || len(c.entries) > c.capacity {
+			c.lru.Remove(elem)
+			delete(c.entries, learned.id)
+		}
+		elem = prev
+	}
+}
+
+// prefillLearnedEstimates prefills the writesets learned for the identifiers of the txs of the block as estimates, for
+// the requests without estimated writesets of their own or carried over ones
+func (s *scheduler) prefillLearnedEstimates(ctx sdk.Context, reqs []*sdk.DeliverTxEntry, carried map[int]struct{}) {
+	if s.writesetCache == nil {
+		return
+	}
+	for i, req := range reqs {
+		if _, ok := carried[i]; ok || len(req.EstimatedWritesets) > 0 {
+			continue
+		}
+		id, ok := s.identifyTx(req.Request.Tx)
+		if !ok {
+			continue
+		}
+		writesets, ok := s.writesetCache.get(id, ctx.BlockHeight())
+		if !ok {
+			continue
+		}
+		for storeKey, keys := range writesets {
+			mvs, ok := s.multiVersionStores[storeKey]
+			if !ok {
+				continue
+			}
+			estimate := make(multiversion.WriteSet, len(keys))
+			for _, key := range keys {
+				estimate[key] = nil
+			}
+			mvs.SetEstimatedWriteset(i, occ.PrefillIncarnation, estimate)
+		}
+		s.metrics.learnedEstimates++
+	}
+}
+
+// recordLearnedWritesets learns the keys written by the final execution of every tx of the block with an identifier
+func (s *scheduler) recordLearnedWritesets(ctx sdk.Context, tasks []*deliverTxTask) {
+	if s.writesetCache == nil {
+		return
+	}
+	writesets := make(map[string]map[sdk.StoreKey][]string)
+	for _, t := range tasks {
+		id, ok := s.identifyTx(t.Request.Tx)
+		if !ok {
+			continue
+		}
+		for _, mv := range s.orderedStores {
+			if keys := mv.store.GetWritesetKeys(t.Index); len(keys) > 0 {
+				if writesets[id] == nil {
+					writesets[id] = make(map[sdk.StoreKey][]string)
+				}
+				writesets[id][mv.key] = append(writesets[id][mv.key], keys...)
+			}
+		}
+	}
+	s.writesetCache.record(ctx.BlockHeight(), writesets)
+}
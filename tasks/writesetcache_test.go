@@ -0,0 +1,100 @@
+package tasks
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestWritesetCache(t *testing.T) {
+	cache := NewWritesetCache(2, 1)
+	cache.record(10, map[string]map[sdk.StoreKey][]string{
+		"oracle": {testStoreKey: {"price"}},
+		"pool":   {testStoreKey: {"reserves"}},
+	})
+	require.Equal(t, 2, cache.Len())
+
+	// the keys of an identifier accumulate over blocks, and expire once they haven't been written for max age heights
+	cache.record(11, map[string]map[sdk.StoreKey][]string{"oracle": {testStoreKey: {"round"}}})
+	found, ok := cache.get("oracle", 11)
+	require.True(t, ok)
+	require.Equal(t, map[sdk.StoreKey][]string{testStoreKey: {"price", "round"}}, found)
+	found, ok = cache.get("oracle", 12)
+	require.True(t, ok)
+	require.Equal(t, map[sdk.StoreKey][]string{testStoreKey: {"round"}}, found)
+	_, ok = cache.get("oracle", 13)
+	require.False(t, ok)
+	_, ok = cache.get("unknown", 11)
+	require.False(t, ok)
+
+	// the least recently used identifier is evicted beyond capacity
+	_, ok = cache.get("pool", 11)
+	require.True(t, ok)
+	cache.record(11, map[string]map[sdk.StoreKey][]string{"bridge": {testStoreKey: {"nonce"}}})
+	require.Equal(t, 2, cache.Len())
+	_, ok = cache.get("oracle", 11)
+	require.False(t, ok)
+	_, ok = cache.get("pool", 11)
+	require.True(t, ok)
+
+	// identifiers whose keys all expired are dropped
+	cache.record(13, nil)
+	require.Zero(t, cache.Len())
+}
+
+func TestProcessAllWritesetCache(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// txs call one of two contracts, by the parity of their tx, and increment the counter of their contract
+	const contracts = 2
+	identify := func(tx []byte) (string, bool) {
+		i, err := strconv.Atoi(string(tx))
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("contract-%d", i%contracts), true
+	}
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		id, _ := identify(req.Tx)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		count, _ := strconv.Atoi(string(kv.Get([]byte(id))))
+		kv.Set([]byte(id), []byte(strconv.Itoa(count+1)))
+		return types.ResponseDeliverTx{Info: strconv.Itoa(count)}
+	}
+
+	const txs = 20
+	cache := NewWritesetCache(10, 1)
+	opts := []SchedulerOption{WithWritesetCache(cache, identify)}
+	_, err := VerifySequential(initTestCtx(true).WithBlockHeight(5), requestList(txs), 10, ti, deliverTx, opts...)
+	require.NoError(t, err)
+	require.Equal(t, contracts, cache.Len())
+
+	// different txs calling the same contracts in the next block start out with estimates of the counters they write,
+	// except the one with estimates of its own
+	reqs := requestList(2 * txs)[txs:]
+	reqs[0].EstimatedWritesets = sdk.MappedWritesets{testStoreKey: {"contract-0": nil}}
+	s := NewScheduler(10, ti, deliverTx, opts...)
+	ctx := initTestCtx(true).WithBlockHeight(6)
+	res, err := s.ProcessAll(ctx, reqs)
+	require.NoError(t, err)
+	require.Equal(t, txs-1, s.Metrics().LearnedEstimates)
+	for idx, response := range res {
+		require.Equal(t, strconv.Itoa(idx/contracts), response.Info)
+	}
+	kv := ctx.MultiStore().GetKVStore(testStoreKey)
+	for c := 0; c < contracts; c++ {
+		require.Equal(t, []byte(strconv.Itoa(txs/contracts)), kv.Get([]byte(fmt.Sprintf("contract-%d", c))))
+	}
+
+	// learned writesets expire once the contracts are no longer called
+	s = NewScheduler(10, ti, deliverTx, opts...)
+	_, err = s.ProcessAll(initTestCtx(true).WithBlockHeight(8), requestList(txs))
+	require.NoError(t, err)
+	require.Zero(t, s.Metrics().LearnedEstimates)
+}
@@ -0,0 +1,61 @@
+package tasks
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// ErrWritesetHashMismatch is returned by CheckWritesetHash when the final writesets of a block diverge
+var ErrWritesetHashMismatch = errors.New("occ scheduler writeset hash mismatch")
+
+// WithWritesetHashing enables hashing the final writesets of every block before they're written to the parent stores,
+// see WritesetHash
+func WithWritesetHashing() SchedulerOption {
+	return func(s *scheduler) { s.writesetHashing = true }
+}
+
+// WritesetHash returns a deterministic hash of the final writesets of the most recently processed block, taken before
+// they're written to the parent stores, or nil if writeset hashing isn't enabled. It covers every store in store key
+// name order, with the writes of each store in key order, so nodes that executed the block to the same state get the
+// same hash. Comparing it across nodes (or against sequential execution) catches OCC-induced divergence with a clear
+// error, before it surfaces as an app hash mismatch.
+func (s *scheduler) WritesetHash() []byte {
+	return s.writesetHash
+}
+
+// HashWritesets returns the hash of the final writesets of multiversion stores, as returned by WritesetHash: the
+// latest writeset hash of every store in store key name order
+func HashWritesets(stores map[sdk.StoreKey]multiversion.MultiVersionStore) []byte {
+	keys := make([]sdk.StoreKey, 0, len(stores))
+	for key := range stores {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name() < keys[j].Name() })
+
+	h := sha256.New()
+	var lenBuf [binary.MaxVarintLen64]byte
+	for _, key := range keys {
+		name := []byte(key.Name())
+		n := binary.PutUvarint(lenBuf[:], uint64(len(name)))
+		h.Write(lenBuf[:n])
+		h.Write(name)
+		h.Write(stores[key].LatestWritesetHash())
+	}
+	return h.Sum(nil)
+}
+
+// CheckWritesetHash returns an ErrWritesetHashMismatch error describing the divergence if the writeset hash of a
+// block doesn't match the expected hash, eg. from another node or from sequential execution
+func CheckWritesetHash(height int64, expected []byte, actual []byte) error {
+	if bytes.Equal(expected, actual) {
+		return nil
+	}
+	return fmt.Errorf("%w at height %d: expected %X, got %X", ErrWritesetHashMismatch, height, expected, actual)
+}
@@ -0,0 +1,42 @@
+package tasks
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestProcessAllWritesetHash(t *testing.T) {
+	ti := newTestTracingInfo()
+
+	// every tx appends its index to the shared key, and writes its own key
+	deliverTx := func(ctx sdk.Context, req types.RequestDeliverTx) (response types.ResponseDeliverTx) {
+		defer abortRecoveryFunc(&response)
+		kv := ctx.MultiStore().GetKVStore(testStoreKey)
+		kv.Set(itemKey, []byte(string(kv.Get(itemKey))+fmt.Sprintf("%d,", ctx.TxIndex())))
+		kv.Set([]byte(fmt.Sprintf("tx-%d", ctx.TxIndex())), req.Tx)
+		return types.ResponseDeliverTx{}
+	}
+	hashBlock := func(txs int, opts ...SchedulerOption) []byte {
+		s := NewScheduler(10, ti, deliverTx, opts...)
+		_, err := s.ProcessAll(initTestCtx(true), requestList(txs))
+		require.NoError(t, err)
+		return s.WritesetHash()
+	}
+
+	hash := hashBlock(50, WithWritesetHashing())
+	require.Len(t, hash, 32)
+	// the hash doesn't depend on how the block was scheduled
+	require.Equal(t, hash, hashBlock(50, WithWritesetHashing(), WithMaxIterations(0)))
+	require.NotEqual(t, hash, hashBlock(49, WithWritesetHashing()))
+	require.Nil(t, hashBlock(50))
+
+	require.NoError(t, CheckWritesetHash(1, hash, hash))
+	err := CheckWritesetHash(1, hash, hashBlock(49, WithWritesetHashing()))
+	require.ErrorIs(t, err, ErrWritesetHashMismatch)
+	require.Contains(t, err.Error(), fmt.Sprintf("%X", hash))
+}
@@ -0,0 +1,138 @@
+package telemetry
+
+import (
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+)
+
+// Emitter emits metrics like the package function wrappers, which handlers can get from their context so that the
+// metrics of a tx can be buffered, see Buffer
+type Emitter interface {
+	IncrCounter(val float32, keys ...string)
+	IncrCounterWithLabels(keys []string, val float32, labels []metrics.Label)
+	SetGauge(val float32, keys ...string)
+	SetGaugeWithLabels(keys []string, val float32, labels []metrics.Label)
+	MeasureSince(start time.Time, keys ...string)
+	MeasureSinceWithLabels(keys []string, start time.Time, labels []metrics.Label)
+}
+
+// DirectEmitter is the Emitter that emits metrics right away, through the package function wrappers
+var DirectEmitter Emitter = directEmitter{}
+
+type directEmitter struct{}
+
+func (directEmitter) IncrCounter(val float32, keys ...string) { IncrCounter(val, keys...) }
+
+func (directEmitter) IncrCounterWithLabels(keys []string, val float32, labels []metrics.Label) {
+	IncrCounterWithLabels(keys, val, labels)
+}
+
+func (directEmitter) SetGauge(val float32, keys ...string) { SetGauge(val, keys...) }
+
+func (directEmitter) SetGaugeWithLabels(keys []string, val float32, labels []metrics.Label) {
+	SetGaugeWithLabels(keys, val, labels)
+}
+
+func (directEmitter) MeasureSince(start time.Time, keys ...string) { MeasureSince(start, keys...) }
+
+func (directEmitter) MeasureSinceWithLabels(keys []string, start time.Time, labels []metrics.Label) {
+	MeasureSinceWithLabels(keys, start, labels)
+}
+
+type emissionKind int
+
+const (
+	emissionCounter emissionKind = iota
+	emissionGauge
+	emissionSample
+)
+
+// emission is a buffered metric emission. Samples keep the time measured when they were buffered, in milliseconds.
+type emission struct {
+	kind   emissionKind
+	keys   []string
+	val    float32
+	labels []metrics.Label
+}
+
+// Buffer is an Emitter that buffers metrics until they're flushed, eg. for txs that may be executed several times
+// under OCC, whose metrics must only be emitted for the execution that's kept. It's safe for concurrent use.
+type Buffer struct {
+	mtx       sync.Mutex
+	emissions []emission
+}
+
+var _ Emitter = (*Buffer)(nil)
+
+// NewBuffer creates an empty buffer
+func NewBuffer() *Buffer {
+	return &Buffer{}
+}
+
+func (b *Buffer) add(e emission) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.emissions = append(b.emissions, e)
+}
+
+// IncrCounter implements Emitter.
+func (b *Buffer) IncrCounter(val float32, keys ...string) {
+	b.add(emission{kind: emissionCounter, keys: keys, val: val})
+}
+
+// IncrCounterWithLabels implements Emitter.
+func (b *Buffer) IncrCounterWithLabels(keys []string, val float32, labels []metrics.Label) {
+	b.add(emission{kind: emissionCounter, keys: keys, val: val, labels: labels})
+}
+
+// SetGauge implements Emitter.
+func (b *Buffer) SetGauge(val float32, keys ...string) {
+	b.add(emission{kind: emissionGauge, keys: keys, val: val})
+}
+
+// SetGaugeWithLabels implements Emitter.
+func (b *Buffer) SetGaugeWithLabels(keys []string, val float32, labels []metrics.Label) {
+	b.add(emission{kind: emissionGauge, keys: keys, val: val, labels: labels})
+}
+
+// MeasureSince implements Emitter. The time is measured when it's called, not when the buffer is flushed.
+func (b *Buffer) MeasureSince(start time.Time, keys ...string) {
+	b.add(emission{kind: emissionSample, keys: keys, val: sinceMillis(start), labels: globalLabels})
+}
+
+// MeasureSinceWithLabels implements Emitter. The time is measured when it's called, not when the buffer is flushed.
+func (b *Buffer) MeasureSinceWithLabels(keys []string, start time.Time, labels []metrics.Label) {
+	b.add(emission{kind: emissionSample, keys: keys, val: sinceMillis(start), labels: labels})
+}
+
+// Len returns the number of buffered emissions
+func (b *Buffer) Len() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return len(b.emissions)
+}
+
+// Flush emits the buffered metrics in the order they were buffered, and empties the buffer
+func (b *Buffer) Flush() {
+	b.mtx.Lock()
+	emissions := b.emissions
+	b.emissions = nil
+	b.mtx.Unlock()
+	for _, e := range emissions {
+		switch e.kind {
+		case emissionCounter:
+			IncrCounterWithLabels(e.keys, e.val, e.labels)
+		case emissionGauge:
+			SetGaugeWithLabels(e.keys, e.val, e.labels)
+		case emissionSample:
+			metrics.AddSampleWithLabels(e.keys, e.val, e.labels)
+		}
+	}
+}
+
+// sinceMillis returns the time elapsed since start in milliseconds, the unit timers are emitted in
+func sinceMillis(start time.Time) float32 {
+	return float32(time.Since(start).Nanoseconds()) / float32(time.Millisecond)
+}
@@ -0,0 +1,47 @@
+package telemetry
+
+import (
+	"testing"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuffer(t *testing.T) {
+	sink := metrics.NewInmemSink(time.Minute, time.Minute)
+	cfg := metrics.DefaultConfig("test")
+	cfg.EnableHostname = false
+	cfg.EnableRuntimeMetrics = false
+	_, err := metrics.NewGlobal(cfg, sink)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = metrics.NewGlobal(metrics.DefaultConfig("test"), &metrics.BlackholeSink{})
+	})
+
+	buffer := NewBuffer()
+	buffer.IncrCounter(1, "buffered", "counter")
+	buffer.IncrCounterWithLabels([]string{"buffered", "counter"}, 2, []metrics.Label{NewLabel("denom", "usei")})
+	buffer.SetGauge(5, "buffered", "gauge")
+	buffer.MeasureSince(time.Now().Add(-time.Second), "buffered", "timer")
+	require.Equal(t, 4, buffer.Len())
+
+	interval := sink.Data()[0]
+	require.Empty(t, interval.Counters)
+	require.Empty(t, interval.Gauges)
+	require.Empty(t, interval.Samples)
+
+	buffer.Flush()
+	require.Zero(t, buffer.Len())
+	interval = sink.Data()[0]
+	require.Equal(t, 1, interval.Counters["test.buffered.counter"].Count)
+	require.Equal(t, 1, interval.Counters["test.buffered.counter;denom=usei"].Count)
+	require.Equal(t, float32(5), interval.Gauges["test.buffered.gauge"].Value)
+	// the time is measured when it's buffered
+	require.GreaterOrEqual(t, interval.Samples["test.buffered.timer"].Max, 1000.0)
+	require.Less(t, interval.Samples["test.buffered.timer"].Max, 2000.0)
+
+	// a flushed buffer is empty
+	buffer.Flush()
+	require.Equal(t, 1, sink.Data()[0].Counters["test.buffered.counter"].Count)
+}
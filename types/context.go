@@ -12,6 +12,7 @@ import (
 
 	"github.com/cosmos/cosmos-sdk/store/gaskv"
 	stypes "github.com/cosmos/cosmos-sdk/store/types"
+	"github.com/cosmos/cosmos-sdk/telemetry"
 	acltypes "github.com/cosmos/cosmos-sdk/types/accesscontrol"
 )
 
@@ -50,6 +51,8 @@ type Context struct {
 	txIndex      int
 
 	traceSpanContext context.Context
+
+	telemetry telemetry.Emitter
 }
 
 // Proposed rename, not done to avoid API breakage
@@ -150,6 +153,15 @@ func (c Context) TraceSpanContext() context.Context {
 	return c.traceSpanContext
 }
 
+// Telemetry returns the emitter handlers should emit metrics with, which buffers them if the tx may be re-executed
+// (eg. under OCC), and otherwise emits them right away
+func (c Context) Telemetry() telemetry.Emitter {
+	if c.telemetry == nil {
+		return telemetry.DirectEmitter
+	}
+	return c.telemetry
+}
+
 // WithEventManager returns a Context with an updated tx priority
 func (c Context) WithPriority(p int64) Context {
 	c.priority = p
@@ -349,6 +361,12 @@ func (c Context) WithTraceSpanContext(ctx context.Context) Context {
 	return c
 }
 
+// WithTelemetry returns a Context with an updated telemetry emitter
+func (c Context) WithTelemetry(emitter telemetry.Emitter) Context {
+	c.telemetry = emitter
+	return c
+}
+
 // TODO: remove???
 func (c Context) IsZero() bool {
 	return c.ms == nil
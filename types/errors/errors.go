@@ -153,6 +153,21 @@ var (
 	// ErrOCCAbort defines an error exncountered by a transaction when it encounters an OCC conflict resulting in an Abort
 	ErrOCCAbort = Register(RootCodespace, 43, "occ abort")
 
+	// ErrOCCLimitExceeded defines an error encountered by a transaction when it exceeds a per-tx OCC resource limit
+	ErrOCCLimitExceeded = Register(RootCodespace, 44, "occ resource limit exceeded")
+
+	// ErrOCCUndeclaredWrite defines an error encountered by a transaction when it writes a key outside of its declared
+	// writeset in strict mode
+	ErrOCCUndeclaredWrite = Register(RootCodespace, 45, "occ write outside of declared writeset")
+
+	// ErrOCCUnexpectedWrite defines an error encountered by a transaction when it writes state despite being flagged
+	// as not expected to write
+	ErrOCCUnexpectedWrite = Register(RootCodespace, 46, "occ write by tx not expected to write")
+
+	// ErrOCCMemoryLimitExceeded defines an error encountered by a transaction when the readsets and writesets of its
+	// stores exceed the per-tx OCC memory limit
+	ErrOCCMemoryLimitExceeded = Register(RootCodespace, 47, "occ memory limit exceeded")
+
 	// ErrPanic is only set when we recover from a panic, so we know to
 	// redact potentially sensitive system info
 	ErrPanic = Register(UndefinedCodespace, 111222, "panic")
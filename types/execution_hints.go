@@ -0,0 +1,17 @@
+package types
+
+// ExecutionHintsProvider provides the writesets, readsets and gas of a tx as found by simulating it before it is
+// delivered, eg. by a mempool or tx selector that simulates txs to prioritize them. These are only hints for the OCC
+// scheduler: a tx may still read and write different keys once executed against the block's state.
+type ExecutionHintsProvider interface {
+	// ProvideExecutionHints returns the hints for the tx bytes, with nil writesets if the tx was never simulated
+	ProvideExecutionHints(tx []byte) (writesets MappedWritesets, readsets MappedReadsets, gas uint64)
+}
+
+// ExecutionHintsProviderFunc is a function that implements ExecutionHintsProvider
+type ExecutionHintsProviderFunc func(tx []byte) (MappedWritesets, MappedReadsets, uint64)
+
+// ProvideExecutionHints implements ExecutionHintsProvider
+func (f ExecutionHintsProviderFunc) ProvideExecutionHints(tx []byte) (MappedWritesets, MappedReadsets, uint64) {
+	return f(tx)
+}
@@ -2,21 +2,259 @@ package occ
 
 import (
 	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+const (
+	// PrefillIncarnation is the incarnation used for estimates prefilled from a tx's estimated writesets before
+	// the tx has executed
+	PrefillIncarnation = -1
+	// MaxIncarnation is the highest supported incarnation. It's the largest integer that is exactly representable
+	// as a float32, so incarnations can be emitted as telemetry values without losing precision.
+	MaxIncarnation = 1 << 24
 )
 
 var (
-	ErrReadEstimate = errors.New("multiversion store value contains estimate, cannot read, aborting")
+	ErrReadEstimate       = errors.New("multiversion store value contains estimate, cannot read, aborting")
+	ErrInvalidIncarnation = errors.New("invalid incarnation")
+	ErrSynchronizedStore  = errors.New("synchronized store accessed before lower-index txs were validated, aborting")
+	ErrSequentialBarrier  = errors.New("tx held back by a sequential-only tx, aborting")
 )
 
+// AbortReason classifies what caused a transaction to abort
+type AbortReason int
+
+const (
+	// AbortReasonUnknown is the reason of an abort that wasn't classified
+	AbortReasonUnknown AbortReason = iota
+	// AbortReasonEstimateRead is a read of a key that a lower-index tx is estimated to write
+	AbortReasonEstimateRead
+	// AbortReasonIteratorConflict is an iteration over a key that a lower-index tx is estimated to write
+	AbortReasonIteratorConflict
+	// AbortReasonGasExhaustion is an abort after which the tx ran out of gas, eg. while unwinding from the abort
+	AbortReasonGasExhaustion
+	// AbortReasonPanicRecovered is an abort whose panic was recovered by the tx itself, which then carried on
+	AbortReasonPanicRecovered
+	// AbortReasonSynchronizedStore is an access to a synchronized store before every lower-index tx was validated
+	AbortReasonSynchronizedStore
+	// AbortReasonSequentialBarrier is an execution held back by a sequential-only tx, either its own or a lower-index
+	// one, until the txs before it are validated
+	AbortReasonSequentialBarrier
+	// AbortReasonInjected is an abort forced by a failpoint, see internal/failpoint
+	AbortReasonInjected
+)
+
+var abortReasonNames = map[AbortReason]string{
+	AbortReasonUnknown:           "unknown",
+	AbortReasonEstimateRead:      "estimate_read",
+	AbortReasonIteratorConflict:  "iterator_conflict",
+	AbortReasonGasExhaustion:     "gas_exhaustion",
+	AbortReasonPanicRecovered:    "panic_recovered",
+	AbortReasonSynchronizedStore: "synchronized_store",
+	AbortReasonSequentialBarrier: "sequential_barrier",
+	AbortReasonInjected:          "injected",
+}
+
+// String returns the name of the reason, as used in telemetry labels and trace attributes
+func (r AbortReason) String() string {
+	if name, ok := abortReasonNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("AbortReason(%d)", int(r))
+}
+
 // Abort contains the information for a transaction's conflict
 type Abort struct {
 	DependentTxIdx int
 	Err            error
+	// Reason is what caused the abort
+	Reason AbortReason
+	// StoreKey is the name of the store that the conflicting key belongs to, if known
+	StoreKey string
+	// Key is the key that triggered the abort, if known
+	Key []byte
 }
 
-func NewEstimateAbort(dependentTxIdx int) Abort {
+// NewEstimateAbort returns the abort of a read of key in the given store, that hit an estimate of the dependent tx
+func NewEstimateAbort(dependentTxIdx int, storeKey string, key []byte) Abort {
 	return Abort{
 		DependentTxIdx: dependentTxIdx,
 		Err:            ErrReadEstimate,
+		Reason:         AbortReasonEstimateRead,
+		StoreKey:       storeKey,
+		Key:            key,
+	}
+}
+
+// NewIteratorConflictAbort returns the abort of an iteration over key in the given store, that hit an estimate of
+// the dependent tx
+func NewIteratorConflictAbort(dependentTxIdx int, storeKey string, key []byte) Abort {
+	return Abort{
+		DependentTxIdx: dependentTxIdx,
+		Err:            ErrReadEstimate,
+		Reason:         AbortReasonIteratorConflict,
+		StoreKey:       storeKey,
+		Key:            key,
+	}
+}
+
+// NewSynchronizedStoreAbort returns the abort of an access to a synchronized store, that has to wait for the dependent
+// tx to be validated
+func NewSynchronizedStoreAbort(dependentTxIdx int, storeKey string) Abort {
+	return Abort{
+		DependentTxIdx: dependentTxIdx,
+		Err:            ErrSynchronizedStore,
+		Reason:         AbortReasonSynchronizedStore,
+		StoreKey:       storeKey,
+	}
+}
+
+// Error implements error, so that aborts can be returned and wrapped like errors, and detected with IsOCCAbort
+func (a Abort) Error() string {
+	return fmt.Sprintf("occ abort with dependent index %d (reason: %s, store: %s, key: %X): %v", a.DependentTxIdx, a.Reason, a.StoreKey, a.Key, a.Err)
+}
+
+// Unwrap returns the error the abort was raised with, eg. ErrReadEstimate
+func (a Abort) Unwrap() error {
+	return a.Err
+}
+
+// IsOCCAbort returns true if err is or wraps an OCC abort, one of the errors aborts are raised with, or the error a
+// tx fails with when it's aborted. Code that handles errors, eg. middleware or the recovery of a handler, should let
+// these through rather than treating them as failures of the tx, which is re-executed once its dependency is done.
+func IsOCCAbort(err error) bool {
+	if err == nil {
+		return false
+	}
+	var abort Abort
+	return errors.As(err, &abort) || errors.Is(err, ErrReadEstimate) || errors.Is(err, ErrSynchronizedStore) ||
+		errors.Is(err, ErrSequentialBarrier) || errors.Is(err, sdkerrors.ErrOCCAbort)
+}
+
+// AsAbort returns the abort that err is or wraps
+func AsAbort(err error) (Abort, bool) {
+	var abort Abort
+	if err == nil || !errors.As(err, &abort) {
+		return Abort{}, false
+	}
+	return abort, true
+}
+
+// RecoveredAbort returns the abort that a recovered panic value is or, if it's an error, wraps, so that code recovering
+// panics can tell aborts apart and re-panic them instead of swallowing them
+func RecoveredAbort(r interface{}) (Abort, bool) {
+	switch recovered := r.(type) {
+	case Abort:
+		return recovered, true
+	case *Abort:
+		if recovered == nil {
+			return Abort{}, false
+		}
+		return *recovered, true
+	case error:
+		return AsAbort(recovered)
+	default:
+		return Abort{}, false
+	}
+}
+
+// NewSequentialBarrierAbort returns the abort of an execution held back by a sequential-only tx, that has to wait for
+// the dependent tx to be validated
+func NewSequentialBarrierAbort(dependentTxIdx int) Abort {
+	return Abort{
+		DependentTxIdx: dependentTxIdx,
+		Err:            ErrSequentialBarrier,
+		Reason:         AbortReasonSequentialBarrier,
+	}
+}
+
+// WithReason returns a copy of the abort with the reason replaced
+func (a Abort) WithReason(reason AbortReason) Abort {
+	a.Reason = reason
+	return a
+}
+
+// AbortSignal records the first abort hit by any of the version indexed stores of a transaction's execution. Once
+// it's signaled, the stores panic with that abort on every further operation, so that an execution which recovered
+// the abort's panic (eg. a handler recovering panics, or a gas meter's recovery converting it into an error) stops at
+// its next store operation, rather than running to completion or until its gas is exhausted, even though its result
+// is bound to be discarded. It's safe to use from multiple goroutines.
+type AbortSignal struct {
+	once  sync.Once
+	abort atomic.Value // Abort
+}
+
+// NewAbortSignal returns a signal that hasn't been signaled yet
+func NewAbortSignal() *AbortSignal {
+	return &AbortSignal{}
+}
+
+// Signal records the abort, unless an abort was already signaled
+func (s *AbortSignal) Signal(abort Abort) {
+	s.once.Do(func() { s.abort.Store(abort) })
+}
+
+// Aborted returns the first abort signaled, or nil if there was none
+func (s *AbortSignal) Aborted() *Abort {
+	abort, ok := s.abort.Load().(Abort)
+	if !ok {
+		return nil
+	}
+	return &abort
+}
+
+// LimitExceeded is panicked by a version indexed store when a transaction exceeds one of its per-tx resource limits.
+// Unlike an Abort, it isn't retried optimistically: the execution stops, and the scheduler executes the tx again
+// sequentially, without limits, so the response of the stopped execution is discarded.
+type LimitExceeded struct {
+	Descriptor string
+	Limit      int
+}
+
+func (e LimitExceeded) Error() string {
+	return fmt.Sprintf("%s limit of %d exceeded", e.Descriptor, e.Limit)
+}
+
+// MemoryLimitExceeded is panicked by a version indexed store when the readsets and writesets of a transaction's stores
+// have grown past its memory limit. Like LimitExceeded the execution stops, and the scheduler executes the tx again
+// sequentially, without the limit.
+type MemoryLimitExceeded struct {
+	Limit int
+	Used  int
+}
+
+func (e MemoryLimitExceeded) Error() string {
+	return fmt.Sprintf("memory limit of %d bytes exceeded with %d bytes", e.Limit, e.Used)
+}
+
+// UndeclaredWrite is the first write of a transaction to a key outside of its declared writeset, which fails the
+// transaction if strict writesets are enabled by the consensus parameters (see baseapp.OCCParams).
+type UndeclaredWrite struct {
+	StoreKey string
+	Key      []byte
+}
+
+func (e UndeclaredWrite) Error() string {
+	return fmt.Sprintf("write to key %X in store %s outside of the declared writeset", e.Key, e.StoreKey)
+}
+
+// ValidateIncarnation returns an error if the incarnation is neither the prefill sentinel nor within [0, MaxIncarnation]
+func ValidateIncarnation(incarnation int) error {
+	if incarnation < PrefillIncarnation || incarnation > MaxIncarnation {
+		return fmt.Errorf("%w: %d", ErrInvalidIncarnation, incarnation)
+	}
+	return nil
+}
+
+// IncarnationString renders an incarnation for telemetry and tracing, naming the sentinel values
+func IncarnationString(incarnation int) string {
+	if incarnation == PrefillIncarnation {
+		return "prefill"
 	}
+	return strconv.Itoa(incarnation)
 }
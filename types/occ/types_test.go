@@ -0,0 +1,102 @@
+package occ_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/types/occ"
+)
+
+func TestValidateIncarnation(t *testing.T) {
+	require.NoError(t, occ.ValidateIncarnation(occ.PrefillIncarnation))
+	require.NoError(t, occ.ValidateIncarnation(0))
+	require.NoError(t, occ.ValidateIncarnation(occ.MaxIncarnation))
+	require.ErrorIs(t, occ.ValidateIncarnation(-2), occ.ErrInvalidIncarnation)
+	require.ErrorIs(t, occ.ValidateIncarnation(occ.MaxIncarnation+1), occ.ErrInvalidIncarnation)
+}
+
+func TestIncarnationString(t *testing.T) {
+	require.Equal(t, "prefill", occ.IncarnationString(occ.PrefillIncarnation))
+	require.Equal(t, "0", occ.IncarnationString(0))
+	require.Equal(t, "16777216", occ.IncarnationString(occ.MaxIncarnation))
+}
+
+func TestAbortReasonString(t *testing.T) {
+	require.Equal(t, "estimate_read", occ.AbortReasonEstimateRead.String())
+	require.Equal(t, "iterator_conflict", occ.AbortReasonIteratorConflict.String())
+	require.Equal(t, "gas_exhaustion", occ.AbortReasonGasExhaustion.String())
+	require.Equal(t, "panic_recovered", occ.AbortReasonPanicRecovered.String())
+	require.Equal(t, "sequential_barrier", occ.AbortReasonSequentialBarrier.String())
+	require.Equal(t, "unknown", occ.Abort{}.Reason.String())
+	require.Equal(t, "AbortReason(42)", occ.AbortReason(42).String())
+}
+
+func TestAbortWithReason(t *testing.T) {
+	abort := occ.NewIteratorConflictAbort(3, "bank", []byte("key"))
+	require.Equal(t, occ.AbortReasonIteratorConflict, abort.Reason)
+
+	refined := abort.WithReason(occ.AbortReasonGasExhaustion)
+	require.Equal(t, occ.AbortReasonGasExhaustion, refined.Reason)
+	require.Equal(t, 3, refined.DependentTxIdx)
+	require.Equal(t, "bank", refined.StoreKey)
+	require.Equal(t, []byte("key"), refined.Key)
+	require.Equal(t, occ.ErrReadEstimate, refined.Err)
+	// the original abort is left as is
+	require.Equal(t, occ.AbortReasonIteratorConflict, abort.Reason)
+}
+
+func TestAbortSignal(t *testing.T) {
+	signal := occ.NewAbortSignal()
+	require.Nil(t, signal.Aborted())
+
+	// only the first abort is kept
+	first := occ.NewEstimateAbort(1, "bank", []byte("key1"))
+	signal.Signal(first)
+	signal.Signal(occ.NewIteratorConflictAbort(2, "bank", []byte("key2")))
+	require.Equal(t, &first, signal.Aborted())
+}
+
+func TestIsOCCAbort(t *testing.T) {
+	abort := occ.NewEstimateAbort(3, "bank", []byte{0xab})
+	require.True(t, occ.IsOCCAbort(abort))
+	require.True(t, occ.IsOCCAbort(fmt.Errorf("wrapped: %w", abort)))
+	require.True(t, occ.IsOCCAbort(occ.ErrReadEstimate))
+	require.True(t, occ.IsOCCAbort(occ.ErrSynchronizedStore))
+	require.True(t, occ.IsOCCAbort(occ.NewSequentialBarrierAbort(1)))
+	require.True(t, occ.IsOCCAbort(sdkerrors.Wrap(sdkerrors.ErrOCCAbort, "aborted")))
+	require.ErrorIs(t, abort, occ.ErrReadEstimate)
+	require.Contains(t, abort.Error(), "dependent index 3 (reason: estimate_read, store: bank, key: AB)")
+
+	require.False(t, occ.IsOCCAbort(nil))
+	require.False(t, occ.IsOCCAbort(errors.New("other")))
+	require.False(t, occ.IsOCCAbort(occ.LimitExceeded{Descriptor: "store operations", Limit: 10}))
+}
+
+func TestAsAbort(t *testing.T) {
+	abort := occ.NewSynchronizedStoreAbort(2, "oracle")
+	found, ok := occ.AsAbort(fmt.Errorf("wrapped: %w", abort))
+	require.True(t, ok)
+	require.Equal(t, abort, found)
+
+	_, ok = occ.AsAbort(occ.ErrReadEstimate)
+	require.False(t, ok)
+	_, ok = occ.AsAbort(nil)
+	require.False(t, ok)
+}
+
+func TestRecoveredAbort(t *testing.T) {
+	abort := occ.NewEstimateAbort(1, "bank", []byte("key"))
+	for _, r := range []interface{}{abort, &abort, fmt.Errorf("recovered: %w", abort)} {
+		found, ok := occ.RecoveredAbort(r)
+		require.True(t, ok)
+		require.Equal(t, abort, found)
+	}
+	for _, r := range []interface{}{nil, (*occ.Abort)(nil), "abort", errors.New("other")} {
+		_, ok := occ.RecoveredAbort(r)
+		require.False(t, ok)
+	}
+}
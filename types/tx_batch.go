@@ -10,11 +10,32 @@ import (
 type DeliverTxEntry struct {
 	Request            abci.RequestDeliverTx
 	EstimatedWritesets MappedWritesets
+	EstimateConfidence EstimateConfidence
+	// EstimatedReadsets and EstimatedGas are hints from a pre-simulation of the tx (eg. in CheckTx), if any
+	EstimatedReadsets MappedReadsets
+	EstimatedGas      uint64
+	// NoWritesExpected flags a tx that isn't expected to write any state (eg. a signature-only or memo tx), which the
+	// scheduler only validates the reads of, skipping the flush of its writeset. It's only a hint: a flagged tx that
+	// writes anyway is handled like any other tx, so the flag never changes the result of the tx.
+	NoWritesExpected bool
 }
 
+// EstimateConfidence describes how far the estimated writesets of a transaction can be trusted
+type EstimateConfidence int
+
+const (
+	// EstimateConfidenceHint means the estimated writesets are only conflict hints, and may be incomplete
+	EstimateConfidenceHint EstimateConfidence = iota
+	// EstimateConfidenceGuaranteed means the estimated writesets contain every key the transaction may write
+	EstimateConfidenceGuaranteed
+)
+
 // EstimatedWritesets represents an estimated writeset for a transaction mapped by storekey to the writeset estimate.
 type MappedWritesets map[StoreKey]multiversion.WriteSet
 
+// MappedReadsets represents the readsets of a transaction mapped by storekey
+type MappedReadsets map[StoreKey]multiversion.ReadSet
+
 // DeliverTxBatchRequest represents a request object for a batch of transactions.
 // This can be extended to include request-level tracing or metadata
 type DeliverTxBatchRequest struct {
@@ -31,4 +52,7 @@ type DeliverTxResult struct {
 // This can be extended to include response-level tracing or metadata
 type DeliverTxBatchResponse struct {
 	Results []*DeliverTxResult
+	// WritesetHash is the hash of the batch's final writesets, if the scheduler hashes writesets and didn't fall back to
+	// sequential execution
+	WritesetHash []byte
 }
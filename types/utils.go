@@ -7,6 +7,8 @@ import (
 	"time"
 
 	dbm "github.com/tendermint/tm-db"
+
+	storetypes "github.com/cosmos/cosmos-sdk/store/types"
 )
 
 var (
@@ -97,10 +99,5 @@ func NewLevelDB(name, dir string) (db dbm.DB, err error) {
 
 // copy bytes
 func CopyBytes(bz []byte) (ret []byte) {
-	if bz == nil {
-		return nil
-	}
-	ret = make([]byte, len(bz))
-	copy(ret, bz)
-	return ret
+	return storetypes.CopyBytes(bz)
 }
@@ -0,0 +1,97 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+// WritesetChangeUnknown is the kind of writes that no decoder recognizes
+const WritesetChangeUnknown = "unknown"
+
+// WritesetChange is a single write of a tx decoded into a typed change of module state, eg. a balance change in the
+// bank store, for execution reports, debugging dumps and indexers
+type WritesetChange struct {
+	// Kind names the kind of state changed, eg. "balance", or WritesetChangeUnknown
+	Kind string `json:"kind"`
+	Key  []byte `json:"key"`
+	// Attributes identify the changed state in human readable form, eg. the address and denom of a balance
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Deleted is whether the write is a delete, in which case there's no value
+	Deleted bool `json:"deleted,omitempty"`
+	// Value is the decoded value written, eg. a Coin for a balance
+	Value interface{} `json:"value,omitempty"`
+}
+
+// WritesetDecoder decodes the writes to a module's store into typed changes. Writes to keys the decoder doesn't
+// recognize are returned with the kind WritesetChangeUnknown, and values it fails to decode are errors. Deletes have
+// a nil value, which isn't decoded. Keys and deletes are filled in by the registry, so a decoder only sets the kind,
+// attributes and value.
+type WritesetDecoder interface {
+	DecodeWrite(key, value []byte) (WritesetChange, error)
+}
+
+// WritesetDecoderFunc is a function that implements WritesetDecoder
+type WritesetDecoderFunc func(key, value []byte) (WritesetChange, error)
+
+// DecodeWrite implements WritesetDecoder
+func (f WritesetDecoderFunc) DecodeWrite(key, value []byte) (WritesetChange, error) {
+	return f(key, value)
+}
+
+// WritesetDecoderRegistry maps store key names to the decoders of the writes to their stores
+type WritesetDecoderRegistry struct {
+	decoders map[string]WritesetDecoder
+}
+
+// NewWritesetDecoderRegistry creates an empty writeset decoder registry
+func NewWritesetDecoderRegistry() *WritesetDecoderRegistry {
+	return &WritesetDecoderRegistry{decoders: make(map[string]WritesetDecoder)}
+}
+
+// Register registers the decoder of the writes to the store. It panics if the store already has a decoder.
+func (r *WritesetDecoderRegistry) Register(storeKey StoreKey, decoder WritesetDecoder) {
+	name := storeKey.Name()
+	if _, ok := r.decoders[name]; ok {
+		panic(fmt.Sprintf("writeset decoder for %s already registered", name))
+	}
+	r.decoders[name] = decoder
+}
+
+// DecodeWriteset decodes a writeset to the store with the given key name into changes sorted by key. The writes to
+// stores without a decoder are all unknown changes.
+func (r *WritesetDecoderRegistry) DecodeWriteset(storeName string, writeset multiversion.WriteSet) ([]WritesetChange, error) {
+	decoder := r.decoders[storeName]
+	changes := make([]WritesetChange, 0, len(writeset))
+	for key, value := range writeset {
+		change := WritesetChange{Kind: WritesetChangeUnknown}
+		if decoder != nil {
+			var err error
+			if change, err = decoder.DecodeWrite([]byte(key), value); err != nil {
+				return nil, fmt.Errorf("failed to decode write to %X in %s: %w", key, storeName, err)
+			}
+		}
+		change.Key = []byte(key)
+		change.Deleted = value == nil
+		if change.Deleted {
+			change.Value = nil
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return string(changes[i].Key) < string(changes[j].Key) })
+	return changes, nil
+}
+
+// DecodeWritesets decodes the writesets of a tx into changes by store key name
+func (r *WritesetDecoderRegistry) DecodeWritesets(writesets MappedWritesets) (map[string][]WritesetChange, error) {
+	changes := make(map[string][]WritesetChange, len(writesets))
+	for storeKey, writeset := range writesets {
+		storeChanges, err := r.DecodeWriteset(storeKey.Name(), writeset)
+		if err != nil {
+			return nil, err
+		}
+		changes[storeKey.Name()] = storeChanges
+	}
+	return changes, nil
+}
@@ -0,0 +1,55 @@
+package types_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestWritesetDecoderRegistry(t *testing.T) {
+	key1 := sdk.NewKVStoreKey("key1")
+	key2 := sdk.NewKVStoreKey("key2")
+
+	registry := sdk.NewWritesetDecoderRegistry()
+	registry.Register(key1, sdk.WritesetDecoderFunc(func(key, value []byte) (sdk.WritesetChange, error) {
+		if string(key) == "bad" {
+			return sdk.WritesetChange{}, errors.New("bad value")
+		}
+		if string(key) != "counter" {
+			return sdk.WritesetChange{Kind: sdk.WritesetChangeUnknown}, nil
+		}
+		return sdk.WritesetChange{Kind: "counter", Attributes: map[string]string{"name": "counter"}, Value: string(value)}, nil
+	}))
+	require.Panics(t, func() {
+		registry.Register(key1, sdk.WritesetDecoderFunc(func(key, value []byte) (sdk.WritesetChange, error) {
+			return sdk.WritesetChange{}, nil
+		}))
+	})
+
+	changes, err := registry.DecodeWritesets(sdk.MappedWritesets{
+		key1: {"counter": []byte("1"), "other": []byte("2")},
+		key2: {"deleted": nil},
+	})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]sdk.WritesetChange{
+		"key1": {
+			{Kind: "counter", Key: []byte("counter"), Attributes: map[string]string{"name": "counter"}, Value: "1"},
+			{Kind: sdk.WritesetChangeUnknown, Key: []byte("other")},
+		},
+		// stores without a decoder only have unknown changes
+		"key2": {{Kind: sdk.WritesetChangeUnknown, Key: []byte("deleted"), Deleted: true}},
+	}, changes)
+
+	// deletes carry no value
+	changes1, err := registry.DecodeWriteset("key1", map[string][]byte{"counter": nil})
+	require.NoError(t, err)
+	require.Equal(t, []sdk.WritesetChange{
+		{Kind: "counter", Key: []byte("counter"), Attributes: map[string]string{"name": "counter"}, Deleted: true},
+	}, changes1)
+
+	_, err = registry.DecodeWriteset("key1", map[string][]byte{"bad": []byte("value")})
+	require.Error(t, err)
+}
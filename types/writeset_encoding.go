@@ -0,0 +1,168 @@
+package types
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+// Estimated writesets are encoded compactly, so that external tooling (eg. a mempool sidecar or an access list
+// generator) can hand them to the app alongside txs. An encoding is laid out as a version byte, the number of stores,
+// and for every store in name order: its uvarint-prefixed name, the number of its keys, and every key in ascending
+// order, uvarint-prefixed, followed by its value as a uvarint of its length plus one, or zero for a nil value, and the
+// value itself. Estimates rarely carry values, so most writes take a single byte on top of their key.
+const writesetEncodingVersion = 1
+
+// ErrCorruptWritesets is returned when decoding data that isn't well-formed encoded writesets
+var ErrCorruptWritesets = errors.New("corrupt encoded writesets")
+
+// MarshalMappedWritesets encodes writesets in the compact binary encoding decoded by UnmarshalMappedWritesets. The
+// encoding is deterministic: equal writesets encode to the same bytes.
+func MarshalMappedWritesets(writesets MappedWritesets) []byte {
+	storeKeys := make([]StoreKey, 0, len(writesets))
+	size := 1 + binary.MaxVarintLen64
+	for storeKey, writeset := range writesets {
+		storeKeys = append(storeKeys, storeKey)
+		size += 2*binary.MaxVarintLen64 + len(storeKey.Name())
+		for key, value := range writeset {
+			size += 2*binary.MaxVarintLen64 + len(key) + len(value)
+		}
+	}
+	sort.Slice(storeKeys, func(i, j int) bool { return storeKeys[i].Name() < storeKeys[j].Name() })
+
+	bz := make([]byte, 0, size)
+	bz = append(bz, writesetEncodingVersion)
+	bz = appendUvarint(bz, uint64(len(storeKeys)))
+	for _, storeKey := range storeKeys {
+		writeset := writesets[storeKey]
+		bz = appendUvarint(bz, uint64(len(storeKey.Name())))
+		bz = append(bz, storeKey.Name()...)
+		keys := make([]string, 0, len(writeset))
+		for key := range writeset {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		bz = appendUvarint(bz, uint64(len(keys)))
+		for _, key := range keys {
+			value := writeset[key]
+			bz = appendUvarint(bz, uint64(len(key)))
+			bz = append(bz, key...)
+			if value == nil {
+				bz = appendUvarint(bz, 0)
+				continue
+			}
+			bz = appendUvarint(bz, uint64(len(value))+1)
+			bz = append(bz, value...)
+		}
+	}
+	return bz
+}
+
+// UnmarshalMappedWritesets decodes writesets encoded by MarshalMappedWritesets, resolving the stores they write by
+// name from storeKeys. Writesets of stores missing from storeKeys are an error rather than dropped, since estimates of
+// the wrong app are more likely than estimates of stores it doesn't have.
+func UnmarshalMappedWritesets(bz []byte, storeKeys map[string]StoreKey) (MappedWritesets, error) {
+	d := writesetDecoder{bz: bz}
+	if version := d.byte(); d.err == nil && version != writesetEncodingVersion {
+		return nil, fmt.Errorf("%w: unknown version %d", ErrCorruptWritesets, version)
+	}
+	numStores := d.count()
+	writesets := make(MappedWritesets, numStores)
+	for i := 0; i < numStores && d.err == nil; i++ {
+		name := d.string()
+		storeKey, ok := storeKeys[name]
+		if d.err == nil && !ok {
+			return nil, fmt.Errorf("writesets of unknown store %q", name)
+		}
+		if _, ok := writesets[storeKey]; ok {
+			return nil, fmt.Errorf("%w: store %q encoded twice", ErrCorruptWritesets, name)
+		}
+		numKeys := d.count()
+		writeset := make(multiversion.WriteSet, numKeys)
+		for j := 0; j < numKeys && d.err == nil; j++ {
+			key := d.string()
+			var value []byte
+			if n := d.uvarint(); n > 0 {
+				// values are copied so that the writesets don't retain the encoding
+				value = append([]byte{}, d.next(n-1)...)
+			}
+			writeset[key] = value
+		}
+		writesets[storeKey] = writeset
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.bz) > 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes", ErrCorruptWritesets, len(d.bz))
+	}
+	return writesets, nil
+}
+
+func appendUvarint(bz []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(bz, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// writesetDecoder consumes encoded writesets, keeping the first error it runs into, after which it only returns zero
+// values
+type writesetDecoder struct {
+	bz  []byte
+	err error
+}
+
+func (d *writesetDecoder) fail(reason string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s", ErrCorruptWritesets, reason)
+	}
+	d.bz = nil
+}
+
+func (d *writesetDecoder) byte() byte {
+	if len(d.bz) == 0 {
+		d.fail("unexpected end")
+		return 0
+	}
+	b := d.bz[0]
+	d.bz = d.bz[1:]
+	return b
+}
+
+func (d *writesetDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.bz)
+	if n <= 0 {
+		d.fail("invalid uvarint")
+		return 0
+	}
+	d.bz = d.bz[n:]
+	return v
+}
+
+// count decodes a number of entries, which can't exceed the remaining bytes since every entry takes at least one
+func (d *writesetDecoder) count() int {
+	n := d.uvarint()
+	if n > uint64(len(d.bz)) {
+		d.fail("count exceeds data")
+		return 0
+	}
+	return int(n)
+}
+
+// next consumes n bytes, returning a slice of the encoding, which callers must copy to keep
+func (d *writesetDecoder) next(n uint64) []byte {
+	if n > uint64(len(d.bz)) {
+		d.fail("length exceeds data")
+		return nil
+	}
+	b := d.bz[:n:n]
+	d.bz = d.bz[n:]
+	return b
+}
+
+// string consumes a uvarint-prefixed string
+func (d *writesetDecoder) string() string {
+	return string(d.next(d.uvarint()))
+}
@@ -0,0 +1,99 @@
+package types_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestMappedWritesetsEncoding(t *testing.T) {
+	bank := sdk.NewKVStoreKey("bank")
+	staking := sdk.NewKVStoreKey("staking")
+	storeKeys := map[string]sdk.StoreKey{"bank": bank, "staking": staking}
+
+	writesets := sdk.MappedWritesets{
+		bank: {
+			"balance-a": nil,
+			"balance-b": []byte("value"),
+			"empty":     {},
+		},
+		staking: {},
+	}
+	bz := sdk.MarshalMappedWritesets(writesets)
+	decoded, err := sdk.UnmarshalMappedWritesets(bz, storeKeys)
+	require.NoError(t, err)
+	require.Equal(t, writesets, decoded)
+	// nil values stay apart from empty ones
+	require.Nil(t, decoded[bank]["balance-a"])
+	require.NotNil(t, decoded[bank]["empty"])
+	// the encoding doesn't depend on map iteration order
+	for i := 0; i < 10; i++ {
+		require.Equal(t, bz, sdk.MarshalMappedWritesets(writesets))
+	}
+
+	decoded, err = sdk.UnmarshalMappedWritesets(sdk.MarshalMappedWritesets(nil), storeKeys)
+	require.NoError(t, err)
+	require.Empty(t, decoded)
+
+	_, err = sdk.UnmarshalMappedWritesets(bz, map[string]sdk.StoreKey{"bank": bank})
+	require.EqualError(t, err, `writesets of unknown store "staking"`)
+
+	for name, corrupt := range map[string][]byte{
+		"empty":          nil,
+		"version":        append([]byte{2}, bz[1:]...),
+		"truncated":      bz[:len(bz)-1],
+		"trailing bytes": append(append([]byte{}, bz...), 0),
+		"huge count":     {1, 0xff, 0xff, 0xff, 0xff, 0x0f},
+	} {
+		_, err := sdk.UnmarshalMappedWritesets(corrupt, storeKeys)
+		require.ErrorIs(t, err, sdk.ErrCorruptWritesets, name)
+	}
+}
+
+// benchmarkWritesets returns the estimated writesets of a typical tx, which writes a few keys without values to
+// each of a few stores
+func benchmarkWritesets(stores int, keys int) (sdk.MappedWritesets, map[string]sdk.StoreKey) {
+	writesets := make(sdk.MappedWritesets, stores)
+	storeKeys := make(map[string]sdk.StoreKey, stores)
+	for i := 0; i < stores; i++ {
+		storeKey := sdk.NewKVStoreKey(fmt.Sprintf("store%d", i))
+		storeKeys[storeKey.Name()] = storeKey
+		writeset := make(multiversion.WriteSet, keys)
+		for j := 0; j < keys; j++ {
+			writeset[fmt.Sprintf("\x02%032d/%s", j, "usei")] = nil
+		}
+		writesets[storeKey] = writeset
+	}
+	return writesets, storeKeys
+}
+
+// BenchmarkMappedWritesetsEncoding reports the size of encoded writesets along with the cost of encoding and decoding
+// them, where the bytes allocated by decoding are the heap the writesets take as in-memory maps
+func BenchmarkMappedWritesetsEncoding(b *testing.B) {
+	for _, size := range []struct{ stores, keys int }{{2, 4}, {4, 32}} {
+		writesets, storeKeys := benchmarkWritesets(size.stores, size.keys)
+		bz := sdk.MarshalMappedWritesets(writesets)
+		name := fmt.Sprintf("stores=%d/keys=%d", size.stores, size.keys)
+
+		b.Run(name+"/encode", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				sdk.MarshalMappedWritesets(writesets)
+			}
+			b.ReportMetric(float64(len(bz)), "encoded-bytes")
+		})
+		b.Run(name+"/decode", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := sdk.UnmarshalMappedWritesets(bz, storeKeys); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(bz)), "encoded-bytes")
+		})
+	}
+}
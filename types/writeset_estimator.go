@@ -0,0 +1,71 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+)
+
+// WritesetEstimator estimates the keys a msg writes, by store, without executing it. Estimates are only conflict
+// hints for the OCC scheduler, so they don't need to be complete, but keys that aren't written make for needless
+// waits.
+type WritesetEstimator interface {
+	EstimateWritesets(ctx Context, msg Msg) (MappedWritesets, error)
+}
+
+// WritesetEstimatorFunc is a function that implements WritesetEstimator
+type WritesetEstimatorFunc func(ctx Context, msg Msg) (MappedWritesets, error)
+
+// EstimateWritesets implements WritesetEstimator
+func (f WritesetEstimatorFunc) EstimateWritesets(ctx Context, msg Msg) (MappedWritesets, error) {
+	return f(ctx, msg)
+}
+
+// Merge adds the keys of other to the writesets
+func (m MappedWritesets) Merge(other MappedWritesets) {
+	for storeKey, writeset := range other {
+		if _, ok := m[storeKey]; !ok {
+			m[storeKey] = make(multiversion.WriteSet, len(writeset))
+		}
+		for key, value := range writeset {
+			m[storeKey][key] = value
+		}
+	}
+}
+
+// WritesetEstimatorRegistry maps msg types to the estimators of their writesets
+type WritesetEstimatorRegistry struct {
+	estimators map[string]WritesetEstimator
+}
+
+// NewWritesetEstimatorRegistry creates an empty writeset estimator registry
+func NewWritesetEstimatorRegistry() *WritesetEstimatorRegistry {
+	return &WritesetEstimatorRegistry{estimators: make(map[string]WritesetEstimator)}
+}
+
+// Register registers the estimator for the type of msg. It panics if the type already has an estimator.
+func (r *WritesetEstimatorRegistry) Register(msg Msg, estimator WritesetEstimator) {
+	typeURL := MsgTypeURL(msg)
+	if _, ok := r.estimators[typeURL]; ok {
+		panic(fmt.Sprintf("writeset estimator for %s already registered", typeURL))
+	}
+	r.estimators[typeURL] = estimator
+}
+
+// EstimateTxWritesets merges the estimated writesets of all msgs in the tx. Msgs without an estimator are skipped,
+// so the estimate may be partial.
+func (r *WritesetEstimatorRegistry) EstimateTxWritesets(ctx Context, tx Tx) (MappedWritesets, error) {
+	writesets := make(MappedWritesets)
+	for _, msg := range tx.GetMsgs() {
+		estimator, ok := r.estimators[MsgTypeURL(msg)]
+		if !ok {
+			continue
+		}
+		msgWritesets, err := estimator.EstimateWritesets(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+		writesets.Merge(msgWritesets)
+	}
+	return writesets, nil
+}
@@ -0,0 +1,46 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/testutil/testdata"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// msgsTx is a tx that only carries msgs
+type msgsTx []sdk.Msg
+
+func (tx msgsTx) GetMsgs() []sdk.Msg   { return tx }
+func (tx msgsTx) ValidateBasic() error { return nil }
+
+func TestWritesetEstimatorRegistry(t *testing.T) {
+	key1 := sdk.NewKVStoreKey("key1")
+	key2 := sdk.NewKVStoreKey("key2")
+
+	registry := sdk.NewWritesetEstimatorRegistry()
+	registry.Register(&testdata.TestMsg{}, sdk.WritesetEstimatorFunc(func(_ sdk.Context, msg sdk.Msg) (sdk.MappedWritesets, error) {
+		writesets := sdk.MappedWritesets{key1: {"shared": nil}}
+		for _, signer := range msg.(*testdata.TestMsg).Signers {
+			writesets.Merge(sdk.MappedWritesets{key2: {signer: nil}})
+		}
+		return writesets, nil
+	}))
+	require.Panics(t, func() {
+		registry.Register(&testdata.TestMsg{}, sdk.WritesetEstimatorFunc(nil))
+	})
+
+	tx := msgsTx{
+		&testdata.TestMsg{Signers: []string{"a"}},
+		&testdata.TestMsg{Signers: []string{"b"}},
+		// msgs without an estimator are skipped
+		&testdata.MsgCreateDog{},
+	}
+	writesets, err := registry.EstimateTxWritesets(sdk.Context{}, tx)
+	require.NoError(t, err)
+	require.Equal(t, sdk.MappedWritesets{
+		key1: {"shared": nil},
+		key2: {"a": nil, "b": nil},
+	}, writesets)
+}
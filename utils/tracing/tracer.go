@@ -63,13 +63,13 @@ func (i *Info) Start(name string) (context.Context, otrace.Span) {
 	return (*i.Tracer).Start(i.tracerContext, name)
 }
 
-func (i *Info) StartWithContext(name string, ctx context.Context) (context.Context, otrace.Span) {
-	i.mtx.Lock()
-	defer i.mtx.Unlock()
+// StartWithContext starts a span as a child of the span of ctx, if any. Unlike Start, it doesn't touch the shared
+// tracer context, and tracers are safe for concurrent use, so spans may be started concurrently without contending.
+func (i *Info) StartWithContext(name string, ctx context.Context, opts ...otrace.SpanStartOption) (context.Context, otrace.Span) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	return (*i.Tracer).Start(ctx, name)
+	return (*i.Tracer).Start(ctx, name, opts...)
 }
 
 func (i *Info) GetContext() context.Context {
@@ -82,11 +82,11 @@ func (s msgServer) CreateVestingAccount(goCtx context.Context, msg *types.MsgCre
 	ak.SetAccount(ctx, acc)
 
 	defer func() {
-		telemetry.IncrCounter(1, "new", "account")
+		ctx.Telemetry().IncrCounter(1, "new", "account")
 
 		for _, a := range msg.Amount {
 			if a.Amount.IsInt64() {
-				telemetry.SetGaugeWithLabels(
+				ctx.Telemetry().SetGaugeWithLabels(
 					[]string{"tx", "msg", "create_vesting_account"},
 					float32(a.Amount.Int64()),
 					[]metrics.Label{telemetry.NewLabel("denom", a.Denom)},
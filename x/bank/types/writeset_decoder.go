@@ -0,0 +1,74 @@
+package types
+
+import (
+	"bytes"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// Kinds of the bank store changes decoded by the bank writeset decoder
+const (
+	WritesetChangeBalance       = "balance"
+	WritesetChangeSupply        = "supply"
+	WritesetChangeDenomMetadata = "denom_metadata"
+)
+
+// RegisterWritesetDecoders registers the decoder of the writes to the bank store, which decodes balance changes into
+// Coins by address and denom, supply changes into Ints by denom and denom metadata changes into Metadata. Deleted
+// balances and supplies are zero.
+func RegisterWritesetDecoders(registry *sdk.WritesetDecoderRegistry, storeKey sdk.StoreKey, cdc codec.BinaryCodec) {
+	registry.Register(storeKey, sdk.WritesetDecoderFunc(func(key, value []byte) (sdk.WritesetChange, error) {
+		switch {
+		case bytes.HasPrefix(key, BalancesPrefix):
+			addr, err := AddressFromBalancesStore(key[len(BalancesPrefix):])
+			if err != nil {
+				break
+			}
+			change := sdk.WritesetChange{
+				Kind: WritesetChangeBalance,
+				Attributes: map[string]string{
+					"address": addr.String(),
+					"denom":   string(key[len(BalancesPrefix)+1+len(addr):]),
+				},
+			}
+			if value != nil {
+				var balance sdk.Coin
+				if err := cdc.Unmarshal(value, &balance); err != nil {
+					return sdk.WritesetChange{}, err
+				}
+				change.Value = balance
+			}
+			return change, nil
+		case bytes.HasPrefix(key, SupplyKey):
+			change := sdk.WritesetChange{
+				Kind:       WritesetChangeSupply,
+				Attributes: map[string]string{"denom": string(key[len(SupplyKey):])},
+			}
+			if value != nil {
+				var amount sdk.Int
+				if err := amount.Unmarshal(value); err != nil {
+					return sdk.WritesetChange{}, err
+				}
+				change.Value = amount
+			}
+			return change, nil
+		case bytes.HasPrefix(key, DenomMetadataPrefix):
+			// metadata is keyed by the denom twice, see BaseKeeper.SetDenomMetaData
+			denom := key[len(DenomMetadataPrefix):]
+			change := sdk.WritesetChange{
+				Kind:       WritesetChangeDenomMetadata,
+				Attributes: map[string]string{"denom": string(denom[:len(denom)/2])},
+			}
+			if value != nil {
+				var metadata Metadata
+				if err := cdc.Unmarshal(value, &metadata); err != nil {
+					return sdk.WritesetChange{}, err
+				}
+				change.Value = metadata
+			}
+			return change, nil
+		}
+		return sdk.WritesetChange{Kind: sdk.WritesetChangeUnknown}, nil
+	}))
+}
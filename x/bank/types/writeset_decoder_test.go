@@ -0,0 +1,50 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/bank/types"
+)
+
+func TestWritesetDecoders(t *testing.T) {
+	cdc := codec.NewProtoCodec(codectypes.NewInterfaceRegistry())
+	storeKey := sdk.NewKVStoreKey(types.StoreKey)
+	registry := sdk.NewWritesetDecoderRegistry()
+	types.RegisterWritesetDecoders(registry, storeKey, cdc)
+
+	addr := sdk.AccAddress([]byte("addr1_______________"))
+	balance := sdk.NewInt64Coin("atom", 10)
+	supply := sdk.NewInt(100)
+	supplyBz, err := supply.Marshal()
+	require.NoError(t, err)
+	metadata := types.Metadata{Base: "uatom", Display: "atom"}
+	balanceKey := string(types.CreatePrefixedAccountStoreKey(addr, []byte("atom")))
+	deletedKey := string(types.CreatePrefixedAccountStoreKey(addr, []byte("usei")))
+	supplyKey := string(append(types.SupplyKey, []byte("atom")...))
+	metadataKey := string(append(types.DenomMetadataKey("uatom"), []byte("uatom")...))
+
+	changes, err := registry.DecodeWriteset(storeKey.Name(), map[string][]byte{
+		balanceKey:  cdc.MustMarshal(&balance),
+		deletedKey:  nil,
+		supplyKey:   supplyBz,
+		metadataKey: cdc.MustMarshal(&metadata),
+		"\x09other": []byte("value"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []sdk.WritesetChange{
+		{Kind: types.WritesetChangeSupply, Key: []byte(supplyKey), Attributes: map[string]string{"denom": "atom"}, Value: supply},
+		{Kind: types.WritesetChangeDenomMetadata, Key: []byte(metadataKey), Attributes: map[string]string{"denom": "uatom"}, Value: metadata},
+		{Kind: types.WritesetChangeBalance, Key: []byte(balanceKey), Attributes: map[string]string{"address": addr.String(), "denom": "atom"}, Value: balance},
+		// deleted balances are zero
+		{Kind: types.WritesetChangeBalance, Key: []byte(deletedKey), Attributes: map[string]string{"address": addr.String(), "denom": "usei"}, Deleted: true},
+		{Kind: sdk.WritesetChangeUnknown, Key: []byte("\x09other")},
+	}, changes)
+
+	_, err = registry.DecodeWriteset(storeKey.Name(), map[string][]byte{balanceKey: []byte("invalid")})
+	require.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// RegisterWritesetEstimators registers the writeset estimators of bank msgs. Sends are estimated to write the sent
+// denom balances of every sender and recipient in the bank store.
+func RegisterWritesetEstimators(registry *sdk.WritesetEstimatorRegistry, storeKey sdk.StoreKey) {
+	estimator := sdk.WritesetEstimatorFunc(func(_ sdk.Context, msg sdk.Msg) (sdk.MappedWritesets, error) {
+		writeset := make(multiversion.WriteSet)
+		addBalances := func(address string, coins sdk.Coins) error {
+			addr, err := sdk.AccAddressFromBech32(address)
+			if err != nil {
+				return err
+			}
+			for _, coin := range coins {
+				writeset[string(balanceKey(addr, coin.Denom))] = nil
+			}
+			return nil
+		}
+
+		switch msg := msg.(type) {
+		case *MsgSend:
+			if err := addBalances(msg.FromAddress, msg.Amount); err != nil {
+				return nil, err
+			}
+			if err := addBalances(msg.ToAddress, msg.Amount); err != nil {
+				return nil, err
+			}
+		case *MsgMultiSend:
+			for _, in := range msg.Inputs {
+				if err := addBalances(in.Address, in.Coins); err != nil {
+					return nil, err
+				}
+			}
+			for _, out := range msg.Outputs {
+				if err := addBalances(out.Address, out.Coins); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unexpected msg type %T", msg)
+		}
+		return sdk.MappedWritesets{storeKey: writeset}, nil
+	})
+	registry.Register(&MsgSend{}, estimator)
+	registry.Register(&MsgMultiSend{}, estimator)
+}
+
+// balanceKey returns the bank store key of an account's balance of a denom
+func balanceKey(addr sdk.AccAddress, denom string) []byte {
+	return append(CreateAccountBalancesPrefix(addr), []byte(denom)...)
+}
@@ -0,0 +1,58 @@
+package types_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/bank/types"
+)
+
+func TestWritesetEstimators(t *testing.T) {
+	storeKey := sdk.NewKVStoreKey(types.StoreKey)
+	registry := sdk.NewWritesetEstimatorRegistry()
+	types.RegisterWritesetEstimators(registry, storeKey)
+
+	addr1 := sdk.AccAddress([]byte("addr1_______________"))
+	addr2 := sdk.AccAddress([]byte("addr2_______________"))
+	addr3 := sdk.AccAddress([]byte("addr3_______________"))
+	balanceKey := func(addr sdk.AccAddress, denom string) string {
+		return string(append(types.CreateAccountBalancesPrefix(addr), []byte(denom)...))
+	}
+	coins := sdk.NewCoins(sdk.NewInt64Coin("atom", 10), sdk.NewInt64Coin("usei", 5))
+
+	writesets, err := registry.EstimateTxWritesets(sdk.Context{}, msgsTx{
+		types.NewMsgSend(addr1, addr2, coins),
+		types.NewMsgMultiSend(
+			[]types.Input{types.NewInput(addr2, sdk.NewCoins(sdk.NewInt64Coin("atom", 10)))},
+			[]types.Output{types.NewOutput(addr3, sdk.NewCoins(sdk.NewInt64Coin("atom", 10)))},
+		),
+	})
+	require.NoError(t, err)
+	require.Len(t, writesets, 1)
+	require.Equal(t, []string{
+		balanceKey(addr1, "atom"), balanceKey(addr1, "usei"),
+		balanceKey(addr2, "atom"), balanceKey(addr2, "usei"),
+		balanceKey(addr3, "atom"),
+	}, sortedKeys(writesets[storeKey]))
+
+	_, err = registry.EstimateTxWritesets(sdk.Context{}, msgsTx{&types.MsgSend{FromAddress: "invalid"}})
+	require.Error(t, err)
+}
+
+// msgsTx is a tx that only carries msgs
+type msgsTx []sdk.Msg
+
+func (tx msgsTx) GetMsgs() []sdk.Msg   { return tx }
+func (tx msgsTx) ValidateBasic() error { return nil }
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
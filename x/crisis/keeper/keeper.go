@@ -78,11 +78,7 @@ func (k Keeper) AssertInvariants(ctx sdk.Context) {
 	for i, ir := range invarRoutes {
 		logger.Info("asserting crisis invariants", "inv", fmt.Sprint(i+1, "/", n), "name", ir.FullRoute())
 		if res, stop := ir.Invar(ctx); stop {
-			// TODO: Include app name as part of context to allow for this to be
-			// variable.
-			panic(fmt.Errorf("invariant broken: %s\n"+
-				"\tCRITICAL please submit the following transaction:\n"+
-				"\t\t tx crisis invariant-broken %s %s", res, ir.ModuleName, ir.Route))
+			panic(brokenInvariantError(res, ir.ModuleName, ir.Route))
 		}
 	}
 
@@ -90,6 +86,32 @@ func (k Keeper) AssertInvariants(ctx sdk.Context) {
 	logger.Info("asserted all invariants", "duration", diff, "height", ctx.BlockHeight())
 }
 
+// RegisterRoutesWith registers all of the keeper's invariant routes with another
+// registry, eg. so that they're also asserted once the writes of the txs of a
+// block are flushed when they're executed in parallel (see tasks.InvariantChecks).
+func (k Keeper) RegisterRoutesWith(registry sdk.InvariantRegistry) {
+	for _, ir := range k.Routes() {
+		registry.RegisterRoute(ir.ModuleName, ir.Route, ir.Invar)
+	}
+}
+
+// HandleBrokenInvariant halts the chain on an invariant found broken outside of
+// AssertInvariants, eg. by the invariant checks of parallel execution (see
+// tasks.BrokenInvariantHandler), the same way AssertInvariants does.
+func (k Keeper) HandleBrokenInvariant(ctx sdk.Context, moduleName, route, res string) {
+	k.Logger(ctx).Error("invariant broken", "name", moduleName+"/"+route, "height", ctx.BlockHeight())
+	panic(brokenInvariantError(res, moduleName, route))
+}
+
+// brokenInvariantError returns the error an invariant broken with the given result halts the chain with
+func brokenInvariantError(res, moduleName, route string) error {
+	// TODO: Include app name as part of context to allow for this to be
+	// variable.
+	return fmt.Errorf("invariant broken: %s\n"+
+		"\tCRITICAL please submit the following transaction:\n"+
+		"\t\t tx crisis invariant-broken %s %s", res, moduleName, route)
+}
+
 // InvCheckPeriod returns the invariant checks period.
 func (k Keeper) InvCheckPeriod() uint { return k.invCheckPeriod }
 
@@ -9,6 +9,7 @@ import (
 	tmproto "github.com/tendermint/tendermint/proto/tendermint/types"
 
 	"github.com/cosmos/cosmos-sdk/simapp"
+	"github.com/cosmos/cosmos-sdk/tasks"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/x/crisis/types"
 )
@@ -46,3 +47,18 @@ func TestAssertInvariants(t *testing.T) {
 	app.CrisisKeeper.RegisterRoute("testModule", "testRoute2", func(sdk.Context) (string, bool) { return "", true })
 	require.Panics(t, func() { app.CrisisKeeper.AssertInvariants(ctx) })
 }
+
+func TestRegisterRoutesWith(t *testing.T) {
+	app := simapp.Setup(false)
+	ctx := app.NewContext(true, tmproto.Header{})
+
+	checks := tasks.NewInvariantChecks(1, app.CrisisKeeper.HandleBrokenInvariant)
+	app.CrisisKeeper.RegisterRoutesWith(checks)
+	require.Equal(t, len(app.CrisisKeeper.Routes()), checks.Len())
+
+	require.PanicsWithError(t, "invariant broken: broken\n"+
+		"\tCRITICAL please submit the following transaction:\n"+
+		"\t\t tx crisis invariant-broken testModule testRoute", func() {
+		app.CrisisKeeper.HandleBrokenInvariant(ctx, "testModule", "testRoute", "broken")
+	})
+}
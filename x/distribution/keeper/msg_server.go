@@ -68,7 +68,7 @@ func (k msgServer) WithdrawDelegatorReward(goCtx context.Context, msg *types.Msg
 	defer func() {
 		for _, a := range amount {
 			if a.Amount.IsInt64() {
-				telemetry.SetGaugeWithLabels(
+				ctx.Telemetry().SetGaugeWithLabels(
 					[]string{"tx", "msg", "withdraw_reward"},
 					float32(a.Amount.Int64()),
 					[]metrics.Label{telemetry.NewLabel("denom", a.Denom)},
@@ -102,7 +102,7 @@ func (k msgServer) WithdrawValidatorCommission(goCtx context.Context, msg *types
 	defer func() {
 		for _, a := range amount {
 			if a.Amount.IsInt64() {
-				telemetry.SetGaugeWithLabels(
+				ctx.Telemetry().SetGaugeWithLabels(
 					[]string{"tx", "msg", "withdraw_commission"},
 					float32(a.Amount.Int64()),
 					[]metrics.Label{telemetry.NewLabel("denom", a.Denom)},
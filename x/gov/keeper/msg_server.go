@@ -31,7 +31,7 @@ func (k msgServer) SubmitProposal(goCtx context.Context, msg *types.MsgSubmitPro
 		return nil, err
 	}
 
-	defer telemetry.IncrCounter(1, types.ModuleName, "proposal")
+	defer ctx.Telemetry().IncrCounter(1, types.ModuleName, "proposal")
 
 	votingStarted, err := k.Keeper.AddDeposit(ctx, proposal.ProposalId, msg.GetProposer(), msg.GetInitialDeposit())
 	if err != nil {
@@ -71,7 +71,7 @@ func (k msgServer) Vote(goCtx context.Context, msg *types.MsgVote) (*types.MsgVo
 		return nil, err
 	}
 
-	defer telemetry.IncrCounterWithLabels(
+	defer ctx.Telemetry().IncrCounterWithLabels(
 		[]string{types.ModuleName, "vote"},
 		1,
 		[]metrics.Label{
@@ -101,7 +101,7 @@ func (k msgServer) VoteWeighted(goCtx context.Context, msg *types.MsgVoteWeighte
 		return nil, err
 	}
 
-	defer telemetry.IncrCounterWithLabels(
+	defer ctx.Telemetry().IncrCounterWithLabels(
 		[]string{types.ModuleName, "vote"},
 		1,
 		[]metrics.Label{
@@ -131,7 +131,7 @@ func (k msgServer) Deposit(goCtx context.Context, msg *types.MsgDeposit) (*types
 		return nil, err
 	}
 
-	defer telemetry.IncrCounterWithLabels(
+	defer ctx.Telemetry().IncrCounterWithLabels(
 		[]string{types.ModuleName, "deposit"},
 		1,
 		[]metrics.Label{
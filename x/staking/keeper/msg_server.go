@@ -222,8 +222,8 @@ func (k msgServer) Delegate(goCtx context.Context, msg *types.MsgDelegate) (*typ
 
 	if msg.Amount.Amount.IsInt64() {
 		defer func() {
-			telemetry.IncrCounter(1, types.ModuleName, "delegate")
-			telemetry.SetGaugeWithLabels(
+			ctx.Telemetry().IncrCounter(1, types.ModuleName, "delegate")
+			ctx.Telemetry().SetGaugeWithLabels(
 				[]string{"tx", "msg", msg.Type()},
 				float32(msg.Amount.Amount.Int64()),
 				[]metrics.Label{telemetry.NewLabel("denom", msg.Amount.Denom)},
@@ -287,8 +287,8 @@ func (k msgServer) BeginRedelegate(goCtx context.Context, msg *types.MsgBeginRed
 
 	if msg.Amount.Amount.IsInt64() {
 		defer func() {
-			telemetry.IncrCounter(1, types.ModuleName, "redelegate")
-			telemetry.SetGaugeWithLabels(
+			ctx.Telemetry().IncrCounter(1, types.ModuleName, "redelegate")
+			ctx.Telemetry().SetGaugeWithLabels(
 				[]string{"tx", "msg", msg.Type()},
 				float32(msg.Amount.Amount.Int64()),
 				[]metrics.Label{telemetry.NewLabel("denom", msg.Amount.Denom)},
@@ -349,8 +349,8 @@ func (k msgServer) Undelegate(goCtx context.Context, msg *types.MsgUndelegate) (
 
 	if msg.Amount.Amount.IsInt64() {
 		defer func() {
-			telemetry.IncrCounter(1, types.ModuleName, "undelegate")
-			telemetry.SetGaugeWithLabels(
+			ctx.Telemetry().IncrCounter(1, types.ModuleName, "undelegate")
+			ctx.Telemetry().SetGaugeWithLabels(
 				[]string{"tx", "msg", msg.Type()},
 				float32(msg.Amount.Amount.Int64()),
 				[]metrics.Label{telemetry.NewLabel("denom", msg.Amount.Denom)},
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// Kinds of the staking store changes decoded by the staking writeset decoder
+const (
+	WritesetChangeValidator           = "validator"
+	WritesetChangeDelegation          = "delegation"
+	WritesetChangeUnbondingDelegation = "unbonding_delegation"
+	WritesetChangeRedelegation        = "redelegation"
+)
+
+// RegisterWritesetDecoders registers the decoder of the writes to the staking store, which decodes validator,
+// delegation, unbonding delegation and redelegation updates into their records, identified by the addresses in their
+// keys. Index and queue keys are unknown changes, as they only mirror the records.
+func RegisterWritesetDecoders(registry *sdk.WritesetDecoderRegistry, storeKey sdk.StoreKey, cdc codec.BinaryCodec) {
+	registry.Register(storeKey, sdk.WritesetDecoderFunc(func(key, value []byte) (sdk.WritesetChange, error) {
+		if len(key) == 0 {
+			return sdk.WritesetChange{Kind: sdk.WritesetChangeUnknown}, nil
+		}
+		addrs, ok := splitLengthPrefixedAddresses(key[1:])
+		var change sdk.WritesetChange
+		var err error
+		switch {
+		case !ok:
+			return sdk.WritesetChange{Kind: sdk.WritesetChangeUnknown}, nil
+		case key[0] == ValidatorsKey[0] && len(addrs) == 1:
+			change.Kind = WritesetChangeValidator
+			change.Attributes = map[string]string{"operator": sdk.ValAddress(addrs[0]).String()}
+			if value != nil {
+				change.Value, err = UnmarshalValidator(cdc, value)
+			}
+		case key[0] == DelegationKey[0] && len(addrs) == 2:
+			change.Kind = WritesetChangeDelegation
+			change.Attributes = map[string]string{
+				"delegator": sdk.AccAddress(addrs[0]).String(),
+				"validator": sdk.ValAddress(addrs[1]).String(),
+			}
+			if value != nil {
+				change.Value, err = UnmarshalDelegation(cdc, value)
+			}
+		case key[0] == UnbondingDelegationKey[0] && len(addrs) == 2:
+			change.Kind = WritesetChangeUnbondingDelegation
+			change.Attributes = map[string]string{
+				"delegator": sdk.AccAddress(addrs[0]).String(),
+				"validator": sdk.ValAddress(addrs[1]).String(),
+			}
+			if value != nil {
+				change.Value, err = UnmarshalUBD(cdc, value)
+			}
+		case key[0] == RedelegationKey[0] && len(addrs) == 3:
+			change.Kind = WritesetChangeRedelegation
+			change.Attributes = map[string]string{
+				"delegator":     sdk.AccAddress(addrs[0]).String(),
+				"src_validator": sdk.ValAddress(addrs[1]).String(),
+				"dst_validator": sdk.ValAddress(addrs[2]).String(),
+			}
+			if value != nil {
+				change.Value, err = UnmarshalRED(cdc, value)
+			}
+		default:
+			return sdk.WritesetChange{Kind: sdk.WritesetChangeUnknown}, nil
+		}
+		if err != nil {
+			return sdk.WritesetChange{}, err
+		}
+		return change, nil
+	}))
+}
+
+// splitLengthPrefixedAddresses splits a key suffix made only of length prefixed addresses, returning false if it
+// isn't one
+func splitLengthPrefixedAddresses(bz []byte) ([][]byte, bool) {
+	var addrs [][]byte
+	for len(bz) > 0 {
+		addrLen := int(bz[0])
+		if addrLen == 0 || len(bz) < 1+addrLen {
+			return nil, false
+		}
+		addrs = append(addrs, bz[1:1+addrLen])
+		bz = bz[1+addrLen:]
+	}
+	return addrs, true
+}
@@ -0,0 +1,70 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cosmos/cosmos-sdk/codec"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/staking/types"
+)
+
+func TestWritesetDecoders(t *testing.T) {
+	cdc := codec.NewProtoCodec(codectypes.NewInterfaceRegistry())
+	storeKey := sdk.NewKVStoreKey(types.StoreKey)
+	registry := sdk.NewWritesetDecoderRegistry()
+	types.RegisterWritesetDecoders(registry, storeKey, cdc)
+
+	delAddr := sdk.AccAddress(keysAddr1)
+	valSrc := sdk.ValAddress(keysAddr2)
+	valDst := sdk.ValAddress(keysAddr3)
+	validator := types.Validator{OperatorAddress: valSrc.String(), Tokens: sdk.NewInt(10), DelegatorShares: sdk.NewDec(10)}
+	delegation := types.NewDelegation(delAddr, valSrc, sdk.NewDec(5))
+	validatorKey := string(types.GetValidatorKey(valSrc))
+	delegationKey := string(types.GetDelegationKey(delAddr, valSrc))
+	ubdKey := string(types.GetUBDKey(delAddr, valSrc))
+	redKey := string(types.GetREDKey(delAddr, valSrc, valDst))
+	indexKey := string(types.GetUBDByValIndexKey(delAddr, valSrc))
+
+	changes, err := registry.DecodeWriteset(storeKey.Name(), map[string][]byte{
+		validatorKey:  cdc.MustMarshal(&validator),
+		delegationKey: types.MustMarshalDelegation(cdc, delegation),
+		ubdKey:        nil,
+		redKey:        nil,
+		indexKey:      []byte{},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []sdk.WritesetChange{
+		{
+			Kind:       types.WritesetChangeValidator,
+			Key:        []byte(validatorKey),
+			Attributes: map[string]string{"operator": valSrc.String()},
+			Value:      validator,
+		},
+		{
+			Kind:       types.WritesetChangeDelegation,
+			Key:        []byte(delegationKey),
+			Attributes: map[string]string{"delegator": delAddr.String(), "validator": valSrc.String()},
+			Value:      delegation,
+		},
+		{
+			Kind:       types.WritesetChangeUnbondingDelegation,
+			Key:        []byte(ubdKey),
+			Attributes: map[string]string{"delegator": delAddr.String(), "validator": valSrc.String()},
+			Deleted:    true,
+		},
+		// index keys only mirror the records
+		{Kind: sdk.WritesetChangeUnknown, Key: []byte(indexKey)},
+		{
+			Kind:       types.WritesetChangeRedelegation,
+			Key:        []byte(redKey),
+			Attributes: map[string]string{"delegator": delAddr.String(), "src_validator": valSrc.String(), "dst_validator": valDst.String()},
+			Deleted:    true,
+		},
+	}, changes)
+
+	_, err = registry.DecodeWriteset(storeKey.Name(), map[string][]byte{delegationKey: []byte("invalid")})
+	require.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/store/multiversion"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// RegisterWritesetEstimators registers the writeset estimators of staking msgs that move delegations. They're
+// estimated to write the delegations and validators involved in the staking store, and any unbonding delegation or
+// redelegation they create. Pool balances and power index updates depend on state and aren't estimated.
+func RegisterWritesetEstimators(registry *sdk.WritesetEstimatorRegistry, storeKey sdk.StoreKey) {
+	estimator := sdk.WritesetEstimatorFunc(func(_ sdk.Context, msg sdk.Msg) (sdk.MappedWritesets, error) {
+		var keys [][]byte
+		switch msg := msg.(type) {
+		case *MsgDelegate:
+			delAddr, valAddr, err := parseDelegationAddrs(msg.DelegatorAddress, msg.ValidatorAddress)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, GetDelegationKey(delAddr, valAddr), GetValidatorKey(valAddr))
+		case *MsgUndelegate:
+			delAddr, valAddr, err := parseDelegationAddrs(msg.DelegatorAddress, msg.ValidatorAddress)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys,
+				GetDelegationKey(delAddr, valAddr),
+				GetValidatorKey(valAddr),
+				GetUBDKey(delAddr, valAddr),
+				GetUBDByValIndexKey(delAddr, valAddr),
+			)
+		case *MsgBeginRedelegate:
+			delAddr, valSrcAddr, err := parseDelegationAddrs(msg.DelegatorAddress, msg.ValidatorSrcAddress)
+			if err != nil {
+				return nil, err
+			}
+			valDstAddr, err := sdk.ValAddressFromBech32(msg.ValidatorDstAddress)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys,
+				GetDelegationKey(delAddr, valSrcAddr),
+				GetDelegationKey(delAddr, valDstAddr),
+				GetValidatorKey(valSrcAddr),
+				GetValidatorKey(valDstAddr),
+				GetREDKey(delAddr, valSrcAddr, valDstAddr),
+				GetREDByValSrcIndexKey(delAddr, valSrcAddr, valDstAddr),
+				GetREDByValDstIndexKey(delAddr, valSrcAddr, valDstAddr),
+			)
+		default:
+			return nil, fmt.Errorf("unexpected msg type %T", msg)
+		}
+
+		writeset := make(multiversion.WriteSet, len(keys))
+		for _, key := range keys {
+			writeset[string(key)] = nil
+		}
+		return sdk.MappedWritesets{storeKey: writeset}, nil
+	})
+	registry.Register(&MsgDelegate{}, estimator)
+	registry.Register(&MsgUndelegate{}, estimator)
+	registry.Register(&MsgBeginRedelegate{}, estimator)
+}
+
+func parseDelegationAddrs(delegator, validator string) (sdk.AccAddress, sdk.ValAddress, error) {
+	delAddr, err := sdk.AccAddressFromBech32(delegator)
+	if err != nil {
+		return nil, nil, err
+	}
+	valAddr, err := sdk.ValAddressFromBech32(validator)
+	if err != nil {
+		return nil, nil, err
+	}
+	return delAddr, valAddr, nil
+}
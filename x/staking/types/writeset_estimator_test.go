@@ -0,0 +1,53 @@
+package types_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/staking/types"
+)
+
+// msgsTx is a tx that only carries msgs
+type msgsTx []sdk.Msg
+
+func (tx msgsTx) GetMsgs() []sdk.Msg   { return tx }
+func (tx msgsTx) ValidateBasic() error { return nil }
+
+func TestWritesetEstimators(t *testing.T) {
+	storeKey := sdk.NewKVStoreKey(types.StoreKey)
+	registry := sdk.NewWritesetEstimatorRegistry()
+	types.RegisterWritesetEstimators(registry, storeKey)
+
+	delAddr := sdk.AccAddress(keysAddr1)
+	valSrc := sdk.ValAddress(keysAddr2)
+	valDst := sdk.ValAddress(keysAddr3)
+	coin := sdk.NewInt64Coin(sdk.DefaultBondDenom, 10)
+
+	estimate := func(msg sdk.Msg) map[string][]byte {
+		writesets, err := registry.EstimateTxWritesets(sdk.Context{}, msgsTx{msg})
+		require.NoError(t, err)
+		return writesets[storeKey]
+	}
+
+	require.Equal(t, map[string][]byte{
+		string(types.GetDelegationKey(delAddr, valSrc)): nil,
+		string(types.GetValidatorKey(valSrc)):           nil,
+	}, estimate(types.NewMsgDelegate(delAddr, valSrc, coin)))
+
+	require.Equal(t, map[string][]byte{
+		string(types.GetDelegationKey(delAddr, valSrc)):    nil,
+		string(types.GetValidatorKey(valSrc)):              nil,
+		string(types.GetUBDKey(delAddr, valSrc)):           nil,
+		string(types.GetUBDByValIndexKey(delAddr, valSrc)): nil,
+	}, estimate(types.NewMsgUndelegate(delAddr, valSrc, coin)))
+
+	redelegation := estimate(types.NewMsgBeginRedelegate(delAddr, valSrc, valDst, coin))
+	require.Len(t, redelegation, 7)
+	require.Contains(t, redelegation, string(types.GetDelegationKey(delAddr, valDst)))
+	require.Contains(t, redelegation, string(types.GetREDKey(delAddr, valSrc, valDst)))
+
+	_, err := registry.EstimateTxWritesets(sdk.Context{}, msgsTx{&types.MsgDelegate{DelegatorAddress: "invalid"}})
+	require.Error(t, err)
+}